@@ -0,0 +1,268 @@
+package miniredis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2/proto"
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+func TestCommandGetkeys(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	mustDo(t, c,
+		"COMMAND", "GETKEYS", "GET", "foo",
+		proto.Strings("foo"),
+	)
+	mustDo(t, c,
+		"COMMAND", "getkeys", "mset", "k1", "v1", "k2", "v2",
+		proto.Strings("k1", "k2"),
+	)
+	mustDo(t, c,
+		"COMMAND", "GETKEYS", "BLPOP", "l1", "l2", "0",
+		proto.Strings("l1", "l2"),
+	)
+	mustDo(t, c,
+		"COMMAND", "GETKEYS", "EVAL", "return 1", "2", "k1", "k2", "arg",
+		proto.Strings("k1", "k2"),
+	)
+	mustDo(t, c,
+		"COMMAND", "GETKEYS", "EVAL", "return 1", "0",
+		proto.Strings(),
+	)
+	mustDo(t, c,
+		"COMMAND", "GETKEYS", "ZUNIONSTORE", "dest", "2", "z1", "z2", "WEIGHTS", "1", "2",
+		proto.Strings("dest", "z1", "z2"),
+	)
+	mustDo(t, c,
+		"COMMAND", "GETKEYS", "XREAD", "COUNT", "2", "STREAMS", "s1", "s2", "0", "0",
+		proto.Strings("s1", "s2"),
+	)
+	mustDo(t, c,
+		"COMMAND", "GETKEYS", "SORT", "src", "BY", "w_*", "STORE", "dst",
+		proto.Strings("src", "dst"),
+	)
+
+	t.Run("flags", func(t *testing.T) {
+		mustDo(t, c,
+			"COMMAND", "GETKEYSANDFLAGS", "GET", "foo",
+			proto.Array(
+				proto.Array(proto.String("foo"), proto.Array(proto.Inline("RO"), proto.Inline("access"))),
+			),
+		)
+		mustDo(t, c,
+			"COMMAND", "GETKEYSANDFLAGS", "RENAME", "a", "b",
+			proto.Array(
+				proto.Array(proto.String("a"), proto.Array(proto.Inline("RW"), proto.Inline("access"), proto.Inline("delete"))),
+				proto.Array(proto.String("b"), proto.Array(proto.Inline("OW"), proto.Inline("update"))),
+			),
+		)
+		mustDo(t, c,
+			"COMMAND", "GETKEYSANDFLAGS", "WATCH", "a",
+			proto.Array(
+				proto.Array(proto.String("a"), proto.Array(proto.Inline("RO"))),
+			),
+		)
+		mustDo(t, c,
+			"COMMAND", "GETKEYSANDFLAGS", "DEL", "a",
+			proto.Array(
+				proto.Array(proto.String("a"), proto.Array(proto.Inline("RM"), proto.Inline("delete"))),
+			),
+		)
+		mustDo(t, c,
+			"COMMAND", "GETKEYSANDFLAGS", "SUNIONSTORE", "dst", "a",
+			proto.Array(
+				proto.Array(proto.String("dst"), proto.Array(proto.Inline("OW"), proto.Inline("update"))),
+				proto.Array(proto.String("a"), proto.Array(proto.Inline("RO"), proto.Inline("access"))),
+			),
+		)
+		mustDo(t, c,
+			"COMMAND", "GETKEYSANDFLAGS", "INCR", "a",
+			proto.Array(
+				proto.Array(proto.String("a"), proto.Array(proto.Inline("RW"), proto.Inline("access"), proto.Inline("update"))),
+			),
+		)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"COMMAND", "GETKEYS",
+			proto.Error("ERR wrong number of arguments for 'command|getkeys' command"),
+		)
+		mustDo(t, c,
+			"COMMAND", "GETKEYSANDFLAGS",
+			proto.Error("ERR wrong number of arguments for 'command|getkeysandflags' command"),
+		)
+		mustDo(t, c,
+			"COMMAND", "GETKEYS", "NOSUCH", "foo",
+			proto.Error(msgInvalidCommand),
+		)
+		mustDo(t, c,
+			"COMMAND", "GETKEYS", "PING",
+			proto.Error(msgCommandNoKeys),
+		)
+		mustDo(t, c,
+			"COMMAND", "GETKEYS", "GET",
+			proto.Error(msgCommandArgsNumber),
+		)
+		mustDo(t, c,
+			"COMMAND", "GETKEYS", "XREAD", "COUNT", "2",
+			proto.Error(msgCommandArgs),
+		)
+	})
+}
+
+func TestCommand(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	t.Run("table", func(t *testing.T) {
+		for _, cmd := range s.srv.Commands() {
+			ci, found := commandInfos[cmd]
+			if !found {
+				t.Errorf("no COMMAND info for %s", cmd)
+				continue
+			}
+			if !hasKeys(cmd) || ci.group == "scripting" {
+				continue
+			}
+			write := strings.Contains(" "+ci.flags+" ", " write ")
+			if write != writeCommand(cmd) {
+				t.Errorf("%s: write flag is %t", cmd, write)
+			}
+		}
+	})
+
+	mustDo(t, c,
+		"COMMAND", "COUNT",
+		proto.Int(len(s.srv.Commands())),
+	)
+
+	mustDo(t, c,
+		"COMMAND", "INFO", "get", "nosuch", "MSET",
+		proto.Array(
+			proto.Array(
+				proto.String("get"),
+				proto.Int(2),
+				proto.Array(proto.Inline("readonly"), proto.Inline("fast")),
+				proto.Int(1),
+				proto.Int(1),
+				proto.Int(1),
+				proto.Array(proto.Inline("@read"), proto.Inline("@string"), proto.Inline("@fast")),
+				proto.Array(),
+				proto.Array(),
+				proto.Array(),
+			),
+			proto.Nil,
+			proto.Array(
+				proto.String("mset"),
+				proto.Int(-3),
+				proto.Array(proto.Inline("write"), proto.Inline("denyoom")),
+				proto.Int(1),
+				proto.Int(-1),
+				proto.Int(2),
+				proto.Array(proto.Inline("@write"), proto.Inline("@string"), proto.Inline("@slow")),
+				proto.Array(),
+				proto.Array(),
+				proto.Array(),
+			),
+		),
+	)
+
+	mustDo(t, c,
+		"COMMAND", "INFO", "EVAL",
+		proto.Array(
+			proto.Array(
+				proto.String("eval"),
+				proto.Int(-3),
+				proto.Array(
+					proto.Inline("noscript"),
+					proto.Inline("stale"),
+					proto.Inline("skip_monitor"),
+					proto.Inline("may_replicate"),
+					proto.Inline("no_mandatory_keys"),
+					proto.Inline("movablekeys"),
+				),
+				proto.Int(0),
+				proto.Int(0),
+				proto.Int(0),
+				proto.Array(proto.Inline("@scripting"), proto.Inline("@slow")),
+				proto.Array(),
+				proto.Array(),
+				proto.Array(),
+			),
+		),
+	)
+
+	t.Run("all", func(t *testing.T) {
+		res, err := c.Do("COMMAND")
+		ok(t, err)
+		all, err := proto.Parse(res)
+		ok(t, err)
+		equals(t, len(s.srv.Commands()), len(all.([]interface{})))
+	})
+
+	mustDo(t, c,
+		"COMMAND", "DOCS", "get", "nosuch",
+		proto.Array(
+			proto.String("get"),
+			proto.Array(
+				proto.String("summary"),
+				proto.String("Returns the string value of a key."),
+				proto.String("since"),
+				proto.String("1.0.0"),
+				proto.String("group"),
+				proto.String("string"),
+			),
+		),
+	)
+
+	t.Run("containers", func(t *testing.T) {
+		// no keys, those are for the subcommands
+		for _, cmd := range []string{"OBJECT", "XGROUP", "XINFO"} {
+			res, err := c.Do("COMMAND", "INFO", cmd)
+			ok(t, err)
+			info, err := proto.Parse(res)
+			ok(t, err)
+			equals(t, []interface{}{0, 0, 0}, info.([]interface{})[0].([]interface{})[3:6])
+		}
+		mustDo(t, c,
+			"COMMAND", "GETKEYS", "XGROUP", "CREATE", "planets", "grp", "$",
+			proto.Strings("planets"),
+		)
+	})
+
+	t.Run("own command", func(t *testing.T) {
+		ok(t, s.Server().Register("MYCMD", func(c *server.Peer, cmd string, args []string) {
+			c.WriteOK()
+		}))
+		mustDo(t, c,
+			"COMMAND", "DOCS", "mycmd",
+			proto.Array(
+				proto.String("mycmd"),
+				proto.Array(),
+			),
+		)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"COMMAND", "COUNT", "foo",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'count'. Try COMMAND HELP."),
+		)
+		mustDo(t, c,
+			"COMMAND", "NOSUCH",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'nosuch'. Try COMMAND HELP."),
+		)
+	})
+}
@@ -6,12 +6,14 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alicebob/miniredis/v2/server"
 )
 
 func commandsConnection(m *Miniredis) {
 	m.srv.Register("AUTH", m.cmdAuth)
+	m.srv.Register("CLIENT", m.cmdClient)
 	m.srv.Register("ECHO", m.cmdEcho)
 	m.srv.Register("HELLO", m.cmdHello)
 	m.srv.Register("PING", m.cmdPing)
@@ -168,7 +170,7 @@ func (m *Miniredis) cmdHello(c *server.Peer, cmd string, args []string) {
 	c.WriteBulk("server")
 	c.WriteBulk("miniredis")
 	c.WriteBulk("version")
-	c.WriteBulk("6.0.5")
+	c.WriteBulk(redisVersion)
 	c.WriteBulk("proto")
 	c.WriteInt(version)
 	c.WriteBulk("id")
@@ -181,6 +183,382 @@ func (m *Miniredis) cmdHello(c *server.Peer, cmd string, args []string) {
 	c.WriteLen(0)
 }
 
+// CLIENT
+func (m *Miniredis) cmdClient(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	subcmd := strings.ToUpper(args[0])
+	switch {
+	case subcmd == "CACHING" && len(args) == 2:
+	case subcmd == "GETNAME" && len(args) == 1:
+	case subcmd == "ID" && len(args) == 1:
+	case subcmd == "INFO" && len(args) == 1:
+	case subcmd == "LIST":
+	case subcmd == "SETNAME" && len(args) == 2:
+	case subcmd == "TRACKING" && len(args) >= 2:
+	default:
+		setDirty(c)
+		c.WriteError(fmt.Sprintf(msgFClientUsage, args[0]))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	switch subcmd {
+	case "CACHING":
+		m.cmdClientCaching(c, args[1:])
+	case "GETNAME":
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			if ctx.name == "" {
+				c.WriteNull()
+				return
+			}
+			c.WriteBulk(ctx.name)
+		})
+	case "ID":
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			c.WriteInt(c.ID)
+		})
+	case "INFO":
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			c.WriteBulk(m.clientInfo(c, ctx, "client|info") + "\n")
+		})
+	case "LIST":
+		m.cmdClientList(c, args[1:])
+	case "SETNAME":
+		m.cmdClientSetname(c, args[1])
+	case "TRACKING":
+		m.cmdClientTracking(c, args[1:])
+	}
+}
+
+// CLIENT CACHING
+func (m *Miniredis) cmdClientCaching(c *server.Peer, args []string) {
+	caching := strings.ToLower(args[0])
+	if caching != "yes" && caching != "no" {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		t, ok := m.trackers[c]
+		switch {
+		case !ok || !(t.optin || t.optout):
+			c.WriteError("ERR CLIENT CACHING can be called only when the client is in tracking mode with OPTIN or OPTOUT mode enabled")
+		case caching == "yes" && !t.optin:
+			c.WriteError("ERR CLIENT CACHING YES is only valid when tracking is enabled in OPTIN mode.")
+		case caching == "no" && !t.optout:
+			c.WriteError("ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.")
+		default:
+			t.caching = caching
+			c.WriteOK()
+		}
+	})
+}
+
+// CLIENT LIST
+func (m *Miniredis) cmdClientList(c *server.Peer, args []string) {
+	var opts struct {
+		typ string
+		ids map[int]bool
+	}
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "TYPE":
+			if len(args) < 2 || opts.ids != nil {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			opts.typ = strings.ToLower(args[1])
+			switch opts.typ {
+			case "normal", "master", "replica", "slave", "pubsub":
+			default:
+				setDirty(c)
+				c.WriteError(fmt.Sprintf("ERR Unknown client type '%s'", args[1]))
+				return
+			}
+			args = args[2:]
+		case "ID":
+			if len(args) < 2 || opts.typ != "" {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			opts.ids = map[int]bool{}
+			for _, a := range args[1:] {
+				id, err := strconv.Atoi(a)
+				if err != nil || id <= 0 {
+					setDirty(c)
+					c.WriteError("ERR Invalid client ID")
+					return
+				}
+				opts.ids[id] = true
+			}
+			args = nil
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		var b strings.Builder
+		for _, p := range m.srv.Peers() {
+			if opts.ids != nil && !opts.ids[p.ID] {
+				continue
+			}
+			pctx, _ := p.Ctx.(*connCtx)
+			if pctx == nil {
+				pctx = &connCtx{}
+			}
+			switch opts.typ {
+			case "":
+			case "normal":
+				if pctx.subscriber != nil {
+					continue
+				}
+			case "pubsub":
+				if pctx.subscriber == nil {
+					continue
+				}
+			default:
+				// no replication in miniredis
+				continue
+			}
+			current := ""
+			if p == c {
+				current = "client|list"
+			}
+			b.WriteString(m.clientInfo(p, pctx, current))
+			b.WriteString("\n")
+		}
+		c.WriteBulk(b.String())
+	})
+}
+
+// clientInfo is a CLIENT LIST line, without newline. current is the command
+// running right now if p is the client asking, and "" otherwise. Must be
+// called with m locked.
+func (m *Miniredis) clientInfo(p *server.Peer, ctx *connCtx, current string) string {
+	now := m.effectiveNow()
+	var age, idle time.Duration
+	if !ctx.created.IsZero() {
+		age, idle = now.Sub(ctx.created), now.Sub(ctx.lastActive)
+	}
+	cmd := ctx.lastCmd
+	if current != "" {
+		cmd, idle = current, 0
+	}
+	if cmd == "" {
+		cmd = "NULL"
+	}
+
+	flags := ""
+	sub, psub, ssub := 0, 0, 0
+	if s := ctx.subscriber; s != nil {
+		flags += "P"
+		s.mu.Lock()
+		sub, psub, ssub = len(s.channels), len(s.patterns), len(s.shardChannels)
+		s.mu.Unlock()
+	}
+	multi := -1
+	if inTx(ctx) {
+		flags += "x"
+		multi = len(ctx.transaction)
+	}
+	redir := -1
+	if t, ok := m.trackers[p]; ok {
+		flags += "t"
+		if t.redirect != 0 {
+			redir = t.redirect
+		}
+	}
+	if flags == "" {
+		flags = "N"
+	}
+	resp := 2
+	if p.Resp3 {
+		resp = 3
+	}
+
+	return fmt.Sprintf(
+		"id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=%d sub=%d psub=%d ssub=%d multi=%d cmd=%s user=default redir=%d resp=%d",
+		p.ID, p.RemoteAddr(), p.LocalAddr(), ctx.name,
+		int(age/time.Second), int(idle/time.Second),
+		flags, ctx.selectedDB, sub, psub, ssub, multi, cmd, redir, resp,
+	)
+}
+
+// CLIENT SETNAME
+func (m *Miniredis) cmdClientSetname(c *server.Peer, name string) {
+	for _, r := range name {
+		if r < '!' || r > '~' {
+			setDirty(c)
+			c.WriteError(msgClientName)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		ctx.name = name
+		c.WriteOK()
+	})
+}
+
+// subcommandCommands are the container commands. CLIENT LIST shows them as
+// "command|subcommand".
+var subcommandCommands = map[string]bool{
+	"ACL":      true,
+	"CLIENT":   true,
+	"CLUSTER":  true,
+	"COMMAND":  true,
+	"CONFIG":   true,
+	"FUNCTION": true,
+	"MEMORY":   true,
+	"OBJECT":   true,
+	"PUBSUB":   true,
+	"SCRIPT":   true,
+	"XGROUP":   true,
+	"XINFO":    true,
+}
+
+// clientActive records the last command of a client, for CLIENT LIST.
+func (m *Miniredis) clientActive(c *server.Peer, cmd string, args ...string) {
+	ctx := getCtx(c)
+	if ctx.nested {
+		return
+	}
+	name := strings.ToLower(cmd)
+	if subcommandCommands[cmd] && len(args) > 0 {
+		name += "|" + strings.ToLower(args[0])
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	ctx.lastActive = m.effectiveNow()
+	ctx.lastCmd = name
+}
+
+// CLIENT TRACKING
+func (m *Miniredis) cmdClientTracking(c *server.Peer, args []string) {
+	var on bool
+	switch strings.ToUpper(args[0]) {
+	case "ON":
+		on = true
+	case "OFF":
+	default:
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+
+	opts := clientTracking{keys: map[string]struct{}{}}
+	for args = args[1:]; len(args) > 0; args = args[1:] {
+		switch strings.ToUpper(args[0]) {
+		case "BCAST":
+			opts.bcast = true
+		case "OPTIN":
+			opts.optin = true
+		case "OPTOUT":
+			opts.optout = true
+		case "NOLOOP":
+			opts.noloop = true
+		case "REDIRECT":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			id, err := strconv.Atoi(args[1])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			opts.redirect = id
+			args = args[1:]
+		case "PREFIX":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			opts.prefixes = append(opts.prefixes, args[1])
+			args = args[1:]
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		if !on {
+			delete(m.trackers, c)
+			c.WriteOK()
+			return
+		}
+
+		old, tracking := m.trackers[c]
+		switch {
+		case len(opts.prefixes) > 0 && !opts.bcast:
+			c.WriteError("ERR PREFIX option requires BCAST mode to be enabled")
+			return
+		case tracking && old.bcast != opts.bcast:
+			c.WriteError("ERR You can't switch BCAST mode on/off before disabling tracking for this client, and then re-enabling it with a different mode.")
+			return
+		case opts.bcast && (opts.optin || opts.optout):
+			c.WriteError("ERR OPTIN and OPTOUT are not compatible with BCAST")
+			return
+		case opts.optin && opts.optout:
+			c.WriteError("ERR You can't use both OPTIN and OPTOUT")
+			return
+		case opts.redirect != 0 && m.srv.Peer(opts.redirect) == nil:
+			c.WriteError("ERR The client ID you want redirect to does not exist")
+			return
+		case tracking && ((opts.optin && old.optout) || (opts.optout && old.optin)):
+			c.WriteError("ERR You can't switch OPTIN/OPTOUT mode before disabling tracking for this client, and then re-enabling it with a different mode.")
+			return
+		}
+		if opts.bcast {
+			var existing []string
+			if tracking {
+				existing = old.prefixes
+			}
+			if msg := checkPrefixes(existing, opts.prefixes); msg != "" {
+				c.WriteError(msg)
+				return
+			}
+		}
+
+		m.enableTracking(c, &opts)
+		c.WriteOK()
+	})
+}
+
+// checkPrefixes checks that BCAST prefixes don't overlap. Returns an error
+// message on failure.
+func checkPrefixes(existing, prefixes []string) string {
+	for i, p := range prefixes {
+		for _, o := range append(existing, prefixes[:i]...) {
+			if p != o && (strings.HasPrefix(p, o) || strings.HasPrefix(o, p)) {
+				return fmt.Sprintf("ERR Prefix '%s' overlaps with an existing prefix '%s'. Prefixes for a single client must not overlap.", p, o)
+			}
+		}
+	}
+	return ""
+}
+
 // ECHO
 func (m *Miniredis) cmdEcho(c *server.Peer, cmd string, args []string) {
 	if len(args) != 1 {
@@ -223,7 +601,7 @@ func (m *Miniredis) cmdSelect(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		if id < 0 {
-			c.WriteError("ERR DB index is out of range")
+			c.WriteError(msgDBIndexOutOfRange)
 			setDirty(c)
 			return
 		}
@@ -258,7 +636,7 @@ func (m *Miniredis) cmdSwapdb(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		if id1 < 0 || id2 < 0 {
-			c.WriteError("ERR DB index is out of range")
+			c.WriteError(msgDBIndexOutOfRange)
 			setDirty(c)
 			return
 		}
@@ -1,7 +1,10 @@
 package miniredis
 
 import (
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2/proto"
 )
@@ -336,3 +339,443 @@ func TestHello(t *testing.T) {
 		})
 	})
 }
+
+func TestClientTracking(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+
+	dial := func() *proto.Client {
+		c, err := proto.Dial(s.Addr())
+		ok(t, err)
+		_, err = c.Do("HELLO", "3")
+		ok(t, err)
+		return c
+	}
+	clientID := func(c *proto.Client) int {
+		res, err := c.Do("CLIENT", "ID")
+		ok(t, err)
+		id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(res, ":"), "\r\n"))
+		ok(t, err)
+		return id
+	}
+
+	t.Run("default", func(t *testing.T) {
+		c1 := dial()
+		defer c1.Close()
+		c2 := dial()
+		defer c2.Close()
+
+		mustOK(t, c1, "CLIENT", "TRACKING", "ON")
+		mustOK(t, c2, "SET", "foo", "bar")
+		mustDo(t, c1, "GET", "foo", proto.String("bar"))
+		mustOK(t, c2, "SET", "foo", "baz")
+		mustRead(t, c1, proto.Push(proto.String("invalidate"), proto.Strings("foo")))
+
+		// only once, until it's read again
+		mustOK(t, c2, "SET", "foo", "again")
+		mustDo(t, c1, "PING", proto.Inline("PONG"))
+
+		// own changes also invalidate
+		mustDo(t, c1, "GET", "foo", proto.String("again"))
+		mustOK(t, c1, "SET", "foo", "mine")
+		mustRead(t, c1, proto.Push(proto.String("invalidate"), proto.Strings("foo")))
+
+		// flushes invalidate everything
+		mustOK(t, c2, "FLUSHALL")
+		mustRead(t, c1, proto.Push(proto.String("invalidate"), "_\r\n"))
+
+		// WATCH doesn't read anything
+		mustOK(t, c1, "WATCH", "watched")
+		mustOK(t, c2, "SET", "watched", "bar")
+		mustDo(t, c1, "PING", proto.Inline("PONG"))
+		mustOK(t, c1, "UNWATCH")
+
+		mustOK(t, c1, "CLIENT", "TRACKING", "OFF")
+		mustDo(t, c1, "GET", "foo", "_\r\n")
+		mustOK(t, c2, "SET", "foo", "bar")
+		mustDo(t, c1, "PING", proto.Inline("PONG"))
+
+		// no bookkeeping without tracking clients
+		s.Lock()
+		equals(t, 0, len(s.changedKeys))
+		s.Unlock()
+	})
+
+	t.Run("bcast", func(t *testing.T) {
+		c1 := dial()
+		defer c1.Close()
+		c2 := dial()
+		defer c2.Close()
+
+		mustOK(t, c1, "CLIENT", "TRACKING", "ON", "BCAST", "PREFIX", "user:", "PREFIX", "session:")
+		mustOK(t, c2, "SET", "user:1", "bar")
+		mustRead(t, c1, proto.Push(proto.String("invalidate"), proto.Strings("user:1")))
+		mustOK(t, c2, "MSET", "session:1", "a", "other", "b", "user:2", "c")
+		mustRead(t, c1, proto.Push(proto.String("invalidate"), proto.Strings("session:1", "user:2")))
+	})
+
+	t.Run("optin", func(t *testing.T) {
+		c1 := dial()
+		defer c1.Close()
+		c2 := dial()
+		defer c2.Close()
+
+		mustOK(t, c1, "CLIENT", "TRACKING", "ON", "OPTIN")
+		mustDo(t, c1, "GET", "in1", "_\r\n")
+		mustOK(t, c1, "CLIENT", "CACHING", "YES")
+		mustDo(t, c1, "GET", "in2", "_\r\n")
+		mustOK(t, c2, "MSET", "in1", "1", "in2", "2")
+		mustRead(t, c1, proto.Push(proto.String("invalidate"), proto.Strings("in2")))
+		mustDo(t, c1, "PING", proto.Inline("PONG"))
+	})
+
+	t.Run("noloop", func(t *testing.T) {
+		c1 := dial()
+		defer c1.Close()
+
+		mustOK(t, c1, "CLIENT", "TRACKING", "ON", "NOLOOP")
+		mustDo(t, c1, "GET", "loop", "_\r\n")
+		mustOK(t, c1, "SET", "loop", "bar")
+		mustDo(t, c1, "PING", proto.Inline("PONG"))
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		// old style RESP2 client
+		c1, err := proto.Dial(s.Addr())
+		ok(t, err)
+		defer c1.Close()
+		c2, err := proto.Dial(s.Addr())
+		ok(t, err)
+		defer c2.Close()
+		c3 := dial()
+		defer c3.Close()
+
+		id := strconv.Itoa(clientID(c1))
+
+		mustDo(t, c1,
+			"SUBSCRIBE", "__redis__:invalidate",
+			proto.Array(proto.String("subscribe"), proto.String("__redis__:invalidate"), proto.Int(1)),
+		)
+		mustOK(t, c2, "CLIENT", "TRACKING", "ON", "REDIRECT", id)
+		mustNil(t, c2, "GET", "redir")
+		mustOK(t, c3, "SET", "redir", "bar")
+		mustRead(t, c1, proto.Array(proto.String("message"), proto.String("__redis__:invalidate"), proto.Strings("redir")))
+
+		mustOK(t, c3, "FLUSHDB")
+		mustRead(t, c1, proto.Array(proto.String("message"), proto.String("__redis__:invalidate"), proto.Nil))
+		mustOK(t, c2, "CLIENT", "TRACKING", "OFF")
+
+		// RESP3 clients get push messages
+		id3 := strconv.Itoa(clientID(c3))
+		mustOK(t, c2, "CLIENT", "TRACKING", "ON", "REDIRECT", id3)
+		mustNil(t, c2, "GET", "redir")
+		mustOK(t, c3, "SET", "redir", "bar")
+		mustRead(t, c3, proto.Push(proto.String("invalidate"), proto.Strings("redir")))
+
+		// redirect is gone
+		c4 := dial()
+		defer c4.Close()
+		id4 := clientID(c4)
+		c5 := dial()
+		defer c5.Close()
+		mustOK(t, c5, "CLIENT", "TRACKING", "ON", "REDIRECT", strconv.Itoa(id4))
+		mustDo(t, c5, "GET", "redir", proto.String("bar"))
+		c4.Close()
+		for s.srv.Peer(id4) != nil {
+			time.Sleep(time.Millisecond)
+		}
+		mustOK(t, c3, "SET", "redir", "baz")
+		mustRead(t, c5, proto.Push(proto.String("tracking-redir-broken"), proto.Int(id4)))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		c := dial()
+		defer c.Close()
+
+		mustDo(t, c,
+			"CLIENT",
+			proto.Error(errWrongNumber("client")),
+		)
+		mustDo(t, c,
+			"CLIENT", "FOO",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'FOO'. Try CLIENT HELP."),
+		)
+		mustDo(t, c,
+			"CLIENT", "TRACKING",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'TRACKING'. Try CLIENT HELP."),
+		)
+		mustDo(t, c,
+			"CLIENT", "TRACKING", "MAYBE",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"CLIENT", "TRACKING", "ON", "FOO",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"CLIENT", "TRACKING", "ON", "PREFIX", "foo",
+			proto.Error("ERR PREFIX option requires BCAST mode to be enabled"),
+		)
+		mustDo(t, c,
+			"CLIENT", "TRACKING", "ON", "BCAST", "OPTIN",
+			proto.Error("ERR OPTIN and OPTOUT are not compatible with BCAST"),
+		)
+		mustDo(t, c,
+			"CLIENT", "TRACKING", "ON", "OPTIN", "OPTOUT",
+			proto.Error("ERR You can't use both OPTIN and OPTOUT"),
+		)
+		mustDo(t, c,
+			"CLIENT", "TRACKING", "ON", "REDIRECT", "foo",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"CLIENT", "TRACKING", "ON", "REDIRECT", "9999",
+			proto.Error("ERR The client ID you want redirect to does not exist"),
+		)
+		mustDo(t, c,
+			"CLIENT", "TRACKING", "ON", "BCAST", "PREFIX", "foo", "PREFIX", "foobar",
+			proto.Error("ERR Prefix 'foobar' overlaps with an existing prefix 'foo'. Prefixes for a single client must not overlap."),
+		)
+		mustDo(t, c,
+			"CLIENT", "CACHING", "YES",
+			proto.Error("ERR CLIENT CACHING can be called only when the client is in tracking mode with OPTIN or OPTOUT mode enabled"),
+		)
+		mustOK(t, c, "CLIENT", "TRACKING", "ON", "OPTOUT")
+		mustDo(t, c,
+			"CLIENT", "CACHING", "YES",
+			proto.Error("ERR CLIENT CACHING YES is only valid when tracking is enabled in OPTIN mode."),
+		)
+		mustDo(t, c,
+			"CLIENT", "TRACKING", "ON", "OPTIN",
+			proto.Error("ERR You can't switch OPTIN/OPTOUT mode before disabling tracking for this client, and then re-enabling it with a different mode."),
+		)
+		mustDo(t, c,
+			"CLIENT", "TRACKING", "ON", "BCAST",
+			proto.Error("ERR You can't switch BCAST mode on/off before disabling tracking for this client, and then re-enabling it with a different mode."),
+		)
+		mustOK(t, c, "CLIENT", "CACHING", "NO")
+	})
+}
+
+func TestClientList(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.SetTime(now)
+
+	c1, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c1.Close()
+	c2, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c2.Close()
+	c3, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c3.Close()
+
+	// every line as a map, without the addresses
+	list := func(args ...string) []map[string]string {
+		t.Helper()
+		res, err := c1.Do(append([]string{"CLIENT", "LIST"}, args...)...)
+		ok(t, err)
+		str, err := proto.ReadString(res)
+		ok(t, err)
+		var rows []map[string]string
+		for _, line := range strings.Split(strings.TrimSuffix(str, "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			row := map[string]string{}
+			for _, f := range strings.Split(line, " ") {
+				kv := strings.SplitN(f, "=", 2)
+				row[kv[0]] = kv[1]
+			}
+			if row["addr"] == "" || row["laddr"] != s.Addr() {
+				t.Errorf("unexpected addr: %q", line)
+			}
+			delete(row, "addr")
+			delete(row, "laddr")
+			rows = append(rows, row)
+		}
+		return rows
+	}
+	row := func(id, age, idle, flags, db, sub, multi, cmd, resp string) map[string]string {
+		return map[string]string{
+			"id":    id,
+			"name":  "",
+			"age":   age,
+			"idle":  idle,
+			"flags": flags,
+			"db":    db,
+			"sub":   sub,
+			"psub":  "0",
+			"ssub":  "0",
+			"multi": multi,
+			"cmd":   cmd,
+			"user":  "default",
+			"redir": "-1",
+			"resp":  resp,
+		}
+	}
+
+	mustOK(t, c1, "SELECT", "2")
+	mustDo(t, c2, "HELLO", "3", proto.Map(
+		proto.String("server"), proto.String("miniredis"),
+		proto.String("version"), proto.String("6.0.5"),
+		proto.String("proto"), proto.Int(3),
+		proto.String("id"), proto.Int(42),
+		proto.String("mode"), proto.String("standalone"),
+		proto.String("role"), proto.String("master"),
+		proto.String("modules"), proto.Array(),
+	))
+	mustOK(t, c2, "MULTI")
+	mustDo(t, c2, "GET", "foo", proto.Inline("QUEUED"))
+	mustDo(t, c3, "SUBSCRIBE", "news", proto.Array(proto.String("subscribe"), proto.String("news"), proto.Int(1)))
+
+	s.SetTime(now.Add(10 * time.Second))
+	equals(t,
+		[]map[string]string{
+			row("1", "10", "0", "N", "2", "0", "-1", "client|list", "2"),
+			row("2", "10", "10", "x", "0", "0", "1", "get", "3"),
+			row("3", "10", "10", "P", "0", "1", "-1", "subscribe", "2"),
+		},
+		list(),
+	)
+
+	t.Run("filters", func(t *testing.T) {
+		equals(t,
+			[]map[string]string{
+				row("3", "10", "10", "P", "0", "1", "-1", "subscribe", "2"),
+			},
+			list("TYPE", "pubsub"),
+		)
+		equals(t, 2, len(list("type", "NORMAL")))
+		equals(t, 0, len(list("TYPE", "replica")))
+		equals(t, 0, len(list("TYPE", "master")))
+
+		equals(t,
+			[]map[string]string{
+				row("2", "10", "10", "x", "0", "0", "1", "get", "3"),
+				row("3", "10", "10", "P", "0", "1", "-1", "subscribe", "2"),
+			},
+			list("ID", "2", "3", "99"),
+		)
+		equals(t, 0, len(list("ID", "99")))
+	})
+
+	t.Run("age", func(t *testing.T) {
+		// age and idle count from the connect, not from the first command
+		c4, err := proto.Dial(s.Addr())
+		ok(t, err)
+		defer c4.Close()
+		for connected := false; !connected; {
+			s.Lock()
+			p := s.srv.Peer(4)
+			connected = p != nil && p.Ctx != nil
+			s.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+		s.SetTime(now.Add(15 * time.Second))
+		equals(t,
+			[]map[string]string{
+				row("4", "5", "5", "N", "0", "0", "-1", "NULL", "2"),
+			},
+			list("ID", "4"),
+		)
+		mustContain(t, c4, "CLIENT", "INFO", " age=5 idle=0 ")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c1,
+			"CLIENT", "LIST", "TYPE", "foo",
+			proto.Error("ERR Unknown client type 'foo'"),
+		)
+		mustDo(t, c1,
+			"CLIENT", "LIST", "ID", "foo",
+			proto.Error("ERR Invalid client ID"),
+		)
+		mustDo(t, c1,
+			"CLIENT", "LIST", "ID",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c1,
+			"CLIENT", "LIST", "TYPE",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c1,
+			"CLIENT", "LIST", "FOO",
+			proto.Error(msgSyntaxError),
+		)
+	})
+}
+
+func TestClientName(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+	c2, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c2.Close()
+
+	mustDo(t, c, "CLIENT", "ID", proto.Int(1))
+	mustDo(t, c2, "CLIENT", "ID", proto.Int(2))
+	mustNil(t, c, "CLIENT", "GETNAME")
+	mustOK(t, c, "CLIENT", "SETNAME", "worker-1")
+	mustDo(t, c, "CLIENT", "GETNAME", proto.String("worker-1"))
+	mustNil(t, c2, "CLIENT", "GETNAME")
+
+	mustContain(t, c, "CLIENT", "INFO", "id=1 addr=")
+	mustContain(t, c, "CLIENT", "INFO", " name=worker-1 age=")
+	mustContain(t, c, "CLIENT", "INFO", " cmd=client|info ")
+	mustContain(t, c2, "CLIENT", "LIST", " name=worker-1 age=")
+	mustContain(t, c2, "CLIENT", "LIST", " cmd=client|info ")
+
+	// empty clears the name
+	mustOK(t, c, "CLIENT", "SETNAME", "")
+	mustNil(t, c, "CLIENT", "GETNAME")
+	mustContain(t, c, "CLIENT", "INFO", " name= age=")
+
+	t.Run("reconnect", func(t *testing.T) {
+		c3, err := proto.Dial(s.Addr())
+		ok(t, err)
+		defer c3.Close()
+		mustDo(t, c3, "CLIENT", "ID", proto.Int(3))
+		mustNil(t, c3, "CLIENT", "GETNAME")
+	})
+
+	t.Run("tx", func(t *testing.T) {
+		mustOK(t, c, "MULTI")
+		mustDo(t, c, "CLIENT", "SETNAME", "tx", proto.Inline("QUEUED"))
+		mustDo(t, c, "CLIENT", "GETNAME", proto.Inline("QUEUED"))
+		mustDo(t, c, "EXEC", proto.Array(proto.Inline("OK"), proto.String("tx")))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"CLIENT", "SETNAME", "foo bar",
+			proto.Error(msgClientName),
+		)
+		mustDo(t, c,
+			"CLIENT", "SETNAME", "foo\nbar",
+			proto.Error(msgClientName),
+		)
+		mustDo(t, c,
+			"CLIENT", "SETNAME",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'SETNAME'. Try CLIENT HELP."),
+		)
+		mustDo(t, c,
+			"CLIENT", "GETNAME", "foo",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'GETNAME'. Try CLIENT HELP."),
+		)
+		mustDo(t, c,
+			"CLIENT", "INFO", "foo",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'INFO'. Try CLIENT HELP."),
+		)
+		mustDo(t, c, "CLIENT", "GETNAME", proto.String("tx"))
+	})
+}
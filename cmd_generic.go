@@ -3,25 +3,33 @@
 package miniredis
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/alicebob/miniredis/v2/proto"
 	"github.com/alicebob/miniredis/v2/server"
 )
 
 // commandsGeneric handles EXPIRE, TTL, PERSIST, &c.
 func commandsGeneric(m *Miniredis) {
+	m.srv.Register("COPY", m.cmdCopy)
 	m.srv.Register("DEL", m.cmdDel)
 	m.srv.Register("UNLINK", m.cmdDel)
-	// DUMP
+	m.srv.Register("DUMP", m.cmdDump)
 	m.srv.Register("EXISTS", m.cmdExists)
 	m.srv.Register("EXPIRE", makeCmdExpire(m, false, time.Second))
 	m.srv.Register("EXPIREAT", makeCmdExpire(m, true, time.Second))
 	m.srv.Register("KEYS", m.cmdKeys)
-	// MIGRATE
+	m.srv.Register("MIGRATE", m.cmdMigrate)
 	m.srv.Register("MOVE", m.cmdMove)
-	// OBJECT
+	m.srv.Register("OBJECT", m.cmdObject)
 	m.srv.Register("PERSIST", m.cmdPersist)
 	m.srv.Register("PEXPIRE", makeCmdExpire(m, false, time.Millisecond))
 	m.srv.Register("PEXPIREAT", makeCmdExpire(m, true, time.Millisecond))
@@ -29,12 +37,15 @@ func commandsGeneric(m *Miniredis) {
 	m.srv.Register("RANDOMKEY", m.cmdRandomkey)
 	m.srv.Register("RENAME", m.cmdRename)
 	m.srv.Register("RENAMENX", m.cmdRenamenx)
-	// RESTORE
-	// SORT
+	m.srv.Register("RESTORE", m.cmdRestore)
+	m.srv.Register("SORT", m.cmdSort)
+	m.srv.Register("SORT_RO", m.cmdSort)
 	m.srv.Register("TOUCH", m.cmdTouch)
 	m.srv.Register("TTL", m.cmdTTL)
 	m.srv.Register("TYPE", m.cmdType)
 	m.srv.Register("SCAN", m.cmdScan)
+	m.srv.Register("WAIT", m.cmdWait)
+	m.srv.Register("WAITAOF", m.cmdWaitaof)
 }
 
 // generic expire command for EXPIRE, PEXPIRE, EXPIREAT, PEXPIREAT
@@ -42,7 +53,7 @@ func commandsGeneric(m *Miniredis) {
 // converted to a duration.
 func makeCmdExpire(m *Miniredis, unix bool, d time.Duration) func(*server.Peer, string, []string) {
 	return func(c *server.Peer, cmd string, args []string) {
-		if len(args) != 2 {
+		if len(args) < 2 {
 			setDirty(c)
 			c.WriteError(errWrongNumber(cmd))
 			return
@@ -54,6 +65,36 @@ func makeCmdExpire(m *Miniredis, unix bool, d time.Duration) func(*server.Peer,
 			return
 		}
 
+		var opts struct {
+			nx, xx, gt, lt bool
+		}
+		for _, arg := range args[2:] {
+			switch strings.ToUpper(arg) {
+			case "NX":
+				opts.nx = true
+			case "XX":
+				opts.xx = true
+			case "GT":
+				opts.gt = true
+			case "LT":
+				opts.lt = true
+			default:
+				setDirty(c)
+				c.WriteError(fmt.Sprintf(msgFUnsupportedOption, arg))
+				return
+			}
+		}
+		if opts.nx && (opts.xx || opts.gt || opts.lt) {
+			setDirty(c)
+			c.WriteError(msgNXandXXGTLT)
+			return
+		}
+		if opts.gt && opts.lt {
+			setDirty(c)
+			c.WriteError(msgGTandLT)
+			return
+		}
+
 		key := args[0]
 		value := args[1]
 		i, err := strconv.Atoi(value)
@@ -71,6 +112,8 @@ func makeCmdExpire(m *Miniredis, unix bool, d time.Duration) func(*server.Peer,
 				c.WriteInt(0)
 				return
 			}
+
+			var ttl time.Duration
 			if unix {
 				var ts time.Time
 				switch d {
@@ -82,19 +125,41 @@ func makeCmdExpire(m *Miniredis, unix bool, d time.Duration) func(*server.Peer,
 					panic("invalid time unit (d). Fixme!")
 				}
 				now := m.effectiveNow()
-				db.ttl[key] = ts.Sub(now)
+				ttl = ts.Sub(now)
+			} else {
+				ttl = time.Duration(i) * d
+			}
+
+			// A key without a TTL counts as an infinite TTL for GT and LT.
+			current, hasTTL := db.ttl[key]
+			if (opts.nx && hasTTL) ||
+				(opts.xx && !hasTTL) ||
+				(opts.gt && (!hasTTL || ttl <= current)) ||
+				(opts.lt && hasTTL && ttl >= current) {
+				c.WriteInt(0)
+				return
+			}
+
+			db.ttl[key] = ttl
+			db.markDirty(key)
+			if ttl <= 0 {
+				db.del(key, true)
+				m.notify(db.id, notifyGeneric, "del", key)
 			} else {
-				db.ttl[key] = time.Duration(i) * d
+				m.notify(db.id, notifyGeneric, "expire", key)
 			}
-			db.keyVersion[key]++
-			db.checkTTL(key)
 			c.WriteInt(1)
 		})
 	}
 }
 
-// TOUCH
-func (m *Miniredis) cmdTouch(c *server.Peer, cmd string, args []string) {
+// SORT and SORT_RO
+func (m *Miniredis) cmdSort(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
 	if !m.handleAuth(c) {
 		return
 	}
@@ -102,11 +167,271 @@ func (m *Miniredis) cmdTouch(c *server.Peer, cmd string, args []string) {
 		return
 	}
 
+	var opts struct {
+		key       string
+		by        string
+		dontSort  bool
+		withLimit bool
+		offset    int
+		count     int
+		get       []string
+		desc      bool
+		alpha     bool
+		store     string
+		withStore bool
+	}
+	opts.key, args = args[0], args[1:]
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "ASC":
+			opts.desc = false
+			args = args[1:]
+		case "DESC":
+			opts.desc = true
+			args = args[1:]
+		case "ALPHA":
+			opts.alpha = true
+			args = args[1:]
+		case "LIMIT":
+			if len(args) < 3 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			offset, err := strconv.Atoi(args[1])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			count, err := strconv.Atoi(args[2])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			opts.withLimit, opts.offset, opts.count = true, offset, count
+			args = args[3:]
+		case "STORE":
+			if len(args) < 2 || strings.ToUpper(cmd) == "SORT_RO" {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			opts.withStore, opts.store = true, args[1]
+			args = args[2:]
+		case "BY":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			opts.by = args[1]
+			// a pattern without a "*" means: don't sort
+			opts.dontSort = !strings.Contains(opts.by, "*")
+			args = args[2:]
+		case "GET":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			opts.get = append(opts.get, args[1])
+			args = args[2:]
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		var elems []string
+		switch db.t(opts.key) {
+		case "":
+		case "list":
+			elems = append(elems, db.listKeys[opts.key].elems()...)
+		case "set":
+			elems = db.setMembers(opts.key)
+		case "zset":
+			elems = db.ssetMembers(opts.key)
+			if opts.dontSort && opts.desc {
+				reverseSlice(elems)
+			}
+		default:
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		if !opts.dontSort {
+			var err error
+			elems, err = db.sortElems(elems, opts.by, opts.alpha, opts.desc)
+			if err != nil {
+				c.WriteError(err.Error())
+				return
+			}
+		}
+
+		if opts.withLimit {
+			start := opts.offset
+			if start < 0 {
+				start = 0
+			}
+			if start > len(elems) {
+				start = len(elems)
+			}
+			end := len(elems)
+			if opts.count >= 0 && opts.count < end-start {
+				end = start + opts.count
+			}
+			elems = elems[start:end]
+		}
+
+		// nil values are stored as an empty string
+		var (
+			res   []string
+			isNil []bool
+		)
+		for _, e := range elems {
+			if len(opts.get) == 0 {
+				res = append(res, e)
+				isNil = append(isNil, false)
+				continue
+			}
+			for _, pat := range opts.get {
+				v, ok := db.sortLookup(pat, e)
+				res = append(res, v)
+				isNil = append(isNil, !ok)
+			}
+		}
+
+		if opts.withStore {
+			existed := db.exists(opts.store)
+			db.del(opts.store, true)
+			if len(res) > 0 {
+				db.listPush(opts.store, res...)
+				m.notify(db.id, notifyList, "sortstore", opts.store)
+			} else if existed {
+				m.notify(db.id, notifyGeneric, "del", opts.store)
+			}
+			c.WriteInt(len(res))
+			return
+		}
+
+		c.WriteLen(len(res))
+		for i, v := range res {
+			if isNil[i] {
+				c.WriteNull()
+				continue
+			}
+			c.WriteBulk(v)
+		}
+	})
+}
+
+// sortElems sorts as SORT does. by is an optional pattern to get the weights.
+func (db *RedisDB) sortElems(elems []string, by string, alpha, desc bool) ([]string, error) {
+	type weighted struct {
+		elem   string
+		value  string
+		ok     bool // value found
+		weight float64
+	}
+	ws := make([]weighted, 0, len(elems))
+	for _, e := range elems {
+		w := weighted{elem: e, value: e, ok: true}
+		if by != "" {
+			w.value, w.ok = db.sortLookup(by, e)
+		}
+		if !alpha && w.ok && w.value != "" {
+			f, err := strconv.ParseFloat(strings.TrimLeft(w.value, " \t\n"), 64)
+			if err != nil || math.IsNaN(f) {
+				return nil, errors.New(msgSortScores)
+			}
+			w.weight = f
+		}
+		ws = append(ws, w)
+	}
+
+	cmp := func(a, b weighted) int {
+		if !alpha {
+			switch {
+			case a.weight < b.weight:
+				return -1
+			case a.weight > b.weight:
+				return 1
+			}
+			// deterministic order for equal weights
+			return strings.Compare(a.elem, b.elem)
+		}
+		switch {
+		case a.ok && b.ok:
+			return strings.Compare(a.value, b.value)
+		case a.ok:
+			return 1
+		case b.ok:
+			return -1
+		}
+		return 0
+	}
+	sort.SliceStable(ws, func(i, j int) bool {
+		if desc {
+			return cmp(ws[i], ws[j]) > 0
+		}
+		return cmp(ws[i], ws[j]) < 0
+	})
+
+	res := make([]string, 0, len(ws))
+	for _, w := range ws {
+		res = append(res, w.elem)
+	}
+	return res, nil
+}
+
+// sortLookup finds the value of a BY or GET pattern for an element. The
+// first "*" is replaced by the element, "key->field" gets a hash field, and
+// "#" is the element itself.
+func (db *RedisDB) sortLookup(pattern, elem string) (string, bool) {
+	if pattern == "#" {
+		return elem, true
+	}
+	star := strings.IndexByte(pattern, '*')
+	if star < 0 {
+		return "", false
+	}
+	keyPattern, field := pattern, ""
+	if f := strings.Index(pattern[star+1:], "->"); f >= 0 && star+1+f+2 < len(pattern) {
+		keyPattern, field = pattern[:star+1+f], pattern[star+1+f+2:]
+	}
+	key := keyPattern[:star] + elem + keyPattern[star+1:]
+	if field != "" {
+		if db.t(key) != "hash" {
+			return "", false
+		}
+		v, ok := db.hashKeys[key][field]
+		return v, ok
+	}
+	if db.t(key) != "string" {
+		return "", false
+	}
+	return db.stringKeys[key], true
+}
+
+// TOUCH
+func (m *Miniredis) cmdTouch(c *server.Peer, cmd string, args []string) {
 	if len(args) == 0 {
 		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
 	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		db := m.db(ctx.selectedDB)
@@ -152,7 +477,8 @@ func (m *Miniredis) cmdTTL(c *server.Peer, cmd string, args []string) {
 			c.WriteInt(-1)
 			return
 		}
-		c.WriteInt(int(v.Seconds()))
+		// rounded, the way Redis does it
+		c.WriteInt(int((v + 500*time.Millisecond) / time.Second))
 	})
 }
 
@@ -187,7 +513,7 @@ func (m *Miniredis) cmdPTTL(c *server.Peer, cmd string, args []string) {
 			c.WriteInt(-1)
 			return
 		}
-		c.WriteInt(int(v.Nanoseconds() / 1000000))
+		c.WriteInt(int(v.Milliseconds()))
 	})
 }
 
@@ -222,23 +548,23 @@ func (m *Miniredis) cmdPersist(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		delete(db.ttl, key)
-		db.keyVersion[key]++
+		db.markDirty(key)
+		m.notify(db.id, notifyGeneric, "persist", key)
 		c.WriteInt(1)
 	})
 }
 
 // DEL and UNLINK
 func (m *Miniredis) cmdDel(c *server.Peer, cmd string, args []string) {
-	if !m.handleAuth(c) {
+	if len(args) == 0 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
 		return
 	}
-	if m.checkPubsub(c, cmd) {
+	if !m.handleAuth(c) {
 		return
 	}
-
-	if len(args) == 0 {
-		setDirty(c)
-		c.WriteError(errWrongNumber(cmd))
+	if m.checkPubsub(c, cmd) {
 		return
 	}
 
@@ -249,6 +575,7 @@ func (m *Miniredis) cmdDel(c *server.Peer, cmd string, args []string) {
 		for _, key := range args {
 			if db.exists(key) {
 				count++
+				m.notify(db.id, notifyGeneric, "del", key)
 			}
 			db.del(key, true) // delete expire
 		}
@@ -285,6 +612,94 @@ func (m *Miniredis) cmdType(c *server.Peer, cmd string, args []string) {
 	})
 }
 
+// OBJECT
+func (m *Miniredis) cmdObject(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	subcmd := strings.ToUpper(args[0])
+	switch subcmd {
+	case "HELP":
+		if len(args) != 1 {
+			setDirty(c)
+			c.WriteError(fmt.Sprintf(msgFObjectUsage, args[0]))
+			return
+		}
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			c.WriteLen(len(objectHelp))
+			for _, l := range objectHelp {
+				c.WriteInline(l)
+			}
+		})
+		return
+	case "ENCODING", "FREQ", "IDLETIME", "REFCOUNT":
+	default:
+		setDirty(c)
+		c.WriteError(fmt.Sprintf(msgFObjectUsage, args[0]))
+		return
+	}
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(fmt.Sprintf(msgFObjectUsage, args[0]))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteNull()
+			return
+		}
+		switch subcmd {
+		case "ENCODING":
+			c.WriteBulk(db.encoding(key))
+		case "FREQ":
+			if !m.lfuPolicy() {
+				c.WriteError(msgObjectFreqPolicy)
+				return
+			}
+			c.WriteInt(int(db.freq(key, m.effectiveNow())))
+		case "IDLETIME":
+			if m.lfuPolicy() {
+				c.WriteError(msgObjectIdlePolicy)
+				return
+			}
+			c.WriteInt(int(db.idle(key, m.effectiveNow()).Seconds()))
+		case "REFCOUNT":
+			c.WriteInt(db.refcount(key))
+		}
+	})
+}
+
+var objectHelp = []string{
+	"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"ENCODING <key>",
+	"    Return the kind of internal representation used in order to store the value",
+	"    associated with a <key>.",
+	"FREQ <key>",
+	"    Return the access frequency index of the <key>. The returned integer is",
+	"    proportional to the logarithm of the recent access frequency of the key.",
+	"IDLETIME <key>",
+	"    Return the idle time of the <key>, that is the approximated number of",
+	"    seconds elapsed since the last access to the key.",
+	"REFCOUNT <key>",
+	"    Return the number of references of the value associated with the specified",
+	"    <key>.",
+	"HELP",
+	"    Print this help.",
+}
+
 // EXISTS
 func (m *Miniredis) cmdExists(c *server.Peer, cmd string, args []string) {
 	if len(args) < 1 {
@@ -329,12 +744,19 @@ func (m *Miniredis) cmdMove(c *server.Peer, cmd string, args []string) {
 	key := args[0]
 	targetDB, err := strconv.Atoi(args[1])
 	if err != nil {
-		targetDB = 0
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	if targetDB < 0 {
+		setDirty(c)
+		c.WriteError(msgDBIndexOutOfRange)
+		return
 	}
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		if ctx.selectedDB == targetDB {
-			c.WriteError("ERR source and destination objects are the same")
+			c.WriteError(msgSameObject)
 			return
 		}
 		db := m.db(ctx.selectedDB)
@@ -344,10 +766,419 @@ func (m *Miniredis) cmdMove(c *server.Peer, cmd string, args []string) {
 			c.WriteInt(0)
 			return
 		}
+		m.notify(db.id, notifyGeneric, "move_from", key)
+		m.notify(targetDB.id, notifyGeneric, "move_to", key)
 		c.WriteInt(1)
 	})
 }
 
+// COPY
+func (m *Miniredis) cmdCopy(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var (
+		from     = args[0]
+		to       = args[1]
+		targetDB = -1
+		replace  = false
+	)
+	args = args[2:]
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "DB":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			id, err := strconv.Atoi(args[1])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			if id < 0 {
+				setDirty(c)
+				c.WriteError(msgDBIndexOutOfRange)
+				return
+			}
+			targetDB = id
+			args = args[2:]
+		case "REPLACE":
+			replace = true
+			args = args[1:]
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		if targetDB == -1 {
+			targetDB = ctx.selectedDB
+		}
+		if targetDB == ctx.selectedDB && from == to {
+			c.WriteError(msgSameObject)
+			return
+		}
+		db := m.db(ctx.selectedDB)
+		tdb := m.db(targetDB)
+
+		if !db.exists(from) {
+			c.WriteInt(0)
+			return
+		}
+		if tdb.exists(to) && !replace {
+			c.WriteInt(0)
+			return
+		}
+		db.copy(from, tdb, to)
+		m.notify(tdb.id, notifyGeneric, "copy_to", to)
+		c.WriteInt(1)
+	})
+}
+
+// DUMP
+func (m *Miniredis) cmdDump(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteNull()
+			return
+		}
+		c.WriteBulk(string(db.dump(key)))
+	})
+}
+
+// RESTORE
+func (m *Miniredis) cmdRestore(c *server.Peer, cmd string, args []string) {
+	if len(args) < 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var opts struct {
+		key      string
+		ttl      int
+		payload  string
+		replace  bool
+		absTTL   bool
+		idletime int
+		freq     int
+	}
+	opts.key, opts.payload = args[0], args[2]
+	opts.idletime, opts.freq = -1, -1
+	ttl, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	opts.ttl = ttl
+	args = args[3:]
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "REPLACE":
+			opts.replace = true
+			args = args[1:]
+		case "ABSTTL":
+			opts.absTTL = true
+			args = args[1:]
+		case "IDLETIME":
+			if len(args) < 2 || opts.freq != -1 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			if n < 0 {
+				setDirty(c)
+				c.WriteError(msgInvalidIdletime)
+				return
+			}
+			opts.idletime = n
+			args = args[2:]
+		case "FREQ":
+			if len(args) < 2 || opts.idletime != -1 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			if n < 0 || n > 255 {
+				setDirty(c)
+				c.WriteError(msgInvalidFreq)
+				return
+			}
+			opts.freq = n
+			args = args[2:]
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+	if opts.ttl < 0 {
+		setDirty(c)
+		c.WriteError(msgInvalidTTL)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(opts.key) && !opts.replace {
+			c.WriteError(msgBusyKey)
+			return
+		}
+		tmp, err := restore(m, opts.payload)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+
+		now := m.effectiveNow()
+		var ttl time.Duration
+		if opts.ttl > 0 {
+			ttl = time.Duration(opts.ttl) * time.Millisecond
+			if opts.absTTL {
+				ttl = time.Unix(0, int64(opts.ttl)*int64(time.Millisecond)).Sub(now)
+				if ttl <= 0 {
+					// already expired
+					if db.exists(opts.key) {
+						m.notify(db.id, notifyGeneric, "del", opts.key)
+					}
+					db.del(opts.key, true)
+					c.WriteOK()
+					return
+				}
+			}
+		}
+
+		tmp.copy("", db, opts.key)
+		if ttl > 0 {
+			db.ttl[opts.key] = ttl
+		}
+		db.lastAccess[opts.key] = now
+		if opts.idletime > 0 {
+			db.lastAccess[opts.key] = now.Add(-time.Duration(opts.idletime) * time.Second)
+		}
+		db.accessFreq[opts.key] = lfuInitVal
+		if opts.freq >= 0 {
+			db.accessFreq[opts.key] = float64(opts.freq)
+		}
+		m.notify(db.id, notifyGeneric, "restore", opts.key)
+		c.WriteOK()
+	})
+}
+
+// MIGRATE
+func (m *Miniredis) cmdMigrate(c *server.Peer, cmd string, args []string) {
+	if len(args) < 5 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var opts struct {
+		addr    string
+		keys    []string
+		db      int
+		timeout time.Duration
+		copy    bool
+		replace bool
+		auth    []string
+	}
+	port, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	opts.addr = net.JoinHostPort(args[0], strconv.Itoa(port))
+	if args[2] != "" {
+		opts.keys = []string{args[2]}
+	}
+	opts.db, err = strconv.Atoi(args[3])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	timeout, err := strconv.Atoi(args[4])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	opts.timeout = time.Duration(timeout) * time.Millisecond
+	if timeout <= 0 {
+		opts.timeout = time.Second
+	}
+	args = args[5:]
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "COPY":
+			opts.copy = true
+			args = args[1:]
+		case "REPLACE":
+			opts.replace = true
+			args = args[1:]
+		case "AUTH":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			opts.auth = []string{"AUTH", args[1]}
+			args = args[2:]
+		case "AUTH2":
+			if len(args) < 3 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			opts.auth = []string{"AUTH", args[1], args[2]}
+			args = args[3:]
+		case "KEYS":
+			if len(opts.keys) > 0 {
+				setDirty(c)
+				c.WriteError(msgMigrateKeys)
+				return
+			}
+			opts.keys = args[1:]
+			args = nil
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		var (
+			keys []string
+			cmds [][]string
+		)
+		if opts.auth != nil {
+			cmds = append(cmds, opts.auth)
+		}
+		cmds = append(cmds, []string{"SELECT", strconv.Itoa(opts.db)})
+		for _, k := range opts.keys {
+			if !db.exists(k) {
+				continue
+			}
+			rcmd := []string{
+				"RESTORE", k,
+				strconv.FormatInt(int64(db.ttl[k]/time.Millisecond), 10),
+				string(db.dump(k)),
+			}
+			if opts.replace {
+				rcmd = append(rcmd, "REPLACE")
+			}
+			keys = append(keys, k)
+			cmds = append(cmds, rcmd)
+		}
+		if len(keys) == 0 {
+			c.WriteInline("NOKEY")
+			return
+		}
+
+		// The keys which made it are deleted, even if some others didn't.
+		n, errMsg := migrate(opts.addr, opts.timeout, cmds)
+		if restored := n - (len(cmds) - len(keys)); restored > 0 && !opts.copy {
+			for _, k := range keys[:restored] {
+				db.del(k, true)
+				m.notify(db.id, notifyGeneric, "del", k)
+			}
+		}
+		if errMsg != "" {
+			c.WriteError(errMsg)
+			return
+		}
+		c.WriteOK()
+	})
+}
+
+// migrate sends the commands to addr, one by one, as MIGRATE does. It returns
+// the number of commands which succeeded, and an error message if one
+// didn't.
+// The lock is held while we wait for the target, so migrating to the same
+// miniredis will time out, just like in real Redis.
+func migrate(addr string, timeout time.Duration, cmds [][]string) (int, string) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return 0, msgIOErrConnect
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for i, cmd := range cmds {
+		conn.SetDeadline(time.Now().Add(timeout))
+		if err := proto.Write(conn, cmd); err != nil {
+			return i, msgIOErrWrite
+		}
+		res, err := proto.Read(r)
+		if err != nil {
+			return i, msgIOErrRead
+		}
+		if e, err := proto.ReadError(res); err == nil {
+			return i, fmt.Sprintf(msgFTargetError, e)
+		}
+	}
+	return len(cmds), ""
+}
+
 // KEYS
 func (m *Miniredis) cmdKeys(c *server.Peer, cmd string, args []string) {
 	if len(args) != 1 {
@@ -367,7 +1198,7 @@ func (m *Miniredis) cmdKeys(c *server.Peer, cmd string, args []string) {
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		db := m.db(ctx.selectedDB)
 
-		keys, _ := matchKeys(db.allKeys(), key)
+		keys := matchKeys(db.allKeys(), key)
 		c.WriteLen(len(keys))
 		for _, s := range keys {
 			c.WriteBulk(s)
@@ -432,6 +1263,8 @@ func (m *Miniredis) cmdRename(c *server.Peer, cmd string, args []string) {
 		}
 
 		db.rename(from, to)
+		m.notify(db.id, notifyGeneric, "rename_from", from)
+		m.notify(db.id, notifyGeneric, "rename_to", to)
 		c.WriteOK()
 	})
 }
@@ -466,10 +1299,88 @@ func (m *Miniredis) cmdRenamenx(c *server.Peer, cmd string, args []string) {
 		}
 
 		db.rename(from, to)
+		m.notify(db.id, notifyGeneric, "rename_from", from)
+		m.notify(db.id, notifyGeneric, "rename_to", to)
 		c.WriteInt(1)
 	})
 }
 
+// scanCursor is the state of a SCAN or SSCAN iteration. Keys (or members)
+// are returned in sorted order, so everything after the last returned one is
+// still to do. That way keys which exist during the whole iteration are
+// returned exactly once, whatever else gets added or deleted.
+type scanCursor struct {
+	cmd  string
+	db   int
+	key  string // SSCAN key
+	last string
+	iter int // the first cursor of the iteration
+	used int // for the LRU, see scanPage()
+}
+
+// We keep the cursors of this many iterations which aren't done yet. Clients
+// can stop halfway, so when there are more the least recently used one goes.
+const maxScanCursors = 1000
+
+// scanPage gives the next page of at most count elements from the sorted
+// elems, and the cursor for the page after that, which is 0 when we're done.
+// ok is false for an unknown cursor. Cursors can be used again (to retry a
+// page) until the iteration is done.
+func (m *Miniredis) scanPage(cur scanCursor, cursor int, elems []string, count int) ([]string, int, bool) {
+	m.scanClock++
+	if cursor != 0 {
+		prev, ok := m.scanCursors[cursor]
+		if !ok || prev.cmd != cur.cmd || prev.db != cur.db || prev.key != cur.key {
+			return nil, 0, false
+		}
+		prev.used = m.scanClock
+		m.scanCursors[cursor] = prev
+		cur.iter = prev.iter
+		elems = elems[sort.SearchStrings(elems, prev.last):]
+		if len(elems) > 0 && elems[0] == prev.last {
+			elems = elems[1:]
+		}
+	}
+	if len(elems) <= count {
+		// done, forget the whole iteration
+		if cur.iter != 0 {
+			for id, c := range m.scanCursors {
+				if c.iter == cur.iter {
+					delete(m.scanCursors, id)
+				}
+			}
+		}
+		return elems, 0, true
+	}
+	elems = elems[:count]
+	m.lastCursor++
+	if cur.iter == 0 {
+		cur.iter = m.lastCursor
+	}
+	cur.last = elems[len(elems)-1]
+	cur.used = m.scanClock
+	m.scanCursors[m.lastCursor] = cur
+	if len(m.scanCursors) > maxScanCursors {
+		lru := m.lastCursor
+		for id, c := range m.scanCursors {
+			if c.used < m.scanCursors[lru].used {
+				lru = id
+			}
+		}
+		delete(m.scanCursors, lru)
+	}
+	return elems, m.lastCursor, true
+}
+
+// dropScanCursors forgets all SCAN and SSCAN iterations of a database.
+func (m *Miniredis) dropScanCursors(db int) {
+	for id, cur := range m.scanCursors {
+		if cur.db == db {
+			delete(m.scanCursors, id)
+		}
+	}
+}
+
 // SCAN
 func (m *Miniredis) cmdScan(c *server.Peer, cmd string, args []string) {
 	if len(args) < 1 {
@@ -492,22 +1403,31 @@ func (m *Miniredis) cmdScan(c *server.Peer, cmd string, args []string) {
 	}
 	args = args[1:]
 
-	// MATCH and COUNT options
+	// MATCH, COUNT, and TYPE options
 	var withMatch bool
 	var match string
+	var withType bool
+	var typ string
+	count := 10
 	for len(args) > 0 {
 		if strings.ToLower(args[0]) == "count" {
-			// we do nothing with count
 			if len(args) < 2 {
 				setDirty(c)
 				c.WriteError(msgSyntaxError)
 				return
 			}
-			if _, err := strconv.Atoi(args[1]); err != nil {
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
 				setDirty(c)
 				c.WriteError(msgInvalidInt)
 				return
 			}
+			if n < 1 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			count = n
 			args = args[2:]
 			continue
 		}
@@ -521,6 +1441,16 @@ func (m *Miniredis) cmdScan(c *server.Peer, cmd string, args []string) {
 			match, args = args[1], args[2:]
 			continue
 		}
+		if strings.ToLower(args[0]) == "type" {
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			withType = true
+			typ, args = args[1], args[2:]
+			continue
+		}
 		setDirty(c)
 		c.WriteError(msgSyntaxError)
 		return
@@ -528,9 +1458,12 @@ func (m *Miniredis) cmdScan(c *server.Peer, cmd string, args []string) {
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		db := m.db(ctx.selectedDB)
-		// We return _all_ (matched) keys every time.
+		keys := db.allKeys()
 
-		if cursor != 0 {
+		// COUNT is the number of keys we look at, as in Redis. MATCH and
+		// TYPE are applied afterwards, so a page can be empty.
+		keys, next, ok := m.scanPage(scanCursor{cmd: "scan", db: ctx.selectedDB}, cursor, keys, count)
+		if !ok {
 			// Invalid cursor.
 			c.WriteLen(2)
 			c.WriteBulk("0") // no next cursor
@@ -538,16 +1471,128 @@ func (m *Miniredis) cmdScan(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
-		keys := db.allKeys()
 		if withMatch {
-			keys, _ = matchKeys(keys, match)
+			keys = matchKeys(keys, match)
+		}
+		if withType {
+			var typed []string
+			for _, k := range keys {
+				if strings.EqualFold(db.t(k), typ) {
+					typed = append(typed, k)
+				}
+			}
+			keys = typed
 		}
 
 		c.WriteLen(2)
-		c.WriteBulk("0") // no next cursor
+		c.WriteBulk(strconv.Itoa(next))
 		c.WriteLen(len(keys))
 		for _, k := range keys {
 			c.WriteBulk(k)
 		}
 	})
 }
+
+// WAIT
+func (m *Miniredis) cmdWait(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	if _, err := strconv.Atoi(args[0]); err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	timeout, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidIntTimeout)
+		return
+	}
+	if timeout < 0 {
+		setDirty(c)
+		c.WriteError(msgNegTimeout)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		// All replicas are always up to date, so there is no need to wait.
+		c.WriteInt(m.replicas)
+	})
+}
+
+// WAITAOF
+func (m *Miniredis) cmdWaitaof(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	numlocal, err := strconv.Atoi(args[0])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	numreplicas, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	if numlocal < 0 || numreplicas < 0 {
+		setDirty(c)
+		c.WriteError(msgPositive)
+		return
+	}
+	timeout, err := strconv.Atoi(args[2])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidIntTimeout)
+		return
+	}
+	if timeout < 0 {
+		setDirty(c)
+		c.WriteError(msgNegTimeout)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		if numlocal > 0 && !m.config.appendonly {
+			c.WriteError(msgWaitaofAppendonly)
+			return
+		}
+		// Everything is always synced, so there is no need to wait.
+		local := 0
+		if m.config.appendonly {
+			local = 1
+		}
+		c.WriteLen(2)
+		c.WriteInt(local)
+		c.WriteInt(m.replicas)
+	})
+}
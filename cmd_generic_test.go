@@ -1,7 +1,9 @@
 package miniredis
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -93,6 +95,68 @@ func TestTTL(t *testing.T) {
 		must1(t, c, "EXPIRE", "wim", "-1200")
 		equals(t, false, s.Exists("wim"))
 	}
+
+	t.Run("milliseconds", func(t *testing.T) {
+		mustOK(t, c, "SET", "ms", "value", "PX", "1500")
+		mustDo(t, c, "PTTL", "ms", proto.Int(1500))
+		mustDo(t, c, "TTL", "ms", proto.Int(2))
+
+		s.FastForward(600 * time.Millisecond)
+		mustDo(t, c, "PTTL", "ms", proto.Int(900))
+		mustDo(t, c, "TTL", "ms", proto.Int(1))
+
+		mustOK(t, c, "PSETEX", "ms", "1234", "value")
+		s.FastForward(1233 * time.Millisecond)
+		mustDo(t, c, "PTTL", "ms", proto.Int(1))
+		mustDo(t, c, "TTL", "ms", proto.Int(0))
+		s.FastForward(time.Millisecond)
+		equals(t, false, s.Exists("ms"))
+	})
+
+	t.Run("options", func(t *testing.T) {
+		mustOK(t, c, "SET", "opt", "value")
+
+		must0(t, c, "EXPIRE", "opt", "100", "XX")
+		must0(t, c, "EXPIRE", "opt", "100", "GT") // no TTL is infinite
+		must1(t, c, "EXPIRE", "opt", "100", "NX")
+		must0(t, c, "EXPIRE", "opt", "200", "NX")
+		equals(t, 100*time.Second, s.TTL("opt"))
+
+		must1(t, c, "EXPIRE", "opt", "200", "xx")
+		must0(t, c, "EXPIRE", "opt", "150", "GT")
+		must0(t, c, "EXPIRE", "opt", "200", "GT")
+		must1(t, c, "EXPIRE", "opt", "300", "GT")
+		must0(t, c, "EXPIRE", "opt", "300", "LT")
+		must1(t, c, "EXPIRE", "opt", "50", "LT")
+		must1(t, c, "EXPIRE", "opt", "60", "XX", "GT")
+		must0(t, c, "EXPIRE", "opt", "70", "XX", "LT")
+		equals(t, 60*time.Second, s.TTL("opt"))
+
+		must1(t, c, "PEXPIRE", "opt", "30000", "LT")
+		equals(t, 30*time.Second, s.TTL("opt"))
+
+		mustOK(t, c, "SET", "opt2", "value")
+		must1(t, c, "EXPIRE", "opt2", "100", "LT") // no TTL is infinite
+
+		must0(t, c, "EXPIRE", "nosuch", "100", "NX")
+
+		mustDo(t, c,
+			"EXPIRE", "opt", "100", "NX", "XX",
+			proto.Error(msgNXandXXGTLT),
+		)
+		mustDo(t, c,
+			"EXPIRE", "opt", "100", "GT", "LT",
+			proto.Error(msgGTandLT),
+		)
+		mustDo(t, c,
+			"EXPIRE", "opt", "100", "FOO",
+			proto.Error("ERR Unsupported option FOO"),
+		)
+		mustDo(t, c,
+			"EXPIRE", "opt", "noint", "NX",
+			proto.Error(msgInvalidInt),
+		)
+	})
 }
 
 func TestExpireat(t *testing.T) {
@@ -129,6 +193,16 @@ func TestExpireat(t *testing.T) {
 		equals(t, 100*time.Second, s.TTL("foo"))
 		mustDo(t, c, "TTL", "foo", proto.Int(100))
 	}
+
+	// Options
+	{
+		now := 1234567890
+		s.SetTime(time.Unix(int64(now), 0))
+		must0(t, c, "EXPIREAT", "foo", strconv.Itoa(now+50), "GT")
+		must1(t, c, "EXPIREAT", "foo", strconv.Itoa(now+50), "LT")
+		equals(t, 50*time.Second, s.TTL("foo"))
+		must0(t, c, "PEXPIREAT", "foo", strconv.Itoa((now+10)*1000), "NX")
+	}
 }
 
 func TestTouch(t *testing.T) {
@@ -171,6 +245,35 @@ func TestTouch(t *testing.T) {
 		must1(t, c, "TOUCH", "baz")
 		equals(t, time.Second, s.TTL("foo"))
 	})
+
+	t.Run("idletime", func(t *testing.T) {
+		mustOK(t, c, "SET", "idle", "value")
+		s.FastForward(time.Minute)
+		mustDo(t, c,
+			"OBJECT", "IDLETIME", "idle",
+			proto.Int(60),
+		)
+
+		must1(t, c, "TOUCH", "idle", "nosuch")
+		must0(t, c, "OBJECT", "IDLETIME", "idle")
+	})
+
+	t.Run("freq", func(t *testing.T) {
+		ok(t, s.SetMaxMemoryPolicy("allkeys-lfu"))
+		defer s.SetMaxMemoryPolicy("noeviction")
+
+		mustOK(t, c, "SET", "freq", "value")
+		mustDo(t, c,
+			"OBJECT", "FREQ", "freq",
+			proto.Int(5),
+		)
+		for i := 0; i < 100; i++ {
+			must1(t, c, "TOUCH", "freq")
+		}
+		res, err := c.Do("OBJECT", "FREQ", "freq")
+		ok(t, err)
+		assert(t, res != proto.Int(5), "TOUCH increments the counter")
+	})
 }
 
 func TestPexpireat(t *testing.T) {
@@ -303,6 +406,30 @@ func TestUnlink(t *testing.T) {
 			proto.Int(2),
 		)
 		equals(t, time.Duration(0), s.TTL("one"))
+
+		// a key is only counted once
+		mustDo(t, c,
+			"UNLINK", "three", "three",
+			proto.Int(1),
+		)
+	})
+
+	t.Run("tx", func(t *testing.T) {
+		s.Set("tx", "value")
+		mustOK(t, c, "MULTI")
+		mustDo(t, c, "UNLINK", "tx", proto.Inline("QUEUED"))
+		mustDo(t, c, "EXISTS", "tx", proto.Inline("QUEUED"))
+		mustDo(t, c,
+			"EXEC",
+			proto.Array(proto.Int(1), proto.Int(0)),
+		)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"UNLINK",
+			proto.Error(errWrongNumber("unlink")),
+		)
 	})
 
 	t.Run("direct", func(t *testing.T) {
@@ -362,6 +489,233 @@ func TestType(t *testing.T) {
 	})
 }
 
+func TestObject(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.Set("str", "bar!")
+	s.HSet("hash", "noot", "mies")
+	s.Lpush("list", "aap")
+	s.SetAdd("set", "aap")
+	s.ZAdd("zset", 1, "aap")
+	_, err = s.XAdd("stream", "*", []string{"name", "aap"})
+	ok(t, err)
+
+	for key, enc := range map[string]string{
+		"str":    "embstr",
+		"hash":   "listpack",
+		"list":   "listpack",
+		"set":    "listpack",
+		"zset":   "listpack",
+		"stream": "stream",
+	} {
+		mustDo(t, c,
+			"OBJECT", "ENCODING", key,
+			proto.String(enc),
+		)
+	}
+	mustNil(t, c, "OBJECT", "ENCODING", "nosuch")
+
+	t.Run("strings", func(t *testing.T) {
+		for v, enc := range map[string]string{
+			"12":                    "int",
+			"-12":                   "int",
+			"9223372036854775807":   "int",
+			"9223372036854775808":   "embstr",
+			"012":                   "embstr",
+			"+12":                   "embstr",
+			"1.5":                   "embstr",
+			strings.Repeat("x", 44): "embstr",
+			strings.Repeat("x", 45): "raw",
+		} {
+			s.Set("enc", v)
+			mustDo(t, c,
+				"OBJECT", "ENCODING", "enc",
+				proto.String(enc),
+			)
+		}
+	})
+
+	t.Run("big", func(t *testing.T) {
+		long := strings.Repeat("x", 65)
+		s.HSet("bighash", "f", long)
+		s.ZAdd("bigzset", 1, long)
+		s.SetAdd("bigset", long)
+		for i := 0; i < 129; i++ {
+			s.HSet("manyhash", strconv.Itoa(i), "v")
+			s.ZAdd("manyzset", float64(i), strconv.Itoa(i))
+			s.SetAdd("manyset", "m"+strconv.Itoa(i))
+		}
+		for i := 0; i < 513; i++ {
+			s.SetAdd("intset", strconv.Itoa(i))
+		}
+		s.Lpush("biglist", strings.Repeat("x", 8*1024+1))
+
+		for key, enc := range map[string]string{
+			"bighash":  "hashtable",
+			"manyhash": "hashtable",
+			"bigzset":  "skiplist",
+			"manyzset": "skiplist",
+			"bigset":   "hashtable",
+			"manyset":  "hashtable",
+			"intset":   "hashtable",
+			"biglist":  "quicklist",
+		} {
+			mustDo(t, c,
+				"OBJECT", "ENCODING", key,
+				proto.String(enc),
+			)
+		}
+
+		s.SRem("intset", "512")
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "intset",
+			proto.String("intset"),
+		)
+	})
+
+	t.Run("idletime", func(t *testing.T) {
+		now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+		s.SetTime(now)
+		mustOK(t, c, "SET", "idle", "value")
+		must0(t, c, "OBJECT", "IDLETIME", "idle")
+
+		s.SetTime(now.Add(10 * time.Second))
+		mustDo(t, c,
+			"OBJECT", "IDLETIME", "idle",
+			proto.Int(10),
+		)
+		// TYPE and OBJECT don't count as an access
+		mustDo(t, c,
+			"TYPE", "idle",
+			proto.Inline("string"),
+		)
+		mustDo(t, c,
+			"OBJECT", "IDLETIME", "idle",
+			proto.Int(10),
+		)
+
+		s.FastForward(time.Minute)
+		mustDo(t, c,
+			"OBJECT", "IDLETIME", "idle",
+			proto.Int(70),
+		)
+
+		mustDo(t, c,
+			"GET", "idle",
+			proto.String("value"),
+		)
+		must0(t, c, "OBJECT", "IDLETIME", "idle")
+
+		mustNil(t, c, "OBJECT", "IDLETIME", "nosuch")
+		mustDo(t, c,
+			"OBJECT", "FREQ", "idle",
+			proto.Error(msgObjectFreqPolicy),
+		)
+	})
+
+	t.Run("freq", func(t *testing.T) {
+		ok(t, s.SetMaxMemoryPolicy("allkeys-lfu"))
+		defer s.SetMaxMemoryPolicy("noeviction")
+		s.SetTime(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+
+		mustOK(t, c, "SET", "freq", "value")
+		mustDo(t, c,
+			"OBJECT", "FREQ", "freq",
+			proto.Int(5),
+		)
+		mustDo(t, c,
+			"GET", "freq",
+			proto.String("value"),
+		)
+		mustDo(t, c,
+			"OBJECT", "FREQ", "freq",
+			proto.Int(6),
+		)
+		for i := 0; i < 12; i++ {
+			mustDo(t, c,
+				"GET", "freq",
+				proto.String("value"),
+			)
+		}
+		mustDo(t, c,
+			"OBJECT", "FREQ", "freq",
+			proto.Int(7),
+		)
+
+		// counters go down a bit every minute
+		s.FastForward(3 * time.Minute)
+		mustDo(t, c,
+			"OBJECT", "FREQ", "freq",
+			proto.Int(4),
+		)
+
+		mustNil(t, c, "OBJECT", "FREQ", "nosuch")
+		mustDo(t, c,
+			"OBJECT", "IDLETIME", "freq",
+			proto.Error(msgObjectIdlePolicy),
+		)
+		equals(t, "invalid maxmemory-policy: \"lfu\"", s.SetMaxMemoryPolicy("lfu").Error())
+	})
+
+	t.Run("refcount", func(t *testing.T) {
+		for v, n := range map[string]int{
+			"0":     2147483647,
+			"9999":  2147483647,
+			"10000": 1,
+			"-1":    1,
+			"012":   1,
+			"aap":   1,
+		} {
+			s.Set("ref", v)
+			mustDo(t, c,
+				"OBJECT", "REFCOUNT", "ref",
+				proto.Int(n),
+			)
+		}
+		must1(t, c, "OBJECT", "REFCOUNT", "hash")
+		mustNil(t, c, "OBJECT", "REFCOUNT", "nosuch")
+
+		// no shared integers with LRU or LFU
+		s.Set("ref", "12")
+		ok(t, s.SetMaxMemoryPolicy("allkeys-lru"))
+		defer s.SetMaxMemoryPolicy("noeviction")
+		must1(t, c, "OBJECT", "REFCOUNT", "ref")
+	})
+
+	t.Run("help", func(t *testing.T) {
+		res, err := c.Do("OBJECT", "HELP")
+		ok(t, err)
+		lines, err := proto.Parse(res)
+		ok(t, err)
+		equals(t, 15, len(lines.([]interface{})))
+		mustContain(t, c, "OBJECT", "help", "REFCOUNT <key>")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"OBJECT",
+			proto.Error(errWrongNumber("object")),
+		)
+		mustDo(t, c,
+			"OBJECT", "FOO", "str",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'FOO'. Try OBJECT HELP."),
+		)
+		mustDo(t, c,
+			"OBJECT", "ENCODING",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'ENCODING'. Try OBJECT HELP."),
+		)
+		mustDo(t, c,
+			"OBJECT", "HELP", "str",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'HELP'. Try OBJECT HELP."),
+		)
+	})
+}
+
 func TestExists(t *testing.T) {
 	s, err := Run()
 	ok(t, err)
@@ -409,7 +763,7 @@ func TestExists(t *testing.T) {
 	})
 }
 
-func TestMove(t *testing.T) {
+func TestCopy(t *testing.T) {
 	s, err := Run()
 	ok(t, err)
 	defer s.Close()
@@ -417,54 +771,153 @@ func TestMove(t *testing.T) {
 	ok(t, err)
 	defer c.Close()
 
-	// No problem.
-	{
-		s.Set("foo", "bar!")
-		must1(t, c, "MOVE", "foo", "1")
-	}
+	t.Run("basic", func(t *testing.T) {
+		s.Set("key1", "value")
+		must1(t, c, "COPY", "key1", "key2")
+		s.CheckGet(t, "key2", "value")
+		s.CheckGet(t, "key1", "value")
 
-	// Src key doesn't exists.
-	{
-		must0(t, c, "MOVE", "nosuch", "1")
-	}
+		must0(t, c, "COPY", "nosuch", "key3")
+		equals(t, false, s.Exists("key3"))
+	})
 
-	// Target key already exists.
-	{
-		s.DB(0).Set("two", "orig")
-		s.DB(1).Set("two", "taken")
-		must0(t, c, "MOVE", "two", "1")
-		s.CheckGet(t, "two", "orig")
-	}
+	t.Run("replace", func(t *testing.T) {
+		s.Set("rep1", "new")
+		s.Set("rep2", "old")
+		must0(t, c, "COPY", "rep1", "rep2")
+		s.CheckGet(t, "rep2", "old")
 
-	// TTL is also moved
-	{
-		s.DB(0).Set("one", "two")
-		s.DB(0).SetTTL("one", time.Second*4242)
-		must1(t, c, "MOVE", "one", "1")
-		equals(t, s.DB(1).TTL("one"), time.Second*4242)
-	}
+		must1(t, c, "COPY", "rep1", "rep2", "REPLACE")
+		s.CheckGet(t, "rep2", "new")
+
+		s.HSet("rep3", "aap", "noot")
+		must1(t, c, "COPY", "rep3", "rep2", "replace")
+		equals(t, "hash", s.Type("rep2"))
+	})
+
+	t.Run("db", func(t *testing.T) {
+		s.Set("db1", "value")
+		s.SetTTL("db1", 10*time.Second)
+		must1(t, c, "COPY", "db1", "db1", "DB", "2")
+		v, err := s.DB(2).Get("db1")
+		ok(t, err)
+		equals(t, "value", v)
+		equals(t, 10*time.Second, s.DB(2).TTL("db1"))
+		must0(t, c, "COPY", "db1", "db1", "DB", "2")
+	})
+
+	t.Run("types", func(t *testing.T) {
+		s.Push("list", "aap", "noot")
+		s.SetAdd("set", "aap", "noot")
+		s.HSet("hash", "aap", "noot")
+		s.ZAdd("zset", 3.14, "aap")
+		must1(t, c, "COPY", "list", "list2")
+		must1(t, c, "COPY", "set", "set2")
+		must1(t, c, "COPY", "hash", "hash2")
+		must1(t, c, "COPY", "zset", "zset2")
+
+		// copies don't share anything
+		s.Push("list", "mies")
+		s.SetAdd("set", "mies")
+		s.HSet("hash", "aap", "mies")
+		s.ZAdd("zset", 1, "aap")
+
+		l, err := s.List("list2")
+		ok(t, err)
+		equals(t, []string{"aap", "noot"}, l)
+		m, err := s.Members("set2")
+		ok(t, err)
+		equals(t, []string{"aap", "noot"}, m)
+		equals(t, "noot", s.HGet("hash2", "aap"))
+		score, err := s.ZScore("zset2", "aap")
+		ok(t, err)
+		equals(t, 3.14, score)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		_, err := s.XAdd("planets", "0-1", []string{"name", "Mercury"})
+		ok(t, err)
+		mustOK(t, c, "XGROUP", "CREATE", "planets", "processing", "$")
+		_, err = s.XAdd("planets", "0-2", []string{"name", "Venus"})
+		ok(t, err)
+		mustDo(t, c,
+			"XREADGROUP", "GROUP", "processing", "alice", "STREAMS", "planets", ">",
+			proto.Array(
+				proto.Array(
+					proto.String("planets"),
+					proto.Array(
+						proto.Array(proto.String("0-2"), proto.Strings("name", "Venus")),
+					),
+				),
+			),
+		)
+
+		must1(t, c, "COPY", "planets", "planets2")
+		mustDo(t, c,
+			"XPENDING", "planets2", "processing",
+			proto.Array(
+				proto.Int(1),
+				proto.String("0-2"),
+				proto.String("0-2"),
+				proto.Array(
+					proto.Array(proto.String("alice"), proto.String("1")),
+				),
+			),
+		)
+
+		// acking in the copy doesn't change the original
+		must1(t, c, "XACK", "planets2", "processing", "0-2")
+		mustDo(t, c,
+			"XPENDING", "planets", "processing",
+			proto.Array(
+				proto.Int(1),
+				proto.String("0-2"),
+				proto.String("0-2"),
+				proto.Array(
+					proto.Array(proto.String("alice"), proto.String("1")),
+				),
+			),
+		)
+		_, err = s.XAdd("planets2", "0-3", []string{"name", "Earth"})
+		ok(t, err)
+		st, err := s.Stream("planets")
+		ok(t, err)
+		equals(t, 2, len(st))
+	})
 
 	t.Run("errors", func(t *testing.T) {
 		mustDo(t, c,
-			"MOVE",
-			proto.Error(errWrongNumber("move")),
+			"COPY",
+			proto.Error(errWrongNumber("copy")),
 		)
 		mustDo(t, c,
-			"MOVE", "foo",
-			proto.Error(errWrongNumber("move")),
+			"COPY", "key1",
+			proto.Error(errWrongNumber("copy")),
 		)
 		mustDo(t, c,
-			"MOVE", "foo", "noint",
-			proto.Error("ERR source and destination objects are the same"),
+			"COPY", "key1", "key1",
+			proto.Error(msgSameObject),
 		)
 		mustDo(t, c,
-			"MOVE", "foo", "2", "toomany",
-			proto.Error(errWrongNumber("move")),
+			"COPY", "key1", "key2", "FOO",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"COPY", "key1", "key2", "DB",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"COPY", "key1", "key2", "DB", "foo",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"COPY", "key1", "key2", "DB", "-1",
+			proto.Error(msgDBIndexOutOfRange),
 		)
 	})
 }
 
-func TestKeys(t *testing.T) {
+func TestCmdDump(t *testing.T) {
 	s, err := Run()
 	ok(t, err)
 	defer s.Close()
@@ -472,47 +925,28 @@ func TestKeys(t *testing.T) {
 	ok(t, err)
 	defer c.Close()
 
-	s.Set("foo", "bar!")
-	s.Set("foobar", "bar!")
-	s.Set("barfoo", "bar!")
-	s.Set("fooooo", "bar!")
-
-	mustDo(t, c,
-		"KEYS", "foo",
-		proto.Strings("foo"),
-	)
-
-	// simple '*'
-	mustDo(t, c,
-		"KEYS", "foo*",
-		proto.Strings("foo", "foobar", "fooooo"),
-	)
-
-	// simple '?'
+	// the example from the DUMP docs
+	mustOK(t, c, "SET", "mykey", "10")
 	mustDo(t, c,
-		"KEYS", "fo?",
-		proto.Strings("foo"),
+		"DUMP", "mykey",
+		proto.String("\x00\xc0\n\t\x00\xbem\x06\x89Z(\x00\n"),
 	)
 
-	// Don't die on never-matching pattern.
-	mustDo(t, c,
-		"KEYS", `f\`,
-		proto.Strings(),
-	)
+	mustNil(t, c, "DUMP", "nosuch")
 
-	t.Run("error", func(t *testing.T) {
+	t.Run("errors", func(t *testing.T) {
 		mustDo(t, c,
-			"KEYS",
-			proto.Error(errWrongNumber("keys")),
+			"DUMP",
+			proto.Error(errWrongNumber("dump")),
 		)
 		mustDo(t, c,
-			"KEYS", "foo", "noint",
-			proto.Error(errWrongNumber("keys")),
+			"DUMP", "mykey", "mykey",
+			proto.Error(errWrongNumber("dump")),
 		)
 	})
 }
 
-func TestRandom(t *testing.T) {
+func TestRestore(t *testing.T) {
 	s, err := Run()
 	ok(t, err)
 	defer s.Close()
@@ -520,37 +954,406 @@ func TestRandom(t *testing.T) {
 	ok(t, err)
 	defer c.Close()
 
-	// Empty db.
-	mustNil(t, c, "RANDOMKEY")
-
-	s.Set("one", "bar!")
-	s.Set("two", "bar!")
-	s.Set("three", "bar!")
-
-	// No idea which key will be returned.
-	{
-		v, err := c.Do("RANDOMKEY")
+	dump := func(key string) string {
+		t.Helper()
+		res, err := c.Do("DUMP", key)
 		ok(t, err)
-		assert(t, v == proto.String("one") || v == proto.String("two") || v == proto.String("three"), "RANDOMKEY looks sane")
+		v, err := proto.ReadString(res)
+		ok(t, err)
+		return v
 	}
 
-	t.Run("errors", func(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		// the example from the RESTORE docs, which has a ziplist
+		mustOK(t, c,
+			"RESTORE", "mylist", "0",
+			"\n\x17\x17\x00\x00\x00\x12\x00\x00\x00\x03\x00\x00\xc0\x01\x00\x04\xc0\x02\x00\x04\xc0\x03\x00\xff\x04\x00u#<\xc0;.\xe9\xdd",
+		)
 		mustDo(t, c,
-			"RANDOMKEY", "spurious",
-			proto.Error(errWrongNumber("randomkey")),
+			"LRANGE", "mylist", "0", "-1",
+			proto.Strings("1", "2", "3"),
 		)
+
+		s.HSet("hash", "aap", "noot")
+		s.SetTTL("hash", time.Minute)
+		payload := dump("hash")
+		mustOK(t, c, "RESTORE", "hash2", "0", payload)
+		equals(t, "noot", s.HGet("hash2", "aap"))
+		equals(t, time.Duration(0), s.TTL("hash2"))
+
+		mustOK(t, c, "RESTORE", "hash3", "1500", payload)
+		equals(t, 1500*time.Millisecond, s.TTL("hash3"))
 	})
-}
 
-func TestRename(t *testing.T) {
-	s, err := Run()
-	ok(t, err)
-	defer s.Close()
-	c, err := proto.Dial(s.Addr())
-	ok(t, err)
-	defer c.Close()
+	t.Run("all types", func(t *testing.T) {
+		s.Set("str", "value")
+		s.Lpush("list", "aap")
+		s.SetAdd("set", "aap", "noot")
+		s.ZAdd("zset", 3.5, "aap")
+		_, err := s.XAdd("stream", "1-1", []string{"aap", "noot"})
+		ok(t, err)
+		for _, k := range []string{"str", "list", "set", "zset", "stream"} {
+			mustOK(t, c, "RESTORE", k+"2", "0", dump(k))
+			equals(t, s.Type(k), s.Type(k+"2"))
+			equals(t, dump(k), dump(k+"2"))
+		}
+	})
 
-	// Non-existing key
+	t.Run("replace", func(t *testing.T) {
+		s.Set("rep", "old")
+		payload := dump("str")
+		mustDo(t, c,
+			"RESTORE", "rep", "0", payload,
+			proto.Error(msgBusyKey),
+		)
+		mustOK(t, c, "RESTORE", "rep", "0", payload, "REPLACE")
+		s.CheckGet(t, "rep", "value")
+	})
+
+	t.Run("absttl", func(t *testing.T) {
+		now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+		s.SetTime(now)
+		defer s.SetTime(time.Time{})
+		payload := dump("str")
+
+		at := now.Add(time.Minute).UnixNano() / int64(time.Millisecond)
+		mustOK(t, c, "RESTORE", "abs", strconv.FormatInt(at, 10), payload, "ABSTTL")
+		equals(t, time.Minute, s.TTL("abs"))
+
+		// already expired
+		at = now.Add(-time.Minute).UnixNano() / int64(time.Millisecond)
+		mustOK(t, c, "RESTORE", "abs", strconv.FormatInt(at, 10), payload, "ABSTTL", "REPLACE")
+		equals(t, false, s.Exists("abs"))
+	})
+
+	t.Run("idletime and freq", func(t *testing.T) {
+		payload := dump("str")
+		mustOK(t, c, "RESTORE", "idle", "0", payload, "IDLETIME", "100")
+		mustDo(t, c,
+			"OBJECT", "IDLETIME", "idle",
+			proto.Int(100),
+		)
+
+		ok(t, s.SetMaxMemoryPolicy("allkeys-lfu"))
+		defer s.SetMaxMemoryPolicy("noeviction")
+		mustOK(t, c, "RESTORE", "freq", "0", payload, "FREQ", "100")
+		mustDo(t, c,
+			"OBJECT", "FREQ", "freq",
+			proto.Int(100),
+		)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		payload := dump("str")
+		mustDo(t, c,
+			"RESTORE", "key", "0",
+			proto.Error(errWrongNumber("restore")),
+		)
+		mustDo(t, c,
+			"RESTORE", "key", "foo", payload,
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"RESTORE", "key", "-1", payload,
+			proto.Error(msgInvalidTTL),
+		)
+		mustDo(t, c,
+			"RESTORE", "key", "0", payload, "FOO",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"RESTORE", "key", "0", payload, "IDLETIME", "-1",
+			proto.Error(msgInvalidIdletime),
+		)
+		mustDo(t, c,
+			"RESTORE", "key", "0", payload, "FREQ", "256",
+			proto.Error(msgInvalidFreq),
+		)
+		mustDo(t, c,
+			"RESTORE", "key", "0", payload, "FREQ", "1", "IDLETIME", "1",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"RESTORE", "key", "0", "foo",
+			proto.Error(msgDumpPayload),
+		)
+		mustDo(t, c,
+			"RESTORE", "key", "0", payload[:len(payload)-1]+"x",
+			proto.Error(msgDumpPayload),
+		)
+		equals(t, false, s.Exists("key"))
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	target, err := Run()
+	ok(t, err)
+	defer target.Close()
+	host, port := target.Host(), target.Port()
+
+	t.Run("basic", func(t *testing.T) {
+		s.Set("key1", "value")
+		s.SetTTL("key1", time.Minute)
+		mustOK(t, c, "MIGRATE", host, port, "key1", "0", "1000")
+		equals(t, false, s.Exists("key1"))
+		target.CheckGet(t, "key1", "value")
+		equals(t, time.Minute, target.TTL("key1"))
+
+		mustDo(t, c,
+			"MIGRATE", host, port, "nosuch", "0", "1000",
+			proto.Inline("NOKEY"),
+		)
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		s.Set("key2", "value")
+		s.HSet("hash", "aap", "noot")
+		mustOK(t, c, "MIGRATE", host, port, "", "3", "1000", "COPY", "KEYS", "key2", "hash", "nosuch")
+		equals(t, true, s.Exists("key2"))
+		equals(t, true, s.Exists("hash"))
+		v, err := target.DB(3).Get("key2")
+		ok(t, err)
+		equals(t, "value", v)
+		equals(t, "noot", target.DB(3).HGet("hash", "aap"))
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		s.Set("rep", "new")
+		target.Set("rep", "old")
+		mustDo(t, c,
+			"MIGRATE", host, port, "rep", "0", "1000",
+			proto.Error("ERR Target instance replied with error: "+msgBusyKey),
+		)
+		equals(t, true, s.Exists("rep"))
+		target.CheckGet(t, "rep", "old")
+
+		mustOK(t, c, "MIGRATE", host, port, "rep", "0", "1000", "REPLACE")
+		equals(t, false, s.Exists("rep"))
+		target.CheckGet(t, "rep", "new")
+	})
+
+	t.Run("auth", func(t *testing.T) {
+		target.RequireAuth("secret")
+		defer target.RequireAuth("")
+
+		s.Set("auth", "value")
+		mustDo(t, c,
+			"MIGRATE", host, port, "auth", "0", "1000",
+			proto.Error("ERR Target instance replied with error: NOAUTH Authentication required."),
+		)
+		mustOK(t, c, "MIGRATE", host, port, "auth", "0", "1000", "AUTH", "secret")
+		target.CheckGet(t, "auth", "value")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		s.Set("key", "value")
+		mustDo(t, c,
+			"MIGRATE", host, port, "key", "0",
+			proto.Error(errWrongNumber("migrate")),
+		)
+		mustDo(t, c,
+			"MIGRATE", host, "foo", "key", "0", "1000",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"MIGRATE", host, port, "key", "foo", "1000",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"MIGRATE", host, port, "key", "0", "foo",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"MIGRATE", host, port, "key", "0", "1000", "FOO",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"MIGRATE", host, port, "key", "0", "1000", "AUTH",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"MIGRATE", host, port, "key", "0", "1000", "KEYS", "key",
+			proto.Error(msgMigrateKeys),
+		)
+		// we're locked, so this will time out
+		mustDo(t, c,
+			"MIGRATE", s.Host(), s.Port(), "key", "1", "50",
+			proto.Error(msgIOErrRead),
+		)
+		equals(t, true, s.Exists("key"))
+	})
+}
+
+func TestMove(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	// No problem.
+	{
+		s.Set("foo", "bar!")
+		must1(t, c, "MOVE", "foo", "1")
+	}
+
+	// Src key doesn't exists.
+	{
+		must0(t, c, "MOVE", "nosuch", "1")
+	}
+
+	// Target key already exists.
+	{
+		s.DB(0).Set("two", "orig")
+		s.DB(1).Set("two", "taken")
+		must0(t, c, "MOVE", "two", "1")
+		s.CheckGet(t, "two", "orig")
+	}
+
+	// TTL is also moved
+	{
+		s.DB(0).Set("one", "two")
+		s.DB(0).SetTTL("one", time.Second*4242)
+		must1(t, c, "MOVE", "one", "1")
+		equals(t, s.DB(1).TTL("one"), time.Second*4242)
+	}
+
+	t.Run("types", func(t *testing.T) {
+		s.HSet("hash", "aap", "noot")
+		s.ZAdd("zset", 1, "aap")
+		_, err := s.XAdd("stream", "1-1", []string{"aap", "noot"})
+		ok(t, err)
+		for _, k := range []string{"hash", "zset", "stream"} {
+			typ := s.Type(k)
+			must1(t, c, "MOVE", k, "3")
+			equals(t, false, s.Exists(k))
+			equals(t, typ, s.DB(3).Type(k))
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"MOVE",
+			proto.Error(errWrongNumber("move")),
+		)
+		mustDo(t, c,
+			"MOVE", "foo",
+			proto.Error(errWrongNumber("move")),
+		)
+		mustDo(t, c,
+			"MOVE", "foo", "noint",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"MOVE", "foo", "-1",
+			proto.Error(msgDBIndexOutOfRange),
+		)
+		mustDo(t, c,
+			"MOVE", "foo", "0",
+			proto.Error(msgSameObject),
+		)
+		mustDo(t, c,
+			"MOVE", "foo", "2", "toomany",
+			proto.Error(errWrongNumber("move")),
+		)
+	})
+}
+
+func TestKeys(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.Set("foo", "bar!")
+	s.Set("foobar", "bar!")
+	s.Set("barfoo", "bar!")
+	s.Set("fooooo", "bar!")
+
+	mustDo(t, c,
+		"KEYS", "foo",
+		proto.Strings("foo"),
+	)
+
+	// simple '*'
+	mustDo(t, c,
+		"KEYS", "foo*",
+		proto.Strings("foo", "foobar", "fooooo"),
+	)
+
+	// simple '?'
+	mustDo(t, c,
+		"KEYS", "fo?",
+		proto.Strings("foo"),
+	)
+
+	// Don't die on never-matching pattern.
+	mustDo(t, c,
+		"KEYS", `f\`,
+		proto.Strings(),
+	)
+
+	t.Run("error", func(t *testing.T) {
+		mustDo(t, c,
+			"KEYS",
+			proto.Error(errWrongNumber("keys")),
+		)
+		mustDo(t, c,
+			"KEYS", "foo", "noint",
+			proto.Error(errWrongNumber("keys")),
+		)
+	})
+}
+
+func TestRandom(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	// Empty db.
+	mustNil(t, c, "RANDOMKEY")
+
+	s.Set("one", "bar!")
+	s.Set("two", "bar!")
+	s.Set("three", "bar!")
+
+	// No idea which key will be returned.
+	{
+		v, err := c.Do("RANDOMKEY")
+		ok(t, err)
+		assert(t, v == proto.String("one") || v == proto.String("two") || v == proto.String("three"), "RANDOMKEY looks sane")
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"RANDOMKEY", "spurious",
+			proto.Error(errWrongNumber("randomkey")),
+		)
+	})
+}
+
+func TestRename(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	// Non-existing key
 	mustDo(t, c,
 		"RENAME", "nosuch", "to",
 		proto.Error("ERR no such key"),
@@ -682,11 +1485,155 @@ func TestScan(t *testing.T) {
 		)
 	})
 
+	t.Run("type", func(t *testing.T) {
+		s.HSet("hash1", "aap", "noot")
+		s.HSet("hash2", "aap", "noot")
+		_, err := s.XAdd("stream", "1-1", []string{"aap", "noot"})
+		ok(t, err)
+
+		mustDo(t, c,
+			"SCAN", "0", "TYPE", "hash",
+			proto.Array(
+				proto.String("0"),
+				proto.Strings("hash1", "hash2"),
+			),
+		)
+		mustDo(t, c,
+			"SCAN", "0", "TYPE", "STREAM",
+			proto.Array(
+				proto.String("0"),
+				proto.Strings("stream"),
+			),
+		)
+		mustDo(t, c,
+			"SCAN", "0", "MATCH", "*2", "TYPE", "hash",
+			proto.Array(
+				proto.String("0"),
+				proto.Strings("hash2"),
+			),
+		)
+		mustDo(t, c,
+			"SCAN", "0", "TYPE", "nosuch",
+			proto.Array(
+				proto.String("0"),
+				proto.Strings(),
+			),
+		)
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		s.FlushAll()
+		for i := 0; i < 25; i++ {
+			s.Set(fmt.Sprintf("key%02d", i), "value")
+		}
+
+		scan := func(cursor string, args ...string) (string, []string) {
+			t.Helper()
+			res, err := c.Do(append([]string{"SCAN", cursor}, args...)...)
+			ok(t, err)
+			elems, err := proto.ReadArray(res)
+			ok(t, err)
+			next, err := proto.ReadString(elems[0])
+			ok(t, err)
+			keys, err := proto.ReadStrings(elems[1])
+			ok(t, err)
+			return next, keys
+		}
+
+		// default COUNT is 10
+		cur, keys := scan("0")
+		equals(t, 10, len(keys))
+		equals(t, "key00", keys[0])
+		cur2, keys := scan(cur)
+		equals(t, 10, len(keys))
+		equals(t, "key10", keys[0])
+		// a retry gives the same page
+		_, keys = scan(cur)
+		equals(t, 10, len(keys))
+		equals(t, "key10", keys[0])
+		next, keys := scan(cur2)
+		equals(t, "0", next)
+		equals(t, 5, len(keys))
+		equals(t, 0, len(s.scanCursors))
+		// and once we're done the cursors are gone
+		mustDo(t, c,
+			"SCAN", cur,
+			proto.Array(proto.String("0"), proto.Strings()),
+		)
+
+		// changes during an iteration
+		cur, keys = scan("0", "COUNT", "5")
+		equals(t, []string{"key00", "key01", "key02", "key03", "key04"}, keys)
+		s.Del("key05")
+		s.Del("key01")
+		s.Set("key04a", "value")
+		s.Set("key000", "value")
+		cur, keys = scan(cur, "COUNT", "5")
+		equals(t, []string{"key04a", "key06", "key07", "key08", "key09"}, keys)
+
+		// COUNT is applied before MATCH
+		cur, keys = scan(cur, "COUNT", "5", "MATCH", "nosuch*")
+		assert(t, cur != "0", "not done yet")
+		equals(t, 0, len(keys))
+
+		// all of them
+		cur, keys = scan("0", "COUNT", "1000")
+		equals(t, "0", cur)
+		equals(t, 25, len(keys))
+
+		// cursors are per DB
+		cur, _ = scan("0", "COUNT", "5")
+		mustOK(t, c, "SELECT", "2")
+		mustDo(t, c,
+			"SCAN", cur,
+			proto.Array(proto.String("0"), proto.Strings()),
+		)
+		mustOK(t, c, "SELECT", "0")
+
+		// FLUSHDB forgets the iterations of that database
+		open := len(s.scanCursors)
+		scan("0", "COUNT", "5")
+		mustOK(t, c, "SELECT", "2")
+		mustOK(t, c, "SET", "foo", "bar")
+		mustOK(t, c, "SET", "foo2", "bar")
+		scan("0", "COUNT", "1")
+		equals(t, open+2, len(s.scanCursors))
+		mustOK(t, c, "FLUSHDB")
+		equals(t, open+1, len(s.scanCursors))
+		mustOK(t, c, "SELECT", "0")
+		mustOK(t, c, "FLUSHALL")
+		equals(t, 0, len(s.scanCursors))
+
+		// iterations which never finish don't pile up
+		for i := 0; i < 10; i++ {
+			s.Set(fmt.Sprintf("key%02d", i), "value")
+		}
+		first, _ := scan("0", "COUNT", "5")
+		for i := 0; i < maxScanCursors; i++ {
+			scan("0", "COUNT", "5")
+		}
+		equals(t, maxScanCursors, len(s.scanCursors))
+		mustDo(t, c,
+			"SCAN", first,
+			proto.Array(proto.String("0"), proto.Strings()),
+		)
+
+		// unknown cursor
+		mustDo(t, c,
+			"SCAN", "123456",
+			proto.Array(proto.String("0"), proto.Strings()),
+		)
+	})
+
 	t.Run("errors", func(t *testing.T) {
 		mustDo(t, c,
 			"SCAN",
 			proto.Error(errWrongNumber("scan")),
 		)
+		mustDo(t, c,
+			"SCAN", "0", "COUNT", "0",
+			proto.Error(msgSyntaxError),
+		)
 		mustDo(t, c,
 			"SCAN", "noint",
 			proto.Error("ERR invalid cursor"),
@@ -703,6 +1650,10 @@ func TestScan(t *testing.T) {
 			"SCAN", "1", "COUNT", "noint",
 			proto.Error("ERR value is not an integer or out of range"),
 		)
+		mustDo(t, c,
+			"SCAN", "1", "TYPE",
+			proto.Error("ERR syntax error"),
+		)
 	})
 }
 
@@ -762,3 +1713,309 @@ func TestRenamenx(t *testing.T) {
 		)
 	})
 }
+
+func TestSort(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.Push("list", "3", "1", "20", "2")
+	s.SetAdd("set", "b", "c", "a")
+	s.ZAdd("zset", 3, "one")
+	s.ZAdd("zset", 1, "two")
+	s.ZAdd("zset", 2, "three")
+
+	t.Run("basic", func(t *testing.T) {
+		mustDo(t, c,
+			"SORT", "list",
+			proto.Strings("1", "2", "3", "20"),
+		)
+		mustDo(t, c,
+			"SORT", "list", "DESC",
+			proto.Strings("20", "3", "2", "1"),
+		)
+		mustDo(t, c,
+			"SORT", "list", "ALPHA",
+			proto.Strings("1", "2", "20", "3"),
+		)
+		mustDo(t, c,
+			"SORT", "set", "ALPHA", "DESC",
+			proto.Strings("c", "b", "a"),
+		)
+		mustDo(t, c,
+			"SORT", "set",
+			proto.Error(msgSortScores),
+		)
+		mustDo(t, c,
+			"SORT", "nosuch",
+			proto.Strings(),
+		)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		mustDo(t, c,
+			"SORT", "list", "LIMIT", "1", "2",
+			proto.Strings("2", "3"),
+		)
+		mustDo(t, c,
+			"SORT", "list", "LIMIT", "-5", "2",
+			proto.Strings("1", "2"),
+		)
+		mustDo(t, c,
+			"SORT", "list", "LIMIT", "2", "-1",
+			proto.Strings("3", "20"),
+		)
+		mustDo(t, c,
+			"SORT", "list", "LIMIT", "10", "2",
+			proto.Strings(),
+		)
+		mustDo(t, c,
+			"SORT", "list", "LIMIT", "1", "9223372036854775807",
+			proto.Strings("2", "3", "20"),
+		)
+	})
+
+	t.Run("by", func(t *testing.T) {
+		s.Set("weight_1", "30")
+		s.Set("weight_2", "10")
+		s.Set("weight_3", "20")
+		// weight_20 doesn't exist, and counts as 0
+		mustDo(t, c,
+			"SORT", "list", "BY", "weight_*",
+			proto.Strings("20", "2", "3", "1"),
+		)
+
+		s.HSet("obj_1", "w", "b")
+		s.HSet("obj_2", "w", "a")
+		s.HSet("obj_3", "w", "c")
+		mustDo(t, c,
+			"SORT", "list", "BY", "obj_*->w", "ALPHA",
+			proto.Strings("20", "2", "1", "3"),
+		)
+
+		// no "*": don't sort
+		mustDo(t, c,
+			"SORT", "list", "BY", "nosort",
+			proto.Strings("3", "1", "20", "2"),
+		)
+		mustDo(t, c,
+			"SORT", "zset", "BY", "nosort",
+			proto.Strings("two", "three", "one"),
+		)
+		mustDo(t, c,
+			"SORT", "zset", "BY", "nosort", "DESC", "LIMIT", "0", "2",
+			proto.Strings("one", "three"),
+		)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		s.Set("name_1", "aap")
+		s.Set("name_2", "noot")
+		s.Set("name_3", "mies")
+		mustDo(t, c,
+			"SORT", "list", "GET", "name_*", "GET", "#", "GET", "obj_*->w",
+			proto.Array(
+				proto.String("aap"), proto.String("1"), proto.String("b"),
+				proto.String("noot"), proto.String("2"), proto.String("a"),
+				proto.String("mies"), proto.String("3"), proto.String("c"),
+				proto.Nil, proto.String("20"), proto.Nil,
+			),
+		)
+		mustDo(t, c,
+			"SORT", "list", "GET", "nostar", "LIMIT", "0", "1",
+			proto.Array(proto.Nil),
+		)
+	})
+
+	t.Run("store", func(t *testing.T) {
+		mustDo(t, c,
+			"SORT", "list", "GET", "name_*", "STORE", "dest",
+			proto.Int(4),
+		)
+		l, err := s.List("dest")
+		ok(t, err)
+		equals(t, []string{"aap", "noot", "mies", ""}, l)
+
+		s.Set("dest2", "string")
+		must0(t, c, "SORT", "nosuch", "STORE", "dest2")
+		equals(t, false, s.Exists("dest2"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"SORT",
+			proto.Error(errWrongNumber("sort")),
+		)
+		mustDo(t, c,
+			"SORT", "list", "LIMIT", "1",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"SORT", "list", "LIMIT", "a", "1",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"SORT", "list", "FOO",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"SORT", "list", "BY",
+			proto.Error(msgSyntaxError),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"SORT", "str",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
+func TestSortRO(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.Push("list", "3", "1", "2")
+	s.Set("name_1", "aap")
+	mustDo(t, c,
+		"SORT_RO", "list",
+		proto.Strings("1", "2", "3"),
+	)
+	mustDo(t, c,
+		"SORT_RO", "list", "DESC", "LIMIT", "0", "1", "GET", "name_*",
+		proto.Array(proto.Nil),
+	)
+	mustDo(t, c,
+		"SORT_RO", "list", "GET", "name_*", "LIMIT", "0", "1",
+		proto.Array(proto.String("aap")),
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"SORT_RO",
+			proto.Error(errWrongNumber("sort_ro")),
+		)
+		mustDo(t, c,
+			"SORT_RO", "list", "STORE", "dest",
+			proto.Error(msgSyntaxError),
+		)
+		equals(t, false, s.Exists("dest"))
+	})
+}
+
+func TestWait(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	must0(t, c, "WAIT", "1", "0")
+
+	s.SetReplicas(2)
+	mustDo(t, c,
+		"WAIT", "1", "100",
+		proto.Int(2),
+	)
+
+	t.Run("tx", func(t *testing.T) {
+		mustOK(t, c, "MULTI")
+		mustDo(t, c, "WAIT", "1", "0", proto.Inline("QUEUED"))
+		mustDo(t, c,
+			"EXEC",
+			proto.Array(proto.Int(2)),
+		)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"WAIT", "1",
+			proto.Error(errWrongNumber("wait")),
+		)
+		mustDo(t, c,
+			"WAIT", "foo", "0",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"WAIT", "1", "foo",
+			proto.Error(msgInvalidIntTimeout),
+		)
+		mustDo(t, c,
+			"WAIT", "1", "-1",
+			proto.Error(msgNegTimeout),
+		)
+		mustContain(t, c,
+			"EVAL", "return redis.call('WAIT', '1', '0')", "0",
+			msgNotFromScripts,
+		)
+	})
+}
+
+func TestWaitaof(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	mustDo(t, c,
+		"WAITAOF", "0", "0", "0",
+		proto.Array(proto.Int(0), proto.Int(0)),
+	)
+	mustDo(t, c,
+		"WAITAOF", "1", "0", "0",
+		proto.Error(msgWaitaofAppendonly),
+	)
+
+	s.SetAppendOnly(true)
+	s.SetReplicas(2)
+	mustDo(t, c,
+		"WAITAOF", "1", "1", "100",
+		proto.Array(proto.Int(1), proto.Int(2)),
+	)
+
+	mustOK(t, c, "CONFIG", "SET", "appendonly", "no")
+	mustDo(t, c,
+		"WAITAOF", "0", "1", "0",
+		proto.Array(proto.Int(0), proto.Int(2)),
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"WAITAOF", "1", "1",
+			proto.Error(errWrongNumber("waitaof")),
+		)
+		mustDo(t, c,
+			"WAITAOF", "foo", "0", "0",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"WAITAOF", "0", "foo", "0",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"WAITAOF", "-1", "0", "0",
+			proto.Error(msgPositive),
+		)
+		mustDo(t, c,
+			"WAITAOF", "0", "0", "foo",
+			proto.Error(msgInvalidIntTimeout),
+		)
+		mustDo(t, c,
+			"WAITAOF", "0", "0", "-1",
+			proto.Error(msgNegTimeout),
+		)
+		mustContain(t, c,
+			"EVAL", "return redis.call('WAITAOF', '0', '0', '0')", "0",
+			msgNotFromScripts,
+		)
+	})
+}
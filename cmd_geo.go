@@ -3,6 +3,7 @@
 package miniredis
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 	"strconv"
@@ -15,16 +16,19 @@ import (
 func commandsGeo(m *Miniredis) {
 	m.srv.Register("GEOADD", m.cmdGeoadd)
 	m.srv.Register("GEODIST", m.cmdGeodist)
+	m.srv.Register("GEOHASH", m.cmdGeohash)
 	m.srv.Register("GEOPOS", m.cmdGeopos)
 	m.srv.Register("GEORADIUS", m.cmdGeoradius)
 	m.srv.Register("GEORADIUS_RO", m.cmdGeoradius)
 	m.srv.Register("GEORADIUSBYMEMBER", m.cmdGeoradiusbymember)
 	m.srv.Register("GEORADIUSBYMEMBER_RO", m.cmdGeoradiusbymember)
+	m.srv.Register("GEOSEARCH", m.cmdGeosearch)
+	m.srv.Register("GEOSEARCHSTORE", m.cmdGeosearchstore)
 }
 
 // GEOADD
 func (m *Miniredis) cmdGeoadd(c *server.Peer, cmd string, args []string) {
-	if len(args) < 3 || len(args[1:])%3 != 0 {
+	if len(args) < 4 {
 		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
@@ -37,6 +41,32 @@ func (m *Miniredis) cmdGeoadd(c *server.Peer, cmd string, args []string) {
 	}
 	key, args := args[0], args[1:]
 
+	var nx, xx, ch bool
+outer:
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "CH":
+			ch = true
+		default:
+			break outer
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 || len(args)%3 != 0 {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+	if nx && xx {
+		setDirty(c)
+		c.WriteError(msgXXandNX)
+		return
+	}
+
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		db := m.db(ctx.selectedDB)
 
@@ -72,11 +102,23 @@ func (m *Miniredis) cmdGeoadd(c *server.Peer, cmd string, args []string) {
 		}
 
 		set := 0
+		changed := false
 		for name, score := range toSet {
-			if db.ssetAdd(key, score, name) {
+			old, exists := db.sortedsetKeys[key][name]
+			if (nx && exists) || (xx && !exists) {
+				continue
+			}
+			if !exists || old != score {
+				changed = true
+			}
+			if db.ssetAdd(key, score, name) || (ch && old != score) {
 				set++
 			}
 		}
+		if changed {
+			// GEOADD is ZADD, as far as events go
+			m.notify(db.id, notifyZset, "zadd", key)
+		}
 		c.WriteInt(set)
 	})
 }
@@ -177,6 +219,41 @@ func (m *Miniredis) cmdGeopos(c *server.Peer, cmd string, args []string) {
 	})
 }
 
+// GEOHASH
+func (m *Miniredis) cmdGeohash(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+	key, args := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "zset" {
+			c.WriteError(ErrWrongType.Error())
+			return
+		}
+
+		c.WriteLen(len(args))
+		for _, l := range args {
+			if !db.ssetExists(key, l) {
+				c.WriteNull()
+				continue
+			}
+			long, lat := fromGeohash(uint64(db.ssetScore(key, l)))
+			c.WriteBulk(toGeohashString(long, lat))
+		}
+	})
+}
+
 type geoDistance struct {
 	Name      string
 	Score     float64
@@ -200,175 +277,70 @@ func (m *Miniredis) cmdGeoradius(c *server.Peer, cmd string, args []string) {
 	}
 
 	key := args[0]
-	longitude, err := strconv.ParseFloat(args[1], 64)
+	opts := geoSearchOpts{withLonLat: true, byRadius: true}
+	var err error
+	opts.longitude, err = strconv.ParseFloat(args[1], 64)
 	if err != nil {
 		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
 	}
-	latitude, err := strconv.ParseFloat(args[2], 64)
+	opts.latitude, err = strconv.ParseFloat(args[2], 64)
 	if err != nil {
 		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
 	}
-	radius, err := strconv.ParseFloat(args[3], 64)
-	if err != nil || radius < 0 {
+	if opts.latitude < -85.05112878 ||
+		opts.latitude > 85.05112878 ||
+		opts.longitude < -180 ||
+		opts.longitude > 180 {
+		setDirty(c)
+		c.WriteError(fmt.Sprintf("ERR invalid longitude,latitude pair %.6f,%.6f", opts.longitude, opts.latitude))
+		return
+	}
+	opts.radius, err = strconv.ParseFloat(args[3], 64)
+	if err != nil || opts.radius < 0 {
 		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
 	}
-	toMeter := parseUnit(args[4])
-	if toMeter == 0 {
+	opts.toMeter = parseUnit(args[4])
+	if opts.toMeter == 0 {
 		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
 	}
-	args = args[5:]
 
-	var (
-		withDist      = false
-		withCoord     = false
-		direction     = unsorted
-		count         = 0
-		withStore     = false
-		storeKey      = ""
-		withStoredist = false
-		storedistKey  = ""
-	)
-	for len(args) > 0 {
-		arg := args[0]
-		args = args[1:]
-		switch strings.ToUpper(arg) {
-		case "WITHCOORD":
-			withCoord = true
-		case "WITHDIST":
-			withDist = true
-		case "ASC":
-			direction = asc
-		case "DESC":
-			direction = desc
-		case "COUNT":
-			if len(args) == 0 {
-				setDirty(c)
-				c.WriteError("ERR syntax error")
-				return
-			}
-			n, err := strconv.Atoi(args[0])
-			if err != nil {
-				setDirty(c)
-				c.WriteError(msgInvalidInt)
-				return
-			}
-			if n <= 0 {
-				setDirty(c)
-				c.WriteError("ERR COUNT must be > 0")
-				return
-			}
-			args = args[1:]
-			count = n
-		case "STORE":
-			if len(args) == 0 {
-				setDirty(c)
-				c.WriteError("ERR syntax error")
-				return
-			}
-			withStore = true
-			storeKey = args[0]
-			args = args[1:]
-		case "STOREDIST":
-			if len(args) == 0 {
-				setDirty(c)
-				c.WriteError("ERR syntax error")
-				return
-			}
-			withStoredist = true
-			storedistKey = args[0]
-			args = args[1:]
-		default:
-			setDirty(c)
-			c.WriteError("ERR syntax error")
-			return
-		}
+	storeKey, msg := parseGeoradiusArgs(args[5:], &opts)
+	if msg == "" && storeKey != "" && strings.ToUpper(cmd) == "GEORADIUS_RO" {
+		msg = msgSyntaxError
 	}
-
-	if strings.ToUpper(cmd) == "GEORADIUS_RO" && (withStore || withStoredist) {
+	if msg != "" {
 		setDirty(c)
-		c.WriteError("ERR syntax error")
+		c.WriteError(msg)
 		return
 	}
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
-		if (withStore || withStoredist) && (withDist || withCoord) {
-			c.WriteError("ERR STORE option in GEORADIUS is not compatible with WITHDIST, WITHHASH and WITHCOORDS options")
+		if storeKey != "" && (opts.withDist || opts.withHash || opts.withCoord) {
+			c.WriteError(msgGeoStoreWith)
 			return
 		}
 
 		db := m.db(ctx.selectedDB)
-		members := db.ssetElements(key)
-
-		matches := withinRadius(members, longitude, latitude, radius*toMeter)
-
-		// deal with ASC/DESC
-		if direction != unsorted {
-			sort.Slice(matches, func(i, j int) bool {
-				if direction == desc {
-					return matches[i].Distance > matches[j].Distance
-				}
-				return matches[i].Distance < matches[j].Distance
-			})
-		}
-
-		// deal with COUNT
-		if count > 0 && len(matches) > count {
-			matches = matches[:count]
-		}
-
-		// deal with "STORE x"
-		if withStore {
-			db.del(storeKey, true)
-			for _, member := range matches {
-				db.ssetAdd(storeKey, member.Score, member.Name)
-			}
-			c.WriteInt(len(matches))
+		matches, err := db.geoSearch(key, opts)
+		if err != nil {
+			c.WriteError(err.Error())
 			return
 		}
 
-		// deal with "STOREDIST x"
-		if withStoredist {
-			db.del(storedistKey, true)
-			for _, member := range matches {
-				db.ssetAdd(storedistKey, member.Distance/toMeter, member.Name)
-			}
+		if storeKey != "" {
+			db.geoStore(storeKey, matches, opts, "georadiusstore")
 			c.WriteInt(len(matches))
 			return
 		}
-
-		c.WriteLen(len(matches))
-		for _, member := range matches {
-			if !withDist && !withCoord {
-				c.WriteBulk(member.Name)
-				continue
-			}
-
-			len := 1
-			if withDist {
-				len++
-			}
-			if withCoord {
-				len++
-			}
-			c.WriteLen(len)
-			c.WriteBulk(member.Name)
-			if withDist {
-				c.WriteBulk(fmt.Sprintf("%.4f", member.Distance/toMeter))
-			}
-			if withCoord {
-				c.WriteLen(2)
-				c.WriteBulk(fmt.Sprintf("%f", member.Longitude))
-				c.WriteBulk(fmt.Sprintf("%f", member.Latitude))
-			}
-		}
+		writeGeoMatches(c, matches, opts)
 	})
 }
 
@@ -387,183 +359,416 @@ func (m *Miniredis) cmdGeoradiusbymember(c *server.Peer, cmd string, args []stri
 	}
 
 	key := args[0]
-	member := args[1]
-
-	radius, err := strconv.ParseFloat(args[2], 64)
-	if err != nil || radius < 0 {
+	opts := geoSearchOpts{withMember: true, fromMember: args[1], byRadius: true}
+	var err error
+	opts.radius, err = strconv.ParseFloat(args[2], 64)
+	if err != nil || opts.radius < 0 {
 		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
 	}
-	toMeter := parseUnit(args[3])
-	if toMeter == 0 {
+	opts.toMeter = parseUnit(args[3])
+	if opts.toMeter == 0 {
 		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
 	}
-	args = args[4:]
 
-	var (
-		withDist      = false
-		withCoord     = false
-		direction     = unsorted
-		count         = 0
-		withStore     = false
-		storeKey      = ""
-		withStoredist = false
-		storedistKey  = ""
-	)
+	storeKey, msg := parseGeoradiusArgs(args[4:], &opts)
+	if msg == "" && storeKey != "" && strings.ToUpper(cmd) == "GEORADIUSBYMEMBER_RO" {
+		msg = msgSyntaxError
+	}
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		if storeKey != "" && (opts.withDist || opts.withHash || opts.withCoord) {
+			c.WriteError(msgGeoStoreWith)
+			return
+		}
+
+		db := m.db(ctx.selectedDB)
+		if !db.exists(key) {
+			c.WriteNull()
+			return
+		}
+
+		matches, err := db.geoSearch(key, opts)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+
+		if storeKey != "" {
+			db.geoStore(storeKey, matches, opts, "georadiusstore")
+			c.WriteInt(len(matches))
+			return
+		}
+		writeGeoMatches(c, matches, opts)
+	})
+}
+
+// parseGeoradiusArgs parses the GEORADIUS and GEORADIUSBYMEMBER options
+// after the unit. Returns the STORE or STOREDIST key, if any, or an error
+// message.
+func parseGeoradiusArgs(args []string, opts *geoSearchOpts) (string, string) {
+	opts.direction = unsorted
+	storeKey := ""
 	for len(args) > 0 {
-		arg := args[0]
+		arg := strings.ToUpper(args[0])
 		args = args[1:]
-		switch strings.ToUpper(arg) {
+		switch arg {
 		case "WITHCOORD":
-			withCoord = true
+			opts.withCoord = true
 		case "WITHDIST":
-			withDist = true
+			opts.withDist = true
+		case "WITHHASH":
+			opts.withHash = true
 		case "ASC":
-			direction = asc
+			opts.direction = asc
 		case "DESC":
-			direction = desc
+			opts.direction = desc
 		case "COUNT":
 			if len(args) == 0 {
-				setDirty(c)
-				c.WriteError("ERR syntax error")
-				return
+				return "", msgSyntaxError
 			}
 			n, err := strconv.Atoi(args[0])
 			if err != nil {
-				setDirty(c)
-				c.WriteError(msgInvalidInt)
-				return
+				return "", msgInvalidInt
 			}
 			if n <= 0 {
-				setDirty(c)
-				c.WriteError("ERR COUNT must be > 0")
-				return
+				return "", "ERR COUNT must be > 0"
 			}
+			opts.count = n
 			args = args[1:]
-			count = n
-		case "STORE":
+		case "ANY":
+			opts.any = true
+		case "STORE", "STOREDIST":
 			if len(args) == 0 {
-				setDirty(c)
-				c.WriteError("ERR syntax error")
-				return
+				return "", msgSyntaxError
 			}
-			withStore = true
-			storeKey = args[0]
-			args = args[1:]
-		case "STOREDIST":
-			if len(args) == 0 {
-				setDirty(c)
-				c.WriteError("ERR syntax error")
-				return
+			storeKey, args = args[0], args[1:]
+			opts.storeDist = arg == "STOREDIST"
+		default:
+			return "", msgSyntaxError
+		}
+	}
+	if opts.any && opts.count == 0 {
+		return "", msgAnyWithoutCount
+	}
+	// COUNT without ANY always gives the closest ones.
+	if opts.count > 0 && !opts.any && opts.direction == unsorted {
+		opts.direction = asc
+	}
+	return storeKey, ""
+}
+
+// geoSearchOpts are the GEOSEARCH and GEOSEARCHSTORE arguments, after the
+// key(s).
+type geoSearchOpts struct {
+	fromMember    string
+	withMember    bool // FROMMEMBER
+	longitude     float64
+	latitude      float64
+	withLonLat    bool // FROMLONLAT
+	radius        float64
+	byRadius      bool
+	width, height float64
+	byBox         bool
+	toMeter       float64
+	direction     direction
+	count         int
+	any           bool
+	withCoord     bool
+	withDist      bool
+	withHash      bool
+	storeDist     bool
+}
+
+// parseGeosearchArgs parses everything after the key(s). Returns an error
+// message on failure.
+func parseGeosearchArgs(cmd string, args []string, store bool) (geoSearchOpts, string) {
+	opts := geoSearchOpts{direction: unsorted}
+	var err error
+	for len(args) > 0 {
+		arg := strings.ToUpper(args[0])
+		args = args[1:]
+		switch {
+		case arg == "FROMMEMBER" && len(args) > 0:
+			if opts.withMember || opts.withLonLat {
+				return opts, fmt.Sprintf("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", cmd)
+			}
+			opts.withMember = true
+			opts.fromMember, args = args[0], args[1:]
+		case arg == "FROMLONLAT" && len(args) > 1:
+			if opts.withMember || opts.withLonLat {
+				return opts, fmt.Sprintf("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", cmd)
+			}
+			opts.withLonLat = true
+			if opts.longitude, err = strconv.ParseFloat(args[0], 64); err != nil {
+				return opts, msgInvalidFloat
+			}
+			if opts.latitude, err = strconv.ParseFloat(args[1], 64); err != nil {
+				return opts, msgInvalidFloat
+			}
+			if opts.latitude < -85.05112878 ||
+				opts.latitude > 85.05112878 ||
+				opts.longitude < -180 ||
+				opts.longitude > 180 {
+				return opts, fmt.Sprintf("ERR invalid longitude,latitude pair %.6f,%.6f", opts.longitude, opts.latitude)
+			}
+			args = args[2:]
+		case arg == "BYRADIUS" && len(args) > 1:
+			if opts.byRadius || opts.byBox {
+				return opts, fmt.Sprintf("ERR exactly one of BYRADIUS and BYBOX can be specified for %s", cmd)
+			}
+			opts.byRadius = true
+			if opts.radius, err = strconv.ParseFloat(args[0], 64); err != nil {
+				return opts, "ERR need numeric radius"
+			}
+			if opts.radius < 0 {
+				return opts, "ERR radius cannot be negative"
 			}
-			withStoredist = true
-			storedistKey = args[0]
+			if opts.toMeter = parseUnit(args[1]); opts.toMeter == 0 {
+				return opts, msgUnsupportedUnit
+			}
+			args = args[2:]
+		case arg == "BYBOX" && len(args) > 2:
+			if opts.byRadius || opts.byBox {
+				return opts, fmt.Sprintf("ERR exactly one of BYRADIUS and BYBOX can be specified for %s", cmd)
+			}
+			opts.byBox = true
+			if opts.width, err = strconv.ParseFloat(args[0], 64); err != nil {
+				return opts, msgInvalidFloat
+			}
+			if opts.height, err = strconv.ParseFloat(args[1], 64); err != nil {
+				return opts, msgInvalidFloat
+			}
+			if opts.width < 0 || opts.height < 0 {
+				return opts, "ERR height or width cannot be negative"
+			}
+			if opts.toMeter = parseUnit(args[2]); opts.toMeter == 0 {
+				return opts, msgUnsupportedUnit
+			}
+			args = args[3:]
+		case arg == "ASC":
+			opts.direction = asc
+		case arg == "DESC":
+			opts.direction = desc
+		case arg == "COUNT" && len(args) > 0:
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return opts, msgInvalidInt
+			}
+			if n <= 0 {
+				return opts, "ERR COUNT must be > 0"
+			}
+			opts.count = n
 			args = args[1:]
+		case arg == "ANY":
+			opts.any = true
+		case arg == "WITHCOORD" && !store:
+			opts.withCoord = true
+		case arg == "WITHDIST" && !store:
+			opts.withDist = true
+		case arg == "WITHHASH" && !store:
+			opts.withHash = true
+		case arg == "STOREDIST" && store:
+			opts.storeDist = true
 		default:
-			setDirty(c)
-			c.WriteError("ERR syntax error")
-			return
+			return opts, msgSyntaxError
+		}
+	}
+
+	if !opts.withMember && !opts.withLonLat {
+		return opts, fmt.Sprintf("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", cmd)
+	}
+	if !opts.byRadius && !opts.byBox {
+		return opts, fmt.Sprintf("ERR exactly one of BYRADIUS and BYBOX can be specified for %s", cmd)
+	}
+	if opts.any && opts.count == 0 {
+		return opts, msgAnyWithoutCount
+	}
+	// COUNT without ANY always gives the closest ones.
+	if opts.count > 0 && !opts.any && opts.direction == unsorted {
+		opts.direction = asc
+	}
+	return opts, ""
+}
+
+// geoSearch runs a GEOSEARCH query. No locks!
+func (db *RedisDB) geoSearch(key string, opts geoSearchOpts) ([]geoDistance, error) {
+	if !db.exists(key) {
+		return nil, nil
+	}
+	if db.t(key) != "zset" {
+		return nil, ErrWrongType
+	}
+
+	longitude, latitude := opts.longitude, opts.latitude
+	if opts.withMember {
+		if !db.ssetExists(key, opts.fromMember) {
+			return nil, errors.New("ERR could not decode requested zset member")
 		}
+		longitude, latitude = fromGeohash(uint64(db.ssetScore(key, opts.fromMember)))
 	}
 
-	if strings.ToUpper(cmd) == "GEORADIUSBYMEMBER_RO" && (withStore || withStoredist) {
+	members := db.ssetElements(key)
+	var matches []geoDistance
+	if opts.byRadius {
+		matches = withinRadius(members, longitude, latitude, opts.radius*opts.toMeter)
+	} else {
+		matches = withinBox(members, longitude, latitude, opts.width*opts.toMeter, opts.height*opts.toMeter)
+	}
+
+	// with ANY we take the first COUNT matches we find, and only then sort
+	if opts.any && len(matches) > opts.count {
+		matches = matches[:opts.count]
+	}
+	if opts.direction != unsorted {
+		sort.SliceStable(matches, func(i, j int) bool {
+			if opts.direction == desc {
+				return matches[i].Distance > matches[j].Distance
+			}
+			return matches[i].Distance < matches[j].Distance
+		})
+	}
+	if opts.count > 0 && len(matches) > opts.count {
+		matches = matches[:opts.count]
+	}
+	return matches, nil
+}
+
+// GEOSEARCH
+func (m *Miniredis) cmdGeosearch(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	opts, msg := parseGeosearchArgs(cmd, args[1:], false)
+	if msg != "" {
 		setDirty(c)
-		c.WriteError("ERR syntax error")
+		c.WriteError(msg)
 		return
 	}
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
-		if (withStore || withStoredist) && (withDist || withCoord) {
-			c.WriteError("ERR STORE option in GEORADIUS is not compatible with WITHDIST, WITHHASH and WITHCOORDS options")
-			return
-		}
-
 		db := m.db(ctx.selectedDB)
-		if !db.exists(key) {
-			c.WriteNull()
-			return
-		}
 
-		if db.t(key) != "zset" {
-			c.WriteError(ErrWrongType.Error())
+		matches, err := db.geoSearch(key, opts)
+		if err != nil {
+			c.WriteError(err.Error())
 			return
 		}
 
-		// get position of member
-		if !db.ssetExists(key, member) {
-			c.WriteError("ERR could not decode requested zset member")
-			return
-		}
-		score := db.ssetScore(key, member)
-		longitude, latitude := fromGeohash(uint64(score))
+		writeGeoMatches(c, matches, opts)
+	})
+}
 
-		members := db.ssetElements(key)
-		matches := withinRadius(members, longitude, latitude, radius*toMeter)
+// GEOSEARCHSTORE
+func (m *Miniredis) cmdGeosearchstore(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
 
-		// deal with ASC/DESC
-		if direction != unsorted {
-			sort.Slice(matches, func(i, j int) bool {
-				if direction == desc {
-					return matches[i].Distance > matches[j].Distance
-				}
-				return matches[i].Distance < matches[j].Distance
-			})
-		}
+	dst, key := args[0], args[1]
+	opts, msg := parseGeosearchArgs(cmd, args[2:], true)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
 
-		// deal with COUNT
-		if count > 0 && len(matches) > count {
-			matches = matches[:count]
-		}
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
 
-		// deal with "STORE x"
-		if withStore {
-			db.del(storeKey, true)
-			for _, member := range matches {
-				db.ssetAdd(storeKey, member.Score, member.Name)
-			}
-			c.WriteInt(len(matches))
+		matches, err := db.geoSearch(key, opts)
+		if err != nil {
+			c.WriteError(err.Error())
 			return
 		}
 
-		// deal with "STOREDIST x"
-		if withStoredist {
-			db.del(storedistKey, true)
-			for _, member := range matches {
-				db.ssetAdd(storedistKey, member.Distance/toMeter, member.Name)
-			}
-			c.WriteInt(len(matches))
-			return
+		db.geoStore(dst, matches, opts, "geosearchstore")
+		c.WriteInt(len(matches))
+	})
+}
+
+// writeGeoMatches writes the GEOSEARCH and GEORADIUS reply.
+func writeGeoMatches(c *server.Peer, matches []geoDistance, opts geoSearchOpts) {
+	c.WriteLen(len(matches))
+	for _, member := range matches {
+		if !opts.withDist && !opts.withHash && !opts.withCoord {
+			c.WriteBulk(member.Name)
+			continue
 		}
 
-		c.WriteLen(len(matches))
-		for _, member := range matches {
-			if !withDist && !withCoord {
-				c.WriteBulk(member.Name)
-				continue
-			}
+		len := 1
+		if opts.withDist {
+			len++
+		}
+		if opts.withHash {
+			len++
+		}
+		if opts.withCoord {
+			len++
+		}
+		c.WriteLen(len)
+		c.WriteBulk(member.Name)
+		if opts.withDist {
+			c.WriteBulk(fmt.Sprintf("%.4f", member.Distance/opts.toMeter))
+		}
+		if opts.withHash {
+			c.WriteInt64(int64(member.Score))
+		}
+		if opts.withCoord {
+			c.WriteLen(2)
+			c.WriteBulk(fmt.Sprintf("%f", member.Longitude))
+			c.WriteBulk(fmt.Sprintf("%f", member.Latitude))
+		}
+	}
+}
 
-			len := 1
-			if withDist {
-				len++
-			}
-			if withCoord {
-				len++
-			}
-			c.WriteLen(len)
-			c.WriteBulk(member.Name)
-			if withDist {
-				c.WriteBulk(fmt.Sprintf("%.4f", member.Distance/toMeter))
-			}
-			if withCoord {
-				c.WriteLen(2)
-				c.WriteBulk(fmt.Sprintf("%f", member.Longitude))
-				c.WriteBulk(fmt.Sprintf("%f", member.Latitude))
-			}
+// geoStore replaces key with the matches, either with their geohash or their
+// distance as score. event is the keyspace event to send. No locks!
+func (db *RedisDB) geoStore(key string, matches []geoDistance, opts geoSearchOpts, event string) {
+	existed := db.exists(key)
+	db.del(key, true)
+	if len(matches) == 0 {
+		if existed {
+			db.master.notify(db.id, notifyGeneric, "del", key)
 		}
-	})
+		return
+	}
+	sset := newSortedSet()
+	for _, member := range matches {
+		if opts.storeDist {
+			sset.set(member.Distance/opts.toMeter, member.Name)
+		} else {
+			sset.set(member.Score, member.Name)
+		}
+	}
+	db.ssetSet(key, sset)
+	db.master.notify(db.id, notifyZset, event, key)
 }
 
 func withinRadius(members []ssElem, longitude, latitude, radius float64) []geoDistance {
@@ -585,6 +790,27 @@ func withinRadius(members []ssElem, longitude, latitude, radius float64) []geoDi
 	return matches
 }
 
+// withinBox gives the members inside a width x height box (in meters)
+// centered on longitude, latitude.
+func withinBox(members []ssElem, longitude, latitude, width, height float64) []geoDistance {
+	matches := []geoDistance{}
+	for _, el := range members {
+		elLo, elLat := fromGeohash(uint64(el.score))
+		if latDistance(latitude, elLat) > height/2 ||
+			distance(elLat, longitude, elLat, elLo) > width/2 {
+			continue
+		}
+		matches = append(matches, geoDistance{
+			Name:      el.member,
+			Score:     el.score,
+			Distance:  distance(latitude, longitude, elLat, elLo),
+			Longitude: elLo,
+			Latitude:  elLat,
+		})
+	}
+	return matches
+}
+
 func parseUnit(u string) float64 {
 	switch u {
 	case "m":
@@ -19,6 +19,16 @@ func TestGeoadd(t *testing.T) {
 		must1(t, c, "GEOADD", "Sicily", "15.087269", "37.502669", "Catania")
 	})
 
+	t.Run("options", func(t *testing.T) {
+		must0(t, c, "GEOADD", "Sicily", "NX", "13.4", "38.2", "Palermo")
+		must1(t, c, "GEOADD", "Sicily", "NX", "CH", "13.4", "38.2", "Agrigento")
+		must0(t, c, "GEOADD", "Sicily", "XX", "13.4", "38.2", "Trapani")
+		mustNil(t, c, "ZSCORE", "Sicily", "Trapani")
+		must0(t, c, "GEOADD", "Sicily", "XX", "13.4", "38.2", "Palermo")
+		must1(t, c, "GEOADD", "Sicily", "XX", "CH", "13.5", "38.2", "Palermo")
+		must0(t, c, "GEOADD", "Sicily", "CH", "13.5", "38.2", "Palermo")
+	})
+
 	t.Run("failure cases", func(t *testing.T) {
 		mustDo(t, c,
 			"GEOADD", "broken", "-190.0", "10.0", "hi",
@@ -45,6 +55,22 @@ func TestGeoadd(t *testing.T) {
 			"GEOADD", "broken", "10.0", "notafloat", "hi",
 			proto.Error("ERR value is not a valid float"),
 		)
+		mustDo(t, c,
+			"GEOADD", "broken", "10.0", "10.0",
+			proto.Error(errWrongNumber("geoadd")),
+		)
+		mustDo(t, c,
+			"GEOADD", "broken", "NX", "10.0", "10.0",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"GEOADD", "broken", "10.0", "10.0", "hi", "20.0",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"GEOADD", "broken", "NX", "XX", "10.0", "10.0", "hi",
+			proto.Error(msgXXandNX),
+		)
 	})
 }
 
@@ -87,6 +113,51 @@ func TestGeopos(t *testing.T) {
 	})
 }
 
+func TestGeohash(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	mustDo(t, c,
+		"GEOADD", "Sicily",
+		"13.361389", "38.115556", "Palermo",
+		"15.087269", "37.502669", "Catania",
+		proto.Int(2),
+	)
+
+	mustDo(t, c,
+		"GEOHASH", "Sicily", "Palermo", "nosuch", "Catania",
+		proto.Array(
+			proto.String("sqc8b49rny0"),
+			proto.Nil,
+			proto.String("sqdtr74hyu0"),
+		),
+	)
+	mustDo(t, c,
+		"GEOHASH", "nosuch", "Palermo",
+		proto.Array(proto.Nil),
+	)
+	mustDo(t, c,
+		"GEOHASH", "Sicily",
+		proto.Array(),
+	)
+
+	t.Run("failure cases", func(t *testing.T) {
+		mustDo(t, c,
+			"GEOHASH",
+			proto.Error(errWrongNumber("geohash")),
+		)
+		s.Set("foo", "bar")
+		mustDo(t, c,
+			"GEOHASH", "foo", "bar",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
 // Test GEOADD / GEORADIUS / GEORADIUS_RO
 func TestGeo(t *testing.T) {
 	s, err := Run()
@@ -117,6 +188,73 @@ func TestGeo(t *testing.T) {
 		)
 	})
 
+	t.Run("WITHHASH", func(t *testing.T) {
+		mustDo(t, c,
+			"GEORADIUS", "Sicily", "15", "37", "200", "km", "WITHHASH", "WITHDIST", "ASC",
+			proto.Array(
+				proto.Array(
+					proto.String("Catania"),
+					proto.String("56.4413"),
+					proto.Int64(3479447370796909),
+				),
+				proto.Array(
+					proto.String("Palermo"),
+					proto.String("190.4424"),
+					proto.Int64(3479099956230698),
+				),
+			),
+		)
+	})
+
+	t.Run("COUNT", func(t *testing.T) {
+		// COUNT sorts by distance
+		mustDo(t, c,
+			"GEORADIUS", "Sicily", "15", "37", "200", "km", "COUNT", "1",
+			proto.Strings("Catania"),
+		)
+		// ...unless it's COUNT ANY
+		mustDo(t, c,
+			"GEORADIUS", "Sicily", "15", "37", "200", "km", "COUNT", "1", "ANY",
+			proto.Strings("Palermo"),
+		)
+		mustDo(t, c,
+			"GEORADIUSBYMEMBER", "Sicily", "Palermo", "200", "km", "COUNT", "1", "DESC",
+			proto.Strings("Catania"),
+		)
+		mustDo(t, c,
+			"GEORADIUS", "Sicily", "15", "37", "200", "km", "ANY",
+			proto.Error(msgAnyWithoutCount),
+		)
+	})
+
+	t.Run("STORE and STOREDIST", func(t *testing.T) {
+		// the last one wins
+		mustDo(t, c,
+			"GEORADIUS", "Sicily", "15", "37", "200", "km", "STORE", "foo", "STOREDIST", "foo",
+			proto.Int(2),
+		)
+		mustDo(t, c,
+			"ZRANGE", "foo", "0", "-1", "WITHSCORES",
+			proto.Strings("Catania", "56.441265994690177", "Palermo", "190.44243513691032"),
+		)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		s.Set("str", "value")
+		mustDo(t, c,
+			"GEORADIUS", "str", "15", "37", "200", "km",
+			proto.Error(msgWrongType),
+		)
+		mustDo(t, c,
+			"GEORADIUSBYMEMBER", "str", "Palermo", "200", "km",
+			proto.Error(msgWrongType),
+		)
+		mustDo(t, c,
+			"GEORADIUS", "Sicily", "200", "37", "200", "km",
+			proto.Error("ERR invalid longitude,latitude pair 200.000000,37.000000"),
+		)
+	})
+
 	t.Run("WITHCOORD", func(t *testing.T) {
 		mustDo(t, c,
 			"GEORADIUS", "Sicily", "15", "37", "200", "km", "WITHCOORD",
@@ -444,3 +582,186 @@ func TestGeobymember(t *testing.T) {
 		)
 	})
 }
+
+func TestGeosearch(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	mustDo(t, c,
+		"GEOADD", "Sicily",
+		"13.361389", "38.115556", "Palermo",
+		"15.087269", "37.502669", "Catania",
+		"12.758489", "38.788135", "edge1",
+		"17.241510", "38.788135", "edge2",
+		proto.Int(4),
+	)
+
+	t.Run("BYRADIUS", func(t *testing.T) {
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC",
+			proto.Strings("Catania", "Palermo"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "200", "km", "DESC", "WITHDIST",
+			proto.Array(
+				proto.Strings("Catania", "166.2742"),
+				proto.Strings("edge1", "91.4007"),
+				proto.Strings("Palermo", "0.0000"),
+			),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "nosuch", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km",
+			proto.Array(),
+		)
+	})
+
+	t.Run("BYBOX", func(t *testing.T) {
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "ASC", "WITHCOORD", "WITHDIST",
+			proto.Array(
+				proto.Array(
+					proto.String("Catania"),
+					proto.String("56.4413"),
+					proto.Strings("15.087267", "37.502668"),
+				),
+				proto.Array(
+					proto.String("Palermo"),
+					proto.String("190.4424"),
+					proto.Strings("13.361389", "38.115556"),
+				),
+				proto.Array(
+					proto.String("edge2"),
+					proto.String("279.7403"),
+					proto.Strings("17.241510", "38.788135"),
+				),
+				proto.Array(
+					proto.String("edge1"),
+					proto.String("279.7405"),
+					proto.Strings("12.758488", "38.788135"),
+				),
+			),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "200", "200", "km",
+			proto.Strings("Catania"),
+		)
+	})
+
+	t.Run("COUNT", func(t *testing.T) {
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "400", "km", "COUNT", "2",
+			proto.Strings("Catania", "Palermo"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "400", "km", "COUNT", "1", "WITHHASH",
+			proto.Array(
+				proto.Array(
+					proto.String("Catania"),
+					proto.Int64(3479447370796909),
+				),
+			),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "400", "km", "COUNT", "1", "ANY",
+			proto.Strings("Palermo"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "400", "km", "ANY", "COUNT", "1",
+			proto.Strings("Palermo"),
+		)
+	})
+
+	t.Run("GEOSEARCHSTORE", func(t *testing.T) {
+		must1(t, c,
+			"GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "200", "200", "km",
+		)
+		mustDo(t, c,
+			"ZRANGE", "dst", "0", "-1", "WITHSCORES",
+			proto.Strings("Catania", "3479447370796909"),
+		)
+		mustDo(t, c,
+			"GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC", "STOREDIST",
+			proto.Int(2),
+		)
+		mustDo(t, c,
+			"ZRANGE", "dst", "0", "-1",
+			proto.Strings("Catania", "Palermo"),
+		)
+		must0(t, c,
+			"GEOSEARCHSTORE", "dst", "nosuch", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km",
+		)
+		equals(t, false, s.Exists("dst"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"GEOSEARCH",
+			proto.Error(errWrongNumber("geosearch")),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "BYRADIUS", "200", "km",
+			proto.Error("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "400", "km", "ANY",
+			proto.Error(msgAnyWithoutCount),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km",
+			proto.Error("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo",
+			proto.Error("ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "200", "km", "BYBOX", "1", "1", "km",
+			proto.Error("ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMMEMBER", "nosuch", "BYRADIUS", "200", "km",
+			proto.Error("ERR could not decode requested zset member"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "200", "37", "BYRADIUS", "200", "km",
+			proto.Error("ERR invalid longitude,latitude pair 200.000000,37.000000"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "-1", "km",
+			proto.Error("ERR radius cannot be negative"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "foo", "km",
+			proto.Error("ERR need numeric radius"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "1", "-1", "km",
+			proto.Error("ERR height or width cannot be negative"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "mm",
+			proto.Error(msgUnsupportedUnit),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "COUNT", "0",
+			proto.Error("ERR COUNT must be > 0"),
+		)
+		mustDo(t, c,
+			"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "STOREDIST",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "WITHDIST",
+			proto.Error(msgSyntaxError),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"GEOSEARCH", "str", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km",
+			proto.Error(msgWrongType),
+		)
+	})
+}
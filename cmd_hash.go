@@ -3,9 +3,12 @@
 package miniredis
 
 import (
+	"fmt"
+	"math"
 	"math/big"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alicebob/miniredis/v2/server"
 )
@@ -14,17 +17,28 @@ import (
 func commandsHash(m *Miniredis) {
 	m.srv.Register("HDEL", m.cmdHdel)
 	m.srv.Register("HEXISTS", m.cmdHexists)
+	m.srv.Register("HEXPIRE", makeCmdHexpire(m, false, time.Second))
+	m.srv.Register("HEXPIREAT", makeCmdHexpire(m, true, time.Second))
+	m.srv.Register("HEXPIRETIME", makeCmdHttl(m, true, time.Second))
 	m.srv.Register("HGET", m.cmdHget)
 	m.srv.Register("HGETALL", m.cmdHgetall)
+	m.srv.Register("HGETDEL", m.cmdHgetdel)
+	m.srv.Register("HGETEX", m.cmdHgetex)
 	m.srv.Register("HINCRBY", m.cmdHincrby)
 	m.srv.Register("HINCRBYFLOAT", m.cmdHincrbyfloat)
 	m.srv.Register("HKEYS", m.cmdHkeys)
 	m.srv.Register("HLEN", m.cmdHlen)
 	m.srv.Register("HMGET", m.cmdHmget)
 	m.srv.Register("HMSET", m.cmdHmset)
+	m.srv.Register("HPERSIST", m.cmdHpersist)
+	m.srv.Register("HPEXPIRE", makeCmdHexpire(m, false, time.Millisecond))
+	m.srv.Register("HPEXPIREAT", makeCmdHexpire(m, true, time.Millisecond))
+	m.srv.Register("HPEXPIRETIME", makeCmdHttl(m, true, time.Millisecond))
+	m.srv.Register("HPTTL", makeCmdHttl(m, false, time.Millisecond))
 	m.srv.Register("HSET", m.cmdHset)
 	m.srv.Register("HSETNX", m.cmdHsetnx)
 	m.srv.Register("HSTRLEN", m.cmdHstrlen)
+	m.srv.Register("HTTL", makeCmdHttl(m, false, time.Second))
 	m.srv.Register("HVALS", m.cmdHvals)
 	m.srv.Register("HSCAN", m.cmdHscan)
 }
@@ -59,6 +73,7 @@ func (m *Miniredis) cmdHset(c *server.Peer, cmd string, args []string) {
 		}
 
 		new := db.hashSet(key, pairs...)
+		m.notify(db.id, notifyHash, "hset", key)
 		c.WriteInt(new)
 	})
 }
@@ -97,7 +112,8 @@ func (m *Miniredis) cmdHsetnx(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		db.hashKeys[key][field] = value
-		db.keyVersion[key]++
+		db.markDirty(key)
+		m.notify(db.id, notifyHash, "hset", key)
 		c.WriteInt(1)
 	})
 }
@@ -136,6 +152,7 @@ func (m *Miniredis) cmdHmset(c *server.Peer, cmd string, args []string) {
 			args = args[2:]
 			db.hashSet(key, field, value)
 		}
+		m.notify(db.id, notifyHash, "hset", key)
 		c.WriteOK()
 	})
 }
@@ -207,21 +224,12 @@ func (m *Miniredis) cmdHdel(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
-		deleted := 0
-		for _, f := range fields {
-			_, ok := db.hashKeys[key][f]
-			if !ok {
-				continue
-			}
-			delete(db.hashKeys[key], f)
-			deleted++
-		}
-		c.WriteInt(deleted)
-
-		// Nothing left. Remove the whole key.
-		if len(db.hashKeys[key]) == 0 {
-			db.del(key, true)
+		n := db.hashDel(key, fields...)
+		if n > 0 {
+			m.notify(db.id, notifyHash, "hdel", key)
+			m.notifyDeleted(db, key)
 		}
+		c.WriteInt(n)
 	})
 }
 
@@ -516,6 +524,7 @@ func (m *Miniredis) cmdHincrby(c *server.Peer, cmd string, args []string) {
 			c.WriteError(err.Error())
 			return
 		}
+		m.notify(db.id, notifyHash, "hincrby", key)
 		c.WriteInt(v)
 	})
 }
@@ -556,6 +565,7 @@ func (m *Miniredis) cmdHincrbyfloat(c *server.Peer, cmd string, args []string) {
 			c.WriteError(err.Error())
 			return
 		}
+		m.notify(db.id, notifyHash, "hincrbyfloat", key)
 		c.WriteBulk(formatBig(v))
 	})
 }
@@ -636,7 +646,7 @@ func (m *Miniredis) cmdHscan(c *server.Peer, cmd string, args []string) {
 
 		members := db.hashFields(key)
 		if withMatch {
-			members, _ = matchKeys(members, match)
+			members = matchKeys(members, match)
 		}
 
 		c.WriteLen(2)
@@ -649,3 +659,414 @@ func (m *Miniredis) cmdHscan(c *server.Peer, cmd string, args []string) {
 		}
 	})
 }
+
+// The highest expire time a hash field can have, in milliseconds.
+const hashMaxExpire = 1<<46 - 1
+
+// parseHashFields parses the "FIELDS numfields field [field ...]" part of the
+// hash field TTL commands.
+func parseHashFields(args []string) ([]string, string) {
+	if len(args) < 2 || strings.ToUpper(args[0]) != "FIELDS" {
+		return nil, msgFieldsMissing
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 {
+		return nil, msgNumFields
+	}
+	if n != len(args)-2 {
+		return nil, msgNumFieldsMismatch
+	}
+	return args[2:], ""
+}
+
+// hashFieldTTL converts an expire time in d units to a TTL. If unix is set
+// it's a unix timestamp.
+func hashFieldTTL(i int64, d time.Duration, unix bool, now time.Time) time.Duration {
+	// a time.Duration can't go all the way to hashMaxExpire
+	ttl := time.Duration(math.MaxInt64)
+	if i <= math.MaxInt64/int64(d) {
+		ttl = time.Duration(i) * d
+	}
+	if unix {
+		ttl = time.Unix(0, 0).Add(ttl).Sub(now)
+	}
+	return ttl
+}
+
+// HEXPIRE, HEXPIREAT, HPEXPIRE, and HPEXPIREAT
+// d is the time unit. If unix is set it'll be seen as a unixtimestamp and
+// converted to a duration.
+func makeCmdHexpire(m *Miniredis, unix bool, d time.Duration) func(*server.Peer, string, []string) {
+	return func(c *server.Peer, cmd string, args []string) {
+		if len(args) < 5 {
+			setDirty(c)
+			c.WriteError(errWrongNumber(cmd))
+			return
+		}
+		if !m.handleAuth(c) {
+			return
+		}
+		if m.checkPubsub(c, cmd) {
+			return
+		}
+
+		key := args[0]
+		i, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			setDirty(c)
+			c.WriteError(msgInvalidInt)
+			return
+		}
+		if i < 0 || i > hashMaxExpire/int64(d/time.Millisecond) {
+			setDirty(c)
+			c.WriteError(fmt.Sprintf(msgFInvalidExpireTime, strings.ToLower(cmd)))
+			return
+		}
+		args = args[2:]
+
+		var opts struct {
+			nx, xx, gt, lt bool
+		}
+		switch strings.ToUpper(args[0]) {
+		case "NX":
+			opts.nx = true
+		case "XX":
+			opts.xx = true
+		case "GT":
+			opts.gt = true
+		case "LT":
+			opts.lt = true
+		}
+		if opts.nx || opts.xx || opts.gt || opts.lt {
+			args = args[1:]
+		}
+		fields, msg := parseHashFields(args)
+		if msg != "" {
+			setDirty(c)
+			c.WriteError(msg)
+			return
+		}
+
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			db := m.db(ctx.selectedDB)
+
+			t, ok := db.keys[key]
+			if ok && t != "hash" {
+				c.WriteError(msgWrongType)
+				return
+			}
+
+			ttl := hashFieldTTL(i, d, unix, m.effectiveNow())
+
+			c.WriteLen(len(fields))
+			var updated, deleted bool
+			for _, f := range fields {
+				if _, ok := db.hashKeys[key][f]; !ok {
+					c.WriteInt(-2)
+					continue
+				}
+
+				// A field without a TTL counts as an infinite TTL for GT and LT.
+				current, hasTTL := db.hashFieldTTL(key, f)
+				if (opts.nx && hasTTL) ||
+					(opts.xx && !hasTTL) ||
+					(opts.gt && (!hasTTL || ttl <= current)) ||
+					(opts.lt && hasTTL && ttl >= current) {
+					c.WriteInt(0)
+					continue
+				}
+
+				if ttl <= 0 {
+					db.hashDel(key, f)
+					deleted = true
+					c.WriteInt(2)
+					continue
+				}
+				db.setHashFieldTTL(key, f, ttl)
+				updated = true
+				c.WriteInt(1)
+			}
+			if updated {
+				m.notify(db.id, notifyHash, "hexpire", key)
+			}
+			if deleted {
+				m.notify(db.id, notifyHash, "hdel", key)
+				m.notifyDeleted(db, key)
+			}
+		})
+	}
+}
+
+// HTTL, HPTTL, HEXPIRETIME, and HPEXPIRETIME
+// d is the time unit. If unix is set the reply is a unix timestamp.
+func makeCmdHttl(m *Miniredis, unix bool, d time.Duration) func(*server.Peer, string, []string) {
+	return func(c *server.Peer, cmd string, args []string) {
+		if len(args) < 4 {
+			setDirty(c)
+			c.WriteError(errWrongNumber(cmd))
+			return
+		}
+		if !m.handleAuth(c) {
+			return
+		}
+		if m.checkPubsub(c, cmd) {
+			return
+		}
+
+		key := args[0]
+		fields, msg := parseHashFields(args[1:])
+		if msg != "" {
+			setDirty(c)
+			c.WriteError(msg)
+			return
+		}
+
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			db := m.db(ctx.selectedDB)
+
+			t, ok := db.keys[key]
+			if ok && t != "hash" {
+				c.WriteError(msgWrongType)
+				return
+			}
+
+			c.WriteLen(len(fields))
+			for _, f := range fields {
+				if _, ok := db.hashKeys[key][f]; !ok {
+					c.WriteInt(-2)
+					continue
+				}
+				ttl, ok := db.hashFieldTTL(key, f)
+				if !ok {
+					c.WriteInt(-1)
+					continue
+				}
+				if unix {
+					ttl = m.effectiveNow().Add(ttl).Sub(time.Unix(0, 0))
+				}
+				// round up, same as Redis
+				c.WriteInt(int((ttl + d - 1) / d))
+			}
+		})
+	}
+}
+
+// HPERSIST
+func (m *Miniredis) cmdHpersist(c *server.Peer, cmd string, args []string) {
+	if len(args) < 4 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	fields, msg := parseHashFields(args[1:])
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		t, ok := db.keys[key]
+		if ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteLen(len(fields))
+		persisted := false
+		for _, f := range fields {
+			if _, ok := db.hashKeys[key][f]; !ok {
+				c.WriteInt(-2)
+				continue
+			}
+			if !db.hashPersist(key, f) {
+				c.WriteInt(-1)
+				continue
+			}
+			persisted = true
+			c.WriteInt(1)
+		}
+		if persisted {
+			m.notify(db.id, notifyHash, "hpersist", key)
+		}
+	})
+}
+
+// HGETDEL
+func (m *Miniredis) cmdHgetdel(c *server.Peer, cmd string, args []string) {
+	if len(args) < 4 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	fields, msg := parseHashFields(args[1:])
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		t, ok := db.keys[key]
+		if ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteLen(len(fields))
+		deleted := false
+		for _, f := range fields {
+			v, ok := db.hashKeys[key][f]
+			if !ok {
+				c.WriteNull()
+				continue
+			}
+			c.WriteBulk(v)
+			db.hashDel(key, f)
+			deleted = true
+		}
+		if deleted {
+			m.notify(db.id, notifyHash, "hdel", key)
+			m.notifyDeleted(db, key)
+		}
+	})
+}
+
+// HGETEX
+func (m *Miniredis) cmdHgetex(c *server.Peer, cmd string, args []string) {
+	if len(args) < 4 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, args := args[0], args[1:]
+	var opts struct {
+		setTTL  bool
+		persist bool
+		i       int64
+		d       time.Duration
+		unix    bool
+	}
+	for len(args) > 0 && strings.ToUpper(args[0]) != "FIELDS" {
+		if opts.setTTL || opts.persist {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		switch arg := strings.ToUpper(args[0]); arg {
+		case "PERSIST":
+			opts.persist = true
+			args = args[1:]
+			continue
+		case "EX", "EXAT":
+			opts.d = time.Second
+			opts.unix = arg == "EXAT"
+		case "PX", "PXAT":
+			opts.d = time.Millisecond
+			opts.unix = arg == "PXAT"
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		if len(args) < 2 {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		i, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			setDirty(c)
+			c.WriteError(msgInvalidInt)
+			return
+		}
+		if i < 0 || (i == 0 && !opts.unix) || i > hashMaxExpire/int64(opts.d/time.Millisecond) {
+			setDirty(c)
+			c.WriteError(fmt.Sprintf(msgFInvalidExpireTime, strings.ToLower(cmd)))
+			return
+		}
+		opts.setTTL = true
+		opts.i = i
+		args = args[2:]
+	}
+	fields, msg := parseHashFields(args)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		t, ok := db.keys[key]
+		if ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		var ttl time.Duration
+		if opts.setTTL {
+			ttl = hashFieldTTL(opts.i, opts.d, opts.unix, m.effectiveNow())
+		}
+		c.WriteLen(len(fields))
+		var updated, persisted, deleted bool
+		for _, f := range fields {
+			v, ok := db.hashKeys[key][f]
+			if !ok {
+				c.WriteNull()
+				continue
+			}
+			c.WriteBulk(v)
+			switch {
+			case opts.persist:
+				if db.hashPersist(key, f) {
+					persisted = true
+				}
+			case opts.setTTL && ttl <= 0:
+				db.hashDel(key, f)
+				deleted = true
+			case opts.setTTL:
+				db.setHashFieldTTL(key, f, ttl)
+				updated = true
+			}
+		}
+		if updated {
+			m.notify(db.id, notifyHash, "hexpire", key)
+		}
+		if persisted {
+			m.notify(db.id, notifyHash, "hpersist", key)
+		}
+		if deleted {
+			m.notify(db.id, notifyHash, "hdel", key)
+			m.notifyDeleted(db, key)
+		}
+	})
+}
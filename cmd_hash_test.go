@@ -1,6 +1,7 @@
 package miniredis
 
 import (
+	"strconv"
 	"testing"
 	"time"
 
@@ -457,7 +458,41 @@ func TestHashIncrbyfloat(t *testing.T) {
 		s.HSet("hash", "field", "noint")
 		mustDo(t, c,
 			"HINCRBYFLOAT", "hash", "field", "400",
-			proto.Error("ERR value is not a valid float"),
+			proto.Error(msgHashFloat),
+		)
+	}
+
+	// Infinity
+	{
+		mustDo(t, c,
+			"HINCRBYFLOAT", "hash", "inf", "+inf",
+			proto.Error(msgIncrFloatInf),
+		)
+		s.HSet("hash", "inf", "-inf")
+		mustDo(t, c,
+			"HINCRBYFLOAT", "hash", "inf", "1",
+			proto.Error(msgIncrFloatInf),
+		)
+		equals(t, "-inf", s.HGet("hash", "inf"))
+	}
+
+	// Formatting
+	{
+		mustDo(t, c,
+			"HINCRBYFLOAT", "hash", "f", "12.3",
+			proto.String("12.3"),
+		)
+		mustDo(t, c,
+			"HINCRBYFLOAT", "hash", "f", "-13.1",
+			proto.String("-0.8"),
+		)
+		mustDo(t, c,
+			"HINCRBYFLOAT", "hash", "f", "12e12",
+			proto.String("11999999999999.2"),
+		)
+		mustDo(t, c,
+			"HINCRBYFLOAT", "hash", "f", "-11999999999999.2",
+			proto.String("0"),
 		)
 	}
 
@@ -661,3 +696,368 @@ func TestHstrlen(t *testing.T) {
 		)
 	})
 }
+
+func TestHashExpire(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.HSet("session", "user", "alice", "token", "abc", "theme", "dark")
+	mustDo(t, c,
+		"HEXPIRE", "session", "10", "FIELDS", "2", "token", "nosuch",
+		proto.Array(proto.Int(1), proto.Int(-2)),
+	)
+	equals(t, 10*time.Second, s.HFieldTTL("session", "token"))
+	mustDo(t, c,
+		"HTTL", "session", "FIELDS", "3", "token", "user", "nosuch",
+		proto.Array(proto.Int(10), proto.Int(-1), proto.Int(-2)),
+	)
+	mustDo(t, c,
+		"HPTTL", "session", "FIELDS", "1", "token",
+		proto.Array(proto.Int(10000)),
+	)
+	mustDo(t, c,
+		"HTTL", "nosuch", "FIELDS", "2", "a", "b",
+		proto.Array(proto.Int(-2), proto.Int(-2)),
+	)
+	mustDo(t, c,
+		"OBJECT", "ENCODING", "session",
+		proto.String("listpackex"),
+	)
+
+	s.FastForward(5 * time.Second)
+	mustDo(t, c,
+		"HTTL", "session", "FIELDS", "1", "token",
+		proto.Array(proto.Int(5)),
+	)
+	s.FastForward(5 * time.Second)
+	mustDo(t, c,
+		"HGETALL", "session",
+		proto.Strings("theme", "dark", "user", "alice"),
+	)
+	mustDo(t, c,
+		"HTTL", "session", "FIELDS", "1", "token",
+		proto.Array(proto.Int(-2)),
+	)
+
+	t.Run("options", func(t *testing.T) {
+		mustDo(t, c,
+			"HPEXPIRE", "session", "2000", "NX", "FIELDS", "1", "user",
+			proto.Array(proto.Int(1)),
+		)
+		mustDo(t, c,
+			"HPEXPIRE", "session", "3000", "NX", "FIELDS", "2", "user", "theme",
+			proto.Array(proto.Int(0), proto.Int(1)),
+		)
+		mustDo(t, c,
+			"HPEXPIRE", "session", "4000", "XX", "FIELDS", "1", "user",
+			proto.Array(proto.Int(1)),
+		)
+		mustDo(t, c,
+			"HPEXPIRE", "session", "1000", "GT", "FIELDS", "1", "user",
+			proto.Array(proto.Int(0)),
+		)
+		mustDo(t, c,
+			"HPEXPIRE", "session", "1000", "LT", "FIELDS", "1", "user",
+			proto.Array(proto.Int(1)),
+		)
+		mustDo(t, c,
+			"HPTTL", "session", "FIELDS", "2", "user", "theme",
+			proto.Array(proto.Int(1000), proto.Int(3000)),
+		)
+		mustDo(t, c,
+			"HPERSIST", "session", "FIELDS", "3", "user", "user", "nosuch",
+			proto.Array(proto.Int(1), proto.Int(-1), proto.Int(-2)),
+		)
+		mustDo(t, c,
+			"HPERSIST", "session", "FIELDS", "1", "theme",
+			proto.Array(proto.Int(1)),
+		)
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "session",
+			proto.String("listpack"),
+		)
+	})
+
+	t.Run("at", func(t *testing.T) {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		s.SetTime(now)
+		defer s.SetTime(time.Time{})
+
+		s.HSet("at", "a", "1", "b", "2")
+		mustDo(t, c,
+			"HEXPIREAT", "at", strconv.Itoa(int(now.Unix())+60), "FIELDS", "1", "a",
+			proto.Array(proto.Int(1)),
+		)
+		equals(t, time.Minute, s.HFieldTTL("at", "a"))
+		mustDo(t, c,
+			"HEXPIRETIME", "at", "FIELDS", "2", "a", "b",
+			proto.Array(proto.Int(int(now.Unix())+60), proto.Int(-1)),
+		)
+		mustDo(t, c,
+			"HPEXPIRETIME", "at", "FIELDS", "1", "a",
+			proto.Array(proto.Int(int(now.Unix()+60)*1000)),
+		)
+		mustDo(t, c,
+			"HPEXPIREAT", "at", strconv.Itoa(int(now.Unix()-1)*1000), "FIELDS", "1", "b",
+			proto.Array(proto.Int(2)),
+		)
+		mustDo(t, c,
+			"HKEYS", "at",
+			proto.Strings("a"),
+		)
+		// last field gone, key gone
+		mustDo(t, c,
+			"HEXPIRE", "at", "0", "FIELDS", "1", "a",
+			proto.Array(proto.Int(2)),
+		)
+		must0(t, c, "EXISTS", "at")
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		s.HSet("ow", "a", "1", "b", "2")
+		s.HSetTTL("ow", "a", time.Minute)
+		s.HSetTTL("ow", "b", time.Minute)
+		s.HSetTTL("ow", "nosuch", time.Minute)
+		mustDo(t, c,
+			"HINCRBY", "ow", "b", "1",
+			proto.Int(3),
+		)
+		equals(t, time.Minute, s.HFieldTTL("ow", "b"))
+		must0(t, c, "HSET", "ow", "a", "3")
+		equals(t, time.Duration(0), s.HFieldTTL("ow", "a"))
+		equals(t, time.Duration(0), s.HFieldTTL("ow", "nosuch"))
+
+		mustOK(t, c, "RENAME", "ow", "ow2")
+		equals(t, time.Minute, s.HFieldTTL("ow2", "b"))
+		must1(t, c, "COPY", "ow2", "ow3")
+		equals(t, time.Minute, s.HFieldTTL("ow3", "b"))
+		must1(t, c, "HDEL", "ow3", "b")
+		mustOK(t, c, "HMSET", "ow3", "b", "4")
+		equals(t, time.Duration(0), s.HFieldTTL("ow3", "b"))
+
+		// whole key expires first
+		s.SetTTL("ow2", time.Second)
+		s.FastForward(time.Minute)
+		must0(t, c, "EXISTS", "ow2")
+	})
+
+	t.Run("direct", func(t *testing.T) {
+		s.HSetWithTTL("direct", "a", "1", time.Minute)
+		equals(t, "1", s.HGet("direct", "a"))
+		equals(t, time.Minute, s.HFieldTTL("direct", "a"))
+		mustDo(t, c,
+			"HTTL", "direct", "FIELDS", "1", "a",
+			proto.Array(proto.Int(60)),
+		)
+		equals(t, time.Duration(0), s.HFieldTTL("direct", "nosuch"))
+		equals(t, time.Duration(0), s.HFieldTTL("nosuch", "a"))
+
+		// a plain HSet clears the TTL
+		s.HSet("direct", "a", "2")
+		equals(t, time.Duration(0), s.HFieldTTL("direct", "a"))
+
+		s.DB(2).HSetWithTTL("direct", "b", "1", time.Second)
+		equals(t, time.Second, s.DB(2).HFieldTTL("direct", "b"))
+		s.FastForward(time.Second)
+		equals(t, false, s.DB(2).Exists("direct"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"HEXPIRE", "session", "10", "FIELDS", "1",
+			proto.Error(errWrongNumber("hexpire")),
+		)
+		mustDo(t, c,
+			"HTTL", "session", "FIELDS", "1",
+			proto.Error(errWrongNumber("httl")),
+		)
+		mustDo(t, c,
+			"HEXPIRE", "session", "foo", "FIELDS", "1", "user",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"HEXPIRE", "session", "-1", "FIELDS", "1", "user",
+			proto.Error("ERR invalid expire time in 'hexpire' command"),
+		)
+		mustDo(t, c,
+			"HEXPIRE", "session", "10", "FOO", "1", "user",
+			proto.Error(msgFieldsMissing),
+		)
+		mustDo(t, c,
+			"HEXPIRE", "session", "10", "NX", "XX", "FIELDS", "1", "user",
+			proto.Error(msgFieldsMissing),
+		)
+		mustDo(t, c,
+			"HEXPIRE", "session", "10", "FIELDS", "0", "user",
+			proto.Error(msgNumFields),
+		)
+		mustDo(t, c,
+			"HEXPIRE", "session", "10", "FIELDS", "2", "user",
+			proto.Error(msgNumFieldsMismatch),
+		)
+		mustDo(t, c,
+			"HPERSIST", "session", "FIELDS", "foo", "user",
+			proto.Error(msgNumFields),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"HEXPIRE", "str", "10", "FIELDS", "1", "user",
+			proto.Error(msgWrongType),
+		)
+		mustDo(t, c,
+			"HTTL", "str", "FIELDS", "1", "user",
+			proto.Error(msgWrongType),
+		)
+		mustDo(t, c,
+			"HPERSIST", "str", "FIELDS", "1", "user",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
+func TestHashGetdel(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.HSet("h", "a", "1", "b", "2", "c", "3")
+	s.HSetTTL("h", "a", time.Minute)
+	mustDo(t, c,
+		"HGETDEL", "h", "FIELDS", "3", "a", "nosuch", "b",
+		proto.Array(proto.String("1"), proto.Nil, proto.String("2")),
+	)
+	mustDo(t, c,
+		"HGETALL", "h",
+		proto.Strings("c", "3"),
+	)
+	equals(t, time.Duration(0), s.HFieldTTL("h", "a"))
+	mustDo(t, c,
+		"HGETDEL", "h", "FIELDS", "1", "c",
+		proto.Array(proto.String("3")),
+	)
+	must0(t, c, "EXISTS", "h")
+	mustDo(t, c,
+		"HGETDEL", "nosuch", "FIELDS", "1", "c",
+		proto.Array(proto.Nil),
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"HGETDEL", "h", "FIELDS", "1",
+			proto.Error(errWrongNumber("hgetdel")),
+		)
+		mustDo(t, c,
+			"HGETDEL", "h", "FOO", "1", "a",
+			proto.Error(msgFieldsMissing),
+		)
+		mustDo(t, c,
+			"HGETDEL", "h", "FIELDS", "2", "a",
+			proto.Error(msgNumFieldsMismatch),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"HGETDEL", "str", "FIELDS", "1", "a",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
+func TestHashGetex(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.HSet("h", "a", "1", "b", "2")
+	mustDo(t, c,
+		"HGETEX", "h", "FIELDS", "2", "a", "nosuch",
+		proto.Array(proto.String("1"), proto.Nil),
+	)
+	equals(t, time.Duration(0), s.HFieldTTL("h", "a"))
+
+	mustDo(t, c,
+		"HGETEX", "h", "EX", "10", "FIELDS", "2", "a", "nosuch",
+		proto.Array(proto.String("1"), proto.Nil),
+	)
+	equals(t, 10*time.Second, s.HFieldTTL("h", "a"))
+	mustDo(t, c,
+		"HGETEX", "h", "px", "2500", "FIELDS", "1", "b",
+		proto.Array(proto.String("2")),
+	)
+	equals(t, 2500*time.Millisecond, s.HFieldTTL("h", "b"))
+	mustDo(t, c,
+		"HGETEX", "h", "PERSIST", "FIELDS", "1", "b",
+		proto.Array(proto.String("2")),
+	)
+	equals(t, time.Duration(0), s.HFieldTTL("h", "b"))
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.SetTime(now)
+	mustDo(t, c,
+		"HGETEX", "h", "EXAT", strconv.Itoa(int(now.Unix())+60), "FIELDS", "1", "b",
+		proto.Array(proto.String("2")),
+	)
+	equals(t, time.Minute, s.HFieldTTL("h", "b"))
+	mustDo(t, c,
+		"HGETEX", "h", "PXAT", "1000", "FIELDS", "1", "b",
+		proto.Array(proto.String("2")),
+	)
+	mustDo(t, c,
+		"HKEYS", "h",
+		proto.Strings("a"),
+	)
+
+	mustDo(t, c,
+		"HGETEX", "nosuch", "EX", "10", "FIELDS", "1", "a",
+		proto.Array(proto.Nil),
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"HGETEX", "h", "FIELDS", "1",
+			proto.Error(errWrongNumber("hgetex")),
+		)
+		mustDo(t, c,
+			"HGETEX", "h", "EX", "10", "PX", "10", "FIELDS", "1", "a",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"HGETEX", "h", "PERSIST", "EX", "10", "FIELDS", "1", "a",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"HGETEX", "h", "FOO", "FIELDS", "1", "a",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"HGETEX", "h", "EX", "foo", "FIELDS", "1", "a",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"HGETEX", "h", "EX", "0", "FIELDS", "1", "a",
+			proto.Error("ERR invalid expire time in 'hgetex' command"),
+		)
+		mustDo(t, c,
+			"HGETEX", "h", "EX", "10", "FIELDS", "2", "a",
+			proto.Error(msgNumFieldsMismatch),
+		)
+		mustDo(t, c,
+			"HGETEX", "h", "EX", "10", "1", "a",
+			proto.Error(msgSyntaxError),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"HGETEX", "str", "FIELDS", "1", "a",
+			proto.Error(msgWrongType),
+		)
+	})
+}
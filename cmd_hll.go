@@ -0,0 +1,163 @@
+// Commands from https://redis.io/commands#hyperloglog
+
+package miniredis
+
+import (
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+// commandsHll handles all HyperLogLog operations.
+func commandsHll(m *Miniredis) {
+	m.srv.Register("PFADD", m.cmdPfadd)
+	m.srv.Register("PFCOUNT", m.cmdPfcount)
+	m.srv.Register("PFMERGE", m.cmdPfmerge)
+}
+
+// PFADD
+func (m *Miniredis) cmdPfadd(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, elems := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		h, err := db.hllGet(key)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		updated := false
+		if h == nil {
+			h = newHLL()
+			updated = true
+		}
+		for _, el := range elems {
+			if h.add(el) {
+				updated = true
+			}
+		}
+		if !updated {
+			c.WriteInt(0)
+			return
+		}
+		h.cardValid = false
+		db.hllSet(key, h)
+		m.notify(db.id, notifyString, "pfadd", key)
+		c.WriteInt(1)
+	})
+}
+
+// PFCOUNT
+func (m *Miniredis) cmdPfcount(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	keys := args
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if len(keys) == 1 {
+			h, err := db.hllGet(keys[0])
+			if err != nil {
+				c.WriteError(err.Error())
+				return
+			}
+			if h == nil {
+				c.WriteInt(0)
+				return
+			}
+			if h.cardValid {
+				c.WriteInt(int(h.count()))
+				return
+			}
+			// this updates the cached cardinality, same as Redis does.
+			n := h.count()
+			db.hllSet(keys[0], h)
+			c.WriteInt(int(n))
+			return
+		}
+
+		sum := newHLL()
+		for _, key := range keys {
+			h, err := db.hllGet(key)
+			if err != nil {
+				c.WriteError(err.Error())
+				return
+			}
+			if h != nil {
+				sum.merge(h)
+			}
+		}
+		c.WriteInt(int(sum.count()))
+	})
+}
+
+// PFMERGE
+func (m *Miniredis) cmdPfmerge(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	dest, keys := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		sum, err := db.hllGet(dest)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if sum == nil {
+			sum = newHLL()
+		}
+		for _, key := range keys {
+			h, err := db.hllGet(key)
+			if err != nil {
+				c.WriteError(err.Error())
+				return
+			}
+			if h == nil {
+				continue
+			}
+			sum.merge(h)
+			if h.dense {
+				sum.dense = true
+			}
+		}
+		sum.cardValid = false
+		db.hllSet(dest, sum)
+		// same event name as Redis uses
+		m.notify(db.id, notifyString, "pfadd", dest)
+		c.WriteOK()
+	})
+}
@@ -0,0 +1,148 @@
+package miniredis
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2/proto"
+)
+
+func TestPfadd(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	must1(t, c, "PFADD", "hll", "foo", "bar", "zap")
+	must0(t, c, "PFADD", "hll", "zap", "zap", "zap")
+	must0(t, c, "PFADD", "hll", "foo", "bar")
+	must1(t, c, "PFADD", "empty")
+	must0(t, c, "PFADD", "empty")
+
+	// it's a string
+	mustDo(t, c,
+		"TYPE", "hll",
+		proto.Inline("string"),
+	)
+	v, err := s.Get("empty")
+	ok(t, err)
+	equals(t, "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x7f\xff", v)
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"PFADD",
+			proto.Error(errWrongNumber("pfadd")),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"PFADD", "str", "foo",
+			proto.Error("WRONGTYPE Key is not a valid HyperLogLog string value."),
+		)
+		s.Push("list", "value")
+		mustDo(t, c,
+			"PFADD", "list", "foo",
+			proto.Error(msgWrongType),
+		)
+		s.Set("broken", "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xfe")
+		mustDo(t, c,
+			"PFADD", "broken", "foo",
+			proto.Error("INVALIDOBJ Corrupted HLL object detected"),
+		)
+	})
+}
+
+func TestPfcount(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	must1(t, c, "PFADD", "hll", "foo", "bar", "zap")
+	must1(t, c, "PFADD", "other", "1", "2", "3")
+	mustDo(t, c,
+		"PFCOUNT", "hll",
+		proto.Int(3),
+	)
+	mustDo(t, c,
+		"PFCOUNT", "hll", "other", "nosuch",
+		proto.Int(6),
+	)
+	must0(t, c, "PFCOUNT", "nosuch")
+
+	// PFCOUNT caches the count in the string
+	v, err := s.Get("hll")
+	ok(t, err)
+	equals(t, "\x03\x00\x00\x00\x00\x00\x00\x00", v[8:16])
+
+	// GET and SET round trip
+	mustOK(t, c, "SET", "copy", v)
+	mustDo(t, c,
+		"PFCOUNT", "copy",
+		proto.Int(3),
+	)
+	must0(t, c, "PFADD", "copy", "foo")
+	must1(t, c, "PFADD", "copy", "new")
+	mustDo(t, c,
+		"PFCOUNT", "copy",
+		proto.Int(4),
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"PFCOUNT",
+			proto.Error(errWrongNumber("pfcount")),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"PFCOUNT", "str",
+			proto.Error("WRONGTYPE Key is not a valid HyperLogLog string value."),
+		)
+		mustDo(t, c,
+			"PFCOUNT", "hll", "str",
+			proto.Error("WRONGTYPE Key is not a valid HyperLogLog string value."),
+		)
+	})
+}
+
+func TestPfmerge(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	must1(t, c, "PFADD", "h1", "foo", "bar", "zap", "a")
+	must1(t, c, "PFADD", "h2", "a", "b", "c", "foo")
+	mustOK(t, c, "PFMERGE", "h3", "h1", "h2", "nosuch")
+	mustDo(t, c,
+		"PFCOUNT", "h3",
+		proto.Int(6),
+	)
+	mustOK(t, c, "PFMERGE", "h1", "h2")
+	mustDo(t, c,
+		"PFCOUNT", "h1",
+		proto.Int(6),
+	)
+	mustOK(t, c, "PFMERGE", "empty")
+	must0(t, c, "PFCOUNT", "empty")
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"PFMERGE",
+			proto.Error(errWrongNumber("pfmerge")),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"PFMERGE", "h1", "str",
+			proto.Error("WRONGTYPE Key is not a valid HyperLogLog string value."),
+		)
+		mustDo(t, c,
+			"PFMERGE", "str", "h1",
+			proto.Error("WRONGTYPE Key is not a valid HyperLogLog string value."),
+		)
+	})
+}
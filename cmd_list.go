@@ -80,6 +80,7 @@ func (m *Miniredis) cmdBXpop(c *server.Peer, cmd string, args []string, lr leftr
 		m,
 		c,
 		time.Duration(timeout)*time.Second,
+		keys,
 		func(c *server.Peer, ctx *connCtx) bool {
 			db := m.db(ctx.selectedDB)
 			for _, key := range keys {
@@ -91,7 +92,7 @@ func (m *Miniredis) cmdBXpop(c *server.Peer, cmd string, args []string, lr leftr
 					return true
 				}
 
-				if len(db.listKeys[key]) == 0 {
+				if db.listKeys[key].len() == 0 {
 					continue
 				}
 				c.WriteLen(2)
@@ -100,9 +101,12 @@ func (m *Miniredis) cmdBXpop(c *server.Peer, cmd string, args []string, lr leftr
 				switch lr {
 				case left:
 					v = db.listLpop(key)
+					m.notify(db.id, notifyList, "lpop", key)
 				case right:
 					v = db.listPop(key)
+					m.notify(db.id, notifyList, "rpop", key)
 				}
+				m.notifyDeleted(db, key)
 				c.WriteBulk(v)
 				return true
 			}
@@ -152,7 +156,7 @@ func (m *Miniredis) cmdLindex(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
-		l := db.listKeys[key]
+		l := db.listKeys[key].elems()
 		if offset < 0 {
 			offset = len(l) + offset
 		}
@@ -207,23 +211,19 @@ func (m *Miniredis) cmdLinsert(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
-		l := db.listKeys[key]
+		l := db.listKeys[key].elems()
 		for i, el := range l {
 			if el != pivot {
 				continue
 			}
 
-			if where < 0 {
-				l = append(l[:i], append(listKey{value}, l[i:]...)...)
-			} else {
-				if i == len(l)-1 {
-					l = append(l, value)
-				} else {
-					l = append(l[:i+1], append(listKey{value}, l[i+1:]...)...)
-				}
+			if where > 0 {
+				i++
 			}
-			db.listKeys[key] = l
-			db.keyVersion[key]++
+			l = append(l[:i], append([]string{value}, l[i:]...)...)
+			db.listKeys[key].replace(l)
+			db.markDirty(key)
+			m.notify(db.id, notifyList, "linsert", key)
 			c.WriteInt(len(l))
 			return
 		}
@@ -261,7 +261,7 @@ func (m *Miniredis) cmdLlen(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
-		c.WriteInt(len(db.listKeys[key]))
+		c.WriteInt(db.listKeys[key].len())
 	})
 }
 
@@ -276,7 +276,7 @@ func (m *Miniredis) cmdRpop(c *server.Peer, cmd string, args []string) {
 }
 
 func (m *Miniredis) cmdXpop(c *server.Peer, cmd string, args []string, lr leftright) {
-	if len(args) != 1 {
+	if len(args) < 1 || len(args) > 2 {
 		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
@@ -289,12 +289,27 @@ func (m *Miniredis) cmdXpop(c *server.Peer, cmd string, args []string, lr leftri
 	}
 
 	key := args[0]
+	withCount := len(args) == 2
+	count := 1
+	if withCount {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			setDirty(c)
+			c.WriteError(msgPositive)
+			return
+		}
+		count = n
+	}
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		db := m.db(ctx.selectedDB)
 
 		if !db.exists(key) {
 			// non-existing key is fine
+			if withCount {
+				c.WriteLen(-1)
+				return
+			}
 			c.WriteNull()
 			return
 		}
@@ -303,14 +318,29 @@ func (m *Miniredis) cmdXpop(c *server.Peer, cmd string, args []string, lr leftri
 			return
 		}
 
-		var elem string
-		switch lr {
-		case left:
-			elem = db.listLpop(key)
-		case right:
-			elem = db.listPop(key)
+		var elems []string
+		for i := 0; i < count && db.exists(key); i++ {
+			switch lr {
+			case left:
+				elems = append(elems, db.listLpop(key))
+			case right:
+				elems = append(elems, db.listPop(key))
+			}
+		}
+		if lr == left {
+			m.notify(db.id, notifyList, "lpop", key)
+		} else {
+			m.notify(db.id, notifyList, "rpop", key)
+		}
+		m.notifyDeleted(db, key)
+		if !withCount {
+			c.WriteBulk(elems[0])
+			return
+		}
+		c.WriteLen(len(elems))
+		for _, e := range elems {
+			c.WriteBulk(e)
 		}
-		c.WriteBulk(elem)
 	})
 }
 
@@ -356,6 +386,11 @@ func (m *Miniredis) cmdXpush(c *server.Peer, cmd string, args []string, lr leftr
 				newLen = db.listPush(key, value)
 			}
 		}
+		if lr == left {
+			m.notify(db.id, notifyList, "lpush", key)
+		} else {
+			m.notify(db.id, notifyList, "rpush", key)
+		}
 		c.WriteInt(newLen)
 	})
 }
@@ -406,6 +441,11 @@ func (m *Miniredis) cmdXpushx(c *server.Peer, cmd string, args []string, lr left
 				newLen = db.listPush(key, value)
 			}
 		}
+		if lr == left {
+			m.notify(db.id, notifyList, "lpush", key)
+		} else {
+			m.notify(db.id, notifyList, "rpush", key)
+		}
 		c.WriteInt(newLen)
 	})
 }
@@ -446,7 +486,7 @@ func (m *Miniredis) cmdLrange(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
-		l := db.listKeys[key]
+		l := db.listKeys[key].elems()
 		if len(l) == 0 {
 			c.WriteLen(0)
 			return
@@ -495,7 +535,7 @@ func (m *Miniredis) cmdLrem(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
-		l := db.listKeys[key]
+		l := db.listKeys[key].elems()
 		if count < 0 {
 			reverseSlice(l)
 		}
@@ -524,8 +564,12 @@ func (m *Miniredis) cmdLrem(c *server.Peer, cmd string, args []string) {
 		if len(newL) == 0 {
 			db.del(key, true)
 		} else {
-			db.listKeys[key] = newL
-			db.keyVersion[key]++
+			db.listKeys[key].replace(newL)
+			db.markDirty(key)
+		}
+		if deleted > 0 {
+			m.notify(db.id, notifyList, "lrem", key)
+			m.notifyDeleted(db, key)
 		}
 
 		c.WriteInt(deleted)
@@ -569,14 +613,15 @@ func (m *Miniredis) cmdLset(c *server.Peer, cmd string, args []string) {
 
 		l := db.listKeys[key]
 		if index < 0 {
-			index = len(l) + index
+			index = l.len() + index
 		}
-		if index < 0 || index > len(l)-1 {
+		if index < 0 || index > l.len()-1 {
 			c.WriteError(msgOutOfRange)
 			return
 		}
-		l[index] = value
-		db.keyVersion[key]++
+		l.set(index, value)
+		db.markDirty(key)
+		m.notify(db.id, notifyList, "lset", key)
 
 		c.WriteOK()
 	})
@@ -623,15 +668,17 @@ func (m *Miniredis) cmdLtrim(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
-		l := db.listKeys[key]
+		l := db.listKeys[key].elems()
 		rs, re := redisRange(len(l), start, end, false)
 		l = l[rs:re]
 		if len(l) == 0 {
 			db.del(key, true)
 		} else {
-			db.listKeys[key] = l
-			db.keyVersion[key]++
+			db.listKeys[key].replace(l)
+			db.markDirty(key)
 		}
+		m.notify(db.id, notifyList, "ltrim", key)
+		m.notifyDeleted(db, key)
 		c.WriteOK()
 	})
 }
@@ -664,7 +711,10 @@ func (m *Miniredis) cmdRpoplpush(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		elem := db.listPop(src)
+		m.notify(db.id, notifyList, "rpop", src)
+		m.notifyDeleted(db, src)
 		db.listLpush(dst, elem)
+		m.notify(db.id, notifyList, "lpush", dst)
 		c.WriteBulk(elem)
 	})
 }
@@ -701,6 +751,7 @@ func (m *Miniredis) cmdBrpoplpush(c *server.Peer, cmd string, args []string) {
 		m,
 		c,
 		time.Duration(timeout)*time.Second,
+		[]string{src},
 		func(c *server.Peer, ctx *connCtx) bool {
 			db := m.db(ctx.selectedDB)
 
@@ -711,11 +762,14 @@ func (m *Miniredis) cmdBrpoplpush(c *server.Peer, cmd string, args []string) {
 				c.WriteError(msgWrongType)
 				return true
 			}
-			if len(db.listKeys[src]) == 0 {
+			if db.listKeys[src].len() == 0 {
 				return false
 			}
 			elem := db.listPop(src)
+			m.notify(db.id, notifyList, "rpop", src)
+			m.notifyDeleted(db, src)
 			db.listLpush(dst, elem)
+			m.notify(db.id, notifyList, "lpush", dst)
 			c.WriteBulk(elem)
 			return true
 		},
@@ -223,6 +223,43 @@ func TestLpop(t *testing.T) {
 		// Can pop non-existing keys just fine.
 		mustNil(t, c, "LPOP", "l")
 	}
+
+	t.Run("count", func(t *testing.T) {
+		s.Push("l", "aap", "noot", "mies", "vuur")
+		mustDo(t, c,
+			"LPOP", "l", "2",
+			proto.Strings("aap", "noot"),
+		)
+		mustDo(t, c,
+			"LPOP", "l", "0",
+			proto.Strings(),
+		)
+		mustDo(t, c,
+			"LPOP", "l", "99",
+			proto.Strings("mies", "vuur"),
+		)
+		must0(t, c, "EXISTS", "l")
+		mustNilList(t, c, "LPOP", "l", "2")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"LPOP",
+			proto.Error(errWrongNumber("lpop")),
+		)
+		mustDo(t, c,
+			"LPOP", "l", "2", "toomany",
+			proto.Error(errWrongNumber("lpop")),
+		)
+		mustDo(t, c,
+			"LPOP", "l", "-1",
+			proto.Error(msgPositive),
+		)
+		mustDo(t, c,
+			"LPOP", "l", "noint",
+			proto.Error(msgPositive),
+		)
+	})
 }
 
 func TestRPushPop(t *testing.T) {
@@ -333,6 +370,26 @@ func TestRpop(t *testing.T) {
 		// Can pop non-existing keys just fine.
 		mustNil(t, c, "RPOP", "l")
 	}
+
+	t.Run("count", func(t *testing.T) {
+		s.Push("l", "aap", "noot", "mies", "vuur")
+		mustDo(t, c,
+			"RPOP", "l", "2",
+			proto.Strings("vuur", "mies"),
+		)
+		mustDo(t, c,
+			"RPOP", "l", "99",
+			proto.Strings("noot", "aap"),
+		)
+		must0(t, c, "EXISTS", "l")
+		mustNilList(t, c, "RPOP", "l", "2")
+
+		s.Set("str", "value")
+		mustDo(t, c,
+			"RPOP", "str", "2",
+			proto.Error(msgWrongType),
+		)
+	})
 }
 
 func TestLindex(t *testing.T) {
@@ -726,6 +783,27 @@ func TestLinsert(t *testing.T) {
 		"LINSERT", "nonexisting", "before", "aap", "noot",
 	)
 
+	t.Run("watch", func(t *testing.T) {
+		// a missing pivot doesn't touch the key
+		mustOK(t, c, "WATCH", "l")
+		mustDo(t, c,
+			"LINSERT", "l", "AFTER", "nosuch", "noot",
+			proto.Int(-1),
+		)
+		mustOK(t, c, "MULTI")
+		mustDo(t, c, "LLEN", "l", proto.Inline("QUEUED"))
+		mustDo(t, c, "EXEC", proto.Ints(10))
+
+		mustOK(t, c, "WATCH", "l")
+		mustDo(t, c,
+			"LINSERT", "l", "AFTER", "]", "noot",
+			proto.Int(11),
+		)
+		mustOK(t, c, "MULTI")
+		mustDo(t, c, "LLEN", "l", proto.Inline("QUEUED"))
+		mustNilList(t, c, "EXEC")
+	})
+
 	t.Run("errors", func(t *testing.T) {
 		mustDo(t, c,
 			"LINSERT",
@@ -1007,6 +1085,27 @@ func TestBrpopMulti(t *testing.T) {
 	}
 }
 
+func TestBrpopRename(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	got := goStrings(t, s, "BRPOP", "l1", "0")
+	time.Sleep(30 * time.Millisecond)
+	must1(t, c, "RPUSH", "l0", "e01")
+	mustOK(t, c, "RENAME", "l0", "l1")
+
+	select {
+	case have := <-got:
+		equals(t, proto.Strings("l1", "e01"), have)
+	case <-time.After(500 * time.Millisecond):
+		t.Error("BRPOP took too long")
+	}
+}
+
 func TestBrpopTimeout(t *testing.T) {
 	s, err := Run()
 	ok(t, err)
@@ -1116,6 +1215,32 @@ func TestBlpop(t *testing.T) {
 	})
 }
 
+func TestBlpopFIFO(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+
+	// clients are served in the order they blocked
+	got := make([]chan string, 3)
+	for i := range got {
+		got[i] = make(chan string, 1)
+		c, err := proto.Dial(s.Addr())
+		ok(t, err)
+		defer c.Close()
+		go func(c *proto.Client, res chan string) {
+			v, err := c.Do("BLPOP", "q", "0")
+			ok(t, err)
+			res <- v
+		}(c, got[i])
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Push("q", "aap", "noot", "mies")
+	equals(t, proto.Strings("q", "aap"), <-got[0])
+	equals(t, proto.Strings("q", "noot"), <-got[1])
+	equals(t, proto.Strings("q", "mies"), <-got[2])
+}
+
 func TestBlpopResourceCleanup(t *testing.T) {
 	s, err := Run()
 	ok(t, err)
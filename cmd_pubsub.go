@@ -17,6 +17,9 @@ func commandsPubsub(m *Miniredis) {
 	m.srv.Register("PUNSUBSCRIBE", m.cmdPunsubscribe)
 	m.srv.Register("PUBLISH", m.cmdPublish)
 	m.srv.Register("PUBSUB", m.cmdPubSub)
+	m.srv.Register("SSUBSCRIBE", m.cmdSsubscribe)
+	m.srv.Register("SUNSUBSCRIBE", m.cmdSunsubscribe)
+	m.srv.Register("SPUBLISH", m.cmdSpublish)
 }
 
 // SUBSCRIBE
@@ -87,7 +90,7 @@ func (m *Miniredis) cmdUnsubscribe(c *server.Peer, cmd string, args []string) {
 			})
 		}
 
-		if sub.Count() == 0 {
+		if !sub.active() {
 			endSubscriber(m, c)
 		}
 	})
@@ -161,7 +164,7 @@ func (m *Miniredis) cmdPunsubscribe(c *server.Peer, cmd string, args []string) {
 			})
 		}
 
-		if sub.Count() == 0 {
+		if !sub.active() {
 			endSubscriber(m, c)
 		}
 	})
@@ -188,6 +191,101 @@ func (m *Miniredis) cmdPublish(c *server.Peer, cmd string, args []string) {
 	})
 }
 
+// SSUBSCRIBE
+func (m *Miniredis) cmdSsubscribe(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		sub := m.subscribedState(c)
+		for _, channel := range args {
+			n := sub.Ssubscribe(channel)
+			c.Block(func(w *server.Writer) {
+				w.WritePushLen(3)
+				w.WriteBulk("ssubscribe")
+				w.WriteBulk(channel)
+				w.WriteInt(n)
+			})
+		}
+	})
+}
+
+// SUNSUBSCRIBE
+func (m *Miniredis) cmdSunsubscribe(c *server.Peer, cmd string, args []string) {
+	if !m.handleAuth(c) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	channels := args
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		sub := m.subscribedState(c)
+
+		if len(channels) == 0 {
+			channels = sub.ShardChannels()
+		}
+
+		// there is no de-duplication
+		for _, channel := range channels {
+			n := sub.Sunsubscribe(channel)
+			c.Block(func(w *server.Writer) {
+				w.WritePushLen(3)
+				w.WriteBulk("sunsubscribe")
+				w.WriteBulk(channel)
+				w.WriteInt(n)
+			})
+		}
+		if len(channels) == 0 {
+			// special case: there is always a reply
+			c.Block(func(w *server.Writer) {
+				w.WritePushLen(3)
+				w.WriteBulk("sunsubscribe")
+				w.WriteNull()
+				w.WriteInt(0)
+			})
+		}
+
+		if !sub.active() {
+			endSubscriber(m, c)
+		}
+	})
+}
+
+// SPUBLISH
+func (m *Miniredis) cmdSpublish(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	channel, mesg := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		c.WriteInt(m.spublish(channel, mesg))
+	})
+}
+
 // PUBSUB
 func (m *Miniredis) cmdPubSub(c *server.Peer, cmd string, args []string) {
 	if len(args) < 1 {
@@ -211,6 +309,10 @@ func (m *Miniredis) cmdPubSub(c *server.Peer, cmd string, args []string) {
 		argsOk = true
 	case "NUMPAT":
 		argsOk = len(subargs) == 0
+	case "SHARDCHANNELS":
+		argsOk = len(subargs) < 2
+	case "SHARDNUMSUB":
+		argsOk = true
 	default:
 		argsOk = false
 	}
@@ -234,7 +336,7 @@ func (m *Miniredis) cmdPubSub(c *server.Peer, cmd string, args []string) {
 			}
 
 			allsubs := m.allSubscribers()
-			channels := activeChannels(allsubs, pat)
+			channels := activeChannels(allsubs, pat, false)
 
 			c.WriteLen(len(channels))
 			for _, channel := range channels {
@@ -251,6 +353,26 @@ func (m *Miniredis) cmdPubSub(c *server.Peer, cmd string, args []string) {
 
 		case "NUMPAT":
 			c.WriteInt(countPsubs(m.allSubscribers()))
+
+		case "SHARDCHANNELS":
+			pat := ""
+			if len(subargs) == 1 {
+				pat = subargs[0]
+			}
+
+			channels := activeChannels(m.allSubscribers(), pat, true)
+			c.WriteLen(len(channels))
+			for _, channel := range channels {
+				c.WriteBulk(channel)
+			}
+
+		case "SHARDNUMSUB":
+			subs := m.allSubscribers()
+			c.WriteLen(len(subargs) * 2)
+			for _, channel := range subargs {
+				c.WriteBulk(channel)
+				c.WriteInt(countShardSubs(subs, channel))
+			}
 		}
 	})
 }
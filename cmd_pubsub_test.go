@@ -243,6 +243,43 @@ func TestPsubscribe(t *testing.T) {
 	})
 }
 
+func TestPsubscribeMultiple(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+	c2, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c2.Close()
+
+	mustDo(t, c,
+		"SUBSCRIBE", "news.art",
+		proto.Array(proto.String("subscribe"), proto.String("news.art"), proto.Int(1)),
+	)
+	mustDo(t, c,
+		"PSUBSCRIBE", "news.*", "*", "n[a-f]ws.[^b]rt", `news\.art`,
+		proto.Array(proto.String("psubscribe"), proto.String("news.*"), proto.Int(2)),
+	)
+	mustRead(t, c, proto.Array(proto.String("psubscribe"), proto.String("*"), proto.Int(3)))
+	mustRead(t, c, proto.Array(proto.String("psubscribe"), proto.String("n[a-f]ws.[^b]rt"), proto.Int(4)))
+	mustRead(t, c, proto.Array(proto.String("psubscribe"), proto.String(`news\.art`), proto.Int(5)))
+
+	// once for the channel, and once for every pattern
+	mustDo(t, c2, "PUBLISH", "news.art", "hello", proto.Int(5))
+	mustRead(t, c, proto.Strings("message", "news.art", "hello"))
+	mustRead(t, c, proto.Strings("pmessage", "*", "news.art", "hello"))
+	mustRead(t, c, proto.Strings("pmessage", "n[a-f]ws.[^b]rt", "news.art", "hello"))
+	mustRead(t, c, proto.Strings("pmessage", "news.*", "news.art", "hello"))
+	mustRead(t, c, proto.Strings("pmessage", `news\.art`, "news.art", "hello"))
+
+	// '*' matches across separators
+	mustDo(t, c2, "PUBLISH", "news.brt.x", "hi", proto.Int(2))
+	mustRead(t, c, proto.Strings("pmessage", "*", "news.brt.x", "hi"))
+	mustRead(t, c, proto.Strings("pmessage", "news.*", "news.brt.x", "hi"))
+}
+
 func TestPunsubscribe(t *testing.T) {
 	s, err := Run()
 	ok(t, err)
@@ -564,6 +601,150 @@ func TestPubsubNumpat(t *testing.T) {
 	equals(t, 0, s.PubSubNumPat())
 }
 
+func TestGoSubscribe(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	msgs := s.Subscribe("events.*", "other")
+	equals(t, 2, s.PubSubNumPat())
+
+	// no reader yet, these get queued
+	mustDo(t, c, "PUBLISH", "events.1", "hello", proto.Int(1))
+	mustDo(t, c, "PUBLISH", "nomatch", "hello", proto.Int(0))
+	equals(t, 1, s.Publish("other", "world"))
+
+	equals(t, PubsubMessage{"events.1", "hello"}, <-msgs)
+	equals(t, PubsubMessage{"other", "world"}, <-msgs)
+
+	s.Close()
+	_, open := <-msgs
+	assert(t, !open, "channel closed")
+	equals(t, 0, s.PubSubNumPat())
+
+	t.Run("restart", func(t *testing.T) {
+		ok(t, s.Restart())
+		defer s.Close()
+		msgs := s.Subscribe("again")
+		equals(t, 1, s.Publish("again", "hi"))
+		equals(t, PubsubMessage{"again", "hi"}, <-msgs)
+	})
+
+	t.Run("not started", func(t *testing.T) {
+		m := NewMiniRedis()
+		msgs := m.Subscribe("foo")
+		m.Close()
+		_, open := <-msgs
+		assert(t, !open, "channel closed")
+		equals(t, 0, m.PubSubNumPat())
+	})
+}
+
+func TestSsubscribe(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c1, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c1.Close()
+	c2, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c2.Close()
+
+	mustDo(t, c2,
+		"SSUBSCRIBE", "event1", "event2",
+		proto.Array(proto.String("ssubscribe"), proto.String("event1"), proto.Int(1)),
+	)
+	mustRead(t, c2, proto.Array(proto.String("ssubscribe"), proto.String("event2"), proto.Int(2)))
+	// shard channels are counted on their own
+	mustDo(t, c2,
+		"SUBSCRIBE", "event1",
+		proto.Array(proto.String("subscribe"), proto.String("event1"), proto.Int(1)),
+	)
+
+	t.Run("publish", func(t *testing.T) {
+		must1(t, c1, "SPUBLISH", "event1", "hello")
+		mustRead(t, c2, proto.Strings("smessage", "event1", "hello"))
+		must0(t, c1, "SPUBLISH", "event3", "hello")
+
+		// PUBLISH doesn't go to shard channels, and vice versa
+		must0(t, c1, "PUBLISH", "event2", "nope")
+		must1(t, c1, "PUBLISH", "event1", "world")
+		mustRead(t, c2, proto.Strings("message", "event1", "world"))
+
+		equals(t, 1, s.Spublish("event2", "direct"))
+		mustRead(t, c2, proto.Strings("smessage", "event2", "direct"))
+	})
+
+	t.Run("pubsub", func(t *testing.T) {
+		mustDo(t, c1,
+			"PUBSUB", "SHARDCHANNELS",
+			proto.Strings("event1", "event2"),
+		)
+		mustDo(t, c1,
+			"PUBSUB", "SHARDCHANNELS", "*2",
+			proto.Strings("event2"),
+		)
+		mustDo(t, c1,
+			"PUBSUB", "CHANNELS",
+			proto.Strings("event1"),
+		)
+		mustDo(t, c1,
+			"PUBSUB", "SHARDNUMSUB", "event1", "event3",
+			proto.Array(
+				proto.String("event1"),
+				proto.Int(1),
+				proto.String("event3"),
+				proto.Int(0),
+			),
+		)
+		equals(t, []string{"event1", "event2"}, s.PubSubShardChannels(""))
+		equals(t, map[string]int{"event2": 1, "nosuch": 0}, s.PubSubShardNumSub("event2", "nosuch"))
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		mustDo(t, c2,
+			"SUNSUBSCRIBE", "event1",
+			proto.Array(proto.String("sunsubscribe"), proto.String("event1"), proto.Int(1)),
+		)
+		mustDo(t, c2,
+			"UNSUBSCRIBE",
+			proto.Array(proto.String("unsubscribe"), proto.String("event1"), proto.Int(0)),
+		)
+		// still subscribed to event2
+		mustDo(t, c2,
+			"GET", "foo",
+			proto.Error("ERR Can't execute 'get': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context"),
+		)
+		mustDo(t, c2,
+			"SUNSUBSCRIBE",
+			proto.Array(proto.String("sunsubscribe"), proto.String("event2"), proto.Int(0)),
+		)
+		mustNil(t, c2, "GET", "foo")
+		mustDo(t, c2,
+			"SUNSUBSCRIBE",
+			proto.Array(proto.String("sunsubscribe"), proto.Nil, proto.Int(0)),
+		)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c1,
+			"SSUBSCRIBE",
+			proto.Error("ERR wrong number of arguments for 'ssubscribe' command"),
+		)
+		mustDo(t, c1,
+			"SPUBLISH", "event1",
+			proto.Error("ERR wrong number of arguments for 'spublish' command"),
+		)
+		mustDo(t, c1,
+			"PUBSUB", "SHARDCHANNELS", "a", "b",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'SHARDCHANNELS'. Try PUBSUB HELP."),
+		)
+	})
+}
+
 func TestPubSubBadArgs(t *testing.T) {
 	s, err := Run()
 	ok(t, err)
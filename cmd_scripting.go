@@ -7,6 +7,7 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	luajson "github.com/alicebob/gopher-json"
 	lua "github.com/yuin/gopher-lua"
@@ -17,15 +18,50 @@ import (
 
 func commandsScripting(m *Miniredis) {
 	m.srv.Register("EVAL", m.cmdEval)
+	m.srv.Register("EVAL_RO", m.cmdEval)
 	m.srv.Register("EVALSHA", m.cmdEvalsha)
+	m.srv.Register("EVALSHA_RO", m.cmdEvalsha)
 	m.srv.Register("SCRIPT", m.cmdScript)
+	m.srv.Register("FCALL", m.cmdFcall)
+	m.srv.Register("FCALL_RO", m.cmdFcall)
+	m.srv.Register("FUNCTION", m.cmdFunction)
 }
 
 // Execute lua. Needs to run m.Lock()ed, from within withTx().
-func (m *Miniredis) runLuaScript(c *server.Peer, script string, args []string) {
-	l := lua.NewState(lua.Options{SkipOpenLibs: true})
+// Returns whether the script compiled, even if it failed to run.
+func (m *Miniredis) runLuaScript(c *server.Peer, script string, readOnly bool, args []string) bool {
+	keys, argv, msg := luaKeysArgs(args)
+	if msg != "" {
+		c.WriteError(msg)
+		return false
+	}
+
+	l := newLuaState(mkLuaFuncs(m.srv, c, readOnly), map[string][]string{
+		"KEYS": keys,
+		"ARGV": argv,
+	})
 	defer l.Close()
 
+	fn, err := l.LoadString(script)
+	if err != nil {
+		c.WriteError(errLuaParseError(err))
+		return false
+	}
+	l.Push(fn)
+	if err := l.PCall(0, lua.MultRet, nil); err != nil {
+		c.WriteError(errLuaParseError(err))
+		return true
+	}
+
+	luaToRedis(l, c, l.Get(1))
+	return true
+}
+
+// newLuaState makes a Lua state with the libraries scripts can use, the
+// globals, and the "redis" module with the given functions.
+func newLuaState(redisFuncs map[string]lua.LGFunction, globals map[string][]string) *lua.LState {
+	l := lua.NewState(lua.Options{SkipOpenLibs: true})
+
 	// Taken from the go-lua manual
 	for _, pair := range []struct {
 		n string
@@ -50,39 +86,23 @@ func (m *Miniredis) runLuaScript(c *server.Peer, script string, args []string) {
 
 	luajson.Preload(l)
 	requireGlobal(l, "cjson", "json")
-
-	// set global variable KEYS
-	keysTable := l.NewTable()
-	keysS, args := args[0], args[1:]
-	keysLen, err := strconv.Atoi(keysS)
-	if err != nil {
-		c.WriteError(msgInvalidInt)
-		return
-	}
-	if keysLen < 0 {
-		c.WriteError(msgNegativeKeysNumber)
-		return
-	}
-	if keysLen > len(args) {
-		c.WriteError(msgInvalidKeysNumber)
-		return
-	}
-	keys, args := args[:keysLen], args[keysLen:]
-	for i, k := range keys {
-		l.RawSet(keysTable, lua.LNumber(i+1), lua.LString(k))
+	l.PreloadModule("bit", luaBitLoader)
+	requireGlobal(l, "bit", "bit")
+	l.PreloadModule("struct", luaStructLoader)
+	requireGlobal(l, "struct", "struct")
+	l.PreloadModule("cmsgpack", luaCmsgpackLoader)
+	requireGlobal(l, "cmsgpack", "cmsgpack")
+
+	for name, vs := range globals {
+		l.SetGlobal(name, luaStrings(l, vs))
 	}
-	l.SetGlobal("KEYS", keysTable)
 
-	argvTable := l.NewTable()
-	for i, a := range args {
-		l.RawSet(argvTable, lua.LNumber(i+1), lua.LString(a))
-	}
-	l.SetGlobal("ARGV", argvTable)
-
-	redisFuncs := mkLuaFuncs(m.srv, c)
 	// Register command handlers
 	l.Push(l.NewFunction(func(l *lua.LState) int {
 		mod := l.RegisterModule("redis", redisFuncs).(*lua.LTable)
+		for i, level := range []string{"LOG_DEBUG", "LOG_VERBOSE", "LOG_NOTICE", "LOG_WARNING"} {
+			mod.RawSetString(level, lua.LNumber(i))
+		}
 		l.Push(mod)
 		return 1
 	}))
@@ -92,12 +112,33 @@ func (m *Miniredis) runLuaScript(c *server.Peer, script string, args []string) {
 	l.Push(lua.LString("redis"))
 	l.Call(1, 0)
 
-	if err := l.DoString(script); err != nil {
-		c.WriteError(errLuaParseError(err))
-		return
+	return l
+}
+
+// luaKeysArgs splits the "numkeys [key ...] [arg ...]" arguments of EVAL and
+// FCALL. Returns an error message on failure.
+func luaKeysArgs(args []string) ([]string, []string, string) {
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, nil, msgInvalidInt
+	}
+	if n < 0 {
+		return nil, nil, msgNegativeKeysNumber
 	}
+	args = args[1:]
+	if n > len(args) {
+		return nil, nil, msgInvalidKeysNumber
+	}
+	return args[:n], args[n:], ""
+}
 
-	luaToRedis(l, c, l.Get(1))
+// luaStrings makes a Lua array.
+func luaStrings(l *lua.LState, vs []string) *lua.LTable {
+	t := l.NewTable()
+	for i, v := range vs {
+		l.RawSet(t, lua.LNumber(i+1), lua.LString(v))
+	}
+	return t
 }
 
 func (m *Miniredis) cmdEval(c *server.Peer, cmd string, args []string) {
@@ -121,7 +162,10 @@ func (m *Miniredis) cmdEval(c *server.Peer, cmd string, args []string) {
 	script, args := args[0], args[1:]
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
-		m.runLuaScript(c, script, args)
+		// EVAL also caches the script, for EVALSHA
+		if m.runLuaScript(c, script, cmd == "EVAL_RO", args) {
+			m.scripts[sha1Hex(script)] = script
+		}
 	})
 }
 
@@ -145,13 +189,13 @@ func (m *Miniredis) cmdEvalsha(c *server.Peer, cmd string, args []string) {
 	sha, args := args[0], args[1:]
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
-		script, ok := m.scripts[sha]
+		script, ok := m.scripts[strings.ToLower(sha)]
 		if !ok {
 			c.WriteError(msgNoScriptFound)
 			return
 		}
 
-		m.runLuaScript(c, script, args)
+		m.runLuaScript(c, script, cmd == "EVALSHA_RO", args)
 	})
 }
 
@@ -192,6 +236,13 @@ func (m *Miniredis) cmdScript(c *server.Peer, cmd string, args []string) {
 			m.scripts[sha] = script
 			c.WriteBulk(sha)
 
+		case "kill":
+			if len(args) != 0 {
+				c.WriteError(fmt.Sprintf(msgFScriptUsage, "KILL"))
+				return
+			}
+			m.killBusy(c, false)
+
 		case "exists":
 			c.WriteLen(len(args))
 			for _, arg := range args {
@@ -203,6 +254,12 @@ func (m *Miniredis) cmdScript(c *server.Peer, cmd string, args []string) {
 			}
 
 		case "flush":
+			if len(args) == 1 {
+				switch strings.ToUpper(args[0]) {
+				case "ASYNC", "SYNC":
+					args = args[1:]
+				}
+			}
 			if len(args) != 0 {
 				c.WriteError(fmt.Sprintf(msgFScriptUsage, "FLUSH"))
 				return
@@ -217,6 +274,382 @@ func (m *Miniredis) cmdScript(c *server.Peer, cmd string, args []string) {
 	})
 }
 
+// FCALL and FCALL_RO
+func (m *Miniredis) cmdFcall(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	name, args := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		keys, argv, msg := luaKeysArgs(args)
+		if msg != "" {
+			c.WriteError(msg)
+			return
+		}
+		lib, f := m.findFunction(name)
+		if f == nil {
+			c.WriteError(msgFunctionNotFound)
+			return
+		}
+		if cmd == "FCALL_RO" && !f.readOnly() {
+			c.WriteError(msgROFunction)
+			return
+		}
+
+		m.runFunction(c, lib, f, keys, argv)
+	})
+}
+
+// FUNCTION
+func (m *Miniredis) cmdFunction(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	subcmd, args := strings.ToUpper(args[0]), args[1:]
+	switch {
+	case subcmd == "LOAD" && (len(args) == 1 || len(args) == 2):
+	case subcmd == "DELETE" && len(args) == 1:
+	case subcmd == "FLUSH" && len(args) <= 1:
+	case subcmd == "LIST":
+	case subcmd == "DUMP" && len(args) == 0:
+	case subcmd == "RESTORE" && (len(args) == 1 || len(args) == 2):
+	case subcmd == "STATS" && len(args) == 0:
+	case subcmd == "KILL" && len(args) == 0:
+	default:
+		setDirty(c)
+		c.WriteError(fmt.Sprintf(msgFFunctionUsage, subcmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	switch subcmd {
+	case "LOAD":
+		m.cmdFunctionLoad(c, args)
+	case "DELETE":
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			if _, ok := m.libraries[args[0]]; !ok {
+				c.WriteError(msgLibraryNotFound)
+				return
+			}
+			delete(m.libraries, args[0])
+			c.WriteOK()
+		})
+	case "FLUSH":
+		if len(args) == 1 {
+			switch strings.ToUpper(args[0]) {
+			case "ASYNC", "SYNC":
+			default:
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+		}
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			m.libraries = map[string]*luaLibrary{}
+			c.WriteOK()
+		})
+	case "LIST":
+		m.cmdFunctionList(c, args)
+	case "DUMP":
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			c.WriteBulk(string(dumpLibraries(m.sortedLibraries())))
+		})
+	case "RESTORE":
+		m.cmdFunctionRestore(c, args)
+	case "STATS":
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			n := 0
+			for _, lib := range m.libraries {
+				n += len(lib.functions)
+			}
+			c.WriteMapLen(2)
+			c.WriteBulk("running_script")
+			if b := m.busy; b != nil && b.function != "" {
+				c.WriteMapLen(3)
+				c.WriteBulk("name")
+				c.WriteBulk(b.function)
+				c.WriteBulk("command")
+				c.WriteLen(3)
+				c.WriteBulk("fcall")
+				c.WriteBulk(b.function)
+				c.WriteBulk("0")
+				c.WriteBulk("duration_ms")
+				c.WriteInt(int(m.effectiveNow().Sub(b.started).Milliseconds()))
+			} else {
+				c.WriteNull()
+			}
+			c.WriteBulk("engines")
+			c.WriteMapLen(1)
+			c.WriteBulk("LUA")
+			c.WriteMapLen(2)
+			c.WriteBulk("libraries_count")
+			c.WriteInt(len(m.libraries))
+			c.WriteBulk("functions_count")
+			c.WriteInt(n)
+		})
+	case "KILL":
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			m.killBusy(c, true)
+		})
+	}
+}
+
+// FUNCTION LOAD
+func (m *Miniredis) cmdFunctionLoad(c *server.Peer, args []string) {
+	replace := false
+	if len(args) == 2 {
+		if strings.ToUpper(args[0]) != "REPLACE" {
+			setDirty(c)
+			c.WriteError(fmt.Sprintf("ERR Unknown option given: %s", args[0]))
+			return
+		}
+		replace = true
+		args = args[1:]
+	}
+	code := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		lib, err := parseLibrary(code)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if err := addLibrary(m.libraries, lib, replace); err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteBulk(lib.name)
+	})
+}
+
+// FUNCTION LIST
+func (m *Miniredis) cmdFunctionList(c *server.Peer, args []string) {
+	var opts struct {
+		withCode bool
+		pattern  string
+	}
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "WITHCODE":
+			opts.withCode = true
+			args = args[1:]
+		case "LIBRARYNAME":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError("ERR library name argument was not given")
+				return
+			}
+			opts.pattern = args[1]
+			args = args[2:]
+		default:
+			setDirty(c)
+			c.WriteError(fmt.Sprintf("ERR Unknown argument %s", args[0]))
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		var libs []*luaLibrary
+		for _, lib := range m.sortedLibraries() {
+			if opts.pattern != "" && !patternMatch(opts.pattern, lib.name) {
+				continue
+			}
+			libs = append(libs, lib)
+		}
+
+		c.WriteLen(len(libs))
+		for _, lib := range libs {
+			if opts.withCode {
+				c.WriteMapLen(4)
+			} else {
+				c.WriteMapLen(3)
+			}
+			c.WriteBulk("library_name")
+			c.WriteBulk(lib.name)
+			c.WriteBulk("engine")
+			c.WriteBulk("LUA")
+			c.WriteBulk("functions")
+			c.WriteLen(len(lib.functions))
+			for _, f := range lib.sortedFunctions() {
+				c.WriteMapLen(3)
+				c.WriteBulk("name")
+				c.WriteBulk(f.name)
+				c.WriteBulk("description")
+				if f.description == "" {
+					c.WriteNull()
+				} else {
+					c.WriteBulk(f.description)
+				}
+				c.WriteBulk("flags")
+				c.WriteSetLen(len(f.flags))
+				for _, fl := range f.flags {
+					c.WriteBulk(fl)
+				}
+			}
+			if opts.withCode {
+				c.WriteBulk("library_code")
+				c.WriteBulk(lib.code)
+			}
+		}
+	})
+}
+
+// FUNCTION RESTORE
+func (m *Miniredis) cmdFunctionRestore(c *server.Peer, args []string) {
+	policy := "APPEND"
+	if len(args) == 2 {
+		policy = strings.ToUpper(args[1])
+		switch policy {
+		case "APPEND", "REPLACE", "FLUSH":
+		default:
+			setDirty(c)
+			c.WriteError("ERR Wrong restore policy given, value should be either FLUSH, APPEND or REPLACE.")
+			return
+		}
+	}
+	payload := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		codes, err := restoreLibraries(payload)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+
+		libs := map[string]*luaLibrary{}
+		if policy != "FLUSH" {
+			for name, lib := range m.libraries {
+				libs[name] = lib
+			}
+		}
+		for _, code := range codes {
+			lib, err := parseLibrary(code)
+			if err != nil {
+				c.WriteError(err.Error())
+				return
+			}
+			if err := addLibrary(libs, lib, policy == "REPLACE"); err != nil {
+				c.WriteError(err.Error())
+				return
+			}
+		}
+		m.libraries = libs
+		c.WriteOK()
+	})
+}
+
+// busyScript is the script SetBusyScript() or SetBusyFunction() pretend is
+// running.
+type busyScript struct {
+	function string // FCALL function, or "" for EVAL
+	dirty    bool   // wrote to the dataset, can't be killed
+	started  time.Time
+	prevHook server.Hook // pre hook from before the script, such as SetError()
+}
+
+// busyErr is the error for commands while the script runs.
+func (b *busyScript) busyErr() string {
+	if b.function != "" {
+		return msgBusyFunction
+	}
+	return msgBusyScript
+}
+
+func (m *Miniredis) setBusy(b *busyScript) {
+	m.Lock()
+	defer m.Unlock()
+	if b == nil {
+		if m.busy != nil {
+			m.stopBusy()
+		}
+		return
+	}
+	b.started = m.effectiveNow()
+	if m.busy != nil {
+		b.prevHook = m.busy.prevHook
+	} else {
+		b.prevHook = m.srv.PreHook()
+	}
+	m.busy = b
+	m.srv.SetPreHook(m.busyHook)
+}
+
+// stopBusy ends the busy script, and puts back the previous pre hook. Needs to
+// run m.Lock()ed.
+func (m *Miniredis) stopBusy() {
+	m.srv.SetPreHook(m.busy.prevHook)
+	m.busy = nil
+}
+
+// busyHook is the server pre hook while a busy script runs. Only commands which
+// Redis allows during a script get through.
+func (m *Miniredis) busyHook(c *server.Peer, cmd string, args ...string) bool {
+	subcmd := ""
+	if len(args) > 0 {
+		subcmd = strings.ToUpper(args[0])
+	}
+	switch {
+	case cmd == "AUTH", cmd == "HELLO", cmd == "SHUTDOWN",
+		cmd == "MULTI", cmd == "DISCARD", cmd == "WATCH", cmd == "UNWATCH":
+		return false
+	case cmd == "SCRIPT" && subcmd == "KILL":
+		return false
+	case cmd == "FUNCTION" && (subcmd == "KILL" || subcmd == "STATS"):
+		return false
+	}
+
+	m.Lock()
+	b := m.busy
+	m.Unlock()
+	if b == nil {
+		return false
+	}
+	setDirty(c)
+	c.WriteError(b.busyErr())
+	return true
+}
+
+// killBusy is SCRIPT KILL and FUNCTION KILL. Needs to run m.Lock()ed.
+func (m *Miniredis) killBusy(c *server.Peer, function bool) {
+	b := m.busy
+	switch {
+	case b == nil:
+		c.WriteError(msgNotBusy)
+	case b.dirty:
+		c.WriteError(msgUnkillable)
+	case function != (b.function != ""):
+		c.WriteError(b.busyErr())
+	default:
+		m.stopBusy()
+		c.WriteOK()
+	}
+}
+
 func sha1Hex(s string) string {
 	h := sha1.New()
 	io.WriteString(h, s)
@@ -1,6 +1,8 @@
 package miniredis
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/alicebob/miniredis/v2/proto"
@@ -156,6 +158,42 @@ func TestScript(t *testing.T) {
 		proto.Array(),
 	)
 
+	t.Run("eval caches", func(t *testing.T) {
+		mustDo(t, c,
+			"EVALSHA", script2sha, "0",
+			proto.Error(msgNoScriptFound),
+		)
+		mustDo(t, c,
+			"EVAL", "return 42", "0",
+			proto.Int(42),
+		)
+		mustDo(t, c,
+			"SCRIPT", "EXISTS", script2sha,
+			proto.Array(proto.Int(1)),
+		)
+		mustDo(t, c,
+			"EVALSHA", strings.ToUpper(script2sha), "0",
+			proto.Int(42),
+		)
+
+		// scripts with errors aren't cached
+		mustContain(t, c,
+			"EVAL", "return [", "0",
+			"Error compiling script",
+		)
+		mustDo(t, c,
+			"SCRIPT", "EXISTS", sha1Hex("return ["),
+			proto.Array(proto.Int(0)),
+		)
+
+		mustOK(t, c, "SCRIPT", "FLUSH", "ASYNC")
+		mustDo(t, c,
+			"SCRIPT", "EXISTS", script2sha,
+			proto.Array(proto.Int(0)),
+		)
+		mustOK(t, c, "SCRIPT", "FLUSH", "sync")
+	})
+
 	mustDo(t, c,
 		"SCRIPT",
 		proto.Error(errWrongNumber("script")),
@@ -187,6 +225,382 @@ func TestScript(t *testing.T) {
 	)
 }
 
+func TestBusyScript(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	mustDo(t, c,
+		"SCRIPT", "KILL",
+		proto.Error(msgNotBusy),
+	)
+
+	s.SetBusyScript(false)
+	mustDo(t, c,
+		"GET", "foo",
+		proto.Error(msgBusyScript),
+	)
+	mustDo(t, c,
+		"FUNCTION", "KILL",
+		proto.Error(msgBusyScript),
+	)
+	mustOK(t, c,
+		"SCRIPT", "KILL",
+	)
+	mustNil(t, c,
+		"GET", "foo",
+	)
+
+	s.SetBusyScript(true)
+	mustDo(t, c,
+		"SCRIPT", "KILL",
+		proto.Error(msgUnkillable),
+	)
+	s.ClearBusyScript()
+	mustNil(t, c,
+		"GET", "foo",
+	)
+
+	t.Run("function", func(t *testing.T) {
+		s.SetBusyFunction("myfunc", false)
+		mustDo(t, c,
+			"SET", "foo", "bar",
+			proto.Error(msgBusyFunction),
+		)
+		mustDo(t, c,
+			"FUNCTION", "STATS",
+			proto.Array(
+				proto.String("running_script"), proto.Array(
+					proto.String("name"), proto.String("myfunc"),
+					proto.String("command"), proto.Strings("fcall", "myfunc", "0"),
+					proto.String("duration_ms"), proto.Int(0),
+				),
+				proto.String("engines"), proto.Array(
+					proto.String("LUA"), proto.Array(
+						proto.String("libraries_count"), proto.Int(0),
+						proto.String("functions_count"), proto.Int(0),
+					),
+				),
+			),
+		)
+		mustDo(t, c,
+			"SCRIPT", "KILL",
+			proto.Error(msgBusyFunction),
+		)
+		mustOK(t, c,
+			"FUNCTION", "KILL",
+		)
+		mustOK(t, c,
+			"SET", "foo", "bar",
+		)
+	})
+
+	t.Run("SetError", func(t *testing.T) {
+		s.SetError("LOADING Redis is loading the dataset in memory")
+		defer s.SetError("")
+
+		s.SetBusyScript(false)
+		mustDo(t, c,
+			"GET", "foo",
+			proto.Error(msgBusyScript),
+		)
+		s.SetBusyFunction("myfunc", false)
+		mustOK(t, c,
+			"FUNCTION", "KILL",
+		)
+		mustDo(t, c,
+			"GET", "foo",
+			proto.Error("LOADING Redis is loading the dataset in memory"),
+		)
+
+		s.SetBusyScript(true)
+		s.ClearBusyScript()
+		mustDo(t, c,
+			"GET", "foo",
+			proto.Error("LOADING Redis is loading the dataset in memory"),
+		)
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		s.SetBusyScript(true)
+		mustDo(t, c,
+			"SHUTDOWN",
+			proto.Error(msgBusyScript),
+		)
+		_, err := c.Do("SHUTDOWN", "NOSAVE")
+		mustFail(t, err, "EOF")
+
+		c2, err := proto.Dial(s.Addr())
+		ok(t, err)
+		defer c2.Close()
+		mustDo(t, c2,
+			"GET", "foo",
+			proto.String("bar"),
+		)
+	})
+}
+
+func TestEvalRO(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.Set("foo", "bar")
+
+	mustDo(t, c,
+		"EVAL_RO", "return redis.call('GET', KEYS[1])", "1", "foo",
+		proto.String("bar"),
+	)
+	mustContain(t, c,
+		"EVAL_RO", "return redis.call('SET', KEYS[1], 'baz')", "1", "foo",
+		"Write commands are not allowed from read-only scripts",
+	)
+	mustContain(t, c,
+		"EVAL_RO", "return redis.call('FLUSHALL')", "0",
+		"Write commands are not allowed from read-only scripts",
+	)
+	mustDo(t, c,
+		"EVAL_RO", "return redis.pcall('DEL', KEYS[1])", "1", "foo",
+		proto.Error(msgROScript),
+	)
+	s.CheckGet(t, "foo", "bar")
+
+	sha := sha1Hex("return redis.call('INCR', KEYS[1])")
+	mustDo(t, c,
+		"SCRIPT", "LOAD", "return redis.call('INCR', KEYS[1])",
+		proto.String(sha),
+	)
+	mustContain(t, c,
+		"EVALSHA_RO", sha, "1", "counter",
+		"Write commands are not allowed from read-only scripts",
+	)
+	mustDo(t, c,
+		"EVALSHA", sha, "1", "counter",
+		proto.Int(1),
+	)
+	mustDo(t, c,
+		"EVALSHA_RO", sha1Hex("return 42"), "0",
+		proto.Error(msgNoScriptFound),
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"EVAL_RO", "return 42",
+			proto.Error(errWrongNumber("eval_ro")),
+		)
+		mustDo(t, c,
+			"EVALSHA_RO", sha,
+			proto.Error(errWrongNumber("evalsha_ro")),
+		)
+		mustContain(t, c,
+			"EVAL", "return redis.call('EVAL_RO', 'return 1', 0)", "0",
+			msgNotFromScripts,
+		)
+	})
+}
+
+func TestFunction(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	lib := "#!lua name=mylib\n" +
+		"redis.register_function('myget', function(keys, args) return redis.call('GET', keys[1]) end)\n" +
+		"redis.register_function{function_name='myset', callback=function(keys, args) return redis.call('SET', keys[1], args[1]) end, description='sets'}\n" +
+		"redis.register_function{function_name='myro', callback=function(keys, args) return redis.call('SET', keys[1], 'x') end, flags={'no-writes'}}\n"
+
+	mustDo(t, c,
+		"FUNCTION", "LOAD", lib,
+		proto.String("mylib"),
+	)
+	mustDo(t, c,
+		"FUNCTION", "LOAD", lib,
+		proto.Error("ERR Library 'mylib' already exists"),
+	)
+	mustDo(t, c,
+		"FUNCTION", "LOAD", "REPLACE", lib,
+		proto.String("mylib"),
+	)
+
+	t.Run("fcall", func(t *testing.T) {
+		mustOK(t, c,
+			"FCALL", "myset", "1", "foo", "bar",
+		)
+		s.CheckGet(t, "foo", "bar")
+		mustDo(t, c,
+			"FCALL", "myget", "1", "foo",
+			proto.String("bar"),
+		)
+		mustDo(t, c,
+			"FCALL", "nosuch", "0",
+			proto.Error(msgFunctionNotFound),
+		)
+		mustDo(t, c,
+			"FCALL_RO", "myset", "1", "foo", "baz",
+			proto.Error(msgROFunction),
+		)
+		mustContain(t, c,
+			"FCALL_RO", "myro", "1", "foo",
+			"Write commands are not allowed from read-only scripts",
+		)
+		s.CheckGet(t, "foo", "bar")
+		mustDo(t, c,
+			"FCALL", "myget", "2", "foo",
+			proto.Error(msgInvalidKeysNumber),
+		)
+		mustDo(t, c,
+			"FCALL", "myget",
+			proto.Error(errWrongNumber("fcall")),
+		)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		mustDo(t, c,
+			"FUNCTION", "LIST", "LIBRARYNAME", "nosuch*",
+			proto.Array(),
+		)
+		mustDo(t, c,
+			"FUNCTION", "LIST", "LIBRARYNAME", "my*",
+			proto.Array(
+				proto.Array(
+					proto.String("library_name"), proto.String("mylib"),
+					proto.String("engine"), proto.String("LUA"),
+					proto.String("functions"), proto.Array(
+						proto.Array(
+							proto.String("name"), proto.String("myget"),
+							proto.String("description"), proto.Nil,
+							proto.String("flags"), proto.Array(),
+						),
+						proto.Array(
+							proto.String("name"), proto.String("myro"),
+							proto.String("description"), proto.Nil,
+							proto.String("flags"), proto.Strings("no-writes"),
+						),
+						proto.Array(
+							proto.String("name"), proto.String("myset"),
+							proto.String("description"), proto.String("sets"),
+							proto.String("flags"), proto.Array(),
+						),
+					),
+				),
+			),
+		)
+		mustDo(t, c,
+			"FUNCTION", "STATS",
+			proto.Array(
+				proto.String("running_script"), proto.Nil,
+				proto.String("engines"), proto.Array(
+					proto.String("LUA"), proto.Array(
+						proto.String("libraries_count"), proto.Int(1),
+						proto.String("functions_count"), proto.Int(3),
+					),
+				),
+			),
+		)
+	})
+
+	t.Run("dump", func(t *testing.T) {
+		dump, err := c.Do("FUNCTION", "DUMP")
+		ok(t, err)
+		payload, err := proto.ReadString(dump)
+		ok(t, err)
+
+		mustDo(t, c,
+			"FUNCTION", "RESTORE", payload,
+			proto.Error("ERR Library 'mylib' already exists"),
+		)
+		mustOK(t, c,
+			"FUNCTION", "DELETE", "mylib",
+		)
+		mustDo(t, c,
+			"FUNCTION", "DELETE", "mylib",
+			proto.Error(msgLibraryNotFound),
+		)
+		mustOK(t, c,
+			"FUNCTION", "RESTORE", payload,
+		)
+		mustDo(t, c,
+			"FCALL", "myget", "1", "foo",
+			proto.String("bar"),
+		)
+		mustDo(t, c,
+			"FUNCTION", "RESTORE", "foo",
+			proto.Error(msgDumpPayload),
+		)
+		mustOK(t, c,
+			"FUNCTION", "FLUSH", "SYNC",
+		)
+		mustDo(t, c,
+			"FUNCTION", "LIST",
+			proto.Array(),
+		)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"FUNCTION", "LOAD", "return 1",
+			proto.Error("ERR Missing library metadata"),
+		)
+		mustDo(t, c,
+			"FUNCTION", "LOAD", "#!lua\nreturn 1",
+			proto.Error("ERR Library name was not given"),
+		)
+		mustDo(t, c,
+			"FUNCTION", "LOAD", "#!lua name=foo\nreturn 1",
+			proto.Error("ERR No functions registered"),
+		)
+		mustContain(t, c,
+			"FUNCTION", "LOAD", "#!lua name=foo\nredis.call('SET', 'a', 'b')",
+			"ERR Error registering functions",
+		)
+		mustDo(t, c,
+			"FUNCTION", "FOO",
+			proto.Error(fmt.Sprintf(msgFFunctionUsage, "FOO")),
+		)
+		mustContain(t, c,
+			"EVAL", "return redis.call('FUNCTION', 'LIST')", "0",
+			msgNotFromScripts,
+		)
+	})
+}
+
+func TestLuaBlocking(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	// these don't block from a script
+	for _, eval := range []string{
+		"redis.call('BLPOP', KEYS[1], 0)",
+		"redis.call('BRPOPLPUSH', KEYS[1], 'dst', 0)",
+		"redis.call('BZPOPMAX', KEYS[1], 0)",
+		"redis.call('XREAD', 'BLOCK', 0, 'STREAMS', KEYS[1], '$')",
+	} {
+		mustDo(t, c,
+			"EVAL", eval+"; return 'done'", "1", "nosuch",
+			proto.String("done"),
+		)
+	}
+
+	s.Push("l", "aap")
+	mustDo(t, c,
+		"EVAL", "return redis.call('BLPOP', KEYS[1], 0)", "1", "l",
+		proto.Strings("l", "aap"),
+	)
+}
+
 func TestCJSON(t *testing.T) {
 	s, err := Run()
 	ok(t, err)
@@ -262,6 +676,161 @@ func TestSha1Hex(t *testing.T) {
 	)
 }
 
+func TestLuaBit(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	test := func(eval string, want int) {
+		t.Helper()
+		mustDo(t, c,
+			"EVAL", eval, "0",
+			proto.Int(want),
+		)
+	}
+	test("return bit.tobit(0xffffffff)", -1)
+	test("return bit.tobit(0xffffffff + 1)", 0)
+	test("return bit.bnot(0)", -1)
+	test("return bit.band(0x12345678, 0xff)", 0x78)
+	test("return bit.bor(1, 2, 4, 8)", 15)
+	test("return bit.bxor(0xa5a5, 0xffff)", 0x5a5a)
+	test("return bit.lshift(1, 31)", -2147483648)
+	test("return bit.lshift(1, 33)", 2)
+	test("return bit.rshift(-1, 28)", 15)
+	test("return bit.arshift(-256, 4)", -16)
+	test("return bit.rol(0x12345678, 12)", 0x45678123)
+	test("return bit.ror(0x12345678, 12)", 0x67812345)
+	test("return bit.bswap(0x12345678)", 0x78563412)
+
+	mustDo(t, c,
+		"EVAL", "return bit.tohex(255)", "0",
+		proto.String("000000ff"),
+	)
+	mustDo(t, c,
+		"EVAL", "return bit.tohex(-1, -4)", "0",
+		proto.String("FFFF"),
+	)
+	mustContain(t, c,
+		"EVAL", "return bit.band('foo')", "0",
+		"number expected",
+	)
+}
+
+func TestLuaStruct(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	mustDo(t, c,
+		"EVAL", "return struct.pack('>HI2b', 258, 3, -1)", "0",
+		proto.String("\x01\x02\x00\x03\xff"),
+	)
+	mustDo(t, c,
+		"EVAL", "return struct.pack('<i', 1)", "0",
+		proto.String("\x01\x00\x00\x00"),
+	)
+	mustDo(t, c,
+		"EVAL", "return struct.pack('!4bi', 1, 2)", "0",
+		proto.String("\x01\x00\x00\x00\x02\x00\x00\x00"),
+	)
+	mustDo(t, c,
+		"EVAL", "return struct.pack('sc3', 'foo', 'barbaz')", "0",
+		proto.String("foo\x00bar"),
+	)
+	mustDo(t, c,
+		"EVAL", "return {struct.unpack('>HI2b', ARGV[1])}", "0", "\x01\x02\x00\x03\xff",
+		proto.Array(proto.Int(258), proto.Int(3), proto.Int(-1), proto.Int(6)),
+	)
+	mustDo(t, c,
+		"EVAL", "return {struct.unpack('Bc0s', ARGV[1])}", "0", "\x03foobar\x00",
+		proto.Array(proto.String("foo"), proto.String("bar"), proto.Int(9)),
+	)
+	mustDo(t, c,
+		"EVAL", "return {struct.unpack('<d', struct.pack('<d', 1.5))}", "0",
+		proto.Array(proto.Int(1), proto.Int(9)), // 1.5 gets truncated
+	)
+	mustDo(t, c,
+		"EVAL", "return struct.size('!4bi')", "0",
+		proto.Int(8),
+	)
+
+	mustContain(t, c,
+		"EVAL", "return struct.unpack('>I', 'ab')", "0",
+		"data string too short",
+	)
+	mustContain(t, c,
+		"EVAL", "return struct.size('s')", "0",
+		"variable-length format",
+	)
+	mustContain(t, c,
+		"EVAL", "return struct.pack('q', 1)", "0",
+		"invalid format option 'q'",
+	)
+	mustContain(t, c,
+		"EVAL", "return struct.pack('!0i', 1)", "0",
+		"alignment 0 is not a power of 2",
+	)
+}
+
+func TestLuaCmsgpack(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	test := func(eval, want string) {
+		t.Helper()
+		mustDo(t, c,
+			"EVAL", eval, "0",
+			proto.String(want),
+		)
+	}
+	test("return cmsgpack.pack(1, -1, 200, -200, 70000)", "\x01\xff\xcc\xc8\xd1\xff\x38\xce\x00\x01\x11\x70")
+	test("return cmsgpack.pack('foo', true, false, nil)", "\xa3foo\xc3\xc2\xc0")
+	test("return cmsgpack.pack(1.5)", "\xca\x3f\xc0\x00\x00")
+	test("return cmsgpack.pack({1, 2, 'a'})", "\x93\x01\x02\xa1a")
+	test("return cmsgpack.pack({foo='bar'})", "\x81\xa3foo\xa3bar")
+	test("return cmsgpack.pack({})", "\x90")
+
+	mustDo(t, c,
+		"EVAL", "return {cmsgpack.unpack(cmsgpack.pack(1, 'foo', {1, 2}))}", "0",
+		proto.Array(proto.Int(1), proto.String("foo"), proto.Ints(1, 2)),
+	)
+	mustDo(t, c,
+		"EVAL", "return cmsgpack.unpack(cmsgpack.pack({foo='bar'})).foo", "0",
+		proto.String("bar"),
+	)
+	mustDo(t, c,
+		"EVAL", "return {cmsgpack.unpack_one(cmsgpack.pack(1, 2), 0)}", "0",
+		proto.Ints(1, 1),
+	)
+	mustDo(t, c,
+		"EVAL", "return {cmsgpack.unpack_limit(cmsgpack.pack(1, 2, 3), 5)}", "0",
+		proto.Ints(-1, 1, 2, 3),
+	)
+
+	mustContain(t, c,
+		"EVAL", "return cmsgpack.unpack('\\205')", "0",
+		"Missing bytes in input.",
+	)
+	mustContain(t, c,
+		"EVAL", "return cmsgpack.unpack('\\193')", "0",
+		"Bad data format in input.",
+	)
+	mustContain(t, c,
+		"EVAL", "return cmsgpack.pack()", "0",
+		"MessagePack pack needs input.",
+	)
+}
+
 func TestEvalsha(t *testing.T) {
 	s, err := Run()
 	ok(t, err)
@@ -494,6 +1063,50 @@ func TestCmdEvalResponse(t *testing.T) {
 		"EVAL", "return redis.call('HMGET','mkey', 'bad', 'key')", "0",
 		proto.Array(proto.Nil, proto.Nil),
 	)
+
+	t.Run("status", func(t *testing.T) {
+		mustDo(t, c,
+			"EVAL", "return redis.call('SET','foo','bar').ok", "0",
+			proto.String("OK"),
+		)
+		mustDo(t, c,
+			"EVAL", "return 'OK'", "0",
+			proto.String("OK"),
+		)
+		mustDo(t, c,
+			"EVAL", "return redis.call('PING')", "0",
+			proto.Inline("PONG"),
+		)
+	})
+
+	t.Run("pcall", func(t *testing.T) {
+		mustDo(t, c,
+			"EVAL", "return type(redis.pcall('INCR','foo'))", "0",
+			proto.String("table"),
+		)
+		mustDo(t, c,
+			"EVAL", "return redis.pcall('INCR','foo').err", "0",
+			proto.String(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"EVAL", "return redis.pcall('INCR','foo')", "0",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"EVAL", "return redis.pcall('NOSUCH')", "0",
+			proto.Error("Unknown Redis command called from Lua script"),
+		)
+	})
+
+	t.Run("log", func(t *testing.T) {
+		mustNil(t, c,
+			"EVAL", "redis.log(redis.LOG_WARNING, 'hello')", "0",
+		)
+		mustDo(t, c,
+			"EVAL", "return {redis.LOG_DEBUG, redis.LOG_VERBOSE, redis.LOG_NOTICE, redis.LOG_WARNING}", "0",
+			proto.Ints(0, 1, 2, 3),
+		)
+	})
 }
 
 func TestCmdEvalAuth(t *testing.T) {
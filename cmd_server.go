@@ -3,19 +3,126 @@
 package miniredis
 
 import (
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alicebob/miniredis/v2/server"
 )
 
 func commandsServer(m *Miniredis) {
+	m.srv.Register("CONFIG", m.cmdConfig)
 	m.srv.Register("DBSIZE", m.cmdDbsize)
+	m.srv.Register("DEBUG", m.cmdDebug)
 	m.srv.Register("FLUSHALL", m.cmdFlushall)
 	m.srv.Register("FLUSHDB", m.cmdFlushdb)
+	m.srv.Register("INFO", m.cmdInfo)
+	m.srv.Register("SHUTDOWN", m.cmdShutdown)
 	m.srv.Register("TIME", m.cmdTime)
 }
 
+// CONFIG
+func (m *Miniredis) cmdConfig(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	subcmd := strings.ToUpper(args[0])
+	switch {
+	case subcmd == "GET" && len(args) >= 2:
+	case subcmd == "SET" && len(args) >= 3 && len(args)%2 == 1:
+	case subcmd == "RESETSTAT" && len(args) == 1:
+	default:
+		setDirty(c)
+		c.WriteError(fmt.Sprintf(msgFConfigUsage, args[0]))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	switch subcmd {
+	case "GET":
+		m.cmdConfigGet(c, args[1:])
+	case "SET":
+		m.cmdConfigSet(c, args[1:])
+	case "RESETSTAT":
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			m.srv.ResetStats()
+			c.WriteOK()
+		})
+	}
+}
+
+// CONFIG GET
+func (m *Miniredis) cmdConfigGet(c *server.Peer, patterns []string) {
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		var res []string
+		for _, p := range configParams {
+			for _, pat := range patterns {
+				pat = strings.ToLower(pat)
+				// aliases only show up when asked for by name
+				name := p.name
+				if p.alias != "" && p.alias == pat {
+					name = p.alias
+				} else if !patternMatch(pat, p.name) {
+					continue
+				}
+				res = append(res, name, p.get(m))
+				break
+			}
+		}
+		c.WriteLen(len(res))
+		for _, v := range res {
+			c.WriteBulk(v)
+		}
+	})
+}
+
+// CONFIG SET
+func (m *Miniredis) cmdConfigSet(c *server.Peer, args []string) {
+	var params []*configParam
+	for i := 0; i < len(args); i += 2 {
+		p := findConfigParam(args[i])
+		if p == nil {
+			setDirty(c)
+			c.WriteError(fmt.Sprintf(msgFConfigSetUnknown, args[i]))
+			return
+		}
+		for _, other := range params {
+			if other == p {
+				setDirty(c)
+				c.WriteError(fmt.Sprintf(msgFConfigSetFailed, args[i], "duplicate parameter"))
+				return
+			}
+		}
+		params = append(params, p)
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		// all or nothing
+		config, policy, notify := m.config, m.maxmemoryPolicy, m.notifyFlags
+		for i, p := range params {
+			if err := p.set(m, args[2*i+1]); err != nil {
+				m.config, m.maxmemoryPolicy, m.notifyFlags = config, policy, notify
+				c.WriteError(fmt.Sprintf(msgFConfigSetFailed, args[2*i], err))
+				return
+			}
+		}
+		c.WriteOK()
+	})
+}
+
 // DBSIZE
 func (m *Miniredis) cmdDbsize(c *server.Peer, cmd string, args []string) {
 	if len(args) > 0 {
@@ -37,6 +144,97 @@ func (m *Miniredis) cmdDbsize(c *server.Peer, cmd string, args []string) {
 	})
 }
 
+// DEBUG
+func (m *Miniredis) cmdDebug(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	subcmd := strings.ToUpper(args[0])
+	switch {
+	case subcmd == "OBJECT" && len(args) == 2:
+	case subcmd == "SLEEP" && len(args) == 2:
+	default:
+		setDirty(c)
+		c.WriteError(fmt.Sprintf(msgFDebugUsage, args[0]))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	if subcmd == "SLEEP" {
+		m.cmdDebugSleep(c, args[1])
+		return
+	}
+
+	key := args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteError(msgKeyNotFound)
+			return
+		}
+		c.WriteInline(fmt.Sprintf(
+			"Value at:0x0 refcount:1 encoding:%s serializedlength:0 lru:0 lru_seconds_idle:0",
+			db.encoding(key),
+		))
+	})
+}
+
+// DEBUG SLEEP. Only the connection sleeps, unless SetDebugSleepBlocking() is
+// set, or the command is part of a MULTI or a script. Huge values sleep for
+// the longest time.Duration, a Close() cuts any sleep short.
+func (m *Miniredis) cmdDebugSleep(c *server.Peer, arg string) {
+	secs, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(secs) || math.IsInf(secs, 0) {
+		setDirty(c)
+		c.WriteError(msgInvalidFloat)
+		return
+	}
+	d := time.Duration(math.MaxInt64)
+	if ns := secs * float64(time.Second); ns < float64(d) {
+		d = time.Duration(ns)
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		if m.sleepBlocking || ctx.nested || inTx(ctx) {
+			m.sleep(d)
+			c.WriteOK()
+			return
+		}
+
+		m.Unlock()
+		m.sleep(d)
+		m.Lock()
+		c.WriteOK()
+	})
+}
+
+// sleep waits for d, or until Close(). Close() doesn't need the lock to stop
+// it, so this can be called with m locked.
+func (m *Miniredis) sleep(d time.Duration) {
+	m.sleepMu.Lock()
+	if m.sleepStop == nil {
+		m.sleepStop = make(chan struct{})
+	}
+	stop := m.sleepStop
+	m.sleepMu.Unlock()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-stop:
+	}
+}
+
 // FLUSHALL
 func (m *Miniredis) cmdFlushall(c *server.Peer, cmd string, args []string) {
 	if len(args) > 0 && strings.ToLower(args[0]) == "async" {
@@ -56,6 +254,7 @@ func (m *Miniredis) cmdFlushall(c *server.Peer, cmd string, args []string) {
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		m.flushAll()
+		m.trackingFlush()
 		c.WriteOK()
 	})
 }
@@ -79,10 +278,75 @@ func (m *Miniredis) cmdFlushdb(c *server.Peer, cmd string, args []string) {
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		m.db(ctx.selectedDB).flush()
+		m.trackingFlush()
 		c.WriteOK()
 	})
 }
 
+// INFO
+func (m *Miniredis) cmdInfo(c *server.Peer, cmd string, args []string) {
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	sections := selectInfoSections(args)
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		c.WriteBulk(m.info(sections))
+	})
+}
+
+// SHUTDOWN
+// Miniredis keeps running. This stops a script set with SetBusyScript(), and
+// closes the connection.
+func (m *Miniredis) cmdShutdown(c *server.Peer, cmd string, args []string) {
+	var opts struct {
+		nosave bool
+		abort  bool
+	}
+	for _, arg := range args {
+		switch strings.ToUpper(arg) {
+		case "NOSAVE":
+			opts.nosave = true
+		case "SAVE", "NOW", "FORCE":
+		case "ABORT":
+			opts.abort = true
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		if opts.abort {
+			c.WriteError(msgNoShutdown)
+			return
+		}
+		if b := m.busy; b != nil {
+			if !opts.nosave {
+				c.WriteError(b.busyErr())
+				return
+			}
+			m.stopBusy()
+		}
+		c.Close()
+	})
+}
+
 // TIME
 func (m *Miniredis) cmdTime(c *server.Peer, cmd string, args []string) {
 	if len(args) > 0 {
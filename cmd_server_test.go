@@ -1,6 +1,8 @@
 package miniredis
 
 import (
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -127,3 +129,677 @@ func TestCmdServerTime(t *testing.T) {
 		proto.Error(errWrongNumber("time")),
 	)
 }
+
+func TestCmdServerShutdown(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	mustDo(t, c,
+		"SHUTDOWN", "ABORT",
+		proto.Error(msgNoShutdown),
+	)
+	mustDo(t, c,
+		"SHUTDOWN", "FOO",
+		proto.Error(msgSyntaxError),
+	)
+	_, err = c.Do("SHUTDOWN")
+	mustFail(t, err, "EOF")
+}
+
+// Test INFO
+func TestCmdServerInfo(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.Set("foo", "bar")
+	s.Set("baz", "bak")
+	s.SetTTL("baz", 10*time.Second)
+	s.DB(2).Set("aap", "noot")
+	s.SetReplicas(2)
+
+	info := func(args ...string) string {
+		t.Helper()
+		res, err := c.Do(append([]string{"INFO"}, args...)...)
+		ok(t, err)
+		str, err := proto.ReadString(res)
+		ok(t, err)
+		return str
+	}
+	// the section headers
+	headers := func(str string) []string {
+		var res []string
+		for _, l := range strings.Split(str, "\r\n") {
+			if strings.HasPrefix(l, "# ") {
+				res = append(res, l)
+			}
+		}
+		return res
+	}
+
+	t.Run("default", func(t *testing.T) {
+		str := info()
+		for _, want := range []string{
+			"# Server\r\nredis_version:6.0.5\r\n",
+			"\r\n\r\n# Clients\r\nconnected_clients:1\r\n",
+			"\r\n# Memory\r\n",
+			"\r\nmaxmemory_policy:noeviction\r\n",
+			"\r\naof_enabled:0\r\n",
+			"\r\n# Stats\r\n",
+			"\r\nrole:master\r\nconnected_slaves:2\r\n",
+			"\r\n# CPU\r\n",
+			"\r\n# Keyspace\r\ndb0:keys=2,expires=1,avg_ttl=10000\r\ndb2:keys=1,expires=0,avg_ttl=0\r\n",
+		} {
+			if !strings.Contains(str, want) {
+				t.Errorf("missing %q in %q", want, str)
+			}
+		}
+		equals(t, []string{"# Server", "# Clients", "# Memory", "# Persistence", "# Stats", "# Replication", "# CPU", "# Keyspace"}, headers(str))
+		equals(t, headers(str), headers(info("default")))
+	})
+
+	t.Run("sections", func(t *testing.T) {
+		equals(t, "# Keyspace\r\ndb0:keys=2,expires=1,avg_ttl=10000\r\ndb2:keys=1,expires=0,avg_ttl=0\r\n", info("keyspace"))
+		equals(t, "# Replication\r\nrole:master\r\nconnected_slaves:2\r\nmaster_failover_state:no-failover\r\nmaster_repl_offset:0\r\nrepl_backlog_active:0\r\n", info("REPLICATION"))
+		equals(t, "", info("nosuch"))
+
+		// standard order, no matter the argument order
+		str := info("keyspace", "server")
+		equals(t, []string{"# Server", "# Keyspace"}, headers(str))
+		if !strings.HasSuffix(str, "\r\n\r\n# Keyspace\r\ndb0:keys=2,expires=1,avg_ttl=10000\r\ndb2:keys=1,expires=0,avg_ttl=0\r\n") {
+			t.Errorf("unexpected INFO: %q", str)
+		}
+		all := []string{"# Server", "# Clients", "# Memory", "# Persistence", "# Stats", "# Replication", "# CPU", "# Commandstats", "# Latencystats", "# Keyspace"}
+		equals(t, all, headers(info("all")))
+		equals(t, all, headers(info("everything")))
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		s.SetAppendOnly(true)
+		mustContain(t, c, "INFO", "persistence", "aof_enabled:1")
+		mustContain(t, c, "INFO", "memory", "used_memory:")
+		mustContain(t, c, "INFO", "stats", "total_connections_received:1\r\n")
+	})
+
+	t.Run("multi", func(t *testing.T) {
+		mustOK(t, c, "MULTI")
+		mustDo(t, c, "INFO", "cpu", proto.Inline("QUEUED"))
+		mustDo(t, c,
+			"EXEC",
+			proto.Strings("# CPU\r\nused_cpu_sys:0.000000\r\nused_cpu_user:0.000000\r\nused_cpu_sys_children:0.000000\r\nused_cpu_user_children:0.000000\r\n"),
+		)
+	})
+
+	t.Run("commandstats", func(t *testing.T) {
+		mustOK(t, c, "CONFIG", "RESETSTAT")
+		mustContain(t, c, "INFO", "stats", "total_commands_processed:1\r\n")
+		mustDo(t, c, "GET", "foo", proto.String("bar"))
+		mustDo(t, c, "GET", "foo", proto.String("bar"))
+		mustDo(t, c, "GET", proto.Error(errWrongNumber("get")))
+		mustDo(t, c, "NOSUCH", proto.Error("ERR unknown command `NOSUCH`, with args beginning with: "))
+
+		str := info("commandstats")
+		equals(t, []string{"# Commandstats"}, headers(str))
+		lines := strings.Split(strings.TrimSuffix(str, "\r\n"), "\r\n")[1:]
+		equals(t, 3, len(lines))
+		// usec varies
+		if !strings.HasPrefix(lines[0], "cmdstat_config:calls=1,usec=") ||
+			!strings.HasPrefix(lines[1], "cmdstat_get:calls=3,usec=") ||
+			!strings.HasSuffix(lines[1], ",rejected_calls=0,failed_calls=1") ||
+			!strings.HasPrefix(lines[2], "cmdstat_info:calls=1,usec=") {
+			t.Errorf("unexpected commandstats: %q", lines)
+		}
+		mustContain(t, c, "INFO", "latencystats", "\r\nlatency_percentiles_usec_get:p50=")
+
+		mustOK(t, c, "CONFIG", "RESETSTAT")
+		str = info("commandstats")
+		if !strings.HasPrefix(str, "# Commandstats\r\ncmdstat_config:calls=1,usec=") || strings.Count(str, "\r\n") != 2 {
+			t.Errorf("unexpected commandstats: %q", str)
+		}
+	})
+
+	t.Run("commandstats MULTI and blocking", func(t *testing.T) {
+		mustOK(t, c, "CONFIG", "RESETSTAT")
+		// queued commands count when they run
+		mustOK(t, c, "MULTI")
+		mustDo(t, c, "GET", "foo", proto.Inline("QUEUED"))
+		mustDo(t, c, "GET", "foo", proto.Inline("QUEUED"))
+		mustDo(t, c, "EXEC", proto.Strings("bar", "bar"))
+		// waiting isn't latency
+		mustDo(t, c, "BZPOPMIN", "nosuch", "0.2", proto.NilList)
+
+		str := info("commandstats")
+		lines := strings.Split(strings.TrimSuffix(str, "\r\n"), "\r\n")[1:]
+		equals(t, 5, len(lines))
+		if !strings.HasPrefix(lines[0], "cmdstat_bzpopmin:calls=1,usec=") ||
+			!strings.HasPrefix(lines[2], "cmdstat_exec:calls=1,usec=") ||
+			!strings.HasPrefix(lines[3], "cmdstat_get:calls=2,usec=") ||
+			!strings.HasPrefix(lines[4], "cmdstat_multi:calls=1,usec=") {
+			t.Errorf("unexpected commandstats: %q", lines)
+		}
+		usec, err := strconv.Atoi(strings.Split(strings.TrimPrefix(lines[0], "cmdstat_bzpopmin:calls=1,usec="), ",")[0])
+		ok(t, err)
+		assert(t, usec < 100000, "BZPOPMIN latency without the wait: %d", usec)
+		mustContain(t, c, "INFO", "stats", "total_commands_processed:7\r\n")
+	})
+}
+
+// Test a DEBUG SLEEP which won't end before Close()
+func TestCmdServerDebugSleepClose(t *testing.T) {
+	test := func(t *testing.T, blocking bool, cmds ...[]string) {
+		s, err := Run()
+		ok(t, err)
+		s.SetDebugSleepBlocking(blocking)
+		c, err := proto.Dial(s.Addr())
+		ok(t, err)
+		defer c.Close()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for _, cmd := range cmds {
+				c.Do(cmd...)
+			}
+		}()
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case <-done:
+			t.Fatal("DEBUG SLEEP didn't sleep")
+		default:
+		}
+
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			s.Close()
+		}()
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("Close() waits for DEBUG SLEEP")
+		}
+		<-done
+	}
+
+	sleep := []string{"DEBUG", "SLEEP", "1e30"}
+	t.Run("connection", func(t *testing.T) {
+		test(t, false, sleep)
+	})
+	t.Run("blocking", func(t *testing.T) {
+		test(t, true, sleep)
+	})
+	t.Run("MULTI", func(t *testing.T) {
+		test(t, false, []string{"MULTI"}, sleep, []string{"EXEC"})
+	})
+}
+
+// Test DEBUG
+func TestCmdServerDebug(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	_, err = s.XAdd("planets", "*", []string{"name", "Mercury"})
+	ok(t, err)
+	mustDo(t, c,
+		"DEBUG", "OBJECT", "planets",
+		proto.Inline("Value at:0x0 refcount:1 encoding:stream serializedlength:0 lru:0 lru_seconds_idle:0"),
+	)
+	mustDo(t, c,
+		"DEBUG", "OBJECT", "nosuch",
+		proto.Error(msgKeyNotFound),
+	)
+	mustDo(t, c,
+		"DEBUG",
+		proto.Error(errWrongNumber("debug")),
+	)
+	mustDo(t, c,
+		"DEBUG", "FOO",
+		proto.Error("ERR Unknown subcommand or wrong number of arguments for 'FOO'. Try DEBUG HELP."),
+	)
+
+	t.Run("sleep", func(t *testing.T) {
+		c2, err := proto.Dial(s.Addr())
+		ok(t, err)
+		defer c2.Close()
+
+		// sleep calls DEBUG SLEEP on c, and gives how long a PING on c2 took
+		// while c was sleeping.
+		sleep := func() time.Duration {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				mustOK(t, c, "DEBUG", "SLEEP", "0.2")
+			}()
+			time.Sleep(50 * time.Millisecond)
+			t0 := time.Now()
+			mustDo(t, c2, "PING", proto.Inline("PONG"))
+			d := time.Since(t0)
+			<-done
+			return d
+		}
+
+		t0 := time.Now()
+		mustOK(t, c, "DEBUG", "SLEEP", "0.1")
+		assert(t, time.Since(t0) >= 100*time.Millisecond, "slept")
+		mustOK(t, c, "DEBUG", "SLEEP", "0")
+
+		assert(t, sleep() < 100*time.Millisecond, "only the connection sleeps")
+
+		s.SetDebugSleepBlocking(true)
+		assert(t, sleep() >= 100*time.Millisecond, "the server sleeps")
+		s.SetDebugSleepBlocking(false)
+
+		mustOK(t, c, "DEBUG", "SLEEP", "-1")
+		mustDo(t, c,
+			"DEBUG", "SLEEP", "foo",
+			proto.Error(msgInvalidFloat),
+		)
+		mustDo(t, c,
+			"DEBUG", "SLEEP",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'SLEEP'. Try DEBUG HELP."),
+		)
+	})
+}
+
+func TestCmdServerConfig(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	mustDo(t, c,
+		"CONFIG", "GET", "hash-max-listpack-entries",
+		proto.Strings("hash-max-listpack-entries", "128"),
+	)
+	mustDo(t, c,
+		"CONFIG", "GET", "zset-*",
+		proto.Strings(
+			"zset-max-listpack-entries", "128",
+			"zset-max-listpack-value", "64",
+		),
+	)
+	mustDo(t, c,
+		"CONFIG", "GET", "hash-max-ziplist-entries", "MAXMEMORY-POLICY",
+		proto.Strings(
+			"hash-max-ziplist-entries", "128",
+			"maxmemory-policy", "noeviction",
+		),
+	)
+	mustDo(t, c,
+		"CONFIG", "GET", "nosuch",
+		proto.Strings(),
+	)
+
+	t.Run("hash", func(t *testing.T) {
+		s.HSet("hash", "aap", "noot")
+		s.HSet("hash", "noot", "mies")
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "hash",
+			proto.String("listpack"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "hash-max-listpack-entries", "1")
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "hash",
+			proto.String("hashtable"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "hash-max-ziplist-entries", "2", "hash-max-listpack-value", "3")
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "hash",
+			proto.String("hashtable"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "hash-max-listpack-value", "4")
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "hash",
+			proto.String("listpack"),
+		)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		s.Push("list", "aap", "noot", "mies")
+		mustOK(t, c, "CONFIG", "SET", "list-max-listpack-size", "3")
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "list",
+			proto.String("listpack"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "list-max-listpack-size", "2")
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "list",
+			proto.String("quicklist"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "list-max-listpack-size", "-1")
+		s.Push("biglist", string(make([]byte, 4097)))
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "biglist",
+			proto.String("quicklist"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "list-max-listpack-size", "-9")
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "biglist",
+			proto.String("listpack"),
+		)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		s.SetAdd("set", "1", "2", "3")
+		mustOK(t, c, "CONFIG", "SET", "set-max-intset-entries", "2")
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "set",
+			proto.String("listpack"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "set-max-listpack-entries", "2")
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "set",
+			proto.String("hashtable"),
+		)
+	})
+
+	t.Run("zset", func(t *testing.T) {
+		s.ZAdd("zset", 1, "aap")
+		mustOK(t, c, "CONFIG", "SET", "zset-max-listpack-value", "2")
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "zset",
+			proto.String("skiplist"),
+		)
+	})
+
+	t.Run("maxmemory-policy", func(t *testing.T) {
+		mustOK(t, c, "CONFIG", "SET", "maxmemory-policy", "allkeys-LFU")
+		mustDo(t, c,
+			"CONFIG", "GET", "maxmemory-policy",
+			proto.Strings("maxmemory-policy", "allkeys-lfu"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "maxmemory-policy", "noeviction")
+	})
+
+	t.Run("notify-keyspace-events", func(t *testing.T) {
+		mustDo(t, c,
+			"CONFIG", "GET", "notify-keyspace-events",
+			proto.Strings("notify-keyspace-events", ""),
+		)
+		mustOK(t, c, "CONFIG", "SET", "notify-keyspace-events", "KEA")
+		mustDo(t, c,
+			"CONFIG", "GET", "notify-keyspace-events",
+			proto.Strings("notify-keyspace-events", "AKE"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "notify-keyspace-events", "lEg$")
+		mustDo(t, c,
+			"CONFIG", "GET", "notify-keyspace-events",
+			proto.Strings("notify-keyspace-events", "g$lE"),
+		)
+		mustDo(t, c,
+			"CONFIG", "SET", "notify-keyspace-events", "Eq",
+			proto.Error("ERR CONFIG SET failed (possibly related to argument 'notify-keyspace-events') - Invalid event class character. Use 'Ag$lshzxeKEtmdn'."),
+		)
+		mustDo(t, c,
+			"CONFIG", "GET", "notify-keyspace-events",
+			proto.Strings("notify-keyspace-events", "g$lE"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "notify-keyspace-events", "")
+	})
+
+	t.Run("maxmemory", func(t *testing.T) {
+		mustDo(t, c,
+			"CONFIG", "GET", "maxmemory",
+			proto.Strings("maxmemory", "0"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "maxmemory", "10mb")
+		mustDo(t, c,
+			"CONFIG", "GET", "maxmemory",
+			proto.Strings("maxmemory", "10485760"),
+		)
+		mustContain(t, c, "INFO", "memory", "maxmemory_human:10.00M")
+		mustOK(t, c, "CONFIG", "SET", "maxmemory", "2K")
+		mustDo(t, c,
+			"CONFIG", "GET", "maxmemory",
+			proto.Strings("maxmemory", "2000"),
+		)
+		mustDo(t, c,
+			"CONFIG", "SET", "maxmemory", "lots",
+			proto.Error("ERR CONFIG SET failed (possibly related to argument 'maxmemory') - argument must be a memory value"),
+		)
+		mustDo(t, c,
+			"CONFIG", "SET", "maxmemory", "99999999999gb",
+			proto.Error("ERR CONFIG SET failed (possibly related to argument 'maxmemory') - argument must be a memory value"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "maxmemory", "0")
+	})
+
+	t.Run("timeout, maxclients, and save", func(t *testing.T) {
+		mustDo(t, c,
+			"CONFIG", "GET", "timeout", "maxclients", "save",
+			proto.Strings(
+				"maxclients", "10000",
+				"timeout", "0",
+				"save", "3600 1 300 100 60 10000",
+			),
+		)
+		mustOK(t, c, "CONFIG", "SET", "timeout", "300", "maxclients", "20", "save", " 900  1 ")
+		mustDo(t, c,
+			"CONFIG", "GET", "timeout", "maxclients", "save",
+			proto.Strings(
+				"maxclients", "20",
+				"timeout", "300",
+				"save", "900 1",
+			),
+		)
+		mustContain(t, c, "INFO", "clients", "maxclients:20")
+		mustDo(t, c,
+			"CONFIG", "SET", "save", "900",
+			proto.Error("ERR CONFIG SET failed (possibly related to argument 'save') - Invalid save parameters"),
+		)
+		mustDo(t, c,
+			"CONFIG", "SET", "maxclients", "0",
+			proto.Error("ERR CONFIG SET failed (possibly related to argument 'maxclients') - argument must be between 1 and 9223372036854775807 inclusive"),
+		)
+		mustOK(t, c, "CONFIG", "SET", "save", "")
+		mustDo(t, c,
+			"CONFIG", "GET", "save",
+			proto.Strings("save", ""),
+		)
+	})
+
+	t.Run("immutable", func(t *testing.T) {
+		mustDo(t, c,
+			"CONFIG", "GET", "databases",
+			proto.Strings("databases", "16"),
+		)
+		mustDo(t, c,
+			"CONFIG", "GET", "port",
+			proto.Strings("port", s.Port()),
+		)
+		mustDo(t, c,
+			"CONFIG", "SET", "databases", "8",
+			proto.Error("ERR CONFIG SET failed (possibly related to argument 'databases') - can't set immutable config"),
+		)
+		// all or nothing
+		mustDo(t, c,
+			"CONFIG", "SET", "timeout", "10", "port", "1234",
+			proto.Error("ERR CONFIG SET failed (possibly related to argument 'port') - can't set immutable config"),
+		)
+		mustDo(t, c,
+			"CONFIG", "GET", "timeout",
+			proto.Strings("timeout", "300"),
+		)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"CONFIG",
+			proto.Error(errWrongNumber("config")),
+		)
+		mustDo(t, c,
+			"CONFIG", "GET",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'GET'. Try CONFIG HELP."),
+		)
+		mustDo(t, c,
+			"CONFIG", "SET", "hash-max-listpack-entries",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'SET'. Try CONFIG HELP."),
+		)
+		mustDo(t, c,
+			"CONFIG", "FOO",
+			proto.Error("ERR Unknown subcommand or wrong number of arguments for 'FOO'. Try CONFIG HELP."),
+		)
+		mustDo(t, c,
+			"CONFIG", "SET", "nosuch", "1",
+			proto.Error("ERR Unknown option or number of arguments for CONFIG SET - 'nosuch'"),
+		)
+		mustDo(t, c,
+			"CONFIG", "SET", "hash-max-listpack-entries", "1", "hash-max-ziplist-entries", "2",
+			proto.Error("ERR CONFIG SET failed (possibly related to argument 'hash-max-ziplist-entries') - duplicate parameter"),
+		)
+		mustDo(t, c,
+			"CONFIG", "SET", "zset-max-listpack-entries", "foo",
+			proto.Error("ERR CONFIG SET failed (possibly related to argument 'zset-max-listpack-entries') - argument couldn't be parsed into an integer"),
+		)
+		mustDo(t, c,
+			"CONFIG", "SET", "zset-max-listpack-entries", "-1",
+			proto.Error("ERR CONFIG SET failed (possibly related to argument 'zset-max-listpack-entries') - argument must be between 0 and 9223372036854775807 inclusive"),
+		)
+		mustContain(t, c,
+			"CONFIG", "SET", "maxmemory-policy", "foo",
+			"argument(s) must be one of the following",
+		)
+
+		// nothing changes when any of the values is wrong
+		mustContain(t, c,
+			"CONFIG", "SET", "zset-max-listpack-entries", "12", "maxmemory-policy", "foo",
+			"argument(s) must be one of the following",
+		)
+		mustDo(t, c,
+			"CONFIG", "GET", "zset-max-listpack-entries",
+			proto.Strings("zset-max-listpack-entries", "128"),
+		)
+	})
+}
+
+func TestCmdServerMaxmemory(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	oom := proto.Error("OOM command not allowed when used memory > 'maxmemory'.")
+	big := strings.Repeat("x", 200)
+
+	mustOK(t, c, "CONFIG", "SET", "maxmemory", "100")
+	// the first one goes in, we're not over the limit yet
+	mustOK(t, c, "SET", "big", big)
+	mustDo(t, c, "SET", "more", big, oom)
+	mustDo(t, c, "APPEND", "big", "y", oom)
+	mustDo(t, c, "GET", "big", proto.String(big))
+
+	t.Run("MULTI", func(t *testing.T) {
+		mustOK(t, c, "MULTI")
+		mustDo(t, c, "SET", "more", big, oom)
+		mustDo(t, c,
+			"EXEC",
+			proto.Error("EXECABORT Transaction discarded because of previous errors."),
+		)
+	})
+
+	t.Run("eviction policy", func(t *testing.T) {
+		// we never evict, but we don't refuse either
+		mustOK(t, c, "CONFIG", "SET", "maxmemory-policy", "allkeys-lru")
+		mustOK(t, c, "SET", "more", big)
+		mustOK(t, c, "CONFIG", "SET", "maxmemory-policy", "noeviction")
+		mustDo(t, c, "SET", "evenmore", big, oom)
+	})
+
+	// deletes are fine, and make room
+	mustDo(t, c, "DEL", "big", "more", proto.Int(2))
+	mustOK(t, c, "SET", "more", big)
+	mustDo(t, c, "SET", "evenmore", big, oom)
+
+	// so does a smaller value, also via the direct API
+	s.Set("more", "x")
+	mustOK(t, c, "SET", "evenmore", "y")
+	mustOK(t, c, "SET", "more", big)
+	mustDo(t, c, "SET", "evenmore", big, oom)
+	mustOK(t, c, "FLUSHALL")
+	mustOK(t, c, "SET", "more", big)
+
+	mustOK(t, c, "CONFIG", "SET", "maxmemory", "0")
+	mustOK(t, c, "SET", "evenmore", big)
+}
+
+func TestCmdServerMaxclients(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	mustOK(t, c, "CONFIG", "SET", "maxclients", "2")
+
+	c2, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c2.Close()
+	mustDo(t, c2, "PING", proto.Inline("PONG"))
+
+	c3, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c3.Close()
+	mustRead(t, c3, proto.Error("ERR max number of clients reached"))
+	_, err = c3.Do("PING")
+	assert(t, err != nil, "connection closed")
+
+	mustDo(t, c2, "PING", proto.Inline("PONG"))
+}
+
+func TestCmdServerTimeout(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+	c2, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c2.Close()
+	sub, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer sub.Close()
+
+	mustDo(t, sub,
+		"SUBSCRIBE", "news",
+		proto.Array(
+			proto.String("subscribe"),
+			proto.String("news"),
+			proto.Int(1),
+		),
+	)
+	mustOK(t, c, "CONFIG", "SET", "timeout", "10")
+	mustDo(t, c2, "PING", proto.Inline("PONG"))
+
+	s.FastForward(5 * time.Second)
+	mustDo(t, c2, "PING", proto.Inline("PONG"))
+	s.FastForward(6 * time.Second)
+
+	// c has been idle for 11 seconds, c2 for 6
+	_, err = c.Do("PING")
+	assert(t, err != nil, "connection closed")
+	mustDo(t, c2, "PING", proto.Inline("PONG"))
+
+	// subscribers don't time out
+	s.FastForward(time.Minute)
+	_, err = c2.Do("PING")
+	assert(t, err != nil, "connection closed")
+	equals(t, 1, s.Publish("news", "hello"))
+	mustRead(t, sub, proto.Strings("message", "news", "hello"))
+}
@@ -19,6 +19,7 @@ func commandsSet(m *Miniredis) {
 	m.srv.Register("SINTERSTORE", m.cmdSinterstore)
 	m.srv.Register("SISMEMBER", m.cmdSismember)
 	m.srv.Register("SMEMBERS", m.cmdSmembers)
+	m.srv.Register("SMISMEMBER", m.cmdSmismember)
 	m.srv.Register("SMOVE", m.cmdSmove)
 	m.srv.Register("SPOP", m.cmdSpop)
 	m.srv.Register("SRANDMEMBER", m.cmdSrandmember)
@@ -53,6 +54,9 @@ func (m *Miniredis) cmdSadd(c *server.Peer, cmd string, args []string) {
 		}
 
 		added := db.setAdd(key, elems...)
+		if added > 0 {
+			m.notify(db.id, notifySet, "sadd", key)
+		}
 		c.WriteInt(added)
 	})
 }
@@ -148,8 +152,14 @@ func (m *Miniredis) cmdSdiffstore(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
+		existed := db.exists(dest)
 		db.del(dest, true)
 		db.setSet(dest, set)
+		if len(set) > 0 {
+			m.notify(db.id, notifySet, "sdiffstore", dest)
+		} else if existed {
+			m.notify(db.id, notifyGeneric, "del", dest)
+		}
 		c.WriteInt(len(set))
 	})
 }
@@ -211,8 +221,14 @@ func (m *Miniredis) cmdSinterstore(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
+		existed := db.exists(dest)
 		db.del(dest, true)
 		db.setSet(dest, set)
+		if len(set) > 0 {
+			m.notify(db.id, notifySet, "sinterstore", dest)
+		} else if existed {
+			m.notify(db.id, notifyGeneric, "del", dest)
+		}
 		c.WriteInt(len(set))
 	})
 }
@@ -254,6 +270,41 @@ func (m *Miniredis) cmdSismember(c *server.Peer, cmd string, args []string) {
 	})
 }
 
+// SMISMEMBER
+func (m *Miniredis) cmdSmismember(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, values := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "set" {
+			c.WriteError(ErrWrongType.Error())
+			return
+		}
+
+		c.WriteLen(len(values))
+		for _, v := range values {
+			if db.setIsMember(key, v) {
+				c.WriteInt(1)
+			} else {
+				c.WriteInt(0)
+			}
+		}
+	})
+}
+
 // SMEMBERS
 func (m *Miniredis) cmdSmembers(c *server.Peer, cmd string, args []string) {
 	if len(args) != 1 {
@@ -332,6 +383,11 @@ func (m *Miniredis) cmdSmove(c *server.Peer, cmd string, args []string) {
 		}
 		db.setRem(src, member)
 		db.setAdd(dst, member)
+		if src != dst {
+			m.notify(db.id, notifySet, "srem", src)
+			m.notifyDeleted(db, src)
+			m.notify(db.id, notifySet, "sadd", dst)
+		}
 		c.WriteInt(1)
 	})
 }
@@ -403,6 +459,10 @@ func (m *Miniredis) cmdSpop(c *server.Peer, cmd string, args []string) {
 			db.setRem(key, member)
 			deleted = append(deleted, member)
 		}
+		if len(deleted) > 0 {
+			m.notify(db.id, notifySet, "spop", key)
+			m.notifyDeleted(db, key)
+		}
 		// without `count` return a single value...
 		if !withCount {
 			if len(deleted) == 0 {
@@ -457,6 +517,10 @@ func (m *Miniredis) cmdSrandmember(c *server.Peer, cmd string, args []string) {
 		db := m.db(ctx.selectedDB)
 
 		if !db.exists(key) {
+			if withCount {
+				c.WriteLen(0)
+				return
+			}
 			c.WriteNull()
 			return
 		}
@@ -523,7 +587,12 @@ func (m *Miniredis) cmdSrem(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
-		c.WriteInt(db.setRem(key, fields...))
+		n := db.setRem(key, fields...)
+		if n > 0 {
+			m.notify(db.id, notifySet, "srem", key)
+			m.notifyDeleted(db, key)
+		}
+		c.WriteInt(n)
 	})
 }
 
@@ -584,8 +653,14 @@ func (m *Miniredis) cmdSunionstore(c *server.Peer, cmd string, args []string) {
 			return
 		}
 
+		existed := db.exists(dest)
 		db.del(dest, true)
 		db.setSet(dest, set)
+		if len(set) > 0 {
+			m.notify(db.id, notifySet, "sunionstore", dest)
+		} else if existed {
+			m.notify(db.id, notifyGeneric, "del", dest)
+		}
 		c.WriteInt(len(set))
 	})
 }
@@ -615,6 +690,7 @@ func (m *Miniredis) cmdSscan(c *server.Peer, cmd string, args []string) {
 	// MATCH and COUNT options
 	var withMatch bool
 	var match string
+	count := 10
 	for len(args) > 0 {
 		if strings.ToLower(args[0]) == "count" {
 			if len(args) < 2 {
@@ -622,13 +698,18 @@ func (m *Miniredis) cmdSscan(c *server.Peer, cmd string, args []string) {
 				c.WriteError(msgSyntaxError)
 				return
 			}
-			_, err := strconv.Atoi(args[1])
+			n, err := strconv.Atoi(args[1])
 			if err != nil {
 				setDirty(c)
 				c.WriteError(msgInvalidInt)
 				return
 			}
-			// We do nothing with count.
+			if n < 1 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			count = n
 			args = args[2:]
 			continue
 		}
@@ -650,27 +731,32 @@ func (m *Miniredis) cmdSscan(c *server.Peer, cmd string, args []string) {
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		db := m.db(ctx.selectedDB)
-		// return _all_ (matched) keys every time
 
-		if cursor != 0 {
-			// invalid cursor
-			c.WriteLen(2)
-			c.WriteBulk("0") // no next cursor
-			c.WriteLen(0)    // no elements
-			return
-		}
 		if db.exists(key) && db.t(key) != "set" {
 			c.WriteError(ErrWrongType.Error())
 			return
 		}
 
 		members := db.setMembers(key)
+		// Same as Redis, small sets are returned in one go, whatever the
+		// COUNT.
+		if db.exists(key) && db.encoding(key) != "hashtable" {
+			count = len(members)
+		}
+		members, next, ok := m.scanPage(scanCursor{cmd: "sscan", db: ctx.selectedDB, key: key}, cursor, members, count)
+		if !ok {
+			// invalid cursor
+			c.WriteLen(2)
+			c.WriteBulk("0") // no next cursor
+			c.WriteLen(0)    // no elements
+			return
+		}
 		if withMatch {
-			members, _ = matchKeys(members, match)
+			members = matchKeys(members, match)
 		}
 
 		c.WriteLen(2)
-		c.WriteBulk("0") // no next cursor
+		c.WriteBulk(strconv.Itoa(next))
 		c.WriteLen(len(members))
 		for _, k := range members {
 			c.WriteBulk(k)
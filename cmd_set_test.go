@@ -1,6 +1,7 @@
 package miniredis
 
 import (
+	"fmt"
 	"sort"
 	"testing"
 
@@ -148,6 +149,44 @@ func TestSismember(t *testing.T) {
 	})
 }
 
+func TestSmismember(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.SetAdd("s", "aap", "noot", "mies")
+
+	mustDo(t, c,
+		"SMISMEMBER", "s", "aap", "nosuch", "mies",
+		proto.Ints(1, 0, 1),
+	)
+
+	// a nonexisting key
+	mustDo(t, c,
+		"SMISMEMBER", "nosuch", "aap", "noot",
+		proto.Ints(0, 0),
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		mustOK(t, c, "SET", "str", "value")
+		mustDo(t, c,
+			"SMISMEMBER", "str", "foo",
+			proto.Error(msgWrongType),
+		)
+		mustDo(t, c,
+			"SMISMEMBER",
+			proto.Error(errWrongNumber("smismember")),
+		)
+		mustDo(t, c,
+			"SMISMEMBER", "set",
+			proto.Error(errWrongNumber("smismember")),
+		)
+	})
+}
+
 // Test SREM
 func TestSrem(t *testing.T) {
 	s, err := Run()
@@ -328,10 +367,11 @@ func TestSpop(t *testing.T) {
 	})
 
 	t.Run("count argument", func(t *testing.T) {
+		s.Seed(42)
 		s.SetAdd("s", "aap", "noot", "mies", "vuur")
 		mustDo(t, c,
 			"SPOP", "s", "2",
-			proto.Strings("vuur", "mies"),
+			proto.Strings("mies", "vuur"),
 		)
 		members, err := s.Members("s")
 		ok(t, err)
@@ -379,10 +419,46 @@ func TestSrandmember(t *testing.T) {
 		proto.Strings("aap", "mies"),
 	)
 
+	// Count larger than the set
+	{
+		res, err := c.Do("SRANDMEMBER", "s", "10")
+		ok(t, err)
+		members, err := proto.ReadStrings(res)
+		ok(t, err)
+		sort.Strings(members)
+		equals(t, []string{"aap", "mies", "noot"}, members)
+	}
+	{
+		res, err := c.Do("SRANDMEMBER", "s", "-10")
+		ok(t, err)
+		members, err := proto.ReadStrings(res)
+		ok(t, err)
+		equals(t, 10, len(members))
+	}
+
+	// Same seed, same result
+	{
+		s.Seed(42)
+		a, err := c.Do("SRANDMEMBER", "s", "-5")
+		ok(t, err)
+		s.Seed(42)
+		b, err := c.Do("SRANDMEMBER", "s", "-5")
+		ok(t, err)
+		equals(t, a, b)
+	}
+
 	// a nonexisting key
 	mustNil(t, c,
 		"SRANDMEMBER", "nosuch",
 	)
+	mustDo(t, c,
+		"SRANDMEMBER", "nosuch", "2",
+		proto.Strings(),
+	)
+	mustDo(t, c,
+		"SRANDMEMBER", "nosuch", "-2",
+		proto.Strings(),
+	)
 
 	t.Run("errors", func(t *testing.T) {
 		s.SetAdd("chk", "aap", "noot")
@@ -747,7 +823,7 @@ func TestSscan(t *testing.T) {
 	ok(t, err)
 	defer c.Close()
 
-	// We cheat with sscan. It always returns everything.
+	// Small sets are returned in one go.
 
 	s.SetAdd("set", "value1", "value2")
 
@@ -772,7 +848,7 @@ func TestSscan(t *testing.T) {
 		),
 	)
 
-	// COUNT (ignored)
+	// COUNT (ignored for small sets)
 	mustDo(t, c,
 		"SSCAN", "set", "0", "COUNT", "200",
 		proto.Array(
@@ -796,6 +872,71 @@ func TestSscan(t *testing.T) {
 		),
 	)
 
+	t.Run("cursor", func(t *testing.T) {
+		// big enough to not be a listpack
+		for i := 0; i < 200; i++ {
+			s.SetAdd("big", fmt.Sprintf("member%03d", i))
+		}
+
+		sscan := func(cursor string, args ...string) (string, []string) {
+			t.Helper()
+			res, err := c.Do(append([]string{"SSCAN", "big", cursor}, args...)...)
+			ok(t, err)
+			elems, err := proto.ReadArray(res)
+			ok(t, err)
+			next, err := proto.ReadString(elems[0])
+			ok(t, err)
+			members, err := proto.ReadStrings(elems[1])
+			ok(t, err)
+			return next, members
+		}
+
+		// default COUNT is 10
+		cur, members := sscan("0")
+		equals(t, 10, len(members))
+		equals(t, "member000", members[0])
+		cur, members = sscan(cur, "COUNT", "100")
+		equals(t, 100, len(members))
+		equals(t, "member010", members[0])
+
+		// changes during an iteration
+		s.SRem("big", "member110")
+		s.SetAdd("big", "member109a")
+		cur, members = sscan(cur, "COUNT", "2")
+		equals(t, []string{"member109a", "member111"}, members)
+
+		// COUNT is applied before MATCH
+		cur, members = sscan(cur, "COUNT", "5", "MATCH", "nosuch*")
+		assert(t, cur != "0", "not done yet")
+		equals(t, 0, len(members))
+
+		// a retry gives the same page
+		used := cur
+		cur, members = sscan(used, "COUNT", "2")
+		equals(t, []string{"member117", "member118"}, members)
+		_, members = sscan(used, "COUNT", "2")
+		equals(t, []string{"member117", "member118"}, members)
+
+		// the rest
+		cur, members = sscan(cur, "COUNT", "1000")
+		equals(t, "0", cur)
+		equals(t, 81, len(members))
+		equals(t, 0, len(s.scanCursors))
+
+		// once we're done the cursors are gone
+		mustDo(t, c,
+			"SSCAN", "big", used,
+			proto.Array(proto.String("0"), proto.Strings()),
+		)
+
+		// cursors are per key
+		cur, _ = sscan("0")
+		mustDo(t, c,
+			"SSCAN", "set", cur,
+			proto.Array(proto.String("0"), proto.Strings()),
+		)
+	})
+
 	t.Run("errors", func(t *testing.T) {
 		mustDo(t, c,
 			"SSCAN",
@@ -821,6 +962,10 @@ func TestSscan(t *testing.T) {
 			"SSCAN", "set", "0", "COUNT", "noint",
 			proto.Error(msgInvalidInt),
 		)
+		mustDo(t, c,
+			"SSCAN", "set", "0", "COUNT", "0",
+			proto.Error(msgSyntaxError),
+		)
 		s.Set("str", "value")
 		mustDo(t, c,
 			"SSCAN", "str", "0",
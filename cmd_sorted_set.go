@@ -4,6 +4,7 @@ package miniredis
 
 import (
 	"errors"
+	"fmt"
 	"sort"
 	"strconv"
 	"strings"
@@ -21,11 +22,15 @@ func commandsSortedSet(m *Miniredis) {
 	m.srv.Register("ZCARD", m.cmdZcard)
 	m.srv.Register("ZCOUNT", m.cmdZcount)
 	m.srv.Register("ZINCRBY", m.cmdZincrby)
-	m.srv.Register("ZINTERSTORE", m.cmdZinterstore)
+	m.srv.Register("ZINTER", m.makeCmdZsetOp(true, false))
+	m.srv.Register("ZINTERSTORE", m.makeCmdZsetOp(true, true))
+	m.srv.Register("ZINTERCARD", m.cmdZintercard)
 	m.srv.Register("ZLEXCOUNT", m.cmdZlexcount)
+	m.srv.Register("ZRANDMEMBER", m.cmdZrandmember)
 	m.srv.Register("ZRANGE", m.makeCmdZrange(false))
 	m.srv.Register("ZRANGEBYLEX", m.makeCmdZrangebylex(false))
 	m.srv.Register("ZRANGEBYSCORE", m.makeCmdZrangebyscore(false))
+	m.srv.Register("ZRANGESTORE", m.cmdZrangestore)
 	m.srv.Register("ZRANK", m.makeCmdZrank(false))
 	m.srv.Register("ZREM", m.cmdZrem)
 	m.srv.Register("ZREMRANGEBYLEX", m.cmdZremrangebylex)
@@ -36,10 +41,16 @@ func commandsSortedSet(m *Miniredis) {
 	m.srv.Register("ZREVRANGEBYSCORE", m.makeCmdZrangebyscore(true))
 	m.srv.Register("ZREVRANK", m.makeCmdZrank(true))
 	m.srv.Register("ZSCORE", m.cmdZscore)
-	m.srv.Register("ZUNIONSTORE", m.cmdZunionstore)
+	m.srv.Register("ZMSCORE", m.cmdZmscore)
+	m.srv.Register("ZUNION", m.makeCmdZsetOp(false, false))
+	m.srv.Register("ZUNIONSTORE", m.makeCmdZsetOp(false, true))
 	m.srv.Register("ZSCAN", m.cmdZscan)
 	m.srv.Register("ZPOPMAX", m.cmdZpopmax(true))
 	m.srv.Register("ZPOPMIN", m.cmdZpopmax(false))
+	m.srv.Register("ZMPOP", m.cmdZmpop)
+	m.srv.Register("BZMPOP", m.cmdBzmpop)
+	m.srv.Register("BZPOPMAX", m.cmdBzpopmax(true))
+	m.srv.Register("BZPOPMIN", m.cmdBzpopmax(false))
 }
 
 // ZADD
@@ -136,12 +147,14 @@ outer:
 					return
 				}
 				newScore := db.ssetIncrby(key, member, delta)
+				m.notify(db.id, notifyZset, "zincr", key)
 				c.WriteFloat(newScore)
 			}
 			return
 		}
 
 		res := 0
+		changed := false
 		for member, score := range elems {
 			if nx && db.ssetExists(key, member) {
 				continue
@@ -152,13 +165,18 @@ outer:
 			old := db.ssetScore(key, member)
 			if db.ssetAdd(key, score, member) {
 				res++
-			} else {
-				if ch && old != score {
+				changed = true
+			} else if old != score {
+				changed = true
+				if ch {
 					// if 'CH' is specified, only count changed keys
 					res++
 				}
 			}
 		}
+		if changed {
+			m.notify(db.id, notifyZset, "zadd", key)
+		}
 		c.WriteInt(res)
 	})
 }
@@ -274,13 +292,82 @@ func (m *Miniredis) cmdZincrby(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		newScore := db.ssetIncrby(key, member, delta)
+		m.notify(db.id, notifyZset, "zincr", key)
 		c.WriteFloat(newScore)
 	})
 }
 
-// ZINTERSTORE
-func (m *Miniredis) cmdZinterstore(c *server.Peer, cmd string, args []string) {
-	if len(args) < 3 {
+// ZINTER and ZINTERSTORE, ZUNION and ZUNIONSTORE
+func (m *Miniredis) makeCmdZsetOp(inter, store bool) server.Cmd {
+	return func(c *server.Peer, cmd string, args []string) {
+		minArgs := 2
+		if store {
+			minArgs = 3
+		}
+		if len(args) < minArgs {
+			setDirty(c)
+			c.WriteError(errWrongNumber(cmd))
+			return
+		}
+		if !m.handleAuth(c) {
+			return
+		}
+		if m.checkPubsub(c, cmd) {
+			return
+		}
+
+		var destination string
+		if store {
+			destination, args = args[0], args[1:]
+		}
+		opts, msg := parseZsetOpArgs(cmd, args, store)
+		if msg != "" {
+			setDirty(c)
+			c.WriteError(msg)
+			return
+		}
+
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			db := m.db(ctx.selectedDB)
+
+			sset, err := db.zsetOp(inter, opts)
+			if err != nil {
+				c.WriteError(err.Error())
+				return
+			}
+
+			if store {
+				existed := db.exists(destination)
+				db.del(destination, true)
+				if sset.card() > 0 {
+					db.ssetSet(destination, sset)
+					m.notify(db.id, notifyZset, strings.ToLower(cmd), destination)
+				} else if existed {
+					m.notify(db.id, notifyGeneric, "del", destination)
+				}
+				c.WriteInt(sset.card())
+				return
+			}
+
+			elems := sset.byScore(asc)
+			if opts.withScores {
+				c.WriteLen(len(elems) * 2)
+			} else {
+				c.WriteLen(len(elems))
+			}
+			for _, el := range elems {
+				c.WriteBulk(el.member)
+				if opts.withScores {
+					c.WriteFloat(el.score)
+				}
+			}
+		})
+	}
+}
+
+// ZINTERCARD
+func (m *Miniredis) cmdZintercard(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
 		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
@@ -292,121 +379,55 @@ func (m *Miniredis) cmdZinterstore(c *server.Peer, cmd string, args []string) {
 		return
 	}
 
-	destination := args[0]
-	numKeys, err := strconv.Atoi(args[1])
-	if err != nil {
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys < 1 {
 		setDirty(c)
-		c.WriteError(msgInvalidInt)
+		c.WriteError(msgNumkeysPositive)
 		return
 	}
-	args = args[2:]
-	if len(args) < numKeys {
+	args = args[1:]
+	if numKeys > len(args) {
 		setDirty(c)
-		c.WriteError(msgSyntaxError)
+		c.WriteError(msgInvalidKeysNumber)
 		return
 	}
-	if numKeys <= 0 {
-		setDirty(c)
-		c.WriteError("ERR at least 1 input key is needed for ZUNIONSTORE/ZINTERSTORE")
-		return
+	opts := zsetOpOpts{
+		keys:      args[:numKeys],
+		aggregate: "sum",
 	}
-	keys := args[:numKeys]
 	args = args[numKeys:]
 
-	withWeights := false
-	weights := []float64{}
-	aggregate := "sum"
+	limit := 0
 	for len(args) > 0 {
-		switch strings.ToLower(args[0]) {
-		case "weights":
-			if len(args) < numKeys+1 {
-				setDirty(c)
-				c.WriteError(msgSyntaxError)
-				return
-			}
-			for i := 0; i < numKeys; i++ {
-				f, err := strconv.ParseFloat(args[i+1], 64)
-				if err != nil {
-					setDirty(c)
-					c.WriteError("ERR weight value is not a float")
-					return
-				}
-				weights = append(weights, f)
-			}
-			withWeights = true
-			args = args[numKeys+1:]
-		case "aggregate":
-			if len(args) < 2 {
-				setDirty(c)
-				c.WriteError(msgSyntaxError)
-				return
-			}
-			aggregate = strings.ToLower(args[1])
-			switch aggregate {
-			case "sum", "min", "max":
-			default:
+		if strings.ToLower(args[0]) == "limit" && len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 0 {
 				setDirty(c)
-				c.WriteError(msgSyntaxError)
+				c.WriteError(msgLimitNegative)
 				return
 			}
+			limit = n
 			args = args[2:]
-		default:
-			setDirty(c)
-			c.WriteError(msgSyntaxError)
-			return
+			continue
 		}
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
 	}
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		db := m.db(ctx.selectedDB)
-		db.del(destination, true)
 
-		// We collect everything and remove all keys which turned out not to be
-		// present in every set.
-		sset := map[string]float64{}
-		counts := map[string]int{}
-		for i, key := range keys {
-			if !db.exists(key) {
-				continue
-			}
-			if db.t(key) != "zset" {
-				c.WriteError(msgWrongType)
-				return
-			}
-			for _, el := range db.ssetElements(key) {
-				score := el.score
-				if withWeights {
-					score *= weights[i]
-				}
-				counts[el.member]++
-				old, ok := sset[el.member]
-				if !ok {
-					sset[el.member] = score
-					continue
-				}
-				switch aggregate {
-				default:
-					panic("Invalid aggregate")
-				case "sum":
-					sset[el.member] += score
-				case "min":
-					if score < old {
-						sset[el.member] = score
-					}
-				case "max":
-					if score > old {
-						sset[el.member] = score
-					}
-				}
-			}
+		sset, err := db.zsetOp(true, opts)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
 		}
-		for key, count := range counts {
-			if count != numKeys {
-				delete(sset, key)
-			}
+		n := sset.card()
+		if limit > 0 && n > limit {
+			n = limit
 		}
-		db.ssetSet(destination, sset)
-		c.WriteInt(len(sset))
+		c.WriteInt(n)
 	})
 }
 
@@ -460,47 +481,163 @@ func (m *Miniredis) cmdZlexcount(c *server.Peer, cmd string, args []string) {
 	})
 }
 
-// ZRANGE and ZREVRANGE
-func (m *Miniredis) makeCmdZrange(reverse bool) server.Cmd {
-	return func(c *server.Peer, cmd string, args []string) {
-		if len(args) < 3 {
+// ZRANDMEMBER
+func (m *Miniredis) cmdZrandmember(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if len(args) > 3 {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	count := 0
+	withCount := false
+	withScores := false
+	if len(args) >= 2 {
+		var err error
+		count, err = strconv.Atoi(args[1])
+		if err != nil {
 			setDirty(c)
-			c.WriteError(errWrongNumber(cmd))
+			c.WriteError(msgInvalidInt)
 			return
 		}
-		if !m.handleAuth(c) {
+		withCount = true
+	}
+	if len(args) == 3 {
+		if strings.ToLower(args[2]) != "withscores" {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
 			return
 		}
-		if m.checkPubsub(c, cmd) {
+		withScores = true
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			if withCount {
+				c.WriteLen(0)
+				return
+			}
+			c.WriteNull()
 			return
 		}
 
-		key := args[0]
-		start, err := strconv.Atoi(args[1])
-		if err != nil {
-			setDirty(c)
-			c.WriteError(msgInvalidInt)
+		if db.t(key) != "zset" {
+			c.WriteError(ErrWrongType.Error())
 			return
 		}
-		end, err := strconv.Atoi(args[2])
-		if err != nil {
-			setDirty(c)
-			c.WriteError(msgInvalidInt)
+
+		members := db.ssetMembers(key)
+		if !withCount {
+			c.WriteBulk(members[m.randIntn(len(members))])
 			return
 		}
 
-		withScores := false
-		if len(args) > 4 {
-			c.WriteError(msgSyntaxError)
+		var res []string
+		if count < 0 {
+			// Non-unique elements is allowed with negative count.
+			for i := 0; i < -count; i++ {
+				res = append(res, members[m.randIntn(len(members))])
+			}
+		} else {
+			// Must be unique elements.
+			m.shuffle(members)
+			if count > len(members) {
+				count = len(members)
+			}
+			res = members[:count]
+		}
+
+		switch {
+		case !withScores:
+			c.WriteLen(len(res))
+			for _, member := range res {
+				c.WriteBulk(member)
+			}
+		case c.Resp3:
+			c.WriteLen(len(res))
+			for _, member := range res {
+				c.WriteLen(2)
+				c.WriteBulk(member)
+				c.WriteFloat(db.ssetScore(key, member))
+			}
+		default:
+			c.WriteLen(len(res) * 2)
+			for _, member := range res {
+				c.WriteBulk(member)
+				c.WriteFloat(db.ssetScore(key, member))
+			}
+		}
+	})
+}
+
+// ZRANGE and ZREVRANGE. ZRANGE also does everything ZRANGEBYSCORE and
+// ZRANGEBYLEX do, ZREVRANGE only has WITHSCORES.
+func (m *Miniredis) makeCmdZrange(reverse bool) server.Cmd {
+	return func(c *server.Peer, cmd string, args []string) {
+		if len(args) < 3 {
+			setDirty(c)
+			c.WriteError(errWrongNumber(cmd))
 			return
 		}
-		if len(args) == 4 {
-			if strings.ToLower(args[3]) != "withscores" {
+		if !m.handleAuth(c) {
+			return
+		}
+		if m.checkPubsub(c, cmd) {
+			return
+		}
+
+		key := args[0]
+		var opts zrangeOpts
+		if reverse {
+			var err error
+			opts.rev = true
+			opts.start, err = strconv.Atoi(args[1])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			opts.end, err = strconv.Atoi(args[2])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			if len(args) > 4 {
 				setDirty(c)
 				c.WriteError(msgSyntaxError)
 				return
 			}
-			withScores = true
+			if len(args) == 4 {
+				if strings.ToLower(args[3]) != "withscores" {
+					setDirty(c)
+					c.WriteError(msgSyntaxError)
+					return
+				}
+				opts.withScores = true
+			}
+		} else {
+			var msg string
+			opts, msg = parseZrangeArgs(args[1:], false)
+			if msg != "" {
+				setDirty(c)
+				c.WriteError(msg)
+				return
+			}
 		}
 
 		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
@@ -516,26 +653,69 @@ func (m *Miniredis) makeCmdZrange(reverse bool) server.Cmd {
 				return
 			}
 
-			members := db.ssetMembers(key)
-			if reverse {
-				reverseSlice(members)
-			}
-			rs, re := redisRange(len(members), start, end, false)
-			if withScores {
-				c.WriteLen((re - rs) * 2)
+			elems := db.ssetRange(key, opts)
+			if opts.withScores {
+				c.WriteLen(len(elems) * 2)
 			} else {
-				c.WriteLen(re - rs)
+				c.WriteLen(len(elems))
 			}
-			for _, el := range members[rs:re] {
-				c.WriteBulk(el)
-				if withScores {
-					c.WriteFloat(db.ssetScore(key, el))
+			for _, el := range elems {
+				c.WriteBulk(el.member)
+				if opts.withScores {
+					c.WriteFloat(el.score)
 				}
 			}
 		})
 	}
 }
 
+// ZRANGESTORE
+func (m *Miniredis) cmdZrangestore(c *server.Peer, cmd string, args []string) {
+	if len(args) < 4 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	dst, src := args[0], args[1]
+	opts, msg := parseZrangeArgs(args[2:], true)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(src) && db.t(src) != "zset" {
+			c.WriteError(ErrWrongType.Error())
+			return
+		}
+
+		elems := db.ssetRange(src, opts)
+		existed := db.exists(dst)
+		db.del(dst, true)
+		if len(elems) > 0 {
+			sset := sortedSet{}
+			for _, e := range elems {
+				sset[e.member] = e.score
+			}
+			db.ssetSet(dst, sset)
+			m.notify(db.id, notifyZset, "zrangestore", dst)
+		} else if existed {
+			m.notify(db.id, notifyGeneric, "del", dst)
+		}
+		c.WriteInt(len(elems))
+	})
+}
+
 // ZRANGEBYLEX and ZREVRANGEBYLEX
 func (m *Miniredis) makeCmdZrangebylex(reverse bool) server.Cmd {
 	return func(c *server.Peer, cmd string, args []string) {
@@ -853,6 +1033,10 @@ func (m *Miniredis) cmdZrem(c *server.Peer, cmd string, args []string) {
 				deleted++
 			}
 		}
+		if deleted > 0 {
+			m.notify(db.id, notifyZset, "zrem", key)
+			m.notifyDeleted(db, key)
+		}
 		c.WriteInt(deleted)
 	})
 }
@@ -906,6 +1090,10 @@ func (m *Miniredis) cmdZremrangebylex(c *server.Peer, cmd string, args []string)
 		for _, el := range members {
 			db.ssetRem(key, el)
 		}
+		if len(members) > 0 {
+			m.notify(db.id, notifyZset, "zremrangebylex", key)
+			m.notifyDeleted(db, key)
+		}
 		c.WriteInt(len(members))
 	})
 }
@@ -956,6 +1144,10 @@ func (m *Miniredis) cmdZremrangebyrank(c *server.Peer, cmd string, args []string
 		for _, el := range members[rs:re] {
 			db.ssetRem(key, el)
 		}
+		if re > rs {
+			m.notify(db.id, notifyZset, "zremrangebyrank", key)
+			m.notifyDeleted(db, key)
+		}
 		c.WriteInt(re - rs)
 	})
 }
@@ -1007,6 +1199,10 @@ func (m *Miniredis) cmdZremrangebyscore(c *server.Peer, cmd string, args []strin
 		for _, el := range members {
 			db.ssetRem(key, el.member)
 		}
+		if len(members) > 0 {
+			m.notify(db.id, notifyZset, "zremrangebyscore", key)
+			m.notifyDeleted(db, key)
+		}
 		c.WriteInt(len(members))
 	})
 }
@@ -1049,19 +1245,327 @@ func (m *Miniredis) cmdZscore(c *server.Peer, cmd string, args []string) {
 	})
 }
 
-// parseFloatRange handles ZRANGEBYSCORE floats. They are inclusive unless the
-// string starts with '('
-func parseFloatRange(s string) (float64, bool, error) {
-	if len(s) == 0 {
-		return 0, false, nil
+// ZMSCORE
+func (m *Miniredis) cmdZmscore(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
 	}
-	inclusive := true
-	if s[0] == '(' {
-		s = s[1:]
-		inclusive = false
+	if !m.handleAuth(c) {
+		return
 	}
-	f, err := strconv.ParseFloat(s, 64)
-	return f, inclusive, err
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, members := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "zset" {
+			c.WriteError(ErrWrongType.Error())
+			return
+		}
+
+		c.WriteLen(len(members))
+		for _, member := range members {
+			if !db.ssetExists(key, member) {
+				c.WriteNull()
+				continue
+			}
+			c.WriteFloat(db.ssetScore(key, member))
+		}
+	})
+}
+
+// zsetOpOpts are the ZUNION and ZINTER arguments, after the destination key.
+type zsetOpOpts struct {
+	keys       []string
+	weights    []float64 // nil without WEIGHTS
+	aggregate  string
+	withScores bool
+}
+
+// parseZsetOpArgs parses "numkeys key [key ...] [WEIGHTS weight [weight ...]]
+// [AGGREGATE SUM|MIN|MAX] [WITHSCORES]". The STORE commands have no
+// WITHSCORES. Returns an error message, if any.
+func parseZsetOpArgs(cmd string, args []string, store bool) (zsetOpOpts, string) {
+	opts := zsetOpOpts{aggregate: "sum"}
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil {
+		return opts, msgInvalidInt
+	}
+	args = args[1:]
+	if numKeys <= 0 {
+		if store {
+			return opts, "ERR at least 1 input key is needed for ZUNIONSTORE/ZINTERSTORE"
+		}
+		return opts, fmt.Sprintf("ERR at least 1 input key is needed for '%s' command", strings.ToLower(cmd))
+	}
+	if len(args) < numKeys {
+		return opts, msgSyntaxError
+	}
+	opts.keys, args = args[:numKeys], args[numKeys:]
+
+	for len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "weights":
+			if len(args) < numKeys+1 {
+				return opts, msgSyntaxError
+			}
+			opts.weights = nil
+			for i := 0; i < numKeys; i++ {
+				f, err := strconv.ParseFloat(args[i+1], 64)
+				if err != nil {
+					return opts, "ERR weight value is not a float"
+				}
+				opts.weights = append(opts.weights, f)
+			}
+			args = args[numKeys+1:]
+		case "aggregate":
+			if len(args) < 2 {
+				return opts, msgSyntaxError
+			}
+			opts.aggregate = strings.ToLower(args[1])
+			switch opts.aggregate {
+			case "sum", "min", "max":
+			default:
+				return opts, msgSyntaxError
+			}
+			args = args[2:]
+		case "withscores":
+			if store {
+				return opts, msgSyntaxError
+			}
+			opts.withScores = true
+			args = args[1:]
+		default:
+			return opts, msgSyntaxError
+		}
+	}
+	return opts, ""
+}
+
+// zsetOp is the union or the intersection of the keys. Sets count as sorted
+// sets with all scores 1.
+func (db *RedisDB) zsetOp(inter bool, opts zsetOpOpts) (sortedSet, error) {
+	// We collect everything and, for ZINTER, remove all members which turned
+	// out not to be present in every set.
+	sset := sortedSet{}
+	counts := map[string]int{}
+	for i, key := range opts.keys {
+		if !db.exists(key) {
+			continue
+		}
+
+		var set map[string]float64
+		switch db.t(key) {
+		case "set":
+			set = map[string]float64{}
+			for elem := range db.setKeys[key] {
+				set[elem] = 1.0
+			}
+		case "zset":
+			set = db.sortedSet(key)
+		default:
+			return nil, ErrWrongType
+		}
+
+		for member, score := range set {
+			if opts.weights != nil {
+				score *= opts.weights[i]
+			}
+			counts[member]++
+			old, ok := sset[member]
+			if !ok {
+				sset[member] = score
+				continue
+			}
+			switch opts.aggregate {
+			default:
+				panic("Invalid aggregate")
+			case "sum":
+				sset[member] += score
+			case "min":
+				if score < old {
+					sset[member] = score
+				}
+			case "max":
+				if score > old {
+					sset[member] = score
+				}
+			}
+		}
+	}
+	if inter {
+		for member, count := range counts {
+			if count != len(opts.keys) {
+				delete(sset, member)
+			}
+		}
+	}
+	return sset, nil
+}
+
+// zrangeOpts are the ZRANGE and ZRANGESTORE arguments, after the key(s).
+type zrangeOpts struct {
+	by         string // "", "score", or "lex"
+	rev        bool
+	withScores bool
+	withLimit  bool
+	offset     int
+	count      int
+
+	start, end       int     // by rank
+	min, max         float64 // BYSCORE
+	minLex, maxLex   string  // BYLEX
+	minIncl, maxIncl bool    // BYSCORE and BYLEX
+}
+
+// parseZrangeArgs parses "<start> <stop> [BYSCORE|BYLEX] [REV] [LIMIT offset
+// count] [WITHSCORES]". ZRANGESTORE has no WITHSCORES. Returns an error
+// message, if any.
+func parseZrangeArgs(args []string, store bool) (zrangeOpts, string) {
+	var opts zrangeOpts
+	min, max := args[0], args[1]
+	for args = args[2:]; len(args) > 0; args = args[1:] {
+		switch strings.ToLower(args[0]) {
+		case "withscores":
+			if store {
+				return opts, msgSyntaxError
+			}
+			opts.withScores = true
+		case "limit":
+			if len(args) < 3 {
+				return opts, msgSyntaxError
+			}
+			offset, err := strconv.Atoi(args[1])
+			if err != nil {
+				return opts, msgInvalidInt
+			}
+			count, err := strconv.Atoi(args[2])
+			if err != nil {
+				return opts, msgInvalidInt
+			}
+			opts.withLimit = true
+			opts.offset, opts.count = offset, count
+			args = args[2:]
+		case "byscore":
+			if opts.by != "" {
+				return opts, msgSyntaxError
+			}
+			opts.by = "score"
+		case "bylex":
+			if opts.by != "" {
+				return opts, msgSyntaxError
+			}
+			opts.by = "lex"
+		case "rev":
+			if opts.rev {
+				return opts, msgSyntaxError
+			}
+			opts.rev = true
+		default:
+			return opts, msgSyntaxError
+		}
+	}
+	if opts.withLimit && opts.by == "" {
+		return opts, msgLimitCombination
+	}
+	if opts.withScores && opts.by == "lex" {
+		return opts, msgWithScoresLex
+	}
+
+	if opts.rev && opts.by != "" {
+		min, max = max, min
+	}
+	switch opts.by {
+	case "":
+		var err error
+		if opts.start, err = strconv.Atoi(min); err != nil {
+			return opts, msgInvalidInt
+		}
+		if opts.end, err = strconv.Atoi(max); err != nil {
+			return opts, msgInvalidInt
+		}
+	case "score":
+		var err error
+		if opts.min, opts.minIncl, err = parseFloatRange(min); err != nil {
+			return opts, msgInvalidMinMax
+		}
+		if opts.max, opts.maxIncl, err = parseFloatRange(max); err != nil {
+			return opts, msgInvalidMinMax
+		}
+	case "lex":
+		var err error
+		if opts.minLex, opts.minIncl, err = parseLexrange(min); err != nil {
+			return opts, err.Error()
+		}
+		if opts.maxLex, opts.maxIncl, err = parseLexrange(max); err != nil {
+			return opts, err.Error()
+		}
+	}
+	return opts, ""
+}
+
+// ssetRange are the elements selected by the ZRANGE options. The key can be
+// missing, but not of another type.
+func (db *RedisDB) ssetRange(key string, opts zrangeOpts) ssElems {
+	elems := db.ssetElements(key)
+	switch opts.by {
+	case "":
+		if opts.rev {
+			reverseElems(elems)
+		}
+		rs, re := redisRange(len(elems), opts.start, opts.end, false)
+		return elems[rs:re]
+	case "score":
+		elems = withSSRange(elems, opts.min, opts.minIncl, opts.max, opts.maxIncl)
+	case "lex":
+		// Just member sort. If scores are not the same we don't care.
+		members := make([]string, 0, len(elems))
+		for _, e := range elems {
+			members = append(members, e.member)
+		}
+		sort.Strings(members)
+		members = withLexRange(members, opts.minLex, opts.minIncl, opts.maxLex, opts.maxIncl)
+		elems = make(ssElems, 0, len(members))
+		for _, m := range members {
+			elems = append(elems, ssElem{db.ssetScore(key, m), m})
+		}
+	}
+	if opts.rev {
+		reverseElems(elems)
+	}
+
+	// Apply LIMIT ranges. That's <start> <elements>. Unlike RANGE.
+	if opts.withLimit {
+		if opts.offset < 0 || opts.offset >= len(elems) {
+			return nil
+		}
+		elems = elems[opts.offset:]
+		if opts.count >= 0 && len(elems) > opts.count {
+			elems = elems[:opts.count]
+		}
+	}
+	return elems
+}
+
+// parseFloatRange handles ZRANGEBYSCORE floats. They are inclusive unless the
+// string starts with '('
+func parseFloatRange(s string) (float64, bool, error) {
+	if len(s) == 0 {
+		return 0, false, nil
+	}
+	inclusive := true
+	if s[0] == '(' {
+		s = s[1:]
+		inclusive = false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, inclusive, err
 }
 
 // parseLexrange handles ZRANGEBYLEX ranges. They start with '[', '(', or are
@@ -1169,147 +1673,6 @@ func withLexRange(members []string, min string, minIncl bool, max string, maxInc
 	return members
 }
 
-// ZUNIONSTORE
-func (m *Miniredis) cmdZunionstore(c *server.Peer, cmd string, args []string) {
-	if len(args) < 3 {
-		setDirty(c)
-		c.WriteError(errWrongNumber(cmd))
-		return
-	}
-	if !m.handleAuth(c) {
-		return
-	}
-	if m.checkPubsub(c, cmd) {
-		return
-	}
-
-	destination := args[0]
-	numKeys, err := strconv.Atoi(args[1])
-	if err != nil {
-		setDirty(c)
-		c.WriteError(msgInvalidInt)
-		return
-	}
-	args = args[2:]
-	if len(args) < numKeys {
-		setDirty(c)
-		c.WriteError(msgSyntaxError)
-		return
-	}
-	if numKeys <= 0 {
-		setDirty(c)
-		c.WriteError("ERR at least 1 input key is needed for ZUNIONSTORE/ZINTERSTORE")
-		return
-	}
-	keys := args[:numKeys]
-	args = args[numKeys:]
-
-	withWeights := false
-	weights := []float64{}
-	aggregate := "sum"
-	for len(args) > 0 {
-		switch strings.ToLower(args[0]) {
-		case "weights":
-			if len(args) < numKeys+1 {
-				setDirty(c)
-				c.WriteError(msgSyntaxError)
-				return
-			}
-			for i := 0; i < numKeys; i++ {
-				f, err := strconv.ParseFloat(args[i+1], 64)
-				if err != nil {
-					setDirty(c)
-					c.WriteError("ERR weight value is not a float")
-					return
-				}
-				weights = append(weights, f)
-			}
-			withWeights = true
-			args = args[numKeys+1:]
-		case "aggregate":
-			if len(args) < 2 {
-				setDirty(c)
-				c.WriteError(msgSyntaxError)
-				return
-			}
-			aggregate = strings.ToLower(args[1])
-			switch aggregate {
-			default:
-				setDirty(c)
-				c.WriteError(msgSyntaxError)
-				return
-			case "sum", "min", "max":
-			}
-			args = args[2:]
-		default:
-			setDirty(c)
-			c.WriteError(msgSyntaxError)
-			return
-		}
-	}
-
-	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
-		db := m.db(ctx.selectedDB)
-		deleteDest := true
-		for _, key := range keys {
-			if destination == key {
-				deleteDest = false
-			}
-		}
-		if deleteDest {
-			db.del(destination, true)
-		}
-
-		sset := sortedSet{}
-		for i, key := range keys {
-			if !db.exists(key) {
-				continue
-			}
-
-			var set map[string]float64
-			switch db.t(key) {
-			case "set":
-				set = map[string]float64{}
-				for elem := range db.setKeys[key] {
-					set[elem] = 1.0
-				}
-			case "zset":
-				set = db.sortedSet(key)
-			default:
-				c.WriteError(msgWrongType)
-				return
-			}
-
-			for member, score := range set {
-				if withWeights {
-					score *= weights[i]
-				}
-				old, ok := sset[member]
-				if !ok {
-					sset[member] = score
-					continue
-				}
-				switch aggregate {
-				default:
-					panic("Invalid aggregate")
-				case "sum":
-					sset[member] += score
-				case "min":
-					if score < old {
-						sset[member] = score
-					}
-				case "max":
-					if score > old {
-						sset[member] = score
-					}
-				}
-			}
-		}
-		db.ssetSet(destination, sset)
-		c.WriteInt(sset.card())
-	})
-}
-
 // ZSCAN
 func (m *Miniredis) cmdZscan(c *server.Peer, cmd string, args []string) {
 	if len(args) < 2 {
@@ -1385,7 +1748,7 @@ func (m *Miniredis) cmdZscan(c *server.Peer, cmd string, args []string) {
 
 		members := db.ssetMembers(key)
 		if withMatch {
-			members, _ = matchKeys(members, match)
+			members = matchKeys(members, match)
 		}
 
 		c.WriteLen(2)
@@ -1460,6 +1823,239 @@ func (m *Miniredis) cmdZpopmax(reverse bool) server.Cmd {
 				}
 				db.ssetRem(key, el)
 			}
+			if re > rs {
+				m.notifyZpop(db, key, reverse)
+			}
 		})
 	}
 }
+
+// BZPOPMAX and BZPOPMIN
+func (m *Miniredis) cmdBzpopmax(reverse bool) server.Cmd {
+	return func(c *server.Peer, cmd string, args []string) {
+		if len(args) < 2 {
+			setDirty(c)
+			c.WriteError(errWrongNumber(cmd))
+			return
+		}
+		if !m.handleAuth(c) {
+			return
+		}
+		if m.checkPubsub(c, cmd) {
+			return
+		}
+
+		keys := args[:len(args)-1]
+		timeout, msg := parseFloatTimeout(args[len(args)-1])
+		if msg != "" {
+			setDirty(c)
+			c.WriteError(msg)
+			return
+		}
+
+		blocking(
+			m,
+			c,
+			timeout,
+			keys,
+			func(c *server.Peer, ctx *connCtx) bool {
+				db := m.db(ctx.selectedDB)
+				for _, key := range keys {
+					if !db.exists(key) {
+						continue
+					}
+					if db.t(key) != "zset" {
+						c.WriteError(msgWrongType)
+						return true
+					}
+
+					d := asc
+					if reverse {
+						d = desc
+					}
+					ss := db.sortedsetKeys[key]
+					el := ss.byScore(d)[0]
+					c.WriteLen(3)
+					c.WriteBulk(key)
+					c.WriteBulk(el.member)
+					c.WriteFloat(el.score)
+					db.ssetRem(key, el.member)
+					m.notifyZpop(db, key, reverse)
+					return true
+				}
+				return false
+			},
+			func(c *server.Peer) {
+				// timeout
+				c.WriteLen(-1)
+			},
+		)
+	}
+}
+
+type zmpopOpts struct {
+	keys  []string
+	max   bool
+	count int
+}
+
+// parseZmpopArgs parses the "numkeys key [key ...] MIN|MAX [COUNT count]"
+// part of ZMPOP and BZMPOP. Returns an error message on failure.
+func parseZmpopArgs(args []string) (zmpopOpts, string) {
+	var opts zmpopOpts
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys < 1 {
+		return opts, msgNumkeysPositive
+	}
+	args = args[1:]
+	if numKeys >= len(args) {
+		return opts, msgSyntaxError
+	}
+	opts.keys, args = args[:numKeys], args[numKeys:]
+
+	switch strings.ToLower(args[0]) {
+	case "min":
+	case "max":
+		opts.max = true
+	default:
+		return opts, msgSyntaxError
+	}
+	args = args[1:]
+
+	opts.count = -1
+	for len(args) > 0 {
+		if opts.count == -1 && strings.ToLower(args[0]) == "count" && len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return opts, msgCountPositive
+			}
+			opts.count = n
+			args = args[2:]
+			continue
+		}
+		return opts, msgSyntaxError
+	}
+	if opts.count == -1 {
+		opts.count = 1
+	}
+	return opts, ""
+}
+
+// zmpop pops from the first non-empty sorted set. Returns false if there was
+// nothing to pop. No locks!
+func (m *Miniredis) zmpop(c *server.Peer, db *RedisDB, opts zmpopOpts) bool {
+	for _, key := range opts.keys {
+		if !db.exists(key) {
+			continue
+		}
+		if db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return true
+		}
+
+		d := asc
+		if opts.max {
+			d = desc
+		}
+		ss := db.sortedsetKeys[key]
+		elems := ss.byScore(d)
+		if len(elems) > opts.count {
+			elems = elems[:opts.count]
+		}
+		c.WriteLen(2)
+		c.WriteBulk(key)
+		c.WriteLen(len(elems))
+		for _, el := range elems {
+			c.WriteLen(2)
+			c.WriteBulk(el.member)
+			c.WriteFloat(el.score)
+			db.ssetRem(key, el.member)
+		}
+		m.notifyZpop(db, key, opts.max)
+		return true
+	}
+	return false
+}
+
+// notifyZpop sends the events for popping elements from a sorted set.
+func (m *Miniredis) notifyZpop(db *RedisDB, key string, max bool) {
+	if max {
+		m.notify(db.id, notifyZset, "zpopmax", key)
+	} else {
+		m.notify(db.id, notifyZset, "zpopmin", key)
+	}
+	m.notifyDeleted(db, key)
+}
+
+// ZMPOP
+func (m *Miniredis) cmdZmpop(c *server.Peer, cmd string, args []string) {
+	if len(args) < 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	opts, msg := parseZmpopArgs(args)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !m.zmpop(c, db, opts) {
+			c.WriteLen(-1)
+		}
+	})
+}
+
+// BZMPOP
+func (m *Miniredis) cmdBzmpop(c *server.Peer, cmd string, args []string) {
+	if len(args) < 4 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	timeout, msg := parseFloatTimeout(args[0])
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	opts, msg := parseZmpopArgs(args[1:])
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	blocking(
+		m,
+		c,
+		timeout,
+		opts.keys,
+		func(c *server.Peer, ctx *connCtx) bool {
+			return m.zmpop(c, m.db(ctx.selectedDB), opts)
+		},
+		func(c *server.Peer) {
+			// timeout
+			c.WriteLen(-1)
+		},
+	)
+}
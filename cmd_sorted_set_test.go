@@ -2,7 +2,9 @@ package miniredis
 
 import (
 	"math"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2/proto"
 )
@@ -196,7 +198,7 @@ func TestSortedSetAdd(t *testing.T) {
 
 		mustDo(t, c,
 			"ZADD", "z", "INCR", "1.2", "one",
-			proto.String("2.4"),
+			proto.String("2.3999999999999999"),
 		)
 
 		mustNil(t, c,
@@ -205,7 +207,7 @@ func TestSortedSetAdd(t *testing.T) {
 
 		mustDo(t, c,
 			"ZADD", "z", "INCR", "XX", "1.2", "one",
-			proto.String("3.6"),
+			proto.String("3.5999999999999996"),
 		)
 
 		mustNil(t, c,
@@ -224,7 +226,7 @@ func TestSortedSetAdd(t *testing.T) {
 		// CH is ignored with INCR
 		mustDo(t, c,
 			"ZADD", "z", "INCR", "CH", "1.2", "one",
-			proto.String("4.8"),
+			proto.String("4.7999999999999998"),
 		)
 	}
 
@@ -369,6 +371,52 @@ func TestSortedSetRange(t *testing.T) {
 		)
 	}
 
+	t.Run("rev", func(t *testing.T) {
+		mustDo(t, c,
+			"ZRANGE", "z", "0", "1", "REV",
+			proto.Strings("inf", "three"),
+		)
+		mustDo(t, c,
+			"ZRANGE", "z", "0", "0", "rev", "withscores",
+			proto.Strings("inf", "inf"),
+		)
+	})
+
+	t.Run("byscore", func(t *testing.T) {
+		mustDo(t, c,
+			"ZRANGE", "z", "(1", "3", "BYSCORE",
+			proto.Strings("two", "zwei", "drei", "three"),
+		)
+		mustDo(t, c,
+			"ZRANGE", "z", "(1", "5", "BYSCORE", "LIMIT", "1", "2", "WITHSCORES",
+			proto.Strings("zwei", "2", "drei", "3"),
+		)
+		// REV takes max first
+		mustDo(t, c,
+			"ZRANGE", "z", "+inf", "(2", "BYSCORE", "REV", "LIMIT", "0", "2",
+			proto.Strings("inf", "three"),
+		)
+		mustDo(t, c,
+			"ZRANGE", "z", "(2", "+inf", "BYSCORE", "REV",
+			proto.Strings(),
+		)
+	})
+
+	t.Run("bylex", func(t *testing.T) {
+		s.ZAdd("lex", 0, "a")
+		s.ZAdd("lex", 0, "b")
+		s.ZAdd("lex", 0, "c")
+		s.ZAdd("lex", 0, "d")
+		mustDo(t, c,
+			"ZRANGE", "lex", "[b", "+", "BYLEX",
+			proto.Strings("b", "c", "d"),
+		)
+		mustDo(t, c,
+			"ZRANGE", "lex", "(d", "-", "BYLEX", "REV", "LIMIT", "1", "10",
+			proto.Strings("b", "a"),
+		)
+	})
+
 	t.Run("errors", func(t *testing.T) {
 		mustDo(t, c,
 			"ZRANGE",
@@ -398,6 +446,34 @@ func TestSortedSetRange(t *testing.T) {
 			"ZRANGE", "set", "1", "2", "toomany",
 			proto.Error(msgSyntaxError),
 		)
+		mustDo(t, c,
+			"ZRANGE", "set", "1", "2", "LIMIT", "1", "1",
+			proto.Error(msgLimitCombination),
+		)
+		mustDo(t, c,
+			"ZRANGE", "set", "[a", "[b", "BYLEX", "WITHSCORES",
+			proto.Error(msgWithScoresLex),
+		)
+		mustDo(t, c,
+			"ZRANGE", "set", "1", "2", "REV", "REV",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"ZRANGE", "set", "1", "2", "BYSCORE", "BYLEX",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"ZRANGE", "set", "a", "2", "BYSCORE",
+			proto.Error(msgInvalidMinMax),
+		)
+		mustDo(t, c,
+			"ZRANGE", "set", "a", "b", "BYLEX",
+			proto.Error(msgInvalidRangeItem),
+		)
+		mustDo(t, c,
+			"ZREVRANGE", "set", "1", "2", "BYSCORE",
+			proto.Error(msgSyntaxError),
+		)
 		// Wrong type of key
 		s.Set("str", "value")
 		mustDo(t, c,
@@ -989,6 +1065,31 @@ func TestSortedSetScore(t *testing.T) {
 		"ZSCORE", "nosuch", "nosuch",
 	)
 
+	t.Run("ZMSCORE", func(t *testing.T) {
+		mustDo(t, c,
+			"ZMSCORE", "z", "one", "nosuch", "zwei",
+			proto.Array(
+				proto.String("1"),
+				proto.Nil,
+				proto.String("2"),
+			),
+		)
+		mustDo(t, c,
+			"ZMSCORE", "nosuch", "one", "two",
+			proto.Array(proto.Nil, proto.Nil),
+		)
+
+		mustDo(t, c,
+			"ZMSCORE", "z",
+			proto.Error(errWrongNumber("zmscore")),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"ZMSCORE", "str", "one",
+			proto.Error(msgWrongType),
+		)
+	})
+
 	// Direct
 	{
 		s.ZAdd("z2", 1, "one")
@@ -1655,6 +1756,409 @@ func TestZinterstore(t *testing.T) {
 	})
 }
 
+func TestZunion(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.ZAdd("h1", 1.0, "field1")
+	s.ZAdd("h1", 2.0, "field2")
+	s.ZAdd("h2", 1.0, "field1")
+	s.ZAdd("h2", 3.0, "field3")
+	s.SetAdd("set", "field1", "field4")
+
+	mustDo(t, c,
+		"ZUNION", "2", "h1", "h2", "WITHSCORES",
+		proto.Strings("field1", "2", "field2", "2", "field3", "3"),
+	)
+	mustDo(t, c,
+		"ZUNION", "2", "h1", "h2", "WEIGHTS", "2", "1", "AGGREGATE", "MAX",
+		proto.Strings("field1", "field3", "field2"),
+	)
+	mustDo(t, c,
+		"ZUNION", "3", "h1", "set", "nosuch", "withscores",
+		proto.Strings("field4", "1", "field1", "2", "field2", "2"),
+	)
+	mustDo(t, c,
+		"ZUNION", "1", "nosuch",
+		proto.Strings(),
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"ZUNION", "1",
+			proto.Error(errWrongNumber("zunion")),
+		)
+		mustDo(t, c,
+			"ZUNION", "0", "h1",
+			proto.Error("ERR at least 1 input key is needed for 'zunion' command"),
+		)
+		mustDo(t, c,
+			"ZUNION", "noint", "h1",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"ZUNION", "2", "h1",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"ZUNION", "1", "h1", "WEIGHTS", "nofloat",
+			proto.Error("ERR weight value is not a float"),
+		)
+		mustDo(t, c,
+			"ZUNIONSTORE", "dst", "1", "h1", "WITHSCORES",
+			proto.Error(msgSyntaxError),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"ZUNION", "2", "h1", "str",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
+func TestZinter(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.ZAdd("h1", 1.0, "field1")
+	s.ZAdd("h1", 2.0, "field2")
+	s.ZAdd("h1", 3.0, "field3")
+	s.ZAdd("h2", 1.0, "field1")
+	s.ZAdd("h2", 4.0, "field2")
+	s.SetAdd("set", "field1", "field4")
+
+	mustDo(t, c,
+		"ZINTER", "2", "h1", "h2", "WITHSCORES",
+		proto.Strings("field1", "2", "field2", "6"),
+	)
+	mustDo(t, c,
+		"ZINTER", "2", "h1", "h2", "AGGREGATE", "min", "WEIGHTS", "1", "-1",
+		proto.Strings("field2", "field1"),
+	)
+	mustDo(t, c,
+		"ZINTER", "2", "h1", "set", "WITHSCORES",
+		proto.Strings("field1", "2"),
+	)
+	mustDo(t, c,
+		"ZINTER", "2", "h1", "nosuch",
+		proto.Strings(),
+	)
+
+	// an empty intersection deletes the destination
+	s.Set("dst", "value")
+	must0(t, c,
+		"ZINTERSTORE", "dst", "2", "h1", "nosuch",
+	)
+	equals(t, false, s.Exists("dst"))
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"ZINTER", "1",
+			proto.Error(errWrongNumber("zinter")),
+		)
+		mustDo(t, c,
+			"ZINTER", "-1", "h1",
+			proto.Error("ERR at least 1 input key is needed for 'zinter' command"),
+		)
+		mustDo(t, c,
+			"ZINTER", "1", "h1", "AGGREGATE", "foo",
+			proto.Error(msgSyntaxError),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"ZINTER", "2", "h1", "str",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
+func TestZintercard(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.ZAdd("h1", 1.0, "field1")
+	s.ZAdd("h1", 2.0, "field2")
+	s.ZAdd("h1", 3.0, "field3")
+	s.ZAdd("h2", 1.0, "field1")
+	s.ZAdd("h2", 4.0, "field2")
+	s.SetAdd("set", "field1", "field4")
+
+	mustDo(t, c,
+		"ZINTERCARD", "2", "h1", "h2",
+		proto.Int(2),
+	)
+	mustDo(t, c,
+		"ZINTERCARD", "2", "h1", "h2", "LIMIT", "1",
+		proto.Int(1),
+	)
+	mustDo(t, c,
+		"ZINTERCARD", "2", "h1", "h2", "limit", "0",
+		proto.Int(2),
+	)
+	must1(t, c,
+		"ZINTERCARD", "2", "h1", "set",
+	)
+	must0(t, c,
+		"ZINTERCARD", "2", "h1", "nosuch",
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"ZINTERCARD", "1",
+			proto.Error(errWrongNumber("zintercard")),
+		)
+		mustDo(t, c,
+			"ZINTERCARD", "0", "h1",
+			proto.Error(msgNumkeysPositive),
+		)
+		mustDo(t, c,
+			"ZINTERCARD", "noint", "h1",
+			proto.Error(msgNumkeysPositive),
+		)
+		mustDo(t, c,
+			"ZINTERCARD", "3", "h1", "h2",
+			proto.Error(msgInvalidKeysNumber),
+		)
+		mustDo(t, c,
+			"ZINTERCARD", "1", "h1", "LIMIT", "-1",
+			proto.Error(msgLimitNegative),
+		)
+		mustDo(t, c,
+			"ZINTERCARD", "1", "h1", "LIMIT",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"ZINTERCARD", "1", "h1", "foo",
+			proto.Error(msgSyntaxError),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"ZINTERCARD", "2", "h1", "str",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
+func TestZrangestore(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.ZAdd("z", 1, "one")
+	s.ZAdd("z", 2, "two")
+	s.ZAdd("z", 3, "three")
+	s.ZAdd("z", 4, "four")
+
+	mustDo(t, c,
+		"ZRANGESTORE", "dst", "z", "0", "1",
+		proto.Int(2),
+	)
+	mustDo(t, c,
+		"ZRANGE", "dst", "0", "-1", "WITHSCORES",
+		proto.Strings("one", "1", "two", "2"),
+	)
+
+	mustDo(t, c,
+		"ZRANGESTORE", "dst", "z", "0", "0", "REV",
+		proto.Int(1),
+	)
+	mustDo(t, c,
+		"ZRANGE", "dst", "0", "-1",
+		proto.Strings("four"),
+	)
+
+	t.Run("byscore", func(t *testing.T) {
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "z", "(1", "+inf", "BYSCORE", "LIMIT", "1", "5",
+			proto.Int(2),
+		)
+		mustDo(t, c,
+			"ZRANGE", "dst", "0", "-1",
+			proto.Strings("three", "four"),
+		)
+
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "z", "3", "2", "BYSCORE", "REV",
+			proto.Int(2),
+		)
+		mustDo(t, c,
+			"ZRANGE", "dst", "0", "-1",
+			proto.Strings("two", "three"),
+		)
+	})
+
+	t.Run("bylex", func(t *testing.T) {
+		s.ZAdd("lex", 0, "a")
+		s.ZAdd("lex", 0, "b")
+		s.ZAdd("lex", 0, "c")
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "lex", "(a", "+", "BYLEX",
+			proto.Int(2),
+		)
+		mustDo(t, c,
+			"ZRANGE", "dst", "0", "-1",
+			proto.Strings("b", "c"),
+		)
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "lex", "+", "-", "BYLEX", "REV", "LIMIT", "0", "1",
+			proto.Int(1),
+		)
+		mustDo(t, c,
+			"ZRANGE", "dst", "0", "-1",
+			proto.Strings("c"),
+		)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		s.Set("str", "value")
+		must0(t, c,
+			"ZRANGESTORE", "str", "z", "10", "20",
+		)
+		equals(t, false, s.Exists("str"))
+		must0(t, c,
+			"ZRANGESTORE", "dst", "nosuch", "0", "-1",
+		)
+		equals(t, false, s.Exists("dst"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "z", "0",
+			proto.Error(errWrongNumber("zrangestore")),
+		)
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "z", "0", "noint",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "z", "0", "nofloat", "BYSCORE",
+			proto.Error(msgInvalidMinMax),
+		)
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "z", "a", "b", "BYLEX",
+			proto.Error(msgInvalidRangeItem),
+		)
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "z", "0", "1", "WITHSCORES",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "z", "0", "1", "LIMIT", "0", "1",
+			proto.Error(msgLimitCombination),
+		)
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "z", "0", "1", "BYSCORE", "BYLEX",
+			proto.Error(msgSyntaxError),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"ZRANGESTORE", "dst", "str", "0", "1",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
+func TestZrandmember(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.ZAdd("z", 1, "one")
+	s.ZAdd("z", 2, "two")
+	s.ZAdd("z", 3, "three")
+
+	s.Seed(42)
+	// No count
+	{
+		res, err := c.Do("ZRANDMEMBER", "z")
+		ok(t, err)
+		assert(t, res == proto.String("one") ||
+			res == proto.String("two") ||
+			res == proto.String("three"),
+			"zrandmember got something",
+		)
+	}
+
+	// Positive count
+	mustDo(t, c,
+		"ZRANDMEMBER", "z", "2",
+		proto.Strings("one", "two"),
+	)
+
+	// Negative count
+	mustDo(t, c,
+		"ZRANDMEMBER", "z", "-4",
+		proto.Strings("three", "three", "two", "two"),
+	)
+
+	// With scores
+	mustDo(t, c,
+		"ZRANDMEMBER", "z", "-2", "WITHSCORES",
+		proto.Strings("three", "3", "one", "1"),
+	)
+
+	// Count larger than the set
+	{
+		res, err := c.Do("ZRANDMEMBER", "z", "10")
+		ok(t, err)
+		members, err := proto.ReadStrings(res)
+		ok(t, err)
+		sort.Strings(members)
+		equals(t, []string{"one", "three", "two"}, members)
+	}
+
+	// a nonexisting key
+	mustNil(t, c,
+		"ZRANDMEMBER", "nosuch",
+	)
+	mustDo(t, c,
+		"ZRANDMEMBER", "nosuch", "2",
+		proto.Strings(),
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"ZRANDMEMBER",
+			proto.Error(errWrongNumber("zrandmember")),
+		)
+		mustDo(t, c,
+			"ZRANDMEMBER", "z", "noint",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"ZRANDMEMBER", "z", "1", "nowithscores",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"ZRANDMEMBER", "z", "1", "WITHSCORES", "toomany",
+			proto.Error(msgSyntaxError),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"ZRANDMEMBER", "str",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
 func TestSSRange(t *testing.T) {
 	ss := newSortedSet()
 	ss.set(1.0, "key1")
@@ -1866,3 +2370,219 @@ func TestSortedSetPopMax(t *testing.T) {
 		)
 	})
 }
+
+func TestZmpop(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.ZAdd("z", 1, "one")
+	s.ZAdd("z", 2, "two")
+	s.ZAdd("z", 3, "three")
+
+	mustDo(t, c,
+		"ZMPOP", "2", "nosuch", "z", "MIN",
+		proto.Array(
+			proto.String("z"),
+			proto.Array(proto.Strings("one", "1")),
+		),
+	)
+	mustDo(t, c,
+		"ZMPOP", "1", "z", "max", "COUNT", "5",
+		proto.Array(
+			proto.String("z"),
+			proto.Array(
+				proto.Strings("three", "3"),
+				proto.Strings("two", "2"),
+			),
+		),
+	)
+	equals(t, false, s.Exists("z"))
+	mustDo(t, c,
+		"ZMPOP", "1", "z", "MIN",
+		proto.NilList,
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"ZMPOP", "1", "z",
+			proto.Error(errWrongNumber("zmpop")),
+		)
+		mustDo(t, c,
+			"ZMPOP", "0", "z", "MIN",
+			proto.Error(msgNumkeysPositive),
+		)
+		mustDo(t, c,
+			"ZMPOP", "2", "z", "MIN",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"ZMPOP", "1", "z", "MIDDLE",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"ZMPOP", "1", "z", "MIN", "COUNT", "0",
+			proto.Error(msgCountPositive),
+		)
+		mustDo(t, c,
+			"ZMPOP", "1", "z", "MIN", "COUNT", "1", "COUNT", "1",
+			proto.Error(msgSyntaxError),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"ZMPOP", "1", "str", "MIN",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
+func TestBzmpop(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	t.Run("basic", func(t *testing.T) {
+		s.ZAdd("z", 1, "one")
+		mustDo(t, c,
+			"BZMPOP", "1", "1", "z", "MIN",
+			proto.Array(
+				proto.String("z"),
+				proto.Array(proto.Strings("one", "1")),
+			),
+		)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		mustDo(t, c,
+			"BZMPOP", "0.01", "1", "z", "MIN",
+			proto.NilList,
+		)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		got := make(chan string, 1)
+		go func() {
+			c2, err := proto.Dial(s.Addr())
+			ok(t, err)
+			defer c2.Close()
+			v, err := c2.Do("BZMPOP", "0", "2", "y", "z", "MAX", "COUNT", "2")
+			ok(t, err)
+			got <- v
+		}()
+		time.Sleep(30 * time.Millisecond)
+
+		mustDo(t, c,
+			"ZADD", "z", "1", "one", "2", "two", "3", "three",
+			proto.Int(3),
+		)
+		equals(t,
+			proto.Array(
+				proto.String("z"),
+				proto.Array(
+					proto.Strings("three", "3"),
+					proto.Strings("two", "2"),
+				),
+			),
+			<-got,
+		)
+		members, err := s.ZMembers("z")
+		ok(t, err)
+		equals(t, []string{"one"}, members)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"BZMPOP", "1", "1", "z",
+			proto.Error(errWrongNumber("bzmpop")),
+		)
+		mustDo(t, c,
+			"BZMPOP", "-1", "1", "z", "MIN",
+			proto.Error(msgNegTimeout),
+		)
+		mustDo(t, c,
+			"BZMPOP", "inf", "1", "z", "MIN",
+			proto.Error(msgInvalidTimeout),
+		)
+		mustDo(t, c,
+			"BZMPOP", "1", "0", "z", "MIN",
+			proto.Error(msgNumkeysPositive),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"BZMPOP", "1", "1", "str", "MIN",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
+func TestBzpopmin(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	t.Run("basic", func(t *testing.T) {
+		s.ZAdd("z", 1, "one")
+		s.ZAdd("z", 2, "two")
+		mustDo(t, c,
+			"BZPOPMIN", "nosuch", "z", "1",
+			proto.Strings("z", "one", "1"),
+		)
+		mustDo(t, c,
+			"BZPOPMAX", "z", "1",
+			proto.Strings("z", "two", "2"),
+		)
+		equals(t, false, s.Exists("z"))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		mustDo(t, c,
+			"BZPOPMIN", "z", "0.01",
+			proto.NilList,
+		)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		got := make(chan string, 1)
+		go func() {
+			c2, err := proto.Dial(s.Addr())
+			ok(t, err)
+			defer c2.Close()
+			v, err := c2.Do("BZPOPMAX", "z", "0")
+			ok(t, err)
+			got <- v
+		}()
+		time.Sleep(30 * time.Millisecond)
+
+		s.ZAdd("z", 3.5, "job")
+		equals(t, proto.Strings("z", "job", "3.5"), <-got)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"BZPOPMIN", "z",
+			proto.Error(errWrongNumber("bzpopmin")),
+		)
+		mustDo(t, c,
+			"BZPOPMIN", "z", "-1",
+			proto.Error(msgNegTimeout),
+		)
+		mustDo(t, c,
+			"BZPOPMAX", "z", "foo",
+			proto.Error(msgInvalidTimeout),
+		)
+		s.Set("str", "value")
+		mustDo(t, c,
+			"BZPOPMIN", "str", "1",
+			proto.Error(msgWrongType),
+		)
+	})
+}
@@ -25,6 +25,8 @@ func commandsStream(m *Miniredis) {
 	m.srv.Register("XREADGROUP", m.cmdXreadgroup)
 	m.srv.Register("XACK", m.cmdXack)
 	m.srv.Register("XDEL", m.cmdXdel)
+	m.srv.Register("XDELEX", m.cmdXdelex)
+	m.srv.Register("XACKDEL", m.cmdXackdel)
 	m.srv.Register("XPENDING", m.cmdXpending)
 }
 
@@ -47,10 +49,15 @@ func (m *Miniredis) cmdXadd(c *server.Peer, cmd string, args []string) {
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 
 		maxlen := -1
+		approx := false
 		if strings.ToLower(args[0]) == "maxlen" {
 			args = args[1:]
-			// we don't treat "~" special
-			if args[0] == "~" {
+			// "~" is exact, unless SetStreamApproxTrim() is used
+			switch args[0] {
+			case "~":
+				approx = true
+				args = args[1:]
+			case "=":
 				args = args[1:]
 			}
 			n, err := strconv.Atoi(args[0])
@@ -104,10 +111,20 @@ func (m *Miniredis) cmdXadd(c *server.Peer, cmd string, args []string) {
 			}
 			return
 		}
+		m.notify(db.id, notifyStream, "xadd", key)
 		if maxlen >= 0 {
-			s.trim(maxlen)
+			n := len(s.entries)
+			if approx {
+				s.trimApprox(maxlen, m.streamTrimBlock)
+			} else {
+				s.trim(maxlen)
+			}
+			if len(s.entries) != n {
+				m.notify(db.id, notifyStream, "xtrim", key)
+			}
 		}
-		db.keyVersion[key]++
+		db.markDirty(key)
+		m.keyReady(db.id, key)
 
 		c.WriteBulk(newID)
 	})
@@ -185,12 +202,12 @@ func (m *Miniredis) makeCmdXrange(reverse bool) server.Cmd {
 		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 			start, err := formatStreamRangeBound(startKey, true, reverse)
 			if err != nil {
-				c.WriteError(msgInvalidStreamID)
+				c.WriteError(streamRangeError(err))
 				return
 			}
 			end, err := formatStreamRangeBound(endKey, false, reverse)
 			if err != nil {
-				c.WriteError(msgInvalidStreamID)
+				c.WriteError(streamRangeError(err))
 				return
 			}
 			count, err := strconv.Atoi(countArg)
@@ -211,43 +228,18 @@ func (m *Miniredis) makeCmdXrange(reverse bool) server.Cmd {
 				return
 			}
 
-			var entries = db.streamKeys[key].entries
-			if reverse {
-				entries = reversedStreamEntries(entries)
-			}
-			if count == 0 {
-				count = len(entries)
-			}
-
 			var returnedEntries []StreamEntry
-			for _, entry := range entries {
-				if len(returnedEntries) == count {
-					break
+			if reverse {
+				returnedEntries = db.streamKeys[key].between(end, start)
+				if count > 0 && len(returnedEntries) > count {
+					returnedEntries = returnedEntries[len(returnedEntries)-count:]
 				}
-
-				if !reverse {
-					// Break if entry ID > end
-					if streamCmp(entry.ID, end) == 1 {
-						break
-					}
-
-					// Continue if entry ID < start
-					if streamCmp(entry.ID, start) == -1 {
-						continue
-					}
-				} else {
-					// Break if entry iD < end
-					if streamCmp(entry.ID, end) == -1 {
-						break
-					}
-
-					// Continue if entry ID > start.
-					if streamCmp(entry.ID, start) == 1 {
-						continue
-					}
+				returnedEntries = reversedStreamEntries(returnedEntries)
+			} else {
+				returnedEntries = db.streamKeys[key].between(start, end)
+				if count > 0 && len(returnedEntries) > count {
+					returnedEntries = returnedEntries[:count]
 				}
-
-				returnedEntries = append(returnedEntries, entry)
 			}
 
 			c.WriteLen(len(returnedEntries))
@@ -265,9 +257,18 @@ func (m *Miniredis) makeCmdXrange(reverse bool) server.Cmd {
 
 // XGROUP
 func (m *Miniredis) cmdXgroup(c *server.Peer, cmd string, args []string) {
-	if (len(args) == 4 || len(args) == 5) && strings.ToUpper(args[0]) == "CREATE" {
+	if len(args) == 0 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+
+	switch subCmd := strings.ToUpper(args[0]); {
+	case subCmd == "CREATE" && (len(args) == 4 || len(args) == 5):
 		m.cmdXgroupCreate(c, cmd, args)
-	} else {
+	case subCmd == "SETID":
+		m.cmdXgroupSetid(c, cmd, args)
+	default:
 		j := strings.Join(args, " ")
 		err := fmt.Sprintf("ERR 'XGROUP %s' not supported", j)
 		setDirty(c)
@@ -302,11 +303,81 @@ func (m *Miniredis) cmdXgroupCreate(c *server.Peer, cmd string, args []string) {
 			c.WriteError(err.Error())
 			return
 		}
+		db.markDirty(stream)
+		m.notify(db.id, notifyStream, "xgroup-create", stream)
 
 		c.WriteOK()
 	})
 }
 
+// XGROUP SETID
+func (m *Miniredis) cmdXgroupSetid(c *server.Peer, cmd string, args []string) {
+	// XGROUP SETID key group id|$ [ENTRIESREAD entries-read]
+	if len(args) != 4 && len(args) != 6 {
+		setDirty(c)
+		c.WriteError(fmt.Sprintf(msgFXgroupUsage, args[0]))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var (
+		key, group, id = args[1], args[2], args[3]
+		entriesRead    = -1
+	)
+	if len(args) == 6 {
+		if strings.ToUpper(args[4]) != "ENTRIESREAD" {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		n, err := strconv.Atoi(args[5])
+		if err != nil {
+			setDirty(c)
+			c.WriteError(msgInvalidInt)
+			return
+		}
+		if n < 0 && n != -1 {
+			setDirty(c)
+			c.WriteError(msgXgroupEntriesRead)
+			return
+		}
+		entriesRead = n
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		s, err := db.stream(key)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if s == nil {
+			c.WriteError(msgXgroupKeyNotFound)
+			return
+		}
+		g, ok := s.groups[group]
+		if !ok {
+			c.WriteError(errNoGroup(key, group).Error())
+			return
+		}
+
+		if err := g.setID(id, entriesRead); err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		db.markDirty(key)
+		m.notify(db.id, notifyStream, "xgroup-setid", key)
+		m.keyReady(db.id, key)
+		c.WriteOK()
+	})
+}
+
 // XINFO
 func (m *Miniredis) cmdXinfo(c *server.Peer, cmd string, args []string) {
 	if len(args) < 1 {
@@ -317,7 +388,11 @@ func (m *Miniredis) cmdXinfo(c *server.Peer, cmd string, args []string) {
 	switch strings.ToUpper(args[0]) {
 	case "STREAM":
 		m.cmdXinfoStream(c, args[1:])
-	case "CONSUMERS", "GROUPS", "HELP":
+	case "GROUPS":
+		m.cmdXinfoGroups(c, args[1:])
+	case "CONSUMERS":
+		m.cmdXinfoConsumers(c, args[1:])
+	case "HELP":
 		err := fmt.Sprintf("'XINFO %s' not supported", strings.Join(args, " "))
 		setDirty(c)
 		c.WriteError(err)
@@ -350,9 +425,129 @@ func (m *Miniredis) cmdXinfoStream(c *server.Peer, args []string) {
 			return
 		}
 
-		c.WriteMapLen(1)
+		c.WriteMapLen(6)
 		c.WriteBulk("length")
 		c.WriteInt(len(s.entries))
+		c.WriteBulk("last-generated-id")
+		c.WriteBulk(s.lastID())
+		c.WriteBulk("max-deleted-entry-id")
+		c.WriteBulk(s.maxDeletedID)
+		c.WriteBulk("entries-added")
+		c.WriteInt(s.entriesAdded)
+		c.WriteBulk("recorded-first-entry-id")
+		c.WriteBulk(s.firstID())
+		c.WriteBulk("groups")
+		c.WriteInt(len(s.groups))
+	})
+}
+
+// XINFO GROUPS
+func (m *Miniredis) cmdXinfoGroups(c *server.Peer, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber("XINFO"))
+		return
+	}
+	key := args[0]
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		s, err := db.stream(key)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if s == nil {
+			c.WriteError(msgKeyNotFound)
+			return
+		}
+
+		var names []string
+		for name := range s.groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		c.WriteLen(len(names))
+		for _, name := range names {
+			g := s.groups[name]
+
+			c.WriteMapLen(6)
+			c.WriteBulk("name")
+			c.WriteBulk(name)
+			c.WriteBulk("consumers")
+			c.WriteInt(len(g.consumers))
+			c.WriteBulk("pending")
+			c.WriteInt(len(g.pending))
+			c.WriteBulk("last-delivered-id")
+			c.WriteBulk(g.lastID)
+			c.WriteBulk("entries-read")
+			if g.entriesRead == -1 {
+				c.WriteNull()
+			} else {
+				c.WriteInt(g.entriesRead)
+			}
+			c.WriteBulk("lag")
+			if lag, ok := g.lag(); ok {
+				c.WriteInt(lag)
+			} else {
+				c.WriteNull()
+			}
+		}
+	})
+}
+
+// XINFO CONSUMERS
+func (m *Miniredis) cmdXinfoConsumers(c *server.Peer, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber("XINFO"))
+		return
+	}
+	key, group := args[0], args[1]
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		s, err := db.stream(key)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if s == nil {
+			c.WriteError(msgKeyNotFound)
+			return
+		}
+		g, ok := s.groups[group]
+		if !ok {
+			c.WriteError(errNoGroup(key, group).Error())
+			return
+		}
+
+		var names []string
+		for name := range g.consumers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		now := m.effectiveNow()
+		c.WriteLen(len(names))
+		for _, name := range names {
+			con := g.consumers[name]
+
+			c.WriteMapLen(4)
+			c.WriteBulk("name")
+			c.WriteBulk(name)
+			c.WriteBulk("pending")
+			c.WriteInt(g.pendingCount(name))
+			c.WriteBulk("idle")
+			c.WriteInt(int(now.Sub(con.seenTime).Milliseconds()))
+			c.WriteBulk("inactive")
+			if con.activeTime.IsZero() {
+				c.WriteInt(-1)
+			} else {
+				c.WriteInt(int(now.Sub(con.activeTime).Milliseconds()))
+			}
+		}
 	})
 }
 
@@ -469,6 +664,7 @@ parsing:
 		m,
 		c,
 		opts.blockTimeout,
+		opts.streams,
 		func(c *server.Peer, ctx *connCtx) bool {
 			db := m.db(ctx.selectedDB)
 			res, err := xreadgroup(
@@ -522,7 +718,15 @@ func xreadgroup(
 		if _, err := parseStreamID(id); id != `>` && err != nil {
 			return nil, err
 		}
+		_, known := g.consumers[consumer]
 		entries := g.readGroup(now, consumer, id, count, noack)
+		if _, ok := g.consumers[consumer]; ok && !known {
+			db.markDirty(key)
+			db.master.notify(db.id, notifyStream, "xgroup-createconsumer", key)
+		}
+		if len(entries) > 0 {
+			db.markDirty(key)
+		}
 		if id == `>` && len(entries) == 0 {
 			continue
 		}
@@ -559,6 +763,9 @@ func (m *Miniredis) cmdXack(c *server.Peer, cmd string, args []string) {
 			c.WriteError(err.Error())
 			return
 		}
+		if cnt > 0 {
+			db.markDirty(key)
+		}
 		c.WriteInt(cnt)
 	})
 }
@@ -590,11 +797,167 @@ func (m *Miniredis) cmdXdel(c *server.Peer, cmd string, args []string) {
 			c.WriteError(err.Error())
 			return
 		}
-		db.keyVersion[stream]++
+		db.markDirty(stream)
+		if n > 0 {
+			m.notify(db.id, notifyStream, "xdel", stream)
+		}
 		c.WriteInt(n)
 	})
 }
 
+// reference policies for XDELEX and XACKDEL
+const (
+	refKeep  = "KEEPREF"
+	refDel   = "DELREF"
+	refAcked = "ACKED"
+)
+
+// parses "[KEEPREF | DELREF | ACKED] IDS numids id [id ...]"
+func parseXdelexArgs(args []string) (string, []string, error) {
+	policy := refKeep
+	if len(args) > 0 {
+		switch p := strings.ToUpper(args[0]); p {
+		case refKeep, refDel, refAcked:
+			policy = p
+			args = args[1:]
+		}
+	}
+	if len(args) < 2 || strings.ToUpper(args[0]) != "IDS" {
+		return "", nil, errors.New(msgSyntaxError)
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n <= 0 {
+		return "", nil, errors.New(msgNumIDs)
+	}
+	ids := args[2:]
+	if len(ids) != n {
+		return "", nil, errors.New(msgNumIDsMismatch)
+	}
+	for _, id := range ids {
+		if _, err := parseStreamID(id); err != nil {
+			return "", nil, err
+		}
+	}
+	return policy, ids, nil
+}
+
+// xdelex deletes a single entry, following a XDELEX reference policy.
+// Returns -1 if there is no such entry, 1 if it was deleted, and 2 if it is
+// still referenced.
+func xdelex(s *streamKey, id, policy string) int {
+	if _, e := s.get(id); e == nil {
+		return -1
+	}
+	if policy == refAcked && s.referenced(id) {
+		return 2
+	}
+	n, _ := s.delete([]string{id})
+	if n == 0 {
+		return -1
+	}
+	if policy == refDel {
+		s.deleteRefs(id)
+	}
+	return 1
+}
+
+// XDELEX
+func (m *Miniredis) cmdXdelex(c *server.Peer, cmd string, args []string) {
+	if len(args) < 4 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	policy, ids, err := parseXdelexArgs(args[1:])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(err.Error())
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+		s, err := db.stream(key)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+
+		deleted := false
+		c.WriteLen(len(ids))
+		for _, id := range ids {
+			if s == nil {
+				c.WriteInt(-1)
+				continue
+			}
+			res := xdelex(s, id, policy)
+			deleted = deleted || res == 1
+			c.WriteInt(res)
+		}
+		if deleted {
+			db.markDirty(key)
+			m.notify(db.id, notifyStream, "xdel", key)
+		}
+	})
+}
+
+// XACKDEL
+func (m *Miniredis) cmdXackdel(c *server.Peer, cmd string, args []string) {
+	if len(args) < 5 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, group := args[0], args[1]
+	policy, ids, err := parseXdelexArgs(args[2:])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(err.Error())
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+		g, err := db.streamGroup(key, group)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if g == nil {
+			c.WriteError(errReadgroup(key, group).Error())
+			return
+		}
+
+		deleted := false
+		c.WriteLen(len(ids))
+		for _, id := range ids {
+			g.ack([]string{id})
+			res := xdelex(g.stream, id, policy)
+			deleted = deleted || res == 1
+			c.WriteInt(res)
+		}
+		db.markDirty(key)
+		if deleted {
+			m.notify(db.id, notifyStream, "xdel", key)
+		}
+	})
+}
+
 // XREAD
 func (m *Miniredis) cmdXread(c *server.Peer, cmd string, args []string) {
 	if len(args) < 3 {
@@ -642,6 +1005,9 @@ parsing:
 
 			opts.streams, opts.ids = args[0:len(args)/2], args[len(args)/2:]
 			for _, id := range opts.ids {
+				if id == "$" || id == "+" {
+					continue
+				}
 				if _, err := parseStreamID(id); err != nil {
 					setDirty(c)
 					c.WriteError(msgInvalidStreamID)
@@ -665,18 +1031,24 @@ parsing:
 	if !opts.block {
 		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 			db := m.db(ctx.selectedDB)
-			res := xread(db, opts.streams, opts.ids, opts.count)
+			ids := xreadIDs(db, opts.streams, opts.ids)
+			res := xread(db, opts.streams, ids, opts.count)
 			writeXread(c, opts.streams, res)
 		})
 		return
 	}
+	var ids []string // "$" and "+" are resolved when we start
 	blocking(
 		m,
 		c,
 		opts.blockTimeout,
+		opts.streams,
 		func(c *server.Peer, ctx *connCtx) bool {
 			db := m.db(ctx.selectedDB)
-			res := xread(db, opts.streams, opts.ids, opts.count)
+			if ids == nil {
+				ids = xreadIDs(db, opts.streams, opts.ids)
+			}
+			res := xread(db, opts.streams, ids, opts.count)
 			if len(res) == 0 {
 				return false
 			}
@@ -689,6 +1061,31 @@ parsing:
 	)
 }
 
+// xreadIDs replaces "$" with the last ID of the stream, and "+" with the ID
+// just before that, so the last entry will be returned.
+func xreadIDs(db *RedisDB, streams []string, ids []string) []string {
+	res := make([]string, len(ids))
+	for i, id := range ids {
+		switch id {
+		case "$":
+			id = "0-0"
+			if s, ok := db.streamKeys[streams[i]]; ok {
+				id = s.lastID()
+			}
+		case "+":
+			id = "0-0"
+			if s, ok := db.streamKeys[streams[i]]; ok && len(s.entries) > 0 {
+				last := s.entries[len(s.entries)-1].ID
+				if prev, ok := streamDecrID(last); ok {
+					id = prev
+				}
+			}
+		}
+		res[i] = id
+	}
+	return res
+}
+
 func xread(db *RedisDB, streams []string, ids []string, count int) map[string][]StreamEntry {
 	res := map[string][]StreamEntry{}
 	for i := range streams {
@@ -699,28 +1096,13 @@ func xread(db *RedisDB, streams []string, ids []string, count int) map[string][]
 		if !ok {
 			continue
 		}
-		entries := s.entries
-		if len(entries) == 0 {
-			continue
-		}
 
-		entryCount := count
-		if entryCount == 0 {
-			entryCount = len(entries)
-		}
-
-		var returnedEntries []StreamEntry
-		for _, entry := range entries {
-			if len(returnedEntries) == entryCount {
-				break
-			}
-			if streamCmp(entry.ID, id) <= 0 {
-				continue
-			}
-			returnedEntries = append(returnedEntries, entry)
+		entries := s.after(id)
+		if count > 0 && len(entries) > count {
+			entries = entries[:count]
 		}
-		if len(returnedEntries) > 0 {
-			res[stream] = returnedEntries
+		if len(entries) > 0 {
+			res[stream] = entries
 		}
 	}
 	return res
@@ -776,13 +1158,15 @@ func (m *Miniredis) cmdXpending(c *server.Peer, cmd string, args []string) {
 
 		start_, err := formatStreamRangeBound(args[0], true, false)
 		if err != nil {
-			c.WriteError(msgInvalidStreamID)
+			setDirty(c)
+			c.WriteError(streamRangeError(err))
 			return
 		}
 		start = start_
 		end_, err := formatStreamRangeBound(args[1], false, false)
 		if err != nil {
-			c.WriteError(msgInvalidStreamID)
+			setDirty(c)
+			c.WriteError(streamRangeError(err))
 			return
 		}
 		end = end_
@@ -921,6 +1305,15 @@ func writeXpending(
 	}
 }
 
+// streamRangeError gives the error message for formatStreamRangeBound()
+// errors.
+func streamRangeError(err error) string {
+	if err == errInvalidEntryID {
+		return msgInvalidStreamID
+	}
+	return err.Error()
+}
+
 func parseBlock(cmd string, args []string, block *bool, timeout *time.Duration) error {
 	if len(args) < 2 {
 		return errors.New(errWrongNumber(cmd))
@@ -33,11 +33,22 @@ func TestStream(t *testing.T) {
 			"TYPE", "s",
 			proto.Inline("stream"),
 		)
+		mustDo(t, c,
+			"OBJECT", "ENCODING", "s",
+			proto.String("stream"),
+		)
 	})
 
 	mustDo(t, c,
 		"XINFO", "STREAM", "s",
-		proto.Array(proto.String("length"), proto.Int(1)),
+		proto.Array(
+			proto.String("length"), proto.Int(1),
+			proto.String("last-generated-id"), proto.String("1234567-89"),
+			proto.String("max-deleted-entry-id"), proto.String("0-0"),
+			proto.String("entries-added"), proto.Int(1),
+			proto.String("recorded-first-entry-id"), proto.String("1234567-89"),
+			proto.String("groups"), proto.Int(0),
+		),
 	)
 
 	now := time.Date(2001, 1, 1, 4, 4, 5, 4000000, time.UTC)
@@ -58,6 +69,11 @@ func TestStream(t *testing.T) {
 		ok(t, err)
 		equals(t, "978321845004-0", id)
 
+		_, err = s.XAdd("s1", "*", []string{"name"})
+		mustFail(t, err, "ERR wrong number of arguments for XADD")
+		_, err = s.XAdd("s1", "*", nil)
+		mustFail(t, err, "ERR wrong number of arguments for XADD")
+
 		stream, err := s.Stream("s1")
 		ok(t, err)
 		equals(t, 2, len(stream))
@@ -69,13 +85,63 @@ func TestStream(t *testing.T) {
 			ID:     "978321845004-0",
 			Values: []string{"name", "baz"},
 		}, stream[1])
+
+		// it's a copy
+		stream[0].Values[1] = "changed"
+		_, err = s.DB(0).XAdd("s1", "*", []string{"name", "qux"})
+		ok(t, err)
+		stream, err = s.Stream("s1")
+		ok(t, err)
+		equals(t, 3, len(stream))
+		equals(t, []string{"name", "bar"}, stream[0].Values)
+
+		stream, err = s.Stream("nosuch")
+		ok(t, err)
+		equals(t, 0, len(stream))
+	})
+
+	t.Run("SetStreamLastID", func(t *testing.T) {
+		_, err := s.XAdd("s2", "0-5", []string{"name", "foo"})
+		ok(t, err)
+		ok(t, s.SetStreamLastID("s2", "10-3"))
+		_, err = s.XAdd("s2", "10-3", []string{"name", "bar"})
+		mustFail(t, err, msgStreamIDTooSmall)
+		id, err := s.XAdd("s2", "", []string{"name", "bar"})
+		ok(t, err)
+		equals(t, "978321845004-0", id)
+
+		mustFail(t, s.SetStreamLastID("s2", "1-0"), msgXsetidSmaller)
+		mustFail(t, s.SetStreamLastID("s2", "foo"), msgInvalidStreamID)
+
+		// new stream
+		ok(t, s.SetStreamLastID("s3", "1-10"))
+		mustDo(t, c,
+			"XADD", "s3", "1-10", "name", "foo",
+			proto.Error(msgStreamIDTooSmall),
+		)
+		mustDo(t, c,
+			"XADD", "s3", "1-11", "name", "foo",
+			proto.String("1-11"),
+		)
+		mustFail(t, s.SetStreamLastID("nostream", "foo"), msgInvalidStreamID)
+		equals(t, false, s.Exists("nostream"))
+
+		s.Set("str", "value")
+		mustFail(t, s.SetStreamLastID("str", "1-0"), msgWrongType)
 	})
 
 	useRESP3(t, c)
 	t.Run("resp3", func(t *testing.T) {
 		mustDo(t, c,
 			"XINFO", "STREAM", "s",
-			proto.Map(proto.String("length"), proto.Int(1)),
+			proto.Map(
+				proto.String("length"), proto.Int(1),
+				proto.String("last-generated-id"), proto.String("1234567-89"),
+				proto.String("max-deleted-entry-id"), proto.String("0-0"),
+				proto.String("entries-added"), proto.Int(1),
+				proto.String("recorded-first-entry-id"), proto.String("1234567-89"),
+				proto.String("groups"), proto.Int(0),
+			),
 		)
 	})
 }
@@ -151,6 +217,27 @@ func TestStreamAdd(t *testing.T) {
 		equals(t, 10, len(nowz))
 	})
 
+	t.Run("XADD MAXLEN approximate", func(t *testing.T) {
+		s.SetStreamApproxTrim(4)
+		defer s.SetStreamApproxTrim(0)
+
+		for i := 0; i < 100; i++ {
+			_, err := c.Do("XADD", "nowa", "MAXLEN", "~", "10", "*", "one", "1")
+			ok(t, err)
+			nowa, _ := s.Stream("nowa")
+			assert(t, len(nowa) >= 10 || len(nowa) == i+1, "too many deleted entries")
+			assert(t, len(nowa) < 14, "not enough deleted entries")
+		}
+		nowa, _ := s.Stream("nowa")
+		equals(t, 12, len(nowa))
+
+		// exact trimming is still exact
+		_, err := c.Do("XADD", "nowa", "MAXLEN", "=", "10", "*", "one", "1")
+		ok(t, err)
+		nowa, _ = s.Stream("nowa")
+		equals(t, 10, len(nowa))
+	})
+
 	t.Run("error cases", func(t *testing.T) {
 		// Wrong type of key
 		mustOK(t, c,
@@ -289,6 +376,53 @@ func TestStreamRange(t *testing.T) {
 		)
 	})
 
+	t.Run("exclusive ranges", func(t *testing.T) {
+		mustDo(t, c,
+			"XRANGE", "planets", "(1-0", "(4-1",
+			proto.Array(
+				proto.Array(proto.String("2-1"), proto.Strings("name", "Earth", "greek-god", "", "idx", "3")),
+				proto.Array(proto.String("3-0"), proto.Strings("greek-god", "Ares", "name", "Mars", "idx", "4")),
+			),
+		)
+		mustDo(t, c,
+			"XRANGE", "planets", "(2", "+", "COUNT", "1",
+			proto.Array(
+				proto.Array(proto.String("2-1"), proto.Strings("name", "Earth", "greek-god", "", "idx", "3")),
+			),
+		)
+		mustDo(t, c,
+			"XREVRANGE", "planets", "(3-0", "(1-0",
+			proto.Array(
+				proto.Array(proto.String("2-1"), proto.Strings("name", "Earth", "greek-god", "", "idx", "3")),
+			),
+		)
+		mustDo(t, c,
+			"XRANGE", "planets", "(2-1", "(3-0",
+			proto.Array(),
+		)
+
+		mustDo(t, c,
+			"XRANGE", "planets", "(18446744073709551615-18446744073709551615", "+",
+			proto.Error(msgStreamRangeStart),
+		)
+		mustDo(t, c,
+			"XRANGE", "planets", "-", "(0-0",
+			proto.Error(msgStreamRangeEnd),
+		)
+		mustDo(t, c,
+			"XRANGE", "planets", "(-", "+",
+			proto.Error(msgInvalidStreamID),
+		)
+		mustDo(t, c,
+			"XRANGE", "planets", "-", "(+",
+			proto.Error(msgInvalidStreamID),
+		)
+		mustDo(t, c,
+			"XRANGE", "planets", "(foo", "+",
+			proto.Error(msgInvalidStreamID),
+		)
+	})
+
 	t.Run("error cases", func(t *testing.T) {
 		mustOK(t, c, "SET", "str", "value")
 		mustDo(t, c,
@@ -432,6 +566,112 @@ func TestStreamRead(t *testing.T) {
 	})
 }
 
+func TestStreamReadBlock(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	t.Run("XADD", func(t *testing.T) {
+		got := goStrings(t, s, "XREAD", "BLOCK", "0", "STREAMS", "planets", "0")
+		time.Sleep(30 * time.Millisecond)
+
+		mustDo(t, c, "XADD", "other", "0-1", "name", "Mercury", proto.String("0-1"))
+		select {
+		case have := <-got:
+			t.Errorf("XREAD returned too early: %q", have)
+		case <-time.After(30 * time.Millisecond):
+		}
+
+		mustDo(t, c, "XADD", "planets", "0-1", "name", "Mercury", proto.String("0-1"))
+		select {
+		case have := <-got:
+			equals(t,
+				proto.Array(
+					proto.Array(proto.String("planets"), proto.Array(proto.Array(proto.String("0-1"), proto.Strings("name", "Mercury")))),
+				),
+				have,
+			)
+		case <-time.After(500 * time.Millisecond):
+			t.Error("XREAD took too long")
+		}
+	})
+
+	t.Run("direct", func(t *testing.T) {
+		got := goStrings(t, s, "XREAD", "BLOCK", "0", "STREAMS", "planets", "0-1")
+		time.Sleep(30 * time.Millisecond)
+
+		_, err := s.XAdd("planets", "0-2", []string{"name", "Venus"})
+		ok(t, err)
+		select {
+		case have := <-got:
+			equals(t,
+				proto.Array(
+					proto.Array(proto.String("planets"), proto.Array(proto.Array(proto.String("0-2"), proto.Strings("name", "Venus")))),
+				),
+				have,
+			)
+		case <-time.After(500 * time.Millisecond):
+			t.Error("XREAD took too long")
+		}
+	})
+
+	t.Run("$", func(t *testing.T) {
+		mustNilList(t, c, "XREAD", "STREAMS", "planets", "$")
+
+		got := goStrings(t, s, "XREAD", "BLOCK", "0", "STREAMS", "planets", "$")
+		time.Sleep(30 * time.Millisecond)
+
+		mustDo(t, c, "XADD", "planets", "0-3", "name", "Earth", proto.String("0-3"))
+		select {
+		case have := <-got:
+			equals(t,
+				proto.Array(
+					proto.Array(proto.String("planets"), proto.Array(proto.Array(proto.String("0-3"), proto.Strings("name", "Earth")))),
+				),
+				have,
+			)
+		case <-time.After(500 * time.Millisecond):
+			t.Error("XREAD took too long")
+		}
+	})
+
+	t.Run("+", func(t *testing.T) {
+		mustDo(t, c,
+			"XREAD", "STREAMS", "planets", "+",
+			proto.Array(
+				proto.Array(proto.String("planets"), proto.Array(proto.Array(proto.String("0-3"), proto.Strings("name", "Earth")))),
+			),
+		)
+		mustNilList(t, c, "XREAD", "STREAMS", "nosuch", "+")
+
+		got := goStrings(t, s, "XREAD", "BLOCK", "0", "STREAMS", "empty", "+")
+		time.Sleep(30 * time.Millisecond)
+
+		mustDo(t, c, "XADD", "empty", "0-1", "name", "Mercury", proto.String("0-1"))
+		select {
+		case have := <-got:
+			equals(t,
+				proto.Array(
+					proto.Array(proto.String("empty"), proto.Array(proto.Array(proto.String("0-1"), proto.Strings("name", "Mercury")))),
+				),
+				have,
+			)
+		case <-time.After(500 * time.Millisecond):
+			t.Error("XREAD took too long")
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		mustNilList(t, c, "XREAD", "BLOCK", "10", "STREAMS", "planets", "$")
+		s.Lock()
+		defer s.Unlock()
+		equals(t, 0, len(s.blocked))
+	})
+}
+
 // Test XINFO
 func TestStreamInfo(t *testing.T) {
 	s, err := Run()
@@ -453,8 +693,180 @@ func TestStreamInfo(t *testing.T) {
 
 	mustDo(t, c,
 		"XINFO", "STREAM", "planets",
-		proto.Array(proto.String("length"), proto.Int(1)),
+		proto.Array(
+			proto.String("length"), proto.Int(1),
+			proto.String("last-generated-id"), proto.String("0-1"),
+			proto.String("max-deleted-entry-id"), proto.String("0-0"),
+			proto.String("entries-added"), proto.Int(1),
+			proto.String("recorded-first-entry-id"), proto.String("0-1"),
+			proto.String("groups"), proto.Int(0),
+		),
 	)
+
+	t.Run("counters", func(t *testing.T) {
+		mustDo(t, c, "XADD", "moons", "0-1", "name", "Moon", proto.String("0-1"))
+		mustDo(t, c, "XADD", "moons", "0-2", "name", "Phobos", proto.String("0-2"))
+		mustDo(t, c, "XADD", "moons", "0-3", "name", "Deimos", proto.String("0-3"))
+		must1(t, c, "XDEL", "moons", "0-3")
+		mustDo(t, c, "XADD", "moons", "MAXLEN", "1", "0-4", "name", "Io", proto.String("0-4"))
+		must1(t, c, "XDEL", "moons", "0-4")
+		mustDo(t, c,
+			"XINFO", "STREAM", "moons",
+			proto.Array(
+				proto.String("length"), proto.Int(0),
+				proto.String("last-generated-id"), proto.String("0-4"),
+				proto.String("max-deleted-entry-id"), proto.String("0-4"),
+				proto.String("entries-added"), proto.Int(4),
+				proto.String("recorded-first-entry-id"), proto.String("0-0"),
+				proto.String("groups"), proto.Int(0),
+			),
+		)
+
+		// IDs don't go back
+		mustDo(t, c,
+			"XADD", "moons", "0-4", "name", "Europa",
+			proto.Error(msgStreamIDTooSmall),
+		)
+		mustDo(t, c, "XADD", "moons", "0-5", "name", "Europa", proto.String("0-5"))
+	})
+
+	t.Run("XINFO GROUPS", func(t *testing.T) {
+		mustDo(t, c,
+			"XINFO", "GROUPS", "planets",
+			proto.Array(),
+		)
+
+		mustOK(t, c, "XGROUP", "CREATE", "planets", "processing", "0")
+		mustOK(t, c, "XGROUP", "CREATE", "planets", "aaa", "$")
+		mustDo(t, c,
+			"XINFO", "GROUPS", "planets",
+			proto.Array(
+				proto.Array(
+					proto.String("name"), proto.String("aaa"),
+					proto.String("consumers"), proto.Int(0),
+					proto.String("pending"), proto.Int(0),
+					proto.String("last-delivered-id"), proto.String("0-1"),
+					proto.String("entries-read"), proto.Nil,
+					proto.String("lag"), proto.Int(0),
+				),
+				proto.Array(
+					proto.String("name"), proto.String("processing"),
+					proto.String("consumers"), proto.Int(0),
+					proto.String("pending"), proto.Int(0),
+					proto.String("last-delivered-id"), proto.String("0-0"),
+					proto.String("entries-read"), proto.Nil,
+					proto.String("lag"), proto.Int(1),
+				),
+			),
+		)
+
+		mustDo(t, c, "XADD", "planets", "0-2", "name", "Venus", proto.String("0-2"))
+		mustDo(t, c, "XADD", "planets", "0-3", "name", "Earth", proto.String("0-3"))
+		_, err := c.Do("XREADGROUP", "GROUP", "processing", "alice", "COUNT", "2", "STREAMS", "planets", ">")
+		ok(t, err)
+		mustDo(t, c,
+			"XINFO", "GROUPS", "planets",
+			proto.Array(
+				proto.Array(
+					proto.String("name"), proto.String("aaa"),
+					proto.String("consumers"), proto.Int(0),
+					proto.String("pending"), proto.Int(0),
+					proto.String("last-delivered-id"), proto.String("0-1"),
+					proto.String("entries-read"), proto.Nil,
+					proto.String("lag"), proto.Int(2),
+				),
+				proto.Array(
+					proto.String("name"), proto.String("processing"),
+					proto.String("consumers"), proto.Int(1),
+					proto.String("pending"), proto.Int(2),
+					proto.String("last-delivered-id"), proto.String("0-2"),
+					proto.String("entries-read"), proto.Int(2),
+					proto.String("lag"), proto.Int(1),
+				),
+			),
+		)
+
+		// acking doesn't change the lag
+		must1(t, c, "XACK", "planets", "processing", "0-1")
+		mustContain(t, c, "XINFO", "GROUPS", "planets", "$3\r\nlag\r\n:1\r\n")
+
+		// a deleted entry after the last delivered ID makes the lag unknown
+		must1(t, c, "XDEL", "planets", "0-3")
+		mustDo(t, c, "XADD", "planets", "0-4", "name", "Mars", proto.String("0-4"))
+		mustContain(t, c, "XINFO", "GROUPS", "planets", "$3\r\nlag\r\n$-1\r\n")
+
+		mustOK(t, c, "XGROUP", "SETID", "planets", "processing", "$", "ENTRIESREAD", "4")
+		mustContain(t, c, "XINFO", "GROUPS", "planets", "$3\r\nlag\r\n:0\r\n")
+
+		mustDo(t, c,
+			"XINFO", "GROUPS", "nosuch",
+			proto.Error(msgKeyNotFound),
+		)
+		mustDo(t, c,
+			"XINFO", "GROUPS",
+			proto.Error(errWrongNumber("xinfo")),
+		)
+		mustOK(t, c, "SET", "str", "value")
+		mustDo(t, c,
+			"XINFO", "GROUPS", "str",
+			proto.Error(msgWrongType),
+		)
+	})
+
+	t.Run("XINFO CONSUMERS", func(t *testing.T) {
+		now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+		s.SetTime(now)
+		mustOK(t, c, "XGROUP", "CREATE", "planets", "consumers", "$")
+		mustDo(t, c,
+			"XINFO", "CONSUMERS", "planets", "consumers",
+			proto.Array(),
+		)
+
+		// bob reads nothing
+		mustNilList(t, c, "XREADGROUP", "GROUP", "consumers", "bob", "STREAMS", "planets", ">")
+		mustDo(t, c, "XADD", "planets", "0-5", "name", "Jupiter", proto.String("0-5"))
+		s.SetTime(now.Add(2 * time.Second))
+		_, err := c.Do("XREADGROUP", "GROUP", "consumers", "alice", "STREAMS", "planets", ">")
+		ok(t, err)
+		s.FastForward(3 * time.Second)
+		mustDo(t, c,
+			"XINFO", "CONSUMERS", "planets", "consumers",
+			proto.Array(
+				proto.Array(
+					proto.String("name"), proto.String("alice"),
+					proto.String("pending"), proto.Int(1),
+					proto.String("idle"), proto.Int(3000),
+					proto.String("inactive"), proto.Int(3000),
+				),
+				proto.Array(
+					proto.String("name"), proto.String("bob"),
+					proto.String("pending"), proto.Int(0),
+					proto.String("idle"), proto.Int(5000),
+					proto.String("inactive"), proto.Int(-1),
+				),
+			),
+		)
+
+		g, err := s.StreamGroup("planets", "consumers")
+		ok(t, err)
+		equals(t, []StreamConsumer{
+			{Name: "alice", Pending: 1, SeenTime: now.Add(-time.Second), ActiveTime: now.Add(-time.Second)},
+			{Name: "bob", Pending: 0, SeenTime: now.Add(-3 * time.Second)},
+		}, g.Consumers)
+
+		mustDo(t, c,
+			"XINFO", "CONSUMERS", "planets", "nosuch",
+			proto.Error("NOGROUP No such consumer group 'nosuch' for key name 'planets'"),
+		)
+		mustDo(t, c,
+			"XINFO", "CONSUMERS", "nosuch", "consumers",
+			proto.Error(msgKeyNotFound),
+		)
+		mustDo(t, c,
+			"XINFO", "CONSUMERS", "planets",
+			proto.Error(errWrongNumber("xinfo")),
+		)
+	})
 }
 
 // Test XGROUP
@@ -478,6 +890,72 @@ func TestStreamGroup(t *testing.T) {
 	must0(t, c,
 		"XLEN", "s",
 	)
+
+	t.Run("XGROUP SETID", func(t *testing.T) {
+		mustDo(t, c,
+			"XADD", "planets", "0-1", "name", "Mercury",
+			proto.String("0-1"),
+		)
+		mustDo(t, c,
+			"XADD", "planets", "0-2", "name", "Venus",
+			proto.String("0-2"),
+		)
+		mustOK(t, c,
+			"XGROUP", "CREATE", "planets", "processing", "$",
+		)
+		mustNilList(t, c,
+			"XREADGROUP", "GROUP", "processing", "alice", "STREAMS", "planets", ">",
+		)
+
+		mustOK(t, c,
+			"XGROUP", "SETID", "planets", "processing", "0-1",
+		)
+		mustDo(t, c,
+			"XREADGROUP", "GROUP", "processing", "alice", "STREAMS", "planets", ">",
+			proto.Array(
+				proto.Array(proto.String("planets"), proto.Array(proto.Array(proto.String("0-2"), proto.Strings("name", "Venus")))),
+			),
+		)
+
+		mustOK(t, c,
+			"XGROUP", "SETID", "planets", "processing", "0", "ENTRIESREAD", "0",
+		)
+		mustOK(t, c,
+			"XGROUP", "SETID", "planets", "processing", "$",
+		)
+		mustNilList(t, c,
+			"XREADGROUP", "GROUP", "processing", "alice", "STREAMS", "planets", ">",
+		)
+
+		mustDo(t, c,
+			"XGROUP", "SETID", "nosuch", "processing", "0",
+			proto.Error(msgXgroupKeyNotFound),
+		)
+		mustDo(t, c,
+			"XGROUP", "SETID", "planets", "nosuch", "0",
+			proto.Error("NOGROUP No such consumer group 'nosuch' for key name 'planets'"),
+		)
+		mustDo(t, c,
+			"XGROUP", "SETID", "planets", "processing", "foo",
+			proto.Error(msgInvalidStreamID),
+		)
+		mustDo(t, c,
+			"XGROUP", "SETID", "planets", "processing",
+			proto.Error("ERR unknown subcommand or wrong number of arguments for 'SETID'. Try XGROUP HELP."),
+		)
+		mustDo(t, c,
+			"XGROUP", "SETID", "planets", "processing", "0", "ENTRIESREAD", "foo",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"XGROUP", "SETID", "planets", "processing", "0", "ENTRIESREAD", "-2",
+			proto.Error(msgXgroupEntriesRead),
+		)
+		mustDo(t, c,
+			"XGROUP", "SETID", "planets", "processing", "0", "FOO", "1",
+			proto.Error(msgSyntaxError),
+		)
+	})
 }
 
 // Test XREADGROUP
@@ -627,6 +1105,177 @@ func TestStreamAck(t *testing.T) {
 	)
 }
 
+// Test XDELEX and XACKDEL
+func TestStreamDelex(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	mustOK(t, c, "XGROUP", "CREATE", "planets", "processing", "0", "MKSTREAM")
+	mustOK(t, c, "XGROUP", "CREATE", "planets", "other", "0")
+	for _, id := range []string{"0-1", "0-2", "0-3", "0-4"} {
+		mustDo(t, c, "XADD", "planets", id, "name", "Mercury", proto.String(id))
+	}
+	_, err = c.Do("XREADGROUP", "GROUP", "processing", "alice", "COUNT", "2", "STREAMS", "planets", ">")
+	ok(t, err)
+
+	t.Run("XDELEX", func(t *testing.T) {
+		mustDo(t, c,
+			"XDELEX", "planets", "ACKED", "IDS", "2", "0-1", "0-3",
+			proto.Ints(2, 2),
+		)
+		mustDo(t, c,
+			"XDELEX", "planets", "IDS", "2", "0-1", "0-9",
+			proto.Ints(1, -1),
+		)
+		// KEEPREF: still pending
+		g, err := s.StreamGroup("planets", "processing")
+		ok(t, err)
+		equals(t, 2, len(g.Pending))
+
+		mustDo(t, c,
+			"XDELEX", "planets", "DELREF", "IDS", "1", "0-2",
+			proto.Ints(1),
+		)
+		g, err = s.StreamGroup("planets", "processing")
+		ok(t, err)
+		equals(t, 1, len(g.Pending))
+
+		mustDo(t, c,
+			"XDELEX", "nosuch", "IDS", "1", "0-2",
+			proto.Ints(-1),
+		)
+
+		// nothing deleted, nothing changed
+		mustOK(t, c, "WATCH", "planets")
+		mustDo(t, c,
+			"XDELEX", "planets", "IDS", "1", "0-9",
+			proto.Ints(-1),
+		)
+		mustOK(t, c, "MULTI")
+		mustDo(t, c, "XLEN", "planets", proto.Inline("QUEUED"))
+		mustDo(t, c, "EXEC", proto.Array(proto.Int(2)))
+	})
+
+	t.Run("XACKDEL", func(t *testing.T) {
+		_, err = c.Do("XREADGROUP", "GROUP", "other", "bob", "STREAMS", "planets", ">")
+		ok(t, err)
+		_, err = c.Do("XREADGROUP", "GROUP", "processing", "alice", "STREAMS", "planets", ">")
+		ok(t, err)
+
+		mustDo(t, c,
+			"XACKDEL", "planets", "processing", "ACKED", "IDS", "1", "0-3",
+			proto.Ints(2),
+		)
+		mustDo(t, c,
+			"XACKDEL", "planets", "other", "ACKED", "IDS", "2", "0-3", "0-9",
+			proto.Ints(1, -1),
+		)
+		mustDo(t, c,
+			"XACKDEL", "planets", "processing", "IDS", "1", "0-4",
+			proto.Ints(1),
+		)
+		stream, err := s.Stream("planets")
+		ok(t, err)
+		equals(t, 0, len(stream))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"XDELEX", "planets", "IDS", "1",
+			proto.Error(errWrongNumber("xdelex")),
+		)
+		mustDo(t, c,
+			"XDELEX", "planets", "FOO", "IDS", "1", "0-1",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"XDELEX", "planets", "IDS", "0", "0-1",
+			proto.Error(msgNumIDs),
+		)
+		mustDo(t, c,
+			"XDELEX", "planets", "IDS", "2", "0-1",
+			proto.Error(msgNumIDsMismatch),
+		)
+		mustDo(t, c,
+			"XDELEX", "planets", "IDS", "1", "foo",
+			proto.Error(msgInvalidStreamID),
+		)
+		mustDo(t, c,
+			"XACKDEL", "planets", "IDS", "1", "0-1",
+			proto.Error(errWrongNumber("xackdel")),
+		)
+		mustDo(t, c,
+			"XACKDEL", "planets", "nosuch", "IDS", "1", "0-1",
+			proto.Error("NOGROUP No such key 'planets' or consumer group 'nosuch'"),
+		)
+		mustOK(t, c, "SET", "str", "value")
+		mustDo(t, c,
+			"XDELEX", "str", "IDS", "1", "0-1",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
+// Test keyspace notifications for streams
+func TestStreamNotify(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+	sub, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer sub.Close()
+
+	assert(t, s.SetNotifyKeyspaceEvents("Eq") != nil, "invalid flags")
+	ok(t, s.SetNotifyKeyspaceEvents("Et"))
+
+	mustDo(t, sub,
+		"PSUBSCRIBE", "__key*",
+		proto.Array(
+			proto.String("psubscribe"),
+			proto.String("__key*"),
+			proto.Int(1),
+		),
+	)
+	event := func(ev, key string) {
+		t.Helper()
+		mustRead(t, sub,
+			proto.Strings("pmessage", "__key*", "__keyevent@0__:"+ev, key),
+		)
+	}
+
+	mustDo(t, c, "XADD", "planets", "0-1", "name", "Mercury", proto.String("0-1"))
+	event("xadd", "planets")
+	mustDo(t, c, "XADD", "planets", "MAXLEN", "1", "0-2", "name", "Venus", proto.String("0-2"))
+	event("xadd", "planets")
+	event("xtrim", "planets")
+	mustOK(t, c, "XGROUP", "CREATE", "planets", "processing", "0")
+	event("xgroup-create", "planets")
+	mustOK(t, c, "XGROUP", "SETID", "planets", "processing", "0")
+	event("xgroup-setid", "planets")
+	_, err = c.Do("XREADGROUP", "GROUP", "processing", "alice", "STREAMS", "planets", ">")
+	ok(t, err)
+	event("xgroup-createconsumer", "planets")
+	must0(t, c, "XDEL", "planets", "0-9")
+	must1(t, c, "XDEL", "planets", "0-2")
+	event("xdel", "planets")
+
+	// disabled again
+	ok(t, s.SetNotifyKeyspaceEvents(""))
+	mustDo(t, c, "XADD", "planets", "0-3", "name", "Earth", proto.String("0-3"))
+	ok(t, s.SetNotifyKeyspaceEvents("Kt"))
+	mustDo(t, c, "XADD", "planets", "0-4", "name", "Mars", proto.String("0-4"))
+	mustRead(t, sub,
+		proto.Strings("pmessage", "__key*", "__keyspace@0__:planets", "xadd"),
+	)
+}
+
 // Test XPENDING
 func TestStreamXpending(t *testing.T) {
 	s, err := Run()
@@ -674,6 +1323,26 @@ func TestStreamXpending(t *testing.T) {
 		)
 	})
 
+	t.Run("direct", func(t *testing.T) {
+		g, err := s.StreamGroup("planets", "processing")
+		ok(t, err)
+		equals(t, &StreamGroup{
+			Name:   "processing",
+			LastID: "99-1",
+			Consumers: []StreamConsumer{
+				{Name: "alice", Pending: 1, SeenTime: now, ActiveTime: now},
+			},
+			Pending: []PendingEntry{
+				{ID: "99-1", Consumer: "alice", DeliveryCount: 1, LastDelivery: now},
+			},
+		}, g)
+
+		_, err = s.StreamGroup("planets", "nosuch")
+		mustFail(t, err, "NOGROUP No such consumer group 'nosuch' for key name 'planets'")
+		_, err = s.StreamGroup("nosuch", "processing")
+		mustFail(t, err, msgKeyNotFound)
+	})
+
 	t.Run("full mode", func(t *testing.T) {
 		s.SetTime(now.Add(3 * time.Second))
 		mustDo(t, c,
@@ -691,6 +1360,25 @@ func TestStreamXpending(t *testing.T) {
 			"XPENDING", "planets", "processing", "-", "+", "-99",
 			proto.NilList,
 		)
+		mustDo(t, c,
+			"XPENDING", "planets", "processing", "(99-1", "+", "999",
+			proto.Array(),
+		)
+		mustDo(t, c,
+			"XPENDING", "planets", "processing", "(99-0", "(99-2", "999",
+			proto.Array(
+				proto.Array(
+					proto.String("99-1"),
+					proto.String("alice"),
+					proto.Int(3000),
+					proto.Int(1),
+				),
+			),
+		)
+		mustDo(t, c,
+			"XPENDING", "planets", "processing", "-", "(0-0", "999",
+			proto.Error(msgStreamRangeEnd),
+		)
 
 		// Increase delivery count
 		s.SetTime(now.Add(5 * time.Second))
@@ -737,3 +1425,68 @@ func TestStreamXpending(t *testing.T) {
 		)
 	})
 }
+
+// Test SetStreamPending()
+func TestStreamSetPending(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.SetTime(now)
+	then := now.Add(-time.Hour)
+
+	mustOK(t, c, "XGROUP", "CREATE", "planets", "processing", "$", "MKSTREAM")
+	mustDo(t, c, "XADD", "planets", "0-1", "name", "Mercury", proto.String("0-1"))
+	mustDo(t, c, "XADD", "planets", "0-2", "name", "Venus", proto.String("0-2"))
+
+	ok(t, s.SetStreamPending("planets", "processing", "alice", []string{"0-2", "0-1"}, then, 7))
+	mustDo(t, c,
+		"XPENDING", "planets", "processing", "-", "+", "10",
+		proto.Array(
+			proto.Array(proto.String("0-1"), proto.String("alice"), proto.Int(3600000), proto.Int(7)),
+			proto.Array(proto.String("0-2"), proto.String("alice"), proto.Int(3600000), proto.Int(7)),
+		),
+	)
+	// overwrite
+	ok(t, s.SetStreamPending("planets", "processing", "bob", []string{"0-1"}, now, 1))
+	mustDo(t, c,
+		"XPENDING", "planets", "processing", "-", "+", "10",
+		proto.Array(
+			proto.Array(proto.String("0-1"), proto.String("bob"), proto.Int(0), proto.Int(1)),
+			proto.Array(proto.String("0-2"), proto.String("alice"), proto.Int(3600000), proto.Int(7)),
+		),
+	)
+	g, err := s.StreamGroup("planets", "processing")
+	ok(t, err)
+	equals(t, "0-2", g.LastID)
+	equals(t, []StreamConsumer{
+		{Name: "alice", Pending: 1, SeenTime: then},
+		{Name: "bob", Pending: 1, SeenTime: now},
+	}, g.Consumers)
+
+	// nothing new to read
+	mustNilList(t, c, "XREADGROUP", "GROUP", "processing", "alice", "STREAMS", "planets", ">")
+
+	t.Run("errors", func(t *testing.T) {
+		mustFail(t,
+			s.SetStreamPending("planets", "processing", "alice", []string{"foo"}, now, 1),
+			msgInvalidStreamID,
+		)
+		mustFail(t,
+			s.SetStreamPending("planets", "processing", "alice", []string{"0-1"}, now, 0),
+			"delivery count should be positive",
+		)
+		mustFail(t,
+			s.SetStreamPending("planets", "nosuch", "alice", []string{"0-1"}, now, 1),
+			"NOGROUP No such consumer group 'nosuch' for key name 'planets'",
+		)
+		mustFail(t,
+			s.SetStreamPending("nosuch", "processing", "alice", []string{"0-1"}, now, 1),
+			msgKeyNotFound,
+		)
+	})
+}
@@ -3,6 +3,7 @@
 package miniredis
 
 import (
+	"math"
 	"math/big"
 	"strconv"
 	"strings"
@@ -21,11 +22,14 @@ func commandsString(m *Miniredis) {
 	m.srv.Register("DECR", m.cmdDecr)
 	m.srv.Register("GETBIT", m.cmdGetbit)
 	m.srv.Register("GET", m.cmdGet)
+	m.srv.Register("GETDEL", m.cmdGetdel)
+	m.srv.Register("GETEX", m.cmdGetex)
 	m.srv.Register("GETRANGE", m.cmdGetrange)
 	m.srv.Register("GETSET", m.cmdGetset)
 	m.srv.Register("INCRBYFLOAT", m.cmdIncrbyfloat)
 	m.srv.Register("INCRBY", m.cmdIncrby)
 	m.srv.Register("INCR", m.cmdIncr)
+	m.srv.Register("LCS", m.cmdLcs)
 	m.srv.Register("MGET", m.cmdMget)
 	m.srv.Register("MSET", m.cmdMset)
 	m.srv.Register("MSETNX", m.cmdMsetnx)
@@ -36,6 +40,7 @@ func commandsString(m *Miniredis) {
 	m.srv.Register("SETNX", m.cmdSetnx)
 	m.srv.Register("SETRANGE", m.cmdSetrange)
 	m.srv.Register("STRLEN", m.cmdStrlen)
+	m.srv.Register("SUBSTR", m.cmdGetrange) // deprecated
 }
 
 // SET
@@ -56,7 +61,9 @@ func (m *Miniredis) cmdSet(c *server.Peer, cmd string, args []string) {
 		nx      = false // set iff not exists
 		xx      = false // set iff exists
 		keepttl = false // set keepttl
+		expire  = false // any of EX, PX, EXAT, PXAT
 		ttl     time.Duration
+		at      time.Time // for EXAT and PXAT
 	)
 
 	key, value, args := args[0], args[1], args[2:]
@@ -64,38 +71,64 @@ func (m *Miniredis) cmdSet(c *server.Peer, cmd string, args []string) {
 		timeUnit := time.Second
 		switch strings.ToUpper(args[0]) {
 		case "NX":
+			if xx {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
 			nx = true
 			args = args[1:]
 			continue
 		case "XX":
+			if nx {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
 			xx = true
 			args = args[1:]
 			continue
 		case "KEEPTTL":
+			if expire {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
 			keepttl = true
 			args = args[1:]
 			continue
-		case "PX":
+		case "PX", "PXAT":
 			timeUnit = time.Millisecond
 			fallthrough
-		case "EX":
+		case "EX", "EXAT":
+			if keepttl || expire {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
 			if len(args) < 2 {
 				setDirty(c)
 				c.WriteError(msgInvalidInt)
 				return
 			}
-			expire, err := strconv.Atoi(args[1])
+			i, err := strconv.Atoi(args[1])
 			if err != nil {
 				setDirty(c)
 				c.WriteError(msgInvalidInt)
 				return
 			}
-			ttl = time.Duration(expire) * timeUnit
-			if ttl <= 0 {
+			if i <= 0 {
 				setDirty(c)
 				c.WriteError(msgInvalidSETime)
 				return
 			}
+			expire = true
+			switch strings.ToUpper(args[0]) {
+			case "EXAT", "PXAT":
+				at = time.Unix(0, 0).Add(time.Duration(i) * timeUnit)
+			default:
+				ttl = time.Duration(i) * timeUnit
+			}
 
 			args = args[2:]
 			continue
@@ -126,12 +159,22 @@ func (m *Miniredis) cmdSet(c *server.Peer, cmd string, args []string) {
 				ttl = val
 			}
 		}
+		if !at.IsZero() {
+			ttl = at.Sub(m.effectiveNow())
+		}
 
 		db.del(key, true) // be sure to remove existing values of other type keys.
 		// a vanilla SET clears the expire
 		db.stringSet(key, value)
-		if ttl != 0 {
+		m.notify(db.id, notifyString, "set", key)
+		if ttl != 0 || !at.IsZero() {
 			db.ttl[key] = ttl
+			if ttl <= 0 {
+				db.del(key, true)
+				m.notify(db.id, notifyGeneric, "del", key)
+			} else {
+				m.notify(db.id, notifyGeneric, "expire", key)
+			}
 		}
 		c.WriteOK()
 	})
@@ -171,6 +214,8 @@ func (m *Miniredis) cmdSetex(c *server.Peer, cmd string, args []string) {
 		db.del(key, true) // Clear any existing keys.
 		db.stringSet(key, value)
 		db.ttl[key] = time.Duration(ttl) * time.Second
+		m.notify(db.id, notifyString, "set", key)
+		m.notify(db.id, notifyGeneric, "expire", key)
 		c.WriteOK()
 	})
 }
@@ -209,6 +254,8 @@ func (m *Miniredis) cmdPsetex(c *server.Peer, cmd string, args []string) {
 		db.del(key, true) // Clear any existing keys.
 		db.stringSet(key, value)
 		db.ttl[key] = time.Duration(ttl) * time.Millisecond
+		m.notify(db.id, notifyString, "set", key)
+		m.notify(db.id, notifyGeneric, "expire", key)
 		c.WriteOK()
 	})
 }
@@ -238,6 +285,7 @@ func (m *Miniredis) cmdSetnx(c *server.Peer, cmd string, args []string) {
 		}
 
 		db.stringSet(key, value)
+		m.notify(db.id, notifyString, "set", key)
 		c.WriteInt(1)
 	})
 }
@@ -272,6 +320,7 @@ func (m *Miniredis) cmdMset(c *server.Peer, cmd string, args []string) {
 
 			db.del(key, true) // clear TTL
 			db.stringSet(key, value)
+			m.notify(db.id, notifyString, "set", key)
 		}
 		c.WriteOK()
 	})
@@ -319,6 +368,7 @@ func (m *Miniredis) cmdMsetnx(c *server.Peer, cmd string, args []string) {
 			for k, v := range keys {
 				// Nothing to delete. That's the whole point.
 				db.stringSet(k, v)
+				m.notify(db.id, notifyString, "set", k)
 			}
 		}
 		c.WriteInt(res)
@@ -385,6 +435,7 @@ func (m *Miniredis) cmdGetset(c *server.Peer, cmd string, args []string) {
 		db.stringSet(key, value)
 		// a GETSET clears the ttl
 		delete(db.ttl, key)
+		m.notify(db.id, notifyString, "set", key)
 
 		if !ok {
 			c.WriteNull()
@@ -394,6 +445,144 @@ func (m *Miniredis) cmdGetset(c *server.Peer, cmd string, args []string) {
 	})
 }
 
+// GETDEL
+func (m *Miniredis) cmdGetdel(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteNull()
+			return
+		}
+		if db.t(key) != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		v := db.stringGet(key)
+		db.del(key, true)
+		m.notify(db.id, notifyGeneric, "del", key)
+		c.WriteBulk(v)
+	})
+}
+
+// GETEX
+func (m *Miniredis) cmdGetex(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var (
+		key      = args[0]
+		persist  = false
+		ttl      time.Duration // relative expire, for EX and PX
+		at       time.Time     // absolute expire, for EXAT and PXAT
+		setTTL   = false
+		timeUnit = time.Second
+	)
+	args = args[1:]
+	if len(args) > 0 {
+		opt := strings.ToUpper(args[0])
+		switch opt {
+		case "PERSIST":
+			if len(args) != 1 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			persist = true
+		case "PX", "PXAT":
+			timeUnit = time.Millisecond
+			fallthrough
+		case "EX", "EXAT":
+			if len(args) != 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			i, err := strconv.Atoi(args[1])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			if i <= 0 {
+				setDirty(c)
+				c.WriteError(msgInvalidGETEXTime)
+				return
+			}
+			setTTL = true
+			if opt == "EXAT" || opt == "PXAT" {
+				at = time.Unix(0, 0).Add(time.Duration(i) * timeUnit)
+			} else {
+				ttl = time.Duration(i) * timeUnit
+			}
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteNull()
+			return
+		}
+		if db.t(key) != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		v := db.stringGet(key)
+		switch {
+		case persist:
+			if _, ok := db.ttl[key]; ok {
+				delete(db.ttl, key)
+				db.markDirty(key)
+				m.notify(db.id, notifyGeneric, "persist", key)
+			}
+		case setTTL:
+			if !at.IsZero() {
+				ttl = at.Sub(m.effectiveNow())
+			}
+			db.ttl[key] = ttl
+			db.markDirty(key)
+			if ttl <= 0 {
+				db.del(key, true)
+				m.notify(db.id, notifyGeneric, "del", key)
+			} else {
+				m.notify(db.id, notifyGeneric, "expire", key)
+			}
+		}
+		c.WriteBulk(v)
+	})
+}
+
 // MGET
 func (m *Miniredis) cmdMget(c *server.Peer, cmd string, args []string) {
 	if len(args) < 1 {
@@ -456,7 +645,8 @@ func (m *Miniredis) cmdIncr(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		// Don't touch TTL
-		c.WriteInt(v)
+		m.notify(db.id, notifyString, "incrby", key)
+		c.WriteInt64(v)
 	})
 }
 
@@ -475,7 +665,7 @@ func (m *Miniredis) cmdIncrby(c *server.Peer, cmd string, args []string) {
 	}
 
 	key := args[0]
-	delta, err := strconv.Atoi(args[1])
+	delta, err := strconv.ParseInt(args[1], 10, 64)
 	if err != nil {
 		setDirty(c)
 		c.WriteError(msgInvalidInt)
@@ -496,7 +686,8 @@ func (m *Miniredis) cmdIncrby(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		// Don't touch TTL
-		c.WriteInt(v)
+		m.notify(db.id, notifyString, "incrby", key)
+		c.WriteInt64(v)
 	})
 }
 
@@ -536,6 +727,7 @@ func (m *Miniredis) cmdIncrbyfloat(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		// Don't touch TTL
+		m.notify(db.id, notifyString, "incrbyfloat", key)
 		c.WriteBulk(formatBig(v))
 	})
 }
@@ -568,7 +760,8 @@ func (m *Miniredis) cmdDecr(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		// Don't touch TTL
-		c.WriteInt(v)
+		m.notify(db.id, notifyString, "incrby", key)
+		c.WriteInt64(v)
 	})
 }
 
@@ -587,12 +780,17 @@ func (m *Miniredis) cmdDecrby(c *server.Peer, cmd string, args []string) {
 	}
 
 	key := args[0]
-	delta, err := strconv.Atoi(args[1])
+	delta, err := strconv.ParseInt(args[1], 10, 64)
 	if err != nil {
 		setDirty(c)
 		c.WriteError(msgInvalidInt)
 		return
 	}
+	if delta == math.MinInt64 {
+		setDirty(c)
+		c.WriteError(msgDecrOverflow)
+		return
+	}
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		db := m.db(ctx.selectedDB)
@@ -608,7 +806,8 @@ func (m *Miniredis) cmdDecrby(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		// Don't touch TTL
-		c.WriteInt(v)
+		m.notify(db.id, notifyString, "incrby", key)
+		c.WriteInt64(v)
 	})
 }
 
@@ -666,6 +865,7 @@ func (m *Miniredis) cmdAppend(c *server.Peer, cmd string, args []string) {
 
 		newValue := db.stringKeys[key] + value
 		db.stringSet(key, newValue)
+		m.notify(db.id, notifyString, "append", key)
 
 		c.WriteInt(len(newValue))
 	})
@@ -712,6 +912,113 @@ func (m *Miniredis) cmdGetrange(c *server.Peer, cmd string, args []string) {
 	})
 }
 
+// LCS
+func (m *Miniredis) cmdLcs(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var (
+		keyA, keyB   = args[0], args[1]
+		getLen       = false
+		getIdx       = false
+		withMatchLen = false
+		minMatchLen  = 0
+	)
+	args = args[2:]
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "LEN":
+			getLen = true
+		case "IDX":
+			getIdx = true
+		case "WITHMATCHLEN":
+			withMatchLen = true
+		case "MINMATCHLEN":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			if n > 0 {
+				minMatchLen = n
+			}
+			args = args[1:]
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		args = args[1:]
+	}
+	if getLen && getIdx {
+		setDirty(c)
+		c.WriteError(msgLCSLenAndIdx)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		for _, k := range []string{keyA, keyB} {
+			if t, ok := db.keys[k]; ok && t != "string" {
+				c.WriteError(msgLCSWrongType)
+				return
+			}
+		}
+
+		match, ranges := lcs(db.stringKeys[keyA], db.stringKeys[keyB])
+		switch {
+		case getLen:
+			c.WriteInt(len(match))
+		case getIdx:
+			var matches []lcsRange
+			for _, r := range ranges {
+				if r.len() >= minMatchLen {
+					matches = append(matches, r)
+				}
+			}
+			c.WriteMapLen(2)
+			c.WriteBulk("matches")
+			c.WriteLen(len(matches))
+			for _, r := range matches {
+				if withMatchLen {
+					c.WriteLen(3)
+				} else {
+					c.WriteLen(2)
+				}
+				c.WriteLen(2)
+				c.WriteInt(r.aStart)
+				c.WriteInt(r.aEnd)
+				c.WriteLen(2)
+				c.WriteInt(r.bStart)
+				c.WriteInt(r.bEnd)
+				if withMatchLen {
+					c.WriteInt(r.len())
+				}
+			}
+			c.WriteBulk("len")
+			c.WriteInt(len(match))
+		default:
+			c.WriteBulk(match)
+		}
+	})
+}
+
 // SETRANGE
 func (m *Miniredis) cmdSetrange(c *server.Peer, cmd string, args []string) {
 	if len(args) != 3 {
@@ -756,6 +1063,7 @@ func (m *Miniredis) cmdSetrange(c *server.Peer, cmd string, args []string) {
 		}
 		copy(v[pos:pos+len(subst)], subst)
 		db.stringSet(key, string(v))
+		m.notify(db.id, notifyString, "setrange", key)
 		c.WriteInt(len(v))
 	})
 }
@@ -776,6 +1084,7 @@ func (m *Miniredis) cmdBitcount(c *server.Peer, cmd string, args []string) {
 
 	var (
 		useRange   = false
+		useBits    = false
 		start, end = 0, 0
 		key        = args[0]
 	)
@@ -796,6 +1105,15 @@ func (m *Miniredis) cmdBitcount(c *server.Peer, cmd string, args []string) {
 			return
 		}
 		args = args[2:]
+		if len(args) > 0 {
+			switch strings.ToUpper(args[0]) {
+			case "BYTE":
+				args = args[1:]
+			case "BIT":
+				useBits = true
+				args = args[1:]
+			}
+		}
 	}
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
@@ -817,6 +1135,10 @@ func (m *Miniredis) cmdBitcount(c *server.Peer, cmd string, args []string) {
 		}
 
 		v := db.stringKeys[key]
+		if useBits {
+			c.WriteInt(countBitsRange([]byte(v), start, end))
+			return
+		}
 		if useRange {
 			v = withRange(v, start, end)
 		}
@@ -870,11 +1192,16 @@ func (m *Miniredis) cmdBitop(c *server.Peer, cmd string, args []string) {
 				}[op]
 				res = sliceBinOp(cb, res, []byte(v))
 			}
+			existed := db.exists(target)
 			db.del(target, false) // Keep TTL
 			if len(res) == 0 {
 				db.del(target, true)
+				if existed {
+					m.notify(db.id, notifyGeneric, "del", target)
+				}
 			} else {
 				db.stringSet(target, string(res))
+				m.notify(db.id, notifyString, "set", target)
 			}
 			c.WriteInt(len(res))
 		case "NOT":
@@ -892,11 +1219,16 @@ func (m *Miniredis) cmdBitop(c *server.Peer, cmd string, args []string) {
 			for i := range value {
 				value[i] = ^value[i]
 			}
+			existed := db.exists(target)
 			db.del(target, false) // Keep TTL
 			if len(value) == 0 {
 				db.del(target, true)
+				if existed {
+					m.notify(db.id, notifyGeneric, "del", target)
+				}
 			} else {
 				db.stringSet(target, string(value))
+				m.notify(db.id, notifyString, "set", target)
 			}
 			c.WriteInt(len(value))
 		default:
@@ -907,7 +1239,7 @@ func (m *Miniredis) cmdBitop(c *server.Peer, cmd string, args []string) {
 
 // BITPOS
 func (m *Miniredis) cmdBitpos(c *server.Peer, cmd string, args []string) {
-	if len(args) < 2 || len(args) > 4 {
+	if len(args) < 2 {
 		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
@@ -926,8 +1258,14 @@ func (m *Miniredis) cmdBitpos(c *server.Peer, cmd string, args []string) {
 		c.WriteError(msgInvalidInt)
 		return
 	}
+	if bit != 0 && bit != 1 {
+		setDirty(c)
+		c.WriteError(msgBitArgument)
+		return
+	}
 	var start, end int
 	withEnd := false
+	useBits := false
 	if len(args) > 2 {
 		start, err = strconv.Atoi(args[2])
 		if err != nil {
@@ -945,6 +1283,22 @@ func (m *Miniredis) cmdBitpos(c *server.Peer, cmd string, args []string) {
 		}
 		withEnd = true
 	}
+	if len(args) > 4 {
+		switch strings.ToUpper(args[4]) {
+		case "BYTE":
+		case "BIT":
+			useBits = true
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+	if len(args) > 5 {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		db := m.db(ctx.selectedDB)
@@ -963,6 +1317,11 @@ func (m *Miniredis) cmdBitpos(c *server.Peer, cmd string, args []string) {
 		}
 		value := db.stringKeys[key]
 
+		if useBits {
+			c.WriteInt(bitPosRange([]byte(value), bit == 1, start, end, withEnd))
+			return
+		}
+
 		if start < 0 {
 			start += len(value)
 			if start < 0 {
@@ -1008,6 +1367,10 @@ func (m *Miniredis) cmdBitpos(c *server.Peer, cmd string, args []string) {
 	})
 }
 
+// maxBitOffset is the largest offset GETBIT and SETBIT accept. Strings are
+// at most 512MB.
+const maxBitOffset = 512*1024*1024*8 - 1
+
 // GETBIT
 func (m *Miniredis) cmdGetbit(c *server.Peer, cmd string, args []string) {
 	if len(args) != 2 {
@@ -1023,10 +1386,10 @@ func (m *Miniredis) cmdGetbit(c *server.Peer, cmd string, args []string) {
 	}
 
 	key := args[0]
-	bit, err := strconv.Atoi(args[1])
-	if err != nil {
+	bit, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || bit > maxBitOffset {
 		setDirty(c)
-		c.WriteError("ERR bit offset is not an integer or out of range")
+		c.WriteError(msgBitOffset)
 		return
 	}
 
@@ -1039,7 +1402,7 @@ func (m *Miniredis) cmdGetbit(c *server.Peer, cmd string, args []string) {
 		}
 		value := db.stringKeys[key]
 
-		ourByteNr := bit / 8
+		ourByteNr := int(bit / 8)
 		var ourByte byte
 		if ourByteNr > len(value)-1 {
 			ourByte = '\x00'
@@ -1069,10 +1432,10 @@ func (m *Miniredis) cmdSetbit(c *server.Peer, cmd string, args []string) {
 	}
 
 	key := args[0]
-	bit, err := strconv.Atoi(args[1])
-	if err != nil || bit < 0 {
+	bit, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || bit > maxBitOffset {
 		setDirty(c)
-		c.WriteError("ERR bit offset is not an integer or out of range")
+		c.WriteError(msgBitOffset)
 		return
 	}
 	newBit, err := strconv.Atoi(args[2])
@@ -1091,7 +1454,7 @@ func (m *Miniredis) cmdSetbit(c *server.Peer, cmd string, args []string) {
 		}
 		value := []byte(db.stringKeys[key])
 
-		ourByteNr := bit / 8
+		ourByteNr := int(bit / 8)
 		ourBitNr := bit % 8
 		if ourByteNr > len(value)-1 {
 			// Too short. Expand.
@@ -1109,11 +1472,87 @@ func (m *Miniredis) cmdSetbit(c *server.Peer, cmd string, args []string) {
 			value[ourByteNr] |= 1 << uint8(7-ourBitNr)
 		}
 		db.stringSet(key, string(value))
+		m.notify(db.id, notifyString, "setbit", key)
 
 		c.WriteInt(old)
 	})
 }
 
+// lcsRange is a matching part of two strings, as returned by LCS IDX. Ends are
+// inclusive.
+type lcsRange struct {
+	aStart, aEnd int
+	bStart, bEnd int
+}
+
+func (r lcsRange) len() int {
+	return r.aEnd - r.aStart + 1
+}
+
+// lcs finds the longest common subsequence of a and b. It also returns the
+// matching ranges, last match first, the same way Redis does.
+func lcs(a, b string) (string, []lcsRange) {
+	// table[i][j] is the LCS length of a[:i] and b[:j]
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+		if i == 0 {
+			continue
+		}
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				table[i][j] = table[i-1][j-1] + 1
+			} else if table[i-1][j] > table[i][j-1] {
+				table[i][j] = table[i-1][j]
+			} else {
+				table[i][j] = table[i][j-1]
+			}
+		}
+	}
+
+	var (
+		res    = make([]byte, table[len(a)][len(b)])
+		idx    = len(res)
+		ranges []lcsRange
+		cur    lcsRange
+		inside = false
+	)
+	for i, j := len(a), len(b); i > 0 && j > 0; {
+		emit := false
+		if a[i-1] == b[j-1] {
+			res[idx-1] = a[i-1]
+			if !inside {
+				cur = lcsRange{aStart: i - 1, aEnd: i - 1, bStart: j - 1, bEnd: j - 1}
+				inside = true
+			} else if cur.aStart == i && cur.bStart == j {
+				// contiguous, extend backwards
+				cur.aStart--
+				cur.bStart--
+			} else {
+				emit = true
+			}
+			if cur.aStart == 0 || cur.bStart == 0 {
+				emit = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if table[i-1][j] > table[i][j-1] {
+				i--
+			} else {
+				j--
+			}
+			emit = inside
+		}
+		if emit {
+			ranges = append(ranges, cur)
+			inside = false
+		}
+	}
+	return string(res), ranges
+}
+
 // Redis range. both start and end can be negative.
 func withRange(v string, start, end int) string {
 	s, e := redisRange(len(v), start, end, true /* string getrange symantics */)
@@ -1131,6 +1570,19 @@ func countBits(v []byte) int {
 	return count
 }
 
+// countBitsRange counts the set bits between two bit offsets. Offsets work
+// the same as GETRANGE offsets, but in bits.
+func countBitsRange(v []byte, start, end int) int {
+	s, e := redisRange(len(v)*8, start, end, true)
+	count := 0
+	for i := s; i < e; i++ {
+		if v[i/8]&(0x80>>uint(i%8)) != 0 {
+			count++
+		}
+	}
+	return count
+}
+
 // sliceBinOp applies an operator to all slice elements, with Redis string
 // padding logic.
 func sliceBinOp(f func(a, b byte) byte, a, b []byte) []byte {
@@ -1161,6 +1613,28 @@ func bitPos(s []byte, bit bool) int {
 	return -1
 }
 
+// bitPosRange is BITPOS with the range given in bits.
+func bitPosRange(v []byte, bit bool, start, end int, withEnd bool) int {
+	if !withEnd {
+		end = -1
+	}
+	s, e := redisRange(len(v)*8, start, end, true)
+	if s == e {
+		return -1
+	}
+	for i := s; i < e; i++ {
+		if (v[i/8]&(0x80>>uint(i%8)) != 0) == bit {
+			return i
+		}
+	}
+	// Looking for a 0 in an open ended range finds the first bit after the
+	// string.
+	if !bit && !withEnd {
+		return e
+	}
+	return -1
+}
+
 // toBits changes a byte in 8 bools.
 func toBits(s byte) [8]bool {
 	r := [8]bool{}
@@ -1,6 +1,7 @@
 package miniredis
 
 import (
+	"math"
 	"strconv"
 	"testing"
 	"time"
@@ -62,6 +63,26 @@ func TestString(t *testing.T) {
 			proto.Error(msgWrongType),
 		)
 	})
+
+	t.Run("binary", func(t *testing.T) {
+		v := "\x00\xff\r\n\xc3(\x00"
+		mustOK(t, c,
+			"SET", "bin\x00\xff", v,
+		)
+		mustDo(t, c,
+			"GET", "bin\x00\xff",
+			proto.String(v),
+		)
+		s.CheckGet(t, "bin\x00\xff", v)
+		mustDo(t, c,
+			"STRLEN", "bin\x00\xff",
+			proto.Int(7),
+		)
+		mustDo(t, c,
+			"KEYS", "bin\x00?",
+			proto.Strings("bin\x00\xff"),
+		)
+	})
 }
 
 func TestSet(t *testing.T) {
@@ -165,6 +186,92 @@ func TestSet(t *testing.T) {
 		)
 		s.CheckGet(t, "foo", "baz")
 		equals(t, time.Second*1337, s.TTL("foo"))
+
+		// no TTL to keep
+		s.Set("nottl", "bar")
+		mustOK(t, c,
+			"SET", "nottl", "baz", "KEEPTTL",
+		)
+		equals(t, time.Duration(0), s.TTL("nottl"))
+
+		// other types keep their TTL as well
+		s.HSet("hash", "aap", "noot")
+		s.SetTTL("hash", time.Second*42)
+		mustOK(t, c,
+			"SET", "hash", "baz", "KEEPTTL",
+		)
+		s.CheckGet(t, "hash", "baz")
+		equals(t, time.Second*42, s.TTL("hash"))
+
+		// a plain SET still clears it
+		mustOK(t, c,
+			"SET", "foo", "bar",
+		)
+		equals(t, time.Duration(0), s.TTL("foo"))
+
+		mustDo(t, c,
+			"SET", "foo", "baz", "KEEPTTL", "EX", "10",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"SET", "foo", "baz", "PX", "10", "KEEPTTL",
+			proto.Error(msgSyntaxError),
+		)
+	}
+
+	// EXAT and PXAT argument. Absolute expire times.
+	{
+		now := time.Unix(1600000000, 0)
+		s.SetTime(now)
+		mustOK(t, c,
+			"SET", "one", "two", "EXAT", "1600000100",
+		)
+		equals(t, time.Second*100, s.TTL("one"))
+
+		mustOK(t, c,
+			"SET", "one", "two", "PXAT", "1600000001500",
+		)
+		equals(t, time.Millisecond*1500, s.TTL("one"))
+		s.FastForward(time.Second)
+		equals(t, true, s.Exists("one"))
+		s.FastForward(time.Second)
+		equals(t, false, s.Exists("one"))
+
+		// in the past
+		mustOK(t, c,
+			"SET", "one", "two", "EXAT", "1500000000",
+		)
+		equals(t, false, s.Exists("one"))
+
+		mustDo(t, c,
+			"SET", "one", "two", "EXAT", "0",
+			proto.Error("ERR invalid expire time in set"),
+		)
+		mustDo(t, c,
+			"SET", "one", "two", "PXAT", "notimestamp",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"SET", "one", "two", "EX", "10", "EXAT", "1600000100",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"SET", "one", "two", "PXAT", "1600000001500", "KEEPTTL",
+			proto.Error(msgSyntaxError),
+		)
+		s.SetTime(time.Time{})
+	}
+
+	// conflicting options
+	{
+		mustDo(t, c,
+			"SET", "foo", "baz", "NX", "XX",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"SET", "foo", "baz", "EX", "10", "PX", "10",
+			proto.Error(msgSyntaxError),
+		)
 	}
 
 	// Invalid argument
@@ -444,6 +551,58 @@ func TestIncr(t *testing.T) {
 		assert(t, err != nil, "do s.Incr error")
 	}
 
+	// 64 bit limits
+	{
+		s.Set("max", "9223372036854775806")
+		mustDo(t, c,
+			"INCR", "max",
+			proto.Int64(math.MaxInt64),
+		)
+		mustDo(t, c,
+			"INCR", "max",
+			proto.Error(msgIncrOverflow),
+		)
+		s.CheckGet(t, "max", "9223372036854775807")
+
+		s.Set("min", "-9223372036854775807")
+		mustDo(t, c,
+			"DECR", "min",
+			proto.Int64(math.MinInt64),
+		)
+		mustDo(t, c,
+			"DECR", "min",
+			proto.Error(msgIncrOverflow),
+		)
+		mustDo(t, c,
+			"INCRBY", "min", "-1",
+			proto.Error(msgIncrOverflow),
+		)
+		mustDo(t, c,
+			"DECRBY", "min", "1",
+			proto.Error(msgIncrOverflow),
+		)
+		mustDo(t, c,
+			"INCRBY", "min", "9223372036854775807",
+			proto.Int(-1),
+		)
+		mustDo(t, c,
+			"DECRBY", "min", "-9223372036854775808",
+			proto.Error(msgDecrOverflow),
+		)
+		mustDo(t, c,
+			"INCRBY", "min", "9223372036854775808",
+			proto.Error(msgInvalidInt),
+		)
+
+		s.Set("big", "9223372036854775808")
+		mustDo(t, c,
+			"INCR", "big",
+			proto.Error(msgInvalidInt),
+		)
+		_, err := s.Incr("max", 1)
+		mustFail(t, err, msgIncrOverflow)
+	}
+
 	// Wrong usage
 	{
 		mustDo(t, c,
@@ -557,6 +716,46 @@ func TestIncrbyfloat(t *testing.T) {
 		s.CheckGet(t, "bar", "40.33")
 	}
 
+	// Formatting: no trailing zeros, no exponents
+	{
+		mustDo(t, c,
+			"INCRBYFLOAT", "fmt", "1.5",
+			proto.String("1.5"),
+		)
+		mustDo(t, c,
+			"INCRBYFLOAT", "fmt", "1.5",
+			proto.String("3"),
+		)
+		mustDo(t, c,
+			"INCRBYFLOAT", "fmt", "12345e10",
+			proto.String("123450000000003"),
+		)
+		mustDo(t, c,
+			"INCRBYFLOAT", "fmt", "-123450000000003.25",
+			proto.String("-0.25"),
+		)
+		s.Set("fmt", "1e3")
+		mustDo(t, c,
+			"INCRBYFLOAT", "fmt", "0.1",
+			proto.String("1000.1"),
+		)
+	}
+
+	// Infinity
+	{
+		mustDo(t, c,
+			"INCRBYFLOAT", "inf", "inf",
+			proto.Error(msgIncrFloatInf),
+		)
+		equals(t, false, s.Exists("inf"))
+		s.Set("inf", "-inf")
+		mustDo(t, c,
+			"INCRBYFLOAT", "inf", "+inf",
+			proto.Error(msgIncrFloatInf),
+		)
+		s.CheckGet(t, "inf", "-inf")
+	}
+
 	// Direct usage
 	{
 		s.Set("foo", "500.1")
@@ -762,9 +961,26 @@ func TestGetSet(t *testing.T) {
 			proto.String("two"),
 		)
 		s.CheckGet(t, "bar", "bak")
+		s.CheckGet(t, "one", "three")
 		equals(t, time.Duration(0), s.TTL("one"))
 	}
 
+	// WATCHed keys are changed
+	{
+		c2, err := proto.Dial(s.Addr())
+		ok(t, err)
+		defer c2.Close()
+
+		mustOK(t, c, "WATCH", "foo")
+		mustDo(t, c2,
+			"GETSET", "foo", "watched",
+			proto.String("baz"),
+		)
+		mustOK(t, c, "MULTI")
+		mustDo(t, c, "GET", "foo", proto.Inline("QUEUED"))
+		mustNilList(t, c, "EXEC")
+	}
+
 	// Wrong type of existing key
 	{
 		s.HSet("wrong", "aap", "noot")
@@ -787,6 +1003,270 @@ func TestGetSet(t *testing.T) {
 	}
 }
 
+func TestGetdel(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.Set("foo", "bar")
+	s.SetTTL("foo", time.Second*1234)
+	mustDo(t, c,
+		"GETDEL", "foo",
+		proto.String("bar"),
+	)
+	equals(t, false, s.Exists("foo"))
+	equals(t, time.Duration(0), s.TTL("foo"))
+
+	mustNil(t, c, "GETDEL", "foo")
+	mustNil(t, c, "GETDEL", "nosuch")
+
+	t.Run("errors", func(t *testing.T) {
+		s.HSet("wrong", "aap", "noot")
+		mustDo(t, c,
+			"GETDEL", "wrong",
+			proto.Error(msgWrongType),
+		)
+		equals(t, true, s.Exists("wrong"))
+		mustDo(t, c,
+			"GETDEL",
+			proto.Error(errWrongNumber("getdel")),
+		)
+		mustDo(t, c,
+			"GETDEL", "too", "many",
+			proto.Error(errWrongNumber("getdel")),
+		)
+	})
+}
+
+func TestGetex(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	now := time.Unix(1600000000, 0)
+	s.SetTime(now)
+
+	s.Set("foo", "bar")
+	mustDo(t, c,
+		"GETEX", "foo",
+		proto.String("bar"),
+	)
+	equals(t, time.Duration(0), s.TTL("foo"))
+	mustNil(t, c, "GETEX", "nosuch")
+	mustNil(t, c, "GETEX", "nosuch", "EX", "10")
+	equals(t, false, s.Exists("nosuch"))
+
+	t.Run("EX", func(t *testing.T) {
+		mustDo(t, c,
+			"GETEX", "foo", "EX", "100",
+			proto.String("bar"),
+		)
+		equals(t, 100*time.Second, s.TTL("foo"))
+		mustDo(t, c,
+			"GETEX", "foo", "px", "1234",
+			proto.String("bar"),
+		)
+		equals(t, 1234*time.Millisecond, s.TTL("foo"))
+	})
+
+	t.Run("EXAT", func(t *testing.T) {
+		mustDo(t, c,
+			"GETEX", "foo", "EXAT", "1600000100",
+			proto.String("bar"),
+		)
+		equals(t, 100*time.Second, s.TTL("foo"))
+		mustDo(t, c,
+			"GETEX", "foo", "PXAT", "1600000001500",
+			proto.String("bar"),
+		)
+		equals(t, 1500*time.Millisecond, s.TTL("foo"))
+		s.FastForward(time.Second)
+		equals(t, true, s.Exists("foo"))
+		s.FastForward(time.Second)
+		equals(t, false, s.Exists("foo"))
+
+		// in the past
+		s.Set("foo", "bar")
+		mustDo(t, c,
+			"GETEX", "foo", "EXAT", "1500000000",
+			proto.String("bar"),
+		)
+		equals(t, false, s.Exists("foo"))
+	})
+
+	t.Run("PERSIST", func(t *testing.T) {
+		s.Set("foo", "bar")
+		s.SetTTL("foo", time.Minute)
+		mustDo(t, c,
+			"GETEX", "foo", "PERSIST",
+			proto.String("bar"),
+		)
+		equals(t, time.Duration(0), s.TTL("foo"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"GETEX",
+			proto.Error(errWrongNumber("getex")),
+		)
+		mustDo(t, c,
+			"GETEX", "foo", "EX",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"GETEX", "foo", "EX", "10", "PERSIST",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"GETEX", "foo", "PERSIST", "EX", "10",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"GETEX", "foo", "FOO",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"GETEX", "foo", "EX", "noint",
+			proto.Error(msgInvalidInt),
+		)
+		mustDo(t, c,
+			"GETEX", "foo", "EX", "0",
+			proto.Error(msgInvalidGETEXTime),
+		)
+		mustDo(t, c,
+			"GETEX", "foo", "PXAT", "-1",
+			proto.Error(msgInvalidGETEXTime),
+		)
+		s.HSet("wrong", "aap", "noot")
+		mustDo(t, c,
+			"GETEX", "wrong",
+			proto.Error(msgWrongType),
+		)
+	})
+}
+
+func TestLcs(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.Set("key1", "ohmytext")
+	s.Set("key2", "mynewtext")
+
+	mustDo(t, c,
+		"LCS", "key1", "key2",
+		proto.String("mytext"),
+	)
+	mustDo(t, c,
+		"LCS", "key1", "key2", "LEN",
+		proto.Int(6),
+	)
+	mustDo(t, c,
+		"LCS", "key1", "key2", "IDX",
+		proto.Array(
+			proto.String("matches"),
+			proto.Array(
+				proto.Array(
+					proto.Array(proto.Int(4), proto.Int(7)),
+					proto.Array(proto.Int(5), proto.Int(8)),
+				),
+				proto.Array(
+					proto.Array(proto.Int(2), proto.Int(3)),
+					proto.Array(proto.Int(0), proto.Int(1)),
+				),
+			),
+			proto.String("len"),
+			proto.Int(6),
+		),
+	)
+	mustDo(t, c,
+		"LCS", "key1", "key2", "IDX", "MINMATCHLEN", "4", "WITHMATCHLEN",
+		proto.Array(
+			proto.String("matches"),
+			proto.Array(
+				proto.Array(
+					proto.Array(proto.Int(4), proto.Int(7)),
+					proto.Array(proto.Int(5), proto.Int(8)),
+					proto.Int(4),
+				),
+			),
+			proto.String("len"),
+			proto.Int(6),
+		),
+	)
+
+	t.Run("missing keys", func(t *testing.T) {
+		mustDo(t, c,
+			"LCS", "key1", "nosuch",
+			proto.String(""),
+		)
+		mustDo(t, c,
+			"LCS", "nosuch", "nosuch2", "IDX",
+			proto.Array(
+				proto.String("matches"),
+				proto.Array(),
+				proto.String("len"),
+				proto.Int(0),
+			),
+		)
+	})
+
+	useRESP3(t, c)
+	t.Run("RESP3", func(t *testing.T) {
+		mustDo(t, c,
+			"LCS", "key1", "key2", "IDX", "MINMATCHLEN", "3",
+			proto.Map(
+				proto.String("matches"),
+				proto.Array(
+					proto.Array(
+						proto.Array(proto.Int(4), proto.Int(7)),
+						proto.Array(proto.Int(5), proto.Int(8)),
+					),
+				),
+				proto.String("len"),
+				proto.Int(6),
+			),
+		)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mustDo(t, c,
+			"LCS", "key1",
+			proto.Error(errWrongNumber("lcs")),
+		)
+		mustDo(t, c,
+			"LCS", "key1", "key2", "LEN", "IDX",
+			proto.Error(msgLCSLenAndIdx),
+		)
+		mustDo(t, c,
+			"LCS", "key1", "key2", "FOO",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"LCS", "key1", "key2", "MINMATCHLEN",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"LCS", "key1", "key2", "MINMATCHLEN", "foo",
+			proto.Error(msgInvalidInt),
+		)
+		s.HSet("hash", "aap", "noot")
+		mustDo(t, c,
+			"LCS", "key1", "hash",
+			proto.Error(msgLCSWrongType),
+		)
+	})
+}
+
 func TestStrlen(t *testing.T) {
 	s, err := Run()
 	ok(t, err)
@@ -802,6 +1282,18 @@ func TestStrlen(t *testing.T) {
 			"STRLEN", "foo",
 			proto.Int(4),
 		)
+
+		// bytes, not runes
+		s.Set("utf8", "❆❅❄☃")
+		mustDo(t, c,
+			"STRLEN", "utf8",
+			proto.Int(12),
+		)
+
+		s.Set("empty", "")
+		must0(t, c,
+			"STRLEN", "empty",
+		)
 	}
 
 	// New key
@@ -848,6 +1340,7 @@ func TestAppend(t *testing.T) {
 			"APPEND", "foo", "morebar",
 			proto.Int(11),
 		)
+		s.CheckGet(t, "foo", "bar!morebar")
 	}
 
 	// New key
@@ -855,6 +1348,24 @@ func TestAppend(t *testing.T) {
 		"APPEND", "bar", "was empty",
 		proto.Int(9),
 	)
+	s.CheckGet(t, "bar", "was empty")
+
+	// TTL is kept
+	{
+		s.Set("ttl", "bar")
+		s.SetTTL("ttl", time.Minute)
+		mustDo(t, c,
+			"APPEND", "ttl", "baz",
+			proto.Int(6),
+		)
+		equals(t, time.Minute, s.TTL("ttl"))
+	}
+
+	// Length is in bytes
+	mustDo(t, c,
+		"APPEND", "utf8", "❆❅",
+		proto.Int(6),
+	)
 
 	// Wrong type of existing key
 	{
@@ -910,6 +1421,29 @@ func TestGetrange(t *testing.T) {
 		test(0, -2, "abcdef")
 		test(0, -100, "a") // Redis is funny
 		test(-2, 2, "")
+		test(-100, 2, "abc")
+		test(-100, -100, "a")
+		test(7, 100, "")
+		test(100, 200, "")
+		test(3, 1, "")
+
+		s.Set("empty", "")
+		mustDo(t, c,
+			"GETRANGE", "empty", "0", "-1",
+			proto.String(""),
+		)
+	}
+
+	// SUBSTR is an alias
+	{
+		mustDo(t, c,
+			"SUBSTR", "foo", "-4", "-2",
+			proto.String("def"),
+		)
+		mustDo(t, c,
+			"SUBSTR", "foo", "0",
+			proto.Error(errWrongNumber("substr")),
+		)
 	}
 
 	// New key
@@ -1060,6 +1594,43 @@ func TestBitcount(t *testing.T) {
 		test(2, -2, 4) // "c"
 	}
 
+	t.Run("units", func(t *testing.T) {
+		s.Set("units", "\xff\xf0\x00\x01")
+		test := func(s, e, unit string, res int) {
+			t.Helper()
+			mustDo(t, c,
+				"BITCOUNT", "units", s, e, unit,
+				proto.Int(res),
+			)
+		}
+		test("0", "0", "BYTE", 8)
+		test("1", "-1", "byte", 5)
+		test("0", "0", "BIT", 1)
+		test("0", "11", "BIT", 12)
+		test("5", "13", "bit", 7)
+		test("-1", "-1", "BIT", 1)
+		test("-8", "-2", "BIT", 0)
+		test("12", "3", "BIT", 0)
+		test("0", "-1", "BIT", 13)
+		test("-100", "100", "BIT", 13)
+
+		mustDo(t, c,
+			"BITCOUNT", "units", "0", "1", "BITS",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"BITCOUNT", "units", "0", "1", "BIT", "BIT",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"BITCOUNT", "units", "0",
+			proto.Error(msgSyntaxError),
+		)
+		must0(t, c,
+			"BITCOUNT", "nosuch", "0", "1", "BIT",
+		)
+	})
+
 	// Wrong type of existing key
 	{
 		s.HSet("wrong", "aap", "noot")
@@ -1358,6 +1929,64 @@ func TestBitpos(t *testing.T) {
 		)
 	})
 
+	t.Run("units", func(t *testing.T) {
+		s.Set("units", "\x0f\xff\x00\xf0")
+		test := func(bit, s, e, unit string, res int) {
+			t.Helper()
+			mustDo(t, c,
+				"BITPOS", "units", bit, s, e, unit,
+				proto.Int(res),
+			)
+		}
+		test("1", "0", "-1", "BYTE", 4)
+		test("1", "2", "-1", "byte", 24)
+		test("0", "1", "1", "BYTE", -1)
+		test("1", "0", "-1", "BIT", 4)
+		test("1", "5", "-1", "BIT", 5)
+		test("0", "5", "-1", "bit", 16)
+		test("0", "8", "15", "BIT", -1)
+		test("1", "16", "23", "BIT", -1)
+		test("1", "-8", "-1", "BIT", 24)
+		test("0", "-4", "-1", "BIT", 28)
+		test("1", "10", "3", "BIT", -1)
+
+		// No end: looking for a 0 past the string
+		s.Set("ones", "\xff\xff")
+		mustDo(t, c,
+			"BITPOS", "ones", "0", "1",
+			proto.Int(16),
+		)
+		mustDo(t, c,
+			"BITPOS", "ones", "0", "3", "-1", "BIT",
+			proto.Int(-1),
+		)
+		mustDo(t, c,
+			"BITPOS", "nosuch", "0", "3", "-1", "BIT",
+			proto.Int(0),
+		)
+		mustDo(t, c,
+			"BITPOS", "nosuch", "1", "3", "-1", "BIT",
+			proto.Int(-1),
+		)
+		mustDo(t, c,
+			"BITPOS", "empty", "0", "0", "-1", "BIT",
+			proto.Int(-1),
+		)
+
+		mustDo(t, c,
+			"BITPOS", "units", "1", "0", "-1", "BITS",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"BITPOS", "units", "1", "0", "-1", "BIT", "BIT",
+			proto.Error(msgSyntaxError),
+		)
+		mustDo(t, c,
+			"BITPOS", "units", "2",
+			proto.Error(msgBitArgument),
+		)
+	})
+
 	t.Run("wrong type", func(t *testing.T) {
 		s.HSet("wrong", "aap", "noot")
 		mustDo(t, c,
@@ -1436,6 +2065,14 @@ func TestGetbit(t *testing.T) {
 			"GETBIT", "many", "noint",
 			proto.Error("ERR bit offset is not an integer or out of range"),
 		)
+		mustDo(t, c,
+			"GETBIT", "many", "-1",
+			proto.Error(msgBitOffset),
+		)
+		mustDo(t, c,
+			"GETBIT", "many", "4294967296",
+			proto.Error(msgBitOffset),
+		)
 	}
 }
 
@@ -1512,6 +2149,10 @@ func TestSetbit(t *testing.T) {
 			"SETBIT", "many", "-3", "0",
 			proto.Error("ERR bit offset is not an integer or out of range"),
 		)
+		mustDo(t, c,
+			"SETBIT", "many", "4294967296", "1",
+			proto.Error(msgBitOffset),
+		)
 		mustDo(t, c,
 			"SETBIT", "many", "3", "2",
 			proto.Error("ERR bit is not an integer or out of range"),
@@ -1554,6 +2195,25 @@ func TestMsetnx(t *testing.T) {
 		equals(t, false, s.Exists("three"))
 	}
 
+	// Double key, last one wins
+	{
+		must1(t, c,
+			"MSETNX", "11", "12", "11", "14",
+		)
+		s.CheckGet(t, "11", "14")
+	}
+
+	// Existing key with a TTL is left alone
+	{
+		s.Set("ttl", "old")
+		s.SetTTL("ttl", time.Minute)
+		must0(t, c,
+			"MSETNX", "ttl", "new",
+		)
+		s.CheckGet(t, "ttl", "old")
+		equals(t, time.Minute, s.TTL("ttl"))
+	}
+
 	// Wrong usage
 	{
 		mustDo(t, c,
@@ -18,6 +18,7 @@ func commandsTransaction(m *Miniredis) {
 // MULTI
 func (m *Miniredis) cmdMulti(c *server.Peer, cmd string, args []string) {
 	if len(args) != 0 {
+		setDirty(c)
 		c.WriteError(errWrongNumber(cmd))
 		return
 	}
@@ -91,8 +92,6 @@ func (m *Miniredis) cmdExec(c *server.Peer, cmd string, args []string) {
 	for _, cb := range ctx.transaction {
 		cb(c, ctx)
 	}
-	// wake up anyone who waits on anything.
-	m.signal.Broadcast()
 
 	stopTx(ctx)
 }
@@ -1,7 +1,9 @@
 package miniredis
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2/proto"
 )
@@ -175,6 +177,69 @@ func TestTxQueueErr(t *testing.T) {
 	equals(t, false, s.Exists("aap"))
 }
 
+func TestTxUnknownCommand(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	mustOK(t, c,
+		"MULTI",
+	)
+	mustDo(t, c,
+		"SET", "aap", "mies",
+		proto.Inline("QUEUED"),
+	)
+	mustContain(t, c,
+		"NOSUCH", "aap",
+		"unknown command",
+	)
+	mustDo(t, c,
+		"EXEC",
+		proto.Error("EXECABORT Transaction discarded because of previous errors."),
+	)
+	equals(t, false, s.Exists("aap"))
+
+	t.Run("runtime error", func(t *testing.T) {
+		s.Set("str", "mies")
+		mustOK(t, c,
+			"MULTI",
+		)
+		mustDo(t, c,
+			"INCR", "str",
+			proto.Inline("QUEUED"),
+		)
+		mustDo(t, c,
+			"SET", "aap", "mies",
+			proto.Inline("QUEUED"),
+		)
+		mustDo(t, c,
+			"EXEC",
+			proto.Array(
+				proto.Error(msgInvalidInt),
+				proto.Inline("OK"),
+			),
+		)
+		s.CheckGet(t, "aap", "mies")
+	})
+
+	t.Run("MULTI arguments", func(t *testing.T) {
+		mustOK(t, c,
+			"MULTI",
+		)
+		mustDo(t, c,
+			"MULTI", "foo",
+			proto.Error(errWrongNumber("multi")),
+		)
+		mustDo(t, c,
+			"EXEC",
+			proto.Error("EXECABORT Transaction discarded because of previous errors."),
+		)
+	})
+}
+
 func TestTxWatch(t *testing.T) {
 	// Watch with no error.
 	s, err := Run()
@@ -290,3 +355,185 @@ func TestUnwatch(t *testing.T) {
 		proto.String("four"),
 	)
 }
+
+// Blocking commands don't block in a transaction.
+func TestTxBlocking(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+
+	s.Push("l2", "aap")
+	mustOK(t, c,
+		"MULTI",
+	)
+	mustDo(t, c,
+		"BLPOP", "l1", "l2", "0",
+		proto.Inline("QUEUED"),
+	)
+	mustDo(t, c,
+		"BLPOP", "l1", "0",
+		proto.Inline("QUEUED"),
+	)
+	mustDo(t, c,
+		"BRPOPLPUSH", "l1", "l2", "0",
+		proto.Inline("QUEUED"),
+	)
+	mustDo(t, c,
+		"BZPOPMIN", "z", "0",
+		proto.Inline("QUEUED"),
+	)
+	mustDo(t, c,
+		"XREAD", "BLOCK", "0", "STREAMS", "x", "$",
+		proto.Inline("QUEUED"),
+	)
+	mustDo(t, c,
+		"EXEC",
+		proto.Array(
+			proto.Strings("l2", "aap"),
+			proto.NilList,
+			proto.NilList,
+			proto.NilList,
+			proto.NilList,
+		),
+	)
+}
+
+// Every write to a WATCHed key makes the EXEC fail.
+func TestTxWatchWrites(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+	c2, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c2.Close()
+
+	var (
+		str    = []string{"SET", "k", "12"}
+		list   = []string{"RPUSH", "k", "a", "b", "c"}
+		set    = []string{"SADD", "k", "a", "b", "c"}
+		hash   = []string{"HSET", "k", "a", "1", "b", "2"}
+		zset   = []string{"ZADD", "k", "1", "a", "2", "b", "3", "c"}
+		stream = []string{"XADD", "k", "1-1", "a", "1"}
+		group  = []string{"XGROUP", "CREATE", "k", "grp", "0"}
+		read   = []string{"XREADGROUP", "GROUP", "grp", "alice", "STREAMS", "k", ">"}
+		other  = []string{"SET", "o", "foo"}
+	)
+	for _, cas := range []struct {
+		setup [][]string
+		cmd   []string
+	}{
+		{nil, []string{"SET", "k", "v"}},
+		{nil, []string{"SETNX", "k", "v"}},
+		{nil, []string{"MSET", "k", "v"}},
+		{nil, []string{"MSETNX", "k", "v"}},
+		{nil, []string{"SETEX", "k", "10", "v"}},
+		{nil, []string{"PSETEX", "k", "10000", "v"}},
+		{nil, []string{"APPEND", "k", "v"}},
+		{nil, []string{"INCR", "k"}},
+		{nil, []string{"INCRBYFLOAT", "k", "1.5"}},
+		{nil, []string{"SETRANGE", "k", "2", "v"}},
+		{nil, []string{"SETBIT", "k", "2", "1"}},
+		{[][]string{other}, []string{"BITOP", "NOT", "k", "o"}},
+		{[][]string{str}, []string{"GETSET", "k", "v"}},
+		{[][]string{str}, []string{"GETDEL", "k"}},
+		{[][]string{str}, []string{"GETEX", "k", "EX", "100"}},
+		{nil, []string{"PFADD", "k", "a"}},
+		{[][]string{{"PFADD", "o", "a"}}, []string{"PFMERGE", "k", "o"}},
+		{[][]string{str}, []string{"DEL", "k"}},
+		{[][]string{str}, []string{"UNLINK", "k"}},
+		{[][]string{str}, []string{"EXPIRE", "k", "100"}},
+		{[][]string{str}, []string{"PEXPIREAT", "k", "99999999999999"}},
+		{[][]string{str, {"EXPIRE", "k", "100"}}, []string{"PERSIST", "k"}},
+		{[][]string{str}, []string{"RENAME", "k", "k2"}},
+		{[][]string{other}, []string{"RENAME", "o", "k"}},
+		{[][]string{other}, []string{"COPY", "o", "k"}},
+		{[][]string{str}, []string{"MOVE", "k", "2"}},
+		{[][]string{str}, []string{"FLUSHDB"}},
+		{[][]string{str}, []string{"FLUSHALL"}},
+		{[][]string{str}, []string{"SWAPDB", "0", "1"}},
+		{nil, []string{"LPUSH", "k", "a"}},
+		{[][]string{list}, []string{"LPUSHX", "k", "a"}},
+		{[][]string{list}, []string{"LPOP", "k"}},
+		{[][]string{list}, []string{"RPOP", "k", "3"}},
+		{[][]string{list}, []string{"LSET", "k", "0", "z"}},
+		{[][]string{list}, []string{"LINSERT", "k", "BEFORE", "b", "z"}},
+		{[][]string{list}, []string{"LREM", "k", "0", "b"}},
+		{[][]string{list}, []string{"LTRIM", "k", "0", "1"}},
+		{[][]string{list}, []string{"RPOPLPUSH", "k", "o"}},
+		{[][]string{{"RPUSH", "o", "a"}}, []string{"RPOPLPUSH", "o", "k"}},
+		{[][]string{list}, []string{"BLPOP", "k", "1"}},
+		{[][]string{list}, []string{"SORT", "k", "ALPHA", "STORE", "k"}},
+		{nil, []string{"SADD", "k", "a"}},
+		{[][]string{set}, []string{"SREM", "k", "a"}},
+		{[][]string{set}, []string{"SPOP", "k"}},
+		{[][]string{set}, []string{"SMOVE", "k", "o", "a"}},
+		{[][]string{{"SADD", "o", "a"}}, []string{"SDIFFSTORE", "k", "o"}},
+		{[][]string{{"SADD", "o", "a"}}, []string{"SUNIONSTORE", "k", "o"}},
+		{nil, []string{"HSET", "k", "a", "1"}},
+		{nil, []string{"HSETNX", "k", "a", "1"}},
+		{[][]string{hash}, []string{"HDEL", "k", "a"}},
+		{[][]string{hash}, []string{"HINCRBY", "k", "a", "1"}},
+		{[][]string{hash}, []string{"HINCRBYFLOAT", "k", "a", "1.5"}},
+		{[][]string{hash}, []string{"HGETDEL", "k", "FIELDS", "1", "a"}},
+		{[][]string{hash}, []string{"HEXPIRE", "k", "100", "FIELDS", "1", "a"}},
+		{[][]string{hash, {"HEXPIRE", "k", "100", "FIELDS", "1", "a"}}, []string{"HPERSIST", "k", "FIELDS", "1", "a"}},
+		{nil, []string{"ZADD", "k", "1", "a"}},
+		{[][]string{zset}, []string{"ZINCRBY", "k", "1", "a"}},
+		{[][]string{zset}, []string{"ZREM", "k", "a"}},
+		{[][]string{zset}, []string{"ZPOPMIN", "k"}},
+		{[][]string{zset}, []string{"ZREMRANGEBYRANK", "k", "0", "0"}},
+		{[][]string{zset}, []string{"ZREMRANGEBYSCORE", "k", "1", "1"}},
+		{[][]string{zset}, []string{"ZREMRANGEBYLEX", "k", "[a", "[a"}},
+		{[][]string{zset}, []string{"ZMPOP", "1", "k", "MIN"}},
+		{[][]string{{"ZADD", "o", "1", "a"}}, []string{"ZUNIONSTORE", "k", "1", "o"}},
+		{[][]string{{"ZADD", "o", "1", "a"}}, []string{"ZRANGESTORE", "k", "o", "0", "-1"}},
+		{nil, []string{"GEOADD", "k", "1", "1", "a"}},
+		{nil, []string{"XADD", "k", "*", "a", "1"}},
+		{[][]string{stream}, []string{"XDEL", "k", "1-1"}},
+		{[][]string{stream}, []string{"XGROUP", "CREATE", "k", "grp2", "0"}},
+		{[][]string{stream, group}, []string{"XGROUP", "SETID", "k", "grp", "0"}},
+		{[][]string{stream, group}, []string{"XREADGROUP", "GROUP", "grp", "bob", "STREAMS", "k", ">"}},
+		{[][]string{stream, group, read}, []string{"XACK", "k", "grp", "1-1"}},
+		{nil, []string{"EVAL", "redis.call('SET', KEYS[1], 'v')", "1", "k"}},
+		{[][]string{other}, []string{"EVAL", "redis.call('RESTORE', KEYS[1], 0, redis.call('DUMP', 'o'))", "1", "k"}},
+	} {
+		t.Run(strings.Join(cas.cmd, " "), func(t *testing.T) {
+			mustOK(t, c, "FLUSHALL")
+			for _, setup := range cas.setup {
+				_, err := c.Do(setup...)
+				ok(t, err)
+			}
+			mustOK(t, c, "WATCH", "k")
+			res, err := c2.Do(cas.cmd...)
+			ok(t, err)
+			if strings.HasPrefix(res, "-") {
+				t.Fatalf("%q: %s", cas.cmd, res)
+			}
+			mustOK(t, c, "MULTI")
+			mustNilList(t, c, "EXEC")
+		})
+	}
+
+	t.Run("expire", func(t *testing.T) {
+		mustOK(t, c, "SET", "k", "v", "EX", "10")
+		mustOK(t, c, "WATCH", "k")
+		s.FastForward(time.Minute)
+		mustOK(t, c, "MULTI")
+		mustNilList(t, c, "EXEC")
+	})
+
+	t.Run("go API", func(t *testing.T) {
+		s.ZAdd("k", 1, "a")
+		s.ZAdd("k", 2, "b")
+		mustOK(t, c, "WATCH", "k")
+		s.ZRem("k", "a")
+		mustOK(t, c, "MULTI")
+		mustNilList(t, c, "EXEC")
+	})
+}
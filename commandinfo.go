@@ -0,0 +1,353 @@
+package miniredis
+
+// What COMMAND and COMMAND DOCS report about our commands.
+
+import (
+	"strings"
+)
+
+// commandInfo has the COMMAND metadata of a single command. The first key,
+// last key, and step values come from keySpecs.
+type commandInfo struct {
+	arity   int    // number of arguments, including the command name. Negative means "at least".
+	flags   string // space separated, without "movablekeys"
+	group   string
+	since   string
+	summary string
+}
+
+var commandInfos = map[string]commandInfo{
+	// bitmap
+	"BITCOUNT": {-2, "readonly", "bitmap", "2.6.0", "Counts the number of set bits (population counting) in a string."},
+	"BITOP":    {-4, "write denyoom", "bitmap", "2.6.0", "Performs bitwise operations on multiple strings, and stores the result."},
+	"BITPOS":   {-3, "readonly", "bitmap", "2.8.7", "Finds the first set (1) or clear (0) bit in a string."},
+	"GETBIT":   {3, "readonly fast", "bitmap", "2.2.0", "Returns a bit value by offset."},
+	"SETBIT":   {4, "write denyoom", "bitmap", "2.2.0", "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist."},
+
+	// cluster
+	"CLUSTER": {-2, "", "cluster", "3.0.0", "A container for Redis Cluster commands."},
+
+	// connection
+	"AUTH":   {-2, "noscript loading stale fast no_auth allow_busy", "connection", "1.0.0", "Authenticates the connection."},
+	"CLIENT": {-2, "", "connection", "2.4.0", "A container for client connection commands."},
+	"ECHO":   {2, "fast", "connection", "1.0.0", "Returns the given string."},
+	"HELLO":  {-1, "noscript loading stale fast no_auth allow_busy", "connection", "6.0.0", "Handshakes with the Redis server."},
+	"PING":   {-1, "fast", "connection", "1.0.0", "Returns the server's liveliness response."},
+	"QUIT":   {-1, "noscript loading stale fast no_auth allow_busy", "connection", "1.0.0", "Closes the connection."},
+	"SELECT": {2, "loading stale fast", "connection", "1.0.0", "Changes the selected database."},
+
+	// generic
+	"COPY":      {-3, "write denyoom", "generic", "6.2.0", "Copies the value of a key to a new key."},
+	"DEL":       {-2, "write", "generic", "1.0.0", "Deletes one or more keys."},
+	"DUMP":      {2, "readonly", "generic", "2.6.0", "Returns a serialized representation of the value stored at a key."},
+	"EXISTS":    {-2, "readonly fast", "generic", "1.0.0", "Determines whether one or more keys exist."},
+	"EXPIRE":    {-3, "write fast", "generic", "1.0.0", "Sets the expiration time of a key in seconds."},
+	"EXPIREAT":  {-3, "write fast", "generic", "1.2.0", "Sets the expiration time of a key to a Unix timestamp."},
+	"KEYS":      {2, "readonly", "generic", "1.0.0", "Returns all key names that match a pattern."},
+	"MIGRATE":   {-6, "write", "generic", "2.6.0", "Atomically transfers a key from one Redis instance to another."},
+	"MOVE":      {3, "write fast", "generic", "1.0.0", "Moves a key to another database."},
+	"OBJECT":    {-2, "readonly", "generic", "2.2.3", "A container for object introspection commands."},
+	"PERSIST":   {2, "write fast", "generic", "2.2.0", "Removes the expiration time of a key."},
+	"PEXPIRE":   {-3, "write fast", "generic", "2.6.0", "Sets the expiration time of a key in milliseconds."},
+	"PEXPIREAT": {-3, "write fast", "generic", "2.6.0", "Sets the expiration time of a key to a Unix milliseconds timestamp."},
+	"PTTL":      {2, "readonly fast", "generic", "2.6.0", "Returns the expiration time in milliseconds of a key."},
+	"RANDOMKEY": {1, "readonly", "generic", "1.0.0", "Returns a random key name from the database."},
+	"RENAME":    {3, "write", "generic", "1.0.0", "Renames a key and overwrites the destination."},
+	"RENAMENX":  {3, "write fast", "generic", "1.0.0", "Renames a key only when the target key name doesn't exist."},
+	"RESTORE":   {-4, "write denyoom", "generic", "2.6.0", "Creates a key from the serialized representation of a value."},
+	"SCAN":      {-2, "readonly", "generic", "2.8.0", "Iterates over the key names in the database."},
+	"SORT":      {-2, "write denyoom", "generic", "1.0.0", "Sorts the elements in a list, a set, or a sorted set, optionally storing the result."},
+	"SORT_RO":   {-2, "readonly", "generic", "7.0.0", "Returns the sorted elements of a list, a set, or a sorted set."},
+	"TOUCH":     {-2, "readonly fast", "generic", "3.2.1", "Returns the number of existing keys out of those specified after updating the time they were last accessed."},
+	"TTL":       {2, "readonly fast", "generic", "1.0.0", "Returns the expiration time in seconds of a key."},
+	"TYPE":      {2, "readonly fast", "generic", "1.0.0", "Determines the type of value stored at a key."},
+	"UNLINK":    {-2, "write fast", "generic", "4.0.0", "Asynchronously deletes one or more keys."},
+	"WAIT":      {3, "", "generic", "3.0.0", "Blocks until the asynchronous replication of all preceding write commands sent by the connection is completed."},
+	"WAITAOF":   {4, "noscript", "generic", "7.2.0", "Blocks until all of the preceding write commands sent by the connection are written to the append-only file of the primary and/or replicas."},
+
+	// geo
+	"GEOADD":               {-5, "write denyoom", "geo", "3.2.0", "Adds one or more members to a geospatial index. The key is created if it doesn't exist."},
+	"GEODIST":              {-4, "readonly", "geo", "3.2.0", "Returns the distance between two members of a geospatial index."},
+	"GEOHASH":              {-2, "readonly", "geo", "3.2.0", "Returns members from a geospatial index as geohash strings."},
+	"GEOPOS":               {-2, "readonly", "geo", "3.2.0", "Returns the longitude and latitude of members from a geospatial index."},
+	"GEORADIUS":            {-6, "write denyoom", "geo", "3.2.0", "Queries a geospatial index for members within a distance from a coordinate, optionally stores the result."},
+	"GEORADIUSBYMEMBER":    {-5, "write denyoom", "geo", "3.2.0", "Queries a geospatial index for members within a distance from a member, optionally stores the result."},
+	"GEORADIUSBYMEMBER_RO": {-5, "readonly", "geo", "3.2.10", "Returns members from a geospatial index that are within a distance from a member."},
+	"GEORADIUS_RO":         {-6, "readonly", "geo", "3.2.10", "Returns members from a geospatial index that are within a distance from a coordinate."},
+	"GEOSEARCH":            {-7, "readonly", "geo", "6.2.0", "Queries a geospatial index for members inside an area of a box or a circle."},
+	"GEOSEARCHSTORE":       {-8, "write denyoom", "geo", "6.2.0", "Queries a geospatial index for members inside an area of a box or a circle, optionally stores the result."},
+
+	// hash
+	"HDEL":         {-3, "write fast", "hash", "2.0.0", "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain."},
+	"HEXISTS":      {3, "readonly fast", "hash", "2.0.0", "Determines whether a field exists in a hash."},
+	"HEXPIRE":      {-6, "write fast", "hash", "7.4.0", "Set expiry for hash field using relative time to expire (seconds)."},
+	"HEXPIREAT":    {-6, "write fast", "hash", "7.4.0", "Set expiry for hash field using an absolute Unix timestamp (seconds)."},
+	"HEXPIRETIME":  {-5, "readonly fast", "hash", "7.4.0", "Returns the expiration time of a hash field as a Unix timestamp, in seconds."},
+	"HGET":         {3, "readonly fast", "hash", "2.0.0", "Returns the value of a field in a hash."},
+	"HGETALL":      {2, "readonly", "hash", "2.0.0", "Returns all fields and values in a hash."},
+	"HGETDEL":      {-5, "write fast", "hash", "8.0.0", "Returns the value of a field and deletes it from the hash."},
+	"HGETEX":       {-5, "write fast", "hash", "8.0.0", "Get the value of one or more fields of a given hash key, and optionally set their expiration."},
+	"HINCRBY":      {4, "write denyoom fast", "hash", "2.0.0", "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist."},
+	"HINCRBYFLOAT": {4, "write denyoom fast", "hash", "2.6.0", "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist."},
+	"HKEYS":        {2, "readonly", "hash", "2.0.0", "Returns all fields in a hash."},
+	"HLEN":         {2, "readonly fast", "hash", "2.0.0", "Returns the number of fields in a hash."},
+	"HMGET":        {-3, "readonly fast", "hash", "2.0.0", "Returns the values of all fields in a hash."},
+	"HMSET":        {-4, "write denyoom fast", "hash", "2.0.0", "Sets the values of multiple fields."},
+	"HPERSIST":     {-5, "write fast", "hash", "7.4.0", "Removes the expiration time for each specified field."},
+	"HPEXPIRE":     {-6, "write fast", "hash", "7.4.0", "Set expiry for hash field using relative time to expire (milliseconds)."},
+	"HPEXPIREAT":   {-6, "write fast", "hash", "7.4.0", "Set expiry for hash field using an absolute Unix timestamp (milliseconds)."},
+	"HPEXPIRETIME": {-5, "readonly fast", "hash", "7.4.0", "Returns the expiration time of a hash field as a Unix timestamp, in msec."},
+	"HPTTL":        {-5, "readonly fast", "hash", "7.4.0", "Returns the TTL in milliseconds of a hash field."},
+	"HSCAN":        {-3, "readonly", "hash", "2.8.0", "Iterates over fields and values of a hash."},
+	"HSET":         {-4, "write denyoom fast", "hash", "2.0.0", "Creates or modifies the value of a field in a hash."},
+	"HSETNX":       {4, "write denyoom fast", "hash", "2.0.0", "Sets the value of a field in a hash only when the field doesn't exist."},
+	"HSTRLEN":      {3, "readonly fast", "hash", "3.2.0", "Returns the length of the value of a field."},
+	"HTTL":         {-5, "readonly fast", "hash", "7.4.0", "Returns the TTL in seconds of a hash field."},
+	"HVALS":        {2, "readonly", "hash", "2.0.0", "Returns all values in a hash."},
+
+	// hyperloglog
+	"PFADD":   {-2, "write denyoom fast", "hyperloglog", "2.8.9", "Adds elements to a HyperLogLog key. Creates the key if it doesn't exist."},
+	"PFCOUNT": {-2, "readonly", "hyperloglog", "2.8.9", "Returns the approximated cardinality of the set(s) observed by the HyperLogLog key(s)."},
+	"PFMERGE": {-2, "write denyoom", "hyperloglog", "2.8.9", "Merges one or more HyperLogLog values into a single key."},
+
+	// list
+	"BLPOP":      {-3, "write blocking", "list", "2.0.0", "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped."},
+	"BRPOP":      {-3, "write blocking", "list", "2.0.0", "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped."},
+	"BRPOPLPUSH": {4, "write denyoom blocking", "list", "2.2.0", "Pops an element from a list, pushes it to another list and returns it. Block until an element is available otherwise. Deletes the list if the last element was popped."},
+	"LINDEX":     {3, "readonly", "list", "1.0.0", "Returns an element from a list by its index."},
+	"LINSERT":    {5, "write denyoom", "list", "2.2.0", "Inserts an element before or after another element in a list."},
+	"LLEN":       {2, "readonly fast", "list", "1.0.0", "Returns the length of a list."},
+	"LPOP":       {-2, "write fast", "list", "1.0.0", "Returns the first elements in a list after removing it. Deletes the list if the last element was popped."},
+	"LPUSH":      {-3, "write denyoom fast", "list", "1.0.0", "Prepends one or more elements to a list. Creates the key if it doesn't exist."},
+	"LPUSHX":     {-3, "write denyoom fast", "list", "2.2.0", "Prepends one or more elements to a list only when the list exists."},
+	"LRANGE":     {4, "readonly", "list", "1.0.0", "Returns a range of elements from a list."},
+	"LREM":       {4, "write", "list", "1.0.0", "Removes elements from a list. Deletes the list if the last element was removed."},
+	"LSET":       {4, "write denyoom", "list", "1.0.0", "Sets the value of an element in a list by its index."},
+	"LTRIM":      {4, "write", "list", "1.0.0", "Removes elements from both ends a list. Deletes the list if all elements were trimmed."},
+	"RPOP":       {-2, "write fast", "list", "1.0.0", "Returns and removes the last elements of a list. Deletes the list if the last element was popped."},
+	"RPOPLPUSH":  {3, "write denyoom", "list", "1.2.0", "Returns the last element of a list after removing and pushing it to another list. Deletes the list if the last element was popped."},
+	"RPUSH":      {-3, "write denyoom fast", "list", "1.0.0", "Appends one or more elements to a list. Creates the key if it doesn't exist."},
+	"RPUSHX":     {-3, "write denyoom fast", "list", "2.2.0", "Appends an element to a list only when the list exists."},
+
+	// pubsub
+	"PSUBSCRIBE":   {-2, "pubsub noscript loading stale", "pubsub", "2.0.0", "Listens for messages published to channels that match one or more patterns."},
+	"PUBLISH":      {3, "pubsub loading stale fast may_replicate", "pubsub", "2.0.0", "Posts a message to a channel."},
+	"PUBSUB":       {-2, "", "pubsub", "2.8.0", "A container for Pub/Sub commands."},
+	"PUNSUBSCRIBE": {-1, "pubsub noscript loading stale", "pubsub", "2.0.0", "Stops listening to messages published to channels that match one or more patterns."},
+	"SPUBLISH":     {3, "pubsub loading stale fast may_replicate", "pubsub", "7.0.0", "Post a message to a shard channel."},
+	"SSUBSCRIBE":   {-2, "pubsub noscript loading stale", "pubsub", "7.0.0", "Listens for messages published to shard channels."},
+	"SUBSCRIBE":    {-2, "pubsub noscript loading stale", "pubsub", "2.0.0", "Listens for messages published to channels."},
+	"SUNSUBSCRIBE": {-1, "pubsub noscript loading stale", "pubsub", "7.0.0", "Stops listening to messages posted to shard channels."},
+	"UNSUBSCRIBE":  {-1, "pubsub noscript loading stale", "pubsub", "2.0.0", "Stops listening to messages posted to channels."},
+
+	// scripting
+	"EVAL":       {-3, "noscript stale skip_monitor may_replicate no_mandatory_keys", "scripting", "2.6.0", "Executes a server-side Lua script."},
+	"EVALSHA":    {-3, "noscript stale skip_monitor may_replicate no_mandatory_keys", "scripting", "2.6.0", "Executes a server-side Lua script by SHA1 digest."},
+	"EVALSHA_RO": {-3, "readonly noscript stale skip_monitor no_mandatory_keys", "scripting", "7.0.0", "Executes a read-only server-side Lua script by SHA1 digest."},
+	"EVAL_RO":    {-3, "readonly noscript stale skip_monitor no_mandatory_keys", "scripting", "7.0.0", "Executes a read-only server-side Lua script."},
+	"FCALL":      {-3, "noscript stale skip_monitor may_replicate no_mandatory_keys", "scripting", "7.0.0", "Invokes a function."},
+	"FCALL_RO":   {-3, "readonly noscript stale skip_monitor no_mandatory_keys", "scripting", "7.0.0", "Invokes a read-only function."},
+	"FUNCTION":   {-2, "", "scripting", "7.0.0", "A container for function commands."},
+	"SCRIPT":     {-2, "", "scripting", "2.6.0", "A container for Lua scripts management commands."},
+
+	// server
+	"COMMAND":  {-1, "loading stale", "server", "2.8.13", "Returns detailed information about all commands."},
+	"CONFIG":   {-2, "", "server", "2.0.0", "A container for server configuration commands."},
+	"DBSIZE":   {1, "readonly fast", "server", "1.0.0", "Returns the number of keys in the database."},
+	"DEBUG":    {-2, "admin noscript loading stale", "server", "1.0.0", "A container for debugging commands."},
+	"FLUSHALL": {-1, "write", "server", "1.0.0", "Removes all keys from all databases."},
+	"FLUSHDB":  {-1, "write", "server", "1.0.0", "Remove all keys from the current database."},
+	"INFO":     {-1, "loading stale", "server", "1.0.0", "Returns information and statistics about the server."},
+	"SHUTDOWN": {-1, "admin noscript loading stale no_multi allow_busy", "server", "1.0.0", "Synchronously saves the database(s) to disk and shuts down the Redis server."},
+	"SWAPDB":   {3, "write fast", "server", "4.0.0", "Swaps two Redis databases."},
+	"TIME":     {1, "loading stale fast", "server", "2.6.0", "Returns the server time."},
+
+	// set
+	"SADD":        {-3, "write denyoom fast", "set", "1.0.0", "Adds one or more members to a set. Creates the key if it doesn't exist."},
+	"SCARD":       {2, "readonly fast", "set", "1.0.0", "Returns the number of members in a set."},
+	"SDIFF":       {-2, "readonly", "set", "1.0.0", "Returns the difference of multiple sets."},
+	"SDIFFSTORE":  {-3, "write denyoom", "set", "1.0.0", "Stores the difference of multiple sets in a key."},
+	"SINTER":      {-2, "readonly", "set", "1.0.0", "Returns the intersect of multiple sets."},
+	"SINTERSTORE": {-3, "write denyoom", "set", "1.0.0", "Stores the intersect of multiple sets in a key."},
+	"SISMEMBER":   {3, "readonly fast", "set", "1.0.0", "Determines whether a member belongs to a set."},
+	"SMEMBERS":    {2, "readonly", "set", "1.0.0", "Returns all members of a set."},
+	"SMISMEMBER":  {-3, "readonly fast", "set", "6.2.0", "Determines whether multiple members belong to a set."},
+	"SMOVE":       {4, "write fast", "set", "1.0.0", "Moves a member from one set to another."},
+	"SPOP":        {-2, "write fast", "set", "1.0.0", "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped."},
+	"SRANDMEMBER": {-2, "readonly", "set", "1.0.0", "Get one or multiple random members from a set."},
+	"SREM":        {-3, "write fast", "set", "1.0.0", "Removes one or more members from a set. Deletes the set if the last member was removed."},
+	"SSCAN":       {-3, "readonly", "set", "2.8.0", "Iterates over members of a set."},
+	"SUNION":      {-2, "readonly", "set", "1.0.0", "Returns the union of multiple sets."},
+	"SUNIONSTORE": {-3, "write denyoom", "set", "1.0.0", "Stores the union of multiple sets in a key."},
+
+	// sorted set
+	"BZMPOP":           {-5, "write blocking", "sorted-set", "7.0.0", "Removes and returns a member by score from one or more sorted sets. Blocks until a member is available otherwise. Deletes the sorted set if the last element was popped."},
+	"BZPOPMAX":         {-3, "write fast blocking", "sorted-set", "5.0.0", "Removes and returns the member with the highest score from one or more sorted sets. Blocks until a member available otherwise. Deletes the sorted set if the last element was popped."},
+	"BZPOPMIN":         {-3, "write fast blocking", "sorted-set", "5.0.0", "Removes and returns the member with the lowest score from one or more sorted sets. Blocks until a member is available otherwise. Deletes the sorted set if the last element was popped."},
+	"ZADD":             {-4, "write denyoom fast", "sorted-set", "1.2.0", "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist."},
+	"ZCARD":            {2, "readonly fast", "sorted-set", "1.2.0", "Returns the number of members in a sorted set."},
+	"ZCOUNT":           {4, "readonly fast", "sorted-set", "2.0.0", "Returns the count of members in a sorted set that have scores within a range."},
+	"ZINCRBY":          {4, "write denyoom fast", "sorted-set", "1.2.0", "Increments the score of a member in a sorted set."},
+	"ZINTER":           {-3, "readonly", "sorted-set", "6.2.0", "Returns the intersect of multiple sorted sets."},
+	"ZINTERCARD":       {-3, "readonly", "sorted-set", "7.0.0", "Returns the number of members of the intersect of multiple sorted sets."},
+	"ZINTERSTORE":      {-4, "write denyoom", "sorted-set", "2.0.0", "Stores the intersect of multiple sorted sets in a key."},
+	"ZLEXCOUNT":        {4, "readonly fast", "sorted-set", "2.8.9", "Returns the number of members in a sorted set within a lexicographical range."},
+	"ZMPOP":            {-4, "write", "sorted-set", "7.0.0", "Returns the highest- or lowest-scoring members from one or more sorted sets after removing them. Deletes the sorted set if the last member was popped."},
+	"ZMSCORE":          {-3, "readonly fast", "sorted-set", "6.2.0", "Returns the score of one or more members in a sorted set."},
+	"ZPOPMAX":          {-2, "write fast", "sorted-set", "5.0.0", "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped."},
+	"ZPOPMIN":          {-2, "write fast", "sorted-set", "5.0.0", "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped."},
+	"ZRANDMEMBER":      {-2, "readonly", "sorted-set", "6.2.0", "Returns one or more random members from a sorted set."},
+	"ZRANGE":           {-4, "readonly", "sorted-set", "1.2.0", "Returns members in a sorted set within a range of indexes."},
+	"ZRANGEBYLEX":      {-4, "readonly", "sorted-set", "2.8.9", "Returns members in a sorted set within a lexicographical range."},
+	"ZRANGEBYSCORE":    {-4, "readonly", "sorted-set", "1.0.5", "Returns members in a sorted set within a range of scores."},
+	"ZRANGESTORE":      {-5, "write denyoom", "sorted-set", "6.2.0", "Stores a range of members from sorted set in a key."},
+	"ZRANK":            {-3, "readonly fast", "sorted-set", "2.0.0", "Returns the index of a member in a sorted set ordered by ascending scores."},
+	"ZREM":             {-3, "write fast", "sorted-set", "1.2.0", "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed."},
+	"ZREMRANGEBYLEX":   {4, "write", "sorted-set", "2.8.9", "Removes members in a sorted set within a lexicographical range. Deletes the sorted set if all members were removed."},
+	"ZREMRANGEBYRANK":  {4, "write", "sorted-set", "2.0.0", "Removes members in a sorted set within a range of indexes. Deletes the sorted set if all members were removed."},
+	"ZREMRANGEBYSCORE": {4, "write", "sorted-set", "1.2.0", "Removes members in a sorted set within a range of scores. Deletes the sorted set if all members were removed."},
+	"ZREVRANGE":        {-4, "readonly", "sorted-set", "1.2.0", "Returns members in a sorted set within a range of indexes in reverse order."},
+	"ZREVRANGEBYLEX":   {-4, "readonly", "sorted-set", "2.8.9", "Returns members in a sorted set within a lexicographical range in reverse order."},
+	"ZREVRANGEBYSCORE": {-4, "readonly", "sorted-set", "2.2.0", "Returns members in a sorted set within a range of scores in reverse order."},
+	"ZREVRANK":         {-3, "readonly fast", "sorted-set", "2.0.0", "Returns the index of a member in a sorted set ordered by descending scores."},
+	"ZSCAN":            {-3, "readonly", "sorted-set", "2.8.0", "Iterates over members and scores of a sorted set."},
+	"ZSCORE":           {3, "readonly fast", "sorted-set", "1.2.0", "Returns the score of a member in a sorted set."},
+	"ZUNION":           {-3, "readonly", "sorted-set", "6.2.0", "Returns the union of multiple sorted sets."},
+	"ZUNIONSTORE":      {-4, "write denyoom", "sorted-set", "2.0.0", "Stores the union of multiple sorted sets in a key."},
+
+	// stream
+	"XACK":       {-4, "write fast", "stream", "5.0.0", "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream."},
+	"XACKDEL":    {-6, "write fast", "stream", "8.2.0", "Acknowledges and deletes one or multiple messages for a stream consumer group."},
+	"XADD":       {-5, "write denyoom fast", "stream", "5.0.0", "Appends a new message to a stream. Creates the key if it doesn't exist."},
+	"XDEL":       {-3, "write fast", "stream", "5.0.0", "Returns the number of messages after removing them from a stream."},
+	"XDELEX":     {-5, "write fast", "stream", "8.2.0", "Deletes one or multiple entries from the stream."},
+	"XGROUP":     {-2, "write denyoom", "stream", "5.0.0", "A container for consumer groups commands."},
+	"XINFO":      {-2, "readonly", "stream", "5.0.0", "A container for stream introspection commands."},
+	"XLEN":       {2, "readonly fast", "stream", "5.0.0", "Return the number of messages in a stream."},
+	"XPENDING":   {-3, "readonly", "stream", "5.0.0", "Returns the information and entries from a stream consumer group's pending entries list."},
+	"XRANGE":     {-4, "readonly", "stream", "5.0.0", "Returns the messages from a stream within a range of IDs."},
+	"XREAD":      {-4, "readonly blocking", "stream", "5.0.0", "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise."},
+	"XREADGROUP": {-7, "write blocking", "stream", "5.0.0", "Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise."},
+	"XREVRANGE":  {-4, "readonly", "stream", "5.0.0", "Returns the messages from a stream within a range of IDs in reverse order."},
+
+	// string
+	"APPEND":      {3, "write denyoom fast", "string", "2.0.0", "Appends a string to the value of a key. Creates the key if it doesn't exist."},
+	"DECR":        {2, "write denyoom fast", "string", "1.0.0", "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist."},
+	"DECRBY":      {3, "write denyoom fast", "string", "1.0.0", "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist."},
+	"GET":         {2, "readonly fast", "string", "1.0.0", "Returns the string value of a key."},
+	"GETDEL":      {2, "write fast", "string", "6.2.0", "Returns the string value of a key after deleting the key."},
+	"GETEX":       {-2, "write fast", "string", "6.2.0", "Returns the string value of a key after setting its expiration time."},
+	"GETRANGE":    {4, "readonly", "string", "2.4.0", "Returns a substring of the string stored at a key."},
+	"GETSET":      {3, "write denyoom fast", "string", "1.0.0", "Returns the previous string value of a key after setting it to a new value."},
+	"INCR":        {2, "write denyoom fast", "string", "1.0.0", "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist."},
+	"INCRBY":      {3, "write denyoom fast", "string", "1.0.0", "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist."},
+	"INCRBYFLOAT": {3, "write denyoom fast", "string", "2.6.0", "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist."},
+	"LCS":         {-3, "readonly", "string", "7.0.0", "Finds the longest common substring."},
+	"MGET":        {-2, "readonly fast", "string", "1.0.0", "Atomically returns the string values of one or more keys."},
+	"MSET":        {-3, "write denyoom", "string", "1.0.1", "Atomically creates or modifies the string values of one or more keys."},
+	"MSETNX":      {-3, "write denyoom", "string", "1.0.1", "Atomically modifies the string values of one or more keys only when all keys don't exist."},
+	"PSETEX":      {4, "write denyoom", "string", "2.6.0", "Sets both string value and expiration time in milliseconds of a key. The key is created if it doesn't exist."},
+	"SET":         {-3, "write denyoom", "string", "1.0.0", "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist."},
+	"SETEX":       {4, "write denyoom", "string", "2.0.0", "Sets the string value and expiration time of a key. Creates the key if it doesn't exist."},
+	"SETNX":       {3, "write denyoom fast", "string", "1.0.0", "Set the string value of a key only when the key doesn't exist."},
+	"SETRANGE":    {4, "write denyoom", "string", "2.2.0", "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist."},
+	"STRLEN":      {2, "readonly fast", "string", "2.2.0", "Returns the length of a string value."},
+	"SUBSTR":      {4, "readonly", "string", "1.0.0", "Returns a substring from a string value."},
+
+	// transactions
+	"DISCARD": {1, "noscript loading stale fast allow_busy", "transactions", "2.0.0", "Discards a transaction."},
+	"EXEC":    {1, "noscript loading stale skip_slowlog", "transactions", "1.2.0", "Executes all commands in a transaction."},
+	"MULTI":   {1, "noscript loading stale fast allow_busy", "transactions", "1.2.0", "Starts a transaction."},
+	"UNWATCH": {1, "noscript loading stale fast allow_busy", "transactions", "2.2.0", "Forgets about watched keys of a transaction."},
+	"WATCH":   {-2, "noscript loading stale fast allow_busy", "transactions", "2.2.0", "Monitors changes to keys to determine the execution of a transaction."},
+}
+
+// movableKeyRanges are the first key, last key, and step values for commands
+// with keys which can't be found with a keySpec.
+var movableKeyRanges = map[string]keySpec{
+	"GEORADIUS":         {1, 1, 1},
+	"GEORADIUSBYMEMBER": {1, 1, 1},
+	"MIGRATE":           {3, 3, 1},
+	"SORT":              {1, 1, 1},
+	"SORT_RO":           {1, 1, 1},
+	"ZINTERSTORE":       {1, 1, 1},
+	"ZUNIONSTORE":       {1, 1, 1},
+}
+
+// aclGroups are the ACL categories of the command groups.
+var aclGroups = map[string]string{
+	"bitmap":       "@bitmap",
+	"connection":   "@connection",
+	"generic":      "@keyspace",
+	"geo":          "@geo",
+	"hash":         "@hash",
+	"hyperloglog":  "@hyperloglog",
+	"list":         "@list",
+	"pubsub":       "@pubsub",
+	"scripting":    "@scripting",
+	"set":          "@set",
+	"sorted-set":   "@sortedset",
+	"stream":       "@stream",
+	"string":       "@string",
+	"transactions": "@transaction",
+}
+
+// flagList gives the COMMAND flags. cmd must be uppercase.
+func (ci commandInfo) flagList(cmd string) []string {
+	flags := strings.Fields(ci.flags)
+	if _, ok := keySpecFuncs[cmd]; ok {
+		flags = append(flags, "movablekeys")
+	}
+	return flags
+}
+
+// hasFlag is whether the command has the COMMAND flag.
+func (ci commandInfo) hasFlag(flag string) bool {
+	return strings.Contains(" "+ci.flags+" ", " "+flag+" ")
+}
+
+// keyRange gives the first key, last key, and step values. cmd must be
+// uppercase. Containers have no keys, only their subcommands do.
+func (ci commandInfo) keyRange(cmd string) keySpec {
+	if subcommandCommands[cmd] {
+		return keySpec{}
+	}
+	if r, ok := movableKeyRanges[cmd]; ok {
+		return r
+	}
+	if _, ok := keySpecFuncs[cmd]; ok {
+		return keySpec{}
+	}
+	return keySpecs[cmd]
+}
+
+// aclCategories gives the ACL categories, which we derive from the flags and
+// the group.
+func (ci commandInfo) aclCategories() []string {
+	var cats []string
+	has := map[string]bool{}
+	for _, f := range strings.Fields(ci.flags) {
+		has[f] = true
+	}
+	switch {
+	case has["write"]:
+		cats = append(cats, "@write")
+	case has["readonly"] && ci.group != "scripting":
+		cats = append(cats, "@read")
+	}
+	if c, ok := aclGroups[ci.group]; ok {
+		cats = append(cats, c)
+	}
+	if has["admin"] {
+		cats = append(cats, "@admin", "@dangerous")
+	}
+	if has["fast"] {
+		cats = append(cats, "@fast")
+	} else {
+		cats = append(cats, "@slow")
+	}
+	if has["blocking"] {
+		cats = append(cats, "@blocking")
+	}
+	return cats
+}
@@ -0,0 +1,245 @@
+package miniredis
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// redisConfig has the redis.conf settings which can be changed with CONFIG
+// SET. The defaults are the same as in Redis.
+type redisConfig struct {
+	appendonly          bool
+	maxmemory           int // in bytes. We never evict, see checkOOM().
+	maxclients          int
+	timeout             int // in seconds, see FastForward()
+	save                string
+	hllSparseMaxBytes   int
+	hashListpackEntries int
+	hashListpackValue   int
+	listListpackSize    int
+	setIntsetEntries    int
+	setListpackEntries  int
+	setListpackValue    int
+	zsetListpackEntries int
+	zsetListpackValue   int
+}
+
+func defaultConfig() redisConfig {
+	return redisConfig{
+		maxclients:          10000,
+		save:                "3600 1 300 100 60 10000",
+		hllSparseMaxBytes:   3000,
+		hashListpackEntries: 128,
+		hashListpackValue:   64,
+		listListpackSize:    -2,
+		setIntsetEntries:    512,
+		setListpackEntries:  128,
+		setListpackValue:    64,
+		zsetListpackEntries: 128,
+		zsetListpackValue:   64,
+	}
+}
+
+// listListpack is whether a list fits in a single listpack. A positive
+// list-max-listpack-size is the max number of elements, a negative one picks
+// a max size in bytes: -1 is 4KB, -2 is 8KB, up to -5 which is 64KB.
+func (cfg redisConfig) listListpack(l []string) bool {
+	if cfg.listListpackSize >= 0 {
+		return len(l) <= cfg.listListpackSize
+	}
+	level := -cfg.listListpackSize - 1
+	if level > 4 {
+		level = 4
+	}
+	size := 0
+	for _, e := range l {
+		size += len(e)
+	}
+	return size <= 4096<<uint(level)
+}
+
+var (
+	errConfigInt       = errors.New("argument couldn't be parsed into an integer")
+	errConfigMemory    = errors.New("argument must be a memory value")
+	errConfigImmutable = errors.New("can't set immutable config")
+)
+
+// configParam is a single CONFIG parameter.
+type configParam struct {
+	name  string
+	alias string // the pre Redis 7 name, if any
+	get   func(m *Miniredis) string
+	set   func(m *Miniredis, v string) error
+}
+
+// intParam is a configParam for a number field in redisConfig. min is the
+// lowest valid value.
+func intParam(name, alias string, min int, field func(*redisConfig) *int) configParam {
+	return configParam{
+		name:  name,
+		alias: alias,
+		get: func(m *Miniredis) string {
+			return strconv.Itoa(*field(&m.config))
+		},
+		set: func(m *Miniredis, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return errConfigInt
+			}
+			if n < min {
+				return errors.New("argument must be between " + strconv.Itoa(min) + " and 9223372036854775807 inclusive")
+			}
+			*field(&m.config) = n
+			return nil
+		},
+	}
+}
+
+// immutableParam is a configParam which CONFIG SET refuses to change.
+func immutableParam(name string, get func(m *Miniredis) string) configParam {
+	return configParam{
+		name: name,
+		get:  get,
+		set: func(m *Miniredis, v string) error {
+			return errConfigImmutable
+		},
+	}
+}
+
+// parseMemory parses a memory value, such as "100", "10k" or "1gb". "k" is
+// 1000 bytes, "kb" is 1024 bytes, &c.
+func parseMemory(v string) (int, error) {
+	v = strings.ToLower(v)
+	mul := 1
+	for _, u := range []struct {
+		suffix string
+		mul    int
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+		{"b", 1},
+	} {
+		if strings.HasSuffix(v, u.suffix) {
+			v, mul = strings.TrimSuffix(v, u.suffix), u.mul
+			break
+		}
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n*mul/mul != n { // the last one is an overflow
+		return 0, errConfigMemory
+	}
+	return n * mul, nil
+}
+
+var configParams = []configParam{
+	{
+		name: "appendonly",
+		get: func(m *Miniredis) string {
+			if m.config.appendonly {
+				return "yes"
+			}
+			return "no"
+		},
+		set: func(m *Miniredis, v string) error {
+			switch strings.ToLower(v) {
+			case "yes":
+				m.config.appendonly = true
+			case "no":
+				m.config.appendonly = false
+			default:
+				return errors.New("argument must be 'yes' or 'no'")
+			}
+			return nil
+		},
+	},
+	immutableParam("databases", func(m *Miniredis) string { return "16" }),
+	immutableParam("port", func(m *Miniredis) string { return strconv.Itoa(m.port) }),
+	{
+		name: "maxmemory",
+		get: func(m *Miniredis) string {
+			return strconv.Itoa(m.config.maxmemory)
+		},
+		set: func(m *Miniredis, v string) error {
+			n, err := parseMemory(v)
+			if err != nil {
+				return err
+			}
+			m.config.maxmemory = n
+			return nil
+		},
+	},
+	intParam("maxclients", "", 1, func(c *redisConfig) *int { return &c.maxclients }),
+	intParam("timeout", "", 0, func(c *redisConfig) *int { return &c.timeout }),
+	{
+		name: "save",
+		get: func(m *Miniredis) string {
+			return m.config.save
+		},
+		set: func(m *Miniredis, v string) error {
+			// pairs of <seconds> <changes>
+			args := strings.Fields(v)
+			if len(args)%2 != 0 {
+				return errors.New("Invalid save parameters")
+			}
+			for _, a := range args {
+				if n, err := strconv.Atoi(a); err != nil || n < 0 {
+					return errors.New("Invalid save parameters")
+				}
+			}
+			m.config.save = strings.Join(args, " ")
+			return nil
+		},
+	},
+	intParam("hash-max-listpack-entries", "hash-max-ziplist-entries", 0, func(c *redisConfig) *int { return &c.hashListpackEntries }),
+	intParam("hash-max-listpack-value", "hash-max-ziplist-value", 0, func(c *redisConfig) *int { return &c.hashListpackValue }),
+	intParam("hll-sparse-max-bytes", "", 0, func(c *redisConfig) *int { return &c.hllSparseMaxBytes }),
+	intParam("list-max-listpack-size", "list-max-ziplist-size", -2147483648, func(c *redisConfig) *int { return &c.listListpackSize }),
+	intParam("set-max-intset-entries", "", 0, func(c *redisConfig) *int { return &c.setIntsetEntries }),
+	intParam("set-max-listpack-entries", "", 0, func(c *redisConfig) *int { return &c.setListpackEntries }),
+	intParam("set-max-listpack-value", "", 0, func(c *redisConfig) *int { return &c.setListpackValue }),
+	intParam("zset-max-listpack-entries", "zset-max-ziplist-entries", 0, func(c *redisConfig) *int { return &c.zsetListpackEntries }),
+	intParam("zset-max-listpack-value", "zset-max-ziplist-value", 0, func(c *redisConfig) *int { return &c.zsetListpackValue }),
+	{
+		name: "notify-keyspace-events",
+		get: func(m *Miniredis) string {
+			return notifyFlagsString(m.notifyFlags)
+		},
+		set: func(m *Miniredis, v string) error {
+			f, err := parseNotifyFlags(v)
+			if err != nil {
+				return err
+			}
+			m.notifyFlags = f
+			return nil
+		},
+	},
+	{
+		name: "maxmemory-policy",
+		get: func(m *Miniredis) string {
+			if m.maxmemoryPolicy == "" {
+				return "noeviction"
+			}
+			return m.maxmemoryPolicy
+		},
+		set: func(m *Miniredis, v string) error {
+			v = strings.ToLower(v)
+			if !validMaxMemoryPolicy(v) {
+				return errors.New("argument(s) must be one of the following: volatile-lru, volatile-lfu, volatile-random, volatile-ttl, allkeys-lru, allkeys-lfu, allkeys-random, noeviction")
+			}
+			m.maxmemoryPolicy = v
+			return nil
+		},
+	},
+}
+
+// findConfigParam looks up a parameter by name or alias, or returns nil.
+func findConfigParam(name string) *configParam {
+	name = strings.ToLower(name)
+	for i, p := range configParams {
+		if p.name == name || (p.alias != "" && p.alias == name) {
+			return &configParams[i]
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package miniredis
 import (
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"sort"
 	"strconv"
@@ -13,6 +14,18 @@ var (
 	errInvalidEntryID = errors.New("stream ID is invalid")
 )
 
+// markDirty records that a key changed, which makes WATCH fail and sends
+// CLIENT TRACKING invalidations. Every write to a key needs to call this, also
+// when the key gets deleted.
+func (db *RedisDB) markDirty(k string) {
+	db.keyVersion[k]++
+	db.memDirty[k] = struct{}{}
+	// the DB RESTORE decodes into isn't one of ours
+	if m := db.master; len(m.trackers) > 0 && m.dbs[db.id] == db {
+		m.changedKeys[k] = struct{}{}
+	}
+}
+
 func (db *RedisDB) exists(k string) bool {
 	_, ok := db.keys[k]
 	return ok
@@ -23,6 +36,167 @@ func (db *RedisDB) t(k string) string {
 	return db.keys[k]
 }
 
+// Strings up to this size are "embstr".
+const encEmbstrSize = 44
+
+// encoding is what OBJECT ENCODING reports for a key. Key must exist.
+// Unlike Redis we look at the current value every time, so a shrinking key
+// will go back to the compact encoding.
+func (db *RedisDB) encoding(k string) string {
+	cfg := db.master.config
+	switch db.t(k) {
+	case "string":
+		v := db.stringKeys[k]
+		if isEncInt(v) {
+			return "int"
+		}
+		if len(v) <= encEmbstrSize {
+			return "embstr"
+		}
+		return "raw"
+	case "list":
+		if cfg.listListpack(db.listKeys[k].elems()) {
+			return "listpack"
+		}
+		return "quicklist"
+	case "set":
+		set := db.setKeys[k]
+		ints := len(set) <= cfg.setIntsetEntries
+		small := len(set) <= cfg.setListpackEntries
+		for e := range set {
+			if ints && !isEncInt(e) {
+				ints = false
+			}
+			if len(e) > cfg.setListpackValue {
+				small = false
+			}
+		}
+		switch {
+		case ints:
+			return "intset"
+		case small:
+			return "listpack"
+		default:
+			return "hashtable"
+		}
+	case "hash":
+		hash := db.hashKeys[k]
+		if len(hash) > cfg.hashListpackEntries {
+			return "hashtable"
+		}
+		for f, v := range hash {
+			if len(f) > cfg.hashListpackValue || len(v) > cfg.hashListpackValue {
+				return "hashtable"
+			}
+		}
+		if len(db.hashTTLs[k]) > 0 {
+			return "listpackex"
+		}
+		return "listpack"
+	case "zset":
+		ss := db.sortedsetKeys[k]
+		if len(ss) > cfg.zsetListpackEntries {
+			return "skiplist"
+		}
+		for e := range ss {
+			if len(e) > cfg.zsetListpackValue {
+				return "skiplist"
+			}
+		}
+		return "listpack"
+	case "stream":
+		return "stream"
+	default:
+		return ""
+	}
+}
+
+// Shared integers, as OBJ_SHARED_INTEGERS and OBJ_SHARED_REFCOUNT in Redis.
+const (
+	sharedIntegers = 10000
+	sharedRefcount = math.MaxInt32
+)
+
+// refcount is what OBJECT REFCOUNT reports for a key. Key must exist. Redis
+// shares the objects for small integer strings, unless an LRU or LFU maxmemory
+// policy needs per key access info.
+func (db *RedisDB) refcount(k string) int {
+	if db.t(k) != "string" || db.master.lruPolicy() || db.master.lfuPolicy() {
+		return 1
+	}
+	v := db.stringKeys[k]
+	if !isEncInt(v) {
+		return 1
+	}
+	if n, _ := strconv.Atoi(v); n < 0 || n >= sharedIntegers {
+		return 1
+	}
+	return sharedRefcount
+}
+
+// isEncInt is whether Redis would store v as an integer: a 64 bit number
+// without any extra characters, such as "+1" or "01".
+func isEncInt(v string) bool {
+	n, err := strconv.ParseInt(v, 10, 64)
+	return err == nil && strconv.FormatInt(n, 10) == v
+}
+
+// LFU settings, as "lfu-log-factor" and "lfu-decay-time" in redis.conf.
+const (
+	lfuInitVal   = 5
+	lfuLogFactor = 10
+	lfuDecayTime = time.Minute
+)
+
+// touch updates the access time and frequency of a key, the same as a
+// lookup does in Redis.
+func (db *RedisDB) touch(k string, now time.Time) {
+	if !db.exists(k) {
+		return
+	}
+	if _, ok := db.lastAccess[k]; !ok {
+		// new key
+		db.lastAccess[k] = now
+		db.accessFreq[k] = lfuInitVal
+		return
+	}
+	counter := db.freq(k, now)
+	// Redis increments the counter with a chance of p. We always add p, which
+	// gives the same counter on average, without the randomness.
+	base := math.Floor(counter) - lfuInitVal
+	if base < 0 {
+		base = 0
+	}
+	counter += 1 / (base*lfuLogFactor + 1)
+	if counter > 255 {
+		counter = 255
+	}
+	db.accessFreq[k] = counter
+	db.lastAccess[k] = now
+}
+
+// freq is the LFU counter of a key, with the decay since its last access.
+func (db *RedisDB) freq(k string, now time.Time) float64 {
+	last, ok := db.lastAccess[k]
+	if !ok {
+		return lfuInitVal
+	}
+	counter := db.accessFreq[k] - float64(now.Sub(last)/lfuDecayTime)
+	if counter < 0 {
+		counter = 0
+	}
+	return counter
+}
+
+// idle is the time since the last access of a key.
+func (db *RedisDB) idle(k string, now time.Time) time.Duration {
+	last, ok := db.lastAccess[k]
+	if !ok {
+		return 0
+	}
+	return now.Sub(last)
+}
+
 // allKeys returns all keys. Sorted.
 func (db *RedisDB) allKeys() []string {
 	res := make([]string, 0, len(db.keys))
@@ -35,14 +209,21 @@ func (db *RedisDB) allKeys() []string {
 
 // flush removes all keys and values.
 func (db *RedisDB) flush() {
+	for k := range db.keys {
+		db.markDirty(k)
+	}
 	db.keys = map[string]string{}
 	db.stringKeys = map[string]string{}
 	db.hashKeys = map[string]hashKey{}
-	db.listKeys = map[string]listKey{}
+	db.listKeys = map[string]*listKey{}
 	db.setKeys = map[string]setKey{}
 	db.sortedsetKeys = map[string]sortedSet{}
 	db.ttl = map[string]time.Duration{}
 	db.streamKeys = map[string]*streamKey{}
+	db.lastAccess = map[string]time.Time{}
+	db.accessFreq = map[string]float64{}
+	db.hashTTLs = map[string]hashTTL{}
+	db.master.dropScanCursors(db.id)
 }
 
 // move something to another db. Will return ok. Or not.
@@ -61,6 +242,9 @@ func (db *RedisDB) move(key string, to *RedisDB) bool {
 		to.stringKeys[key] = db.stringKeys[key]
 	case "hash":
 		to.hashKeys[key] = db.hashKeys[key]
+		if ttls, ok := db.hashTTLs[key]; ok {
+			to.hashTTLs[key] = ttls
+		}
 	case "list":
 		to.listKeys[key] = db.listKeys[key]
 	case "set":
@@ -72,14 +256,65 @@ func (db *RedisDB) move(key string, to *RedisDB) bool {
 	default:
 		panic("unhandled key type")
 	}
-	to.keyVersion[key]++
+	to.markDirty(key)
 	if v, ok := db.ttl[key]; ok {
 		to.ttl[key] = v
 	}
+	if v, ok := db.lastAccess[key]; ok {
+		to.lastAccess[key] = v
+		to.accessFreq[key] = db.accessFreq[key]
+	}
 	db.del(key, true)
+	to.master.keyReady(to.id, key)
 	return true
 }
 
+// copy a key, possibly to another db. Overwrites the destination key.
+func (db *RedisDB) copy(from string, to *RedisDB, toKey string) {
+	to.del(toKey, true)
+	switch db.t(from) {
+	case "string":
+		to.stringKeys[toKey] = db.stringKeys[from]
+	case "hash":
+		h := hashKey{}
+		for f, v := range db.hashKeys[from] {
+			h[f] = v
+		}
+		to.hashKeys[toKey] = h
+		if ttls, ok := db.hashTTLs[from]; ok {
+			t := hashTTL{}
+			for f, ttl := range ttls {
+				t[f] = ttl
+			}
+			to.hashTTLs[toKey] = t
+		}
+	case "list":
+		to.listKeys[toKey] = newListKey(db.listKeys[from].elems()...)
+	case "set":
+		s := setKey{}
+		for e := range db.setKeys[from] {
+			s[e] = struct{}{}
+		}
+		to.setKeys[toKey] = s
+	case "zset":
+		ss := sortedSet{}
+		for e, score := range db.sortedsetKeys[from] {
+			ss[e] = score
+		}
+		to.sortedsetKeys[toKey] = ss
+	case "stream":
+		to.streamKeys[toKey] = db.streamKeys[from].copy()
+	default:
+		panic("missing case")
+	}
+	to.keys[toKey] = db.keys[from]
+	to.markDirty(toKey)
+	if v, ok := db.ttl[from]; ok {
+		to.ttl[toKey] = v
+	}
+	to.master.keyReady(to.id, toKey)
+}
+
 func (db *RedisDB) rename(from, to string) {
 	db.del(to, true)
 	switch db.t(from) {
@@ -87,6 +322,9 @@ func (db *RedisDB) rename(from, to string) {
 		db.stringKeys[to] = db.stringKeys[from]
 	case "hash":
 		db.hashKeys[to] = db.hashKeys[from]
+		if ttls, ok := db.hashTTLs[from]; ok {
+			db.hashTTLs[to] = ttls
+		}
 	case "list":
 		db.listKeys[to] = db.listKeys[from]
 	case "set":
@@ -99,12 +337,17 @@ func (db *RedisDB) rename(from, to string) {
 		panic("missing case")
 	}
 	db.keys[to] = db.keys[from]
-	db.keyVersion[to]++
+	db.markDirty(to)
 	if v, ok := db.ttl[from]; ok {
 		db.ttl[to] = v
 	}
+	if v, ok := db.lastAccess[from]; ok {
+		db.lastAccess[to] = v
+		db.accessFreq[to] = db.accessFreq[from]
+	}
 
 	db.del(from, true)
+	db.master.keyReady(db.id, to)
 }
 
 func (db *RedisDB) del(k string, delTTL bool) {
@@ -113,15 +356,19 @@ func (db *RedisDB) del(k string, delTTL bool) {
 	}
 	t := db.t(k)
 	delete(db.keys, k)
-	db.keyVersion[k]++
+	db.markDirty(k)
 	if delTTL {
+		// not an overwrite, so the access info goes as well
 		delete(db.ttl, k)
+		delete(db.lastAccess, k)
+		delete(db.accessFreq, k)
 	}
 	switch t {
 	case "string":
 		delete(db.stringKeys, k)
 	case "hash":
 		delete(db.hashKeys, k)
+		delete(db.hashTTLs, k)
 	case "list":
 		delete(db.listKeys, k)
 	case "set":
@@ -148,21 +395,41 @@ func (db *RedisDB) stringSet(k, v string) {
 	db.del(k, false)
 	db.keys[k] = "string"
 	db.stringKeys[k] = v
-	db.keyVersion[k]++
+	db.markDirty(k)
+}
+
+// hllGet gives the HyperLogLog stored in a string key, or nil if there is no
+// such key.
+func (db *RedisDB) hllGet(k string) (*hll, error) {
+	if !db.exists(k) {
+		return nil, nil
+	}
+	if db.t(k) != "string" {
+		return nil, ErrWrongType
+	}
+	return parseHLL(db.stringKeys[k])
+}
+
+// hllSet stores a HyperLogLog as a string. Does not touch expire.
+func (db *RedisDB) hllSet(k string, h *hll) {
+	db.stringSet(k, h.encode(db.master.config.hllSparseMaxBytes))
 }
 
 // change int key value
-func (db *RedisDB) stringIncr(k string, delta int) (int, error) {
-	v := 0
+func (db *RedisDB) stringIncr(k string, delta int64) (int64, error) {
+	var v int64
 	if sv, ok := db.stringKeys[k]; ok {
 		var err error
-		v, err = strconv.Atoi(sv)
+		v, err = strconv.ParseInt(sv, 10, 64)
 		if err != nil {
 			return 0, ErrIntValueError
 		}
 	}
+	if (delta > 0 && v > math.MaxInt64-delta) || (delta < 0 && v < math.MinInt64-delta) {
+		return 0, errors.New(msgIncrOverflow)
+	}
 	v += delta
-	db.stringSet(k, strconv.Itoa(v))
+	db.stringSet(k, strconv.FormatInt(v, 10))
 	return v, nil
 }
 
@@ -177,6 +444,9 @@ func (db *RedisDB) stringIncrfloat(k string, delta *big.Float) (*big.Float, erro
 			return nil, ErrFloatValueError
 		}
 	}
+	if v.IsInf() || delta.IsInf() {
+		return nil, errors.New(msgIncrFloatInf)
+	}
 	v.Add(v, delta)
 	db.stringSet(k, formatBig(v))
 	return v, nil
@@ -187,24 +457,23 @@ func (db *RedisDB) listLpush(k, v string) int {
 	l, ok := db.listKeys[k]
 	if !ok {
 		db.keys[k] = "list"
+		l = newListKey()
+		db.listKeys[k] = l
 	}
-	l = append([]string{v}, l...)
-	db.listKeys[k] = l
-	db.keyVersion[k]++
-	return len(l)
+	l.lpush(v)
+	db.markDirty(k)
+	db.master.keyReady(db.id, k)
+	return l.len()
 }
 
 // 'left pop', aka shift.
 func (db *RedisDB) listLpop(k string) string {
 	l := db.listKeys[k]
-	el := l[0]
-	l = l[1:]
-	if len(l) == 0 {
+	el := l.lpop()
+	if l.len() == 0 {
 		db.del(k, true)
-	} else {
-		db.listKeys[k] = l
 	}
-	db.keyVersion[k]++
+	db.markDirty(k)
 	return el
 }
 
@@ -212,22 +481,22 @@ func (db *RedisDB) listPush(k string, v ...string) int {
 	l, ok := db.listKeys[k]
 	if !ok {
 		db.keys[k] = "list"
+		l = newListKey()
+		db.listKeys[k] = l
 	}
-	l = append(l, v...)
-	db.listKeys[k] = l
-	db.keyVersion[k]++
-	return len(l)
+	l.rpush(v...)
+	db.markDirty(k)
+	db.master.keyReady(db.id, k)
+	return l.len()
 }
 
 func (db *RedisDB) listPop(k string) string {
 	l := db.listKeys[k]
-	el := l[len(l)-1]
-	l = l[:len(l)-1]
-	if len(l) == 0 {
+	el := l.rpop()
+	if l.len() == 0 {
 		db.del(k, true)
 	} else {
-		db.listKeys[k] = l
-		db.keyVersion[k]++
+		db.markDirty(k)
 	}
 	return el
 }
@@ -236,7 +505,7 @@ func (db *RedisDB) listPop(k string) string {
 func (db *RedisDB) setSet(k string, set setKey) {
 	db.keys[k] = "set"
 	db.setKeys[k] = set
-	db.keyVersion[k]++
+	db.markDirty(k)
 }
 
 // setadd adds members to a set. Returns nr of new keys.
@@ -254,7 +523,7 @@ func (db *RedisDB) setAdd(k string, elems ...string) int {
 		s[e] = struct{}{}
 	}
 	db.setKeys[k] = s
-	db.keyVersion[k]++
+	db.markDirty(k)
 	return added
 }
 
@@ -276,7 +545,7 @@ func (db *RedisDB) setRem(k string, fields ...string) int {
 	} else {
 		db.setKeys[k] = s
 	}
-	db.keyVersion[k]++
+	db.markDirty(k)
 	return removed
 }
 
@@ -342,7 +611,8 @@ func (db *RedisDB) hashSet(k string, fv ...string) int {
 		f, v := fv[idx], fv[idx+1]
 		_, ok := db.hashKeys[k][f]
 		db.hashKeys[k][f] = v
-		db.keyVersion[k]++
+		db.hashPersist(k, f)
+		db.markDirty(k)
 		if !ok {
 			new++
 		}
@@ -350,6 +620,66 @@ func (db *RedisDB) hashSet(k string, fv ...string) int {
 	return new
 }
 
+// hashUpdate sets a single field, but unlike hashSet it keeps the TTL of the
+// field.
+func (db *RedisDB) hashUpdate(k, f, v string) {
+	ttl, ok := db.hashFieldTTL(k, f)
+	db.hashSet(k, f, v)
+	if ok {
+		db.setHashFieldTTL(k, f, ttl)
+	}
+}
+
+// hashFieldTTL gives the TTL of a hash field, if it has one.
+func (db *RedisDB) hashFieldTTL(k, f string) (time.Duration, bool) {
+	ttl, ok := db.hashTTLs[k][f]
+	return ttl, ok
+}
+
+// setHashFieldTTL sets the TTL of an existing hash field.
+func (db *RedisDB) setHashFieldTTL(k, f string, ttl time.Duration) {
+	ttls, ok := db.hashTTLs[k]
+	if !ok {
+		ttls = hashTTL{}
+		db.hashTTLs[k] = ttls
+	}
+	ttls[f] = ttl
+	db.markDirty(k)
+}
+
+// hashPersist removes the TTL of a hash field. Returns whether there was one.
+func (db *RedisDB) hashPersist(k, f string) bool {
+	ttls := db.hashTTLs[k]
+	if _, ok := ttls[f]; !ok {
+		return false
+	}
+	delete(ttls, f)
+	if len(ttls) == 0 {
+		delete(db.hashTTLs, k)
+	}
+	db.markDirty(k)
+	return true
+}
+
+// hashDel deletes hash fields, and the key if nothing is left. Returns the
+// number of deleted fields.
+func (db *RedisDB) hashDel(k string, fields ...string) int {
+	deleted := 0
+	for _, f := range fields {
+		if _, ok := db.hashKeys[k][f]; !ok {
+			continue
+		}
+		delete(db.hashKeys[k], f)
+		db.hashPersist(k, f)
+		db.markDirty(k)
+		deleted++
+	}
+	if len(db.hashKeys[k]) == 0 {
+		db.del(k, true)
+	}
+	return deleted
+}
+
 // hashIncr changes int key value
 func (db *RedisDB) hashIncr(key, field string, delta int) (int, error) {
 	v := 0
@@ -363,7 +693,7 @@ func (db *RedisDB) hashIncr(key, field string, delta int) (int, error) {
 		}
 	}
 	v += delta
-	db.hashSet(key, field, strconv.Itoa(v))
+	db.hashUpdate(key, field, strconv.Itoa(v))
 	return v, nil
 }
 
@@ -376,12 +706,15 @@ func (db *RedisDB) hashIncrfloat(key, field string, delta *big.Float) (*big.Floa
 			var err error
 			v, _, err = big.ParseFloat(f, 10, 128, 0)
 			if err != nil {
-				return nil, ErrFloatValueError
+				return nil, errors.New(msgHashFloat)
 			}
 		}
 	}
+	if v.IsInf() || delta.IsInf() {
+		return nil, errors.New(msgIncrFloatInf)
+	}
 	v.Add(v, delta)
-	db.hashSet(key, field, formatBig(v))
+	db.hashUpdate(key, field, formatBig(v))
 	return v, nil
 }
 
@@ -394,8 +727,9 @@ func (db *RedisDB) sortedSet(key string) map[string]float64 {
 // ssetSet sets a complete sorted set.
 func (db *RedisDB) ssetSet(key string, sset sortedSet) {
 	db.keys[key] = "zset"
-	db.keyVersion[key]++
+	db.markDirty(key)
 	db.sortedsetKeys[key] = sset
+	db.master.keyReady(db.id, key)
 }
 
 // ssetAdd adds member to a sorted set. Returns whether this was a new member.
@@ -408,7 +742,8 @@ func (db *RedisDB) ssetAdd(key string, score float64, member string) bool {
 	_, ok = ss[member]
 	ss[member] = score
 	db.sortedsetKeys[key] = ss
-	db.keyVersion[key]++
+	db.markDirty(key)
+	db.master.keyReady(db.id, key)
 	return !ok
 }
 
@@ -462,6 +797,9 @@ func (db *RedisDB) ssetRem(key, member string) bool {
 		// Delete key on removal of last member
 		db.del(key, true)
 	}
+	if ok {
+		db.markDirty(key)
+	}
 	return ok
 }
 
@@ -484,7 +822,8 @@ func (db *RedisDB) ssetIncrby(k, m string, delta float64) float64 {
 	v, _ := ss.get(m)
 	v += delta
 	ss.set(v, m)
-	db.keyVersion[k]++
+	db.markDirty(k)
+	db.master.keyReady(db.id, k)
 	return v
 }
 
@@ -580,7 +919,7 @@ func (db *RedisDB) newStream(key string) (*streamKey, error) {
 	db.keys[key] = "stream"
 	s := newStreamKey()
 	db.streamKeys[key] = s
-	db.keyVersion[key]++
+	db.markDirty(key)
 	return s, nil
 }
 
@@ -604,16 +943,45 @@ func (db *RedisDB) streamGroup(key, group string) (*streamGroup, error) {
 
 // fastForward proceeds the current timestamp with duration, works as a time machine
 func (db *RedisDB) fastForward(duration time.Duration) {
+	db.expireKeys(duration)
+	for _, s := range db.streamKeys {
+		s.fastForward(duration)
+	}
+	for k, t := range db.lastAccess {
+		db.lastAccess[k] = t.Add(-duration)
+	}
+}
+
+// expireKeys lowers all TTLs with duration, and removes the keys which
+// expired.
+func (db *RedisDB) expireKeys(duration time.Duration) {
 	for _, key := range db.allKeys() {
 		if value, ok := db.ttl[key]; ok {
 			db.ttl[key] = value - duration
-			db.checkTTL(key)
+			if db.ttl[key] <= 0 {
+				db.expired(key)
+			}
+		}
+	}
+	for key, ttls := range db.hashTTLs {
+		var expired []string
+		for f, ttl := range ttls {
+			ttls[f] = ttl - duration
+			if ttls[f] <= 0 {
+				expired = append(expired, f)
+			}
+		}
+		if len(expired) > 0 {
+			db.hashDel(key, expired...)
+			db.master.notify(db.id, notifyHash, "hexpired", key)
+			db.master.notifyDeleted(db, key)
 		}
 	}
 }
 
-func (db *RedisDB) checkTTL(key string) {
-	if v, ok := db.ttl[key]; ok && v <= 0 {
-		db.del(key, true)
-	}
+// expired removes a key whose TTL ran out.
+func (db *RedisDB) expired(key string) {
+	db.del(key, true)
+	db.master.keyReady(db.id, key)
+	db.master.notify(db.id, notifyExpired, "expired", key)
 }
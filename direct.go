@@ -46,7 +46,6 @@ func (db *RedisDB) Keys() []string {
 func (m *Miniredis) FlushAll() {
 	m.Lock()
 	defer m.Unlock()
-	defer m.signal.Broadcast()
 
 	m.flushAll()
 }
@@ -66,7 +65,6 @@ func (m *Miniredis) FlushDB() {
 func (db *RedisDB) FlushDB() {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	db.flush()
 }
@@ -100,7 +98,6 @@ func (m *Miniredis) Set(k, v string) error {
 func (db *RedisDB) Set(k, v string) error {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if db.exists(k) && db.t(k) != "string" {
 		return ErrWrongType
@@ -119,13 +116,13 @@ func (m *Miniredis) Incr(k string, delta int) (int, error) {
 func (db *RedisDB) Incr(k string, delta int) (int, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if db.exists(k) && db.t(k) != "string" {
 		return 0, ErrWrongType
 	}
 
-	return db.stringIncr(k, delta)
+	v, err := db.stringIncr(k, int64(delta))
+	return int(v), err
 }
 
 // IncrByFloat increments the float value of a key by the given delta.
@@ -143,7 +140,6 @@ func (m *Miniredis) Incrfloat(k string, delta float64) (float64, error) {
 func (db *RedisDB) Incrfloat(k string, delta float64) (float64, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if db.exists(k) && db.t(k) != "string" {
 		return 0, ErrWrongType
@@ -177,7 +173,7 @@ func (db *RedisDB) List(k string) ([]string, error) {
 	if db.t(k) != "list" {
 		return nil, ErrWrongType
 	}
-	return db.listKeys[k], nil
+	return append([]string(nil), db.listKeys[k].elems()...), nil
 }
 
 // Lpush prepends one value to a list. Returns the new length.
@@ -189,7 +185,6 @@ func (m *Miniredis) Lpush(k, v string) (int, error) {
 func (db *RedisDB) Lpush(k, v string) (int, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if db.exists(k) && db.t(k) != "list" {
 		return 0, ErrWrongType
@@ -206,7 +201,6 @@ func (m *Miniredis) Lpop(k string) (string, error) {
 func (db *RedisDB) Lpop(k string) (string, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if !db.exists(k) {
 		return "", ErrKeyNotFound
@@ -232,7 +226,6 @@ func (m *Miniredis) Push(k string, v ...string) (int, error) {
 func (db *RedisDB) Push(k string, v ...string) (int, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if db.exists(k) && db.t(k) != "list" {
 		return 0, ErrWrongType
@@ -254,7 +247,6 @@ func (m *Miniredis) Pop(k string) (string, error) {
 func (db *RedisDB) Pop(k string) (string, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if !db.exists(k) {
 		return "", ErrKeyNotFound
@@ -281,7 +273,6 @@ func (m *Miniredis) SetAdd(k string, elems ...string) (int, error) {
 func (db *RedisDB) SetAdd(k string, elems ...string) (int, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if db.exists(k) && db.t(k) != "set" {
 		return 0, ErrWrongType
@@ -367,7 +358,6 @@ func (m *Miniredis) Del(k string) bool {
 func (db *RedisDB) Del(k string) bool {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if !db.exists(k) {
 		return false
@@ -415,10 +405,9 @@ func (m *Miniredis) SetTTL(k string, ttl time.Duration) {
 func (db *RedisDB) SetTTL(k string, ttl time.Duration) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	db.ttl[k] = ttl
-	db.keyVersion[k]++
+	db.markDirty(k)
 }
 
 // Type gives the type of a key, or ""
@@ -479,7 +468,6 @@ func (m *Miniredis) HSet(k string, fv ...string) {
 func (db *RedisDB) HSet(k string, fv ...string) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	db.hashSet(k, fv...)
 }
@@ -493,7 +481,6 @@ func (m *Miniredis) HDel(k, f string) {
 func (db *RedisDB) HDel(k, f string) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	db.hdel(k, f)
 }
@@ -503,7 +490,57 @@ func (db *RedisDB) hdel(k, f string) {
 		return
 	}
 	delete(db.hashKeys[k], f)
-	db.keyVersion[k]++
+	db.hashPersist(k, f)
+	db.markDirty(k)
+}
+
+// HFieldTTL is the left over time to live of a hash field. As set via HEXPIRE,
+// HPEXPIRE, HEXPIREAT, HPEXPIREAT.
+// 0 if not set.
+func (m *Miniredis) HFieldTTL(k, f string) time.Duration {
+	return m.DB(m.selectedDB).HFieldTTL(k, f)
+}
+
+// HFieldTTL is the left over time to live of a hash field.
+// 0 if not set.
+func (db *RedisDB) HFieldTTL(k, f string) time.Duration {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	ttl, _ := db.hashFieldTTL(k, f)
+	return ttl
+}
+
+// HSetWithTTL sets a hash field with a time to live.
+// If there is another key by the same name it will be gone.
+func (m *Miniredis) HSetWithTTL(k, f, v string, ttl time.Duration) {
+	m.DB(m.selectedDB).HSetWithTTL(k, f, v, ttl)
+}
+
+// HSetWithTTL sets a hash field with a time to live.
+// If there is another key by the same name it will be gone.
+func (db *RedisDB) HSetWithTTL(k, f, v string, ttl time.Duration) {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	db.hashSet(k, f, v)
+	db.setHashFieldTTL(k, f, ttl)
+}
+
+// HSetTTL sets the time to live of a hash field. The field must exist.
+func (m *Miniredis) HSetTTL(k, f string, ttl time.Duration) {
+	m.DB(m.selectedDB).HSetTTL(k, f, ttl)
+}
+
+// HSetTTL sets the time to live of a hash field. The field must exist.
+func (db *RedisDB) HSetTTL(k, f string, ttl time.Duration) {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	if _, ok := db.hashKeys[k][f]; !ok {
+		return
+	}
+	db.setHashFieldTTL(k, f, ttl)
 }
 
 // HIncrBy increases the integer value of a hash field by delta (int).
@@ -520,7 +557,6 @@ func (m *Miniredis) HIncr(k, f string, delta int) (int, error) {
 func (db *RedisDB) HIncr(k, f string, delta int) (int, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	return db.hashIncr(k, f, delta)
 }
@@ -539,7 +575,6 @@ func (m *Miniredis) HIncrfloat(k, f string, delta float64) (float64, error) {
 func (db *RedisDB) HIncrfloat(k, f string, delta float64) (float64, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	v, err := db.hashIncrfloat(k, f, big.NewFloat(delta))
 	if err != nil {
@@ -558,7 +593,6 @@ func (m *Miniredis) SRem(k string, fields ...string) (int, error) {
 func (db *RedisDB) SRem(k string, fields ...string) (int, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if !db.exists(k) {
 		return 0, ErrKeyNotFound
@@ -578,7 +612,6 @@ func (m *Miniredis) ZAdd(k string, score float64, member string) (bool, error) {
 func (db *RedisDB) ZAdd(k string, score float64, member string) (bool, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if db.exists(k) && db.t(k) != "zset" {
 		return false, ErrWrongType
@@ -633,7 +666,6 @@ func (m *Miniredis) ZRem(k, member string) (bool, error) {
 func (db *RedisDB) ZRem(k, member string) (bool, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
 	if !db.exists(k) {
 		return false, ErrKeyNotFound
@@ -676,8 +708,10 @@ func (m *Miniredis) XAdd(k string, id string, values []string) (string, error) {
 func (db *RedisDB) XAdd(k string, id string, values []string) (string, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
-	defer db.master.signal.Broadcast()
 
+	if len(values) == 0 || len(values)%2 != 0 {
+		return "", errors.New("ERR wrong number of arguments for XADD")
+	}
 	s, err := db.stream(k)
 	if err != nil {
 		return "", err
@@ -686,7 +720,43 @@ func (db *RedisDB) XAdd(k string, id string, values []string) (string, error) {
 		s, _ = db.newStream(k)
 	}
 
-	return s.add(id, values, db.master.effectiveNow())
+	newID, err := s.add(id, values, db.master.effectiveNow())
+	if err != nil {
+		return "", err
+	}
+	db.markDirty(k)
+	db.master.keyReady(db.id, k)
+	return newID, nil
+}
+
+// SetStreamLastID changes the last generated ID of a stream, the same as
+// XSETID. New entries need to have a higher ID. The stream is created if it
+// doesn't exist.
+func (m *Miniredis) SetStreamLastID(key, id string) error {
+	return m.DB(m.selectedDB).SetStreamLastID(key, id)
+}
+
+// SetStreamLastID changes the last generated ID of a stream. See
+// Miniredis.SetStreamLastID().
+func (db *RedisDB) SetStreamLastID(key, id string) error {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	if _, err := formatStreamID(id); err != nil {
+		return errors.New(msgInvalidStreamID)
+	}
+	s, err := db.stream(key)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		s, _ = db.newStream(key)
+	}
+	if err := s.setLastID(id); err != nil {
+		return err
+	}
+	db.markDirty(key)
+	return nil
 }
 
 // Stream returns a slice of stream entries. Oldest first.
@@ -694,7 +764,7 @@ func (m *Miniredis) Stream(k string) ([]StreamEntry, error) {
 	return m.DB(m.selectedDB).Stream(k)
 }
 
-// Stream returns a slice of stream entries. Oldest first.
+// Stream returns a copy of the stream entries. Oldest first.
 func (db *RedisDB) Stream(key string) ([]StreamEntry, error) {
 	db.master.Lock()
 	defer db.master.Unlock()
@@ -706,7 +776,88 @@ func (db *RedisDB) Stream(key string) ([]StreamEntry, error) {
 	if s == nil {
 		return nil, nil
 	}
-	return s.entries, nil
+	entries := make([]StreamEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, StreamEntry{
+			ID:     e.ID,
+			Values: append([]string{}, e.Values...),
+		})
+	}
+	return entries, nil
+}
+
+// StreamGroup returns the state of a stream consumer group: the last delivered
+// ID, the consumers, and the pending entries list (PEL).
+func (m *Miniredis) StreamGroup(key, group string) (*StreamGroup, error) {
+	return m.DB(m.selectedDB).StreamGroup(key, group)
+}
+
+// StreamGroup returns the state of a stream consumer group: the last delivered
+// ID, the consumers, and the pending entries list (PEL).
+func (db *RedisDB) StreamGroup(key, group string) (*StreamGroup, error) {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	s, err := db.stream(key)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, ErrKeyNotFound
+	}
+	g, ok := s.groups[group]
+	if !ok {
+		return nil, errNoGroup(key, group)
+	}
+	return g.export(group), nil
+}
+
+// SetStreamPending puts IDs in the pending entries list (PEL) of a consumer
+// group, as if they were delivered to consumer deliveryCount times, the last
+// time at lastDelivery. IDs already in the PEL are overwritten. The consumer is
+// created if needed, and the last delivered ID of the group is moved up if
+// it's lower than any of the IDs.
+// The entries don't need to exist in the stream.
+func (m *Miniredis) SetStreamPending(key, group, consumer string, ids []string, lastDelivery time.Time, deliveryCount int) error {
+	return m.DB(m.selectedDB).SetStreamPending(key, group, consumer, ids, lastDelivery, deliveryCount)
+}
+
+// SetStreamPending puts IDs in the pending entries list (PEL) of a consumer
+// group. See Miniredis.SetStreamPending().
+func (db *RedisDB) SetStreamPending(key, group, consumer string, ids []string, lastDelivery time.Time, deliveryCount int) error {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	if deliveryCount < 1 {
+		return errors.New("delivery count should be positive")
+	}
+	s, err := db.stream(key)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return ErrKeyNotFound
+	}
+	g, ok := s.groups[group]
+	if !ok {
+		return errNoGroup(key, group)
+	}
+	for _, id := range ids {
+		if _, err := formatStreamID(id); err != nil {
+			return errors.New(msgInvalidStreamID)
+		}
+	}
+	for _, id := range ids {
+		id, _ = formatStreamID(id)
+		g.setPending(pendingEntry{
+			id:            id,
+			consumer:      consumer,
+			deliveryCount: deliveryCount,
+			lastDelivery:  lastDelivery,
+		})
+	}
+	db.markDirty(key)
+	return nil
 }
 
 // Publish a message to subscribers. Returns the number of receivers.
@@ -724,7 +875,7 @@ func (m *Miniredis) PubSubChannels(pattern string) []string {
 	m.Lock()
 	defer m.Unlock()
 
-	return activeChannels(m.allSubscribers(), pattern)
+	return activeChannels(m.allSubscribers(), pattern, false)
 }
 
 // PubSubNumSub is "PUBSUB NUMSUB [channels]". It returns all channels with their
@@ -748,3 +899,36 @@ func (m *Miniredis) PubSubNumPat() int {
 
 	return countPsubs(m.allSubscribers())
 }
+
+// Spublish publishes a message to shard channel subscribers. Returns the
+// number of receivers.
+func (m *Miniredis) Spublish(channel, message string) int {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.spublish(channel, message)
+}
+
+// PubSubShardChannels is "PUBSUB SHARDCHANNELS <pattern>". An empty pattern is
+// fine (meaning all shard channels).
+// Returned channels will be ordered alphabetically.
+func (m *Miniredis) PubSubShardChannels(pattern string) []string {
+	m.Lock()
+	defer m.Unlock()
+
+	return activeChannels(m.allSubscribers(), pattern, true)
+}
+
+// PubSubShardNumSub is "PUBSUB SHARDNUMSUB [channels]". It returns all shard
+// channels with their subscriber count.
+func (m *Miniredis) PubSubShardNumSub(channels ...string) map[string]int {
+	m.Lock()
+	defer m.Unlock()
+
+	subs := m.allSubscribers()
+	res := map[string]int{}
+	for _, channel := range channels {
+		res[channel] = countShardSubs(subs, channel)
+	}
+	return res
+}
@@ -0,0 +1,1319 @@
+package miniredis
+
+// DUMP and RESTORE payloads, in the RDB serialization format Redis uses.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// RDB version we write, which Redis 6.0 and up can read.
+	rdbVersion = 9
+	// Highest RDB version we accept, from Redis 8.
+	rdbMaxVersion = 12
+)
+
+// Object types in RDB payloads.
+const (
+	rdbTypeString           = 0
+	rdbTypeList             = 1
+	rdbTypeSet              = 2
+	rdbTypeZset             = 3
+	rdbTypeHash             = 4
+	rdbTypeZset2            = 5
+	rdbTypeListZiplist      = 10
+	rdbTypeSetIntset        = 11
+	rdbTypeZsetZiplist      = 12
+	rdbTypeHashZiplist      = 13
+	rdbTypeListQuicklist    = 14
+	rdbTypeStreamListpacks  = 15
+	rdbTypeHashListpack     = 16
+	rdbTypeZsetListpack     = 17
+	rdbTypeListQuicklist2   = 18
+	rdbTypeStreamListpacks2 = 19
+	rdbTypeSetListpack      = 20
+	rdbTypeStreamListpacks3 = 21
+	rdbTypeHashMetadata     = 24 // hash with field TTLs, Redis 7.4
+	rdbTypeHashListpackEx   = 25 // listpack hash with field TTLs, Redis 7.4
+
+	// FUNCTION DUMP library, followed by its code.
+	rdbOpcodeFunction2 = 245
+)
+
+// Special string encodings, used when the length has 0b11 as its top bits.
+const (
+	rdbEncInt8  = 0
+	rdbEncInt16 = 1
+	rdbEncInt32 = 2
+	rdbEncLZF   = 3
+)
+
+const (
+	quicklistNodePlain  = 1
+	quicklistNodePacked = 2
+
+	streamItemFlagDeleted    = 1
+	streamItemFlagSameFields = 2
+	streamNodeMaxEntries     = 100 // stream-node-max-entries
+
+	hashMaxFieldExpire = 1<<48 - 1 // in unix ms
+)
+
+var errBadDataFormat = errors.New(msgBadDataFormat)
+
+// Redis uses the "Jones" CRC64, without the inversions Go does.
+var rdbCRCTable = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+func rdbCRC(b []byte) uint64 {
+	return ^crc64.Update(^uint64(0), rdbCRCTable, b)
+}
+
+// dump serializes a key, the same as DUMP. The key must exist.
+// We use the simplest RDB type for every key, and never compress strings.
+func (db *RedisDB) dump(k string) []byte {
+	w := &rdbWriter{}
+	switch db.t(k) {
+	case "string":
+		w.buf.WriteByte(rdbTypeString)
+		w.string(db.stringKeys[k])
+	case "list":
+		w.buf.WriteByte(rdbTypeList)
+		l := db.listKeys[k].elems()
+		w.len(uint64(len(l)))
+		for _, e := range l {
+			w.string(e)
+		}
+	case "set":
+		w.buf.WriteByte(rdbTypeSet)
+		members := db.setMembers(k)
+		w.len(uint64(len(members)))
+		for _, e := range members {
+			w.string(e)
+		}
+	case "zset":
+		// Redis writes the highest score first.
+		w.buf.WriteByte(rdbTypeZset2)
+		ss := db.sortedsetKeys[k]
+		elems := ss.byScore(desc)
+		w.len(uint64(len(elems)))
+		for _, e := range elems {
+			w.string(e.member)
+			w.double(e.score)
+		}
+	case "hash":
+		fields := db.hashFields(k)
+		ttls := db.hashTTLs[k]
+		if len(ttls) == 0 {
+			w.buf.WriteByte(rdbTypeHash)
+			w.len(uint64(len(fields)))
+			for _, f := range fields {
+				w.string(f)
+				w.string(db.hashGet(k, f))
+			}
+			break
+		}
+		// Field TTLs are unix ms, written relative to the lowest one.
+		w.buf.WriteByte(rdbTypeHashMetadata)
+		now := db.master.effectiveNow().UnixNano() / int64(time.Millisecond)
+		min := int64(math.MaxInt64)
+		for _, ttl := range ttls {
+			if at := now + int64(ttl/time.Millisecond); at < min {
+				min = at
+			}
+		}
+		w.int64(min)
+		w.len(uint64(len(fields)))
+		for _, f := range fields {
+			var rel uint64 // 0 is no TTL
+			if ttl, ok := ttls[f]; ok {
+				rel = uint64(now+int64(ttl/time.Millisecond)-min) + 1
+			}
+			w.len(rel)
+			w.string(f)
+			w.string(db.hashGet(k, f))
+		}
+	case "stream":
+		w.buf.WriteByte(rdbTypeStreamListpacks)
+		w.stream(db.streamKeys[k])
+	default:
+		panic("missing case")
+	}
+	return w.payload()
+}
+
+// dumpLibraries serializes function libraries, the same as FUNCTION DUMP.
+func dumpLibraries(libs []*luaLibrary) []byte {
+	w := &rdbWriter{}
+	for _, lib := range libs {
+		w.buf.WriteByte(rdbOpcodeFunction2)
+		w.string(lib.code)
+	}
+	return w.payload()
+}
+
+// restore loads a DUMP payload. It returns a new db with the value stored
+// under the key "".
+func restore(m *Miniredis, payload string) (*RedisDB, error) {
+	body, err := payloadBody(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	db := newRedisDB(0, m)
+	r := &rdbReader{b: body}
+	if err := r.object(&db); err != nil {
+		return nil, err
+	}
+	if r.err != nil {
+		return nil, errBadDataFormat
+	}
+	return &db, nil
+}
+
+// restoreLibraries loads a FUNCTION DUMP payload. It returns the code of every
+// library.
+func restoreLibraries(payload string) ([]string, error) {
+	body, err := payloadBody(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	r := &rdbReader{b: body}
+	for len(r.b) > 0 {
+		if r.byte() != rdbOpcodeFunction2 {
+			return nil, errors.New(msgFunctionPayload)
+		}
+		codes = append(codes, r.string())
+	}
+	if r.err != nil {
+		return nil, errBadDataFormat
+	}
+	return codes, nil
+}
+
+// payloadBody checks the version and the checksum of a payload, and returns
+// the data without them.
+func payloadBody(payload string) ([]byte, error) {
+	if len(payload) < 10 {
+		return nil, errors.New(msgDumpPayload)
+	}
+	body, footer := payload[:len(payload)-8], payload[len(payload)-10:]
+	if binary.LittleEndian.Uint16([]byte(footer[:2])) > rdbMaxVersion {
+		return nil, errors.New(msgDumpPayload)
+	}
+	if binary.LittleEndian.Uint64([]byte(footer[2:])) != rdbCRC([]byte(body)) {
+		return nil, errors.New(msgDumpPayload)
+	}
+	return []byte(body[:len(body)-2]), nil
+}
+
+type rdbWriter struct {
+	buf bytes.Buffer
+}
+
+// payload adds the version and the checksum, and returns the payload.
+func (w *rdbWriter) payload() []byte {
+	var footer [10]byte
+	binary.LittleEndian.PutUint16(footer[:2], rdbVersion)
+	w.buf.Write(footer[:2])
+	binary.LittleEndian.PutUint64(footer[2:], rdbCRC(w.buf.Bytes()))
+	w.buf.Write(footer[2:])
+	return w.buf.Bytes()
+}
+
+func (w *rdbWriter) len(n uint64) {
+	switch {
+	case n < 1<<6:
+		w.buf.WriteByte(byte(n))
+	case n < 1<<14:
+		w.buf.WriteByte(0x40 | byte(n>>8))
+		w.buf.WriteByte(byte(n))
+	case n <= math.MaxUint32:
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(n))
+		w.buf.WriteByte(0x80)
+		w.buf.Write(b[:])
+	default:
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], n)
+		w.buf.WriteByte(0x81)
+		w.buf.Write(b[:])
+	}
+}
+
+// string writes a string, as an integer if it looks like a small one, the
+// same as Redis does.
+func (w *rdbWriter) string(s string) {
+	if len(s) <= 11 {
+		if n, err := strconv.ParseInt(s, 10, 32); err == nil && strconv.FormatInt(n, 10) == s {
+			w.int(n)
+			return
+		}
+	}
+	w.len(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *rdbWriter) int(n int64) {
+	switch {
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		w.buf.WriteByte(0xc0 | rdbEncInt8)
+		w.buf.WriteByte(byte(n))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		var b [2]byte
+		binary.LittleEndian.PutUint16(b[:], uint16(n))
+		w.buf.WriteByte(0xc0 | rdbEncInt16)
+		w.buf.Write(b[:])
+	default:
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(n))
+		w.buf.WriteByte(0xc0 | rdbEncInt32)
+		w.buf.Write(b[:])
+	}
+}
+
+func (w *rdbWriter) double(f float64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+	w.buf.Write(b[:])
+}
+
+func (w *rdbWriter) ms(t time.Time) {
+	w.int64(t.UnixNano() / int64(time.Millisecond))
+}
+
+// int64 writes a number as 8 bytes, the way millisecond times are written.
+func (w *rdbWriter) int64(n int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(n))
+	w.buf.Write(b[:])
+}
+
+// stream writes a stream in the rdbTypeStreamListpacks format.
+func (w *rdbWriter) stream(s *streamKey) {
+	var nodes [][]StreamEntry
+	for es := s.entries; len(es) > 0; {
+		n := streamNodeMaxEntries
+		if n > len(es) {
+			n = len(es)
+		}
+		nodes = append(nodes, es[:n])
+		es = es[n:]
+	}
+	w.len(uint64(len(nodes)))
+	for _, n := range nodes {
+		master, _ := parseStreamID(n[0].ID)
+		w.string(string(streamIDBytes(master)))
+		w.string(string(streamNode(master, n)))
+	}
+
+	w.len(uint64(len(s.entries)))
+	last, _ := parseStreamID(s.lastID())
+	w.len(last[0])
+	w.len(last[1])
+
+	var names []string
+	for name := range s.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w.len(uint64(len(names)))
+	for _, name := range names {
+		g := s.groups[name]
+		w.string(name)
+		id, _ := parseStreamID(g.lastID)
+		w.len(id[0])
+		w.len(id[1])
+
+		w.len(uint64(len(g.pending)))
+		for _, p := range g.pending {
+			id, _ := parseStreamID(p.id)
+			w.buf.Write(streamIDBytes(id))
+			w.ms(p.lastDelivery)
+			w.len(uint64(p.deliveryCount))
+		}
+
+		var consumers []string
+		for c := range g.consumers {
+			consumers = append(consumers, c)
+		}
+		sort.Strings(consumers)
+		w.len(uint64(len(consumers)))
+		for _, c := range consumers {
+			w.string(c)
+			w.ms(g.consumers[c].seenTime)
+			var ids [][]byte
+			for _, p := range g.pending {
+				if p.consumer == c {
+					id, _ := parseStreamID(p.id)
+					ids = append(ids, streamIDBytes(id))
+				}
+			}
+			w.len(uint64(len(ids)))
+			for _, id := range ids {
+				w.buf.Write(id)
+			}
+		}
+	}
+}
+
+// streamIDBytes is the 128 bit big endian form of a stream ID.
+func streamIDBytes(id [2]uint64) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b[:8], id[0])
+	binary.BigEndian.PutUint64(b[8:], id[1])
+	return b
+}
+
+// streamNode makes the listpack of a single stream node. The fields of the
+// first entry are used as the master fields.
+func streamNode(master [2]uint64, entries []StreamEntry) []byte {
+	var (
+		lp     = &listpack{}
+		fields []string
+	)
+	for i := 0; i < len(entries[0].Values); i += 2 {
+		fields = append(fields, entries[0].Values[i])
+	}
+	lp.int(int64(len(entries)))
+	lp.int(0) // deleted
+	lp.int(int64(len(fields)))
+	for _, f := range fields {
+		lp.string(f)
+	}
+	lp.int(0)
+
+	for _, e := range entries {
+		id, _ := parseStreamID(e.ID)
+		same := len(e.Values) == 2*len(fields)
+		for i := 0; same && i < len(fields); i++ {
+			same = e.Values[2*i] == fields[i]
+		}
+		n := int64(len(e.Values) / 2)
+		if same {
+			lp.int(streamItemFlagSameFields)
+		} else {
+			lp.int(0)
+		}
+		lp.int(int64(id[0] - master[0]))
+		lp.int(int64(id[1] - master[1]))
+		if same {
+			for i := 1; i < len(e.Values); i += 2 {
+				lp.string(e.Values[i])
+			}
+			lp.int(n + 3)
+		} else {
+			lp.int(n)
+			for _, v := range e.Values {
+				lp.string(v)
+			}
+			lp.int(n + 3 + n + 1)
+		}
+	}
+	return lp.bytes()
+}
+
+// listpack builds a Redis listpack.
+type listpack struct {
+	buf []byte
+	n   int
+}
+
+func (lp *listpack) int(v int64) {
+	var e []byte
+	switch {
+	case v >= 0 && v <= 127:
+		e = []byte{byte(v)}
+	case v >= -4096 && v <= 4095:
+		u := uint16(v) & 0x1fff
+		e = []byte{0xc0 | byte(u>>8), byte(u)}
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		e = []byte{0xf1, 0, 0}
+		binary.LittleEndian.PutUint16(e[1:], uint16(v))
+	case v >= -1<<23 && v < 1<<23:
+		u := uint32(v)
+		e = []byte{0xf2, byte(u), byte(u >> 8), byte(u >> 16)}
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		e = []byte{0xf3, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(e[1:], uint32(v))
+	default:
+		e = make([]byte, 9)
+		e[0] = 0xf4
+		binary.LittleEndian.PutUint64(e[1:], uint64(v))
+	}
+	lp.append(e)
+}
+
+func (lp *listpack) string(s string) {
+	var e []byte
+	switch l := len(s); {
+	case l < 64:
+		e = []byte{0x80 | byte(l)}
+	case l < 4096:
+		e = []byte{0xe0 | byte(l>>8), byte(l)}
+	default:
+		e = []byte{0xf0, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(e[1:], uint32(l))
+	}
+	lp.append(append(e, s...))
+}
+
+// append adds an encoded entry, and its "backlen".
+func (lp *listpack) append(e []byte) {
+	lp.buf = append(lp.buf, e...)
+	l := uint64(len(e))
+	switch size := backlenSize(l); size {
+	case 1:
+		lp.buf = append(lp.buf, byte(l))
+	default:
+		for i := size - 1; i >= 0; i-- {
+			b := byte(l>>(7*uint(i))) & 127
+			if i != size-1 {
+				b |= 128
+			}
+			lp.buf = append(lp.buf, b)
+		}
+	}
+	lp.n++
+}
+
+func (lp *listpack) bytes() []byte {
+	b := make([]byte, 6, 6+len(lp.buf)+1)
+	binary.LittleEndian.PutUint32(b, uint32(6+len(lp.buf)+1))
+	n := lp.n
+	if n > math.MaxUint16 {
+		n = math.MaxUint16
+	}
+	binary.LittleEndian.PutUint16(b[4:], uint16(n))
+	b = append(b, lp.buf...)
+	return append(b, 0xff)
+}
+
+// backlenSize is the number of bytes the "backlen" of a listpack entry of
+// length l takes.
+func backlenSize(l uint64) int {
+	switch {
+	case l <= 127:
+		return 1
+	case l < 16383:
+		return 2
+	case l < 2097151:
+		return 3
+	case l < 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// rdbReader reads a payload. After the first error all reads return zero
+// values, so only r.err needs to be checked at the end.
+type rdbReader struct {
+	b   []byte
+	err error
+}
+
+func (r *rdbReader) fail() {
+	if r.err == nil {
+		r.err = errBadDataFormat
+	}
+	r.b = nil
+}
+
+func (r *rdbReader) byte() byte {
+	if len(r.b) < 1 {
+		r.fail()
+		return 0
+	}
+	b := r.b[0]
+	r.b = r.b[1:]
+	return b
+}
+
+// bytes reads n bytes. Always returns n bytes, to keep the callers simple.
+func (r *rdbReader) bytes(n uint64) []byte {
+	if uint64(len(r.b)) < n {
+		r.fail()
+		if n > 16 {
+			return nil
+		}
+		return make([]byte, n)
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+// len reads a length. encoded is set for the special string encodings.
+func (r *rdbReader) len() (n uint64, encoded bool) {
+	b := r.byte()
+	switch b >> 6 {
+	case 0:
+		return uint64(b & 0x3f), false
+	case 1:
+		return uint64(b&0x3f)<<8 | uint64(r.byte()), false
+	case 2:
+		switch b {
+		case 0x80:
+			return uint64(binary.BigEndian.Uint32(r.bytes(4))), false
+		case 0x81:
+			return binary.BigEndian.Uint64(r.bytes(8)), false
+		}
+		r.fail()
+		return 0, false
+	default:
+		return uint64(b & 0x3f), true
+	}
+}
+
+// count reads a length which is used as a number of elements.
+func (r *rdbReader) count() int {
+	n, enc := r.len()
+	if enc || n > uint64(len(r.b)) {
+		// every element takes at least a byte
+		r.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (r *rdbReader) string() string {
+	n, enc := r.len()
+	if !enc {
+		return string(r.bytes(n))
+	}
+	switch n {
+	case rdbEncInt8:
+		return strconv.Itoa(int(int8(r.byte())))
+	case rdbEncInt16:
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(r.bytes(2)))))
+	case rdbEncInt32:
+		return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(r.bytes(4)))))
+	case rdbEncLZF:
+		clen, _ := r.len()
+		l, _ := r.len()
+		in := r.bytes(clen)
+		if r.err != nil {
+			return ""
+		}
+		out, err := lzfDecompress(in, l)
+		if err != nil {
+			r.fail()
+			return ""
+		}
+		return string(out)
+	default:
+		r.fail()
+		return ""
+	}
+}
+
+func (r *rdbReader) double() float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(r.bytes(8)))
+}
+
+// oldDouble is the string encoded double of rdbTypeZset.
+func (r *rdbReader) oldDouble() float64 {
+	switch n := r.byte(); n {
+	case 253:
+		return math.NaN()
+	case 254:
+		return math.Inf(1)
+	case 255:
+		return math.Inf(-1)
+	default:
+		f, err := strconv.ParseFloat(string(r.bytes(uint64(n))), 64)
+		if err != nil {
+			r.fail()
+		}
+		return f
+	}
+}
+
+func (r *rdbReader) ms() time.Time {
+	return time.Unix(0, r.int64()*int64(time.Millisecond))
+}
+
+// int64 reads an 8 byte number, the way millisecond times are written.
+func (r *rdbReader) int64() int64 {
+	return int64(binary.LittleEndian.Uint64(r.bytes(8)))
+}
+
+func (r *rdbReader) streamID() string {
+	b := r.bytes(16)
+	return fmt.Sprintf("%d-%d", binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:]))
+}
+
+// object reads a single value, and stores it in db, under the key "".
+func (r *rdbReader) object(db *RedisDB) error {
+	const k = ""
+	t := r.byte()
+	switch t {
+	case rdbTypeString:
+		db.stringKeys[k] = r.string()
+		db.keys[k] = "string"
+		return nil
+	case rdbTypeList:
+		var l []string
+		for n := r.count(); n > 0; n-- {
+			l = append(l, r.string())
+		}
+		return setList(db, l)
+	case rdbTypeListZiplist:
+		l, err := ziplistEntries(r.string())
+		if err != nil {
+			return err
+		}
+		return setList(db, l)
+	case rdbTypeListQuicklist, rdbTypeListQuicklist2:
+		var l []string
+		for n := r.count(); n > 0; n-- {
+			container := uint64(quicklistNodePacked)
+			if t == rdbTypeListQuicklist2 {
+				container, _ = r.len()
+			}
+			node := r.string()
+			switch {
+			case container == quicklistNodePlain:
+				l = append(l, node)
+			case t == rdbTypeListQuicklist:
+				es, err := ziplistEntries(node)
+				if err != nil {
+					return err
+				}
+				l = append(l, es...)
+			default:
+				es, err := listpackEntries(node)
+				if err != nil {
+					return err
+				}
+				l = append(l, es...)
+			}
+		}
+		return setList(db, l)
+	case rdbTypeSet:
+		var members []string
+		for n := r.count(); n > 0; n-- {
+			members = append(members, r.string())
+		}
+		return setSet(db, members)
+	case rdbTypeSetIntset:
+		members, err := intsetEntries(r.string())
+		if err != nil {
+			return err
+		}
+		return setSet(db, members)
+	case rdbTypeSetListpack:
+		members, err := listpackEntries(r.string())
+		if err != nil {
+			return err
+		}
+		return setSet(db, members)
+	case rdbTypeZset, rdbTypeZset2:
+		ss := sortedSet{}
+		for n := r.count(); n > 0; n-- {
+			member := r.string()
+			if t == rdbTypeZset {
+				ss[member] = r.oldDouble()
+			} else {
+				ss[member] = r.double()
+			}
+		}
+		return setZset(db, ss)
+	case rdbTypeZsetZiplist, rdbTypeZsetListpack:
+		es, err := ziplistOrListpack(t == rdbTypeZsetZiplist, r.string())
+		if err != nil {
+			return err
+		}
+		if len(es)%2 != 0 {
+			return errBadDataFormat
+		}
+		ss := sortedSet{}
+		for i := 0; i < len(es); i += 2 {
+			score, err := strconv.ParseFloat(es[i+1], 64)
+			if err != nil {
+				return errBadDataFormat
+			}
+			ss[es[i]] = score
+		}
+		return setZset(db, ss)
+	case rdbTypeHash:
+		h := hashKey{}
+		for n := r.count(); n > 0; n-- {
+			f := r.string()
+			h[f] = r.string()
+		}
+		return setHash(db, h)
+	case rdbTypeHashZiplist, rdbTypeHashListpack:
+		es, err := ziplistOrListpack(t == rdbTypeHashZiplist, r.string())
+		if err != nil {
+			return err
+		}
+		if len(es)%2 != 0 {
+			return errBadDataFormat
+		}
+		h := hashKey{}
+		for i := 0; i < len(es); i += 2 {
+			h[es[i]] = es[i+1]
+		}
+		return setHash(db, h)
+	case rdbTypeHashMetadata:
+		now := db.master.effectiveNow()
+		min := r.int64()
+		if min < 0 || min > hashMaxFieldExpire {
+			return errBadDataFormat
+		}
+		h, ttls := hashKey{}, hashTTL{}
+		for n := r.count(); n > 0; n-- {
+			rel, _ := r.len()
+			f, v := r.string(), r.string()
+			if rel == 0 {
+				h[f] = v
+				continue
+			}
+			if rel > hashMaxFieldExpire {
+				return errBadDataFormat
+			}
+			if ttl := fieldTTL(now, min+int64(rel)-1); ttl > 0 {
+				h[f], ttls[f] = v, ttl
+			}
+		}
+		return setHashTTLs(db, h, ttls)
+	case rdbTypeHashListpackEx:
+		now := db.master.effectiveNow()
+		r.int64() // the lowest TTL
+		es, err := listpackEntries(r.string())
+		if err != nil {
+			return err
+		}
+		if len(es)%3 != 0 {
+			return errBadDataFormat
+		}
+		// field, value, and a unix ms TTL, or 0
+		h, ttls := hashKey{}, hashTTL{}
+		for i := 0; i < len(es); i += 3 {
+			at, err := strconv.ParseInt(es[i+2], 10, 64)
+			if err != nil || at < 0 || at > hashMaxFieldExpire {
+				return errBadDataFormat
+			}
+			if at == 0 {
+				h[es[i]] = es[i+1]
+				continue
+			}
+			if ttl := fieldTTL(now, at); ttl > 0 {
+				h[es[i]], ttls[es[i]] = es[i+1], ttl
+			}
+		}
+		return setHashTTLs(db, h, ttls)
+	case rdbTypeStreamListpacks, rdbTypeStreamListpacks2, rdbTypeStreamListpacks3:
+		s, err := r.stream(t)
+		if err != nil {
+			return err
+		}
+		db.streamKeys[k] = s
+		db.keys[k] = "stream"
+		return nil
+	default:
+		return errBadDataFormat
+	}
+}
+
+func setList(db *RedisDB, l []string) error {
+	if len(l) == 0 {
+		return errBadDataFormat
+	}
+	db.listKeys[""] = newListKey(l...)
+	db.keys[""] = "list"
+	return nil
+}
+
+func setSet(db *RedisDB, members []string) error {
+	if len(members) == 0 {
+		return errBadDataFormat
+	}
+	s := setKey{}
+	for _, m := range members {
+		s[m] = struct{}{}
+	}
+	db.setKeys[""] = s
+	db.keys[""] = "set"
+	return nil
+}
+
+func setZset(db *RedisDB, ss sortedSet) error {
+	if len(ss) == 0 {
+		return errBadDataFormat
+	}
+	db.sortedsetKeys[""] = ss
+	db.keys[""] = "zset"
+	return nil
+}
+
+func setHash(db *RedisDB, h hashKey) error {
+	if len(h) == 0 {
+		return errBadDataFormat
+	}
+	db.hashKeys[""] = h
+	db.keys[""] = "hash"
+	return nil
+}
+
+// setHashTTLs is setHash for a hash with field TTLs. Fields which already
+// expired are not in h. Same as Redis, a hash with only expired fields is
+// refused.
+func setHashTTLs(db *RedisDB, h hashKey, ttls hashTTL) error {
+	if err := setHash(db, h); err != nil {
+		return err
+	}
+	if len(ttls) > 0 {
+		db.hashTTLs[""] = ttls
+	}
+	return nil
+}
+
+// fieldTTL gives the TTL of a hash field which expires at the unix time at,
+// in ms.
+func fieldTTL(now time.Time, at int64) time.Duration {
+	ttl := at - now.UnixNano()/int64(time.Millisecond)
+	if max := int64(math.MaxInt64 / time.Millisecond); ttl > max {
+		ttl = max
+	}
+	return time.Duration(ttl) * time.Millisecond
+}
+
+// stream reads any of the three stream formats.
+func (r *rdbReader) stream(t byte) (*streamKey, error) {
+	s := newStreamKey()
+	for n := r.count(); n > 0; n-- {
+		master := []byte(r.string())
+		lp := r.string()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if len(master) != 16 {
+			return nil, errBadDataFormat
+		}
+		es, err := streamNodeEntries(
+			[2]uint64{binary.BigEndian.Uint64(master[:8]), binary.BigEndian.Uint64(master[8:])},
+			lp,
+		)
+		if err != nil {
+			return nil, err
+		}
+		s.entries = append(s.entries, es...)
+	}
+	r.len() // length
+	ms, _ := r.len()
+	seq, _ := r.len()
+	s.lastGenID = fmt.Sprintf("%d-%d", ms, seq)
+	s.entriesAdded = len(s.entries)
+	if t >= rdbTypeStreamListpacks2 {
+		r.len() // first ID
+		r.len()
+		ms, _ := r.len()
+		seq, _ := r.len()
+		s.maxDeletedID = fmt.Sprintf("%d-%d", ms, seq)
+		added, _ := r.len()
+		s.entriesAdded = int(added)
+	}
+
+	for n := r.count(); n > 0; n-- {
+		name := r.string()
+		ms, _ := r.len()
+		seq, _ := r.len()
+		g := &streamGroup{
+			stream:      s,
+			lastID:      fmt.Sprintf("%d-%d", ms, seq),
+			entriesRead: -1,
+			consumers:   map[string]consumer{},
+		}
+		if t >= rdbTypeStreamListpacks2 {
+			read, _ := r.len()
+			g.entriesRead = int(int64(read))
+		}
+
+		for n := r.count(); n > 0; n-- {
+			id := r.streamID()
+			delivery := r.ms()
+			count, _ := r.len()
+			g.pending = append(g.pending, pendingEntry{
+				id:            id,
+				deliveryCount: int(count),
+				lastDelivery:  delivery,
+			})
+		}
+		sort.Slice(g.pending, func(i, j int) bool {
+			return streamCmp(g.pending[i].id, g.pending[j].id) < 0
+		})
+
+		for n := r.count(); n > 0; n-- {
+			cname := r.string()
+			con := consumer{seenTime: r.ms()}
+			if t >= rdbTypeStreamListpacks3 {
+				if active := int64(binary.LittleEndian.Uint64(r.bytes(8))); active != -1 {
+					con.activeTime = time.Unix(0, active*int64(time.Millisecond))
+				}
+			}
+			g.consumers[cname] = con
+			for n := r.count(); n > 0; n-- {
+				id := r.streamID()
+				pos := sort.Search(len(g.pending), func(i int) bool {
+					return streamCmp(g.pending[i].id, id) >= 0
+				})
+				if pos == len(g.pending) || g.pending[pos].id != id {
+					return nil, errBadDataFormat
+				}
+				g.pending[pos].consumer = cname
+			}
+		}
+		for _, p := range g.pending {
+			if p.consumer == "" {
+				return nil, errBadDataFormat
+			}
+		}
+		s.groups[name] = g
+	}
+	return s, r.err
+}
+
+// streamNodeEntries decodes the listpack of a single stream node.
+func streamNodeEntries(master [2]uint64, lp string) ([]StreamEntry, error) {
+	es, err := listpackEntries(lp)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		pos  = 0
+		next = func() string {
+			if pos >= len(es) {
+				err = errBadDataFormat
+				return "0"
+			}
+			pos++
+			return es[pos-1]
+		}
+		nextInt = func() int64 {
+			n, e := strconv.ParseInt(next(), 10, 64)
+			if e != nil {
+				err = errBadDataFormat
+			}
+			return n
+		}
+	)
+	nextInt() // count
+	nextInt() // deleted
+	// every count needs at least that many entries left
+	nextCount := func() int {
+		n := nextInt()
+		if n < 0 || n > int64(len(es)-pos) {
+			err = errBadDataFormat
+			return 0
+		}
+		return int(n)
+	}
+	fields := make([]string, nextCount())
+	for i := range fields {
+		fields[i] = next()
+	}
+	nextInt() // terminator
+
+	var entries []StreamEntry
+	for err == nil && pos < len(es) {
+		flags := nextInt()
+		e := StreamEntry{
+			ID: fmt.Sprintf("%d-%d",
+				master[0]+uint64(nextInt()),
+				master[1]+uint64(nextInt()),
+			),
+		}
+		if flags&streamItemFlagSameFields != 0 {
+			for _, f := range fields {
+				e.Values = append(e.Values, f, next())
+			}
+		} else {
+			for n := nextCount(); n > 0; n-- {
+				e.Values = append(e.Values, next(), next())
+			}
+		}
+		nextInt() // lp-count
+		if flags&streamItemFlagDeleted == 0 {
+			entries = append(entries, e)
+		}
+	}
+	return entries, err
+}
+
+func ziplistOrListpack(ziplist bool, s string) ([]string, error) {
+	if ziplist {
+		return ziplistEntries(s)
+	}
+	return listpackEntries(s)
+}
+
+// listpackEntries decodes a listpack. Integers are returned as strings.
+func listpackEntries(s string) ([]string, error) {
+	b := []byte(s)
+	if len(b) < 7 || int(binary.LittleEndian.Uint32(b)) != len(b) {
+		return nil, errBadDataFormat
+	}
+	b = b[6:]
+	var res []string
+	for {
+		if len(b) == 0 {
+			return nil, errBadDataFormat
+		}
+		if b[0] == 0xff {
+			return res, nil
+		}
+		var (
+			v    string
+			size int // encoding + data
+			need = func(n int) bool { return len(b) >= n }
+		)
+		switch e := b[0]; {
+		case e&0x80 == 0:
+			v, size = strconv.Itoa(int(e)), 1
+		case e&0xc0 == 0x80:
+			l := int(e & 0x3f)
+			if !need(1 + l) {
+				return nil, errBadDataFormat
+			}
+			v, size = string(b[1:1+l]), 1+l
+		case e&0xe0 == 0xc0:
+			if !need(2) {
+				return nil, errBadDataFormat
+			}
+			u := int(e&0x1f)<<8 | int(b[1])
+			if u >= 1<<12 {
+				u -= 1 << 13
+			}
+			v, size = strconv.Itoa(u), 2
+		case e&0xf0 == 0xe0:
+			if !need(2) {
+				return nil, errBadDataFormat
+			}
+			l := int(e&0x0f)<<8 | int(b[1])
+			if !need(2 + l) {
+				return nil, errBadDataFormat
+			}
+			v, size = string(b[2:2+l]), 2+l
+		case e == 0xf0:
+			if !need(5) {
+				return nil, errBadDataFormat
+			}
+			l := int(binary.LittleEndian.Uint32(b[1:]))
+			if l < 0 || !need(5+l) {
+				return nil, errBadDataFormat
+			}
+			v, size = string(b[5:5+l]), 5+l
+		case e == 0xf1:
+			if !need(3) {
+				return nil, errBadDataFormat
+			}
+			v, size = strconv.Itoa(int(int16(binary.LittleEndian.Uint16(b[1:])))), 3
+		case e == 0xf2:
+			if !need(4) {
+				return nil, errBadDataFormat
+			}
+			u := int32(uint32(b[1]) | uint32(b[2])<<8 | uint32(b[3])<<16)
+			v, size = strconv.Itoa(int(u<<8>>8)), 4
+		case e == 0xf3:
+			if !need(5) {
+				return nil, errBadDataFormat
+			}
+			v, size = strconv.Itoa(int(int32(binary.LittleEndian.Uint32(b[1:])))), 5
+		case e == 0xf4:
+			if !need(9) {
+				return nil, errBadDataFormat
+			}
+			v, size = strconv.FormatInt(int64(binary.LittleEndian.Uint64(b[1:])), 10), 9
+		default:
+			return nil, errBadDataFormat
+		}
+		size += backlenSize(uint64(size))
+		if !need(size) {
+			return nil, errBadDataFormat
+		}
+		res = append(res, v)
+		b = b[size:]
+	}
+}
+
+// ziplistEntries decodes a ziplist, the format before listpacks.
+func ziplistEntries(s string) ([]string, error) {
+	b := []byte(s)
+	if len(b) < 11 || int(binary.LittleEndian.Uint32(b)) != len(b) {
+		return nil, errBadDataFormat
+	}
+	b = b[10:]
+	var res []string
+	for {
+		if len(b) == 0 {
+			return nil, errBadDataFormat
+		}
+		if b[0] == 0xff {
+			return res, nil
+		}
+		// previous entry length
+		if b[0] == 0xfe {
+			if len(b) < 5 {
+				return nil, errBadDataFormat
+			}
+			b = b[5:]
+		} else {
+			b = b[1:]
+		}
+		if len(b) == 0 {
+			return nil, errBadDataFormat
+		}
+
+		var (
+			v    string
+			size int // encoding + data
+			need = func(n int) bool { return len(b) >= n }
+		)
+		switch e := b[0]; {
+		case e>>6 == 0:
+			l := int(e & 0x3f)
+			if !need(1 + l) {
+				return nil, errBadDataFormat
+			}
+			v, size = string(b[1:1+l]), 1+l
+		case e>>6 == 1:
+			if !need(2) {
+				return nil, errBadDataFormat
+			}
+			l := int(e&0x3f)<<8 | int(b[1])
+			if !need(2 + l) {
+				return nil, errBadDataFormat
+			}
+			v, size = string(b[2:2+l]), 2+l
+		case e == 0x80:
+			if !need(5) {
+				return nil, errBadDataFormat
+			}
+			l := int(binary.BigEndian.Uint32(b[1:]))
+			if l < 0 || !need(5+l) {
+				return nil, errBadDataFormat
+			}
+			v, size = string(b[5:5+l]), 5+l
+		case e == 0xc0:
+			if !need(3) {
+				return nil, errBadDataFormat
+			}
+			v, size = strconv.Itoa(int(int16(binary.LittleEndian.Uint16(b[1:])))), 3
+		case e == 0xd0:
+			if !need(5) {
+				return nil, errBadDataFormat
+			}
+			v, size = strconv.Itoa(int(int32(binary.LittleEndian.Uint32(b[1:])))), 5
+		case e == 0xe0:
+			if !need(9) {
+				return nil, errBadDataFormat
+			}
+			v, size = strconv.FormatInt(int64(binary.LittleEndian.Uint64(b[1:])), 10), 9
+		case e == 0xf0:
+			if !need(4) {
+				return nil, errBadDataFormat
+			}
+			u := int32(uint32(b[1]) | uint32(b[2])<<8 | uint32(b[3])<<16)
+			v, size = strconv.Itoa(int(u<<8>>8)), 4
+		case e == 0xfe:
+			if !need(2) {
+				return nil, errBadDataFormat
+			}
+			v, size = strconv.Itoa(int(int8(b[1]))), 2
+		case e >= 0xf1 && e <= 0xfd:
+			v, size = strconv.Itoa(int(e&0x0f)-1), 1
+		default:
+			return nil, errBadDataFormat
+		}
+		res = append(res, v)
+		b = b[size:]
+	}
+}
+
+// intsetEntries decodes an intset.
+func intsetEntries(s string) ([]string, error) {
+	b := []byte(s)
+	if len(b) < 8 {
+		return nil, errBadDataFormat
+	}
+	enc := int(binary.LittleEndian.Uint32(b))
+	n := int(binary.LittleEndian.Uint32(b[4:]))
+	b = b[8:]
+	if (enc != 2 && enc != 4 && enc != 8) || n < 0 || len(b) != n*enc {
+		return nil, errBadDataFormat
+	}
+	res := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		var v int64
+		switch enc {
+		case 2:
+			v = int64(int16(binary.LittleEndian.Uint16(b[i*2:])))
+		case 4:
+			v = int64(int32(binary.LittleEndian.Uint32(b[i*4:])))
+		case 8:
+			v = int64(binary.LittleEndian.Uint64(b[i*8:]))
+		}
+		res = append(res, strconv.FormatInt(v, 10))
+	}
+	return res, nil
+}
+
+// lzfDecompress decompresses LZF data, as used for long strings in RDB
+// payloads.
+func lzfDecompress(in []byte, outLen uint64) ([]byte, error) {
+	if outLen > 512*1024*1024 {
+		return nil, errBadDataFormat
+	}
+	out := make([]byte, 0, outLen)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+		if ctrl < 1<<5 {
+			// literal run
+			n := ctrl + 1
+			if i+n > len(in) {
+				return nil, errBadDataFormat
+			}
+			out = append(out, in[i:i+n]...)
+			i += n
+			continue
+		}
+		// back reference
+		l := ctrl >> 5
+		if l == 7 {
+			if i >= len(in) {
+				return nil, errBadDataFormat
+			}
+			l += int(in[i])
+			i++
+		}
+		l += 2
+		if i >= len(in) {
+			return nil, errBadDataFormat
+		}
+		ref := len(out) - (ctrl&0x1f)<<8 - int(in[i]) - 1
+		i++
+		if ref < 0 {
+			return nil, errBadDataFormat
+		}
+		for j := 0; j < l; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+	if uint64(len(out)) != outLen {
+		return nil, errBadDataFormat
+	}
+	return out, nil
+}
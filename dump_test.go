@@ -0,0 +1,310 @@
+package miniredis
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rdbPayload adds the version and checksum to a serialized value.
+func rdbPayload(body string, version uint16) string {
+	b := []byte(body)
+	b = append(b, byte(version), byte(version>>8))
+	var crc [8]byte
+	binary.LittleEndian.PutUint64(crc[:], rdbCRC(b))
+	return string(append(b, crc[:]...))
+}
+
+func TestRDBCRC(t *testing.T) {
+	equals(t, uint64(0xe9c6d914c4b8d9ca), rdbCRC([]byte("123456789")))
+}
+
+func TestDumpRoundtrip(t *testing.T) {
+	s := NewMiniRedis()
+	db := s.db(0)
+
+	test := func(key string) {
+		t.Helper()
+		res, err := restore(s, string(db.dump(key)))
+		ok(t, err)
+		equals(t, db.keys[key], res.keys[""])
+		switch db.keys[key] {
+		case "string":
+			equals(t, db.stringKeys[key], res.stringKeys[""])
+		case "list":
+			equals(t, db.listKeys[key].elems(), res.listKeys[""].elems())
+		case "set":
+			equals(t, db.setKeys[key], res.setKeys[""])
+		case "zset":
+			equals(t, db.sortedsetKeys[key], res.sortedsetKeys[""])
+		case "hash":
+			equals(t, db.hashKeys[key], res.hashKeys[""])
+		case "stream":
+			equals(t, db.streamKeys[key].entries, res.streamKeys[""].entries)
+			equals(t, db.streamKeys[key].lastID(), res.streamKeys[""].lastID())
+		}
+	}
+
+	db.stringSet("str", "hello world")
+	test("str")
+	db.stringSet("int", "-12345")
+	test("int")
+	db.stringSet("bigint", "123456789012")
+	test("bigint")
+	db.stringSet("zeros", "007")
+	test("zeros")
+	db.stringSet("long", strings.Repeat("x", 20000))
+	test("long")
+
+	db.listPush("list", "aap", "1", "-300", "70000", strings.Repeat("y", 100))
+	test("list")
+
+	db.setAdd("set", "aap", "noot", "12")
+	test("set")
+
+	db.ssetAdd("zset", 1.5, "one")
+	db.ssetAdd("zset", -3, "two")
+	db.ssetAdd("zset", 1e100, "three")
+	test("zset")
+
+	db.hashSet("hash", "aap", "noot", "mies", "1")
+	test("hash")
+
+	db.stringSet("empty", "")
+	test("empty")
+}
+
+func TestDumpHashTTLs(t *testing.T) {
+	s := NewMiniRedis()
+	now := time.Unix(1700000000, 0)
+	s.SetTime(now)
+	db := s.db(0)
+
+	db.hashSet("hash", "aap", "1", "noot", "2", "mies", "3")
+	db.setHashFieldTTL("hash", "aap", 10*time.Second)
+	db.setHashFieldTTL("hash", "noot", time.Hour)
+	payload := string(db.dump("hash"))
+
+	res, err := restore(s, payload)
+	ok(t, err)
+	equals(t, db.hashKeys["hash"], res.hashKeys[""])
+	equals(t, db.hashTTLs["hash"], res.hashTTLs[""])
+
+	t.Run("expired fields", func(t *testing.T) {
+		s.SetTime(now.Add(20 * time.Second))
+		defer s.SetTime(now)
+		res, err := restore(s, payload)
+		ok(t, err)
+		equals(t, hashKey{"noot": "2", "mies": "3"}, res.hashKeys[""])
+		equals(t, hashTTL{"noot": time.Hour - 20*time.Second}, res.hashTTLs[""])
+
+		// nothing left
+		s.SetTime(now)
+		db.hashSet("short", "aap", "1")
+		db.setHashFieldTTL("short", "aap", time.Second)
+		short := string(db.dump("short"))
+		s.SetTime(now.Add(2 * time.Second))
+		_, err = restore(s, short)
+		mustFail(t, err, msgBadDataFormat)
+	})
+
+	t.Run("listpack", func(t *testing.T) {
+		// what Redis writes for small hashes
+		at := now.Add(time.Minute).UnixNano() / int64(time.Millisecond)
+		lp := &listpack{}
+		lp.string("aap")
+		lp.string("1")
+		lp.int(at)
+		lp.string("noot")
+		lp.string("2")
+		lp.int(0)
+		w := &rdbWriter{}
+		w.buf.WriteByte(rdbTypeHashListpackEx)
+		w.int64(at)
+		w.string(string(lp.bytes()))
+
+		res, err := restore(s, string(w.payload()))
+		ok(t, err)
+		equals(t, hashKey{"aap": "1", "noot": "2"}, res.hashKeys[""])
+		equals(t, hashTTL{"aap": time.Minute}, res.hashTTLs[""])
+	})
+}
+
+func TestDumpStreamKey(t *testing.T) {
+	s := NewMiniRedis()
+	db := s.db(0)
+	now := time.Unix(1600000000, 0)
+
+	st, err := db.newStream("planets")
+	ok(t, err)
+	for i := 0; i < 250; i++ {
+		_, err := st.add("*", []string{"name", "earth", "moons", "1"}, now)
+		ok(t, err)
+	}
+	_, err = st.add("*", []string{"other", "fields"}, now)
+	ok(t, err)
+	ok(t, st.createGroup("g", "0"))
+	st.groups["g"].readGroup(now, "alice", ">", 2, false)
+
+	res, err := restore(s, string(db.dump("planets")))
+	ok(t, err)
+	rst := res.streamKeys[""]
+	equals(t, st.entries, rst.entries)
+	equals(t, st.lastID(), rst.lastID())
+	equals(t, 1, len(rst.groups))
+	g := rst.groups["g"]
+	equals(t, st.groups["g"].lastID, g.lastID)
+	equals(t, 2, len(g.pending))
+	equals(t, "alice", g.pending[0].consumer)
+	equals(t, 1, g.pending[0].deliveryCount)
+	equals(t, now, g.pending[0].lastDelivery)
+	equals(t, now, g.consumers["alice"].seenTime)
+}
+
+func TestListpack(t *testing.T) {
+	lp := &listpack{}
+	ints := []int64{0, 127, 128, -1, 4095, -4096, 4096, -32768, 32767, 1 << 20, -1 << 23, 1 << 30, 1 << 40, -1 << 62}
+	for _, i := range ints {
+		lp.int(i)
+	}
+	lp.string("")
+	lp.string(strings.Repeat("a", 63))
+	lp.string(strings.Repeat("b", 64))
+	lp.string(strings.Repeat("c", 5000))
+
+	es, err := listpackEntries(string(lp.bytes()))
+	ok(t, err)
+	equals(t, []string{
+		"0", "127", "128", "-1", "4095", "-4096", "4096", "-32768", "32767",
+		"1048576", "-8388608", "1073741824", "1099511627776", "-4611686018427387904",
+		"", strings.Repeat("a", 63), strings.Repeat("b", 64), strings.Repeat("c", 5000),
+	}, es)
+
+	_, err = listpackEntries("\x07\x00\x00\x00\x01\x00\x01")
+	mustFail(t, err, msgBadDataFormat)
+	_, err = listpackEntries("foo")
+	mustFail(t, err, msgBadDataFormat)
+}
+
+func TestZiplist(t *testing.T) {
+	// ziplist with: "1", "2", "3"
+	es, err := ziplistEntries("\x17\x00\x00\x00\x12\x00\x00\x00\x03\x00\x00\xc0\x01\x00\x04\xc0\x02\x00\x04\xc0\x03\x00\xff")
+	ok(t, err)
+	equals(t, []string{"1", "2", "3"}, es)
+
+	// ziplist with: "aa", 12 (immediate), -2 (int8)
+	es, err = ziplistEntries("\x14\x00\x00\x00\x0f\x00\x00\x00\x03\x00\x00\x02aa\x04\xfd\x02\xfe\xfe\xff")
+	ok(t, err)
+	equals(t, []string{"aa", "12", "-2"}, es)
+
+	_, err = ziplistEntries("\x0b\x00\x00\x00\x0a\x00\x00\x00\x00\x00\x00")
+	mustFail(t, err, msgBadDataFormat)
+	// a 5 byte previous entry length, truncated
+	_, err = ziplistEntries("\x0c\x00\x00\x00\x0a\x00\x00\x00\x01\x00\xfe\x01")
+	mustFail(t, err, msgBadDataFormat)
+}
+
+func TestStreamNode(t *testing.T) {
+	// count, deleted, field count, fields, terminator
+	node := func(fields ...int64) string {
+		lp := &listpack{}
+		lp.int(1)
+		lp.int(0)
+		for _, f := range fields {
+			lp.int(f)
+		}
+		lp.int(0)
+		return string(lp.bytes())
+	}
+	_, err := streamNodeEntries([2]uint64{1, 0}, node(-1))
+	mustFail(t, err, msgBadDataFormat)
+	_, err = streamNodeEntries([2]uint64{1, 0}, node(1<<40, 5))
+	mustFail(t, err, msgBadDataFormat)
+
+	es, err := streamNodeEntries([2]uint64{1, 0}, node(0))
+	ok(t, err)
+	equals(t, 0, len(es))
+}
+
+func TestIntset(t *testing.T) {
+	es, err := intsetEntries("\x02\x00\x00\x00\x02\x00\x00\x00\xff\xff\x05\x00")
+	ok(t, err)
+	equals(t, []string{"-1", "5"}, es)
+
+	_, err = intsetEntries("\x02\x00\x00\x00\x03\x00\x00\x00\xff\xff\x05\x00")
+	mustFail(t, err, msgBadDataFormat)
+}
+
+func TestLZF(t *testing.T) {
+	// a literal "a", and a back reference of 20 bytes.
+	out, err := lzfDecompress([]byte("\x00a\xe0\x0b\x00"), 21)
+	ok(t, err)
+	equals(t, strings.Repeat("a", 21), string(out))
+
+	_, err = lzfDecompress([]byte("\x00a\xe0\x0b\x05"), 21)
+	mustFail(t, err, msgBadDataFormat)
+	_, err = lzfDecompress([]byte("\x00a"), 2)
+	mustFail(t, err, msgBadDataFormat)
+}
+
+func TestRestoreEncodings(t *testing.T) {
+	s := NewMiniRedis()
+
+	t.Run("lzf string", func(t *testing.T) {
+		res, err := restore(s, rdbPayload("\x00\xc3\x05\x15\x00a\xe0\x0b\x00", 9))
+		ok(t, err)
+		equals(t, strings.Repeat("a", 21), res.stringKeys[""])
+	})
+
+	t.Run("intset", func(t *testing.T) {
+		res, err := restore(s, rdbPayload("\x0b\x0c\x02\x00\x00\x00\x02\x00\x00\x00\xff\xff\x05\x00", 9))
+		ok(t, err)
+		equals(t, setKey{"-1": {}, "5": {}}, res.setKeys[""])
+	})
+
+	t.Run("listpacks", func(t *testing.T) {
+		lp := &listpack{}
+		lp.string("one")
+		lp.int(1)
+		lp.string("two")
+		lp.string("2.5")
+		w := &rdbWriter{}
+		w.buf.WriteByte(rdbTypeZsetListpack)
+		w.string(string(lp.bytes()))
+		res, err := restore(s, rdbPayload(w.buf.String(), 11))
+		ok(t, err)
+		equals(t, sortedSet{"one": 1, "two": 2.5}, res.sortedsetKeys[""])
+
+		w = &rdbWriter{}
+		w.buf.WriteByte(rdbTypeHashListpack)
+		w.string(string(lp.bytes()))
+		res, err = restore(s, rdbPayload(w.buf.String(), 11))
+		ok(t, err)
+		equals(t, hashKey{"one": "1", "two": "2.5"}, res.hashKeys[""])
+
+		w = &rdbWriter{}
+		w.buf.WriteByte(rdbTypeListQuicklist2)
+		w.len(2)
+		w.len(quicklistNodePacked)
+		w.string(string(lp.bytes()))
+		w.len(quicklistNodePlain)
+		w.string("plain")
+		res, err = restore(s, rdbPayload(w.buf.String(), 11))
+		ok(t, err)
+		equals(t, []string{"one", "1", "two", "2.5", "plain"}, res.listKeys[""].elems())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := restore(s, rdbPayload("\x00\x03abc", 13))
+		mustFail(t, err, msgDumpPayload)
+		_, err = restore(s, rdbPayload("\x00\x03ab", 9))
+		mustFail(t, err, msgBadDataFormat)
+		_, err = restore(s, rdbPayload("\x01\x00", 9))
+		mustFail(t, err, msgBadDataFormat)
+		_, err = restore(s, rdbPayload("\x09\x00", 9))
+		mustFail(t, err, msgBadDataFormat)
+		_, err = restore(s, "short")
+		mustFail(t, err, msgDumpPayload)
+	})
+}
@@ -0,0 +1,306 @@
+package miniredis
+
+// Functions, as loaded with FUNCTION LOAD. A library is Lua code which
+// registers its functions with redis.register_function().
+//
+// Function code is run in a new Lua state for every FCALL, same as EVAL does.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+// luaLibrary is a library loaded with FUNCTION LOAD.
+type luaLibrary struct {
+	name      string
+	code      string // including the #! line
+	functions map[string]*luaFunction
+}
+
+// luaFunction is a function registered by a library.
+type luaFunction struct {
+	name        string
+	description string // optional
+	flags       []string
+}
+
+// valid redis.register_function() flags
+var functionFlags = map[string]bool{
+	"no-writes":             true,
+	"allow-oom":             true,
+	"allow-stale":           true,
+	"no-cluster":            true,
+	"allow-cross-slot-keys": true,
+}
+
+// readOnly is whether the function has the "no-writes" flag.
+func (f *luaFunction) readOnly() bool {
+	for _, fl := range f.flags {
+		if fl == "no-writes" {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedFunctions returns the functions ordered by name.
+func (lib *luaLibrary) sortedFunctions() []*luaFunction {
+	var fs []*luaFunction
+	for _, f := range lib.functions {
+		fs = append(fs, f)
+	}
+	sort.Slice(fs, func(i, j int) bool { return fs[i].name < fs[j].name })
+	return fs
+}
+
+// validFunctionName is whether a library or function name only has letters,
+// numbers, and underscores.
+func validFunctionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// libraryMetadata parses the "#!lua name=<name>" line. It returns the library
+// name and the code after the #! line.
+func libraryMetadata(code string) (string, string, error) {
+	if !strings.HasPrefix(code, "#!") {
+		return "", "", errors.New("ERR Missing library metadata")
+	}
+	line, body := code, ""
+	if i := strings.IndexByte(code, '\n'); i >= 0 {
+		line, body = code[:i], code[i+1:]
+	}
+	parts := strings.Fields(line[2:])
+	if len(parts) == 0 || !strings.EqualFold(parts[0], "lua") {
+		engine := ""
+		if len(parts) > 0 {
+			engine = parts[0]
+		}
+		return "", "", fmt.Errorf("ERR Engine '%s' not found", engine)
+	}
+	name := ""
+	for _, p := range parts[1:] {
+		if !strings.HasPrefix(p, "name=") {
+			return "", "", fmt.Errorf("ERR Invalid metadata value given: %s", p)
+		}
+		name = p[len("name="):]
+	}
+	if name == "" {
+		return "", "", errors.New("ERR Library name was not given")
+	}
+	if !validFunctionName(name) {
+		return "", "", errors.New("ERR Library names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	}
+	return name, body, nil
+}
+
+// parseLibrary runs the library code and returns the library with all the
+// functions it registers. Only redis.register_function() and redis.log() can be
+// used while loading. Conflicts with other libraries are not checked.
+func parseLibrary(code string) (*luaLibrary, error) {
+	name, body, err := libraryMetadata(code)
+	if err != nil {
+		return nil, err
+	}
+
+	lib := &luaLibrary{
+		name:      name,
+		code:      code,
+		functions: map[string]*luaFunction{},
+	}
+	l := newLuaState(map[string]lua.LGFunction{
+		"register_function": registerFunction(lib, nil),
+		"log":               luaLog,
+	}, nil)
+	defer l.Close()
+
+	fn, err := l.LoadString(body)
+	if err != nil {
+		return nil, fmt.Errorf("ERR Error compiling function: %s", err)
+	}
+	l.Push(fn)
+	if err := l.PCall(0, 0, nil); err != nil {
+		return nil, fmt.Errorf("ERR Error registering functions: %s", err)
+	}
+	if len(lib.functions) == 0 {
+		return nil, errors.New("ERR No functions registered")
+	}
+	return lib, nil
+}
+
+// registerFunction is redis.register_function(). It adds the functions to lib,
+// and the callbacks to callbacks, if that's not nil.
+func registerFunction(lib *luaLibrary, callbacks map[string]*lua.LFunction) lua.LGFunction {
+	return func(l *lua.LState) int {
+		var (
+			name, callback, description, flags lua.LValue
+		)
+		switch l.GetTop() {
+		case 1:
+			args, ok := l.Get(1).(*lua.LTable)
+			if !ok {
+				l.RaiseError("calling redis.register_function with a single argument is only applicable to Lua table (representing named arguments).")
+				return 0
+			}
+			var unknown bool
+			args.ForEach(func(k, v lua.LValue) {
+				switch lua.LVAsString(k) {
+				case "function_name":
+					name = v
+				case "callback":
+					callback = v
+				case "description":
+					description = v
+				case "flags":
+					flags = v
+				default:
+					unknown = true
+				}
+			})
+			if unknown {
+				l.RaiseError("unknown argument given to redis.register_function")
+				return 0
+			}
+		case 2:
+			name, callback = l.Get(1), l.Get(2)
+		default:
+			l.RaiseError("wrong number of arguments to redis.register_function")
+			return 0
+		}
+
+		f := &luaFunction{}
+		if n, ok := name.(lua.LString); ok {
+			f.name = string(n)
+		} else {
+			l.RaiseError("function_name argument given to redis.register_function must be a string")
+			return 0
+		}
+		cb, ok := callback.(*lua.LFunction)
+		if !ok {
+			l.RaiseError("callback argument given to redis.register_function must be a function")
+			return 0
+		}
+		if description != nil {
+			d, ok := description.(lua.LString)
+			if !ok {
+				l.RaiseError("description argument given to redis.register_function must be a string")
+				return 0
+			}
+			f.description = string(d)
+		}
+		if flags != nil {
+			fl, ok := flags.(*lua.LTable)
+			if !ok {
+				l.RaiseError("flags argument to redis.register_function must be a table representing function flags")
+				return 0
+			}
+			for i := 1; i <= fl.Len(); i++ {
+				v := lua.LVAsString(fl.RawGetInt(i))
+				if !functionFlags[v] {
+					l.RaiseError("unknown flag given")
+					return 0
+				}
+				f.flags = append(f.flags, v)
+			}
+		}
+		if !validFunctionName(f.name) {
+			l.RaiseError("Function names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+			return 0
+		}
+		if _, ok := lib.functions[f.name]; ok {
+			l.RaiseError("Function already exists in the library")
+			return 0
+		}
+		lib.functions[f.name] = f
+		if callbacks != nil {
+			callbacks[f.name] = cb
+		}
+		return 0
+	}
+}
+
+func luaLog(l *lua.LState) int {
+	// there is no log
+	return 0
+}
+
+// addLibrary adds a library, unless it has a function another library already
+// has. With replace an existing library with the same name is replaced.
+func addLibrary(libs map[string]*luaLibrary, lib *luaLibrary, replace bool) error {
+	if _, ok := libs[lib.name]; ok && !replace {
+		return fmt.Errorf("ERR Library '%s' already exists", lib.name)
+	}
+	for name, other := range libs {
+		if name == lib.name {
+			continue
+		}
+		for _, f := range lib.sortedFunctions() {
+			if _, ok := other.functions[f.name]; ok {
+				return fmt.Errorf("ERR Function %s already exists", f.name)
+			}
+		}
+	}
+	libs[lib.name] = lib
+	return nil
+}
+
+// findFunction finds a function in any library. No locks!
+func (m *Miniredis) findFunction(name string) (*luaLibrary, *luaFunction) {
+	for _, lib := range m.libraries {
+		if f, ok := lib.functions[name]; ok {
+			return lib, f
+		}
+	}
+	return nil, nil
+}
+
+// sortedLibraries returns all libraries ordered by name. No locks!
+func (m *Miniredis) sortedLibraries() []*luaLibrary {
+	var libs []*luaLibrary
+	for _, lib := range m.libraries {
+		libs = append(libs, lib)
+	}
+	sort.Slice(libs, func(i, j int) bool { return libs[i].name < libs[j].name })
+	return libs
+}
+
+// runFunction runs a function with FCALL. Needs to run m.Lock()ed, from within
+// withTx().
+func (m *Miniredis) runFunction(c *server.Peer, lib *luaLibrary, f *luaFunction, keys, argv []string) {
+	callbacks := map[string]*lua.LFunction{}
+	funcs := mkLuaFuncs(m.srv, c, f.readOnly())
+	funcs["register_function"] = registerFunction(&luaLibrary{functions: map[string]*luaFunction{}}, callbacks)
+	l := newLuaState(funcs, nil)
+	defer l.Close()
+
+	_, body, _ := libraryMetadata(lib.code)
+	if err := l.DoString(body); err != nil {
+		c.WriteError(errLuaFunction(err))
+		return
+	}
+	if err := l.CallByParam(lua.P{
+		Fn:      callbacks[f.name],
+		NRet:    1,
+		Protect: true,
+	}, luaStrings(l, keys), luaStrings(l, argv)); err != nil {
+		c.WriteError(errLuaFunction(err))
+		return
+	}
+
+	luaToRedis(l, c, l.Get(-1))
+}
@@ -15,6 +15,32 @@ func fromGeohash(score uint64) (float64, float64) {
 	return long, lat
 }
 
+const earthRadius = 6372797.560856 // Earth radius in METERS, according to src/geohash_helper.c
+
+const geoAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// toGeohashString gives the 11 character geohash GEOHASH returns. Unlike the
+// scores this uses the standard -90..90 latitude range.
+func toGeohashString(long, lat float64) string {
+	const step = 26
+	lo := uint64((long + 180) / 360 * (1 << step))
+	la := uint64((lat + 90) / 180 * (1 << step))
+	var bits uint64
+	for i := step - 1; i >= 0; i-- {
+		bits = bits<<2 | (lo>>uint(i)&1)<<1 | la>>uint(i)&1
+	}
+
+	buf := make([]byte, 11)
+	for i := range buf {
+		idx := uint64(0) // we only have 52 bits, redis pads with 0
+		if i < 10 {
+			idx = bits >> uint(52-(i+1)*5) & 0x1f
+		}
+		buf[i] = geoAlphabet[idx]
+	}
+	return string(buf)
+}
+
 // haversin(θ) function
 func hsin(theta float64) float64 {
 	return math.Pow(math.Sin(theta/2), 2)
@@ -39,10 +65,14 @@ func distance(lat1, lon1, lat2, lon2 float64) float64 {
 	la2 = lat2 * math.Pi / 180
 	lo2 = lon2 * math.Pi / 180
 
-	earth := 6372797.560856 // Earth radius in METERS, according to src/geohash_helper.c
-
 	// calculate
 	h := hsin(la2-la1) + math.Cos(la1)*math.Cos(la2)*hsin(lo2-lo1)
 
-	return 2 * earth * math.Asin(math.Sqrt(h))
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// latDistance is the distance (in meters) between two latitudes on the same
+// meridian.
+func latDistance(lat1, lat2 float64) float64 {
+	return earthRadius * math.Abs((lat2-lat1)*math.Pi/180)
 }
@@ -0,0 +1,343 @@
+package miniredis
+
+// HyperLogLogs are stored as plain strings, byte for byte the same as Redis
+// does, so they can be GET, SET, and DUMPed between miniredis and Redis. See
+// hyperloglog.c in the Redis source for the details of the format.
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+const (
+	hllP         = 14
+	hllQ         = 64 - hllP
+	hllRegisters = 1 << hllP
+	hllBits      = 6
+	hllHeaderLen = 16
+	hllDenseLen  = hllHeaderLen + (hllRegisters*hllBits+7)/8
+	hllDense     = 0
+	hllSparse    = 1
+	hllMaxVal    = 1<<hllBits - 1
+
+	hllSparseValMax    = 32
+	hllSparseValMaxLen = 4
+	hllSparseZeroMax   = 64
+	hllSparseXZeroMax  = 16384
+)
+
+var (
+	errHLLNotValid = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	errHLLInvalid  = errors.New("INVALIDOBJ Corrupted HLL object detected")
+)
+
+// hll is a decoded HyperLogLog.
+type hll struct {
+	registers [hllRegisters]uint8
+	dense     bool
+	card      uint64 // cached cardinality
+	cardValid bool
+}
+
+func newHLL() *hll {
+	// a new HLL has a valid cached cardinality of 0
+	return &hll{cardValid: true}
+}
+
+// isHLL is whether a string looks like a HyperLogLog. It doesn't check the
+// registers.
+func isHLL(v string) bool {
+	if len(v) < hllHeaderLen || v[:4] != "HYLL" {
+		return false
+	}
+	switch v[4] {
+	case hllDense:
+		return len(v) == hllDenseLen
+	case hllSparse:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseHLL decodes a HyperLogLog string.
+func parseHLL(v string) (*hll, error) {
+	if !isHLL(v) {
+		return nil, errHLLNotValid
+	}
+	h := &hll{}
+	card := []byte(v[8:16])
+	h.cardValid = card[7]&(1<<7) == 0
+	card[7] &^= 1 << 7
+	h.card = binary.LittleEndian.Uint64(card)
+
+	if v[4] == hllDense {
+		h.dense = true
+		regs := v[hllHeaderLen:]
+		for i := range h.registers {
+			h.registers[i] = denseGet(regs, i)
+		}
+		return h, nil
+	}
+
+	idx := 0
+	for p := hllHeaderLen; p < len(v); p++ {
+		op := v[p]
+		switch {
+		case op&0xc0 == 0x00: // ZERO
+			idx += int(op&0x3f) + 1
+		case op&0xc0 == 0x40: // XZERO
+			if p+1 >= len(v) {
+				return nil, errHLLInvalid
+			}
+			idx += (int(op&0x3f)<<8 | int(v[p+1])) + 1
+			p++
+		default: // VAL
+			val := (op>>2)&0x1f + 1
+			n := int(op&0x03) + 1
+			if idx+n > hllRegisters {
+				return nil, errHLLInvalid
+			}
+			for i := 0; i < n; i++ {
+				h.registers[idx+i] = val
+			}
+			idx += n
+		}
+	}
+	if idx != hllRegisters {
+		return nil, errHLLInvalid
+	}
+	return h, nil
+}
+
+func denseGet(regs string, i int) uint8 {
+	b := i * hllBits / 8
+	fb := uint(i * hllBits & 7)
+	b0 := uint(regs[b])
+	b1 := uint(0)
+	if b+1 < len(regs) {
+		b1 = uint(regs[b+1])
+	}
+	return uint8((b0>>fb | b1<<(8-fb)) & hllMaxVal)
+}
+
+func denseSet(regs []byte, i int, val uint8) {
+	b := i * hllBits / 8
+	fb := uint(i * hllBits & 7)
+	v := uint(val)
+	regs[b] &^= byte(hllMaxVal << fb)
+	regs[b] |= byte(v << fb)
+	if b+1 < len(regs) {
+		regs[b+1] &^= byte(hllMaxVal >> (8 - fb))
+		regs[b+1] |= byte(v >> (8 - fb))
+	}
+}
+
+// add adds an element. Returns whether a register changed.
+func (h *hll) add(el string) bool {
+	hash := murmurHash64A([]byte(el), 0xadc83b19)
+	idx := hash & (hllRegisters - 1)
+	hash >>= hllP
+	hash |= 1 << hllQ // make sure count is <= Q+1
+	count := uint8(bits.TrailingZeros64(hash) + 1)
+	return h.set(int(idx), count)
+}
+
+// set sets a register, if val is bigger than what's there now.
+func (h *hll) set(idx int, val uint8) bool {
+	if h.registers[idx] >= val {
+		return false
+	}
+	h.registers[idx] = val
+	h.cardValid = false
+	return true
+}
+
+// merge sets every register to the max of h and o.
+func (h *hll) merge(o *hll) {
+	for i, v := range o.registers {
+		h.set(i, v)
+	}
+}
+
+// count estimates the cardinality, using the cached value if there is one.
+func (h *hll) count() uint64 {
+	if h.cardValid {
+		return h.card
+	}
+	var histo [64]int
+	for _, r := range h.registers {
+		histo[r]++
+	}
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histo[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histo[0])/m)
+	const alphaInf = 0.721347520444481703680
+	h.card = uint64(math.Round(alphaInf * m * m / z))
+	h.cardValid = true
+	return h.card
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// encode gives the Redis string for the HLL. It uses the sparse encoding
+// unless the HLL is already dense, or if that would be larger than
+// maxSparse bytes, same as Redis. Once dense, it stays dense.
+func (h *hll) encode(maxSparse int) string {
+	if !h.dense {
+		if s, ok := h.encodeSparse(); ok && len(s) <= maxSparse {
+			return s
+		}
+		h.dense = true
+	}
+
+	b := make([]byte, hllDenseLen)
+	h.header(b, hllDense)
+	regs := b[hllHeaderLen:]
+	for i, v := range h.registers {
+		denseSet(regs, i, v)
+	}
+	return string(b)
+}
+
+// encodeSparse gives the sparse encoding. False if a register is too big
+// for it.
+func (h *hll) encodeSparse() (string, bool) {
+	b := make([]byte, hllHeaderLen, 64)
+	h.header(b, hllSparse)
+	for i := 0; i < hllRegisters; {
+		v := h.registers[i]
+		n := 1
+		for i+n < hllRegisters && h.registers[i+n] == v {
+			n++
+		}
+		i += n
+
+		if v == 0 {
+			for n > 0 {
+				switch {
+				case n <= hllSparseZeroMax:
+					b = append(b, byte(n-1))
+					n = 0
+				default:
+					l := n
+					if l > hllSparseXZeroMax {
+						l = hllSparseXZeroMax
+					}
+					b = append(b, 0x40|byte((l-1)>>8), byte(l-1))
+					n -= l
+				}
+			}
+			continue
+		}
+		if v > hllSparseValMax {
+			return "", false
+		}
+		for n > 0 {
+			l := n
+			if l > hllSparseValMaxLen {
+				l = hllSparseValMaxLen
+			}
+			b = append(b, 0x80|(v-1)<<2|byte(l-1))
+			n -= l
+		}
+	}
+	return string(b), true
+}
+
+func (h *hll) header(b []byte, encoding byte) {
+	copy(b, "HYLL")
+	b[4] = encoding
+	binary.LittleEndian.PutUint64(b[8:], h.card)
+	if !h.cardValid {
+		b[15] |= 1 << 7
+	}
+}
+
+// murmurHash64A is the hash function Redis uses for HyperLogLogs.
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const (
+		m = 0xc6a4a7935bd1e995
+		r = 47
+	)
+	h := seed ^ (uint64(len(key)) * m)
+
+	n := len(key) / 8
+	for i := 0; i < n; i++ {
+		k := binary.LittleEndian.Uint64(key[i*8:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+
+	tail := key[n*8:]
+	switch len(tail) {
+	case 7:
+		h ^= uint64(tail[6]) << 48
+		fallthrough
+	case 6:
+		h ^= uint64(tail[5]) << 40
+		fallthrough
+	case 5:
+		h ^= uint64(tail[4]) << 32
+		fallthrough
+	case 4:
+		h ^= uint64(tail[3]) << 24
+		fallthrough
+	case 3:
+		h ^= uint64(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint64(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint64(tail[0])
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
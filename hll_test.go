@@ -0,0 +1,66 @@
+package miniredis
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestHLL(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		h := newHLL()
+		equals(t, uint64(0), h.count())
+		equals(t, "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xff", h.encode(3000))
+	})
+
+	t.Run("sparse", func(t *testing.T) {
+		h := newHLL()
+		for i := 0; i < 100; i++ {
+			h.add(strconv.Itoa(i))
+		}
+		equals(t, uint64(100), h.count())
+		v := h.encode(3000)
+		equals(t, byte(hllSparse), v[4])
+
+		h2, err := parseHLL(v)
+		ok(t, err)
+		equals(t, h.registers, h2.registers)
+		equals(t, true, h2.cardValid)
+		equals(t, uint64(100), h2.card)
+	})
+
+	t.Run("dense", func(t *testing.T) {
+		h := newHLL()
+		for i := 0; i < 10000; i++ {
+			h.add(strconv.Itoa(i))
+		}
+		n := h.count()
+		assert(t, n > 9800 && n < 10200, "count is close: %d", n)
+		v := h.encode(3000)
+		equals(t, hllDenseLen, len(v))
+		equals(t, byte(hllDense), v[4])
+
+		h2, err := parseHLL(v)
+		ok(t, err)
+		equals(t, h.registers, h2.registers)
+		equals(t, true, h2.dense)
+		// once dense, it stays dense
+		equals(t, byte(hllDense), h2.encode(3000)[4])
+	})
+
+	t.Run("sparse max bytes", func(t *testing.T) {
+		h := newHLL()
+		for i := 0; i < 100; i++ {
+			h.add(strconv.Itoa(i))
+		}
+		equals(t, byte(hllDense), h.encode(100)[4])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := parseHLL("foo")
+		equals(t, errHLLNotValid, err)
+		_, err = parseHLL("HYLL\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+		equals(t, errHLLNotValid, err)
+		_, err = parseHLL("HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xfe")
+		equals(t, errHLLInvalid, err)
+	})
+}
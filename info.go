@@ -0,0 +1,275 @@
+package miniredis
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+// infoSection is a single INFO section. The values are made up from the
+// miniredis state where possible, and are the defaults of a fresh Redis
+// otherwise.
+type infoSection struct {
+	title string // "Server", &c. The lowercase version is the section name.
+	dflt  bool   // part of "INFO" without arguments
+	lines func(m *Miniredis) []string
+}
+
+var infoSections = []infoSection{
+	{title: "Server", dflt: true, lines: infoServer},
+	{title: "Clients", dflt: true, lines: infoClients},
+	{title: "Memory", dflt: true, lines: infoMemory},
+	{title: "Persistence", dflt: true, lines: infoPersistence},
+	{title: "Stats", dflt: true, lines: infoStats},
+	{title: "Replication", dflt: true, lines: infoReplication},
+	{title: "CPU", dflt: true, lines: infoCPU},
+	{title: "Commandstats", lines: infoCommandstats},
+	{title: "Latencystats", lines: infoLatencystats},
+	{title: "Keyspace", dflt: true, lines: infoKeyspace},
+}
+
+// selectInfoSections gives the sections asked for by the INFO arguments, in
+// the standard order. Unknown section names are ignored.
+func selectInfoSections(args []string) []infoSection {
+	if len(args) == 0 {
+		args = []string{"default"}
+	}
+	var res []infoSection
+	for _, s := range infoSections {
+		name := strings.ToLower(s.title)
+		for _, a := range args {
+			a = strings.ToLower(a)
+			if a == name || a == "all" || a == "everything" || (a == "default" && s.dflt) {
+				res = append(res, s)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// info renders sections the way INFO does. Must be called with m locked.
+func (m *Miniredis) info(sections []infoSection) string {
+	var b strings.Builder
+	for i, s := range sections {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + s.title + "\r\n")
+		for _, l := range s.lines(m) {
+			b.WriteString(l + "\r\n")
+		}
+	}
+	return b.String()
+}
+
+func infoServer(m *Miniredis) []string {
+	uptime := time.Since(m.started)
+	return []string{
+		"redis_version:" + redisVersion,
+		"redis_mode:standalone",
+		"os:" + runtime.GOOS,
+		"arch_bits:" + strconv.Itoa(strconv.IntSize),
+		"process_id:" + strconv.Itoa(os.Getpid()),
+		"tcp_port:" + strconv.Itoa(m.port),
+		"server_time_usec:" + strconv.FormatInt(m.effectiveNow().UnixNano()/1000, 10),
+		"uptime_in_seconds:" + strconv.Itoa(int(uptime/time.Second)),
+		"uptime_in_days:" + strconv.Itoa(int(uptime/(24*time.Hour))),
+	}
+}
+
+func infoClients(m *Miniredis) []string {
+	blocked := map[chan struct{}]struct{}{}
+	for _, chans := range m.blocked {
+		for _, ch := range chans {
+			blocked[ch] = struct{}{}
+		}
+	}
+	pubsub := 0
+	for s := range m.subscribers {
+		if s.active() {
+			pubsub++
+		}
+	}
+	return []string{
+		"connected_clients:" + strconv.Itoa(m.srv.ClientsLen()),
+		"maxclients:" + strconv.Itoa(m.config.maxclients),
+		"blocked_clients:" + strconv.Itoa(len(blocked)),
+		"tracking_clients:" + strconv.Itoa(len(m.trackers)),
+		"pubsub_clients:" + strconv.Itoa(pubsub),
+	}
+}
+
+func infoMemory(m *Miniredis) []string {
+	used := m.usedMemory()
+	return []string{
+		"used_memory:" + strconv.Itoa(used),
+		"used_memory_human:" + bytesToHuman(used),
+		"maxmemory:" + strconv.Itoa(m.config.maxmemory),
+		"maxmemory_human:" + bytesToHuman(m.config.maxmemory),
+		"maxmemory_policy:" + findConfigParam("maxmemory-policy").get(m),
+	}
+}
+
+func infoPersistence(m *Miniredis) []string {
+	aof := "0"
+	if m.config.appendonly {
+		aof = "1"
+	}
+	return []string{
+		"loading:0",
+		"async_loading:0",
+		"rdb_changes_since_last_save:0",
+		"rdb_bgsave_in_progress:0",
+		"rdb_last_save_time:" + strconv.FormatInt(m.started.Unix(), 10),
+		"rdb_last_bgsave_status:ok",
+		"aof_enabled:" + aof,
+		"aof_rewrite_in_progress:0",
+		"aof_last_bgrewrite_status:ok",
+	}
+}
+
+func infoStats(m *Miniredis) []string {
+	subs := m.allSubscribers()
+	return []string{
+		"total_connections_received:" + strconv.Itoa(m.srv.TotalConnections()),
+		"total_commands_processed:" + strconv.Itoa(m.srv.TotalCommands()),
+		"instantaneous_ops_per_sec:0",
+		"rejected_connections:0",
+		"expired_keys:0",
+		"evicted_keys:0",
+		"keyspace_hits:0",
+		"keyspace_misses:0",
+		"pubsub_channels:" + strconv.Itoa(len(activeChannels(subs, "", false))),
+		"pubsub_patterns:" + strconv.Itoa(countPsubs(subs)),
+		"pubsub_shardchannels:" + strconv.Itoa(len(activeChannels(subs, "", true))),
+	}
+}
+
+func infoReplication(m *Miniredis) []string {
+	return []string{
+		"role:master",
+		"connected_slaves:" + strconv.Itoa(m.replicas),
+		"master_failover_state:no-failover",
+		"master_repl_offset:0",
+		"repl_backlog_active:0",
+	}
+}
+
+// We don't measure CPU usage.
+func infoCPU(m *Miniredis) []string {
+	return []string{
+		"used_cpu_sys:0.000000",
+		"used_cpu_user:0.000000",
+		"used_cpu_sys_children:0.000000",
+		"used_cpu_user_children:0.000000",
+	}
+}
+
+// sortedCommandStats has the stats of every called command, by lowercase
+// command name.
+func (m *Miniredis) sortedCommandStats() ([]string, map[string]server.CmdStats) {
+	stats := m.srv.CommandStats()
+	var names []string
+	for k := range stats {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names, stats
+}
+
+// All errors are counted as failed_calls.
+func infoCommandstats(m *Miniredis) []string {
+	names, stats := m.sortedCommandStats()
+	var res []string
+	for _, name := range names {
+		cs := stats[name]
+		usec := int(cs.Duration / time.Microsecond)
+		res = append(res, fmt.Sprintf(
+			"cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=0,failed_calls=%d",
+			strings.ToLower(name), cs.Calls, usec, float64(usec)/float64(cs.Calls), cs.Failed,
+		))
+	}
+	return res
+}
+
+func infoLatencystats(m *Miniredis) []string {
+	names, stats := m.sortedCommandStats()
+	var res []string
+	for _, name := range names {
+		cs := stats[name]
+		res = append(res, fmt.Sprintf(
+			"latency_percentiles_usec_%s:p50=%d.000,p99=%d.000,p99.9=%d.000",
+			strings.ToLower(name), cs.Percentile(50), cs.Percentile(99), cs.Percentile(99.9),
+		))
+	}
+	return res
+}
+
+// Only non-empty databases are listed. avg_ttl is in milliseconds.
+func infoKeyspace(m *Miniredis) []string {
+	var ids []int
+	for id, db := range m.dbs {
+		if len(db.keys) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	var res []string
+	for _, id := range ids {
+		db := m.dbs[id]
+		var total time.Duration
+		for _, ttl := range db.ttl {
+			total += ttl
+		}
+		avg := 0
+		if n := len(db.ttl); n > 0 {
+			avg = int(total / time.Duration(n) / time.Millisecond)
+		}
+		res = append(res, fmt.Sprintf("db%d:keys=%d,expires=%d,avg_ttl=%d", id, len(db.keys), len(db.ttl), avg))
+	}
+	return res
+}
+
+// usedMemory is an estimate of the memory used by all keys: the length of
+// the key names plus their DUMP size. Sizes are cached, only the keys changed
+// since the last call (see markDirty()) get dumped again.
+func (m *Miniredis) usedMemory() int {
+	n := 0
+	for _, db := range m.dbs {
+		for k := range db.memDirty {
+			db.memUsed -= db.memSizes[k]
+			delete(db.memSizes, k)
+			if db.exists(k) {
+				size := len(k) + len(db.dump(k))
+				db.memSizes[k] = size
+				db.memUsed += size
+			}
+		}
+		db.memDirty = map[string]struct{}{}
+		n += db.memUsed
+	}
+	return n
+}
+
+// bytesToHuman formats a number of bytes the way INFO does: "1.50K".
+func bytesToHuman(n int) string {
+	if n < 1024 {
+		return strconv.Itoa(n) + "B"
+	}
+	f := float64(n)
+	for _, unit := range []string{"K", "M", "G", "T"} {
+		f /= 1024
+		if f < 1024 || unit == "T" {
+			return fmt.Sprintf("%.2f%s", f, unit)
+		}
+	}
+	panic("unreachable")
+}
@@ -10,3 +10,35 @@ func TestCommand(t *testing.T) {
 		c.DoLoosely("COMMAND")
 	})
 }
+
+func TestCommandInfo(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.DoLoosely("COMMAND", "COUNT")
+		c.DoLoosely("COMMAND", "INFO", "GET", "SET")
+		c.Do("COMMAND", "INFO", "NOSUCH")
+		c.DoLoosely("COMMAND", "DOCS", "GET")
+		c.Do("COMMAND", "DOCS", "NOSUCH")
+
+		c.Error("Try COMMAND HELP", "COMMAND", "COUNT", "foo")
+		c.Error("Try COMMAND HELP", "COMMAND", "NOSUCH")
+	})
+}
+
+func TestCommandGetkeys(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("COMMAND", "GETKEYS", "GET", "foo")
+		c.Do("COMMAND", "GETKEYS", "MSET", "k1", "v1", "k2", "v2")
+		c.Do("COMMAND", "GETKEYS", "BLPOP", "l1", "l2", "0")
+		c.Do("COMMAND", "GETKEYS", "EVAL", "return 1", "2", "k1", "k2", "arg")
+		c.Do("COMMAND", "GETKEYS", "ZUNIONSTORE", "dest", "2", "z1", "z2")
+		c.Do("COMMAND", "GETKEYS", "XREAD", "STREAMS", "s1", "s2", "0", "0")
+		c.Do("COMMAND", "GETKEYS", "SORT", "src", "STORE", "dst")
+		c.Do("COMMAND", "GETKEYSANDFLAGS", "GET", "foo")
+		c.Do("COMMAND", "GETKEYSANDFLAGS", "DEL", "foo")
+
+		c.Error("wrong number", "COMMAND", "GETKEYS")
+		c.Error("Invalid command", "COMMAND", "GETKEYS", "NOSUCH")
+		c.Error("no key arguments", "COMMAND", "GETKEYS", "PING")
+		c.Error("Invalid number of arguments", "COMMAND", "GETKEYS", "GET")
+	})
+}
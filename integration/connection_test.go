@@ -204,3 +204,66 @@ func TestHello(t *testing.T) {
 		},
 	)
 }
+
+func TestClientTracking(t *testing.T) {
+	testRESP3Pair(t, func(c1, c2 *client) {
+		c1.Do("CLIENT", "TRACKING", "ON")
+		c2.Do("SET", "foo", "bar")
+		c1.Do("GET", "foo")
+		c2.Do("SET", "foo", "baz")
+		c1.Receive()
+		c2.Do("FLUSHALL")
+		c1.Receive()
+		c1.Do("CLIENT", "TRACKING", "OFF")
+
+		c1.Do("CLIENT", "TRACKING", "ON", "BCAST", "PREFIX", "user:")
+		c2.Do("SET", "user:1", "bar")
+		c1.Receive()
+		c1.Do("CLIENT", "TRACKING", "OFF")
+	})
+
+	testRaw(t, func(c *client) {
+		c.Error("wrong number", "CLIENT")
+		c.Error("Try CLIENT HELP", "CLIENT", "FOO")
+		c.Error("syntax error", "CLIENT", "TRACKING", "MAYBE")
+		c.Error("requires BCAST", "CLIENT", "TRACKING", "ON", "PREFIX", "foo")
+		c.Error("not compatible", "CLIENT", "TRACKING", "ON", "BCAST", "OPTIN")
+		c.Error("both OPTIN and OPTOUT", "CLIENT", "TRACKING", "ON", "OPTIN", "OPTOUT")
+		c.Error("overlaps", "CLIENT", "TRACKING", "ON", "BCAST", "PREFIX", "foo", "PREFIX", "foobar")
+		c.Error("not an integer", "CLIENT", "TRACKING", "ON", "REDIRECT", "foo")
+		c.Error("does not exist", "CLIENT", "TRACKING", "ON", "REDIRECT", "999999")
+		c.Error("OPTIN or OPTOUT", "CLIENT", "CACHING", "YES")
+		c.Do("CLIENT", "TRACKING", "ON", "OPTIN")
+		c.Do("CLIENT", "CACHING", "YES")
+		c.Error("OPTOUT mode", "CLIENT", "CACHING", "NO")
+	})
+}
+
+func TestClientList(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.DoLoosely("CLIENT", "LIST")
+		c.DoLoosely("CLIENT", "LIST", "TYPE", "normal")
+		c.Do("CLIENT", "LIST", "TYPE", "pubsub")
+		c.Do("CLIENT", "LIST", "ID", "999999")
+
+		c.Error("Unknown client type", "CLIENT", "LIST", "TYPE", "foo")
+		c.Error("Invalid client ID", "CLIENT", "LIST", "ID", "foo")
+		c.Error("syntax error", "CLIENT", "LIST", "FOO")
+	})
+}
+
+func TestClientName(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("CLIENT", "GETNAME")
+		c.Do("CLIENT", "SETNAME", "worker-1")
+		c.Do("CLIENT", "GETNAME")
+		c.DoLoosely("CLIENT", "INFO")
+		c.DoLoosely("CLIENT", "ID")
+		c.Do("CLIENT", "SETNAME", "")
+		c.Do("CLIENT", "GETNAME")
+
+		c.Error("cannot contain spaces", "CLIENT", "SETNAME", "foo bar")
+		c.Error("Try CLIENT HELP", "CLIENT", "SETNAME")
+		c.Error("Try CLIENT HELP", "CLIENT", "GETNAME", "foo")
+	})
+}
@@ -39,6 +39,24 @@ func TestKeys(t *testing.T) {
 		c.DoSorted("KEYS", `*o*`)
 		c.DoSorted("KEYS", `[]*`) // nothing
 	})
+
+	testRaw(t, func(c *client) {
+		c.Do("SET", "ax", "1")
+		c.Do("SET", "bx", "2")
+		c.Do("SET", "dx", "3")
+		c.Do("SET", `x\`, "4")
+		c.Do("SET", "*", "5")
+		c.DoSorted("KEYS", `[a-c]x`)
+		c.DoSorted("KEYS", `[c-a]x`)
+		c.DoSorted("KEYS", `[^a-c]x`)
+		c.DoSorted("KEYS", `[^]x`)
+		c.DoSorted("KEYS", `[\d]x`)
+		c.DoSorted("KEYS", `x\`)
+		c.DoSorted("KEYS", `[ab`)
+		c.DoSorted("KEYS", `\*`)
+		c.DoSorted("KEYS", `[*]`)
+		c.Do("SCAN", "0", "MATCH", `[c-d]x`)
+	})
 }
 
 func TestRandom(t *testing.T) {
@@ -151,6 +169,11 @@ func TestScan(t *testing.T) {
 		c.Do("SCAN", "0", "MATCH", "anoth*", "COUNT", "100")
 		c.Do("SCAN", "0", "COUNT", "100", "MATCH", "anoth*")
 
+		c.Do("HSET", "hash", "aap", "noot")
+		c.Do("SCAN", "0", "TYPE", "hash")
+		c.Do("SCAN", "0", "TYPE", "string", "MATCH", "anoth*")
+		c.Do("SCAN", "0", "TYPE", "nosuch")
+
 		// Can't really test multiple keys.
 		// c.Do("SET", "key2", "value2")
 		// c.Do("SCAN", "0")
@@ -160,7 +183,9 @@ func TestScan(t *testing.T) {
 		c.Error("invalid cursor", "SCAN", "noint")
 		c.Error("not an integer", "SCAN", "0", "COUNT", "noint")
 		c.Error("syntax error", "SCAN", "0", "COUNT")
+		c.Error("syntax error", "SCAN", "0", "COUNT", "0")
 		c.Error("syntax error", "SCAN", "0", "MATCH")
+		c.Error("syntax error", "SCAN", "0", "TYPE")
 		c.Error("syntax error", "SCAN", "0", "garbage")
 		c.Error("syntax error", "SCAN", "0", "COUNT", "12", "MATCH", "foo", "garbage")
 	})
@@ -191,6 +216,21 @@ func TestFastForward(t *testing.T) {
 		c.DoSorted("KEYS", "*")
 		c.Do("EXPIRE", "key4", "0")
 		c.DoSorted("KEYS", "*")
+
+		c.Do("SET", "opt", "value")
+		c.Do("EXPIRE", "opt", "100", "XX")
+		c.Do("EXPIRE", "opt", "100", "GT")
+		c.Do("EXPIRE", "opt", "100", "NX")
+		c.Do("EXPIRE", "opt", "200", "NX")
+		c.Do("EXPIRE", "opt", "200", "XX", "GT")
+		c.Do("EXPIRE", "opt", "100", "GT")
+		c.Do("EXPIRE", "opt", "100", "lt")
+		c.Do("TTL", "opt")
+		c.Do("PEXPIRE", "opt", "300000", "LT")
+		c.Do("EXPIRE", "nosuch", "100", "NX")
+		c.Error("not compatible", "EXPIRE", "opt", "100", "NX", "XX")
+		c.Error("not compatible", "EXPIRE", "opt", "100", "GT", "LT")
+		c.Error("Unsupported option", "EXPIRE", "opt", "100", "FOO")
 	})
 }
 
@@ -284,6 +324,7 @@ func TestTouch(t *testing.T) {
 		c.Do("TTL", "a")
 
 		c.Do("TOUCH", "a", "foobar", "a")
+		c.Do("OBJECT", "IDLETIME", "a")
 
 		c.Error("wrong number", "TOUCH")
 	})
@@ -298,3 +339,217 @@ func TestPersist(t *testing.T) {
 		c.Do("TTL", "foo")
 	})
 }
+
+func TestObjectEncoding(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("SET", "int", "12345")
+		c.Do("SET", "notint", "012345")
+		c.Do("SET", "short", "short value")
+		c.Do("SET", "long", strings.Repeat("x", 45))
+		c.Do("RPUSH", "list", "aap", "noot")
+		c.Do("RPUSH", "biglist", strings.Repeat("x", 8*1024+1))
+		c.Do("SADD", "intset", "1", "2", "3")
+		c.Do("SADD", "set", "aap", "noot")
+		c.Do("SADD", "bigset", strings.Repeat("x", 65))
+		c.Do("HSET", "hash", "aap", "noot")
+		c.Do("HSET", "bighash", "aap", strings.Repeat("x", 65))
+		c.Do("ZADD", "zset", "1", "aap")
+		c.Do("ZADD", "bigzset", "1", strings.Repeat("x", 65))
+
+		for _, k := range []string{
+			"int", "notint", "short", "long",
+			"list", "biglist",
+			"intset", "set", "bigset",
+			"hash", "bighash",
+			"zset", "bigzset",
+			"nosuch",
+		} {
+			c.Do("OBJECT", "ENCODING", k)
+		}
+	})
+}
+
+func TestObjectIdletime(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("SET", "foo", "bar")
+		c.Do("OBJECT", "IDLETIME", "foo")
+		c.Do("GET", "foo")
+		c.Do("OBJECT", "IDLETIME", "foo")
+		c.Do("OBJECT", "IDLETIME", "nosuch")
+		c.Error("LFU maxmemory policy is not selected", "OBJECT", "FREQ", "foo")
+		c.Do("OBJECT", "FREQ", "nosuch")
+		c.Error("wrong number", "OBJECT", "IDLETIME")
+	})
+}
+
+func TestObjectRefcount(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("SET", "small", "12")
+		c.Do("OBJECT", "REFCOUNT", "small")
+		c.Do("SET", "big", "12345")
+		c.Do("OBJECT", "REFCOUNT", "big")
+		c.Do("SET", "str", "foo")
+		c.Do("OBJECT", "REFCOUNT", "str")
+		c.Do("RPUSH", "list", "12")
+		c.Do("OBJECT", "REFCOUNT", "list")
+		c.Do("OBJECT", "REFCOUNT", "nosuch")
+		c.Error("wrong number", "OBJECT", "REFCOUNT")
+		c.Error("wrong number", "OBJECT", "HELP", "foo")
+	})
+}
+
+func TestCopy(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("SET", "key1", "value")
+		c.Do("COPY", "key1", "key2")
+		c.Do("GET", "key2")
+		c.Do("COPY", "nosuch", "key3")
+		c.Do("COPY", "key1", "key2")
+		c.Do("COPY", "key1", "key2", "REPLACE")
+
+		c.Do("HSET", "hash", "aap", "noot")
+		c.Do("EXPIRE", "hash", "100")
+		c.Do("COPY", "hash", "hash2", "DB", "2")
+		c.Do("EXISTS", "hash2")
+		c.Do("SELECT", "2")
+		c.Do("HGETALL", "hash2")
+		c.Do("TTL", "hash2")
+		c.Do("SELECT", "0")
+
+		c.Do("XADD", "planets", "0-1", "name", "Mercury")
+		c.Do("XGROUP", "CREATE", "planets", "processing", "0")
+		c.Do("XREADGROUP", "GROUP", "processing", "alice", "STREAMS", "planets", ">")
+		c.Do("COPY", "planets", "planets2")
+		c.Do("XPENDING", "planets2", "processing")
+		c.Do("XRANGE", "planets2", "-", "+")
+
+		c.Error("wrong number", "COPY")
+		c.Error("wrong number", "COPY", "key1")
+		c.Error("the same", "COPY", "key1", "key1")
+		c.Error("syntax error", "COPY", "key1", "key2", "FOO")
+		c.Error("not an integer", "COPY", "key1", "key2", "DB", "foo")
+	})
+}
+
+func TestDumpRestore(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("SET", "mykey", "10")
+		c.Do("DUMP", "mykey")
+		c.Do("SET", "str", "value")
+		c.Do("DUMP", "str")
+		c.Do("DUMP", "nosuch")
+
+		c.Do("RESTORE", "mylist", "0", "\n\x17\x17\x00\x00\x00\x12\x00\x00\x00\x03\x00\x00\xc0\x01\x00\x04\xc0\x02\x00\x04\xc0\x03\x00\xff\x04\x00u#<\xc0;.\xe9\xdd")
+		c.Do("LRANGE", "mylist", "0", "-1")
+		c.Do("RESTORE", "restored", "0", "\x00\x05value\t\x00Q\x04\x90\xf4\x95,\xf8\xdf")
+		c.Do("GET", "restored")
+		c.Do("RESTORE", "restored", "0", "\x00\x05value\t\x00Q\x04\x90\xf4\x95,\xf8\xdf", "REPLACE", "IDLETIME", "10")
+		c.Do("OBJECT", "IDLETIME", "restored")
+
+		c.Error("wrong number", "DUMP")
+		c.Error("wrong number", "RESTORE", "key", "0")
+		c.Error("BUSYKEY", "RESTORE", "restored", "0", "\x00\x05value\t\x00Q\x04\x90\xf4\x95,\xf8\xdf")
+		c.Error("checksum", "RESTORE", "key", "0", "foo")
+		c.Error("TTL", "RESTORE", "key", "-1", "\x00\x05value\t\x00Q\x04\x90\xf4\x95,\xf8\xdf")
+		c.Error("syntax error", "RESTORE", "key", "0", "\x00\x05value\t\x00Q\x04\x90\xf4\x95,\xf8\xdf", "FOO")
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	// there's no second server, so only the parts which don't connect
+	testRaw(t, func(c *client) {
+		c.Do("MIGRATE", "localhost", "6379", "nosuch", "0", "1000")
+		c.Do("MIGRATE", "localhost", "6379", "", "0", "1000", "KEYS", "nosuch", "nosuch2")
+
+		c.Error("wrong number", "MIGRATE", "localhost", "6379", "key", "0")
+		c.Error("not an integer", "MIGRATE", "localhost", "6379", "key", "0", "foo")
+		c.Error("syntax error", "MIGRATE", "localhost", "6379", "key", "0", "1000", "FOO")
+		c.Error("empty string", "MIGRATE", "localhost", "6379", "key", "0", "1000", "KEYS", "key")
+	})
+}
+
+func TestSort(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("RPUSH", "list", "3", "1", "20", "2")
+		c.Do("SORT", "list")
+		c.Do("SORT", "list", "DESC")
+		c.Do("SORT", "list", "ALPHA")
+		c.Do("SORT", "list", "LIMIT", "1", "2")
+		c.Do("SORT", "list", "LIMIT", "-5", "2")
+		c.Do("SORT", "list", "LIMIT", "2", "-1")
+		c.Do("SORT", "list", "LIMIT", "10", "2")
+		c.Do("SORT", "nosuch")
+
+		c.Do("SADD", "set", "b", "c", "a")
+		c.Do("SORT", "set", "ALPHA", "DESC")
+		c.Error("converted into double", "SORT", "set")
+
+		c.Do("ZADD", "zset", "3", "one", "1", "two", "2", "three")
+		c.Do("SORT", "zset", "BY", "nosort")
+		c.Do("SORT", "zset", "BY", "nosort", "DESC", "LIMIT", "0", "2")
+
+		c.Do("SET", "weight_1", "30")
+		c.Do("SET", "weight_2", "10")
+		c.Do("SET", "weight_3", "20")
+		c.Do("SORT", "list", "BY", "weight_*")
+		c.Do("SORT", "list", "BY", "nosort")
+		c.Do("HSET", "obj_1", "w", "b")
+		c.Do("HSET", "obj_2", "w", "a")
+		c.Do("HSET", "obj_3", "w", "c")
+		c.Do("SORT", "list", "BY", "obj_*->w", "ALPHA")
+
+		c.Do("SET", "name_1", "aap")
+		c.Do("SET", "name_2", "noot")
+		c.Do("SET", "name_3", "mies")
+		c.Do("SORT", "list", "GET", "name_*", "GET", "#", "GET", "obj_*->w")
+		c.Do("SORT", "list", "GET", "nostar", "LIMIT", "0", "1")
+		c.Do("SORT", "list", "GET", "name_*", "STORE", "dest")
+		c.Do("LRANGE", "dest", "0", "-1")
+		c.Do("SORT", "nosuch", "STORE", "dest")
+		c.Do("EXISTS", "dest")
+
+		c.Error("wrong number", "SORT")
+		c.Error("syntax error", "SORT", "list", "LIMIT", "1")
+		c.Error("not an integer", "SORT", "list", "LIMIT", "a", "1")
+		c.Error("syntax error", "SORT", "list", "FOO")
+		c.Do("SET", "str", "value")
+		c.Error("wrong kind", "SORT", "str")
+	})
+}
+
+func TestSortRO(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("RPUSH", "list", "3", "1", "2")
+		c.Do("SET", "name_1", "aap")
+		c.Do("SORT_RO", "list")
+		c.Do("SORT_RO", "list", "DESC", "LIMIT", "0", "1", "GET", "name_*")
+
+		c.Error("wrong number", "SORT_RO")
+		c.Error("syntax error", "SORT_RO", "list", "STORE", "dest")
+	})
+}
+
+func TestWait(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("WAIT", "0", "0")
+		c.Do("WAIT", "1", "10")
+
+		c.Error("wrong number", "WAIT", "1")
+		c.Error("not an integer", "WAIT", "foo", "0")
+		c.Error("not an integer", "WAIT", "1", "foo")
+		c.Error("negative", "WAIT", "1", "-1")
+	})
+}
+
+func TestWaitaof(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("WAITAOF", "0", "0", "0")
+		c.Error("appendonly is disabled", "WAITAOF", "1", "0", "0")
+
+		c.Error("wrong number", "WAITAOF", "0", "0")
+		c.Error("not an integer", "WAITAOF", "foo", "0", "0")
+		c.Error("must be positive", "WAITAOF", "-1", "0", "0")
+		c.Error("not an integer", "WAITAOF", "0", "0", "foo")
+		c.Error("negative", "WAITAOF", "0", "0", "-1")
+	})
+}
@@ -46,6 +46,9 @@ func TestGeoadd(t *testing.T) {
 		c.Error("wrong number", "GEOADD", "foo", "86.9248308")
 		c.Error("wrong number", "GEOADD", "foo", "86.9248308", "27.9878675")
 		c.Do("GEOADD", "foo", "86.9248308", "27.9878675", "")
+		c.Error("syntax error", "GEOADD", "foo", "86.9248308", "27.9878675", "bar", "1")
+		c.Error("syntax error", "GEOADD", "foo", "NX", "86.9248308", "27.9878675")
+		c.Error("not compatible", "GEOADD", "foo", "NX", "XX", "86.9248308", "27.9878675", "bar")
 		c.Error("not a valid float", "GEOADD", "foo", "eight", "27.9878675", "bar")
 		c.Error("not a valid float", "GEOADD", "foo", "86.9248308", "seven", "bar")
 		// failures in a transaction
@@ -67,6 +70,38 @@ func TestGeoadd(t *testing.T) {
 	})
 }
 
+func TestGeoaddOptions(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("GEOADD", "Sicily", "13.361389", "38.115556", "Palermo")
+		c.Do("GEOADD", "Sicily", "NX", "13.4", "38.2", "Palermo")
+		c.Do("GEOADD", "Sicily", "NX", "15.087269", "37.502669", "Catania")
+		c.Do("GEOADD", "Sicily", "XX", "13.4", "38.2", "Trapani")
+		c.Do("GEOADD", "Sicily", "XX", "13.4", "38.2", "Palermo")
+		c.Do("GEOADD", "Sicily", "XX", "CH", "13.5", "38.2", "Palermo")
+		c.Do("GEOADD", "Sicily", "ch", "13.5", "38.2", "Palermo")
+		c.Do("ZRANGE", "Sicily", "0", "-1", "WITHSCORES")
+	})
+}
+
+func TestGeohash(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("GEOADD",
+			"Sicily",
+			"13.361389", "38.115556", "Palermo",
+			"15.087269", "37.502669", "Catania",
+		)
+		c.Do("GEOHASH", "Sicily")
+		c.Do("GEOHASH", "Sicily", "Palermo")
+		c.Do("GEOHASH", "Sicily", "Palermo", "nosuch", "Catania")
+		c.Do("GEOHASH", "nosuch", "Palermo")
+
+		// failure cases
+		c.Error("wrong number", "GEOHASH")
+		c.Do("SET", "foo", "bar")
+		c.Error("wrong kind", "GEOHASH", "foo", "Palermo")
+	})
+}
+
 func TestGeopos(t *testing.T) {
 	testRaw(t, func(c *client) {
 		c.Do("GEOADD",
@@ -150,6 +185,7 @@ func TestGeoradius(t *testing.T) {
 			c.DoRounded(3, "GEORADIUS", "stations", "-73.9718893", "40.7728773", "400", "km", "ASC", "COUNT", "999")
 			c.Error("syntax error", "GEORADIUS", "stations", "-73.9718893", "40.7728773", "400", "km", "COUNT")
 			c.Error("COUNT must", "GEORADIUS", "stations", "-73.9718893", "40.7728773", "400", "km", "COUNT", "0")
+			c.Error("requires COUNT", "GEORADIUS", "stations", "-73.9718893", "40.7728773", "400", "km", "ANY")
 			c.Error("COUNT must", "GEORADIUS", "stations", "-73.9718893", "40.7728773", "400", "km", "COUNT", "-12")
 			c.Error("not an integer", "GEORADIUS", "stations", "-73.9718893", "40.7728773", "400", "km", "COUNT", "foobar")
 
@@ -930,3 +966,71 @@ func TestGeo(t *testing.T) {
 		c.DoLoosely("ZRANGE", "resbymemd", "0", "-1", "WITHSCORES")
 	})
 }
+
+func TestGeoradiusOptions(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("GEOADD",
+			"Sicily",
+			"13.361389", "38.115556", "Palermo",
+			"15.087269", "37.502669", "Catania",
+		)
+		c.Do("GEORADIUS", "Sicily", "15", "37", "200", "km", "WITHHASH", "ASC")
+		c.Do("GEORADIUS", "Sicily", "15", "37", "200", "km", "WITHHASH", "WITHDIST", "ASC")
+		c.Do("GEORADIUS", "Sicily", "15", "37", "200", "km", "COUNT", "1")
+		c.Do("GEORADIUS", "Sicily", "15", "37", "200", "km", "COUNT", "1", "DESC")
+		c.Do("GEORADIUSBYMEMBER", "Sicily", "Palermo", "200", "km", "COUNT", "1")
+		c.Do("GEORADIUSBYMEMBER", "Sicily", "Palermo", "200", "km", "WITHHASH", "ASC")
+		c.Do("GEORADIUS", "Sicily", "15", "37", "200", "km", "STORE", "foo", "STOREDIST", "foo")
+		c.DoLoosely("ZRANGE", "foo", "0", "-1", "WITHSCORES")
+
+		c.Error("not compatible", "GEORADIUS", "Sicily", "15", "37", "200", "km", "WITHHASH", "STORE", "foo")
+		c.Error("invalid longitude", "GEORADIUS", "Sicily", "200", "37", "200", "km")
+		c.Do("SET", "str", "value")
+		c.Error("wrong kind", "GEORADIUS", "str", "15", "37", "200", "km")
+		c.Error("wrong kind", "GEORADIUSBYMEMBER", "str", "Palermo", "200", "km")
+	})
+}
+
+func TestGeosearch(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("GEOADD",
+			"Sicily",
+			"13.361389", "38.115556", "Palermo",
+			"15.087269", "37.502669", "Catania",
+			"12.758489", "38.788135", "edge1",
+			"17.241510", "38.788135", "edge2",
+		)
+		c.DoSorted("GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km")
+		c.Do("GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC")
+		c.Do("GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "200", "km", "DESC", "WITHDIST")
+		c.DoRounded(3, "GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "ASC", "WITHCOORD", "WITHDIST", "WITHHASH")
+		c.Do("GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "200", "200", "km")
+		c.Do("GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "400", "km", "COUNT", "2")
+		c.Do("GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "400", "km", "COUNT", "1", "DESC")
+		c.Do("GEOSEARCH", "nosuch", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km")
+
+		c.Do("GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "200", "200", "km")
+		c.Do("ZRANGE", "dst", "0", "-1", "WITHSCORES")
+		c.Do("GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC", "STOREDIST")
+		c.DoLoosely("ZRANGE", "dst", "0", "-1", "WITHSCORES")
+		c.Do("GEOSEARCHSTORE", "dst", "nosuch", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km")
+		c.Do("EXISTS", "dst")
+
+		// failure cases
+		c.Error("wrong number", "GEOSEARCH")
+		c.Error("exactly one of", "GEOSEARCH", "Sicily", "BYRADIUS", "200", "km")
+		c.Error("exactly one of", "GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo")
+		c.Error("exactly one of", "GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km")
+		c.Error("could not decode", "GEOSEARCH", "Sicily", "FROMMEMBER", "nosuch", "BYRADIUS", "200", "km")
+		c.Error("invalid longitude", "GEOSEARCH", "Sicily", "FROMLONLAT", "200", "37", "BYRADIUS", "200", "km")
+		c.Error("negative", "GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "-1", "km")
+		c.Error("negative", "GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "1", "-1", "km")
+		c.Error("unsupported unit", "GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "mm")
+		c.Error("COUNT must", "GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "COUNT", "0")
+		c.Error("requires COUNT", "GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "ANY")
+		c.Error("syntax error", "GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "STOREDIST")
+		c.Error("syntax error", "GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km", "WITHDIST")
+		c.Do("SET", "str", "value")
+		c.Error("wrong kind", "GEOSEARCH", "str", "FROMLONLAT", "15", "37", "BYRADIUS", "1", "km")
+	})
+}
@@ -174,6 +174,15 @@ func TestHashIncr(t *testing.T) {
 		c.Do("SET", "str", "value")
 		c.Error("wrong kind","HINCRBYFLOAT", "str", "value", "12")
 		c.Do("HINCRBYFLOAT", "aap", "noot", "12")
+
+		c.Do("HSET", "aap", "str", "value")
+		c.Error("hash value is not a float", "HINCRBYFLOAT", "aap", "str", "12")
+		c.Error("NaN or Infinity", "HINCRBYFLOAT", "aap", "inf", "inf")
+		c.Do("HSET", "aap", "inf", "-inf")
+		c.Error("NaN or Infinity", "HINCRBYFLOAT", "aap", "inf", "1")
+		c.Do("HINCRBYFLOAT", "aap", "e", "12e12")
+		c.Do("HINCRBYFLOAT", "aap", "e", "-1.5")
+		c.Error("not a valid float", "HINCRBYFLOAT", "aap", "e", "12e34.1")
 	})
 }
 
@@ -225,3 +234,52 @@ func TestHstrlen(t *testing.T) {
 		c.Error("wrong kind","HSTRLEN", "str", "bar")
 	})
 }
+
+func TestHashExpire(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("HSET", "session", "user", "alice", "token", "abc")
+		c.Do("HEXPIRE", "session", "100", "FIELDS", "2", "token", "nosuch")
+		c.Do("HTTL", "session", "FIELDS", "3", "token", "user", "nosuch")
+		c.Do("HTTL", "nosuch", "FIELDS", "1", "token")
+		c.Do("HPEXPIRE", "session", "200000", "NX", "FIELDS", "2", "user", "token")
+		c.Do("HPEXPIRE", "session", "200000", "GT", "FIELDS", "1", "token")
+		c.Do("HEXPIRE", "session", "10", "LT", "FIELDS", "1", "user")
+		c.Do("HPERSIST", "session", "FIELDS", "2", "user", "nosuch")
+		c.Do("HPERSIST", "session", "FIELDS", "1", "user")
+		c.Do("OBJECT", "ENCODING", "session")
+		c.Do("HEXPIRE", "session", "0", "FIELDS", "1", "user")
+		c.Do("HEXPIREAT", "session", "1", "FIELDS", "1", "token")
+		c.Do("EXISTS", "session")
+
+		c.Error("wrong number", "HEXPIRE", "session", "10", "FIELDS", "1")
+		c.Error("not an integer", "HEXPIRE", "session", "foo", "FIELDS", "1", "a")
+		c.Error("FIELDS is missing", "HEXPIRE", "session", "10", "FOO", "1", "a")
+		c.Error("greater than 0", "HEXPIRE", "session", "10", "FIELDS", "0", "a")
+		c.Error("must match", "HEXPIRE", "session", "10", "FIELDS", "2", "a")
+		c.Do("SET", "str", "1")
+		c.Error("wrong kind", "HTTL", "str", "FIELDS", "1", "a")
+	})
+}
+
+func TestHashGetdelGetex(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("HSET", "h", "a", "1", "b", "2", "c", "3")
+		c.Do("HGETDEL", "h", "FIELDS", "2", "a", "nosuch")
+		c.Do("HGETDEL", "nosuch", "FIELDS", "1", "a")
+		c.Do("HGETEX", "h", "FIELDS", "2", "b", "nosuch")
+		c.Do("HGETEX", "h", "EX", "100", "FIELDS", "1", "b")
+		c.Do("HTTL", "h", "FIELDS", "1", "b")
+		c.Do("HGETEX", "h", "PERSIST", "FIELDS", "1", "b")
+		c.Do("HTTL", "h", "FIELDS", "1", "b")
+		c.Do("HGETEX", "h", "PXAT", "1000", "FIELDS", "1", "b")
+		c.Do("HGETALL", "h")
+		c.Do("HGETDEL", "h", "FIELDS", "1", "c")
+		c.Do("EXISTS", "h")
+
+		c.Error("wrong number", "HGETDEL", "h", "FIELDS", "1")
+		c.Error("not an integer", "HGETEX", "h", "EX", "foo", "FIELDS", "1", "a")
+		c.Do("SET", "str", "1")
+		c.Error("wrong kind", "HGETDEL", "str", "FIELDS", "1", "a")
+		c.Error("wrong kind", "HGETEX", "str", "FIELDS", "1", "a")
+	})
+}
@@ -0,0 +1,73 @@
+// +build int
+
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPfadd(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("PFADD", "hll", "foo", "bar", "zap")
+		c.Do("PFADD", "hll", "zap", "zap", "zap")
+		c.Do("PFADD", "hll", "foo", "bar")
+		c.Do("PFADD", "empty")
+		c.Do("PFADD", "empty")
+		c.Do("TYPE", "hll")
+		c.Do("GET", "hll")
+		c.Do("GET", "empty")
+
+		c.Error("wrong number", "PFADD")
+		c.Do("SET", "str", "value")
+		c.Error("not a valid HyperLogLog", "PFADD", "str", "foo")
+		c.Do("LPUSH", "list", "value")
+		c.Error("wrong kind", "PFADD", "list", "foo")
+	})
+}
+
+func TestPfcount(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("PFADD", "hll", "foo", "bar", "zap")
+		c.Do("PFADD", "other", "1", "2", "3")
+		c.Do("PFCOUNT", "hll")
+		c.Do("GET", "hll")
+		c.Do("PFCOUNT", "hll", "other", "nosuch")
+		c.Do("PFCOUNT", "nosuch")
+
+		c.Error("wrong number", "PFCOUNT")
+		c.Do("SET", "str", "value")
+		c.Error("not a valid HyperLogLog", "PFCOUNT", "str")
+		c.Error("not a valid HyperLogLog", "PFCOUNT", "hll", "str")
+	})
+
+	// sparse to dense
+	testRaw(t, func(c *client) {
+		args := []string{"big"}
+		for i := 0; i < 5000; i++ {
+			args = append(args, strconv.Itoa(i))
+		}
+		c.Do("PFADD", args...)
+		c.Do("PFCOUNT", "big")
+		c.Do("GET", "big")
+		c.Do("STRLEN", "big")
+	})
+}
+
+func TestPfmerge(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("PFADD", "h1", "foo", "bar", "zap", "a")
+		c.Do("PFADD", "h2", "a", "b", "c", "foo")
+		c.Do("PFMERGE", "h3", "h1", "h2", "nosuch")
+		c.Do("PFCOUNT", "h3")
+		c.Do("GET", "h3")
+		c.Do("PFMERGE", "h1", "h2")
+		c.Do("PFCOUNT", "h1")
+		c.Do("PFMERGE", "empty")
+		c.Do("GET", "empty")
+
+		c.Error("wrong number", "PFMERGE")
+		c.Do("SET", "str", "value")
+		c.Error("not a valid HyperLogLog", "PFMERGE", "h1", "str")
+	})
+}
@@ -28,6 +28,12 @@ func TestLPush(t *testing.T) {
 		c.Do("LPOP", "l")
 		c.Do("EXISTS", "l")
 		c.Do("LPOP", "nosuch")
+		c.Do("LPOP", "nosuch", "2")
+		c.Do("LPUSH", "l", "aap", "noot", "mies")
+		c.Do("LPOP", "l", "2")
+		c.Do("LPOP", "l", "0")
+		c.Do("LPOP", "l", "99")
+		c.Do("EXISTS", "l")
 
 		// failure cases
 		c.Error("wrong number", "LPUSH")
@@ -41,7 +47,9 @@ func TestLPush(t *testing.T) {
 		c.Error("not an integer", "LRANGE", "key", "noint", "6")
 		c.Error("not an integer", "LRANGE", "key", "2", "noint")
 		c.Error("wrong number", "LPOP")
-		c.Error("wrong number", "LPOP", "key", "args")
+		c.Error("out of range", "LPOP", "key", "args")
+		c.Error("out of range", "LPOP", "key", "-1")
+		c.Error("wrong number", "LPOP", "key", "1", "toomany")
 	})
 }
 
@@ -80,6 +88,12 @@ func TestRPush(t *testing.T) {
 		c.Do("RPOP", "l")
 		c.Do("EXISTS", "l")
 		c.Do("RPOP", "nosuch")
+		c.Do("RPOP", "nosuch", "2")
+		c.Do("RPUSH", "l", "aap", "noot", "mies")
+		c.Do("RPOP", "l", "2")
+		c.Do("RPOP", "l", "0")
+		c.Do("RPOP", "l", "99")
+		c.Do("EXISTS", "l")
 
 		// failure cases
 		c.Error("wrong number", "RPUSH")
@@ -87,7 +101,9 @@ func TestRPush(t *testing.T) {
 		c.Do("SET", "str", "I am a string")
 		c.Error("wrong kind", "RPUSH", "str", "noot", "mies")
 		c.Error("wrong number", "RPOP")
-		c.Error("wrong number", "RPOP", "key", "args")
+		c.Error("out of range", "RPOP", "key", "args")
+		c.Error("out of range", "RPOP", "key", "-1")
+		c.Error("wrong number", "RPOP", "key", "1", "toomany")
 	})
 }
 
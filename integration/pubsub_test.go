@@ -95,6 +95,17 @@ func TestPsubscribe(t *testing.T) {
 		c1.Receive()
 	})
 
+	testRaw2(t, func(c1, c2 *client) {
+		// once for the subscription, and once for the psubscription
+		c1.Do("SUBSCRIBE", "news.art")
+		c1.Do("PSUBSCRIBE", "n[a-f]ws.*")
+		c2.Do("PUBLISH", "news.art", "fire!")
+		c1.Receive()
+		c1.Receive()
+		c2.Do("PUBLISH", "news.art.x", "fire!")
+		c1.Receive()
+	})
+
 	testRaw2(t, func(c1, c2 *client) {
 		c1.Do("PSUBSCRIBE", "news") // no pattern
 		c2.Do("PUBLISH", "news", "fire!")
@@ -446,3 +457,70 @@ func TestPubsubTx(t *testing.T) {
 		c1.Do("PUBSUB", "NUMPAT")
 	})
 }
+
+func TestSsubscribe(t *testing.T) {
+	testRaw2(t, func(c1, c2 *client) {
+		c1.Error("wrong number", "SSUBSCRIBE")
+		c2.Error("wrong number", "SPUBLISH", "foo")
+
+		c1.Do("SSUBSCRIBE", "foo")
+		c2.Do("SPUBLISH", "foo", "hi")
+		c1.Receive()
+		c2.Do("PUBLISH", "foo", "not for shards")
+		c2.Do("PUBSUB", "SHARDCHANNELS")
+		c2.Do("PUBSUB", "SHARDCHANNELS", "f*")
+		c2.Do("PUBSUB", "SHARDNUMSUB", "foo", "bar")
+		c2.Do("PUBSUB", "CHANNELS")
+		c1.Do("SUNSUBSCRIBE")
+		c1.Do("SUNSUBSCRIBE")
+
+		c1.Do("SSUBSCRIBE", "foo")
+		c1.Do("SUBSCRIBE", "bar")
+		c1.Do("SUNSUBSCRIBE", "foo")
+		c1.Error("are allowed", "GET", "foo")
+		c1.Do("UNSUBSCRIBE", "bar")
+		c1.Do("GET", "foo")
+	})
+}
+
+func TestKeyspaceEvents(t *testing.T) {
+	testRaw2(t, func(c1, c2 *client) {
+		c2.Do("CONFIG", "SET", "notify-keyspace-events", "KEA")
+		c2.Do("CONFIG", "GET", "notify-keyspace-events")
+		c2.Error("Invalid event class", "CONFIG", "SET", "notify-keyspace-events", "Kq")
+
+		c1.Do("PSUBSCRIBE", "__keyevent@*")
+
+		c2.Do("SET", "foo", "bar")
+		c1.Receive()
+		c2.Do("EXPIRE", "foo", "100")
+		c1.Receive()
+		c2.Do("DEL", "foo")
+		c1.Receive()
+
+		c2.Do("RPUSH", "l", "a", "b")
+		c1.Receive()
+		c2.Do("RPOPLPUSH", "l", "l2")
+		c1.Receive()
+		c1.Receive()
+
+		c2.Do("SADD", "s", "a")
+		c1.Receive()
+		c2.Do("SREM", "s", "a")
+		c1.Receive()
+		c1.Receive()
+
+		c2.Do("ZADD", "z", "1", "one")
+		c1.Receive()
+		c2.Do("ZPOPMAX", "z")
+		c1.Receive()
+		c1.Receive()
+
+		c2.Do("HSET", "h", "f", "v")
+		c1.Receive()
+		c2.Do("HINCRBY", "h", "n", "1")
+		c1.Receive()
+
+		c2.Do("CONFIG", "SET", "notify-keyspace-events", "")
+	})
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -79,6 +80,69 @@ func TestEvalsha(t *testing.T) {
 		c.Error("wrong number", "EVALSHA", "nosuch")
 		c.Error("Please use EVAL", "EVALSHA", "nosuch", "0")
 	})
+
+	// EVAL caches the script
+	testRaw(t, func(c *client) {
+		c.Do("SCRIPT", "FLUSH", "ASYNC")
+		c.Error("Please use EVAL", "EVALSHA", sha1, "0")
+		c.Do("EVAL", "return 42", "0")
+		c.Do("SCRIPT", "EXISTS", sha1)
+		c.Do("EVALSHA", sha1, "0")
+		c.Do("EVALSHA", strings.ToUpper(sha1), "0")
+		c.Do("SCRIPT", "FLUSH", "SYNC")
+		c.Do("SCRIPT", "EXISTS", sha1)
+	})
+}
+
+func TestEvalRO(t *testing.T) {
+	sha := "1fa00e76656cc152ad327c13fe365858fd7be306" // return 42
+
+	testRaw(t, func(c *client) {
+		c.Do("SET", "foo", "bar")
+		c.Do("EVAL_RO", "return redis.call('GET', KEYS[1])", "1", "foo")
+		c.Error("Write commands are not allowed", "EVAL_RO", "return redis.call('SET', KEYS[1], 'baz')", "1", "foo")
+		c.Error("Write commands are not allowed", "EVAL_RO", "return redis.pcall('DEL', KEYS[1])", "1", "foo")
+		c.Do("GET", "foo")
+
+		c.Do("SCRIPT", "LOAD", "return 42")
+		c.Do("EVALSHA_RO", sha, "0")
+		c.Error("wrong number", "EVAL_RO", "return 42")
+		c.Error("wrong number", "EVALSHA_RO", sha)
+	})
+}
+
+func TestFunction(t *testing.T) {
+	lib := "#!lua name=mylib\n" +
+		"redis.register_function('myget', function(keys, args) return redis.call('GET', keys[1]) end)\n" +
+		"redis.register_function{function_name='myset', callback=function(keys, args) return redis.call('SET', keys[1], args[1]) end}\n" +
+		"redis.register_function{function_name='myro', callback=function(keys, args) return redis.call('GET', keys[1]) end, flags={'no-writes'}}\n"
+
+	testRaw(t, func(c *client) {
+		c.Do("FUNCTION", "FLUSH")
+		c.Do("FUNCTION", "LOAD", lib)
+		c.Error("already exists", "FUNCTION", "LOAD", lib)
+		c.Do("FUNCTION", "LOAD", "REPLACE", lib)
+		c.Do("FCALL", "myset", "1", "foo", "bar")
+		c.Do("FCALL", "myget", "1", "foo")
+		c.Do("FCALL_RO", "myro", "1", "foo")
+		c.Error("write flag", "FCALL_RO", "myset", "1", "foo", "bar")
+		c.Error("not found", "FCALL", "nosuch", "0")
+		c.Error("wrong number", "FCALL", "myget")
+		c.Do("FUNCTION", "LIST", "LIBRARYNAME", "nosuch")
+		c.Do("FUNCTION", "STATS")
+		c.Do("FUNCTION", "DELETE", "mylib")
+		c.Error("not found", "FUNCTION", "DELETE", "mylib")
+		c.Error("metadata", "FUNCTION", "LOAD", "return 1")
+		c.Error("No functions", "FUNCTION", "LOAD", "#!lua name=foo\nreturn 1")
+		c.Do("FUNCTION", "FLUSH")
+	})
+}
+
+func TestScriptKill(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Error("NOTBUSY", "SCRIPT", "KILL")
+		c.Error("NOTBUSY", "FUNCTION", "KILL")
+	})
 }
 
 func TestLua(t *testing.T) {
@@ -197,6 +261,20 @@ func TestLua(t *testing.T) {
 		)
 	})
 
+	// bit, struct, and cmsgpack modules
+	testRaw(t, func(c *client) {
+		c.Do("EVAL", `return bit.tobit(0xffffffff)`, "0")
+		c.Do("EVAL", `return bit.band(0x12345678, 0xff)`, "0")
+		c.Do("EVAL", `return bit.rol(0x12345678, 12)`, "0")
+		c.Do("EVAL", `return bit.tohex(-1, -4)`, "0")
+		c.Do("EVAL", `return struct.pack('>HI2b', 258, 3, -1)`, "0")
+		c.Do("EVAL", `return {struct.unpack('>HI2b', ARGV[1])}`, "0", "\x01\x02\x00\x03\xff")
+		c.Do("EVAL", `return struct.size('!4bi')`, "0")
+		c.Do("EVAL", `return cmsgpack.pack(1, -1, 200, 'foo', {1, 2}, {foo='bar'})`, "0")
+		c.Do("EVAL", `return {cmsgpack.unpack(cmsgpack.pack(1, 'foo', {1, 2}))}`, "0")
+		c.Do("EVAL", `return {cmsgpack.unpack_limit(cmsgpack.pack(1, 2, 3), 2)}`, "0")
+	})
+
 	// selected DB gets passed on to lua
 	testRaw(t, func(c *client) {
 		c.Do("SELECT", "3")
@@ -340,6 +418,19 @@ func TestLuaCall(t *testing.T) {
 		c.Do("EVAL", `redis.pcall("EXEC")`, "0")
 		c.Do("GET", "foo")
 	})
+
+	// reply conversions
+	testRaw(t, func(c *client) {
+		c.Do("SET", "foo", "bar")
+		c.Do("EVAL", `return redis.call("SET", "foo", "bar").ok`, "0")
+		c.Do("EVAL", `return "OK"`, "0")
+		c.Do("EVAL", `return redis.call("PING")`, "0")
+		c.Do("EVAL", `return type(redis.pcall("INCR", "foo"))`, "0")
+		c.Do("EVAL", `return redis.pcall("INCR", "foo").err`, "0")
+		c.Error("not an integer", "EVAL", `return redis.pcall("INCR", "foo")`, "0")
+		c.Do("EVAL", `redis.log(redis.LOG_WARNING, "hello")`, "0")
+		c.Do("EVAL", `return {redis.LOG_DEBUG, redis.LOG_VERBOSE, redis.LOG_NOTICE, redis.LOG_WARNING}`, "0")
+	})
 }
 
 func TestScriptNoAuth(t *testing.T) {
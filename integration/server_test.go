@@ -38,6 +38,23 @@ func TestServer(t *testing.T) {
 	})
 }
 
+func TestServerInfo(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("SET", "foo", "bar")
+		c.DoLoosely("INFO")
+		c.DoLoosely("INFO", "keyspace")
+		c.DoLoosely("INFO", "KEYSPACE", "server")
+		c.DoLoosely("INFO", "all")
+		c.Do("INFO", "nosuch")
+
+		c.Do("CONFIG", "RESETSTAT")
+		c.Do("GET", "foo")
+		c.DoLoosely("INFO", "commandstats")
+		c.DoLoosely("INFO", "latencystats")
+		c.Error("Unknown subcommand", "CONFIG", "RESETSTAT", "foo")
+	})
+}
+
 func TestServerTLS(t *testing.T) {
 	testTLS(t, func(c *client) {
 		c.Do("PING", "foo")
@@ -46,3 +63,40 @@ func TestServerTLS(t *testing.T) {
 		c.Do("GET", "foo")
 	})
 }
+
+func TestServerConfig(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("CONFIG", "GET", "hash-max-listpack-entries")
+		c.Do("CONFIG", "GET", "hash-max-ziplist-entries")
+		c.DoSorted("CONFIG", "GET", "zset-max-*")
+		c.Do("CONFIG", "GET", "nosuch")
+		c.Do("CONFIG", "GET", "databases")
+		c.Do("CONFIG", "GET", "maxmemory")
+		c.Do("CONFIG", "GET", "timeout")
+		c.DoLoosely("CONFIG", "GET", "save")
+		c.Do("CONFIG", "SET", "maxmemory", "10mb")
+		c.Do("CONFIG", "GET", "maxmemory")
+		c.Do("CONFIG", "SET", "maxmemory", "0")
+
+		c.Do("HSET", "hash", "aap", "noot", "mies", "vuur")
+		c.Do("OBJECT", "ENCODING", "hash")
+		c.Do("CONFIG", "SET", "hash-max-listpack-entries", "1")
+		c.Do("OBJECT", "ENCODING", "hash")
+		c.Do("CONFIG", "SET", "hash-max-listpack-entries", "128")
+
+		c.Do("SADD", "set", "1", "2", "3")
+		c.Do("CONFIG", "SET", "set-max-intset-entries", "2")
+		c.Do("OBJECT", "ENCODING", "set")
+		c.Do("CONFIG", "SET", "set-max-intset-entries", "512")
+
+		c.Error("wrong number", "CONFIG")
+		c.Error("wrong number", "CONFIG", "GET")
+		c.Error("wrong number", "CONFIG", "SET", "hash-max-listpack-entries")
+		c.Error("Unknown option", "CONFIG", "SET", "nosuch", "1")
+		c.Error("integer", "CONFIG", "SET", "hash-max-listpack-entries", "foo")
+		c.Error("must be one of", "CONFIG", "SET", "maxmemory-policy", "foo")
+		c.Error("memory value", "CONFIG", "SET", "maxmemory", "foo")
+		c.Error("immutable", "CONFIG", "SET", "databases", "8")
+		c.Error("Invalid save", "CONFIG", "SET", "save", "900")
+	})
+}
@@ -22,6 +22,8 @@ func TestSet(t *testing.T) {
 
 		c.Do("SCARD", "nosuch")
 		c.Do("SISMEMBER", "nosuch", "nosuch")
+		c.Do("SMISMEMBER", "s", "aap", "nosuch", "noot")
+		c.Do("SMISMEMBER", "nosuch", "aap")
 
 		// failure cases
 		c.Error("wrong number", "SADD")
@@ -33,11 +35,14 @@ func TestSet(t *testing.T) {
 		c.Error("wrong number", "SISMEMBER")
 		c.Error("wrong number", "SISMEMBER", "few")
 		c.Error("wrong number", "SISMEMBER", "too", "many", "arguments")
+		c.Error("wrong number", "SMISMEMBER")
+		c.Error("wrong number", "SMISMEMBER", "few")
 		// Wrong type
 		c.Do("SET", "str", "I am a string")
 		c.Error("wrong kind", "SADD", "str", "noot", "mies")
 		c.Error("wrong kind", "SMEMBERS", "str")
 		c.Error("wrong kind", "SISMEMBER", "str", "noot")
+		c.Error("wrong kind", "SMISMEMBER", "str", "noot")
 		c.Error("wrong kind", "SCARD", "str")
 	})
 
@@ -178,6 +183,13 @@ func TestSetSrandmember(t *testing.T) {
 
 		c.Do("SRANDMEMBER", "s", "0")
 		c.Do("SPOP", "nosuch")
+		c.Do("SRANDMEMBER", "nosuch")
+		c.Do("SRANDMEMBER", "nosuch", "2")
+		c.Do("SRANDMEMBER", "nosuch", "-2")
+
+		c.Do("SADD", "more", "aap", "noot", "mies")
+		c.DoSorted("SRANDMEMBER", "more", "3")
+		c.DoSorted("SRANDMEMBER", "more", "10")
 
 		// failure cases
 		c.Error("wrong number", "SRANDMEMBER")
@@ -316,6 +328,7 @@ func TestSscan(t *testing.T) {
 		c.Error("wrong number", "SSCAN")
 		c.Error("wrong number", "SSCAN", "noint")
 		c.Error("not an integer", "SSCAN", "set", "0", "COUNT", "noint")
+		c.Error("syntax error", "SSCAN", "set", "0", "COUNT", "0")
 		c.Error("syntax error", "SSCAN", "set", "0", "COUNT")
 		c.Error("syntax error", "SSCAN", "set", "0", "MATCH")
 		c.Error("syntax error", "SSCAN", "set", "0", "garbage")
@@ -195,6 +195,17 @@ func TestSortedSetRange(t *testing.T) {
 		)
 		c.Do("ZRANGE", "zz", "0", "-1")
 
+		// Redis 6.2 syntax
+		c.Do("ZRANGE", "z", "0", "2", "REV")
+		c.Do("ZRANGE", "z", "0", "2", "REV", "WITHSCORES")
+		c.Do("ZRANGE", "z", "(1", "3", "BYSCORE")
+		c.Do("ZRANGE", "z", "(1", "5", "BYSCORE", "LIMIT", "0", "10", "REV")
+		c.Do("ZRANGE", "z", "5", "(1", "BYSCORE", "LIMIT", "1", "2", "REV", "WITHSCORES")
+		c.Do("ZRANGE", "z", "-inf", "+inf", "BYSCORE", "LIMIT", "2", "-1")
+		c.Do("ZRANGE", "zz", "[a", "+", "BYLEX")
+		c.Do("ZRANGE", "zz", "+", "-", "BYLEX", "REV", "LIMIT", "1", "2")
+		c.Do("ZRANGE", "nosuch", "0", "1", "BYSCORE")
+
 		// failure cases
 		c.Error("wrong number", "ZRANGE")
 		c.Error("wrong number", "ZRANGE", "foo")
@@ -203,6 +214,14 @@ func TestSortedSetRange(t *testing.T) {
 		c.Error("syntax error", "ZRANGE", "foo", "2", "3", "WITHSCORES", "toomany")
 		c.Error("not an integer", "ZRANGE", "foo", "noint", "3")
 		c.Error("not an integer", "ZRANGE", "foo", "2", "noint")
+		c.Error("only supported", "ZRANGE", "foo", "2", "3", "LIMIT", "1", "2")
+		c.Error("not supported", "ZRANGE", "foo", "[a", "[b", "BYLEX", "WITHSCORES")
+		c.Error("syntax error", "ZRANGE", "foo", "2", "3", "BYSCORE", "BYLEX")
+		c.Error("syntax error", "ZRANGE", "foo", "2", "3", "REV", "REV")
+		c.Error("syntax error", "ZRANGE", "foo", "2", "3", "BYSCORE", "LIMIT", "1")
+		c.Error("not a float", "ZRANGE", "foo", "a", "3", "BYSCORE")
+		c.Error("string range", "ZRANGE", "foo", "a", "b", "BYLEX")
+		c.Error("syntax error", "ZREVRANGE", "foo", "2", "3", "BYSCORE")
 		c.Do("SET", "str", "I am a string")
 		c.Error("wrong kind", "ZRANGE", "str", "300", "-110")
 
@@ -347,6 +366,8 @@ func TestSortedSetScore(t *testing.T) {
 		c.Do("ZSCORE", "z", "the stars")
 		c.Do("ZSCORE", "z", "nosuch")
 		c.Do("ZSCORE", "nosuch", "nosuch")
+		c.Do("ZMSCORE", "z", "mies", "nosuch", "the stars")
+		c.Do("ZMSCORE", "nosuch", "aap", "noot")
 
 		// failure cases
 		c.Error("wrong number", "ZSCORE")
@@ -354,6 +375,27 @@ func TestSortedSetScore(t *testing.T) {
 		c.Error("wrong number", "ZSCORE", "foo", "too", "many")
 		c.Do("SET", "str", "I am a string")
 		c.Error("wrong kind", "ZSCORE", "str", "member")
+		c.Error("wrong number", "ZMSCORE")
+		c.Error("wrong number", "ZMSCORE", "foo")
+		c.Error("wrong kind", "ZMSCORE", "str", "member")
+	})
+
+	testRaw(t, func(c *client) {
+		c.Do("ZADD", "f", "1.1", "a", "0.1", "b", "1e20", "c", "-inf", "d", "3.14159265359", "e")
+		c.Do("ZSCORE", "f", "a")
+		c.Do("ZSCORE", "f", "b")
+		c.Do("ZSCORE", "f", "c")
+		c.Do("ZSCORE", "f", "d")
+		c.Do("ZRANGE", "f", "0", "-1", "WITHSCORES")
+		c.Do("ZINCRBY", "f", "0.2", "b")
+	})
+
+	testRESP3(t, func(c *client) {
+		c.Do("ZADD", "f", "1.1", "a", "0.1", "b", "1e20", "c", "-inf", "d")
+		c.Do("ZSCORE", "f", "a")
+		c.Do("ZSCORE", "f", "d")
+		c.Do("ZMSCORE", "f", "b", "c", "nosuch")
+		c.Do("ZRANGE", "f", "0", "-1", "WITHSCORES")
 	})
 }
 
@@ -678,6 +720,182 @@ func TestZinterstore(t *testing.T) {
 	})
 }
 
+func TestZrangestore(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("ZADD", "z",
+			"1", "aap",
+			"2", "noot",
+			"3", "mies",
+			"2", "nootagain",
+			"+Inf", "the stars",
+		)
+		c.Do("ZRANGESTORE", "dst", "z", "0", "-1")
+		c.Do("ZRANGE", "dst", "0", "-1", "WITHSCORES")
+		c.Do("ZRANGESTORE", "dst", "z", "0", "1", "REV")
+		c.Do("ZRANGE", "dst", "0", "-1", "WITHSCORES")
+		c.Do("ZRANGESTORE", "dst", "z", "(1", "+inf", "BYSCORE", "LIMIT", "1", "2")
+		c.Do("ZRANGE", "dst", "0", "-1", "WITHSCORES")
+		c.Do("ZRANGESTORE", "dst", "z", "3", "2", "BYSCORE", "REV")
+		c.Do("ZRANGE", "dst", "0", "-1", "WITHSCORES")
+		c.Do("ZRANGESTORE", "dst", "z", "0", "-1", "BYSCORE", "LIMIT", "0", "-1")
+		c.Do("ZRANGE", "dst", "0", "-1", "WITHSCORES")
+
+		c.Do("ZADD", "lex", "0", "a", "0", "b", "0", "c", "0", "d")
+		c.Do("ZRANGESTORE", "dst", "lex", "[b", "+", "BYLEX")
+		c.Do("ZRANGE", "dst", "0", "-1", "WITHSCORES")
+		c.Do("ZRANGESTORE", "dst", "lex", "+", "(a", "BYLEX", "REV", "LIMIT", "1", "5")
+		c.Do("ZRANGE", "dst", "0", "-1", "WITHSCORES")
+
+		// empty results delete the destination
+		c.Do("ZRANGESTORE", "dst", "z", "10", "20")
+		c.Do("EXISTS", "dst")
+		c.Do("SET", "str", "value")
+		c.Do("ZRANGESTORE", "str", "nosuch", "0", "-1")
+		c.Do("EXISTS", "str")
+		// source is destination
+		c.Do("ZRANGESTORE", "z", "z", "0", "1")
+		c.Do("ZRANGE", "z", "0", "-1", "WITHSCORES")
+
+		// Error cases
+		c.Error("wrong number", "ZRANGESTORE")
+		c.Error("wrong number", "ZRANGESTORE", "dst", "z", "0")
+		c.Error("not an integer", "ZRANGESTORE", "dst", "z", "noint", "1")
+		c.Error("not a float", "ZRANGESTORE", "dst", "z", "noint", "1", "BYSCORE")
+		c.Error("string range", "ZRANGESTORE", "dst", "z", "a", "b", "BYLEX")
+		c.Error("syntax error", "ZRANGESTORE", "dst", "z", "0", "1", "WITHSCORES")
+		c.Error("syntax error", "ZRANGESTORE", "dst", "z", "0", "1", "BYSCORE", "BYLEX")
+		c.Error("syntax error", "ZRANGESTORE", "dst", "z", "0", "1", "BYSCORE", "LIMIT", "1")
+		c.Error("only supported", "ZRANGESTORE", "dst", "z", "0", "1", "LIMIT", "1", "1")
+		c.Error("not an integer", "ZRANGESTORE", "dst", "z", "0", "1", "BYSCORE", "LIMIT", "a", "1")
+		c.Do("SET", "str", "1")
+		c.Error("wrong kind", "ZRANGESTORE", "dst", "str", "0", "1")
+	})
+}
+
+func TestZrandmember(t *testing.T) {
+	testRaw(t, func(c *client) {
+		// Set with a single member...
+		c.Do("ZADD", "z", "1", "aap")
+		c.Do("ZRANDMEMBER", "z")
+		c.Do("ZRANDMEMBER", "z", "1")
+		c.Do("ZRANDMEMBER", "z", "5")
+		c.Do("ZRANDMEMBER", "z", "-1")
+		c.Do("ZRANDMEMBER", "z", "-5")
+		c.Do("ZRANDMEMBER", "z", "0")
+		c.Do("ZRANDMEMBER", "z", "-3", "WITHSCORES")
+		c.Do("ZRANDMEMBER", "z", "3", "withscores")
+		c.Do("ZRANDMEMBER", "nosuch")
+		c.Do("ZRANDMEMBER", "nosuch", "2")
+
+		c.Do("ZADD", "more", "1", "aap", "2", "noot", "3", "mies")
+		c.DoSorted("ZRANDMEMBER", "more", "3")
+		c.DoSorted("ZRANDMEMBER", "more", "10")
+
+		// failure cases
+		c.Error("wrong number", "ZRANDMEMBER")
+		c.Error("not an integer", "ZRANDMEMBER", "z", "noint")
+		c.Error("syntax error", "ZRANDMEMBER", "z", "1", "nowithscores")
+		c.Error("syntax error", "ZRANDMEMBER", "z", "1", "WITHSCORES", "toomany")
+		c.Do("SET", "str", "I am a string")
+		c.Error("wrong kind", "ZRANDMEMBER", "str")
+	})
+
+	testRESP3(t, func(c *client) {
+		c.Do("ZADD", "z", "1", "aap")
+		c.Do("ZRANDMEMBER", "z", "-2", "WITHSCORES")
+	})
+}
+
+func TestZunion(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("ZADD", "h1", "1.0", "key1")
+		c.Do("ZADD", "h1", "2.0", "key2")
+		c.Do("ZADD", "h2", "1.0", "key1")
+		c.Do("ZADD", "h2", "4.0", "key2")
+		c.Do("ZADD", "h2", "5.0", "key3")
+		c.Do("SADD", "s1", "key1", "key4")
+		c.Do("ZUNION", "2", "h1", "h2")
+		c.Do("ZUNION", "2", "h1", "h2", "WITHSCORES")
+		c.Do("ZUNION", "2", "h1", "h2", "WEIGHTS", "2.0", "12", "WITHSCORES")
+		c.Do("ZUNION", "2", "h1", "h2", "AGGREGATE", "min", "WITHSCORES")
+		c.Do("ZUNION", "2", "h1", "h2", "AGGREGATE", "max", "withscores")
+		c.Do("ZUNION", "3", "h1", "s1", "nosuch", "WITHSCORES")
+		c.Do("ZUNION", "1", "nosuch")
+
+		// Error cases
+		c.Error("wrong number", "ZUNION")
+		c.Error("wrong number", "ZUNION", "1")
+		c.Error("not an integer", "ZUNION", "noint", "h1")
+		c.Error("at least 1", "ZUNION", "0", "f")
+		c.Error("syntax error", "ZUNION", "2", "f")
+		c.Error("syntax error", "ZUNION", "1", "f", "WEIGHTS")
+		c.Error("not a float", "ZUNION", "2", "f1", "f2", "WEIGHTS", "f", "2")
+		c.Error("syntax error", "ZUNION", "2", "f1", "f2", "AGGREGATE", "foo")
+		c.Error("syntax error", "ZUNIONSTORE", "dst", "1", "h1", "WITHSCORES")
+		c.Do("SET", "str", "1")
+		c.Error("wrong kind", "ZUNION", "1", "str")
+	})
+}
+
+func TestZinter(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("ZADD", "h1", "1.0", "key1")
+		c.Do("ZADD", "h1", "2.0", "key2")
+		c.Do("ZADD", "h1", "3.0", "key3")
+		c.Do("ZADD", "h2", "1.0", "key1")
+		c.Do("ZADD", "h2", "4.0", "key2")
+		c.Do("SADD", "s1", "key1", "key4")
+		c.Do("ZINTER", "2", "h1", "h2")
+		c.Do("ZINTER", "2", "h1", "h2", "WITHSCORES")
+		c.Do("ZINTER", "2", "h1", "h2", "WEIGHTS", "2.0", "12", "WITHSCORES")
+		c.Do("ZINTER", "2", "h1", "h2", "AGGREGATE", "min", "WITHSCORES")
+		c.Do("ZINTER", "2", "h1", "s1", "WITHSCORES")
+		c.Do("ZINTER", "2", "h1", "nosuch")
+
+		// empty results delete the destination
+		c.Do("SET", "dst", "value")
+		c.Do("ZINTERSTORE", "dst", "2", "h1", "nosuch")
+		c.Do("EXISTS", "dst")
+
+		// Error cases
+		c.Error("wrong number", "ZINTER")
+		c.Error("wrong number", "ZINTER", "1")
+		c.Error("at least 1", "ZINTER", "0", "f")
+		c.Error("syntax error", "ZINTER", "2", "f")
+		c.Error("syntax error", "ZINTER", "1", "f", "AGGREGATE")
+		c.Do("SET", "str", "1")
+		c.Error("wrong kind", "ZINTER", "1", "str")
+	})
+}
+
+func TestZintercard(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("ZADD", "h1", "1.0", "key1")
+		c.Do("ZADD", "h1", "2.0", "key2")
+		c.Do("ZADD", "h1", "3.0", "key3")
+		c.Do("ZADD", "h2", "1.0", "key1")
+		c.Do("ZADD", "h2", "4.0", "key2")
+		c.Do("SADD", "s1", "key1", "key4")
+		c.Do("ZINTERCARD", "2", "h1", "h2")
+		c.Do("ZINTERCARD", "2", "h1", "h2", "LIMIT", "1")
+		c.Do("ZINTERCARD", "2", "h1", "h2", "LIMIT", "0")
+		c.Do("ZINTERCARD", "2", "h1", "s1")
+		c.Do("ZINTERCARD", "2", "h1", "nosuch")
+
+		// Error cases
+		c.Error("wrong number", "ZINTERCARD")
+		c.Error("wrong number", "ZINTERCARD", "1")
+		c.Error("greater than 0", "ZINTERCARD", "0", "h1")
+		c.Error("greater than 0", "ZINTERCARD", "noint", "h1")
+		c.Error("greater than number", "ZINTERCARD", "3", "h1", "h2")
+		c.Error("can't be negative", "ZINTERCARD", "1", "h1", "LIMIT", "-1")
+		c.Error("syntax error", "ZINTERCARD", "1", "h1", "LIMIT")
+		c.Error("syntax error", "ZINTERCARD", "1", "h1", "foo")
+		c.Do("SET", "str", "1")
+		c.Error("wrong kind", "ZINTERCARD", "1", "str")
+	})
+}
+
 func TestZpopminmax(t *testing.T) {
 	testRaw(t, func(c *client) {
 		c.Do("ZADD", "set:zpop", "1.0", "key1")
@@ -707,3 +925,89 @@ func TestZpopminmax(t *testing.T) {
 		c.Error("syntax error", "ZPOPMIN", "set:zpop", "1", "h2")
 	})
 }
+
+func TestBzpopminmax(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("ZADD", "z", "1", "one", "2", "two", "3", "three")
+		c.Do("BZPOPMIN", "z", "1")
+		c.Do("BZPOPMAX", "nosuch", "z", "1")
+		c.Do("BZPOPMIN", "z", "0.1")
+		c.Do("BZPOPMIN", "z", "0.1")
+		c.Do("EXISTS", "z")
+
+		c.Error("wrong number", "BZPOPMIN")
+		c.Error("wrong number", "BZPOPMIN", "z")
+		c.Error("not a float", "BZPOPMIN", "z", "foo")
+		c.Error("negative", "BZPOPMAX", "z", "-1")
+		c.Do("SET", "str", "value")
+		c.Error("wrong kind", "BZPOPMIN", "str", "1")
+	})
+
+	testMulti(t,
+		func(c *client) {
+			c.Do("BZPOPMIN", "key", "1")
+			c.Do("BZPOPMAX", "key", "1")
+			c.Do("BZPOPMIN", "key", "1") // will timeout
+		},
+		func(c *client) {
+			c.Do("ZADD", "key", "1", "aap", "2", "noot")
+		},
+	)
+}
+
+func TestZmpop(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("ZADD", "z", "1", "one", "2", "two", "3", "three")
+		c.Do("ZMPOP", "2", "nosuch", "z", "MIN")
+		c.Do("ZMPOP", "1", "z", "MAX", "COUNT", "1")
+		c.Do("ZMPOP", "1", "z", "min", "count", "10")
+		c.Do("ZMPOP", "1", "z", "MIN")
+		c.Do("EXISTS", "z")
+
+		c.Error("wrong number", "ZMPOP")
+		c.Error("wrong number", "ZMPOP", "1", "z")
+		c.Error("greater than 0", "ZMPOP", "0", "z", "MIN")
+		c.Error("greater than 0", "ZMPOP", "foo", "z", "MIN")
+		c.Error("syntax error", "ZMPOP", "2", "z", "MIN")
+		c.Error("syntax error", "ZMPOP", "1", "z", "MIDDLE")
+		c.Error("greater than 0", "ZMPOP", "1", "z", "MIN", "COUNT", "0")
+		c.Error("syntax error", "ZMPOP", "1", "z", "MIN", "COUNT")
+		c.Error("syntax error", "ZMPOP", "1", "z", "MIN", "COUNT", "1", "COUNT", "1")
+		c.Do("SET", "str", "value")
+		c.Error("wrong kind", "ZMPOP", "1", "str", "MIN")
+	})
+
+	testRESP3(t, func(c *client) {
+		c.Do("ZADD", "z", "1", "one", "2", "two")
+		c.Do("ZMPOP", "1", "z", "MIN", "COUNT", "2")
+		c.Do("ZMPOP", "1", "z", "MIN")
+	})
+}
+
+func TestBzmpop(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("ZADD", "z", "1", "one", "2", "two")
+		c.Do("BZMPOP", "1", "1", "z", "MAX")
+		c.Do("BZMPOP", "0.1", "2", "nosuch", "z", "MIN", "COUNT", "5")
+		c.Do("BZMPOP", "0.1", "1", "z", "MIN")
+
+		c.Error("wrong number", "BZMPOP")
+		c.Error("wrong number", "BZMPOP", "1", "1", "z")
+		c.Error("not a float", "BZMPOP", "foo", "1", "z", "MIN")
+		c.Error("negative", "BZMPOP", "-1", "1", "z", "MIN")
+		c.Error("greater than 0", "BZMPOP", "1", "0", "z", "MIN")
+		c.Do("SET", "str", "value")
+		c.Error("wrong kind", "BZMPOP", "1", "1", "str", "MIN")
+	})
+
+	testMulti(t,
+		func(c *client) {
+			c.Do("BZMPOP", "1", "1", "key", "MIN")
+			c.Do("BZMPOP", "1", "1", "key", "MAX", "COUNT", "2")
+			c.Do("BZMPOP", "1", "1", "key", "MIN") // will timeout
+		},
+		func(c *client) {
+			c.Do("ZADD", "key", "1", "aap", "2", "noot", "3", "mies")
+		},
+	)
+}
@@ -32,6 +32,8 @@ func TestStream(t *testing.T) {
 				"name", "Earth",
 			)
 			c.Do("XLEN", "planets")
+			c.Do("TYPE", "planets")
+			c.Do("OBJECT", "ENCODING", "planets")
 			c.Do("RENAME", "planets", "planets2")
 			c.Do("DEL", "planets2")
 			c.Do("XLEN", "planets")
@@ -159,6 +161,27 @@ func TestStream(t *testing.T) {
 			c.Do("SET", "scalar", "foo")
 			c.Error("wrong kind", "XINFO", "STREAM", "scalar")
 		})
+
+		testRaw(t, func(c *client) {
+			c.Do("XADD", "planets", "0-1", "name", "Mercury")
+			c.Do("XINFO", "GROUPS", "planets")
+			c.Do("XGROUP", "CREATE", "planets", "processing", "0")
+			c.Do("XGROUP", "CREATE", "planets", "aaa", "$")
+			c.Do("XINFO", "GROUPS", "planets")
+			c.Do("XADD", "planets", "0-2", "name", "Venus")
+			c.Do("XADD", "planets", "0-3", "name", "Earth")
+			c.Do("XREADGROUP", "GROUP", "processing", "alice", "COUNT", "2", "STREAMS", "planets", ">")
+			c.Do("XINFO", "GROUPS", "planets")
+			c.Do("XACK", "planets", "processing", "0-1")
+			c.Do("XINFO", "GROUPS", "planets")
+			c.Do("XDEL", "planets", "0-3")
+			c.Do("XADD", "planets", "0-4", "name", "Mars")
+			c.Do("XINFO", "GROUPS", "planets")
+
+			c.Error("no such key", "XINFO", "GROUPS", "foo")
+			c.Do("SET", "scalar", "foo")
+			c.Error("wrong kind", "XINFO", "GROUPS", "scalar")
+		})
 	})
 
 	t.Run("XREAD", func(t *testing.T) {
@@ -241,6 +264,17 @@ func TestStream(t *testing.T) {
 			// timeout
 			c.Do("XREAD", "BLOCK", "10", "STREAMS", "pl", "70")
 
+			// only entries after the call
+			c.Do("XREAD", "STREAMS", "pl", "$")
+			wg.Add(1)
+			go func() {
+				c.Do("XREAD", "BLOCK", "1000", "STREAMS", "pl", "$")
+				wg.Done()
+			}()
+			time.Sleep(10 * time.Millisecond)
+			c2.Do("XADD", "pl", "61-1", "name", "Venus")
+			wg.Wait()
+
 			c.Error("not an int", "XREAD", "BLOCK", "foo", "STREAMS", "pl", "0")
 			c.Error("negative", "XREAD", "BLOCK", "-12", "STREAMS", "pl", "0")
 		})
@@ -301,6 +335,13 @@ func TestStreamRange(t *testing.T) {
 		c.Do("XREVRANGE", "ordplanets", "2-2", "2-1")
 		c.Do("XREVRANGE", "ordplanets", "1-0", "0")
 		c.Do("XREVRANGE", "ordplanets", "3-42", "1-0", "COUNT", "2")
+
+		c.Do("XRANGE", "ordplanets", "(1-0", "+")
+		c.Do("XRANGE", "ordplanets", "(2", "(3")
+		c.Do("XREVRANGE", "ordplanets", "(3", "(1-0")
+		c.Error("invalid start ID", "XRANGE", "ordplanets", "(18446744073709551615-18446744073709551615", "+")
+		c.Error("invalid end ID", "XRANGE", "ordplanets", "-", "(0-0")
+		c.Error("stream ID", "XRANGE", "ordplanets", "(-", "+")
 		c.Do("DEL", "ordplanets")
 
 		// failure cases
@@ -354,6 +395,23 @@ func TestStreamGroup(t *testing.T) {
 			c.Do("XGROUP", "CREATE", "planets", "processing", "$")
 			c.Error("already exist", "XGROUP", "CREATE", "planets", "processing", "$")
 		})
+
+		testRaw(t, func(c *client) {
+			c.Do("XADD", "planets", "123-500", "foo", "bar")
+			c.Do("XADD", "planets", "123-501", "foo", "baz")
+			c.Do("XGROUP", "CREATE", "planets", "processing", "$")
+			c.Do("XGROUP", "SETID", "planets", "processing", "123-500")
+			c.Do("XREADGROUP", "GROUP", "processing", "alice", "STREAMS", "planets", ">")
+			c.Do("XGROUP", "SETID", "planets", "processing", "0", "ENTRIESREAD", "0")
+			c.Do("XGROUP", "SETID", "planets", "processing", "$")
+			c.Do("XREADGROUP", "GROUP", "processing", "alice", "STREAMS", "planets", ">")
+
+			c.Error("to exist", "XGROUP", "SETID", "nosuch", "processing", "0")
+			c.Error("No such consumer group", "XGROUP", "SETID", "planets", "nosuch", "0")
+			c.Error("Invalid stream ID", "XGROUP", "SETID", "planets", "processing", "foo")
+			c.Error("wrong number", "XGROUP", "SETID", "planets", "processing")
+			c.Error("ENTRIESREAD", "XGROUP", "SETID", "planets", "processing", "0", "ENTRIESREAD", "-2")
+		})
 	})
 
 	t.Run("XREADGROUP", func(t *testing.T) {
@@ -17,6 +17,19 @@ func TestString(t *testing.T) {
 		c.Error("not an integer", "SET", "foo", "bar", "EX", "noint")
 		c.Do("SET", "utf8", "❆❅❄☃")
 		c.Do("SET", "foo", "baz", "KEEPTTL")
+		c.Do("TTL", "foo")
+		c.Do("SET", "foo", "baz")
+		c.Do("TTL", "foo")
+		c.Error("syntax error", "SET", "foo", "baz", "KEEPTTL", "EX", "10")
+		c.Error("syntax error", "SET", "foo", "baz", "PX", "10", "KEEPTTL")
+		c.Error("syntax error", "SET", "foo", "baz", "NX", "XX")
+		c.Error("syntax error", "SET", "foo", "baz", "EX", "10", "PX", "10")
+		c.Do("SET", "foo", "bar", "EXAT", "1000")
+		c.Do("EXISTS", "foo")
+		c.Do("SET", "foo", "bar", "PXAT", "99999999999999")
+		c.Do("EXISTS", "foo")
+		c.Error("syntax error", "SET", "foo", "baz", "EXAT", "1000", "KEEPTTL")
+		c.Error("invalid expire", "SET", "foo", "bar", "PXAT", "0")
 
 		// Failure cases
 		c.Error("wrong number", "SET")
@@ -40,6 +53,10 @@ func TestStringGetSet(t *testing.T) {
 		c.Do("GET", "new")
 		c.Do("GETSET", "nosuch", "new")
 		c.Do("GET", "nosuch")
+		// clears the TTL
+		c.Do("SET", "ttl", "bar", "EX", "100")
+		c.Do("GETSET", "ttl", "new")
+		c.Do("TTL", "ttl")
 
 		// Failure cases
 		c.Error("wrong number", "GETSET")
@@ -51,6 +68,75 @@ func TestStringGetSet(t *testing.T) {
 	})
 }
 
+func TestStringGetdel(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("SET", "foo", "bar")
+		c.Do("GETDEL", "foo")
+		c.Do("GET", "foo")
+		c.Do("GETDEL", "nosuch")
+
+		// Failure cases
+		c.Error("wrong number", "GETDEL")
+		c.Error("wrong number", "GETDEL", "foo", "bar")
+		// Wrong type
+		c.Do("HSET", "hash", "key", "value")
+		c.Error("wrong kind", "GETDEL", "hash")
+	})
+}
+
+func TestStringGetex(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("SET", "foo", "bar")
+		c.Do("GETEX", "foo")
+		c.Do("GETEX", "foo", "EX", "100")
+		c.Do("TTL", "foo")
+		c.Do("GETEX", "foo", "PX", "100000")
+		c.Do("TTL", "foo")
+		c.Do("GETEX", "foo", "PERSIST")
+		c.Do("TTL", "foo")
+		c.Do("GETEX", "foo", "EXAT", "1000")
+		c.Do("EXISTS", "foo")
+		c.Do("GETEX", "nosuch")
+		c.Do("GETEX", "nosuch", "EX", "100")
+
+		// Failure cases
+		c.Error("wrong number", "GETEX")
+		c.Error("syntax", "GETEX", "foo", "EX")
+		c.Error("syntax", "GETEX", "foo", "EX", "10", "PERSIST")
+		c.Error("syntax", "GETEX", "foo", "FOO")
+		c.Error("not an integer", "GETEX", "foo", "EX", "noint")
+		c.Error("invalid expire", "GETEX", "foo", "EX", "0")
+		// Wrong type
+		c.Do("HSET", "hash", "key", "value")
+		c.Error("wrong kind", "GETEX", "hash")
+	})
+}
+
+func TestStringLcs(t *testing.T) {
+	testRaw(t, func(c *client) {
+		c.Do("SET", "key1", "ohmytext")
+		c.Do("SET", "key2", "mynewtext")
+		c.Do("LCS", "key1", "key2")
+		c.Do("LCS", "key1", "key2", "LEN")
+		c.Do("LCS", "key1", "key2", "IDX")
+		c.Do("LCS", "key1", "key2", "IDX", "MINMATCHLEN", "4")
+		c.Do("LCS", "key1", "key2", "IDX", "MINMATCHLEN", "-4", "WITHMATCHLEN")
+		c.Do("LCS", "key1", "nosuch")
+		c.Do("LCS", "nosuch", "nosuch", "IDX")
+		c.Do("SET", "a", "aaaaaaaaaaaaaaaaaaaaaa")
+		c.Do("SET", "b", "abababababababababab")
+		c.Do("LCS", "a", "b", "IDX", "WITHMATCHLEN")
+
+		// Failure cases
+		c.Error("wrong number", "LCS", "key1")
+		c.Error("both the length and indexes", "LCS", "key1", "key2", "LEN", "IDX")
+		c.Error("syntax", "LCS", "key1", "key2", "FOO")
+		c.Error("not an integer", "LCS", "key1", "key2", "MINMATCHLEN", "foo")
+		c.Do("HSET", "hash", "key", "value")
+		c.Error("string values", "LCS", "key1", "hash")
+	})
+}
+
 func TestStringMget(t *testing.T) {
 	testRaw(t, func(c *client) {
 		c.Do("SET", "foo", "bar")
@@ -183,6 +269,13 @@ func TestGetrange(t *testing.T) {
 		c.Do("GETRANGE", "foo", "0", "-400")
 		c.Do("GETRANGE", "foo", "-4", "-4")
 		c.Do("GETRANGE", "foo", "4", "2")
+		c.Do("GETRANGE", "foo", "-400", "2")
+		c.Do("GETRANGE", "foo", "-400", "-400")
+		c.Do("GETRANGE", "foo", "100", "200")
+		c.Do("GETRANGE", "nosuch", "0", "-1")
+		c.Do("SUBSTR", "foo", "0", "-4")
+		c.Do("SUBSTR", "foo", "-400", "2")
+		c.Error("wrong number", "SUBSTR", "foo", "4")
 		c.Error("not an integer", "GETRANGE", "foo", "aap", "2")
 		c.Error("not an integer", "GETRANGE", "foo", "4", "aap")
 		c.Error("wrong number", "GETRANGE", "foo", "4", "2", "aap")
@@ -196,6 +289,13 @@ func TestStrlen(t *testing.T) {
 	testRaw(t, func(c *client) {
 		c.Do("SET", "str", "The quick brown fox jumps over the lazy dog")
 		c.Do("STRLEN", "str")
+		c.Do("STRLEN", "nosuch")
+		c.Do("SET", "empty", "")
+		c.Do("STRLEN", "empty")
+		c.Do("SET", "utf8", "❆❅❄☃")
+		c.Do("STRLEN", "utf8")
+		c.Do("SET", "int", "12345")
+		c.Do("STRLEN", "int")
 		// failure cases
 		c.Error("wrong number", "STRLEN")
 		c.Error("wrong number", "STRLEN", "str", "bar")
@@ -259,6 +359,27 @@ func TestIncrAndFriends(t *testing.T) {
 		c.Do("GET", "whole")
 		c.Do("INCRBYFLOAT", "big", "12345e10")
 		c.Do("GET", "big")
+		c.Do("INCRBYFLOAT", "small", "1.5")
+		c.Do("INCRBYFLOAT", "small", "-1.25")
+		c.Do("INCRBYFLOAT", "small", "-0.25")
+		c.Do("GET", "small")
+		c.Error("NaN or Infinity", "INCRBYFLOAT", "inf", "inf")
+		c.Do("SET", "inf", "-inf")
+		c.Error("NaN or Infinity", "INCRBYFLOAT", "inf", "+inf")
+
+		// 64 bit limits
+		c.Do("SET", "max", "9223372036854775806")
+		c.Do("INCR", "max")
+		c.Error("would overflow", "INCR", "max")
+		c.Error("would overflow", "INCRBY", "max", "10")
+		c.Do("SET", "min", "-9223372036854775807")
+		c.Do("DECR", "min")
+		c.Error("would overflow", "DECR", "min")
+		c.Error("would overflow", "DECRBY", "min", "10")
+		c.Error("would overflow", "DECRBY", "min", "-9223372036854775808")
+		c.Error("not an integer", "INCRBY", "min", "9223372036854775808")
+		c.Do("SET", "big", "9223372036854775808")
+		c.Error("not an integer", "INCR", "big")
 
 		// Floats are not ints.
 		c.Do("SET", "float", "1.23")
@@ -312,6 +433,14 @@ func TestBitcount(t *testing.T) {
 		c.Do("BITCOUNT", "str", "-2", "-1")
 		c.Do("BITCOUNT", "str", "-2", "-12")
 		c.Do("BITCOUNT", "utf8", "0", "0")
+		c.Do("BITCOUNT", "str", "0", "0", "BYTE")
+		c.Do("BITCOUNT", "str", "1", "2", "byte")
+		c.Do("BITCOUNT", "str", "0", "0", "BIT")
+		c.Do("BITCOUNT", "str", "3", "19", "BIT")
+		c.Do("BITCOUNT", "str", "-5", "-1", "bit")
+		c.Do("BITCOUNT", "str", "19", "3", "BIT")
+		c.Do("BITCOUNT", "nosuch", "0", "10", "BIT")
+		c.Error("syntax error", "BITCOUNT", "str", "0", "1", "BITS")
 
 		c.Error("wrong number", "BITCOUNT")
 		c.Do("BITCOUNT", "wrong", "arguments")
@@ -455,6 +584,16 @@ func TestBitpos(t *testing.T) {
 		c.Do("BITPOS", "nosuch", "1")
 		c.Do("BITPOS", "nosuch", "1", "0")
 		c.Do("BITPOS", "nosuch", "1", "0", "0")
+		c.Do("BITPOS", "a", "1", "0", "-1", "BYTE")
+		c.Do("BITPOS", "a", "1", "0", "-1", "bit")
+		c.Do("BITPOS", "a", "0", "3", "-1", "BIT")
+		c.Do("BITPOS", "e", "1", "9", "20", "BIT")
+		c.Do("BITPOS", "e", "0", "-5", "-1", "BIT")
+		c.Do("BITPOS", "e", "1", "20", "9", "BIT")
+		c.Do("BITPOS", "empty", "0", "0", "-1", "BIT")
+		c.Do("BITPOS", "nosuch", "0", "0", "-1", "BIT")
+		c.Error("syntax error", "BITPOS", "a", "1", "0", "-1", "BITS")
+		c.Error("bit argument", "BITPOS", "a", "2")
 
 		c.Do("HSET", "hash", "aap", "noot")
 		c.Error("wrong kind", "BITPOS", "hash", "1")
@@ -474,6 +613,8 @@ func TestGetbit(t *testing.T) {
 			c.Do("HSET", "hash", "aap", "noot")
 			c.Error("wrong kind", "GETBIT", "hash", "1")
 			c.Error("not an integer", "GETBIT", "a", "aap")
+			c.Error("not an integer", "GETBIT", "a", "-1")
+			c.Error("not an integer", "GETBIT", "a", "4294967296")
 			c.Error("wrong number", "GETBIT", "a")
 			c.Error("wrong number", "GETBIT", "too", "1", "many")
 
@@ -502,6 +643,7 @@ func TestSetbit(t *testing.T) {
 			c.Error("not an integer", "SETBIT", "a", "aap", "0")
 			c.Error("not an integer", "SETBIT", "a", "0", "aap")
 			c.Error("not an integer", "SETBIT", "a", "-1", "0")
+			c.Error("not an integer", "SETBIT", "a", "4294967296", "0")
 			c.Error("not an integer", "SETBIT", "a", "1", "-1")
 			c.Error("not an integer", "SETBIT", "a", "1", "2")
 			c.Error("wrong number", "SETBIT", "too", "1", "2", "many")
@@ -518,6 +660,10 @@ func TestAppend(t *testing.T) {
 		c.Do("APPEND", "foo", "more")
 		c.Do("GET", "foo")
 		c.Do("APPEND", "nosuch", "more")
+		c.Do("SET", "ttl", "bar", "EX", "100")
+		c.Do("APPEND", "ttl", "more")
+		c.Do("TTL", "ttl")
+		c.Do("APPEND", "utf8", "❆❅")
 		c.Do("GET", "nosuch")
 
 		// Failure cases
@@ -540,7 +686,8 @@ func TestMove(t *testing.T) {
 		// Failure cases
 		c.Error("wrong number", "MOVE")
 		c.Error("wrong number", "MOVE", "foo")
-		// c.Do("MOVE", "foo", "noint")
+		c.Error("out of range", "MOVE", "foo", "noint")
+		c.Error("out of range", "MOVE", "foo", "-1")
 	})
 	// hash key
 	testRaw(t, func(c *client) {
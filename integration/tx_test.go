@@ -149,6 +149,18 @@ func TestTx(t *testing.T) {
 		c.Do("EXEC")
 	})
 
+	// blocking commands don't block
+	testRaw(t, func(c *client) {
+		c.Do("RPUSH", "l2", "aap")
+		c.Do("MULTI")
+		c.Do("BLPOP", "l1", "l2", "0")
+		c.Do("BLPOP", "l1", "0")
+		c.Do("BRPOPLPUSH", "l1", "l2", "0")
+		c.Do("BZPOPMIN", "z", "0")
+		c.Do("XREAD", "BLOCK", "0", "STREAMS", "x", "$")
+		c.Do("EXEC")
+	})
+
 	// fail on invalid command
 	testRaw(t, func(c *client) {
 		c.Do("MULTI")
@@ -156,14 +168,24 @@ func TestTx(t *testing.T) {
 		c.Error("Transaction discarded", "EXEC")
 	})
 
-	/* FIXME
-		// fail on unknown command
+	// fail on unknown command
 	testRaw(t, func(c *client) {
-			c.Do("MULTI")
-			c.Do("NOSUCH")
-			c.Do("EXEC")
+		c.Do("MULTI")
+		c.Do("SET", "foo", "bar")
+		c.Error("unknown command", "NOSUCH")
+		c.Error("Transaction discarded", "EXEC")
+		c.Do("GET", "foo")
+	})
+
+	// runtime errors don't abort
+	testRaw(t, func(c *client) {
+		c.Do("SET", "str", "bar")
+		c.Do("MULTI")
+		c.Do("INCR", "str")
+		c.Do("SET", "foo", "bar")
+		c.Do("EXEC")
+		c.Do("GET", "foo")
 	})
-	*/
 
 	// failed EXEC cleaned up the tx
 	testRaw(t, func(c *client) {
@@ -1,83 +1,115 @@
 package miniredis
 
-// Translate the 'KEYS' or 'PSUBSCRIBE' argument ('foo*', 'f??', &c.) into a regexp.
+// Glob style pattern matching, for KEYS, SCAN MATCH, PSUBSCRIBE, &c.
 
-import (
-	"bytes"
-	"regexp"
-)
+// patternMatch matches a glob pattern ('foo*', 'f??', 'f[a-c]o', &c.)
+// against a key or channel, byte by byte. It's a port of Redis'
+// stringmatchlen(), quirks included: a trailing '\' matches itself, an
+// unterminated class is closed at the end of the pattern, reversed ranges are
+// fine, and '[]' never matches.
+func patternMatch(pattern, s string) bool {
+	skipLonger := false
+	return stringMatch(pattern, s, &skipLonger, 0)
+}
+
+func stringMatch(p, s string, skipLonger *bool, nesting int) bool {
+	// protection against abusive patterns
+	if nesting > 1000 {
+		return false
+	}
 
-// patternRE compiles a glob to a regexp. Returns nil if the given
-// pattern will never match anything.
-// The general strategy is to sandwich all non-meta characters between \Q...\E.
-func patternRE(k string) *regexp.Regexp {
-	re := bytes.Buffer{}
-	re.WriteString(`(?s)^\Q`)
-	for i := 0; i < len(k); i++ {
-		p := k[i]
-		switch p {
+	for len(p) > 0 && len(s) > 0 {
+		switch p[0] {
 		case '*':
-			re.WriteString(`\E.*\Q`)
+			for len(p) > 1 && p[1] == '*' {
+				p = p[1:]
+			}
+			if len(p) == 1 {
+				return true
+			}
+			for len(s) > 0 {
+				if stringMatch(p[1:], s, skipLonger, nesting+1) {
+					return true
+				}
+				if *skipLonger {
+					// a later '*' already failed on all shorter strings
+					return false
+				}
+				s = s[1:]
+			}
+			*skipLonger = true
+			return false
 		case '?':
-			re.WriteString(`\E.\Q`)
+			s = s[1:]
 		case '[':
-			charClass := bytes.Buffer{}
-			i++
-			for ; i < len(k); i++ {
-				if k[i] == ']' {
+			p = p[1:]
+			not := len(p) > 0 && p[0] == '^'
+			if not {
+				p = p[1:]
+			}
+			match := false
+			for {
+				if len(p) >= 2 && p[0] == '\\' {
+					p = p[1:]
+					if p[0] == s[0] {
+						match = true
+					}
+				} else if len(p) == 0 || p[0] == ']' {
 					break
-				}
-				if k[i] == '\\' {
-					if i == len(k)-1 {
-						// Ends with a '\'. U-huh.
-						return nil
+				} else if len(p) >= 3 && p[1] == '-' {
+					start, end := p[0], p[2]
+					if start > end {
+						start, end = end, start
+					}
+					if s[0] >= start && s[0] <= end {
+						match = true
 					}
-					charClass.WriteByte(k[i])
-					i++
-					charClass.WriteByte(k[i])
-					continue
+					p = p[2:]
+				} else if p[0] == s[0] {
+					match = true
 				}
-				charClass.WriteByte(k[i])
+				p = p[1:]
 			}
-			if charClass.Len() == 0 {
-				// '[]' is valid in Redis, but matches nothing.
-				return nil
+			if not {
+				match = !match
 			}
-			re.WriteString(`\E[`)
-			re.Write(charClass.Bytes())
-			re.WriteString(`]\Q`)
-
+			if !match {
+				return false
+			}
+			s = s[1:]
 		case '\\':
-			if i == len(k)-1 {
-				// Ends with a '\'. U-huh.
-				return nil
+			if len(p) >= 2 {
+				p = p[1:]
 			}
-			// Forget the \, keep the next char.
-			i++
-			re.WriteByte(k[i])
-			continue
+			fallthrough
 		default:
-			re.WriteByte(p)
+			if p[0] != s[0] {
+				return false
+			}
+			s = s[1:]
+		}
+		if len(p) > 0 {
+			p = p[1:]
+		}
+		if len(s) == 0 {
+			for len(p) > 0 && p[0] == '*' {
+				p = p[1:]
+			}
+			break
 		}
 	}
-	re.WriteString(`\E$`)
-	return regexp.MustCompile(re.String())
+	return len(p) == 0 && len(s) == 0
 }
 
 // matchKeys filters only matching keys.
-// The returned boolean is whether the match pattern was valid
-func matchKeys(keys []string, match string) ([]string, bool) {
-	re := patternRE(match)
-	if re == nil {
-		// Special case: the given pattern won't match anything or is invalid.
-		return nil, false
-	}
+func matchKeys(keys []string, match string) []string {
 	var res []string
 	for _, k := range keys {
-		if !re.MatchString(k) {
+		// Redis special cases "*", which also matches the empty key.
+		if match != "*" && !patternMatch(match, k) {
 			continue
 		}
 		res = append(res, k)
 	}
-	return res, true
+	return res
 }
@@ -1,6 +1,7 @@
 package miniredis
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -9,13 +10,8 @@ func TestKeysSel(t *testing.T) {
 	// pattern -> cases -> should match?
 	test := func(pat string, chk map[string]bool) {
 		t.Helper()
-		patRe := patternRE(pat)
-		if patRe == nil {
-			t.Errorf("'%v' won't match anything. Didn't expect that.", pat)
-			return
-		}
 		for key, expected := range chk {
-			match := patRe.MatchString(key)
+			match := patternMatch(pat, key)
 			if have, want := match, expected; have != want {
 				t.Errorf("'%v' -> '%v'. have %v, want %v", pat, key, have, want)
 			}
@@ -109,34 +105,137 @@ func TestKeysSel(t *testing.T) {
 		`\foo`: false,
 	})
 
-	// Patterns which won't match anything.
-	test2 := func(pat string) {
-		t.Helper()
-		if patternRE(pat) != nil {
-			t.Errorf("'%v' will match something. Didn't expect that.", pat)
-		}
-	}
-	test2(`ap[\`) // trailing \ in char class
-	test2(`ap[`)  // open char class
-	test2(`[]ap`) // empty char class
-	test2(`ap\`)  // trailing \
+	test("[a-c]x", map[string]bool{
+		"ax": true,
+		"bx": true,
+		"cx": true,
+		"dx": false,
+		"-x": false,
+	})
+	test("[c-a]x", map[string]bool{
+		"bx": true,
+		"dx": false,
+	})
+	test("[^a-c]x", map[string]bool{
+		"ax": false,
+		"dx": true,
+		"^x": true,
+	})
+	test("[a-]", map[string]bool{ // that's the range ']' to 'a'
+		"a": true,
+		"_": true,
+		"-": false,
+		"b": false,
+	})
+	test(`[\d]`, map[string]bool{
+		`\`: false,
+		"d": true,
+		"1": false,
+	})
+	test("[[:alpha:]]", map[string]bool{ // no POSIX classes
+		"[]": true,
+		":]": true,
+		"h]": true,
+		"h":  false,
+		"b]": false,
+	})
+	test("a**b*?", map[string]bool{
+		"ab":    false,
+		"abc":   true,
+		"axbyz": true,
+	})
+	test("a*", map[string]bool{
+		"a": true,
+	})
+	test("", map[string]bool{
+		"":  true,
+		"a": false,
+	})
+
+	// Redis' quirks
+	test(`ap\`, map[string]bool{ // trailing \ matches itself
+		`ap\`: true,
+		"ap":  false,
+	})
+	test(`ap[\`, map[string]bool{ // trailing \ in char class
+		`ap\`: true,
+		"ap":  false,
+	})
+	test("ap[bc", map[string]bool{ // open char class
+		"apb": true,
+		"apc": true,
+		"ap[": false,
+		"ap":  false,
+	})
+	test("ap[", map[string]bool{
+		"ap":  false,
+		"ap[": false,
+	})
+	test("[]ap", map[string]bool{ // empty char class
+		"]ap": false,
+		"ap":  false,
+	})
+	test("[^]", map[string]bool{
+		"a": true,
+		"]": true,
+		"":  false,
+	})
+	test("*", map[string]bool{
+		"a": true,
+		"":  false, // see matchKeys()
+	})
+}
+
+func TestPatternMatchAbuse(t *testing.T) {
+	// this takes forever without the skipLonger trick
+	pat := strings.Repeat("a*", 30) + "b"
+	equals(t, false, patternMatch(pat, strings.Repeat("a", 100)))
 }
 
 func TestMatchKeys(t *testing.T) {
 	t.Run("simple", func(t *testing.T) {
-		m, ok := matchKeys([]string{"a", "b", "c"}, "*")
-		equals(t, true, ok)
+		m := matchKeys([]string{"a", "b", "c"}, "*")
 		equals(t, []string{"a", "b", "c"}, m)
 	})
 
+	t.Run("empty key", func(t *testing.T) {
+		m := matchKeys([]string{"", "a"}, "*")
+		equals(t, []string{"", "a"}, m)
+	})
+
 	t.Run("newlines", func(t *testing.T) {
-		m, ok := matchKeys([]string{"a", "b\nb", "c"}, "*")
-		equals(t, true, ok)
+		m := matchKeys([]string{"a", "b\nb", "c"}, "*")
 		equals(t, []string{"a", "b\nb", "c"}, m)
+
+		m = matchKeys([]string{"a", "b\nb", "c"}, "b?b")
+		equals(t, []string{"b\nb"}, m)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		m := matchKeys([]string{"a", "b", "c"}, "[")
+		equals(t, []string(nil), m)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		keys := []string{"a\x00b", "\xff", "\xff\xfe", "\xc3\xa9"}
+		m := matchKeys(keys, "?")
+		equals(t, []string{"\xff"}, m)
+
+		m = matchKeys(keys, "\xff*")
+		equals(t, []string{"\xff", "\xff\xfe"}, m)
+
+		m = matchKeys(keys, "a[\x00]b")
+		equals(t, []string{"a\x00b"}, m)
+
+		m = matchKeys(keys, "??")
+		equals(t, []string{"\xff\xfe", "\xc3\xa9"}, m)
+
+		m = matchKeys(keys, "[\xc0-\xff]*")
+		equals(t, []string{"\xff", "\xff\xfe", "\xc3\xa9"}, m)
 	})
 
-	t.Run("invalid", func(t *testing.T) {
-		_, ok := matchKeys([]string{"a", "b", "c"}, "[")
-		equals(t, false, ok)
+	t.Run("negated class", func(t *testing.T) {
+		m := matchKeys([]string{"a", "b", "c"}, "[^b]")
+		equals(t, []string{"a", "c"}, m)
 	})
 }
@@ -0,0 +1,425 @@
+package miniredis
+
+// Which arguments of a command are keys.
+
+import (
+	"strconv"
+	"strings"
+)
+
+// keySpec has the first key, last key, and step values as COMMAND reports
+// them. Positions start at 1 for the first argument after the command name,
+// a negative last key counts from the end.
+type keySpec struct {
+	first, last, step int
+}
+
+var keySpecs = map[string]keySpec{
+	// generic
+	"COPY":      {1, 2, 1},
+	"DEL":       {1, -1, 1},
+	"DUMP":      {1, 1, 1},
+	"EXISTS":    {1, -1, 1},
+	"EXPIRE":    {1, 1, 1},
+	"EXPIREAT":  {1, 1, 1},
+	"MOVE":      {1, 1, 1},
+	"OBJECT":    {2, 2, 1},
+	"PERSIST":   {1, 1, 1},
+	"PEXPIRE":   {1, 1, 1},
+	"PEXPIREAT": {1, 1, 1},
+	"PTTL":      {1, 1, 1},
+	"RENAME":    {1, 2, 1},
+	"RENAMENX":  {1, 2, 1},
+	"RESTORE":   {1, 1, 1},
+	"TOUCH":     {1, -1, 1},
+	"TTL":       {1, 1, 1},
+	"TYPE":      {1, 1, 1},
+	"UNLINK":    {1, -1, 1},
+
+	// geo
+	"GEOADD":               {1, 1, 1},
+	"GEODIST":              {1, 1, 1},
+	"GEOHASH":              {1, 1, 1},
+	"GEOPOS":               {1, 1, 1},
+	"GEORADIUS_RO":         {1, 1, 1},
+	"GEORADIUSBYMEMBER_RO": {1, 1, 1},
+	"GEOSEARCH":            {1, 1, 1},
+	"GEOSEARCHSTORE":       {1, 2, 1},
+
+	// hash
+	"HDEL":         {1, 1, 1},
+	"HEXISTS":      {1, 1, 1},
+	"HEXPIRE":      {1, 1, 1},
+	"HEXPIREAT":    {1, 1, 1},
+	"HEXPIRETIME":  {1, 1, 1},
+	"HGET":         {1, 1, 1},
+	"HGETALL":      {1, 1, 1},
+	"HGETDEL":      {1, 1, 1},
+	"HGETEX":       {1, 1, 1},
+	"HINCRBY":      {1, 1, 1},
+	"HINCRBYFLOAT": {1, 1, 1},
+	"HKEYS":        {1, 1, 1},
+	"HLEN":         {1, 1, 1},
+	"HMGET":        {1, 1, 1},
+	"HMSET":        {1, 1, 1},
+	"HPERSIST":     {1, 1, 1},
+	"HPEXPIRE":     {1, 1, 1},
+	"HPEXPIREAT":   {1, 1, 1},
+	"HPEXPIRETIME": {1, 1, 1},
+	"HPTTL":        {1, 1, 1},
+	"HSCAN":        {1, 1, 1},
+	"HSET":         {1, 1, 1},
+	"HSETNX":       {1, 1, 1},
+	"HSTRLEN":      {1, 1, 1},
+	"HTTL":         {1, 1, 1},
+	"HVALS":        {1, 1, 1},
+
+	// hyperloglog
+	"PFADD":   {1, 1, 1},
+	"PFCOUNT": {1, -1, 1},
+	"PFMERGE": {1, -1, 1},
+
+	// list
+	"BLPOP":      {1, -2, 1},
+	"BRPOP":      {1, -2, 1},
+	"BRPOPLPUSH": {1, 2, 1},
+	"LINDEX":     {1, 1, 1},
+	"LINSERT":    {1, 1, 1},
+	"LLEN":       {1, 1, 1},
+	"LPOP":       {1, 1, 1},
+	"LPUSH":      {1, 1, 1},
+	"LPUSHX":     {1, 1, 1},
+	"LRANGE":     {1, 1, 1},
+	"LREM":       {1, 1, 1},
+	"LSET":       {1, 1, 1},
+	"LTRIM":      {1, 1, 1},
+	"RPOP":       {1, 1, 1},
+	"RPOPLPUSH":  {1, 2, 1},
+	"RPUSH":      {1, 1, 1},
+	"RPUSHX":     {1, 1, 1},
+
+	// pubsub
+	"SPUBLISH":     {1, 1, 1},
+	"SSUBSCRIBE":   {1, -1, 1},
+	"SUNSUBSCRIBE": {1, -1, 1},
+
+	// set
+	"SADD":        {1, 1, 1},
+	"SCARD":       {1, 1, 1},
+	"SDIFF":       {1, -1, 1},
+	"SDIFFSTORE":  {1, -1, 1},
+	"SINTER":      {1, -1, 1},
+	"SINTERSTORE": {1, -1, 1},
+	"SISMEMBER":   {1, 1, 1},
+	"SMEMBERS":    {1, 1, 1},
+	"SMISMEMBER":  {1, 1, 1},
+	"SMOVE":       {1, 2, 1},
+	"SPOP":        {1, 1, 1},
+	"SRANDMEMBER": {1, 1, 1},
+	"SREM":        {1, 1, 1},
+	"SSCAN":       {1, 1, 1},
+	"SUNION":      {1, -1, 1},
+	"SUNIONSTORE": {1, -1, 1},
+
+	// sorted set
+	"BZPOPMAX":         {1, -2, 1},
+	"BZPOPMIN":         {1, -2, 1},
+	"ZADD":             {1, 1, 1},
+	"ZCARD":            {1, 1, 1},
+	"ZCOUNT":           {1, 1, 1},
+	"ZINCRBY":          {1, 1, 1},
+	"ZLEXCOUNT":        {1, 1, 1},
+	"ZMSCORE":          {1, 1, 1},
+	"ZPOPMAX":          {1, 1, 1},
+	"ZPOPMIN":          {1, 1, 1},
+	"ZRANDMEMBER":      {1, 1, 1},
+	"ZRANGE":           {1, 1, 1},
+	"ZRANGEBYLEX":      {1, 1, 1},
+	"ZRANGEBYSCORE":    {1, 1, 1},
+	"ZRANGESTORE":      {1, 2, 1},
+	"ZRANK":            {1, 1, 1},
+	"ZREM":             {1, 1, 1},
+	"ZREMRANGEBYLEX":   {1, 1, 1},
+	"ZREMRANGEBYRANK":  {1, 1, 1},
+	"ZREMRANGEBYSCORE": {1, 1, 1},
+	"ZREVRANGE":        {1, 1, 1},
+	"ZREVRANGEBYLEX":   {1, 1, 1},
+	"ZREVRANGEBYSCORE": {1, 1, 1},
+	"ZREVRANK":         {1, 1, 1},
+	"ZSCAN":            {1, 1, 1},
+	"ZSCORE":           {1, 1, 1},
+
+	// stream
+	"XACK":      {1, 1, 1},
+	"XACKDEL":   {1, 1, 1},
+	"XADD":      {1, 1, 1},
+	"XDEL":      {1, 1, 1},
+	"XDELEX":    {1, 1, 1},
+	"XGROUP":    {2, 2, 1},
+	"XINFO":     {2, 2, 1},
+	"XLEN":      {1, 1, 1},
+	"XPENDING":  {1, 1, 1},
+	"XRANGE":    {1, 1, 1},
+	"XREVRANGE": {1, 1, 1},
+
+	// string
+	"APPEND":      {1, 1, 1},
+	"BITCOUNT":    {1, 1, 1},
+	"BITOP":       {2, -1, 1},
+	"BITPOS":      {1, 1, 1},
+	"DECR":        {1, 1, 1},
+	"DECRBY":      {1, 1, 1},
+	"GET":         {1, 1, 1},
+	"GETBIT":      {1, 1, 1},
+	"GETDEL":      {1, 1, 1},
+	"GETEX":       {1, 1, 1},
+	"GETRANGE":    {1, 1, 1},
+	"GETSET":      {1, 1, 1},
+	"INCR":        {1, 1, 1},
+	"INCRBY":      {1, 1, 1},
+	"INCRBYFLOAT": {1, 1, 1},
+	"LCS":         {1, 2, 1},
+	"MGET":        {1, -1, 1},
+	"MSET":        {1, -1, 2},
+	"MSETNX":      {1, -1, 2},
+	"PSETEX":      {1, 1, 1},
+	"SET":         {1, 1, 1},
+	"SETBIT":      {1, 1, 1},
+	"SETEX":       {1, 1, 1},
+	"SETNX":       {1, 1, 1},
+	"SETRANGE":    {1, 1, 1},
+	"STRLEN":      {1, 1, 1},
+	"SUBSTR":      {1, 1, 1},
+
+	// transactions
+	"WATCH": {1, -1, 1},
+}
+
+// Commands where the keys can't be found with a keySpec.
+var keySpecFuncs = map[string]func([]string) []string{
+	"BZMPOP":            evalKeys,
+	"EVAL":              evalKeys,
+	"EVAL_RO":           evalKeys,
+	"EVALSHA":           evalKeys,
+	"EVALSHA_RO":        evalKeys,
+	"FCALL":             evalKeys,
+	"FCALL_RO":          evalKeys,
+	"GEORADIUS":         geoRadiusKeys,
+	"GEORADIUSBYMEMBER": geoRadiusKeys,
+	"MIGRATE":           migrateKeys,
+	"SORT":              sortKeys,
+	"SORT_RO":           sortKeys,
+	"XREAD":             xreadKeys,
+	"XREADGROUP":        xreadKeys,
+	"ZINTER":            numKeys,
+	"ZINTERCARD":        numKeys,
+	"ZINTERSTORE":       zstoreKeys,
+	"ZMPOP":             numKeys,
+	"ZUNION":            numKeys,
+	"ZUNIONSTORE":       zstoreKeys,
+}
+
+// Commands which don't need any keys, such as EVAL with 0 keys.
+var noMandatoryKeys = map[string]bool{
+	"EVAL":       true,
+	"EVAL_RO":    true,
+	"EVALSHA":    true,
+	"EVALSHA_RO": true,
+	"FCALL":      true,
+	"FCALL_RO":   true,
+}
+
+// hasKeys is whether a command can have keys. cmd must be uppercase.
+func hasKeys(cmd string) bool {
+	_, spec := keySpecs[cmd]
+	_, f := keySpecFuncs[cmd]
+	return spec || f
+}
+
+// writeCommand is whether a command can change data, which read only scripts
+// can't do. cmd must be uppercase.
+func writeCommand(cmd string) bool {
+	return commandInfos[cmd].hasFlag("write")
+}
+
+// readOnlyCommand is whether a command only reads data. Those are the ones
+// CLIENT TRACKING remembers the keys of. cmd must be uppercase.
+func readOnlyCommand(cmd string) bool {
+	return commandInfos[cmd].hasFlag("readonly")
+}
+
+// keyFlags gives the key flags COMMAND GETKEYSANDFLAGS reports for the i-th
+// key of a command. cmd must be uppercase. This is a simplification of the
+// key specs Redis has, but read only keys are always "RO".
+func keyFlags(cmd string, i int) []string {
+	var (
+		ro     = []string{"RO", "access"}
+		rw     = []string{"RW", "access", "update"}
+		ow     = []string{"OW", "update"}
+		source = []string{"RW", "access", "delete"}
+	)
+	switch cmd {
+	case "SPUBLISH", "SSUBSCRIBE", "SUNSUBSCRIBE":
+		// shard channels, not keys
+		return []string{"not_key"}
+	case "WATCH":
+		// not a read only command, but it doesn't access the keys
+		return []string{"RO"}
+	case "DEL", "UNLINK":
+		return []string{"RM", "delete"}
+	case "BLPOP", "BRPOP", "BZMPOP", "BZPOPMAX", "BZPOPMIN", "GETDEL",
+		"HGETDEL", "LPOP", "MIGRATE", "RPOP", "SPOP", "ZMPOP", "ZPOPMAX",
+		"ZPOPMIN":
+		return source
+	case "MSET", "MSETNX", "RESTORE":
+		return ow
+	case "BITOP", "GEOSEARCHSTORE", "PFMERGE", "SDIFFSTORE", "SINTERSTORE",
+		"SUNIONSTORE", "ZINTERSTORE", "ZRANGESTORE", "ZUNIONSTORE":
+		// destination first
+		if i == 0 {
+			return ow
+		}
+		return ro
+	case "COPY", "GEORADIUS", "GEORADIUSBYMEMBER", "SORT":
+		// optional destination last
+		if i == 0 {
+			return ro
+		}
+		return ow
+	case "RENAME", "RENAMENX":
+		if i == 0 {
+			return source
+		}
+		return ow
+	case "BRPOPLPUSH", "RPOPLPUSH", "SMOVE":
+		if i == 0 {
+			return source
+		}
+		return []string{"RW", "insert"}
+	}
+	if readOnlyCommand(cmd) {
+		return ro
+	}
+	return rw
+}
+
+// commandKeys returns the keys used by a command. cmd must be uppercase.
+// Invalid arguments give whatever keys can be found.
+func commandKeys(cmd string, args []string) []string {
+	if f, ok := keySpecFuncs[cmd]; ok {
+		return f(args)
+	}
+	spec, ok := keySpecs[cmd]
+	if !ok {
+		return nil
+	}
+	last := spec.last
+	if last < 0 {
+		last += len(args) + 1
+	}
+	if last > len(args) {
+		last = len(args)
+	}
+	var keys []string
+	for i := spec.first; i <= last; i += spec.step {
+		keys = append(keys, args[i-1])
+	}
+	return keys
+}
+
+// numKeys returns the keys after a "numkeys" argument.
+func numKeys(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 || n > len(args)-1 {
+		return nil
+	}
+	return args[1 : n+1]
+}
+
+// EVAL script numkeys key [key ...] arg [arg ...]
+// BZMPOP timeout numkeys key [key ...] MIN|MAX ...
+func evalKeys(args []string) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	return numKeys(args[1:])
+}
+
+// ZUNIONSTORE destination numkeys key [key ...] ...
+func zstoreKeys(args []string) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	return append([]string{args[0]}, numKeys(args[1:])...)
+}
+
+// MIGRATE host port key|"" db timeout ... [KEYS key [key ...]]
+func migrateKeys(args []string) []string {
+	if len(args) < 5 {
+		return nil
+	}
+	if args[2] != "" {
+		return []string{args[2]}
+	}
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "AUTH":
+			i++
+		case "AUTH2":
+			i += 2
+		case "KEYS":
+			return args[i+1:]
+		}
+	}
+	return nil
+}
+
+// SORT key ... [STORE destination]
+func sortKeys(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	keys := []string{args[0]}
+	for i := 1; i < len(args)-1; i++ {
+		switch strings.ToUpper(args[i]) {
+		case "STORE":
+			i++
+			keys = append(keys, args[i])
+		case "BY", "GET":
+			i++
+		case "LIMIT":
+			i += 2
+		}
+	}
+	return keys
+}
+
+// XREAD ... STREAMS key [key ...] id [id ...]
+func xreadKeys(args []string) []string {
+	for i, a := range args {
+		if strings.ToUpper(a) == "STREAMS" {
+			rest := args[i+1:]
+			return rest[:len(rest)/2]
+		}
+	}
+	return nil
+}
+
+// GEORADIUS key ... [STORE key] [STOREDIST key]
+func geoRadiusKeys(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	keys := []string{args[0]}
+	for i := 1; i < len(args)-1; i++ {
+		switch strings.ToUpper(args[i]) {
+		case "STORE", "STOREDIST":
+			i++
+			keys = append(keys, args[i])
+		}
+	}
+	return keys
+}
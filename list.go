@@ -0,0 +1,79 @@
+package miniredis
+
+// listKey is a Redis list. It's a deque: pushing and popping at either end is
+// amortized O(1), so big lists used as queues stay fast. The elements are
+// buf[head:]; LPUSH grows the free space before head, RPUSH uses append().
+
+type listKey struct {
+	buf  []string
+	head int
+}
+
+func newListKey(elems ...string) *listKey {
+	return &listKey{
+		buf: append([]string(nil), elems...),
+	}
+}
+
+func (l *listKey) len() int {
+	return len(l.buf) - l.head
+}
+
+// elems returns the elements, in order. This is not a copy.
+func (l *listKey) elems() []string {
+	return l.buf[l.head:]
+}
+
+func (l *listKey) index(i int) string {
+	return l.buf[l.head+i]
+}
+
+func (l *listKey) set(i int, v string) {
+	l.buf[l.head+i] = v
+}
+
+// replace replaces all elements.
+func (l *listKey) replace(elems []string) {
+	l.buf = elems
+	l.head = 0
+}
+
+func (l *listKey) lpush(v string) {
+	if l.head == 0 {
+		// make room in front, as much as we have elements.
+		n := l.len()
+		if n < 8 {
+			n = 8
+		}
+		buf := make([]string, n+len(l.buf), n+cap(l.buf))
+		copy(buf[n:], l.buf)
+		l.buf = buf
+		l.head = n
+	}
+	l.head--
+	l.buf[l.head] = v
+}
+
+func (l *listKey) rpush(v ...string) {
+	l.buf = append(l.buf, v...)
+}
+
+func (l *listKey) lpop() string {
+	v := l.buf[l.head]
+	l.buf[l.head] = ""
+	l.head++
+	if l.head > 64 && l.head > 2*l.len() {
+		// mostly unused space, compact it.
+		l.buf = append([]string(nil), l.buf[l.head:]...)
+		l.head = 0
+	}
+	return v
+}
+
+func (l *listKey) rpop() string {
+	last := len(l.buf) - 1
+	v := l.buf[last]
+	l.buf[last] = ""
+	l.buf = l.buf[:last]
+	return v
+}
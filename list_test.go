@@ -0,0 +1,45 @@
+package miniredis
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestListImpl(t *testing.T) {
+	l := newListKey("b", "c")
+	equals(t, 2, l.len())
+	l.lpush("a")
+	l.rpush("d", "e")
+	equals(t, []string{"a", "b", "c", "d", "e"}, l.elems())
+	equals(t, "c", l.index(2))
+
+	l.set(2, "C")
+	equals(t, "C", l.index(2))
+
+	equals(t, "a", l.lpop())
+	equals(t, "e", l.rpop())
+	equals(t, []string{"b", "C", "d"}, l.elems())
+
+	l.replace([]string{"x", "y"})
+	equals(t, []string{"x", "y"}, l.elems())
+
+	// Both ends, lots of elements
+	{
+		l := newListKey()
+		for i := 0; i < 1000; i++ {
+			l.lpush(strconv.Itoa(-i - 1))
+			l.rpush(strconv.Itoa(i))
+		}
+		equals(t, 2000, l.len())
+		equals(t, "-1000", l.index(0))
+		equals(t, "999", l.index(1999))
+		for i := 0; i < 1500; i++ {
+			equals(t, strconv.Itoa(i-1000), l.lpop())
+			l.rpush("x")
+		}
+		equals(t, 2000, l.len())
+		equals(t, "500", l.index(0))
+		equals(t, "x", l.rpop())
+		equals(t, 1999, l.len())
+	}
+}
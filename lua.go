@@ -11,7 +11,7 @@ import (
 	"github.com/alicebob/miniredis/v2/server"
 )
 
-func mkLuaFuncs(srv *server.Server, c *server.Peer) map[string]lua.LGFunction {
+func mkLuaFuncs(srv *server.Server, c *server.Peer, readOnly bool) map[string]lua.LGFunction {
 	mkCall := func(failFast bool) func(l *lua.LState) int {
 		// one server.Ctx for a single Lua run
 		pCtx := &connCtx{}
@@ -48,26 +48,38 @@ func mkLuaFuncs(srv *server.Server, c *server.Peer) map[string]lua.LGFunction {
 			wr := bufio.NewWriter(buf)
 			peer := server.NewPeer(wr)
 			peer.Ctx = pCtx
-			srv.Dispatch(peer, args)
+			if readOnly && writeCommand(strings.ToUpper(args[0])) {
+				peer.WriteError(msgROScript)
+			} else {
+				srv.Dispatch(peer, args)
+			}
 			wr.Flush()
 
+			status := bytes.HasPrefix(buf.Bytes(), []byte("+"))
 			res, err := server.ParseReply(bufio.NewReader(buf))
 			if err != nil {
+				msg := err.Error()
+				if strings.Contains(msg, "ERR unknown command") {
+					msg = "Unknown Redis command called from Lua script"
+				}
 				if failFast {
 					// call() mode
-					if strings.Contains(err.Error(), "ERR unknown command") {
-						l.Error(lua.LString("Unknown Redis command called from Lua script"), 1)
-					} else {
-						l.Error(lua.LString(err.Error()), 1)
-					}
+					l.Error(lua.LString(msg), 1)
 					return 0
 				}
-				// pcall() mode
-				l.Push(lua.LNil)
+				// pcall() mode: errors are returned as an {err=...} table
+				tb := l.NewTable()
+				tb.RawSetString("err", lua.LString(msg))
+				l.Push(tb)
 				return 1
 			}
 
-			if res == nil {
+			if status {
+				// status replies are an {ok=...} table
+				tb := l.NewTable()
+				tb.RawSetString("ok", lua.LString(res.(string)))
+				l.Push(tb)
+			} else if res == nil {
 				l.Push(lua.LFalse)
 			} else {
 				switch r := res.(type) {
@@ -133,6 +145,10 @@ func mkLuaFuncs(srv *server.Server, c *server.Peer) map[string]lua.LGFunction {
 			// ignored
 			return 1
 		},
+		"log": func(l *lua.LState) int {
+			// there is no log
+			return 0
+		},
 	}
 }
 
@@ -154,12 +170,7 @@ func luaToRedis(l *lua.LState, c *server.Peer, value lua.LValue) {
 	case lua.LNumber:
 		c.WriteInt(int(lua.LVAsNumber(value)))
 	case lua.LString:
-		s := lua.LVAsString(value)
-		if s == "OK" {
-			c.WriteInline(s)
-		} else {
-			c.WriteBulk(s)
-		}
+		c.WriteBulk(lua.LVAsString(value))
 	case *lua.LTable:
 		// special case for tables with an 'err' or 'ok' field
 		// note: according to the docs this only counts when 'err' or 'ok' is
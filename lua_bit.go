@@ -0,0 +1,96 @@
+package miniredis
+
+// The "bit" Lua module, a port of LuaBitOp as used in Redis. All operations
+// work on 32 bit signed integers.
+
+import (
+	"fmt"
+	"math"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+var luaBitFuncs = map[string]lua.LGFunction{
+	"tobit": func(l *lua.LState) int {
+		l.Push(lua.LNumber(luaToBit(l, 1)))
+		return 1
+	},
+	"tohex": func(l *lua.LState) int {
+		b := uint32(luaToBit(l, 1))
+		n := l.OptInt(2, 8)
+		hex := "%08x"
+		if n < 0 {
+			n = -n
+			hex = "%08X"
+		}
+		if n > 8 {
+			n = 8
+		}
+		s := fmt.Sprintf(hex, b)
+		l.Push(lua.LString(s[8-n:]))
+		return 1
+	},
+	"bnot": func(l *lua.LState) int {
+		l.Push(lua.LNumber(^luaToBit(l, 1)))
+		return 1
+	},
+	"band": luaBitReduce(func(a, b int32) int32 { return a & b }),
+	"bor":  luaBitReduce(func(a, b int32) int32 { return a | b }),
+	"bxor": luaBitReduce(func(a, b int32) int32 { return a ^ b }),
+	"lshift": luaBitShift(func(b uint32, n uint) int32 {
+		return int32(b << n)
+	}),
+	"rshift": luaBitShift(func(b uint32, n uint) int32 {
+		return int32(b >> n)
+	}),
+	"arshift": luaBitShift(func(b uint32, n uint) int32 {
+		return int32(b) >> n
+	}),
+	"rol": luaBitShift(func(b uint32, n uint) int32 {
+		return int32(b<<n | b>>(32-n))
+	}),
+	"ror": luaBitShift(func(b uint32, n uint) int32 {
+		return int32(b>>n | b<<(32-n))
+	}),
+	"bswap": func(l *lua.LState) int {
+		b := uint32(luaToBit(l, 1))
+		b = b>>24 | (b>>8)&0xff00 | (b&0xff00)<<8 | b<<24
+		l.Push(lua.LNumber(int32(b)))
+		return 1
+	},
+}
+
+func luaBitLoader(l *lua.LState) int {
+	l.Push(l.SetFuncs(l.NewTable(), luaBitFuncs))
+	return 1
+}
+
+// luaToBit converts argument n to a 32 bit integer, the same as bit.tobit().
+func luaToBit(l *lua.LState, n int) int32 {
+	f := math.RoundToEven(float64(l.CheckNumber(n)))
+	return int32(uint32(int64(math.Mod(f, 1<<32))))
+}
+
+// luaBitReduce makes bit.band() and friends, which take any number of
+// arguments.
+func luaBitReduce(op func(int32, int32) int32) lua.LGFunction {
+	return func(l *lua.LState) int {
+		b := luaToBit(l, 1)
+		for i := 2; i <= l.GetTop(); i++ {
+			b = op(b, luaToBit(l, i))
+		}
+		l.Push(lua.LNumber(b))
+		return 1
+	}
+}
+
+// luaBitShift makes bit.lshift() and friends. Only the lower 5 bits of the
+// shift count are used.
+func luaBitShift(op func(uint32, uint) int32) lua.LGFunction {
+	return func(l *lua.LState) int {
+		b := uint32(luaToBit(l, 1))
+		n := uint(luaToBit(l, 2)) & 31
+		l.Push(lua.LNumber(op(b, n)))
+		return 1
+	}
+}
@@ -0,0 +1,322 @@
+package miniredis
+
+// The "cmsgpack" Lua module, as used in Redis: cmsgpack.pack(),
+// cmsgpack.unpack(), cmsgpack.unpack_one(), and cmsgpack.unpack_limit().
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// tables nested deeper than this are encoded as nil
+const msgpackMaxNesting = 16
+
+var (
+	errMsgpackMissing = errors.New("Missing bytes in input.")
+	errMsgpackFormat  = errors.New("Bad data format in input.")
+)
+
+var luaCmsgpackFuncs = map[string]lua.LGFunction{
+	"pack": func(l *lua.LState) int {
+		if l.GetTop() == 0 {
+			l.ArgError(0, "MessagePack pack needs input.")
+		}
+		var buf bytes.Buffer
+		for i := 1; i <= l.GetTop(); i++ {
+			msgpackEncode(&buf, l.Get(i), 0)
+		}
+		l.Push(lua.LString(buf.String()))
+		return 1
+	},
+	"unpack": func(l *lua.LState) int {
+		return msgpackUnpack(l, l.CheckString(1), 0, 0)
+	},
+	"unpack_one": func(l *lua.LState) int {
+		return msgpackUnpack(l, l.CheckString(1), 1, l.OptInt(2, 0))
+	},
+	"unpack_limit": func(l *lua.LState) int {
+		return msgpackUnpack(l, l.CheckString(1), l.CheckInt(2), l.OptInt(3, 0))
+	},
+}
+
+func luaCmsgpackLoader(l *lua.LState) int {
+	l.Push(l.SetFuncs(l.NewTable(), luaCmsgpackFuncs))
+	return 1
+}
+
+// msgpackUnpack decodes up to limit objects (0 is everything), starting at
+// offset. Unless everything is decoded the next offset is returned first, or
+// -1 if there is no more data.
+func msgpackUnpack(l *lua.LState, s string, limit, offset int) int {
+	if offset < 0 || offset > len(s) {
+		l.ArgError(2, "Start offset greater than input length.")
+	}
+	d := &msgpackDecoder{l: l, b: []byte(s[offset:])}
+	var res []lua.LValue
+	for len(d.b) > 0 && (limit == 0 || len(res) < limit) {
+		v, err := d.decode()
+		if err != nil {
+			l.RaiseError(err.Error())
+		}
+		res = append(res, v)
+	}
+	n := 0
+	if limit != 0 {
+		next := len(s) - len(d.b)
+		if len(d.b) == 0 {
+			next = -1
+		}
+		l.Push(lua.LNumber(next))
+		n++
+	}
+	for _, v := range res {
+		l.Push(v)
+	}
+	return n + len(res)
+}
+
+func msgpackEncode(buf *bytes.Buffer, v lua.LValue, level int) {
+	switch v := v.(type) {
+	case lua.LBool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case lua.LNumber:
+		f := float64(v)
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			msgpackInt(buf, int64(f))
+		} else if f32 := float32(f); float64(f32) == f {
+			buf.WriteByte(0xca)
+			binary.Write(buf, binary.BigEndian, math.Float32bits(f32))
+		} else {
+			buf.WriteByte(0xcb)
+			binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+		}
+	case lua.LString:
+		n := len(v)
+		switch {
+		case n < 32:
+			buf.WriteByte(0xa0 | byte(n))
+		case n <= math.MaxUint8:
+			buf.Write([]byte{0xd9, byte(n)})
+		case n <= math.MaxUint16:
+			buf.WriteByte(0xda)
+			binary.Write(buf, binary.BigEndian, uint16(n))
+		default:
+			buf.WriteByte(0xdb)
+			binary.Write(buf, binary.BigEndian, uint32(n))
+		}
+		buf.WriteString(string(v))
+	case *lua.LTable:
+		if level >= msgpackMaxNesting {
+			buf.WriteByte(0xc0)
+			return
+		}
+		if n, ok := msgpackArrayLen(v); ok {
+			msgpackHeader(buf, n, 0x90, 0xdc, 0xdd)
+			for i := 1; i <= n; i++ {
+				msgpackEncode(buf, v.RawGetInt(i), level+1)
+			}
+			return
+		}
+		n := 0
+		v.ForEach(func(_, _ lua.LValue) { n++ })
+		msgpackHeader(buf, n, 0x80, 0xde, 0xdf)
+		v.ForEach(func(k, v lua.LValue) {
+			msgpackEncode(buf, k, level+1)
+			msgpackEncode(buf, v, level+1)
+		})
+	default:
+		buf.WriteByte(0xc0)
+	}
+}
+
+// msgpackArrayLen is whether a table only has the keys 1..n.
+func msgpackArrayLen(t *lua.LTable) (int, bool) {
+	n, max := 0, 0
+	isArray := true
+	t.ForEach(func(k, _ lua.LValue) {
+		i, ok := k.(lua.LNumber)
+		if !ok || i < 1 || i != lua.LNumber(math.Trunc(float64(i))) {
+			isArray = false
+			return
+		}
+		n++
+		if int(i) > max {
+			max = int(i)
+		}
+	})
+	return n, isArray && n == max
+}
+
+// msgpackHeader writes an array or map header.
+func msgpackHeader(buf *bytes.Buffer, n int, fix, c16, c32 byte) {
+	switch {
+	case n <= 15:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(c16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(c32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func msgpackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n <= 127:
+		buf.WriteByte(byte(n))
+	case n >= 0 && n <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(n)})
+	case n >= 0 && n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n >= 0 && n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	case n >= 0:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, uint64(n))
+	case n >= -32:
+		buf.WriteByte(byte(n))
+	case n >= math.MinInt8:
+		buf.Write([]byte{0xd0, byte(n)})
+	case n >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(n))
+	case n >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+type msgpackDecoder struct {
+	l *lua.LState
+	b []byte
+}
+
+// read takes n bytes
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n > len(d.b) {
+		return nil, errMsgpackMissing
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b, nil
+}
+
+// uint reads a big endian unsigned integer of n bytes
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (d *msgpackDecoder) decode() (lua.LValue, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	switch c := b[0]; {
+	case c <= 0x7f:
+		return lua.LNumber(c), nil
+	case c >= 0xe0:
+		return lua.LNumber(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.table(int(c&0x0f), true)
+	case c&0xf0 == 0x90:
+		return d.table(int(c&0x0f), false)
+	case c&0xe0 == 0xa0:
+		return d.str(uint64(c & 0x1f))
+	case c == 0xc0:
+		return lua.LNil, nil
+	case c == 0xc2:
+		return lua.LFalse, nil
+	case c == 0xc3:
+		return lua.LTrue, nil
+	case c == 0xca:
+		v, err := d.uint(4)
+		return lua.LNumber(math.Float32frombits(uint32(v))), err
+	case c == 0xcb:
+		v, err := d.uint(8)
+		return lua.LNumber(math.Float64frombits(v)), err
+	case c >= 0xcc && c <= 0xcf:
+		v, err := d.uint(1 << (c - 0xcc))
+		return lua.LNumber(v), err
+	case c >= 0xd0 && c <= 0xd3:
+		size := 1 << (c - 0xd0)
+		v, err := d.uint(size)
+		shift := uint(64 - 8*size)
+		return lua.LNumber(int64(v<<shift) >> shift), err
+	case c >= 0xc4 && c <= 0xc6, c >= 0xd9 && c <= 0xdb:
+		size := 1 << (c - 0xc4)
+		if c >= 0xd9 {
+			size = 1 << (c - 0xd9)
+		}
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+	case c == 0xdc, c == 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.table(int(n), false)
+	case c == 0xde, c == 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.table(int(n), true)
+	default:
+		return nil, errMsgpackFormat
+	}
+}
+
+func (d *msgpackDecoder) str(n uint64) (lua.LValue, error) {
+	if n > uint64(len(d.b)) {
+		return nil, errMsgpackMissing
+	}
+	b, _ := d.read(int(n))
+	return lua.LString(b), nil
+}
+
+// table decodes an array or a map with n elements.
+func (d *msgpackDecoder) table(n int, isMap bool) (lua.LValue, error) {
+	t := d.l.NewTable()
+	for i := 1; i <= n; i++ {
+		var k lua.LValue = lua.LNumber(i)
+		if isMap {
+			var err error
+			if k, err = d.decode(); err != nil {
+				return nil, err
+			}
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		if k != lua.LNil {
+			t.RawSet(k, v)
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,273 @@
+package miniredis
+
+// The "struct" Lua module, as used in Redis: struct.pack(), struct.unpack(),
+// and struct.size().
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	structMaxAlign   = 8
+	structMaxIntSize = 8
+)
+
+var luaStructFuncs = map[string]lua.LGFunction{
+	"pack":   luaStructPack,
+	"unpack": luaStructUnpack,
+	"size":   luaStructSize,
+}
+
+func luaStructLoader(l *lua.LState) int {
+	l.Push(l.SetFuncs(l.NewTable(), luaStructFuncs))
+	return 1
+}
+
+// structFormat walks over a struct format string.
+type structFormat struct {
+	l      *lua.LState
+	fmt    string
+	endian binary.ByteOrder
+	align  int
+}
+
+func newStructFormat(l *lua.LState) *structFormat {
+	return &structFormat{
+		l:      l,
+		fmt:    l.CheckString(1),
+		endian: binary.LittleEndian,
+		align:  1,
+	}
+}
+
+// next returns the next option and its size. Options which don't take any
+// data (endianness, alignment, spaces) are handled here, and have size 0.
+func (f *structFormat) next() (byte, int) {
+	opt := f.fmt[0]
+	f.fmt = f.fmt[1:]
+	switch opt {
+	case 'b', 'B':
+		return opt, 1
+	case 'h', 'H':
+		return opt, 2
+	case 'l', 'L', 'T', 'd':
+		return opt, 8
+	case 'f':
+		return opt, 4
+	case 'x':
+		return opt, 1
+	case 'c':
+		return opt, f.num(1)
+	case 'i', 'I':
+		n := f.num(4)
+		if n > structMaxIntSize {
+			f.l.RaiseError("integral size %d is larger than limit of %d", n, structMaxIntSize)
+		}
+		return opt, n
+	case 's':
+		return opt, 0
+	case ' ':
+	case '>':
+		f.endian = binary.BigEndian
+	case '<':
+		f.endian = binary.LittleEndian
+	case '!':
+		a := f.num(structMaxAlign)
+		if a <= 0 || a&(a-1) != 0 {
+			f.l.RaiseError("alignment %d is not a power of 2", a)
+		}
+		f.align = a
+	default:
+		f.l.ArgError(1, fmt.Sprintf("invalid format option '%c'", opt))
+	}
+	return opt, 0
+}
+
+// num reads an optional number from the format.
+func (f *structFormat) num(def int) int {
+	i := 0
+	for i < len(f.fmt) && f.fmt[i] >= '0' && f.fmt[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return def
+	}
+	n := 0
+	for _, c := range f.fmt[:i] {
+		n = n*10 + int(c-'0')
+	}
+	f.fmt = f.fmt[i:]
+	return n
+}
+
+// padding is the number of bytes needed to align an option at pos.
+func (f *structFormat) padding(pos int, opt byte, size int) int {
+	if size == 0 || opt == 'c' {
+		return 0
+	}
+	if size > f.align {
+		size = f.align
+	}
+	return (size - (pos & (size - 1))) & (size - 1)
+}
+
+func (f *structFormat) putInt(buf *bytes.Buffer, v uint64, size int) {
+	var b [8]byte
+	if f.endian == binary.BigEndian {
+		binary.BigEndian.PutUint64(b[:], v)
+		buf.Write(b[8-size:])
+	} else {
+		binary.LittleEndian.PutUint64(b[:], v)
+		buf.Write(b[:size])
+	}
+}
+
+func (f *structFormat) getInt(data string, signed bool, size int) lua.LNumber {
+	var (
+		b [8]byte
+		v uint64
+	)
+	if f.endian == binary.BigEndian {
+		copy(b[8-size:], data[:size])
+		v = binary.BigEndian.Uint64(b[:])
+	} else {
+		copy(b[:size], data[:size])
+		v = binary.LittleEndian.Uint64(b[:])
+	}
+	if signed && size < 8 {
+		shift := uint(64 - 8*size)
+		return lua.LNumber(int64(v<<shift) >> shift)
+	}
+	if signed {
+		return lua.LNumber(int64(v))
+	}
+	return lua.LNumber(v)
+}
+
+// struct.pack(format, ...)
+func luaStructPack(l *lua.LState) int {
+	f := newStructFormat(l)
+	var buf bytes.Buffer
+	arg := 2
+	for len(f.fmt) > 0 {
+		opt, size := f.next()
+		buf.Write(make([]byte, f.padding(buf.Len(), opt, size)))
+		switch opt {
+		case 'b', 'B', 'h', 'H', 'l', 'L', 'T', 'i', 'I':
+			n := float64(l.CheckNumber(arg))
+			arg++
+			var v uint64
+			if n < 0 {
+				v = uint64(int64(n))
+			} else {
+				v = uint64(n)
+			}
+			f.putInt(&buf, v, size)
+		case 'x':
+			buf.WriteByte(0)
+		case 'f':
+			var b [4]byte
+			f.endian.PutUint32(b[:], math.Float32bits(float32(l.CheckNumber(arg))))
+			arg++
+			buf.Write(b[:])
+		case 'd':
+			var b [8]byte
+			f.endian.PutUint64(b[:], math.Float64bits(float64(l.CheckNumber(arg))))
+			arg++
+			buf.Write(b[:])
+		case 'c', 's':
+			s := l.CheckString(arg)
+			arg++
+			if size == 0 {
+				size = len(s)
+			}
+			if len(s) < size {
+				l.ArgError(arg-1, "string too short")
+			}
+			buf.WriteString(s[:size])
+			if opt == 's' {
+				buf.WriteByte(0)
+			}
+		}
+	}
+	l.Push(lua.LString(buf.String()))
+	return 1
+}
+
+// struct.unpack(format, data, [init])
+func luaStructUnpack(l *lua.LState) int {
+	f := newStructFormat(l)
+	data := l.CheckString(2)
+	pos := l.OptInt(3, 1) - 1
+	if pos < 0 || pos > len(data) {
+		l.ArgError(3, "offset must be 1 or greater")
+	}
+	var res []lua.LValue
+	for len(f.fmt) > 0 {
+		opt, size := f.next()
+		pos += f.padding(pos, opt, size)
+		if pos+size > len(data) {
+			l.ArgError(2, "data string too short")
+		}
+		switch opt {
+		case 'b', 'B', 'h', 'H', 'l', 'L', 'T', 'i', 'I':
+			res = append(res, f.getInt(data[pos:], opt >= 'a', size))
+		case 'f':
+			res = append(res, lua.LNumber(math.Float32frombits(f.endian.Uint32([]byte(data[pos:pos+4])))))
+		case 'd':
+			res = append(res, lua.LNumber(math.Float64frombits(f.endian.Uint64([]byte(data[pos:pos+8])))))
+		case 'c':
+			if size == 0 {
+				var prev lua.LNumber
+				ok := false
+				if len(res) > 0 {
+					prev, ok = res[len(res)-1].(lua.LNumber)
+				}
+				if !ok {
+					l.RaiseError("format 'c0' needs a previous size")
+				}
+				size = int(prev)
+				res = res[:len(res)-1]
+				if size < 0 || pos+size > len(data) {
+					l.ArgError(2, "data string too short")
+				}
+			}
+			res = append(res, lua.LString(data[pos:pos+size]))
+		case 's':
+			e := strings.IndexByte(data[pos:], 0)
+			if e < 0 {
+				l.RaiseError("unfinished string in data")
+			}
+			res = append(res, lua.LString(data[pos:pos+e]))
+			size = e + 1
+		}
+		pos += size
+	}
+	for _, v := range res {
+		l.Push(v)
+	}
+	l.Push(lua.LNumber(pos + 1))
+	return len(res) + 1
+}
+
+// struct.size(format)
+func luaStructSize(l *lua.LState) int {
+	f := newStructFormat(l)
+	pos := 0
+	for len(f.fmt) > 0 {
+		opt, size := f.next()
+		pos += f.padding(pos, opt, size)
+		if opt == 's' || (opt == 'c' && size == 0) {
+			l.ArgError(1, "variable-length format")
+		}
+		pos += size
+	}
+	l.Push(lua.LNumber(pos))
+	return 1
+}
@@ -30,7 +30,7 @@ import (
 )
 
 type hashKey map[string]string
-type listKey []string
+type hashTTL map[string]time.Duration
 type setKey map[string]struct{}
 
 // RedisDB holds a single (numbered) Redis database.
@@ -40,29 +40,52 @@ type RedisDB struct {
 	keys          map[string]string        // Master map of keys with their type
 	stringKeys    map[string]string        // GET/SET &c. keys
 	hashKeys      map[string]hashKey       // MGET/MSET &c. keys
-	listKeys      map[string]listKey       // LPUSH &c. keys
+	listKeys      map[string]*listKey      // LPUSH &c. keys
 	setKeys       map[string]setKey        // SADD &c. keys
 	sortedsetKeys map[string]sortedSet     // ZADD &c. keys
 	streamKeys    map[string]*streamKey    // XADD &c. keys
 	ttl           map[string]time.Duration // effective TTL values
 	keyVersion    map[string]uint          // used to watch values
+	lastAccess    map[string]time.Time     // for OBJECT IDLETIME
+	accessFreq    map[string]float64       // LFU counter, for OBJECT FREQ
+	hashTTLs      map[string]hashTTL       // HEXPIRE &c. field TTLs
+	memUsed       int                      // see usedMemory()
+	memSizes      map[string]int           // see usedMemory()
+	memDirty      map[string]struct{}      // see usedMemory()
 }
 
 // Miniredis is a Redis server implementation.
 type Miniredis struct {
 	sync.Mutex
-	srv         *server.Server
-	port        int
-	passwords   map[string]string // username password
-	dbs         map[int]*RedisDB
-	selectedDB  int               // DB id used in the direct Get(), Set() &c.
-	scripts     map[string]string // sha1 -> lua src
-	signal      *sync.Cond
-	now         time.Time // time.Now() if not set.
-	subscribers map[*Subscriber]struct{}
-	rand        *rand.Rand
-	Ctx         context.Context
-	CtxCancel   context.CancelFunc
+	srv             *server.Server
+	port            int
+	passwords       map[string]string // username password
+	dbs             map[int]*RedisDB
+	selectedDB      int                       // DB id used in the direct Get(), Set() &c.
+	scripts         map[string]string         // sha1 -> lua src
+	libraries       map[string]*luaLibrary    // FUNCTION LOAD libraries, by name
+	blocked         map[dbKey][]chan struct{} // clients in a blocking command
+	now             time.Time                 // time.Now() if not set.
+	subscribers     map[*Subscriber]struct{}
+	trackers        map[*server.Peer]*clientTracking // CLIENT TRACKING connections
+	changedKeys     map[string]struct{}              // see invalidateChanged()
+	scanCursors     map[int]scanCursor               // SCAN iterations, by cursor
+	lastCursor      int
+	scanClock       int // see scanPage()
+	rand            *rand.Rand
+	streamTrimBlock int                // see SetStreamApproxTrim()
+	notifyFlags     int                // see SetNotifyKeyspaceEvents()
+	maxmemoryPolicy string             // see SetMaxMemoryPolicy()
+	config          redisConfig        // see CONFIG SET
+	replicas        int                // see SetReplicas()
+	busy            *busyScript        // see SetBusyScript()
+	sleepBlocking   bool               // see SetDebugSleepBlocking()
+	sleepMu         sync.Mutex         // for sleepStop, which doesn't use m's lock
+	sleepStop       chan struct{}      // closed by Close(), see sleep()
+	started         time.Time          // for INFO
+	stopExpire      context.CancelFunc // see SetActiveExpire()
+	Ctx             context.Context
+	CtxCancel       context.CancelFunc
 }
 
 type txCmd func(*server.Peer, *connCtx)
@@ -82,6 +105,11 @@ type connCtx struct {
 	watch            map[dbKey]uint // WATCHed keys
 	subscriber       *Subscriber    // client is in PUBSUB mode if not nil
 	nested           bool           // this is called via Lua
+	name             string         // CLIENT SETNAME
+	blocked          bool           // waiting in a blocking command
+	created          time.Time      // when the client connected, for CLIENT LIST
+	lastActive       time.Time      // last command, for CLIENT LIST
+	lastCmd          string         // for CLIENT LIST
 }
 
 // NewMiniRedis makes a new, non-started, Miniredis object.
@@ -89,10 +117,14 @@ func NewMiniRedis() *Miniredis {
 	m := Miniredis{
 		dbs:         map[int]*RedisDB{},
 		scripts:     map[string]string{},
+		libraries:   map[string]*luaLibrary{},
 		subscribers: map[*Subscriber]struct{}{},
+		trackers:    map[*server.Peer]*clientTracking{},
+		blocked:     map[dbKey][]chan struct{}{},
+		scanCursors: map[int]scanCursor{},
+		config:      defaultConfig(),
 	}
 	m.Ctx, m.CtxCancel = context.WithCancel(context.Background())
-	m.signal = sync.NewCond(&m)
 	return &m
 }
 
@@ -103,12 +135,17 @@ func newRedisDB(id int, m *Miniredis) RedisDB {
 		keys:          map[string]string{},
 		stringKeys:    map[string]string{},
 		hashKeys:      map[string]hashKey{},
-		listKeys:      map[string]listKey{},
+		listKeys:      map[string]*listKey{},
 		setKeys:       map[string]setKey{},
 		sortedsetKeys: map[string]sortedSet{},
 		streamKeys:    map[string]*streamKey{},
 		ttl:           map[string]time.Duration{},
 		keyVersion:    map[string]uint{},
+		lastAccess:    map[string]time.Time{},
+		accessFreq:    map[string]float64{},
+		hashTTLs:      map[string]hashTTL{},
+		memSizes:      map[string]int{},
+		memDirty:      map[string]struct{}{},
 	}
 }
 
@@ -158,6 +195,14 @@ func (m *Miniredis) start(s *server.Server) error {
 	defer m.Unlock()
 	m.srv = s
 	m.port = s.Addr().Port
+	m.started = time.Now()
+	if m.Ctx.Err() != nil {
+		// restarted after a Close()
+		m.Ctx, m.CtxCancel = context.WithCancel(context.Background())
+	}
+	m.sleepMu.Lock()
+	m.sleepStop = nil
+	m.sleepMu.Unlock()
 
 	commandsConnection(m)
 	commandsGeneric(m)
@@ -172,12 +217,72 @@ func (m *Miniredis) start(s *server.Server) error {
 	commandsTransaction(m)
 	commandsScripting(m)
 	commandsGeo(m)
+	commandsHll(m)
 	commandsCluster(m)
 	commandsCommand(m)
 
+	s.SetCheckHook(m.checkOOM)
+	s.SetPostHook(m.postCommand)
+	s.SetUnknownHook(m.unknownCommand)
+	s.SetConnectHook(m.clientConnect)
 	return nil
 }
 
+// clientConnect runs for every new client. Clients beyond maxclients are
+// refused.
+func (m *Miniredis) clientConnect(c *server.Peer) bool {
+	m.Lock()
+	defer m.Unlock()
+	if m.srv == nil {
+		return false
+	}
+	if len(m.srv.Peers()) > m.config.maxclients {
+		c.WriteError(msgMaxClients)
+		return false
+	}
+	ctx := getCtx(c)
+	ctx.created = m.effectiveNow()
+	ctx.lastActive = ctx.created
+	return true
+}
+
+// checkOOM refuses commands which can use more memory once we're past
+// maxmemory. We never evict anything, so with any policy other than
+// "noeviction" the commands just go through.
+func (m *Miniredis) checkOOM(c *server.Peer, cmd string, args ...string) bool {
+	if getCtx(c).nested {
+		return false
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if m.config.maxmemory == 0 || (m.maxmemoryPolicy != "" && m.maxmemoryPolicy != "noeviction") {
+		return false
+	}
+	if !commandInfos[cmd].hasFlag("denyoom") {
+		return false
+	}
+	if m.usedMemory() <= m.config.maxmemory {
+		return false
+	}
+	setDirty(c)
+	c.WriteError(msgOOM)
+	return true
+}
+
+// postCommand runs after every command.
+func (m *Miniredis) postCommand(c *server.Peer, cmd string, args ...string) {
+	m.touchKeys(c, cmd, args...)
+	m.trackKeys(c, cmd, args...)
+	m.clientActive(c, cmd, args...)
+}
+
+// unknownCommand runs after every unknown command. Same as a wrong number of
+// arguments, it makes a MULTI fail.
+func (m *Miniredis) unknownCommand(c *server.Peer, cmd string, args ...string) {
+	setDirty(c)
+}
+
 // Restart restarts a Close()d server on the same port. Values will be
 // preserved.
 func (m *Miniredis) Restart() error {
@@ -186,15 +291,29 @@ func (m *Miniredis) Restart() error {
 
 // Close shuts down a Miniredis.
 func (m *Miniredis) Close() {
+	// a DEBUG SLEEP can hold the lock. Until a restart nothing sleeps.
+	m.sleepMu.Lock()
+	if m.sleepStop == nil {
+		m.sleepStop = make(chan struct{})
+	}
+	select {
+	case <-m.sleepStop:
+	default:
+		close(m.sleepStop)
+	}
+	m.sleepMu.Unlock()
+
 	m.Lock()
 
+	// Subscribe() and SetActiveExpire() also work without a running server.
+	m.CtxCancel()
 	if m.srv == nil {
 		m.Unlock()
 		return
 	}
 	srv := m.srv
 	m.srv = nil
-	m.CtxCancel()
+	m.scanCursors = map[int]scanCursor{}
 	m.Unlock()
 
 	// the OnDisconnect callbacks can lock m, so run Close() outside the lock.
@@ -253,12 +372,27 @@ func (m *Miniredis) swapDB(i, j int) bool {
 	db1 := m.db(i)
 	db2 := m.db(j)
 
+	// Key versions are per DB, so every key in either DB needs a version
+	// newer than what both DBs had.
+	for _, db := range []*RedisDB{db1, db2} {
+		for k := range db.keys {
+			if v := db2.keyVersion[k]; v > db1.keyVersion[k] {
+				db1.keyVersion[k] = v
+			}
+			db2.keyVersion[k] = db1.keyVersion[k]
+			db1.markDirty(k)
+			db2.markDirty(k)
+		}
+	}
+
 	db1.id = j
 	db2.id = i
 
 	m.dbs[i] = db2
 	m.dbs[j] = db1
 
+	m.dbReady(i)
+	m.dbReady(j)
 	return true
 }
 
@@ -306,13 +440,84 @@ func (m *Miniredis) TotalConnectionCount() int {
 }
 
 // FastForward decreases all TTLs by the given duration. All TTLs <= 0 will be
-// expired.
+// expired. Stream consumers and pending entries will be that much more idle.
 func (m *Miniredis) FastForward(duration time.Duration) {
 	m.Lock()
 	defer m.Unlock()
 	for _, db := range m.dbs {
 		db.fastForward(duration)
 	}
+	m.closeIdleClients(duration)
+}
+
+// closeIdleClients disconnects clients which have been idle for longer than
+// "timeout", now that FastForward() moved the clock. Subscribers and blocked
+// clients are never idle, same as in Redis. Needs to run m.Lock()ed.
+func (m *Miniredis) closeIdleClients(duration time.Duration) {
+	if m.srv == nil {
+		return
+	}
+	now := m.effectiveNow()
+	for _, p := range m.srv.Peers() {
+		ctx, _ := p.Ctx.(*connCtx)
+		if ctx == nil {
+			continue
+		}
+		ctx.created = ctx.created.Add(-duration)
+		ctx.lastActive = ctx.lastActive.Add(-duration)
+		if m.config.timeout == 0 || ctx.subscriber != nil || ctx.blocked {
+			continue
+		}
+		if now.Sub(ctx.lastActive) > time.Duration(m.config.timeout)*time.Second {
+			m.srv.Disconnect(p)
+		}
+	}
+}
+
+// SetActiveExpire starts a background loop which expires keys without anyone
+// touching them, the way Redis' active expire cycle does. Every interval all
+// TTLs are lowered by however much the clock moved since the previous round,
+// and keys whose TTL runs out are removed. The clock is time.Now(), or
+// whatever SetTime() set, so with a fixed SetTime() keys only expire when you
+// move the time forward. Expired keys wake up blocked clients and send
+// "expired" keyspace events. Use 0 to stop the loop, which is the default.
+// The loop also stops on Close().
+func (m *Miniredis) SetActiveExpire(interval time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	if m.stopExpire != nil {
+		m.stopExpire()
+		m.stopExpire = nil
+	}
+	if interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(m.Ctx)
+	m.stopExpire = cancel
+	go m.activeExpire(ctx, interval, m.effectiveNow())
+}
+
+func (m *Miniredis) activeExpire(ctx context.Context, interval time.Duration, last time.Time) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		m.Lock()
+		if ctx.Err() == nil {
+			now := m.effectiveNow()
+			if d := now.Sub(last); d > 0 {
+				for _, db := range m.dbs {
+					db.expireKeys(d)
+				}
+			}
+			last = now
+		}
+		m.Unlock()
+	}
 }
 
 // Server returns the underlying server to allow custom commands to be implemented
@@ -350,7 +555,7 @@ func (m *Miniredis) Dump() string {
 				r += fmt.Sprintf("%s%s: %s\n", indent, hk, v(db.hashGet(k, hk)))
 			}
 		case "list":
-			for _, lk := range db.listKeys[k] {
+			for _, lk := range db.listKeys[k].elems() {
 				r += fmt.Sprintf("%s%s\n", indent, v(lk))
 			}
 		case "set":
@@ -392,6 +597,7 @@ func (m *Miniredis) SetError(msg string) {
 	cb := server.Hook(nil)
 	if msg != "" {
 		cb = func(c *server.Peer, cmd string, args ...string) bool {
+			setDirty(c)
 			c.WriteError(msg)
 			return true
 		}
@@ -411,6 +617,7 @@ func (m *Miniredis) handleAuth(c *server.Peer) bool {
 		return true
 	}
 	if !getCtx(c).authenticated {
+		setDirty(c)
 		c.WriteError("NOAUTH Authentication required.")
 		return false
 	}
@@ -510,6 +717,14 @@ func (m *Miniredis) publish(c, msg string) int {
 	return n
 }
 
+func (m *Miniredis) spublish(c, msg string) int {
+	n := 0
+	for s := range m.subscribers {
+		n += s.Spublish(c, msg)
+	}
+	return n
+}
+
 // enter 'subscribed state', or return the existing one.
 func (m *Miniredis) subscribedState(c *server.Peer) *Subscriber {
 	ctx := getCtx(c)
@@ -529,13 +744,12 @@ func (m *Miniredis) subscribedState(c *server.Peer) *Subscriber {
 
 	ctx.subscriber = sub
 
-	go monitorPublish(c, sub.publish)
-	go monitorPpublish(c, sub.ppublish)
+	go monitorSubscriber(c, sub)
 
 	return sub
 }
 
-// whenever the p?sub count drops to 0 subscribed state should be stopped, and
+// whenever the [ps]?sub count drops to 0 subscribed state should be stopped, and
 // all redis commands are allowed again.
 func endSubscriber(m *Miniredis, c *server.Peer) {
 	ctx := getCtx(c)
@@ -559,6 +773,58 @@ func (m *Miniredis) NewSubscriber() *Subscriber {
 	return sub
 }
 
+// Subscribe returns a channel with all messages published to channels matching
+// any of the patterns, same as PSUBSCRIBE. A message matching more than one
+// pattern is received once per pattern. Messages are queued, so publishing
+// never blocks on reading the channel.
+// The channel is closed by Close(), which also ends the subscription. That
+// works the same for a server which was never started.
+func (m *Miniredis) Subscribe(patterns ...string) <-chan PubsubMessage {
+	sub := newSubscriber()
+	for _, p := range patterns {
+		sub.Psubscribe(p)
+	}
+
+	m.Lock()
+	m.addSubscriber(sub)
+	ctx := m.Ctx
+	m.Unlock()
+
+	out := make(chan PubsubMessage)
+	go func() {
+		defer close(out)
+		in := sub.Pmessages()
+		var queue []PubsubMessage
+		for {
+			var (
+				send chan<- PubsubMessage
+				next PubsubMessage
+			)
+			if len(queue) > 0 {
+				send, next = out, queue[0]
+			}
+			select {
+			case msg := <-in:
+				queue = append(queue, PubsubMessage{msg.Channel, msg.Message})
+			case send <- next:
+				queue = queue[1:]
+			case <-ctx.Done():
+				// a publish might be waiting on us while holding the lock, so
+				// keep reading until the subscriber is closed.
+				go func() {
+					m.Lock()
+					m.removeSubscriber(sub)
+					m.Unlock()
+				}()
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
 func (m *Miniredis) allSubscribers() []*Subscriber {
 	var subs []*Subscriber
 	for s := range m.subscribers {
@@ -575,6 +841,148 @@ func (m *Miniredis) Seed(seed int) {
 	m.rand = rand.New(rand.NewSource(int64(seed)))
 }
 
+// SetStreamApproxTrim changes how "XADD key MAXLEN ~ n" trims streams. By
+// default "~" is treated as an exact trim. With a block size > 0 entries are
+// removed in chunks of that many entries, the way Redis removes whole nodes,
+// so a stream can end up longer than n (but never shorter).
+// Use 0 to go back to exact trimming.
+func (m *Miniredis) SetStreamApproxTrim(block int) {
+	m.Lock()
+	defer m.Unlock()
+	m.streamTrimBlock = block
+}
+
+// SetNotifyKeyspaceEvents enables keyspace notifications, the same as
+// "notify-keyspace-events" does in redis.conf. For example "KEA" enables
+// everything, and "Et" only sends __keyevent@<db>__ events for streams. Use ""
+// to disable notifications again, which is the default.
+func (m *Miniredis) SetNotifyKeyspaceEvents(flags string) error {
+	f, err := parseNotifyFlags(flags)
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.notifyFlags = f
+	return nil
+}
+
+// SetMaxMemoryPolicy sets "maxmemory-policy". Miniredis never evicts
+// anything, but the policy decides whether OBJECT FREQ or OBJECT IDLETIME can
+// be used, and only "noeviction" refuses writes past "maxmemory". The default
+// is "noeviction".
+func (m *Miniredis) SetMaxMemoryPolicy(policy string) error {
+	if !validMaxMemoryPolicy(policy) {
+		return fmt.Errorf("invalid maxmemory-policy: %q", policy)
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.maxmemoryPolicy = policy
+	return nil
+}
+
+// SetAppendOnly sets "appendonly". Miniredis never writes anything to disk,
+// but WAITAOF acknowledges the local fsync when this is enabled. The default
+// is false.
+func (m *Miniredis) SetAppendOnly(enabled bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.config.appendonly = enabled
+}
+
+func validMaxMemoryPolicy(policy string) bool {
+	switch policy {
+	case "volatile-lru", "allkeys-lru", "volatile-lfu", "allkeys-lfu",
+		"volatile-random", "allkeys-random", "volatile-ttl", "noeviction":
+		return true
+	default:
+		return false
+	}
+}
+
+// SetReplicas sets the number of replicas WAIT reports as acknowledged.
+// Miniredis has no replication, and WAIT never blocks. The default is 0.
+func (m *Miniredis) SetReplicas(n int) {
+	m.Lock()
+	defer m.Unlock()
+	m.replicas = n
+}
+
+// SetDebugSleepBlocking makes DEBUG SLEEP block the whole server, the way it
+// does in Redis. By default only the connection which sent the command sleeps.
+func (m *Miniredis) SetDebugSleepBlocking(b bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.sleepBlocking = b
+}
+
+// SetBusyScript makes the server behave as if an EVAL script is running for a
+// long time: other clients get a BUSY error for most commands, until SCRIPT
+// KILL, SHUTDOWN NOSAVE, or ClearBusyScript(). With dirty the script has
+// already written to the dataset, and SCRIPT KILL won't stop it.
+// Any SetError() message is back once the script stops.
+func (m *Miniredis) SetBusyScript(dirty bool) {
+	m.setBusy(&busyScript{dirty: dirty})
+}
+
+// SetBusyFunction is SetBusyScript(), but for a function called with FCALL.
+// Use FUNCTION KILL to stop it.
+func (m *Miniredis) SetBusyFunction(name string, dirty bool) {
+	m.setBusy(&busyScript{function: name, dirty: dirty})
+}
+
+// ClearBusyScript stops the script started with SetBusyScript() or
+// SetBusyFunction().
+func (m *Miniredis) ClearBusyScript() {
+	m.setBusy(nil)
+}
+
+// lfuPolicy is whether the maxmemory-policy uses LFU, not LRU.
+func (m *Miniredis) lfuPolicy() bool {
+	return strings.HasSuffix(m.maxmemoryPolicy, "-lfu")
+}
+
+// lruPolicy is whether the maxmemory-policy uses LRU.
+func (m *Miniredis) lruPolicy() bool {
+	return strings.HasSuffix(m.maxmemoryPolicy, "-lru")
+}
+
+// Commands which don't change the last access time of their keys.
+var noTouchCommands = map[string]bool{
+	"EXISTS":  true,
+	"OBJECT":  true,
+	"PTTL":    true,
+	"RESTORE": true,
+	"TTL":     true,
+	"TYPE":    true,
+	"WATCH":   true,
+}
+
+// touchKeys updates the access time and frequency of all keys used by a
+// command.
+func (m *Miniredis) touchKeys(c *server.Peer, cmd string, args ...string) {
+	if noTouchCommands[cmd] {
+		return
+	}
+	keys := commandKeys(cmd, args)
+	if len(keys) == 0 {
+		return
+	}
+	ctx := getCtx(c)
+	if inTx(ctx) {
+		return
+	}
+	if !ctx.nested {
+		m.Lock()
+		defer m.Unlock()
+	}
+	db := m.db(ctx.selectedDB)
+	now := m.effectiveNow()
+	for _, k := range keys {
+		db.touch(k, now)
+	}
+}
+
 func (m *Miniredis) randIntn(n int) int {
 	if m.rand == nil {
 		return rand.Intn(n)
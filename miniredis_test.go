@@ -237,6 +237,57 @@ func TestExpireWithFastForward(t *testing.T) {
 	equals(t, 1, len(s.Keys()))
 }
 
+func TestActiveExpire(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	sub, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer sub.Close()
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.SetTime(now)
+	ok(t, s.SetNotifyKeyspaceEvents("Ex"))
+	mustDo(t, sub,
+		"SUBSCRIBE", "__keyevent@0__:expired",
+		proto.Array(
+			proto.String("subscribe"),
+			proto.String("__keyevent@0__:expired"),
+			proto.Int(1),
+		),
+	)
+
+	s.Set("aap", "noot")
+	s.Set("noot", "aap")
+	s.SetTTL("aap", 10*time.Second)
+	s.SetActiveExpire(time.Millisecond)
+
+	// Rounds while the clock doesn't move change nothing, so once a round
+	// after the SetTime() has run the TTL is exactly 4s lower.
+	s.SetTime(now.Add(4 * time.Second))
+	deadline := time.Now().Add(5 * time.Second)
+	for s.TTL("aap") != 6*time.Second {
+		assert(t, time.Now().Before(deadline), "TTL never got lowered: %s", s.TTL("aap"))
+		time.Sleep(time.Millisecond)
+	}
+	equals(t, 2, len(s.Keys()))
+
+	s.SetTime(now.Add(10 * time.Second))
+	mustRead(t, sub,
+		proto.Strings("message", "__keyevent@0__:expired", "aap"),
+	)
+	equals(t, []string{"noot"}, s.Keys())
+
+	t.Run("stop", func(t *testing.T) {
+		s.SetTTL("noot", 10*time.Second)
+		// no round runs after SetActiveExpire(0) returns
+		s.SetActiveExpire(0)
+		s.SetTime(now.Add(time.Minute))
+		equals(t, []string{"noot"}, s.Keys())
+		equals(t, 10*time.Second, s.TTL("noot"))
+	})
+}
+
 /*
 we don't have the redis client anymore
 func TestPool(t *testing.T) {
@@ -0,0 +1,115 @@
+package miniredis
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Keyspace notification classes, as used in "notify-keyspace-events".
+const (
+	notifyKeyspace = 1 << iota // K
+	notifyKeyevent             // E
+	notifyGeneric              // g
+	notifyString               // $
+	notifyList                 // l
+	notifySet                  // s
+	notifyHash                 // h
+	notifyZset                 // z
+	notifyExpired              // x
+	notifyEvicted              // e
+	notifyStream               // t
+	notifyKeyMiss              // m
+	notifyNew                  // n
+	notifyModule               // d, never sent, we have no modules
+
+	// A, which doesn't include 'm' and 'n'
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash | notifyZset | notifyExpired | notifyEvicted | notifyStream | notifyModule
+)
+
+var notifyClasses = []struct {
+	c    byte
+	flag int
+}{
+	{'g', notifyGeneric},
+	{'$', notifyString},
+	{'l', notifyList},
+	{'s', notifySet},
+	{'h', notifyHash},
+	{'z', notifyZset},
+	{'x', notifyExpired},
+	{'e', notifyEvicted},
+	{'t', notifyStream},
+	{'d', notifyModule},
+	{'m', notifyKeyMiss},
+	{'n', notifyNew},
+	{'K', notifyKeyspace},
+	{'E', notifyKeyevent},
+}
+
+var errInvalidNotifyFlags = errors.New("Invalid event class character. Use 'Ag$lshzxeKEtmdn'.")
+
+// parseNotifyFlags parses a "notify-keyspace-events" string, such as "KEA" or
+// "Et".
+func parseNotifyFlags(s string) (int, error) {
+	flags := 0
+outer:
+	for i := 0; i < len(s); i++ {
+		if s[i] == 'A' {
+			flags |= notifyAll
+			continue
+		}
+		for _, nc := range notifyClasses {
+			if nc.c == s[i] {
+				flags |= nc.flag
+				continue outer
+			}
+		}
+		return 0, errInvalidNotifyFlags
+	}
+	return flags, nil
+}
+
+// notifyFlagsString formats flags the same way Redis does for CONFIG GET:
+// classes first (with "A" if they're all set), then "K", "E", "m", and "n".
+func notifyFlagsString(flags int) string {
+	var res []byte
+	if flags&notifyAll == notifyAll {
+		res = append(res, 'A')
+	} else {
+		for _, nc := range notifyClasses {
+			if nc.flag&notifyAll != 0 && flags&nc.flag != 0 {
+				res = append(res, nc.c)
+			}
+		}
+	}
+	for _, c := range "KEmn" {
+		for _, nc := range notifyClasses {
+			if nc.c == byte(c) && flags&nc.flag != 0 {
+				res = append(res, nc.c)
+			}
+		}
+	}
+	return string(res)
+}
+
+// notify publishes a keyspace event, if notifications are enabled for the
+// event's class. Must be called with the lock held.
+func (m *Miniredis) notify(db int, class int, event, key string) {
+	if m.notifyFlags&class == 0 {
+		return
+	}
+	if m.notifyFlags&notifyKeyspace != 0 {
+		m.publish(fmt.Sprintf("__keyspace@%d__:%s", db, key), event)
+	}
+	if m.notifyFlags&notifyKeyevent != 0 {
+		m.publish(fmt.Sprintf("__keyevent@%d__:%s", db, event), key)
+	}
+}
+
+// notifyDeleted sends a "del" event if key is gone, for commands which remove
+// a key after they took out its last element.
+func (m *Miniredis) notifyDeleted(db *RedisDB, key string) {
+	if !db.exists(key) {
+		m.notify(db.id, notifyGeneric, "del", key)
+	}
+}
@@ -0,0 +1,163 @@
+package miniredis
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2/proto"
+)
+
+func TestNotifyFlags(t *testing.T) {
+	for flags, want := range map[string]string{
+		"":      "",
+		"KEA":   "AKE",
+		"Ex":    "xE",
+		"E$lg":  "g$lE",
+		"AKEmn": "AKEmn",
+		"Kgslh": "glshK",
+		"Ag$":   "A",
+		"Etd":   "tdE",
+
+		"g$lshzxet": "g$lshzxet",
+	} {
+		f, err := parseNotifyFlags(flags)
+		ok(t, err)
+		equals(t, want, notifyFlagsString(f))
+	}
+
+	_, err := parseNotifyFlags("Kq")
+	equals(t, errInvalidNotifyFlags, err)
+}
+
+func TestKeyspaceEvents(t *testing.T) {
+	s, err := Run()
+	ok(t, err)
+	defer s.Close()
+	c, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer c.Close()
+	sub, err := proto.Dial(s.Addr())
+	ok(t, err)
+	defer sub.Close()
+
+	mustOK(t, c, "CONFIG", "SET", "notify-keyspace-events", "EA")
+
+	mustDo(t, sub,
+		"PSUBSCRIBE", "__key*",
+		proto.Array(
+			proto.String("psubscribe"),
+			proto.String("__key*"),
+			proto.Int(1),
+		),
+	)
+	event := func(ev, key string) {
+		t.Helper()
+		mustRead(t, sub,
+			proto.Strings("pmessage", "__key*", "__keyevent@0__:"+ev, key),
+		)
+	}
+
+	t.Run("generic", func(t *testing.T) {
+		mustOK(t, c, "SET", "foo", "bar")
+		event("set", "foo")
+		must1(t, c, "EXPIRE", "foo", "100")
+		event("expire", "foo")
+		must1(t, c, "PERSIST", "foo")
+		event("persist", "foo")
+		mustOK(t, c, "RENAME", "foo", "bar")
+		event("rename_from", "foo")
+		event("rename_to", "bar")
+		must1(t, c, "COPY", "bar", "baz")
+		event("copy_to", "baz")
+		must1(t, c, "MOVE", "baz", "2")
+		event("move_from", "baz")
+		mustRead(t, sub,
+			proto.Strings("pmessage", "__key*", "__keyevent@2__:move_to", "baz"),
+		)
+		mustDo(t, c, "DEL", "bar", "nosuch", proto.Int(1))
+		event("del", "bar")
+
+		mustOK(t, c, "SET", "foo", "bar", "EX", "100")
+		event("set", "foo")
+		event("expire", "foo")
+		must1(t, c, "PEXPIRE", "foo", "-1")
+		event("del", "foo")
+	})
+
+	t.Run("string", func(t *testing.T) {
+		must1(t, c, "INCR", "counter")
+		event("incrby", "counter")
+		mustDo(t, c, "INCRBYFLOAT", "counter", "1.5", proto.String("2.5"))
+		event("incrbyfloat", "counter")
+		mustDo(t, c, "APPEND", "counter", "0", proto.Int(4))
+		event("append", "counter")
+		mustDo(t, c, "SETRANGE", "counter", "0", "3", proto.Int(4))
+		event("setrange", "counter")
+		mustDo(t, c, "GETDEL", "counter", proto.String("3.50"))
+		event("del", "counter")
+	})
+
+	t.Run("list", func(t *testing.T) {
+		mustDo(t, c, "RPUSH", "l", "a", "b", "c", proto.Int(3))
+		event("rpush", "l")
+		mustDo(t, c, "LPOP", "l", proto.String("a"))
+		event("lpop", "l")
+		mustDo(t, c, "RPOPLPUSH", "l", "l2", proto.String("c"))
+		event("rpop", "l")
+		event("lpush", "l2")
+		mustOK(t, c, "LTRIM", "l", "1", "-1")
+		event("ltrim", "l")
+		event("del", "l")
+		mustDo(t, c, "RPOP", "l2", proto.String("c"))
+		event("rpop", "l2")
+		event("del", "l2")
+	})
+
+	t.Run("set", func(t *testing.T) {
+		mustDo(t, c, "SADD", "s", "a", "b", proto.Int(2))
+		event("sadd", "s")
+		must0(t, c, "SADD", "s", "a")
+		must1(t, c, "SMOVE", "s", "s2", "a")
+		event("srem", "s")
+		event("sadd", "s2")
+		mustDo(t, c, "SUNIONSTORE", "s3", "s", "s2", proto.Int(2))
+		event("sunionstore", "s3")
+		must1(t, c, "SREM", "s", "b")
+		event("srem", "s")
+		event("del", "s")
+	})
+
+	t.Run("sorted set", func(t *testing.T) {
+		must1(t, c, "ZADD", "z", "1", "one")
+		event("zadd", "z")
+		must0(t, c, "ZADD", "z", "1", "one")
+		mustDo(t, c, "ZINCRBY", "z", "1", "one", proto.String("2"))
+		event("zincr", "z")
+		mustDo(t, c, "ZPOPMIN", "z", proto.Strings("one", "2"))
+		event("zpopmin", "z")
+		event("del", "z")
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		must1(t, c, "HSET", "h", "f", "1")
+		event("hset", "h")
+		mustDo(t, c, "HINCRBY", "h", "f", "1", proto.Int(2))
+		event("hincrby", "h")
+		must1(t, c, "HDEL", "h", "f")
+		event("hdel", "h")
+		event("del", "h")
+	})
+
+	t.Run("keyspace", func(t *testing.T) {
+		mustOK(t, c, "CONFIG", "SET", "notify-keyspace-events", "K$")
+		mustOK(t, c, "SET", "foo", "bar")
+		mustRead(t, sub,
+			proto.Strings("pmessage", "__key*", "__keyspace@0__:foo", "set"),
+		)
+		// no generic events
+		must1(t, c, "DEL", "foo")
+		mustOK(t, c, "SET", "foo", "baz")
+		mustRead(t, sub,
+			proto.Strings("pmessage", "__key*", "__keyspace@0__:foo", "set"),
+		)
+	})
+}
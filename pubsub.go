@@ -1,7 +1,6 @@
 package miniredis
 
 import (
-	"regexp"
 	"sort"
 	"sync"
 
@@ -20,23 +19,27 @@ type PubsubPmessage struct {
 	Message string
 }
 
-// Subscriber has the (p)subscriptions.
+// Subscriber has the (p)subscriptions, and the sharded subscriptions.
 type Subscriber struct {
-	publish  chan PubsubMessage
-	ppublish chan PubsubPmessage
-	channels map[string]struct{}
-	patterns map[string]*regexp.Regexp
-	mu       sync.Mutex
+	publish       chan PubsubMessage
+	ppublish      chan PubsubPmessage
+	spublish      chan PubsubMessage
+	channels      map[string]struct{}
+	patterns      map[string]struct{}
+	shardChannels map[string]struct{}
+	mu            sync.Mutex
 }
 
 // Make a new subscriber. The channel is not buffered, so you will need to keep
 // reading using Messages(). Use Close() when done, or unsubscribe.
 func newSubscriber() *Subscriber {
 	return &Subscriber{
-		publish:  make(chan PubsubMessage),
-		ppublish: make(chan PubsubPmessage),
-		channels: map[string]struct{}{},
-		patterns: map[string]*regexp.Regexp{},
+		publish:       make(chan PubsubMessage),
+		ppublish:      make(chan PubsubPmessage),
+		spublish:      make(chan PubsubMessage),
+		channels:      map[string]struct{}{},
+		patterns:      map[string]struct{}{},
+		shardChannels: map[string]struct{}{},
 	}
 }
 
@@ -44,6 +47,7 @@ func newSubscriber() *Subscriber {
 func (s *Subscriber) Close() {
 	close(s.publish)
 	close(s.ppublish)
+	close(s.spublish)
 }
 
 // Count the total number of channels and patterns
@@ -57,6 +61,21 @@ func (s *Subscriber) count() int {
 	return len(s.channels) + len(s.patterns)
 }
 
+// Count the number of shard channels. These are counted separately from
+// Count(), same as Redis does.
+func (s *Subscriber) ShardCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.shardChannels)
+}
+
+// active is whether there are any subscriptions left, of any kind.
+func (s *Subscriber) active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count()+len(s.shardChannels) > 0
+}
+
 // Subscribe to a channel. Returns the total number of (p)subscriptions after
 // subscribing.
 func (s *Subscriber) Subscribe(c string) int {
@@ -83,7 +102,7 @@ func (s *Subscriber) Psubscribe(pat string) int {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.patterns[pat] = patternRE(pat)
+	s.patterns[pat] = struct{}{}
 	return s.count()
 }
 
@@ -97,6 +116,26 @@ func (s *Subscriber) Punsubscribe(pat string) int {
 	return s.count()
 }
 
+// Subscribe to a shard channel. Returns the total number of shard
+// subscriptions after subscribing.
+func (s *Subscriber) Ssubscribe(c string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shardChannels[c] = struct{}{}
+	return len(s.shardChannels)
+}
+
+// Unsubscribe a shard channel. Returns the total number of shard
+// subscriptions after unsubscribing.
+func (s *Subscriber) Sunsubscribe(c string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.shardChannels, c)
+	return len(s.shardChannels)
+}
+
 // List all subscribed channels, in alphabetical order
 func (s *Subscriber) Channels() []string {
 	s.mu.Lock()
@@ -123,35 +162,69 @@ func (s *Subscriber) Patterns() []string {
 	return ps
 }
 
-// Publish a message. Will return return how often we sent the message (can be
-// a match for a subscription and for a psubscription.
+// List all subscribed shard channels, in alphabetical order
+func (s *Subscriber) ShardChannels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cs []string
+	for c := range s.shardChannels {
+		cs = append(cs, c)
+	}
+	sort.Strings(cs)
+	return cs
+}
+
+// subscribed is whether there is a SUBSCRIBE for the channel.
+func (s *Subscriber) subscribed(c string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.channels[c]
+	return ok
+}
+
+// Publish a message. Will return how often we sent the message: once for a
+// matching subscription, and once for every matching psubscription, same as
+// Redis. Pattern messages are sent in pattern order.
 func (s *Subscriber) Publish(c, msg string) int {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	found := 0
 
-subs:
-	for sub := range s.channels {
-		if sub == c {
-			s.publish <- PubsubMessage{c, msg}
-			found++
-			break subs
-		}
+	if _, ok := s.channels[c]; ok {
+		s.publish <- PubsubMessage{c, msg}
+		found++
 	}
 
-pats:
-	for orig, pat := range s.patterns {
-		if pat != nil && pat.MatchString(c) {
-			s.ppublish <- PubsubPmessage{orig, c, msg}
-			found++
-			break pats
+	var pats []string
+	for pat := range s.patterns {
+		if patternMatch(pat, c) {
+			pats = append(pats, pat)
 		}
 	}
+	sort.Strings(pats)
+	for _, pat := range pats {
+		s.ppublish <- PubsubPmessage{pat, c, msg}
+		found++
+	}
 
 	return found
 }
 
+// Publish a message to a shard channel. Returns 1 if the message was sent, 0
+// otherwise.
+func (s *Subscriber) Spublish(c, msg string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.shardChannels[c]; !ok {
+		return 0
+	}
+	s.spublish <- PubsubMessage{c, msg}
+	return 1
+}
+
 // The channel to read messages for this subscriber. Only for messages matching
 // a SUBSCRIBE.
 func (s *Subscriber) Messages() <-chan PubsubMessage {
@@ -164,24 +237,30 @@ func (s *Subscriber) Pmessages() <-chan PubsubPmessage {
 	return s.ppublish
 }
 
-// List all pubsub channels. If `pat` isn't empty channels names must match the
-// pattern. Channels are returned alphabetically.
-func activeChannels(subs []*Subscriber, pat string) []string {
+// The channel to read messages for this subscriber. Only for messages matching
+// an SSUBSCRIBE.
+func (s *Subscriber) Smessages() <-chan PubsubMessage {
+	return s.spublish
+}
+
+// List all pubsub channels, or shard channels if `shard` is set. If `pat`
+// isn't empty channels names must match the pattern. Channels are returned
+// alphabetically.
+func activeChannels(subs []*Subscriber, pat string, shard bool) []string {
 	channels := map[string]struct{}{}
 	for _, s := range subs {
-		for c := range s.channels {
+		cs := s.channels
+		if shard {
+			cs = s.shardChannels
+		}
+		for c := range cs {
 			channels[c] = struct{}{}
 		}
 	}
 
-	var cpat *regexp.Regexp
-	if pat != "" {
-		cpat = patternRE(pat)
-	}
-
 	var cs []string
 	for k := range channels {
-		if cpat != nil && !cpat.MatchString(k) {
+		if pat != "" && !patternMatch(pat, k) {
 			continue
 		}
 		cs = append(cs, k)
@@ -205,6 +284,17 @@ func countSubs(subs []*Subscriber, channel string) int {
 	return n
 }
 
+// Count all clients subscribed to the given shard channel.
+func countShardSubs(subs []*Subscriber, channel string) int {
+	n := 0
+	for _, p := range subs {
+		if _, ok := p.shardChannels[channel]; ok {
+			n++
+		}
+	}
+	return n
+}
+
 // Count the total of all client psubscriptions.
 func countPsubs(subs []*Subscriber) int {
 	n := 0
@@ -214,27 +304,44 @@ func countPsubs(subs []*Subscriber) int {
 	return n
 }
 
-func monitorPublish(conn *server.Peer, msgs <-chan PubsubMessage) {
-	for msg := range msgs {
-		conn.Block(func(c *server.Writer) {
-			c.WritePushLen(3)
-			c.WriteBulk("message")
-			c.WriteBulk(msg.Channel)
-			c.WriteBulk(msg.Message)
-			c.Flush()
-		})
+// monitorSubscriber writes all messages for a subscriber to the connection.
+// A single goroutine handles all kinds of messages, so they are written in the
+// order they were published.
+func monitorSubscriber(conn *server.Peer, sub *Subscriber) {
+	for {
+		select {
+		case msg, ok := <-sub.publish:
+			if !ok {
+				return
+			}
+			writeMessage(conn, "message", msg)
+		case msg, ok := <-sub.spublish:
+			if !ok {
+				return
+			}
+			writeMessage(conn, "smessage", msg)
+		case msg, ok := <-sub.ppublish:
+			if !ok {
+				return
+			}
+			conn.Block(func(c *server.Writer) {
+				c.WritePushLen(4)
+				c.WriteBulk("pmessage")
+				c.WriteBulk(msg.Pattern)
+				c.WriteBulk(msg.Channel)
+				c.WriteBulk(msg.Message)
+				c.Flush()
+			})
+		}
 	}
 }
 
-func monitorPpublish(conn *server.Peer, msgs <-chan PubsubPmessage) {
-	for msg := range msgs {
-		conn.Block(func(c *server.Writer) {
-			c.WritePushLen(4)
-			c.WriteBulk("pmessage")
-			c.WriteBulk(msg.Pattern)
-			c.WriteBulk(msg.Channel)
-			c.WriteBulk(msg.Message)
-			c.Flush()
-		})
-	}
+func writeMessage(conn *server.Peer, kind string, msg PubsubMessage) {
+	conn.Block(func(c *server.Writer) {
+		c.WritePushLen(3)
+		c.WriteBulk(kind)
+		c.WriteBulk(msg.Channel)
+		c.WriteBulk(msg.Message)
+		c.Flush()
+	})
 }
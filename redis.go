@@ -2,43 +2,121 @@ package miniredis
 
 import (
 	"fmt"
+	"math"
 	"math/big"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/alicebob/miniredis/v2/server"
 )
 
+// redisVersion is the version we report in HELLO and INFO.
+const redisVersion = "6.0.5"
+
 const (
 	msgWrongType          = "WRONGTYPE Operation against a key holding the wrong kind of value"
 	msgInvalidInt         = "ERR value is not an integer or out of range"
 	msgInvalidFloat       = "ERR value is not a valid float"
+	msgBitOffset          = "ERR bit offset is not an integer or out of range"
+	msgIncrOverflow       = "ERR increment or decrement would overflow"
+	msgDecrOverflow       = "ERR decrement would overflow"
+	msgIncrFloatInf       = "ERR increment would produce NaN or Infinity"
+	msgHashFloat          = "ERR hash value is not a float"
+	msgLCSWrongType       = "ERR The specified keys must contain string values"
+	msgLCSLenAndIdx       = "ERR If you want both the length and indexes, please just use IDX."
+	msgBitArgument        = "ERR The bit argument must be 1 or 0."
 	msgInvalidMinMax      = "ERR min or max is not a float"
 	msgInvalidRangeItem   = "ERR min or max not valid string range item"
+	msgLimitCombination   = "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX"
+	msgWithScoresLex      = "ERR syntax error, WITHSCORES not supported in combination with BYLEX"
 	msgInvalidTimeout     = "ERR timeout is not a float or out of range"
+	msgInvalidIntTimeout  = "ERR timeout is not an integer or out of range"
 	msgSyntaxError        = "ERR syntax error"
+	msgSameObject         = "ERR source and destination objects are the same"
+	msgBusyKey            = "BUSYKEY Target key name already exists."
+	msgInvalidTTL         = "ERR Invalid TTL value, must be >= 0"
+	msgInvalidIdletime    = "ERR Invalid IDLETIME value, must be >= 0"
+	msgInvalidFreq        = "ERR Invalid FREQ value, must be >= 0 and <= 255"
+	msgDumpPayload        = "ERR DUMP payload version or checksum are wrong"
+	msgBadDataFormat      = "ERR Bad data format"
+	msgMigrateKeys        = "ERR When using MIGRATE KEYS option, the key argument must be set to the empty string"
+	msgIOErrConnect       = "IOERR error or timeout connecting to the client"
+	msgIOErrWrite         = "IOERR error or timeout writing to target instance"
+	msgIOErrRead          = "IOERR error or timeout reading to target instance"
+	msgFTargetError       = "ERR Target instance replied with error: %s"
+	msgSortScores         = "ERR One or more scores can't be converted into double"
+	msgFUnsupportedOption = "ERR Unsupported option %s"
+	msgNXandXXGTLT        = "ERR NX and XX, GT or LT options at the same time are not compatible"
+	msgGTandLT            = "ERR GT and LT options at the same time are not compatible"
+	msgDBIndexOutOfRange  = "ERR DB index is out of range"
 	msgKeyNotFound        = "ERR no such key"
 	msgOutOfRange         = "ERR index out of range"
 	msgInvalidCursor      = "ERR invalid cursor"
 	msgXXandNX            = "ERR XX and NX options at the same time are not compatible"
 	msgNegTimeout         = "ERR timeout is negative"
+	msgPositive           = "ERR value is out of range, must be positive"
+	msgWaitaofAppendonly  = "ERR WAITAOF cannot be used when numlocal is set but appendonly is disabled."
 	msgInvalidSETime      = "ERR invalid expire time in set"
 	msgInvalidSETEXTime   = "ERR invalid expire time in setex"
 	msgInvalidPSETEXTime  = "ERR invalid expire time in psetex"
+	msgInvalidGETEXTime   = "ERR invalid expire time in getex"
 	msgInvalidKeysNumber  = "ERR Number of keys can't be greater than number of args"
 	msgNegativeKeysNumber = "ERR Number of keys can't be negative"
+	msgNumkeysPositive    = "ERR numkeys should be greater than 0"
+	msgLimitNegative      = "ERR LIMIT can't be negative"
+	msgCountPositive      = "ERR count should be greater than 0"
 	msgFScriptUsage       = "ERR Unknown subcommand or wrong number of arguments for '%s'. Try SCRIPT HELP."
+	msgFFunctionUsage     = "ERR Unknown subcommand or wrong number of arguments for '%s'. Try FUNCTION HELP."
 	msgFPubsubUsage       = "ERR Unknown subcommand or wrong number of arguments for '%s'. Try PUBSUB HELP."
+	msgFObjectUsage       = "ERR Unknown subcommand or wrong number of arguments for '%s'. Try OBJECT HELP."
+	msgObjectFreqPolicy   = "ERR An LFU maxmemory policy is not selected, access frequency not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust."
+	msgObjectIdlePolicy   = "ERR An LRU maxmemory policy is not selected, access time not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust."
+	msgFDebugUsage        = "ERR Unknown subcommand or wrong number of arguments for '%s'. Try DEBUG HELP."
+	msgFCommandUsage      = "ERR Unknown subcommand or wrong number of arguments for '%s'. Try COMMAND HELP."
+	msgInvalidCommand     = "ERR Invalid command specified"
+	msgCommandNoKeys      = "ERR The command has no key arguments"
+	msgCommandArgs        = "ERR Invalid arguments specified for command"
+	msgCommandArgsNumber  = "ERR Invalid number of arguments specified for command"
+	msgFConfigUsage       = "ERR Unknown subcommand or wrong number of arguments for '%s'. Try CONFIG HELP."
+	msgClientName         = "ERR Client names cannot contain spaces, newlines or special characters."
+	msgFClientUsage       = "ERR Unknown subcommand or wrong number of arguments for '%s'. Try CLIENT HELP."
+	msgFConfigSetUnknown  = "ERR Unknown option or number of arguments for CONFIG SET - '%s'"
+	msgFConfigSetFailed   = "ERR CONFIG SET failed (possibly related to argument '%s') - %s"
 	msgSingleElementPair  = "ERR INCR option supports a single increment-element pair"
 	msgInvalidStreamID    = "ERR Invalid stream ID specified as stream command argument"
 	msgStreamIDTooSmall   = "ERR The ID specified in XADD is equal or smaller than the target stream top item"
 	msgStreamIDZero       = "ERR The ID specified in XADD must be greater than 0-0"
+	msgStreamRangeStart   = "ERR invalid start ID for the interval"
+	msgStreamRangeEnd     = "ERR invalid end ID for the interval"
+	msgNumIDs             = "ERR Number of IDs must be a positive integer"
+	msgXsetidSmaller      = "ERR The ID specified in XSETID is smaller than the target stream top item"
+	msgNumIDsMismatch     = "ERR The `numids` parameter must match the number of arguments"
+	msgFieldsMissing      = "ERR Mandatory argument FIELDS is missing or not at the right position"
+	msgNumFields          = "ERR Parameter `numFields` should be greater than 0"
+	msgNumFieldsMismatch  = "ERR The `numfields` parameter must match the number of arguments"
+	msgFInvalidExpireTime = "ERR invalid expire time in '%s' command"
 	msgNoScriptFound      = "NOSCRIPT No matching script. Please use EVAL."
+	msgFunctionNotFound   = "ERR Function not found"
+	msgLibraryNotFound    = "ERR Library not found"
+	msgFunctionPayload    = "ERR given type is not a function"
+	msgROFunction         = "ERR Can not execute a script with write flag using *_ro command."
+	msgNotBusy            = "NOTBUSY No scripts in execution right now."
+	msgBusyScript         = "BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE."
+	msgBusyFunction       = "BUSY Redis is busy running a script. You can only call FUNCTION KILL or SHUTDOWN NOSAVE."
+	msgUnkillable         = "UNKILLABLE Sorry the script already executed write commands against the dataset. You can either wait the script termination or kill the server in a hard way using the SHUTDOWN NOSAVE command."
+	msgNoShutdown         = "ERR No shutdown in progress."
 	msgUnsupportedUnit    = "ERR unsupported unit provided. please use m, km, ft, mi"
+	msgAnyWithoutCount    = "ERR the ANY argument requires COUNT argument"
+	msgOOM                = "OOM command not allowed when used memory > 'maxmemory'."
+	msgMaxClients         = "ERR max number of clients reached"
+	msgGeoStoreWith       = "ERR STORE option in GEORADIUS is not compatible with WITHDIST, WITHHASH and WITHCOORDS options"
 	msgNotFromScripts     = "This Redis command is not allowed from scripts"
+	msgROScript           = "ERR Write commands are not allowed from read-only scripts."
 	msgXreadUnbalanced    = "ERR Unbalanced XREAD list of streams: for each stream key an ID or '$' must be specified."
 	msgXgroupKeyNotFound  = "ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically."
+	msgXgroupEntriesRead  = "ERR value for ENTRIESREAD must be positive or -1"
+	msgFXgroupUsage       = "ERR unknown subcommand or wrong number of arguments for '%s'. Try XGROUP HELP."
 )
 
 func errWrongNumber(cmd string) string {
@@ -49,10 +127,18 @@ func errLuaParseError(err error) string {
 	return fmt.Sprintf("ERR Error compiling script (new function): %s", err.Error())
 }
 
+func errLuaFunction(err error) string {
+	return fmt.Sprintf("ERR Error running function: %s", err.Error())
+}
+
 func errReadgroup(key, group string) error {
 	return fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
 }
 
+func errNoGroup(key, group string) error {
+	return fmt.Errorf("NOGROUP No such consumer group '%s' for key name '%s'", group, key)
+}
+
 func errXreadgroup(key, group string) error {
 	return fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", key, group)
 }
@@ -69,31 +155,50 @@ func withTx(
 	if ctx.nested {
 		// this is a call via Lua's .call(). It's already locked.
 		cb(c, ctx)
-		m.signal.Broadcast()
 		return
 	}
 
 	if inTx(ctx) {
-		addTxCmd(ctx, cb)
+		// stats are for when it runs, in EXEC
+		cmd := c.Command()
+		addTxCmd(ctx, func(c *server.Peer, ctx *connCtx) {
+			m.srv.Count(c, cmd, func() { cb(c, ctx) })
+		})
+		c.SkipStats()
 		c.WriteInline("QUEUED")
 		return
 	}
 	m.Lock()
 	cb(c, ctx)
-	// done, wake up anyone who waits on anything.
-	m.signal.Broadcast()
 	m.Unlock()
 }
 
+// parseFloatTimeout parses the timeout of a blocking command, in (possibly
+// fractional) seconds. Returns an error message on failure.
+func parseFloatTimeout(s string) (time.Duration, string) {
+	timeout, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(timeout) || math.IsInf(timeout, 0) {
+		return 0, msgInvalidTimeout
+	}
+	if timeout < 0 {
+		return 0, msgNegTimeout
+	}
+	return time.Duration(timeout * float64(time.Second)), ""
+}
+
 // blockCmd is executed returns whether it is done
 type blockCmd func(*server.Peer, *connCtx) bool
 
 // blocking keeps trying a command until the callback returns true. Calls
-// onTimeout after the timeout (or when we call this in a transaction).
+// onTimeout after the timeout (or right away in a transaction or a script).
+// The callback is only retried when one of the keys is signaled via
+// keyReady(). Clients blocked on the same key are served in the order they
+// blocked, same as Redis.
 func blocking(
 	m *Miniredis,
 	c *server.Peer,
 	timeout time.Duration,
+	keys []string,
 	cb blockCmd,
 	onTimeout func(*server.Peer),
 ) {
@@ -102,13 +207,13 @@ func blocking(
 		dl  *time.Timer
 		dlc <-chan time.Time
 	)
-	if inTx(ctx) {
-		addTxCmd(ctx, func(c *server.Peer, ctx *connCtx) {
+	if inTx(ctx) || ctx.nested {
+		// Inside MULTI and from Lua nothing blocks, it's a timeout right away.
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 			if !cb(c, ctx) {
 				onTimeout(c)
 			}
 		})
-		c.WriteInline("QUEUED")
 		return
 	}
 	if timeout != 0 {
@@ -119,36 +224,127 @@ func blocking(
 
 	m.Lock()
 	defer m.Unlock()
-	for {
-		done := cb(c, ctx)
-		if done {
-			return
+	if cb(c, ctx) {
+		return
+	}
+
+	db := ctx.selectedDB
+	wakeup := m.block(db, keys)
+	woken := false
+	ctx.blocked = true
+	defer func() {
+		ctx.blocked = false
+		// a wakeup which came in while we timed out is for the next client.
+		select {
+		case <-wakeup:
+			woken = true
+		default:
 		}
-		// there is no cond.WaitTimeout(), so hence the the goroutine to wait
-		// for a timeout
-		var (
-			wg     sync.WaitGroup
-			wakeup = make(chan struct{}, 1)
-		)
-		wg.Add(1)
-		go func() {
-			m.signal.Wait()
-			wakeup <- struct{}{}
-			wg.Done()
-		}()
+		m.unblock(db, keys, wakeup, woken)
+	}()
+	for {
+		waitStart := time.Now()
+		m.Unlock()
+		var timedOut, closed bool
 		select {
 		case <-wakeup:
+			woken = true
 		case <-dlc:
+			timedOut = true
+		case <-m.Ctx.Done():
+			closed = true
+		}
+		m.Lock()
+		c.Waited(time.Since(waitStart))
+
+		switch {
+		case timedOut:
 			onTimeout(c)
-			m.signal.Broadcast() // to kill the wakeup go routine
-			wg.Wait()
 			return
-		case <-m.Ctx.Done():
-			m.signal.Broadcast() // to kill the wakeup go routine
-			wg.Wait()
+		case closed:
 			return
 		}
-		wg.Wait()
+		if cb(c, ctx) {
+			return
+		}
+		// nothing for us, maybe for the next one in line.
+		m.wakeNext(db, keys, wakeup)
+		woken = false
+	}
+}
+
+// block registers a blocked client for the given keys. The returned channel
+// gets a value whenever any of the keys is signaled. No locks!
+func (m *Miniredis) block(db int, keys []string) chan struct{} {
+	wakeup := make(chan struct{}, 1)
+	for _, k := range keys {
+		dk := dbKey{db: db, key: k}
+		m.blocked[dk] = append(m.blocked[dk], wakeup)
+	}
+	return wakeup
+}
+
+// unblock removes what block() registered. If woken is set the client
+// after this one gets the wakeup. No locks!
+func (m *Miniredis) unblock(db int, keys []string, wakeup chan struct{}, woken bool) {
+	for _, k := range keys {
+		dk := dbKey{db: db, key: k}
+		chans := m.blocked[dk]
+		for i, ch := range chans {
+			if ch == wakeup {
+				chans = append(chans[:i], chans[i+1:]...)
+				if woken && i < len(chans) {
+					wake(chans[i])
+				}
+				break
+			}
+		}
+		if len(chans) == 0 {
+			delete(m.blocked, dk)
+		} else {
+			m.blocked[dk] = chans
+		}
+	}
+}
+
+// wakeNext wakes up the clients blocked right after this one. No locks!
+func (m *Miniredis) wakeNext(db int, keys []string, wakeup chan struct{}) {
+	for _, k := range keys {
+		chans := m.blocked[dbKey{db: db, key: k}]
+		for i, ch := range chans {
+			if ch == wakeup {
+				if i+1 < len(chans) {
+					wake(chans[i+1])
+				}
+				break
+			}
+		}
+	}
+}
+
+// keyReady wakes up the longest waiting client blocked on a key. If that
+// client has no use for the key it'll pass it on to the next one, and so on.
+// Call it whenever something is added to a key a blocking command might be
+// waiting for. No locks!
+func (m *Miniredis) keyReady(db int, key string) {
+	if chans := m.blocked[dbKey{db: db, key: key}]; len(chans) > 0 {
+		wake(chans[0])
+	}
+}
+
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// dbReady wakes up all clients blocked on any key in a DB. No locks!
+func (m *Miniredis) dbReady(db int) {
+	for dk := range m.blocked {
+		if dk.db == db {
+			m.keyReady(dk.db, dk.key)
+		}
 	}
 }
 
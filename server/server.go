@@ -5,10 +5,13 @@ import (
 	"crypto/tls"
 	"fmt"
 	"math"
+	"math/bits"
 	"net"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
-	"unicode"
+	"time"
 )
 
 func errUnknownCommand(cmd string, args []string) string {
@@ -30,16 +33,56 @@ type DisconnectHandler func(c *Peer)
 // Hook is can be added to run before every cmd. Return true if the command is done.
 type Hook func(*Peer, string, ...string) bool
 
+// PostHook is can be added to run after every known cmd.
+type PostHook func(*Peer, string, ...string)
+
+// UnknownHook is can be added to run after an unknown cmd got its error.
+type UnknownHook func(*Peer, string, ...string)
+
+// ConnectHook is can be added to run for every new client, before its first
+// cmd. Return false to close the connection right away.
+type ConnectHook func(*Peer) bool
+
 // Server is a simple redis server
 type Server struct {
 	l         net.Listener
 	cmds      map[string]Cmd
 	preHook   Hook
-	peers     map[net.Conn]struct{}
+	checkHook Hook
+	postHook  PostHook
+	unknown   UnknownHook
+	connect   ConnectHook
+	peers     map[net.Conn]*Peer
 	mu        sync.Mutex
 	wg        sync.WaitGroup
+	lastID    int // last Peer.ID handed out
 	infoConns int
 	infoCmds  int
+	cmdStats  map[string]*CmdStats
+}
+
+// CmdStats has the INFO commandstats and latencystats numbers for a single
+// command.
+type CmdStats struct {
+	Calls    int
+	Failed   int           // calls which replied with an error
+	Duration time.Duration // total time spent in the command
+	latency  [64]int       // number of calls by bits.Len(microseconds)
+}
+
+// Percentile gives the latency of a percentile (0-100), in microseconds. The
+// latencies are kept in power of two buckets, this returns the upper bound of
+// the bucket.
+func (cs CmdStats) Percentile(p float64) int {
+	want := int(math.Ceil(p / 100 * float64(cs.Calls)))
+	n := 0
+	for b, c := range cs.latency {
+		n += c
+		if n >= want && n > 0 {
+			return 1<<uint(b) - 1
+		}
+	}
+	return 0
 }
 
 // NewServer makes a server listening on addr. Close with .Close().
@@ -61,9 +104,10 @@ func NewServerTLS(addr string, cfg *tls.Config) (*Server, error) {
 
 func newServer(l net.Listener) *Server {
 	s := Server{
-		cmds:  map[string]Cmd{},
-		peers: map[net.Conn]struct{}{},
-		l:     l,
+		cmds:     map[string]Cmd{},
+		peers:    map[net.Conn]*Peer{},
+		cmdStats: map[string]*CmdStats{},
+		l:        l,
 	}
 
 	s.wg.Add(1)
@@ -87,6 +131,42 @@ func (s *Server) SetPreHook(h Hook) {
 	s.mu.Unlock()
 }
 
+// PreHook gives the hook set with SetPreHook(), if any.
+func (s *Server) PreHook() Hook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.preHook
+}
+
+// (un)set a hook which is ran before every known command, after the pre hook.
+// It returns true if the command is done.
+func (s *Server) SetCheckHook(h Hook) {
+	s.mu.Lock()
+	s.checkHook = h
+	s.mu.Unlock()
+}
+
+// (un)set a hook which is ran after every known command.
+func (s *Server) SetPostHook(h PostHook) {
+	s.mu.Lock()
+	s.postHook = h
+	s.mu.Unlock()
+}
+
+// (un)set a hook which is ran after every unknown command.
+func (s *Server) SetUnknownHook(h UnknownHook) {
+	s.mu.Lock()
+	s.unknown = h
+	s.mu.Unlock()
+}
+
+// (un)set a hook which is ran for every new client.
+func (s *Server) SetConnectHook(h ConnectHook) {
+	s.mu.Lock()
+	s.connect = h
+	s.mu.Unlock()
+}
+
 func (s *Server) serve(l net.Listener) {
 	for {
 		conn, err := l.Accept()
@@ -101,15 +181,27 @@ func (s *Server) serve(l net.Listener) {
 func (s *Server) ServeConn(conn net.Conn) {
 	s.wg.Add(1)
 	s.mu.Lock()
-	s.peers[conn] = struct{}{}
 	s.infoConns++
+	s.lastID++
+	peer := &Peer{
+		ID:    s.lastID,
+		w:     bufio.NewWriter(conn),
+		addr:  conn.RemoteAddr(),
+		laddr: conn.LocalAddr(),
+	}
+	s.peers[conn] = peer
+	h := s.connect
 	s.mu.Unlock()
 
 	go func() {
 		defer s.wg.Done()
 		defer conn.Close()
 
-		s.servePeer(conn)
+		if h == nil || h(peer) {
+			s.servePeer(conn, peer)
+		} else {
+			peer.Flush()
+		}
 
 		s.mu.Lock()
 		delete(s.peers, conn)
@@ -153,11 +245,52 @@ func (s *Server) Register(cmd string, f Cmd) error {
 	return nil
 }
 
-func (s *Server) servePeer(c net.Conn) {
-	r := bufio.NewReader(c)
-	peer := &Peer{
-		w: bufio.NewWriter(c),
+// Registered is whether a command is registered.
+func (s *Server) Registered(cmd string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.cmds[strings.ToUpper(cmd)]
+	return ok
+}
+
+// Commands gives the names of all registered commands, sorted.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cmds []string
+	for c := range s.cmds {
+		cmds = append(cmds, c)
 	}
+	sort.Strings(cmds)
+	return cmds
+}
+
+// Disconnect closes the connection of a client right away, it doesn't wait
+// for a command to finish.
+func (s *Server) Disconnect(p *Peer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, peer := range s.peers {
+		if peer == p {
+			conn.Close()
+		}
+	}
+}
+
+// Peer returns the connected client with the given ID, or nil.
+func (s *Server) Peer(id int) *Peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.peers {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *Server) servePeer(c net.Conn, peer *Peer) {
+	r := bufio.NewReader(c)
 	defer func() {
 		for _, f := range peer.onDisconnect {
 			f()
@@ -195,16 +328,55 @@ func (s *Server) Dispatch(c *Peer, args []string) {
 
 	s.mu.Lock()
 	cb, ok := s.cmds[cmdUp]
+	uh := s.unknown
 	s.mu.Unlock()
 	if !ok {
 		c.WriteError(errUnknownCommand(cmd, args))
+		if uh != nil {
+			uh(c, cmdUp, args...)
+		}
 		return
 	}
 
+	s.mu.Lock()
+	ch := s.checkHook
+	s.mu.Unlock()
+	if ch != nil {
+		if ch(c, cmdUp, args...) {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	ph := s.postHook
+	s.mu.Unlock()
+	c.cmd, c.skipStats = cmdUp, false
+	s.Count(c, cmdUp, func() {
+		cb(c, cmdUp, args)
+	})
+	if ph != nil {
+		ph(c, cmdUp, args...)
+	}
+}
+
+// Count runs f, and counts it as a call of cmd in the command stats. Time
+// given to Peer.Waited() is not part of the latency. Dispatch() does this
+// for every command, this is for commands which are run later, after they
+// used Peer.SkipStats().
+func (s *Server) Count(c *Peer, cmd string, f func()) {
+	errs, waited := c.errorCount(), c.waited
+	c.waited = 0
+	start := time.Now()
+	f()
+	d := time.Since(start) - c.waited
+	c.waited = waited
+	if c.skipStats {
+		return
+	}
 	s.mu.Lock()
 	s.infoCmds++
 	s.mu.Unlock()
-	cb(c, cmdUp, args)
+	s.addStats(cmd, d, c.errorCount() > errs)
 }
 
 // TotalCommands is total (known) commands since this the server started
@@ -214,6 +386,57 @@ func (s *Server) TotalCommands() int {
 	return s.infoCmds
 }
 
+// Peers gives all connected clients, ordered by ID.
+func (s *Server) Peers() []*Peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps := make([]*Peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].ID < ps[j].ID })
+	return ps
+}
+
+// addStats counts a call of a command.
+func (s *Server) addStats(cmd string, d time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cs, ok := s.cmdStats[cmd]
+	if !ok {
+		cs = &CmdStats{}
+		s.cmdStats[cmd] = cs
+	}
+	cs.Calls++
+	if failed {
+		cs.Failed++
+	}
+	cs.Duration += d
+	cs.latency[bits.Len64(uint64(d/time.Microsecond))]++
+}
+
+// CommandStats gives the stats of every command called since the server
+// started, or since ResetStats(). Keys are uppercase command names.
+func (s *Server) CommandStats() map[string]CmdStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make(map[string]CmdStats, len(s.cmdStats))
+	for k, v := range s.cmdStats {
+		res[k] = *v
+	}
+	return res
+}
+
+// ResetStats clears the command stats, and the TotalCommands() and
+// TotalConnections() counters.
+func (s *Server) ResetStats() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cmdStats = map[string]*CmdStats{}
+	s.infoCmds = 0
+	s.infoConns = 0
+}
+
 // ClientsLen gives the number of connected clients right now
 func (s *Server) ClientsLen() int {
 	s.mu.Lock()
@@ -231,12 +454,18 @@ func (s *Server) TotalConnections() int {
 
 // Peer is a client connected to the server
 type Peer struct {
+	ID           int // unique per server, starting at 1. 0 for NewPeer()s
 	w            *bufio.Writer
 	closed       bool
 	Resp3        bool
-	Ctx          interface{} // anything goes, server won't touch this
-	onDisconnect []func()    // list of callbacks
-	mu           sync.Mutex  // for Block()
+	Ctx          interface{}   // anything goes, server won't touch this
+	onDisconnect []func()      // list of callbacks
+	errors       int           // number of WriteError() calls
+	addr, laddr  net.Addr      // nil for NewPeer()s
+	mu           sync.Mutex    // for Block()
+	cmd          string        // the running command
+	skipStats    bool          // see SkipStats()
+	waited       time.Duration // see Waited()
 }
 
 func NewPeer(w *bufio.Writer) *Peer {
@@ -245,6 +474,22 @@ func NewPeer(w *bufio.Writer) *Peer {
 	}
 }
 
+// RemoteAddr is the address of the client, or "" for NewPeer()s.
+func (c *Peer) RemoteAddr() string {
+	if c.addr == nil {
+		return ""
+	}
+	return c.addr.String()
+}
+
+// LocalAddr is the address the client connected to, or "" for NewPeer()s.
+func (c *Peer) LocalAddr() string {
+	if c.laddr == nil {
+		return ""
+	}
+	return c.laddr.String()
+}
+
 // Flush the write buffer. Called automatically after every redis command
 func (c *Peer) Flush() {
 	c.mu.Lock()
@@ -259,6 +504,23 @@ func (c *Peer) Close() {
 	c.closed = true
 }
 
+// Command gives the (uppercase) command which is running right now.
+func (c *Peer) Command() string {
+	return c.cmd
+}
+
+// SkipStats makes the running command not count in the command stats, for
+// commands which are only queued. Use Server.Count() when they run.
+func (c *Peer) SkipStats() {
+	c.skipStats = true
+}
+
+// Waited tells the running command has been blocked, waiting for something,
+// for d. That doesn't count as latency in the command stats.
+func (c *Peer) Waited(d time.Duration) {
+	c.waited += d
+}
+
 // Register a function to execute on disconnect. There can be multiple
 // functions registered.
 func (c *Peer) OnDisconnect(f func()) {
@@ -275,10 +537,17 @@ func (c *Peer) Block(f func(*Writer)) {
 // WriteError writes a redis 'Error'
 func (c *Peer) WriteError(e string) {
 	c.Block(func(w *Writer) {
+		c.errors++
 		w.WriteError(e)
 	})
 }
 
+func (c *Peer) errorCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.errors
+}
+
 // WriteInline writes a redis inline string
 func (c *Peer) WriteInline(s string) {
 	c.Block(func(w *Writer) {
@@ -340,6 +609,13 @@ func (c *Peer) WriteInt(n int) {
 	})
 }
 
+// WriteInt64 writes a 64 bit integer
+func (c *Peer) WriteInt64(n int64) {
+	c.Block(func(w *Writer) {
+		w.WriteInt64(n)
+	})
+}
+
 // WriteFloat writes a float
 func (c *Peer) WriteFloat(n float64) {
 	c.Block(func(w *Writer) {
@@ -355,12 +631,15 @@ func (c *Peer) WriteRaw(s string) {
 }
 
 func toInline(s string) string {
-	return strings.Map(func(r rune) rune {
-		if unicode.IsSpace(r) {
-			return ' '
+	// works on bytes, so binary data isn't turned into U+FFFD
+	b := []byte(s)
+	for i, c := range b {
+		switch c {
+		case '\r', '\n', '\t', '\v', '\f':
+			b[i] = ' '
 		}
-		return r
-	}, s)
+	}
+	return string(b)
 }
 
 // A Writer is given to the callback in Block()
@@ -412,6 +691,11 @@ func (w *Writer) WriteInt(n int) {
 	fmt.Fprintf(w.w, ":%d\r\n", n)
 }
 
+// WriteInt64 writes a 64 bit integer
+func (w *Writer) WriteInt64(n int64) {
+	fmt.Fprintf(w.w, ":%d\r\n", n)
+}
+
 // WriteFloat writes a float
 func (w *Writer) WriteFloat(n float64) {
 	if w.resp3 {
@@ -444,7 +728,8 @@ func (w *Writer) Flush() {
 	w.w.Flush()
 }
 
-// formatFloat formats a float the way redis does (sort-of)
+// formatFloat formats a float the way redis does: 17 significant digits,
+// and "inf" and "-inf".
 func formatFloat(v float64) string {
 	if math.IsInf(v, 1) {
 		return "inf"
@@ -452,21 +737,5 @@ func formatFloat(v float64) string {
 	if math.IsInf(v, -1) {
 		return "-inf"
 	}
-	return stripZeros(fmt.Sprintf("%.12f", v))
-}
-
-func stripZeros(sv string) string {
-	for strings.Contains(sv, ".") {
-		if sv[len(sv)-1] != '0' {
-			break
-		}
-		// Remove trailing 0s.
-		sv = sv[:len(sv)-1]
-		// Ends with a '.'.
-		if sv[len(sv)-1] == '.' {
-			sv = sv[:len(sv)-1]
-			break
-		}
-	}
-	return sv
+	return strconv.FormatFloat(v, 'g', 17, 64)
 }
@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"strconv"
 	"strings"
 	"testing"
@@ -165,6 +166,54 @@ func Test(t *testing.T) {
 			t.Errorf("have: %s, want: %s", have, want)
 		}
 	}
+
+	{
+		stats := s.CommandStats()
+		if have, want := stats["PING"].Calls, 2; have != want {
+			t.Errorf("have: %d, want: %d", have, want)
+		}
+		if have, want := stats["ECHO"].Calls, 3; have != want {
+			t.Errorf("have: %d, want: %d", have, want)
+		}
+		if have, want := stats["ECHO"].Failed, 1; have != want {
+			t.Errorf("have: %d, want: %d", have, want)
+		}
+		if _, ok := stats["NOSUCH"]; ok {
+			t.Errorf("unknown commands should not have stats")
+		}
+
+		s.ResetStats()
+		if have, want := len(s.CommandStats()), 0; have != want {
+			t.Errorf("have: %d, want: %d", have, want)
+		}
+		if have, want := s.TotalCommands(), 0; have != want {
+			t.Errorf("have: %d, want: %d", have, want)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var cs CmdStats
+	if have, want := cs.Percentile(50), 0; have != want {
+		t.Errorf("have: %d, want: %d", have, want)
+	}
+	cs.Calls = 10
+	cs.latency[1] = 5 // 1us
+	cs.latency[4] = 4 // 8-15us
+	cs.latency[7] = 1 // 64-127us
+	for p, want := range map[float64]int{
+		0:    1,
+		50:   1,
+		51:   15,
+		90:   15,
+		99:   127,
+		99.9: 127,
+		100:  127,
+	} {
+		if have := cs.Percentile(p); have != want {
+			t.Errorf("p%v have: %d, want: %d", p, have, want)
+		}
+	}
 }
 
 func testServerTLS(t *testing.T) *tls.Config {
@@ -236,3 +285,35 @@ func TestTLS(t *testing.T) {
 		t.Errorf("have: %s, want: %s", have, want)
 	}
 }
+
+func TestToInline(t *testing.T) {
+	for in, want := range map[string]string{
+		"simple":      "simple",
+		"new\r\nline": "new  line",
+		"tab\tv\vf\f": "tab v f ",
+		"bin\x00\xff": "bin\x00\xff",
+		"caf\xc3\xa9": "caf\xc3\xa9",
+	} {
+		if have := toInline(in); have != want {
+			t.Errorf("toInline(%q): have %q, want %q", in, have, want)
+		}
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	for in, want := range map[float64]string{
+		1:             "1",
+		-2:            "-2",
+		1.5:           "1.5",
+		1.1:           "1.1000000000000001",
+		0.1:           "0.10000000000000001",
+		1e20:          "1e+20",
+		math.Inf(1):   "inf",
+		math.Inf(-1):  "-inf",
+		3.14159265359: "3.1415926535900001",
+	} {
+		if have := formatFloat(in); have != want {
+			t.Errorf("formatFloat(%v): have %q, want %q", in, have, want)
+		}
+	}
+}
@@ -14,8 +14,11 @@ import (
 
 // a Stream is a list of entries, lowest ID (oldest) first, and all "groups".
 type streamKey struct {
-	entries []StreamEntry
-	groups  map[string]*streamGroup
+	entries      []StreamEntry
+	groups       map[string]*streamGroup
+	entriesAdded int    // total number of entries ever added
+	maxDeletedID string // highest ID removed with XDEL, or "0-0"
+	lastGenID    string // ID of the last added entry, even if it's deleted
 }
 
 // a StreamEntry is an entry in a stream. The ID is always of the form
@@ -26,15 +29,42 @@ type StreamEntry struct {
 	Values []string
 }
 
+// StreamGroup is the state of a consumer group, as returned by
+// Miniredis.StreamGroup().
+type StreamGroup struct {
+	Name      string
+	LastID    string
+	Consumers []StreamConsumer // sorted by name
+	Pending   []PendingEntry   // lowest ID first
+}
+
+// StreamConsumer is a consumer in a StreamGroup.
+type StreamConsumer struct {
+	Name       string
+	Pending    int       // number of pending entries
+	SeenTime   time.Time // last XREADGROUP by this consumer
+	ActiveTime time.Time // last XREADGROUP which delivered entries. Zero if never.
+}
+
+// PendingEntry is an entry in the pending entries list of a StreamGroup.
+type PendingEntry struct {
+	ID            string
+	Consumer      string
+	DeliveryCount int
+	LastDelivery  time.Time
+}
+
 type streamGroup struct {
-	stream    *streamKey
-	lastID    string
-	pending   []pendingEntry
-	consumers map[string]consumer
+	stream      *streamKey
+	lastID      string
+	entriesRead int // -1 if unknown
+	pending     []pendingEntry
+	consumers   map[string]consumer
 }
 
 type consumer struct {
-	// TODO: "last seen" timestamp
+	seenTime   time.Time // last interaction
+	activeTime time.Time // last successful read, zero if never
 }
 
 type pendingEntry struct {
@@ -46,7 +76,9 @@ type pendingEntry struct {
 
 func newStreamKey() *streamKey {
 	return &streamKey{
-		groups: map[string]*streamGroup{},
+		groups:       map[string]*streamGroup{},
+		maxDeletedID: "0-0",
+		lastGenID:    "0-0",
 	}
 }
 
@@ -63,12 +95,64 @@ func (s *streamKey) generateID(now time.Time) string {
 	return fmt.Sprintf("%d-%d", last[0], last[1]+1)
 }
 
+// lastID is the last generated ID. The entry might have been deleted since.
 func (s *streamKey) lastID() string {
+	return s.lastGenID
+}
+
+// setLastID changes the last generated ID. It can't go below the last entry.
+func (s *streamKey) setLastID(id string) error {
+	id, err := formatStreamID(id)
+	if err != nil {
+		return errors.New(msgInvalidStreamID)
+	}
+	if len(s.entries) > 0 && streamCmp(id, s.entries[len(s.entries)-1].ID) < 0 {
+		return errors.New(msgXsetidSmaller)
+	}
+	s.lastGenID = id
+	return nil
+}
+
+func (s *streamKey) firstID() string {
 	if len(s.entries) == 0 {
 		return "0-0"
 	}
 
-	return s.entries[len(s.entries)-1].ID
+	return s.entries[0].ID
+}
+
+// hasTombstonesFrom tells whether an XDEL'ed entry could be at or after id.
+func (s *streamKey) hasTombstonesFrom(id string) bool {
+	if len(s.entries) == 0 || s.maxDeletedID == "0-0" {
+		return false
+	}
+	return streamCmp(s.maxDeletedID, id) >= 0
+}
+
+// entriesReadUntil gives the number of entries ever added up to and including
+// id, the way Redis estimates it. Returns -1 if that can't be known.
+func (s *streamKey) entriesReadUntil(id string) int {
+	if s.entriesAdded == 0 {
+		return 0
+	}
+	if len(s.entries) == 0 && streamCmp(id, s.lastID()) < 1 {
+		return s.entriesAdded
+	}
+	switch streamCmp(id, s.lastID()) {
+	case 0:
+		return s.entriesAdded
+	case 1:
+		return -1
+	}
+	if s.maxDeletedID == "0-0" || streamCmp(s.maxDeletedID, s.firstID()) < 0 {
+		switch streamCmp(id, s.firstID()) {
+		case -1:
+			return s.entriesAdded - len(s.entries)
+		case 0:
+			return s.entriesAdded - len(s.entries) + 1
+		}
+	}
+	return -1
 }
 
 func parseStreamID(id string) ([2]uint64, error) {
@@ -76,13 +160,16 @@ func parseStreamID(id string) ([2]uint64, error) {
 		res [2]uint64
 		err error
 	)
-	parts := strings.SplitN(id, "-", 2)
-	res[0], err = strconv.ParseUint(parts[0], 10, 64)
+	ms, seq := id, ""
+	if i := strings.IndexByte(id, '-'); i >= 0 {
+		ms, seq = id[:i], id[i+1:]
+	}
+	res[0], err = strconv.ParseUint(ms, 10, 64)
 	if err != nil {
 		return res, errors.New(msgInvalidStreamID)
 	}
-	if len(parts) == 2 {
-		res[1], err = strconv.ParseUint(parts[1], 10, 64)
+	if seq != "" || len(ms) < len(id) {
+		res[1], err = strconv.ParseUint(seq, 10, 64)
 		if err != nil {
 			return res, errors.New(msgInvalidStreamID)
 		}
@@ -133,6 +220,11 @@ func formatStreamID(id string) (string, error) {
 }
 
 func formatStreamRangeBound(id string, start bool, reverse bool) (string, error) {
+	lower := start != reverse
+	if strings.HasPrefix(id, "(") && len(id) > 1 {
+		return formatStreamExclusiveBound(id[1:], lower)
+	}
+
 	if id == "-" {
 		return "0-0", nil
 	}
@@ -156,13 +248,73 @@ func formatStreamRangeBound(id string, start bool, reverse bool) (string, error)
 		return "", errInvalidEntryID
 	}
 
-	if (!start && !reverse) || (start && reverse) {
+	if !lower {
 		return fmt.Sprintf("%d-%d", ts, uint64(math.MaxUint64)), nil
 	}
 
 	return fmt.Sprintf("%d-%d", ts, 0), nil
 }
 
+// formatStreamExclusiveBound handles the "(123-4" range syntax. It returns
+// the inclusive bound.
+func formatStreamExclusiveBound(id string, lower bool) (string, error) {
+	if id == "-" || id == "+" {
+		return "", errInvalidEntryID
+	}
+	if _, err := parseStreamID(id); err != nil {
+		return "", errInvalidEntryID
+	}
+	if !strings.Contains(id, "-") {
+		if lower {
+			id += "-0"
+		} else {
+			id += fmt.Sprintf("-%d", uint64(math.MaxUint64))
+		}
+	}
+	if lower {
+		next, ok := streamIncrID(id)
+		if !ok {
+			return "", errors.New(msgStreamRangeStart)
+		}
+		return next, nil
+	}
+	prev, ok := streamDecrID(id)
+	if !ok {
+		return "", errors.New(msgStreamRangeEnd)
+	}
+	return prev, nil
+}
+
+// streamIncrID gives the ID following id. Returns false if there is none.
+func streamIncrID(id string) (string, bool) {
+	p, _ := parseStreamID(id)
+	switch {
+	case p[1] < math.MaxUint64:
+		p[1]++
+	case p[0] < math.MaxUint64:
+		p[0]++
+		p[1] = 0
+	default:
+		return "", false
+	}
+	return fmt.Sprintf("%d-%d", p[0], p[1]), true
+}
+
+// streamDecrID gives the ID before id. Returns false if there is none.
+func streamDecrID(id string) (string, bool) {
+	p, _ := parseStreamID(id)
+	switch {
+	case p[1] > 0:
+		p[1]--
+	case p[0] > 0:
+		p[0]--
+		p[1] = math.MaxUint64
+	default:
+		return "", false
+	}
+	return fmt.Sprintf("%d-%d", p[0], p[1]), true
+}
+
 func reversedStreamEntries(o []StreamEntry) []StreamEntry {
 	newStream := make([]StreamEntry, len(o))
 	for i, e := range o {
@@ -179,14 +331,34 @@ func (s *streamKey) createGroup(group, id string) error {
 	if id == "$" {
 		id = s.lastID()
 	}
+	id, err := formatStreamID(id)
+	if err != nil {
+		return errors.New(msgInvalidStreamID)
+	}
 	s.groups[group] = &streamGroup{
-		stream:    s,
-		lastID:    id,
-		consumers: map[string]consumer{},
+		stream:      s,
+		lastID:      id,
+		entriesRead: -1,
+		consumers:   map[string]consumer{},
 	}
 	return nil
 }
 
+// setID changes the last delivered ID of the group. "$" is the last ID of the
+// stream. entriesRead can be -1 if it's unknown.
+func (g *streamGroup) setID(id string, entriesRead int) error {
+	if id == "$" {
+		id = g.stream.lastID()
+	}
+	id, err := formatStreamID(id)
+	if err != nil {
+		return errors.New(msgInvalidStreamID)
+	}
+	g.lastID = id
+	g.entriesRead = entriesRead
+	return nil
+}
+
 // streamAdd adds an entry to a stream. Returns the new entry ID.
 // If id is empty or "*" the ID will be generated automatically.
 // `values` should have an even length.
@@ -210,6 +382,8 @@ func (s *streamKey) add(entryID string, values []string, now time.Time) (string,
 		ID:     entryID,
 		Values: values,
 	})
+	s.entriesAdded++
+	s.lastGenID = entryID
 	return entryID, nil
 }
 
@@ -219,6 +393,19 @@ func (s *streamKey) trim(n int) {
 	}
 }
 
+// trimApprox emulates "MAXLEN ~ n". Redis only removes complete nodes of
+// its radix tree, so we remove entries in blocks of `block` entries, but never
+// go below n. A block of 0 or less trims exactly.
+func (s *streamKey) trimApprox(n, block int) {
+	if block <= 0 {
+		s.trim(n)
+		return
+	}
+	for len(s.entries)-block >= n {
+		s.entries = s.entries[block:]
+	}
+}
+
 // all entries after "id"
 func (s *streamKey) after(id string) []StreamEntry {
 	pos := sort.Search(len(s.entries), func(i int) bool {
@@ -227,6 +414,20 @@ func (s *streamKey) after(id string) []StreamEntry {
 	return s.entries[pos:]
 }
 
+// all entries with start <= ID <= end
+func (s *streamKey) between(start, end string) []StreamEntry {
+	from := sort.Search(len(s.entries), func(i int) bool {
+		return streamCmp(start, s.entries[i].ID) <= 0
+	})
+	to := sort.Search(len(s.entries), func(i int) bool {
+		return streamCmp(end, s.entries[i].ID) < 0
+	})
+	if to < from {
+		return nil
+	}
+	return s.entries[from:to]
+}
+
 // get a stream entry by ID
 // Also returns the position in the entries slice, if found.
 func (s *streamKey) get(id string) (int, *StreamEntry) {
@@ -246,6 +447,10 @@ func (g *streamGroup) readGroup(
 	count int,
 	noack bool,
 ) []StreamEntry {
+	con := g.consumers[consumerID]
+	con.seenTime = now
+	g.consumers[consumerID] = con
+
 	if id == ">" {
 		// undelivered messages
 		msgs := g.stream.after(g.lastID)
@@ -257,8 +462,9 @@ func (g *streamGroup) readGroup(
 			msgs = msgs[:count]
 		}
 
-		if !noack {
-			for _, msg := range msgs {
+		for _, msg := range msgs {
+			g.countRead(msg.ID)
+			if !noack {
 				g.pending = append(g.pending, pendingEntry{
 					id:            msg.ID,
 					consumer:      consumerID,
@@ -267,13 +473,15 @@ func (g *streamGroup) readGroup(
 				})
 			}
 		}
-		g.consumers[consumerID] = consumer{}
 		g.lastID = msgs[len(msgs)-1].ID
+		g.consumers[consumerID] = consumer{
+			seenTime:   now,
+			activeTime: now,
+		}
 		return msgs
 	}
 
 	// re-deliver messages from the pending list.
-	// con := gr.consumers[consumerID]
 	msgs := g.pendingAfter(id)
 	var res []StreamEntry
 	for i, p := range msgs {
@@ -293,6 +501,55 @@ func (g *streamGroup) readGroup(
 	return res
 }
 
+// countRead updates the entries-read counter after delivering id.
+func (g *streamGroup) countRead(id string) {
+	if g.entriesRead != -1 && !g.stream.hasTombstonesFrom(id) {
+		g.entriesRead++
+		return
+	}
+	if g.stream.entriesAdded > 0 {
+		g.entriesRead = g.stream.entriesReadUntil(id)
+	}
+}
+
+// lag is the number of entries not yet delivered to the group. The bool is
+// false if that can't be known, because of XDEL'ed entries.
+func (g *streamGroup) lag() (int, bool) {
+	s := g.stream
+	if s.entriesAdded == 0 {
+		return 0, true
+	}
+	if g.entriesRead != -1 && !s.hasTombstonesFrom(g.lastID) {
+		return s.entriesAdded - g.entriesRead, true
+	}
+	if n := s.entriesReadUntil(g.lastID); n != -1 {
+		return s.entriesAdded - n, true
+	}
+	return 0, false
+}
+
+// setPending adds or replaces an entry in the PEL.
+func (g *streamGroup) setPending(p pendingEntry) {
+	pos := sort.Search(len(g.pending), func(i int) bool {
+		return streamCmp(p.id, g.pending[i].id) <= 0
+	})
+	if pos < len(g.pending) && g.pending[pos].id == p.id {
+		g.pending[pos] = p
+	} else {
+		g.pending = append(g.pending, pendingEntry{})
+		copy(g.pending[pos+1:], g.pending[pos:])
+		g.pending[pos] = p
+	}
+
+	if _, ok := g.consumers[p.consumer]; !ok {
+		g.consumers[p.consumer] = consumer{seenTime: p.lastDelivery}
+	}
+	if streamCmp(p.id, g.lastID) > 0 {
+		g.lastID = p.id
+		g.entriesRead = -1
+	}
+}
+
 func (g *streamGroup) ack(ids []string) (int, error) {
 	count := 0
 	for _, id := range ids {
@@ -326,11 +583,42 @@ func (s *streamKey) delete(ids []string) (int, error) {
 		}
 
 		s.entries = append(s.entries[:i], s.entries[i+1:]...)
+		if streamCmp(id, s.maxDeletedID) > 0 {
+			s.maxDeletedID = id
+		}
 		count++
 	}
 	return count, nil
 }
 
+// referenced tells whether a consumer group still needs the entry: it's
+// either not delivered yet, or it's in a pending entries list.
+func (s *streamKey) referenced(id string) bool {
+	for _, g := range s.groups {
+		if streamCmp(id, g.lastID) > 0 {
+			return true
+		}
+		if g.isPending(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteRefs removes an ID from the pending entries list of every group.
+func (s *streamKey) deleteRefs(id string) {
+	for _, g := range s.groups {
+		g.ack([]string{id})
+	}
+}
+
+func (g *streamGroup) isPending(id string) bool {
+	pos := sort.Search(len(g.pending), func(i int) bool {
+		return streamCmp(id, g.pending[i].id) <= 0
+	})
+	return pos < len(g.pending) && g.pending[pos].id == id
+}
+
 func (g *streamGroup) pendingAfter(id string) []pendingEntry {
 	pos := sort.Search(len(g.pending), func(i int) bool {
 		return streamCmp(id, g.pending[i].id) < 0
@@ -347,3 +635,75 @@ func (g *streamGroup) pendingCount(consumer string) int {
 	}
 	return n
 }
+
+// export makes a copy of the group, for use outside of miniredis.
+func (g *streamGroup) export(name string) *StreamGroup {
+	res := &StreamGroup{
+		Name:   name,
+		LastID: g.lastID,
+	}
+	var names []string
+	for c := range g.consumers {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	for _, c := range names {
+		con := g.consumers[c]
+		res.Consumers = append(res.Consumers, StreamConsumer{
+			Name:       c,
+			Pending:    g.pendingCount(c),
+			SeenTime:   con.seenTime,
+			ActiveTime: con.activeTime,
+		})
+	}
+	for _, p := range g.pending {
+		res.Pending = append(res.Pending, PendingEntry{
+			ID:            p.id,
+			Consumer:      p.consumer,
+			DeliveryCount: p.deliveryCount,
+			LastDelivery:  p.lastDelivery,
+		})
+	}
+	return res
+}
+
+// fastForward ages all consumer and pending entry timestamps, so idle times go
+// up.
+func (s *streamKey) fastForward(d time.Duration) {
+	for _, g := range s.groups {
+		for name, con := range g.consumers {
+			con.seenTime = con.seenTime.Add(-d)
+			if !con.activeTime.IsZero() {
+				con.activeTime = con.activeTime.Add(-d)
+			}
+			g.consumers[name] = con
+		}
+		for i := range g.pending {
+			g.pending[i].lastDelivery = g.pending[i].lastDelivery.Add(-d)
+		}
+	}
+}
+
+// copy makes a deep copy of the stream, including all groups.
+func (s *streamKey) copy() *streamKey {
+	cp := *s
+	cp.entries = make([]StreamEntry, len(s.entries))
+	for i, e := range s.entries {
+		cp.entries[i] = StreamEntry{
+			ID:     e.ID,
+			Values: append([]string(nil), e.Values...),
+		}
+	}
+	cp.groups = map[string]*streamGroup{}
+	for name, g := range s.groups {
+		cg := *g
+		cg.stream = &cp
+		cg.pending = append([]pendingEntry(nil), g.pending...)
+		cg.consumers = map[string]consumer{}
+		for cn, con := range g.consumers {
+			cg.consumers[cn] = con
+		}
+		cp.groups[name] = &cg
+	}
+	return &cp
+}
@@ -1,6 +1,7 @@
 package miniredis
 
 import (
+	"fmt"
 	"testing"
 	"time"
 )
@@ -37,6 +38,26 @@ func TestFormatStreamID(t *testing.T) {
 	}
 }
 
+func TestParseStreamID(t *testing.T) {
+	test := func(id string, want [2]uint64, wantErr bool) {
+		t.Helper()
+		have, err := parseStreamID(id)
+		if (err != nil) != wantErr {
+			t.Errorf("parse(%q) err: %v", id, err)
+			return
+		}
+		if !wantErr && have != want {
+			t.Errorf("parse(%q) have %v, want %v", id, have, want)
+		}
+	}
+	test("1-2", [2]uint64{1, 2}, false)
+	test("1", [2]uint64{1, 0}, false)
+	test("1-", [2]uint64{}, true)
+	test("-1", [2]uint64{}, true)
+	test("1-2-3", [2]uint64{}, true)
+	test("foo", [2]uint64{}, true)
+}
+
 func TestStreamKey(t *testing.T) {
 	now := time.Now()
 
@@ -63,6 +84,20 @@ func TestStreamKey(t *testing.T) {
 		equals(t, 0, len(s.after("999-999")))
 	})
 
+	t.Run("between", func(t *testing.T) {
+		s := newStreamKey()
+		s.add("123-123", []string{"k", "v"}, now)
+		s.add("123-128", []string{"k", "v"}, now)
+		s.add("123-129", []string{"k", "v"}, now)
+
+		equals(t, 3, len(s.between("0-0", "999-0")))
+		equals(t, 3, len(s.between("123-123", "123-129")))
+		equals(t, 1, len(s.between("123-124", "123-128")))
+		equals(t, 0, len(s.between("123-124", "123-127")))
+		equals(t, 0, len(s.between("123-129", "123-123")))
+		equals(t, 0, len(s.between("999-0", "999-9")))
+	})
+
 	t.Run("get", func(t *testing.T) {
 		s := newStreamKey()
 		s.add("123-123", []string{"k", "v"}, now)
@@ -90,6 +125,19 @@ func TestStreamKey(t *testing.T) {
 		equals(t, (*StreamEntry)(nil), entry)
 	})
 
+	t.Run("trimApprox", func(t *testing.T) {
+		s := newStreamKey()
+		for i := 1; i <= 10; i++ {
+			s.add(fmt.Sprintf("1-%d", i), []string{"k", "v"}, now)
+		}
+		s.trimApprox(5, 3)
+		equals(t, 7, len(s.entries))
+		equalStr(t, "1-4", s.entries[0].ID)
+
+		s.trimApprox(5, 0)
+		equals(t, 5, len(s.entries))
+	})
+
 	t.Run("delete", func(t *testing.T) {
 		s := newStreamKey()
 		s.add("123-123", []string{"k", "v"}, now)
@@ -171,4 +219,36 @@ func TestStreamKeyGroup(t *testing.T) {
 		ls := g.readGroup(now, "consumer1", ">", 999, false)
 		equals(t, 0, len(ls))
 	})
+
+	t.Run("lag", func(t *testing.T) {
+		s := newStreamKey()
+		ok(t, s.createGroup("mygroup", "0"))
+		g := s.groups["mygroup"]
+		lag, known := g.lag()
+		equals(t, 0, lag)
+		equals(t, true, known)
+
+		s.add("1-1", []string{"k", "v"}, now)
+		s.add("1-2", []string{"k", "v"}, now)
+		s.add("1-3", []string{"k", "v"}, now)
+		lag, known = g.lag()
+		equals(t, 3, lag)
+		equals(t, true, known)
+
+		g.readGroup(now, "consumer1", ">", 1, false)
+		equals(t, 1, g.entriesRead)
+		lag, known = g.lag()
+		equals(t, 2, lag)
+		equals(t, true, known)
+
+		s.trim(2)
+		lag, known = g.lag()
+		equals(t, 2, lag)
+		equals(t, true, known)
+
+		_, err := s.delete([]string{"1-3"})
+		ok(t, err)
+		_, known = g.lag()
+		equals(t, false, known)
+	})
 }
@@ -0,0 +1,219 @@
+package miniredis
+
+// Client side caching support, see CLIENT TRACKING.
+//
+// Changed keys are collected by db.markDirty() while any client has tracking
+// on, and invalidated after every command, so changes made via the Go API only
+// send invalidation messages after the next command any client sends.
+//
+// RESP3 connections get "invalidate" push messages. With REDIRECT the messages
+// go to another connection, which for RESP2 is a message on the
+// "__redis__:invalidate" channel.
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+const invalidateChannel = "__redis__:invalidate"
+
+// clientTracking is the CLIENT TRACKING state of a single connection.
+type clientTracking struct {
+	bcast    bool
+	optin    bool
+	optout   bool
+	noloop   bool
+	redirect int                 // client ID, or 0
+	prefixes []string            // BCAST prefixes. None means all keys.
+	caching  string              // "yes" or "no", from CLIENT CACHING
+	keys     map[string]struct{} // keys to invalidate, if not BCAST
+}
+
+// trackRead is whether keys read by the current command should be tracked.
+func (t *clientTracking) trackRead() bool {
+	switch {
+	case t.bcast:
+		return false
+	case t.optin:
+		return t.caching == "yes"
+	case t.optout:
+		return t.caching != "no"
+	default:
+		return true
+	}
+}
+
+// matchPrefix is whether a key matches any of the BCAST prefixes.
+func (t *clientTracking) matchPrefix(key string) bool {
+	if len(t.prefixes) == 0 {
+		return true
+	}
+	for _, p := range t.prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// enableTracking turns on tracking for a connection, or updates the options
+// if it's already on. No locks!
+func (m *Miniredis) enableTracking(c *server.Peer, t *clientTracking) {
+	if len(m.trackers) == 0 {
+		m.changedKeys = map[string]struct{}{}
+	}
+	if old, ok := m.trackers[c]; ok {
+		// keep the keys we're already tracking
+		t.keys = old.keys
+		t.prefixes = append(old.prefixes, t.prefixes...)
+	} else {
+		c.OnDisconnect(func() {
+			m.Lock()
+			defer m.Unlock()
+			delete(m.trackers, c)
+		})
+	}
+	m.trackers[c] = t
+}
+
+// trackKeys remembers the keys read by the command for tracking clients, and
+// sends invalidation messages for all keys which changed. It runs after every
+// command.
+func (m *Miniredis) trackKeys(c *server.Peer, cmd string, args ...string) {
+	ctx := getCtx(c)
+	if ctx.nested {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	if len(m.trackers) == 0 {
+		return
+	}
+
+	if t, ok := m.trackers[c]; ok {
+		if !inTx(ctx) && readOnlyCommand(cmd) && t.trackRead() {
+			for _, k := range commandKeys(cmd, args) {
+				t.keys[k] = struct{}{}
+			}
+		}
+		// CLIENT CACHING is only for the next command
+		if cmd != "CLIENT" || len(args) == 0 || strings.ToUpper(args[0]) != "CACHING" {
+			t.caching = ""
+		}
+	}
+
+	m.invalidateChanged(c)
+}
+
+// invalidateChanged sends invalidation messages for all keys which changed
+// since the last check. Key names are tracked regardless of the DB they're in,
+// same as Redis does. c is the client which ran the command. No locks!
+func (m *Miniredis) invalidateChanged(c *server.Peer) {
+	if len(m.changedKeys) == 0 {
+		return
+	}
+	changed := make([]string, 0, len(m.changedKeys))
+	for k := range m.changedKeys {
+		changed = append(changed, k)
+	}
+	m.changedKeys = map[string]struct{}{}
+	sort.Strings(changed)
+
+	for peer, t := range m.trackers {
+		var keys []string
+		for _, k := range changed {
+			if t.bcast {
+				if t.matchPrefix(k) {
+					keys = append(keys, k)
+				}
+				continue
+			}
+			if _, ok := t.keys[k]; ok {
+				delete(t.keys, k)
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) == 0 || (t.noloop && peer == c) {
+			continue
+		}
+		if t.bcast {
+			m.sendInvalidate(peer, t, keys)
+			continue
+		}
+		for _, k := range keys {
+			m.sendInvalidate(peer, t, []string{k})
+		}
+	}
+}
+
+// trackingFlush invalidates everything, for FLUSHDB and FLUSHALL. No locks!
+func (m *Miniredis) trackingFlush() {
+	for peer, t := range m.trackers {
+		t.keys = map[string]struct{}{}
+		m.sendInvalidate(peer, t, nil)
+	}
+	m.changedKeys = map[string]struct{}{}
+}
+
+// sendInvalidate sends an "invalidate" message for a tracking connection. No
+// keys means all keys. No locks!
+func (m *Miniredis) sendInvalidate(c *server.Peer, t *clientTracking, keys []string) {
+	if t.redirect == 0 {
+		if c.Resp3 {
+			writeInvalidate(c, keys)
+		}
+		return
+	}
+
+	target := m.srv.Peer(t.redirect)
+	if target == nil {
+		if c.Resp3 {
+			c.Block(func(w *server.Writer) {
+				w.WritePushLen(2)
+				w.WriteBulk("tracking-redir-broken")
+				w.WriteInt(t.redirect)
+				w.Flush()
+			})
+		}
+		return
+	}
+	if target.Resp3 {
+		writeInvalidate(target, keys)
+		return
+	}
+	// RESP2 connections need to SUBSCRIBE to get these
+	if sub := getCtx(target).subscriber; sub == nil || !sub.subscribed(invalidateChannel) {
+		return
+	}
+	target.Block(func(w *server.Writer) {
+		w.WriteLen(3)
+		w.WriteBulk("message")
+		w.WriteBulk(invalidateChannel)
+		writeInvalidateKeys(w, keys)
+		w.Flush()
+	})
+}
+
+// writeInvalidate writes an "invalidate" push message.
+func writeInvalidate(c *server.Peer, keys []string) {
+	c.Block(func(w *server.Writer) {
+		w.WritePushLen(2)
+		w.WriteBulk("invalidate")
+		writeInvalidateKeys(w, keys)
+		w.Flush()
+	})
+}
+
+func writeInvalidateKeys(w *server.Writer, keys []string) {
+	if keys == nil {
+		w.WriteNull()
+		return
+	}
+	w.WriteLen(len(keys))
+	for _, k := range keys {
+		w.WriteBulk(k)
+	}
+}